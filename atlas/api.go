@@ -0,0 +1,154 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package atlas
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// BaseURL is the URL of Atlas Admin API
+const BaseURL = "https://cloud.mongodb.com/api/atlas/v1.0"
+
+// API calls Atlas Admin API with a programmatic API key
+type API struct {
+	baseURL    string
+	client     *http.Client
+	groupID    string
+	privateKey string
+	publicKey  string
+	verbose    bool
+}
+
+// NewAPI returns API of a project (group) with a public and a private key
+func NewAPI(publicKey string, privateKey string, groupID string) *API {
+	return &API{baseURL: BaseURL, client: &http.Client{Timeout: 10 * time.Minute}, groupID: groupID,
+		privateKey: privateKey, publicKey: publicKey}
+}
+
+// SetBaseURL sets base URL of Atlas Admin API
+func (api *API) SetBaseURL(baseURL string) {
+	api.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
+// SetVerbose sets verbose level
+func (api *API) SetVerbose(verbose bool) {
+	api.verbose = verbose
+}
+
+// get sends a GET request with digest authentication, the caller closes the body
+func (api *API) get(path string) (*http.Response, error) {
+	var err error
+	var req *http.Request
+	var resp *http.Response
+	uri := api.baseURL + path
+	if req, err = http.NewRequest(http.MethodGet, uri, nil); err != nil {
+		return nil, err
+	}
+	if resp, err = api.client.Do(req); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		if req, err = http.NewRequest(http.MethodGet, uri, nil); err != nil {
+			return nil, err
+		}
+		var auth string
+		if auth, err = api.getDigestAuthorization(challenge, http.MethodGet, req.URL.RequestURI()); err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", auth)
+		if resp, err = api.client.Do(req); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+// getJSON decodes a JSON response of a GET request
+func (api *API) getJSON(path string, doc interface{}) error {
+	resp, err := api.get(path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(doc)
+}
+
+// getDigestAuthorization returns an Authorization header of RFC 2617 digest authentication
+func (api *API) getDigestAuthorization(challenge string, method string, uri string) (string, error) {
+	if strings.HasPrefix(challenge, "Digest ") == false {
+		return "", errors.New("unsupported authentication " + challenge)
+	}
+	params := parseDigestChallenge(challenge[len("Digest "):])
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	cnonce := hex.EncodeToString(buf)
+	nc := "00000001"
+	ha1 := md5Hex(api.publicKey + ":" + params["realm"] + ":" + api.privateKey)
+	ha2 := md5Hex(method + ":" + uri)
+	auth := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s"`, api.publicKey, params["realm"], params["nonce"], uri)
+	if params["qop"] == "" {
+		auth += fmt.Sprintf(`, response="%s"`, md5Hex(ha1+":"+params["nonce"]+":"+ha2))
+	} else {
+		auth += fmt.Sprintf(`, qop=auth, nc=%s, cnonce="%s", response="%s"`, nc, cnonce,
+			md5Hex(ha1+":"+params["nonce"]+":"+nc+":"+cnonce+":auth:"+ha2))
+	}
+	if params["opaque"] != "" {
+		auth += fmt.Sprintf(`, opaque="%s"`, params["opaque"])
+	}
+	return auth + ", algorithm=MD5", nil
+}
+
+// parseDigestChallenge returns parameters of a challenge, e.g. realm="MMS Public API", nonce="abc", qop="auth"
+func parseDigestChallenge(str string) map[string]string {
+	params := map[string]string{}
+	parts := []string{}
+	quoted := false
+	begin := 0
+	for i, r := range str { // commas in quoted values, e.g. qop="auth,auth-int"
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ',' && quoted == false {
+			parts = append(parts, str[begin:i])
+			begin = i + 1
+		}
+	}
+	parts = append(parts, str[begin:])
+	for _, part := range parts {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) == 2 {
+			params[kv[0]] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return params
+}
+
+func md5Hex(str string) string {
+	sum := md5.Sum([]byte(str))
+	return hex.EncodeToString(sum[:])
+}
+
+// escape escapes a path segment
+func escape(str string) string {
+	return url.PathEscape(str)
+}
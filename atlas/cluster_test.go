@@ -0,0 +1,79 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package atlas
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseClusterURI(t *testing.T) {
+	cluster, err := ParseClusterURI("atlas://user:secret@g1/Cluster0?period=24h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cluster.api.groupID != "g1" || cluster.clusterName != "Cluster0" || cluster.period != 24*time.Hour {
+		t.Fatal(cluster)
+	}
+	if _, err = ParseClusterURI("atlas://user:secret@g1/Cluster0?period=day"); err == nil {
+		t.Fatal("expected an error of an invalid period")
+	}
+}
+
+func TestGetMeasurementsQuery(t *testing.T) {
+	query := getMeasurementsQuery(24*time.Hour, diskMetrics)
+	if query != "?granularity=PT5M&period=PT1440M&m=DISK_PARTITION_IOPS_TOTAL" {
+		t.Fatal(query)
+	}
+}
+
+func TestClusterGetReport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/groups/g1/clusters/Cluster0":
+			w.Write([]byte(`{"name": "Cluster0", "clusterType": "REPLICASET", "mongoDBVersion": "4.2.1", "replicationFactor": 3,
+				"providerSettings": {"providerName": "AWS", "instanceSizeName": "M30", "regionName": "US_EAST_1"}}`))
+		case "/groups/g1/processes":
+			w.Write([]byte(`{"results": [{"hostname": "cluster0-shard-00-00-abcde.mongodb.net", "port": 27017, "typeName": "REPLICA_PRIMARY"},
+				{"hostname": "cluster0-shard-00-01-abcde.mongodb.net", "port": 27017, "typeName": "REPLICA_SECONDARY"}]}`))
+		case "/groups/g1/processes/cluster0-shard-00-00-abcde.mongodb.net:27017/measurements",
+			"/groups/g1/processes/cluster0-shard-00-01-abcde.mongodb.net:27017/measurements":
+			w.Write([]byte(`{"measurements": [{"name": "SYSTEM_NORMALIZED_CPU_USER", "units": "PERCENT",
+				"dataPoints": [{"value": 10}, {"value": null}, {"value": 30}]}]}`))
+		case "/groups/g1/processes/cluster0-shard-00-00-abcde.mongodb.net:27017/disks/data/measurements":
+			w.Write([]byte(`{"measurements": [{"name": "DISK_PARTITION_IOPS_TOTAL", "units": "SCALAR_PER_SECOND",
+				"dataPoints": [{"value": 100}, {"value": 300}]}]}`))
+		case "/groups/g1/processes/cluster0-shard-00-00-abcde.mongodb.net:27017/performanceAdvisor/suggestedIndexes":
+			w.Write([]byte(`{"shapes": [{"id": "s1", "avgMs": 100, "count": 3}, {"id": "s2", "avgMs": 300, "count": 1}],
+				"suggestedIndexes": [{"impact": ["s1", "s2"], "index": [{"status": 1}, {"ts": -1}], "namespace": "demo.orders", "weight": 500}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	api := NewAPI("user", "secret", "g1")
+	api.SetBaseURL(server.URL)
+	report, err := NewCluster(api, "Cluster0").GetReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary, secondary := report.Processes[0], report.Processes[1]
+	if report.Config.ProviderSettings.InstanceSizeName != "M30" || len(report.Processes) != 2 || primary.Error != "" ||
+		primary.Measurements[0].Avg != 20 || primary.Measurements[0].Max != 30 || primary.Measurements[1].Avg != 200 ||
+		strings.Contains(secondary.Error, "404") == false {
+		t.Fatal(report)
+	}
+	index := report.SuggestedIndexes[0]
+	if len(report.SuggestedIndexes) != 1 || index.Index != "{status: 1, ts: -1}" || index.Count != 4 || index.AvgMS != 150 {
+		t.Fatal(report.SuggestedIndexes)
+	}
+	str := GetClusterReportSummary(report)
+	t.Log(str)
+	if strings.Contains(str, "AWS M30") == false || strings.Contains(str, "demo.orders {status: 1, ts: -1}") == false {
+		t.Fatal(str)
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+// Package ftdc decodes MongoDB diagnostic.data (FTDC) files into metric time series
+package ftdc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// types of FTDC documents
+const (
+	typeMetadata    = 0
+	typeMetricChunk = 1
+)
+
+// maxDocumentSize is the largest BSON document of a diagnostic.data file accepted
+const maxDocumentSize = 64 * 1024 * 1024
+
+// TimeSeries is values of a metric at times of samples
+type TimeSeries struct {
+	Times  []time.Time
+	Values []int64
+}
+
+// Metrics is metric time series decoded from diagnostic.data files, keys are dotted paths of serverStatus
+// documents, e.g. serverStatus.connections.current
+type Metrics struct {
+	Host    string
+	Version string
+	Series  map[string]*TimeSeries
+	Start   time.Time
+	End     time.Time
+	verbose bool
+}
+
+// NewMetrics returns Metrics
+func NewMetrics() *Metrics {
+	return &Metrics{Series: map[string]*TimeSeries{}}
+}
+
+// SetVerbose sets verbose level
+func (m *Metrics) SetVerbose(verbose bool) {
+	m.verbose = verbose
+}
+
+// ReadFiles decodes diagnostic.data files, metrics.* files of a directory are read in order
+func (m *Metrics) ReadFiles(filenames []string) error {
+	var err error
+	for _, filename := range filenames {
+		var files []string
+		if files, err = getMetricsFiles(filename); err != nil {
+			return err
+		}
+		for _, f := range files {
+			if m.verbose == true {
+				fmt.Println("decoding", f)
+			}
+			if err = m.ReadFile(f); err != nil {
+				return fmt.Errorf("%v: %v", f, err)
+			}
+		}
+	}
+	return err
+}
+
+// ReadFile decodes a diagnostic.data file
+func (m *Metrics) ReadFile(filename string) error {
+	var err error
+	var file *os.File
+	if file, err = os.Open(filename); err != nil {
+		return err
+	}
+	defer file.Close()
+	return m.Decode(bufio.NewReader(file))
+}
+
+// Decode decodes FTDC documents of a reader, metadata documents set host and version
+func (m *Metrics) Decode(reader io.Reader) error {
+	for {
+		doc, err := readDocument(reader)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		typ, _ := doc.Lookup("type").Int32OK()
+		switch typ {
+		case typeMetadata:
+			m.setMetadata(doc.Lookup("doc"))
+		case typeMetricChunk:
+			_, data, ok := doc.Lookup("data").BinaryOK()
+			if ok == false {
+				return errors.New("metric chunk without data")
+			}
+			var keys []string
+			var values [][]int64
+			if keys, values, err = decodeChunk(data); err != nil {
+				return err
+			}
+			m.addChunk(keys, values)
+		}
+	}
+}
+
+// setMetadata sets host and version from a metadata document
+func (m *Metrics) setMetadata(value bson.RawValue) {
+	doc, ok := value.DocumentOK()
+	if ok == false {
+		return
+	}
+	if host, ok := doc.Lookup("hostInfo", "system", "hostname").StringValueOK(); ok == true {
+		m.Host = host
+	}
+	if version, ok := doc.Lookup("buildInfo", "version").StringValueOK(); ok == true {
+		m.Version = version
+	}
+}
+
+// addChunk appends samples of a chunk to time series, times are of the start metric of samples
+func (m *Metrics) addChunk(keys []string, values [][]int64) {
+	pos := -1
+	for i, key := range keys {
+		if key == "start" || (pos < 0 && key == "serverStatus.localTime") {
+			pos = i
+		}
+	}
+	if pos < 0 || len(values[pos]) == 0 {
+		return
+	}
+	times := make([]time.Time, len(values[pos]))
+	for i, ms := range values[pos] {
+		times[i] = time.Unix(0, ms*int64(time.Millisecond)).UTC()
+	}
+	if m.Start.IsZero() || times[0].Before(m.Start) {
+		m.Start = times[0]
+	}
+	if times[len(times)-1].After(m.End) {
+		m.End = times[len(times)-1]
+	}
+	for i, key := range keys {
+		series, ok := m.Series[key]
+		if ok == false {
+			series = &TimeSeries{}
+			m.Series[key] = series
+		}
+		series.Times = append(series.Times, times...)
+		series.Values = append(series.Values, values[i]...)
+	}
+}
+
+// getMetricsFiles returns a file, or metrics.* files of a directory sorted by names
+func getMetricsFiles(filename string) ([]string, error) {
+	var err error
+	var fi os.FileInfo
+	if fi, err = os.Stat(filename); err != nil {
+		return nil, err
+	}
+	if fi.IsDir() == false {
+		return []string{filename}, nil
+	}
+	var infos []os.FileInfo
+	if infos, err = ioutil.ReadDir(filename); err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, info := range infos {
+		if info.IsDir() == false && strings.HasPrefix(info.Name(), "metrics.") {
+			files = append(files, filepath.Join(filename, info.Name()))
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { // metrics.interim is the latest
+		if strings.HasSuffix(files[i], ".interim") != strings.HasSuffix(files[j], ".interim") {
+			return strings.HasSuffix(files[j], ".interim")
+		}
+		return files[i] < files[j]
+	})
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no metrics files in %v", filename)
+	}
+	return files, nil
+}
+
+// readDocument reads a BSON document from a reader, io.EOF at the end
+func readDocument(reader io.Reader) (bson.Raw, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	size := binary.LittleEndian.Uint32(header)
+	if size < 5 || size > maxDocumentSize {
+		return nil, fmt.Errorf("invalid document size %d", size)
+	}
+	doc := make([]byte, size)
+	copy(doc, header)
+	if _, err := io.ReadFull(reader, doc[4:]); err != nil {
+		return nil, err
+	}
+	return bson.Raw(doc), bson.Raw(doc).Validate()
+}
+
+// decodeChunk returns keys and values of samples of a metric chunk. A chunk is the uncompressed length followed
+// by zlib compressed data of a reference document, number of metrics, number of deltas, and varint deltas of
+// metrics in columns with runs of zeros compressed.
+func decodeChunk(data []byte) ([]string, [][]int64, error) {
+	var err error
+	if len(data) < 4 {
+		return nil, nil, errors.New("invalid metric chunk")
+	}
+	var zr io.ReadCloser
+	if zr, err = zlib.NewReader(bytes.NewReader(data[4:])); err != nil {
+		return nil, nil, err
+	}
+	defer zr.Close()
+	var buf []byte
+	if buf, err = ioutil.ReadAll(zr); err != nil {
+		return nil, nil, err
+	}
+	reader := bytes.NewReader(buf)
+	var ref bson.Raw
+	if ref, err = readDocument(reader); err != nil {
+		return nil, nil, err
+	}
+	keys, refs := flattenMetrics(ref, "", []string{}, []int64{})
+	var counts [2]uint32
+	if err = binary.Read(reader, binary.LittleEndian, &counts); err != nil {
+		return nil, nil, err
+	}
+	numMetrics, numDeltas := int(counts[0]), int(counts[1])
+	if numMetrics != len(keys) {
+		return nil, nil, fmt.Errorf("%d metrics of reference document, expected %d", len(keys), numMetrics)
+	}
+	values := make([][]int64, numMetrics)
+	var zeros uint64
+	for i := range values {
+		values[i] = make([]int64, numDeltas+1)
+		values[i][0] = refs[i]
+		for j := 1; j <= numDeltas; j++ {
+			var delta uint64
+			if zeros > 0 {
+				zeros--
+			} else {
+				if delta, err = binary.ReadUvarint(reader); err != nil {
+					return nil, nil, err
+				}
+				if delta == 0 {
+					if zeros, err = binary.ReadUvarint(reader); err != nil {
+						return nil, nil, err
+					}
+				}
+			}
+			values[i][j] = int64(uint64(values[i][j-1]) + delta)
+		}
+	}
+	return keys, values, nil
+}
+
+// flattenMetrics returns dotted keys and values of numeric, boolean, date, and timestamp fields of a document in
+// order, a timestamp is two metrics of seconds and increment
+func flattenMetrics(doc bson.Raw, prefix string, keys []string, values []int64) ([]string, []int64) {
+	elems, _ := doc.Elements()
+	for _, elem := range elems {
+		key := prefix + elem.Key()
+		value := elem.Value()
+		switch value.Type {
+		case bsontype.Double:
+			keys, values = append(keys, key), append(values, int64(value.Double()))
+		case bsontype.Int32:
+			keys, values = append(keys, key), append(values, int64(value.Int32()))
+		case bsontype.Int64:
+			keys, values = append(keys, key), append(values, value.Int64())
+		case bsontype.Boolean:
+			b := int64(0)
+			if value.Boolean() == true {
+				b = 1
+			}
+			keys, values = append(keys, key), append(values, b)
+		case bsontype.DateTime:
+			keys, values = append(keys, key), append(values, value.DateTime())
+		case bsontype.Timestamp:
+			t, i := value.Timestamp()
+			keys, values = append(keys, key+".t", key+".i"), append(values, int64(t), int64(i))
+		case bsontype.EmbeddedDocument:
+			keys, values = flattenMetrics(value.Document(), key+".", keys, values)
+		case bsontype.Array:
+			keys, values = flattenMetrics(value.Array(), key+".", keys, values)
+		}
+	}
+	return keys, values
+}
@@ -0,0 +1,178 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package ftdc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var testStart = time.Date(2019, 10, 1, 10, 0, 0, 0, time.UTC)
+
+// getTestReference returns a reference document of serverStatus metrics
+func getTestReference() bson.D {
+	start := primitive.NewDateTimeFromTime(testStart)
+	return bson.D{{Key: "start", Value: start}, {Key: "serverStatus", Value: bson.D{
+		{Key: "host", Value: "keyhole.local"}, // strings aren't metrics
+		{Key: "connections", Value: bson.D{{Key: "current", Value: int32(10)}}},
+		{Key: "globalLock", Value: bson.D{{Key: "currentQueue", Value: bson.D{
+			{Key: "readers", Value: int32(0)}, {Key: "writers", Value: int32(0)}}}}},
+		{Key: "ok", Value: float64(1)},
+		{Key: "optime", Value: primitive.Timestamp{T: 1569924000, I: 1}},
+		{Key: "wiredTiger", Value: bson.D{{Key: "cache", Value: bson.D{
+			{Key: "bytes currently in the cache", Value: int64(400)},
+			{Key: "maximum bytes configured", Value: int64(1000)},
+			{Key: "tracked dirty bytes in the cache", Value: int64(50)}}},
+			{Key: "concurrentTransactions", Value: bson.D{
+				{Key: "read", Value: bson.D{{Key: "out", Value: int32(1)}, {Key: "available", Value: int32(127)}}},
+				{Key: "write", Value: bson.D{{Key: "out", Value: int32(0)}, {Key: "available", Value: int32(128)}}}}}}},
+		{Key: "replicated", Value: bson.A{true, int64(5)}},
+	}}}
+}
+
+// getTestChunk returns a metric chunk of a reference document and deltas of metrics
+func getTestChunk(t *testing.T, ref bson.D, deltas [][]uint64) []byte {
+	var err error
+	var data []byte
+	if data, err = bson.Marshal(ref); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	buf.Write(data)
+	binary.Write(&buf, binary.LittleEndian, []uint32{uint32(len(deltas)), uint32(len(deltas[0]))})
+	flat := []uint64{}
+	for _, d := range deltas {
+		flat = append(flat, d...)
+	}
+	varint := make([]byte, binary.MaxVarintLen64)
+	for i := 0; i < len(flat); {
+		if flat[i] != 0 {
+			buf.Write(varint[:binary.PutUvarint(varint, flat[i])])
+			i++
+			continue
+		}
+		j := i
+		for j < len(flat) && flat[j] == 0 {
+			j++
+		}
+		buf.Write(varint[:binary.PutUvarint(varint, 0)])
+		buf.Write(varint[:binary.PutUvarint(varint, uint64(j-i-1))])
+		i = j
+	}
+	var zbuf bytes.Buffer
+	zbuf.Write(make([]byte, 4))
+	binary.LittleEndian.PutUint32(zbuf.Bytes(), uint32(buf.Len()))
+	zw := zlib.NewWriter(&zbuf)
+	zw.Write(buf.Bytes())
+	zw.Close()
+	return zbuf.Bytes()
+}
+
+// getTestFile returns a diagnostic.data file of a metadata document and a metric chunk of 3 samples
+func getTestFile(t *testing.T) []byte {
+	neg := func(n int64) uint64 { return uint64(-n) }
+	deltas := [][]uint64{
+		{1000, 1000},    // start
+		{5, neg(3)},     // connections.current
+		{0, 4},          // queued readers
+		{0, 0},          // queued writers
+		{0, 0},          // ok
+		{0, 1},          // optime.t
+		{1, 0},          // optime.i
+		{500, 0},        // bytes currently in the cache
+		{0, 0},          // maximum bytes configured
+		{150, neg(100)}, // tracked dirty bytes in the cache
+		{2, 0},          // read tickets out
+		{neg(2), 0},     // read tickets available
+		{0, 0},          // write tickets out
+		{0, 0},          // write tickets available
+		{0, 0},          // replicated.0
+		{1, 1},          // replicated.1
+	}
+	metadata := bson.D{{Key: "_id", Value: primitive.NewDateTimeFromTime(testStart)}, {Key: "type", Value: int32(0)},
+		{Key: "doc", Value: bson.D{{Key: "hostInfo", Value: bson.D{{Key: "system", Value: bson.D{{Key: "hostname", Value: "keyhole.local"}}}}},
+			{Key: "buildInfo", Value: bson.D{{Key: "version", Value: "4.2.0"}}}}}}
+	chunk := bson.D{{Key: "_id", Value: primitive.NewDateTimeFromTime(testStart)}, {Key: "type", Value: int32(1)},
+		{Key: "data", Value: primitive.Binary{Data: getTestChunk(t, getTestReference(), deltas)}}}
+	var buf bytes.Buffer
+	for _, doc := range []bson.D{metadata, chunk} {
+		data, err := bson.Marshal(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf.Write(data)
+	}
+	return buf.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	m := NewMetrics()
+	if err := m.Decode(bytes.NewReader(getTestFile(t))); err != nil {
+		t.Fatal(err)
+	}
+	if m.Host != "keyhole.local" || m.Version != "4.2.0" || m.Start.Equal(testStart) == false ||
+		m.End.Equal(testStart.Add(2*time.Second)) == false {
+		t.Fatal(m.Host, m.Version, m.Start, m.End)
+	}
+	for key, expected := range map[string][]int64{
+		"serverStatus.connections.current":                               {10, 15, 12},
+		"serverStatus.optime.t":                                          {1569924000, 1569924000, 1569924001},
+		"serverStatus.optime.i":                                          {1, 2, 2},
+		"serverStatus.wiredTiger.cache.tracked dirty bytes in the cache": {50, 200, 100},
+		"serverStatus.replicated.0":                                      {1, 1, 1},
+		"serverStatus.replicated.1":                                      {5, 6, 7},
+	} {
+		series := m.Series[key]
+		if series == nil || len(series.Values) != 3 || len(series.Times) != 3 {
+			t.Fatal(key, series)
+		}
+		for i, v := range expected {
+			if series.Values[i] != v {
+				t.Fatal(key, series.Values)
+			}
+		}
+	}
+	if _, ok := m.Series["serverStatus.host"]; ok == true {
+		t.Fatal("strings aren't metrics")
+	}
+}
+
+func TestDecodeChunkMismatch(t *testing.T) {
+	data := getTestChunk(t, bson.D{{Key: "a", Value: int32(1)}}, [][]uint64{{0}, {0}})
+	if _, _, err := decodeChunk(data); err == nil {
+		t.Fatal("expected an error of number of metrics")
+	}
+}
+
+func TestReadFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnostic.data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"metrics.interim", "metrics.2019-10-01T10-00-00Z-00000", "other.txt"} {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), getTestFile(t), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, _ := getMetricsFiles(dir)
+	if len(files) != 2 || filepath.Base(files[1]) != "metrics.interim" {
+		t.Fatal(files)
+	}
+	m := NewMetrics()
+	if err = m.ReadFiles([]string{dir}); err != nil {
+		t.Fatal(err)
+	}
+	if series := m.Series["start"]; series == nil || len(series.Values) != 6 {
+		t.Fatal(series)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package ftdc
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// keys of metrics of serverStatus
+const (
+	keyCacheBytes     = "serverStatus.wiredTiger.cache.bytes currently in the cache"
+	keyCacheDirty     = "serverStatus.wiredTiger.cache.tracked dirty bytes in the cache"
+	keyCacheMax       = "serverStatus.wiredTiger.cache.maximum bytes configured"
+	keyConnections    = "serverStatus.connections.current"
+	keyQueuedReaders  = "serverStatus.globalLock.currentQueue.readers"
+	keyQueuedWriters  = "serverStatus.globalLock.currentQueue.writers"
+	keyReadTickets    = "serverStatus.wiredTiger.concurrentTransactions.read.out"
+	keyReadAvailable  = "serverStatus.wiredTiger.concurrentTransactions.read.available"
+	keyWriteTickets   = "serverStatus.wiredTiger.concurrentTransactions.write.out"
+	keyWriteAvailable = "serverStatus.wiredTiger.concurrentTransactions.write.available"
+)
+
+// Stat is average and maximum of a metric and when the maximum was sampled
+type Stat struct {
+	Name    string    `json:"name"`
+	Avg     float64   `json:"avg"`
+	Max     float64   `json:"max"`
+	MaxTime time.Time `json:"maxTime"`
+}
+
+// GetStats returns statistics of cache usage, dirty bytes, tickets, queues, and connections, metrics not
+// sampled are skipped
+func (m *Metrics) GetStats() []Stat {
+	stats := []Stat{}
+	add := func(name string, series *TimeSeries, scale float64) {
+		if series == nil || len(series.Values) == 0 {
+			return
+		}
+		stat := Stat{Name: name, Max: scale * float64(series.Values[0]), MaxTime: series.Times[0]}
+		sum := 0.0
+		for i, v := range series.Values {
+			value := scale * float64(v)
+			sum += value
+			if value > stat.Max {
+				stat.Max, stat.MaxTime = value, series.Times[i]
+			}
+		}
+		stat.Avg = sum / float64(len(series.Values))
+		stats = append(stats, stat)
+	}
+	add("cache used %", m.getPercentSeries(keyCacheBytes, keyCacheMax), 1)
+	add("cache dirty %", m.getPercentSeries(keyCacheDirty, keyCacheMax), 1)
+	add("cache dirty MB", m.Series[keyCacheDirty], 1.0/(1024*1024))
+	add("read tickets out", m.Series[keyReadTickets], 1)
+	add("read tickets available", m.Series[keyReadAvailable], 1)
+	add("write tickets out", m.Series[keyWriteTickets], 1)
+	add("write tickets available", m.Series[keyWriteAvailable], 1)
+	add("queued readers", m.Series[keyQueuedReaders], 1)
+	add("queued writers", m.Series[keyQueuedWriters], 1)
+	add("connections", m.Series[keyConnections], 1)
+	return stats
+}
+
+// getPercentSeries returns percentages of a metric of another metric sampled at the same times
+func (m *Metrics) getPercentSeries(key string, totalKey string) *TimeSeries {
+	series, totals := m.Series[key], m.Series[totalKey]
+	if series == nil || totals == nil {
+		return nil
+	}
+	byTimes := map[time.Time]int64{}
+	for i, t := range totals.Times {
+		byTimes[t] = totals.Values[i]
+	}
+	percents := &TimeSeries{}
+	for i, t := range series.Times {
+		if total := byTimes[t]; total > 0 {
+			percents.Times = append(percents.Times, t)
+			percents.Values = append(percents.Values, 100*series.Values[i]/total)
+		}
+	}
+	return percents
+}
+
+// GetSummary returns host, version, time range, and statistics of metrics
+func (m *Metrics) GetSummary() string {
+	var buffer bytes.Buffer
+	if len(m.Series) == 0 {
+		return "No FTDC metrics found"
+	}
+	if m.Host != "" {
+		buffer.WriteString(fmt.Sprintf("Host: %v, version: %v\n", m.Host, m.Version))
+	}
+	buffer.WriteString(fmt.Sprintf("Samples from %v to %v, %d metrics\n", m.Start.Format(time.RFC3339),
+		m.End.Format(time.RFC3339), len(m.Series)))
+	buffer.WriteString(fmt.Sprintf("%-24s %12s %12s  %s\n", "metric", "avg", "max", "max at"))
+	for _, stat := range m.GetStats() {
+		buffer.WriteString(fmt.Sprintf("%-24s %12.1f %12.1f  %v\n", stat.Name, stat.Avg, stat.Max,
+			stat.MaxTime.Format(time.RFC3339)))
+	}
+	return buffer.String()
+}
@@ -0,0 +1,41 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package ftdc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetStats(t *testing.T) {
+	m := NewMetrics()
+	if err := m.Decode(bytes.NewReader(getTestFile(t))); err != nil {
+		t.Fatal(err)
+	}
+	stats := map[string]Stat{}
+	for _, stat := range m.GetStats() {
+		stats[stat.Name] = stat
+	}
+	if s := stats["cache used %"]; s.Avg != 220.0/3 || s.Max != 90 || s.MaxTime.Equal(testStart.Add(time.Second)) == false {
+		t.Fatal(s)
+	}
+	if s := stats["cache dirty %"]; s.Max != 20 {
+		t.Fatal(s)
+	}
+	if s := stats["queued readers"]; s.Max != 4 || s.MaxTime.Equal(testStart.Add(2*time.Second)) == false {
+		t.Fatal(s)
+	}
+	if s := stats["read tickets out"]; s.Max != 3 || s.Avg != 7.0/3 {
+		t.Fatal(s)
+	}
+	str := m.GetSummary()
+	t.Log(str)
+	if strings.Contains(str, "Host: keyhole.local, version: 4.2.0") == false || strings.Contains(str, "write tickets available") == false {
+		t.Fatal(str)
+	}
+	if str = NewMetrics().GetSummary(); str != "No FTDC metrics found" {
+		t.Fatal(str)
+	}
+}
@@ -0,0 +1,393 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// analyses of the daemon
+const (
+	AnalysisIndexUsage   = "indexUsage"
+	AnalysisProfile      = "profile"
+	AnalysisServerStatus = "serverStatus"
+)
+
+// defaultDaemonDir is directory of results of the daemon if not configured
+const defaultDaemonDir = "keyhole-daemon"
+
+// AnalysisDaemon runs analyses on cron-like schedules, persists time-stamped results, and reports trends
+// across runs
+type AnalysisDaemon struct {
+	client    *mongo.Client
+	config    DaemonConfig
+	schedules map[string]*CronSchedule
+	verbose   bool
+}
+
+// DaemonConfig is the configuration of an AnalysisDaemon, e.g.
+// {"dir": "keyhole-daemon", "jobs": [{"name": "profile", "analysis": "profile", "schedule": "@hourly"}]}
+type DaemonConfig struct {
+	Dir  string      `json:"dir"`
+	Jobs []DaemonJob `json:"jobs"`
+}
+
+// DaemonJob is an analysis of indexUsage, profile, or serverStatus run on a schedule
+type DaemonJob struct {
+	Name     string `json:"name"`
+	Analysis string `json:"analysis"`
+	Schedule string `json:"schedule"`
+	Database string `json:"database,omitempty"` // database of indexUsage and profile, all databases if empty
+	Minutes  int    `json:"minutes,omitempty"`  // minutes of serverStatus sampling, 1 if 0
+	Interval int    `json:"interval,omitempty"` // seconds between serverStatus samples, 10 if 0
+
+	ReadPreference     string `json:"readPreference,omitempty"`     // of indexUsage and serverStatus, primary if empty
+	ReadPreferenceTags string `json:"readPreferenceTags,omitempty"` // e.g. nodeType:ANALYTICS
+}
+
+// DaemonRun is metrics of a run of a job
+type DaemonRun struct {
+	Job      string             `json:"job"`
+	Analysis string             `json:"analysis"`
+	Time     time.Time          `json:"time"`
+	Seconds  float64            `json:"seconds"`
+	Metrics  map[string]float64 `json:"metrics"`
+	Error    string             `json:"error,omitempty"`
+}
+
+// DaemonTrend is changes of a metric of a job across runs
+type DaemonTrend struct {
+	Job     string  `json:"job"`
+	Metric  string  `json:"metric"`
+	First   float64 `json:"first"`
+	Last    float64 `json:"last"`
+	Runs    int     `json:"runs"`
+	Growing bool    `json:"growing"` // increased and never decreased across runs
+}
+
+// NewAnalysisDaemon returns an AnalysisDaemon of a configuration
+func NewAnalysisDaemon(client *mongo.Client, config DaemonConfig) (*AnalysisDaemon, error) {
+	var err error
+	if config.Dir == "" {
+		config.Dir = defaultDaemonDir
+	}
+	if len(config.Jobs) == 0 {
+		return nil, errors.New("no jobs configured")
+	}
+	daemon := &AnalysisDaemon{client: client, config: config, schedules: map[string]*CronSchedule{}}
+	for _, job := range config.Jobs {
+		if job.Name == "" || filepath.Base(job.Name) != job.Name {
+			return nil, fmt.Errorf("invalid job name '%v'", job.Name)
+		} else if _, ok := daemon.schedules[job.Name]; ok == true {
+			return nil, fmt.Errorf("duplicate job name %v", job.Name)
+		} else if job.Analysis != AnalysisIndexUsage && job.Analysis != AnalysisProfile && job.Analysis != AnalysisServerStatus {
+			return nil, fmt.Errorf("unsupported analysis '%v' of job %v", job.Analysis, job.Name)
+		}
+		if daemon.schedules[job.Name], err = ParseCronSchedule(job.Schedule); err != nil {
+			return nil, fmt.Errorf("job %v: %v", job.Name, err)
+		}
+		if _, err = ParseReadPreference(job.ReadPreference, job.ReadPreferenceTags); err != nil {
+			return nil, fmt.Errorf("job %v: %v", job.Name, err)
+		}
+	}
+	return daemon, nil
+}
+
+// ReadDaemonConfig reads a configuration from a JSON file
+func ReadDaemonConfig(filename string) (DaemonConfig, error) {
+	var err error
+	var data []byte
+	var config DaemonConfig
+	if data, err = ioutil.ReadFile(filename); err != nil {
+		return config, err
+	}
+	err = json.Unmarshal(data, &config)
+	return config, err
+}
+
+// SetVerbose sets verbose level
+func (d *AnalysisDaemon) SetVerbose(verbose bool) {
+	d.verbose = verbose
+}
+
+// Run runs jobs on their schedules until interrupted
+func (d *AnalysisDaemon) Run() error {
+	return d.RunContext(context.Background())
+}
+
+// RunContext runs jobs on their schedules until ctx is done, jobs due at the same time run one after another
+func (d *AnalysisDaemon) RunContext(ctx context.Context) error {
+	var err error
+	if err = os.MkdirAll(d.config.Dir, 0755); err != nil {
+		return err
+	}
+	nexts := map[string]time.Time{}
+	for _, job := range d.config.Jobs {
+		nexts[job.Name] = d.schedules[job.Name].Next(time.Now())
+		log.Printf("job %v (%v) scheduled at %v\n", job.Name, job.Analysis, nexts[job.Name].Format(time.RFC3339))
+	}
+	for {
+		var due DaemonJob
+		for _, job := range d.config.Jobs {
+			if due.Name == "" || nexts[job.Name].Before(nexts[due.Name]) {
+				due = job
+			}
+		}
+		if nexts[due.Name].IsZero() {
+			return errors.New("no schedules of jobs within a year")
+		}
+		timer := time.NewTimer(time.Until(nexts[due.Name]))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		run := d.RunJob(ctx, due)
+		if run.Error != "" {
+			log.Println("job", due.Name, "failed:", run.Error)
+		} else if d.verbose == true {
+			log.Println("job", due.Name, "completed in", run.Seconds, "seconds")
+		}
+		var runs []DaemonRun
+		if runs, err = ReadDaemonRuns(d.getRunsFilename(due.Name)); err != nil {
+			log.Println(err)
+		} else {
+			fmt.Println(GetDaemonTrendsSummary(GetDaemonTrends(runs)))
+		}
+		nexts[due.Name] = d.schedules[due.Name].Next(time.Now())
+	}
+}
+
+// RunJob runs a job once, writes its result to a file of the time of the run, and appends its metrics to
+// the runs file of the job
+func (d *AnalysisDaemon) RunJob(ctx context.Context, job DaemonJob) DaemonRun {
+	var err error
+	var result interface{}
+	run := DaemonRun{Job: job.Name, Analysis: job.Analysis, Time: time.Now(), Metrics: map[string]float64{}}
+	switch job.Analysis {
+	case AnalysisIndexUsage:
+		result, run.Metrics, err = d.runIndexUsage(ctx, job)
+	case AnalysisProfile:
+		result, run.Metrics, err = d.runProfile(ctx, job)
+	case AnalysisServerStatus:
+		result, run.Metrics, err = d.runServerStatus(ctx, job)
+	}
+	run.Seconds = time.Since(run.Time).Seconds()
+	if err != nil {
+		run.Error = err.Error()
+	} else {
+		var data []byte
+		filename := filepath.Join(d.config.Dir, job.Name+"-"+run.Time.Format("20060102-150405")+".json")
+		if data, err = json.MarshalIndent(bson.M{"run": run, "result": result}, "", "  "); err != nil {
+			run.Error = err.Error()
+		} else if err = ioutil.WriteFile(filename, data, 0644); err != nil {
+			run.Error = err.Error()
+		}
+	}
+	if err = appendJSONLine(d.getRunsFilename(job.Name), run); err != nil {
+		log.Println(err)
+	}
+	return run
+}
+
+// getRunsFilename returns the file of metrics of runs of a job
+func (d *AnalysisDaemon) getRunsFilename(name string) string {
+	return filepath.Join(d.config.Dir, name+"-runs.json")
+}
+
+// runIndexUsage takes a snapshot of index usage and appends it to the usage file of the job
+func (d *AnalysisDaemon) runIndexUsage(ctx context.Context, job DaemonJob) (interface{}, map[string]float64, error) {
+	var err error
+	var indexesMap bson.M
+	var rp *readpref.ReadPref
+	if rp, err = ParseReadPreference(job.ReadPreference, job.ReadPreferenceTags); err != nil {
+		return nil, nil, err
+	}
+	ir := NewIndexesReader(d.client)
+	ir.SetDBName(job.Database)
+	ir.SetReadPreference(rp)
+	ir.SetVerbose(d.verbose)
+	if indexesMap, err = ir.GetIndexesContext(ctx); err != nil {
+		return nil, nil, err
+	}
+	ir.LogErrors()
+	snapshot := GetUsageSnapshot(indexesMap)
+	if err = AppendUsageSnapshot(filepath.Join(d.config.Dir, job.Name+"-usage.json"), snapshot); err != nil {
+		return nil, nil, err
+	}
+	return snapshot, getIndexUsageMetrics(indexesMap), err
+}
+
+// runProfile reads system.profile and summarizes ops patterns
+func (d *AnalysisDaemon) runProfile(ctx context.Context, job DaemonJob) (interface{}, map[string]float64, error) {
+	var err error
+	var li *LogInfo
+	pr := NewProfileReader(d.client)
+	pr.SetDBName(job.Database)
+	pr.SetVerbose(d.verbose)
+	if li, err = pr.GetLogInfoContext(ctx); err != nil {
+		return nil, nil, err
+	}
+	return li.GetOpsPatterns(), getProfileMetrics(li), err
+}
+
+// runServerStatus appends serverStatus samples to the samples file of the job for minutes of the job
+func (d *AnalysisDaemon) runServerStatus(ctx context.Context, job DaemonJob) (interface{}, map[string]float64, error) {
+	var err error
+	var samples []ServerStatusSample
+	var rp *readpref.ReadPref
+	if rp, err = ParseReadPreference(job.ReadPreference, job.ReadPreferenceTags); err != nil {
+		return nil, nil, err
+	}
+	minutes, interval := job.Minutes, job.Interval
+	if minutes <= 0 {
+		minutes = 1
+	}
+	if interval <= 0 {
+		interval = 10
+	}
+	collector := NewServerStatusCollector(d.client, filepath.Join(d.config.Dir, job.Name+"-serverStatus.json"))
+	collector.SetDuration(time.Duration(minutes) * time.Minute)
+	collector.SetInterval(time.Duration(interval) * time.Second)
+	collector.SetReadPreference(rp)
+	collector.SetVerbose(d.verbose)
+	if samples, err = collector.CollectContext(ctx); err != nil {
+		return nil, nil, err
+	}
+	metrics := GetServerStatusMetrics(samples)
+	values := map[string]float64{}
+	for _, m := range metrics {
+		values[m.Name] = m.Avg
+	}
+	return metrics, values, err
+}
+
+// getIndexUsageMetrics returns numbers of indexes, unused indexes, and accesses of indexes
+func getIndexUsageMetrics(indexesMap bson.M) map[string]float64 {
+	metrics := map[string]float64{"indexes": 0, "unusedIndexes": 0, "ops": 0}
+	for _, collections := range indexesMap {
+		m, ok := collections.(bson.M)
+		if ok == false {
+			continue
+		}
+		for _, list := range m {
+			indexes, ok := list.([]IndexStatsDoc)
+			if ok == false {
+				continue
+			}
+			for _, o := range indexes {
+				metrics["indexes"]++
+				metrics["ops"] += float64(o.TotalOps)
+				if isUnusedIndex(o) {
+					metrics["unusedIndexes"]++
+				}
+			}
+		}
+	}
+	return metrics
+}
+
+// getProfileMetrics returns numbers of ops, ops patterns, COLLSCAN patterns and ops, and total milliseconds
+func getProfileMetrics(li *LogInfo) map[string]float64 {
+	metrics := map[string]float64{"ops": 0, "patterns": 0, "collscans": 0, "collscanOps": 0, "totalMilli": 0}
+	for _, doc := range li.OpsPatterns {
+		metrics["ops"] += float64(doc.Count)
+		metrics["patterns"]++
+		metrics["totalMilli"] += float64(doc.TotalMilli)
+		if doc.Scan == COLLSCAN {
+			metrics["collscans"]++
+			metrics["collscanOps"] += float64(doc.Count)
+		}
+	}
+	return metrics
+}
+
+// ReadDaemonRuns reads runs appended to a runs file of a job
+func ReadDaemonRuns(filename string) ([]DaemonRun, error) {
+	var err error
+	var file *os.File
+	runs := []DaemonRun{}
+	if file, err = os.Open(filename); err != nil {
+		return runs, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var run DaemonRun
+		if err = json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			return runs, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, scanner.Err()
+}
+
+// GetDaemonTrends returns changes of metrics of successful runs by jobs and metrics
+func GetDaemonTrends(runs []DaemonRun) []DaemonTrend {
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].Time.Before(runs[j].Time) })
+	trends := map[string]*DaemonTrend{}
+	keys := []string{}
+	for _, run := range runs {
+		if run.Error != "" {
+			continue
+		}
+		for name, value := range run.Metrics {
+			key := run.Job + "\x00" + name
+			trend, ok := trends[key]
+			if ok == false {
+				trend = &DaemonTrend{Job: run.Job, Metric: name, First: value, Last: value, Growing: true}
+				trends[key] = trend
+				keys = append(keys, key)
+			} else if value < trend.Last {
+				trend.Growing = false
+			}
+			trend.Last = value
+			trend.Runs++
+		}
+	}
+	sort.Strings(keys)
+	list := []DaemonTrend{}
+	for _, key := range keys {
+		trend := trends[key]
+		trend.Growing = trend.Growing && trend.Last > trend.First
+		list = append(list, *trend)
+	}
+	return list
+}
+
+// GetDaemonTrendsSummary returns first and last values of metrics across runs, growing ones are flagged
+func GetDaemonTrendsSummary(trends []DaemonTrend) string {
+	var buffer bytes.Buffer
+	if len(trends) == 0 {
+		return "No runs of jobs"
+	}
+	buffer.WriteString(fmt.Sprintf("%-16s %-20s %6s %14s %14s\n", "job", "metric", "runs", "first", "last"))
+	for _, t := range trends {
+		flag := ""
+		if t.Growing == true {
+			flag = "  <- growing"
+		}
+		buffer.WriteString(fmt.Sprintf("%-16s %-20s %6d %14.2f %14.2f%v\n", t.Job, t.Metric, t.Runs, t.First, t.Last, flag))
+	}
+	return buffer.String()
+}
@@ -0,0 +1,98 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func getTestDaemonRuns() []DaemonRun {
+	now := time.Now()
+	return []DaemonRun{
+		{Job: "profile", Time: now.Add(-2 * time.Hour), Metrics: map[string]float64{"collscans": 1, "ops": 90}},
+		{Job: "profile", Time: now.Add(-1 * time.Hour), Metrics: map[string]float64{"collscans": 2, "ops": 120}},
+		{Job: "profile", Time: now.Add(-30 * time.Minute), Error: "timeout"},
+		{Job: "profile", Time: now, Metrics: map[string]float64{"collscans": 4, "ops": 100}},
+	}
+}
+
+func TestNewAnalysisDaemon(t *testing.T) {
+	config := DaemonConfig{Jobs: []DaemonJob{{Name: "profile", Analysis: AnalysisProfile, Schedule: "@hourly"}}}
+	daemon, err := NewAnalysisDaemon(nil, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if daemon.config.Dir != defaultDaemonDir {
+		t.Fatal(daemon.config.Dir)
+	}
+	for _, job := range []DaemonJob{
+		{Name: "a", Analysis: "unknown", Schedule: "@hourly"},
+		{Name: "a", Analysis: AnalysisProfile, Schedule: "* *"},
+		{Name: "../a", Analysis: AnalysisProfile, Schedule: "@hourly"},
+		{Name: "a", Analysis: AnalysisIndexUsage, Schedule: "@hourly", ReadPreferenceTags: "nodeType:ANALYTICS"},
+	} {
+		if _, err = NewAnalysisDaemon(nil, DaemonConfig{Jobs: []DaemonJob{job}}); err == nil {
+			t.Fatal("expected an error of", job)
+		}
+	}
+}
+
+func TestGetDaemonTrends(t *testing.T) {
+	trends := GetDaemonTrends(getTestDaemonRuns())
+	if len(trends) != 2 {
+		t.Fatal(trends)
+	}
+	if trends[0].Metric != "collscans" || trends[0].Runs != 3 || trends[0].Last != 4 || trends[0].Growing == false {
+		t.Fatal(trends[0])
+	}
+	if trends[1].Metric != "ops" || trends[1].Growing == true {
+		t.Fatal(trends[1])
+	}
+	if str := GetDaemonTrendsSummary(trends); strings.Contains(str, "growing") == false {
+		t.Fatal(str)
+	}
+}
+
+func TestDaemonRunsFile(t *testing.T) {
+	filename := filepath.Join(os.TempDir(), "keyhole-daemon-runs-test.json")
+	os.Remove(filename)
+	defer os.Remove(filename)
+	for _, run := range getTestDaemonRuns() {
+		if err := appendJSONLine(filename, run); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runs, err := ReadDaemonRuns(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 4 || runs[2].Error != "timeout" || runs[3].Metrics["collscans"] != 4 {
+		t.Fatal(runs)
+	}
+}
+
+func TestGetProfileMetrics(t *testing.T) {
+	li := &LogInfo{OpsPatterns: []OpPerformanceDoc{
+		{Namespace: "demo.a", Count: 10, TotalMilli: 500, Scan: COLLSCAN},
+		{Namespace: "demo.b", Count: 5, TotalMilli: 20},
+	}}
+	metrics := getProfileMetrics(li)
+	if metrics["collscans"] != 1 || metrics["collscanOps"] != 10 || metrics["ops"] != 15 || metrics["patterns"] != 2 {
+		t.Fatal(metrics)
+	}
+}
+
+func TestGetIndexUsageMetrics(t *testing.T) {
+	indexesMap := getTestIndexesMap()
+	indexesMap["keyhole"].(bson.M)["others"] = []bson.M{{"name": "_id_"}} // not of IndexStatsDoc, skipped
+	metrics := getIndexUsageMetrics(indexesMap)
+	if metrics["indexes"] != 4 || metrics["ops"] != 15 || metrics["unusedIndexes"] != 2 {
+		t.Fatal(metrics)
+	}
+}
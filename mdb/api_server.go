@@ -0,0 +1,339 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+// maxUploadBytes is the max size of a log uploaded to analyze
+const maxUploadBytes = 1024 * 1024 * 1024
+
+// APIServer exposes analyzers as REST endpoints returning JSON:
+//
+//	POST /analyze/log    a log uploaded as the body or as a multipart file, or {"path": "mongod.log"} of the log dir
+//	GET  /indexes?uri=   indexes and their usage of all databases, or of the database of the URI
+//	POST /explain        {"uri": "mongodb://...", "query": {"ns": "db.collection", "filter": {...}}} or a log line
+//
+// Requests require "Authorization: Bearer <token>" if a token is set, or a Host header of localhost if not, and URIs are
+// limited to allowed hosts if set
+type APIServer struct {
+	allowedHosts  []string
+	caFile        string
+	clientPEMFile string
+	logDir        string
+	token         string
+	verbose       bool
+}
+
+// APIError is a response of an error
+type APIError struct {
+	Error string `json:"error"`
+}
+
+// LogAnalysis is a response of analyzing a log
+type LogAnalysis struct {
+	ServerInfo  string                 `json:"serverInfo"`
+	OpsPatterns []LogInfoLineAnalytics `json:"opsPatterns"`
+	SlowOps     []SlowOps              `json:"slowOps"`
+}
+
+// explainRequest is a request of explaining a query
+type explainRequest struct {
+	URI   string          `json:"uri"`
+	Query json.RawMessage `json:"query"` // a document or a log line as a string
+}
+
+// NewAPIServer returns an APIServer, logs are only accepted as uploads unless a log dir is set
+func NewAPIServer() *APIServer {
+	return &APIServer{}
+}
+
+// SetAllowedHosts sets hosts of URIs allowed to connect to, any hosts if empty
+func (as *APIServer) SetAllowedHosts(hosts []string) {
+	as.allowedHosts = hosts
+}
+
+// SetLogDir sets a directory of logs that can be analyzed by paths
+func (as *APIServer) SetLogDir(logDir string) {
+	as.logDir = logDir
+}
+
+// SetTLSFiles sets a CA file and a client PEM file to connect to clusters
+func (as *APIServer) SetTLSFiles(caFile string, clientPEMFile string) {
+	as.caFile, as.clientPEMFile = caFile, clientPEMFile
+}
+
+// SetToken sets a bearer token required of requests
+func (as *APIServer) SetToken(token string) {
+	as.token = token
+}
+
+// SetVerbose sets verbose level
+func (as *APIServer) SetVerbose(verbose bool) {
+	as.verbose = verbose
+}
+
+// Serve serves endpoints at an address, e.g. :5409 of localhost, other than loopback addresses require a token
+func (as *APIServer) Serve(addr string) error {
+	if addr = getListenAddr(addr); isLoopbackAddr(addr) == false && as.token == "" {
+		return errors.New("a token is required to serve at " + addr)
+	}
+	return http.ListenAndServe(addr, as.Handler())
+}
+
+// getListenAddr returns an address of localhost if the host is omitted, e.g. :5409
+func getListenAddr(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "localhost" + addr
+	}
+	return addr
+}
+
+// isLoopbackAddr returns true if the host of an address is localhost or a loopback IP
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	return isLoopbackHost(host)
+}
+
+// isLoopbackHost returns true if a host, with or without a port, is localhost or a loopback IP
+func isLoopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if strings.ToLower(host) == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+// isAuthorized returns true if the request has the bearer token, or of a localhost Host header if no token is required,
+// pages of other host names resolved to a loopback address (DNS rebinding) aren't allowed
+func isAuthorized(r *http.Request, token string) bool {
+	if token == "" {
+		return isLoopbackHost(r.Host)
+	}
+	bearer := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1
+}
+
+// Handler returns a handler of endpoints
+func (as *APIServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/analyze/log", as.handle(http.MethodPost, as.analyzeLog))
+	mux.HandleFunc("/indexes", as.handle(http.MethodGet, as.getIndexes))
+	mux.HandleFunc("/explain", as.handle(http.MethodPost, as.explain))
+	return mux
+}
+
+// handle writes a result of an endpoint as JSON, or an error of a status
+func (as *APIServer) handle(method string, endpoint func(r *http.Request) (interface{}, int, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if as.verbose == true {
+			log.Println(r.Method, r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if isAuthorized(r, as.token) == false {
+			writeJSON(w, http.StatusUnauthorized, APIError{Error: "unauthorized"})
+			return
+		} else if r.Method != method {
+			writeJSON(w, http.StatusMethodNotAllowed, APIError{Error: "expected " + method})
+			return
+		}
+		defer func() { // parsers of query shapes panic on unexpected inputs, so does connecting
+			if e := recover(); e != nil {
+				writeJSON(w, http.StatusBadRequest, APIError{Error: fmt.Sprintf("%v", e)})
+			}
+		}()
+		r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes) // of multipart files, too
+		result, status, err := endpoint(r)
+		if err != nil {
+			writeJSON(w, status, APIError{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+// writeJSON writes a document as JSON with a status
+func writeJSON(w http.ResponseWriter, status int, doc interface{}) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(doc)
+}
+
+// analyzeLog analyzes a log uploaded or of a path of the log dir
+func (as *APIServer) analyzeLog(r *http.Request) (interface{}, int, error) {
+	var err error
+	var filename string
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var doc struct {
+			Path string `json:"path"`
+		}
+		if err = json.NewDecoder(r.Body).Decode(&doc); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		if filename, err = as.getLogPath(doc.Path); err != nil {
+			return nil, http.StatusForbidden, err
+		}
+	} else {
+		if filename, err = saveUpload(r); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		defer os.Remove(filename)
+	}
+	li := NewLogInfo(filename, "")
+	li.SetSilent(true)
+	var tmp *os.File
+	if tmp, err = ioutil.TempFile("", "keyhole-*-"+filepath.Base(li.OutputFilename)); err != nil { // unique of concurrent requests
+		return nil, http.StatusInternalServerError, err
+	}
+	tmp.Close()
+	li.OutputFilename = tmp.Name()
+	defer os.Remove(li.OutputFilename)
+	if _, err = li.AnalyzeContext(r.Context()); err != nil {
+		return nil, http.StatusUnprocessableEntity, err
+	}
+	return LogAnalysis{ServerInfo: li.GetServerInfo(), OpsPatterns: li.GetOpsPatterns(), SlowOps: li.GetSlowOps()}, http.StatusOK, nil
+}
+
+// getLogPath returns a path of the log dir, paths outside of it, or symlinks to outside of it, aren't allowed
+func (as *APIServer) getLogPath(path string) (string, error) {
+	var err error
+	var dir, filename string
+	if as.logDir == "" {
+		return "", errors.New("logs by paths aren't allowed without a log dir")
+	}
+	if dir, err = filepath.Abs(as.logDir); err != nil {
+		return "", err
+	}
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		return "", err
+	}
+	if filename, err = filepath.Abs(filepath.Join(dir, path)); err != nil {
+		return "", err
+	}
+	if filename, err = filepath.EvalSymlinks(filename); err != nil {
+		return "", errors.New("path must be a file of the log dir")
+	}
+	if path == "" || strings.HasPrefix(filename, dir+string(filepath.Separator)) == false {
+		return "", errors.New("path must be a file of the log dir")
+	}
+	return filename, nil
+}
+
+// saveUpload writes a log of a multipart file or of the body into a temp file and returns the file name
+func saveUpload(r *http.Request) (string, error) {
+	var err error
+	var reader io.Reader = r.Body // limited by handle
+	name := "upload.log"
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, ferr := r.FormFile("file")
+		if ferr != nil {
+			return "", ferr
+		}
+		defer file.Close()
+		reader, name = file, filepath.Base(header.Filename)
+	}
+	var tmp *os.File
+	if tmp, err = ioutil.TempFile("", "keyhole-*-"+name); err != nil { // keeps .gz suffixes
+		return "", err
+	}
+	defer tmp.Close()
+	if _, err = io.Copy(tmp, reader); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// getIndexes returns indexes of a cluster of the uri parameter
+func (as *APIServer) getIndexes(r *http.Request) (interface{}, int, error) {
+	var err error
+	var client *mongo.Client
+	var indexesMap bson.M
+	uri := r.URL.Query().Get("uri")
+	if uri == "" {
+		return nil, http.StatusBadRequest, errors.New("uri is required")
+	}
+	if client, err = as.connect(uri); err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	defer client.Disconnect(context.Background())
+	ir := NewIndexesReader(client)
+	ir.SetVerbose(as.verbose)
+	if connString, cerr := connstring.Parse(uri); cerr == nil && connString.Database != "" {
+		ir.SetDBName(connString.Database)
+	}
+	if indexesMap, err = ir.GetIndexes(); err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	ir.LogErrors()
+	return ir.GetIndexesWithErrors(indexesMap), http.StatusOK, nil
+}
+
+// explain explains a query against a cluster of the uri of the request
+func (as *APIServer) explain(r *http.Request) (interface{}, int, error) {
+	var err error
+	var req explainRequest
+	var client *mongo.Client
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, http.StatusBadRequest, err
+	} else if req.URI == "" || len(req.Query) == 0 {
+		return nil, http.StatusBadRequest, errors.New("uri and query are required")
+	}
+	query := []byte(req.Query)
+	var line string
+	if json.Unmarshal(req.Query, &line) == nil { // a log line
+		query = []byte(line)
+	}
+	if client, err = as.connect(req.URI); err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	defer client.Disconnect(context.Background())
+	e := NewExplain()
+	e.SetVerbose(as.verbose)
+	var result ExplainResult
+	if result, err = e.ExplainQuery(r.Context(), client, query); err != nil {
+		return nil, http.StatusUnprocessableEntity, err
+	}
+	return result, http.StatusOK, nil
+}
+
+// connect returns a client of a uri with TLS files if set
+func (as *APIServer) connect(uri string) (*mongo.Client, error) {
+	if strings.HasPrefix(uri, "mongodb://") == false && strings.HasPrefix(uri, "mongodb+srv://") == false {
+		return nil, errors.New("invalid uri " + uri)
+	}
+	if len(as.allowedHosts) > 0 {
+		connString, err := connstring.Parse(uri)
+		if err != nil {
+			return nil, err
+		}
+		for _, host := range connString.Hosts {
+			if contains(as.allowedHosts, host) == false && contains(as.allowedHosts, strings.Split(host, ":")[0]) == false {
+				return nil, errors.New("host " + host + " is not allowed")
+			}
+		}
+	}
+	return NewMongoClient(uri, as.caFile, as.clientPEMFile)
+}
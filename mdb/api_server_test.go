@@ -0,0 +1,131 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAPIServerAnalyzeLog(t *testing.T) {
+	as := NewAPIServer()
+	server := httptest.NewServer(as.Handler())
+	defer server.Close()
+	data, err := ioutil.ReadFile("testdata/mongod.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("file", "mongod.log")
+	part.Write(data)
+	writer.Close()
+	resp, err := http.Post(server.URL+"/analyze/log", writer.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var analysis LogAnalysis
+	json.NewDecoder(resp.Body).Decode(&analysis)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(analysis.OpsPatterns) == 0 {
+		t.Fatal(resp.Status, analysis)
+	}
+
+	resp, _ = http.Post(server.URL+"/analyze/log", "application/json", strings.NewReader(`{"path": "mongod.log"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatal(resp.Status)
+	}
+	as.SetLogDir("testdata")
+	for path, status := range map[string]int{"mongod.log": http.StatusOK, "../api_server.go": http.StatusForbidden} {
+		resp, _ = http.Post(server.URL+"/analyze/log", "application/json", strings.NewReader(`{"path": "`+path+`"}`))
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Fatal(path, resp.Status)
+		}
+	}
+
+	dir, _ := ioutil.TempDir("", "keyhole-logs-")
+	defer os.RemoveAll(dir)
+	outside, _ := ioutil.TempFile("", "keyhole-outside-*.log")
+	outside.Close()
+	defer os.Remove(outside.Name())
+	os.Symlink(outside.Name(), filepath.Join(dir, "outside.log"))
+	as.SetLogDir(dir)
+	if _, err = as.getLogPath("outside.log"); err == nil {
+		t.Fatal("expected a symlink to outside of the log dir not allowed")
+	}
+}
+
+func TestAPIServerBadRequests(t *testing.T) {
+	server := httptest.NewServer(NewAPIServer().Handler())
+	defer server.Close()
+	resp, _ := http.Get(server.URL + "/analyze/log")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatal(resp.Status)
+	}
+	resp, _ = http.Get(server.URL + "/indexes")
+	var doc APIError
+	json.NewDecoder(resp.Body).Decode(&doc)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest || doc.Error != "uri is required" {
+		t.Fatal(resp.Status, doc)
+	}
+	resp, _ = http.Post(server.URL+"/explain", "application/json", strings.NewReader(`{"uri": "localhost"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatal(resp.Status)
+	}
+}
+
+func TestAPIServerToken(t *testing.T) {
+	as := NewAPIServer()
+	as.SetToken("secret")
+	server := httptest.NewServer(as.Handler())
+	defer server.Close()
+	resp, _ := http.Get(server.URL + "/indexes")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatal(resp.Status)
+	}
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/indexes", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatal(resp.Status)
+	}
+
+	server = httptest.NewServer(NewAPIServer().Handler())
+	defer server.Close()
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/indexes", nil)
+	req.Host = "rebind.example.com:5409" // resolved to a loopback address
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatal(resp.Status)
+	}
+	if isLoopbackHost("localhost") == false || isLoopbackHost("[::1]:5409") == false || isLoopbackHost("127.0.0.1") == false {
+		t.Fatal("expected loopback hosts")
+	}
+
+	as.SetAllowedHosts([]string{"localhost"})
+	if _, err := as.connect("mongodb://example.com:27017"); err == nil {
+		t.Fatal("expected a host not allowed")
+	}
+	if addr := getListenAddr(":5409"); addr != "localhost:5409" || isLoopbackAddr(addr) == false || isLoopbackAddr("0.0.0.0:5409") == true {
+		t.Fatal(addr)
+	}
+	if err := NewAPIServer().Serve("0.0.0.0:5409"); err == nil {
+		t.Fatal("expected a token required")
+	}
+}
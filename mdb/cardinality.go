@@ -7,6 +7,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"math/rand"
 	"sort"
 	"strings"
 
@@ -18,16 +20,30 @@ import (
 	"golang.org/x/text/message"
 )
 
+// sampling methods of cardinality
+const (
+	SamplingRandom  = "sample"  // $sample
+	SamplingNatural = "natural" // $limit in natural order, deterministic
+)
+
 // Cardinality -
 type Cardinality struct {
-	client  *mongo.Client
-	verbose bool
+	client     *mongo.Client
+	method     string
+	sampleSize int64
+	seed       int64
+	verbose    bool
 }
 
 // CardinalitySummary stores Cardinality summary
 type CardinalitySummary struct {
-	SampledCount int64
-	List         []CardinalityCount
+	SampledCount    int64
+	List            []CardinalityCount
+	TotalCount      int64
+	SampledFraction float64 // sampled to total documents
+	MarginOfError   float64 // at 95% confidence
+	Method          string
+	Seed            int64
 }
 
 // CardinalityCount stores cardinality counts
@@ -38,7 +54,27 @@ type CardinalityCount struct {
 
 // NewCardinality returns cardinality constructor
 func NewCardinality(client *mongo.Client) *Cardinality {
-	return &Cardinality{client: client}
+	return &Cardinality{client: client, method: SamplingRandom}
+}
+
+// SetSampleSize sets number of documents to sample, 0 to size by number of documents
+func (card *Cardinality) SetSampleSize(sampleSize int64) {
+	card.sampleSize = sampleSize
+}
+
+// SetSamplingMethod sets sampling method, sample for $sample or natural for documents in natural order
+func (card *Cardinality) SetSamplingMethod(method string) error {
+	if method != SamplingRandom && method != SamplingNatural {
+		return fmt.Errorf("invalid sampling method %v", method)
+	}
+	card.method = method
+	return nil
+}
+
+// SetSeed sets a random seed to choose where natural order sampling starts, results are reproducible with
+// the same seed as long as documents don't change.  Sampling starts from the first document if 0.
+func (card *Cardinality) SetSeed(seed int64) {
+	card.seed = seed
 }
 
 // SetVerbose -
@@ -48,9 +84,13 @@ func (card *Cardinality) SetVerbose(verbose bool) {
 
 // GetCardinalityArray returns cardinality list
 func (card *Cardinality) GetCardinalityArray(database string, collection string, keys ...[]string) (CardinalitySummary, error) {
+	return card.GetCardinalityArrayContext(context.Background(), database, collection, keys...)
+}
+
+// GetCardinalityArrayContext returns cardinality list, stops when ctx is done
+func (card *Cardinality) GetCardinalityArrayContext(ctx context.Context, database string, collection string, keys ...[]string) (CardinalitySummary, error) {
 	var err error
 	var cur *mongo.Cursor
-	var ctx = context.Background()
 	var doc bson.M
 	var fields []string
 	summary := CardinalitySummary{}
@@ -60,7 +100,7 @@ func (card *Cardinality) GetCardinalityArray(database string, collection string,
 
 	keysFmt := `
   [
-    {"$sample": {"size": %d}},
+    %s,
     {"$project":{"kvs":{"$objectToArray":"$$ROOT"}}},
     {"$unwind":"$kvs"},
     {"$group":{"_id":null,"keys":{"$addToSet":"$kvs.k"}}},
@@ -68,7 +108,7 @@ func (card *Cardinality) GetCardinalityArray(database string, collection string,
   ]`
 	facetFmt := `
   [
-      {"$sample": {"size": %d}},
+      %s,
       {"$facet": {%s}},
       {"$project": {%s}}
   ]`
@@ -83,17 +123,14 @@ func (card *Cardinality) GetCardinalityArray(database string, collection string,
 		return summary, err
 	}
 
-	summary.SampledCount = count
-	if summary.SampledCount > int64(10000) { // random number
-		summary.SampledCount = int64(.0495 * float32(count))
-		for summary.SampledCount >= int64(10000) {
-			summary.SampledCount /= 10
-		}
-	}
+	summary.SampledCount = card.getSampleSize(count)
+	summary.TotalCount, summary.Method, summary.Seed = count, card.method, card.seed
+	summary.SampledFraction, summary.MarginOfError = getSamplingConfidence(summary.SampledCount, count)
+	sampling := getSamplingStages(card.method, summary.SampledCount, count, card.seed)
 	var pipeline string
 	opts := options.Aggregate()
 	if len(keys) == 0 || len(keys[0]) == 0 {
-		pipeline = fmt.Sprintf(keysFmt, summary.SampledCount)
+		pipeline = fmt.Sprintf(keysFmt, sampling)
 		if card.verbose {
 			fmt.Println("keysFmt", pipeline)
 		}
@@ -122,7 +159,7 @@ func (card *Cardinality) GetCardinalityArray(database string, collection string,
 		groups = append(groups, fmt.Sprintf(countFmt, strings.Replace(elem, ".", "__", -1), elem))
 		items = append(items, fmt.Sprintf("\"%s\": {\"$sum\": \"$%s.count\"}", strings.Replace(elem, ".", "__", -1), strings.Replace(elem, ".", "__", -1)))
 	}
-	pipeline = fmt.Sprintf(facetFmt, summary.SampledCount, strings.Join(groups, ","), strings.Join(items, ","))
+	pipeline = fmt.Sprintf(facetFmt, sampling, strings.Join(groups, ","), strings.Join(items, ","))
 	if card.verbose {
 		fmt.Println("facetFmt", pipeline)
 	}
@@ -161,6 +198,54 @@ func (card *Cardinality) GetCardinalityArray(database string, collection string,
 	return summary, err
 }
 
+// getSampleSize returns number of documents to sample of a collection
+func (card *Cardinality) getSampleSize(count int64) int64 {
+	if card.sampleSize > 0 {
+		if card.sampleSize < count {
+			return card.sampleSize
+		}
+		return count
+	}
+	size := count
+	if size > int64(10000) { // random number
+		size = int64(.0495 * float32(count))
+		for size >= int64(10000) {
+			size /= 10
+		}
+	}
+	return size
+}
+
+// getSamplingStages returns stages of a pipeline to sample documents, $sample, or $skip from a position
+// chosen by the seed and $limit in natural order
+func getSamplingStages(method string, size int64, count int64, seed int64) string {
+	if method != SamplingNatural {
+		return fmt.Sprintf(`{"$sample": {"size": %d}}`, size)
+	}
+	if size < 1 {
+		size = 1 // $limit must be positive
+	}
+	if seed != 0 && count > size {
+		skip := rand.New(rand.NewSource(seed)).Int63n(count - size + 1)
+		return fmt.Sprintf(`{"$skip": %d}, {"$limit": %d}`, skip, size)
+	}
+	return fmt.Sprintf(`{"$limit": %d}`, size)
+}
+
+// getSamplingConfidence returns fraction of documents sampled and margin of error of proportions estimated at
+// 95% confidence, with the finite population correction
+func getSamplingConfidence(size int64, count int64) (float64, float64) {
+	if size <= 0 || count <= 0 {
+		return 0, 0
+	}
+	fraction := float64(size) / float64(count)
+	if size >= count || count == 1 {
+		return fraction, 0
+	}
+	margin := 1.96 * math.Sqrt(0.25/float64(size)) * math.Sqrt(float64(count-size)/float64(count-1))
+	return fraction, margin
+}
+
 // GetSummary get summary of cardinality
 func (card *Cardinality) GetSummary(summary CardinalitySummary) string {
 	if card.verbose {
@@ -169,7 +254,15 @@ func (card *Cardinality) GetSummary(summary CardinalitySummary) string {
 	var buffer bytes.Buffer
 
 	p := message.NewPrinter(language.English)
-	buffer.WriteString("=> Cardinality (sampled data: " + p.Sprintf("%d", summary.SampledCount) + "):\n")
+	buffer.WriteString("=> Cardinality (sampled data: " + p.Sprintf("%d", summary.SampledCount))
+	if summary.TotalCount > 0 {
+		buffer.WriteString(p.Sprintf(" of %d, %.2f%%, %v", summary.TotalCount, 100*summary.SampledFraction, summary.Method))
+		if summary.Method == SamplingNatural && summary.Seed != 0 {
+			buffer.WriteString(fmt.Sprintf(", seed %d", summary.Seed))
+		}
+		buffer.WriteString(fmt.Sprintf(", margin of error ±%.2f%% at 95%% confidence", 100*summary.MarginOfError))
+	}
+	buffer.WriteString("):\n")
 	buffer.WriteString("=========================================\n")
 	for _, val := range summary.List {
 		buffer.WriteString(fmt.Sprintf("%7v: %s\n", p.Sprintf("%d", val.Count), val.Field))
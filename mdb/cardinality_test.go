@@ -6,6 +6,7 @@ import (
 	"context"
 	"encoding/json"
 	"io/ioutil"
+	"strings"
 	"testing"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -47,3 +48,52 @@ func TestGetCardinalitySummary(t *testing.T) {
 	json.Unmarshal(data, &summary)
 	t.Log(card.GetSummary(summary))
 }
+
+func TestGetSamplingStages(t *testing.T) {
+	if str := getSamplingStages(SamplingRandom, 100, 1000, 7); str != `{"$sample": {"size": 100}}` {
+		t.Fatal(str)
+	}
+	if str := getSamplingStages(SamplingNatural, 100, 1000, 0); str != `{"$limit": 100}` {
+		t.Fatal(str)
+	}
+	str := getSamplingStages(SamplingNatural, 100, 1000, 7)
+	if str != getSamplingStages(SamplingNatural, 100, 1000, 7) || strings.HasPrefix(str, `{"$skip": `) == false {
+		t.Fatal("natural order sampling isn't reproducible with a seed", str)
+	}
+	if pipeline := MongoPipeline("[" + str + "]"); len(pipeline) != 2 {
+		t.Fatal(pipeline)
+	}
+}
+
+func TestGetSampleSize(t *testing.T) {
+	card := NewCardinality(nil)
+	if size := card.getSampleSize(1000); size != 1000 {
+		t.Fatal(size)
+	}
+	if size := card.getSampleSize(1000000); size != 4950 {
+		t.Fatal(size)
+	}
+	card.SetSampleSize(500)
+	if size := card.getSampleSize(100); size != 100 {
+		t.Fatal(size)
+	}
+	if err := card.SetSamplingMethod("random"); err == nil {
+		t.Fatal("expected an error of an invalid sampling method")
+	}
+}
+
+func TestGetSamplingConfidence(t *testing.T) {
+	fraction, margin := getSamplingConfidence(1000, 1000000)
+	if fraction != 0.001 || margin < 0.03 || margin > 0.032 {
+		t.Fatal(fraction, margin)
+	}
+	if fraction, margin = getSamplingConfidence(100, 100); fraction != 1 || margin != 0 {
+		t.Fatal(fraction, margin)
+	}
+	card := NewCardinality(nil)
+	summary := CardinalitySummary{SampledCount: 1000, TotalCount: 1000000, SampledFraction: fraction, MarginOfError: margin,
+		Method: SamplingNatural, Seed: 7}
+	if str := card.GetSummary(summary); strings.Contains(str, "of 1,000,000") == false || strings.Contains(str, "seed 7") == false {
+		t.Fatal(str)
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ChangeStreamStats is statistics of change events, lag is of cluster times of events behind receiving them
+type ChangeStreamStats struct {
+	Start               time.Time      `json:"start"`
+	End                 time.Time      `json:"end"`
+	Events              int            `json:"events"`
+	EventsPerSec        float64        `json:"eventsPerSec"`
+	OperationTypes      map[string]int `json:"operationTypes"`
+	Namespaces          map[string]int `json:"namespaces"`
+	AvgFullDocumentSize float64        `json:"avgFullDocumentSize"`
+	AvgLagSeconds       float64        `json:"avgLagSeconds"`
+	MaxLagSeconds       float64        `json:"maxLagSeconds"`
+	ResumeToken         string         `json:"resumeToken"` // of the last event to resume from
+	fullDocuments       int
+	fullDocumentBytes   int64
+	totalLagSeconds     float64
+}
+
+// SetDumpFile sets a file to append events to as NDJSON when watching for stats
+func (cs *ChangeStream) SetDumpFile(filename string) {
+	cs.dumpFile = filename
+}
+
+// newChangeStreamStats returns an empty ChangeStreamStats
+func newChangeStreamStats(start time.Time) *ChangeStreamStats {
+	return &ChangeStreamStats{Start: start, End: start, OperationTypes: map[string]int{}, Namespaces: map[string]int{}}
+}
+
+// WatchStats watches change events for a duration and returns their statistics
+func (cs *ChangeStream) WatchStats(client *mongo.Client, duration time.Duration) (*ChangeStreamStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+	return cs.WatchStatsContext(ctx, client)
+}
+
+// WatchStatsContext watches change events until ctx is done and returns their statistics, events are appended
+// to the dump file if set
+func (cs *ChangeStream) WatchStatsContext(ctx context.Context, client *mongo.Client) (*ChangeStreamStats, error) {
+	var err error
+	var stream *mongo.ChangeStream
+	stats := newChangeStreamStats(time.Now())
+	var writer *bufio.Writer
+	if cs.dumpFile != "" {
+		var file *os.File
+		if file, err = os.OpenFile(cs.dumpFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+			return stats, err
+		}
+		defer file.Close()
+		writer = bufio.NewWriter(file)
+		defer writer.Flush()
+	}
+	opts := options.ChangeStream()
+	opts.SetFullDocument("updateLookup")
+	if stream, err = cs.open(ctx, client, opts); err != nil {
+		return stats, err
+	}
+	defer stream.Close(context.Background())
+	for stream.Next(ctx) {
+		stats.add(stream.Current, time.Now())
+		if writer == nil {
+			continue
+		}
+		var data []byte
+		if data, err = bson.MarshalExtJSON(stream.Current, false, false); err != nil {
+			return stats, err
+		}
+		writer.Write(append(data, '\n'))
+	}
+	stats.End = time.Now()
+	stats.setRates()
+	if err = stream.Err(); err != nil && ctx.Err() == nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// open opens a change stream of a collection, a database, or the deployment
+func (cs *ChangeStream) open(ctx context.Context, client *mongo.Client, opts *options.ChangeStreamOptions) (*mongo.ChangeStream, error) {
+	if cs.collection != "" && cs.database != "" {
+		return client.Database(cs.database).Collection(cs.collection).Watch(ctx, cs.pipeline, opts)
+	} else if cs.database != "" {
+		return client.Database(cs.database).Watch(ctx, cs.pipeline, opts)
+	}
+	return client.Watch(ctx, cs.pipeline, opts)
+}
+
+// add adds a change event received at a time
+func (s *ChangeStreamStats) add(event bson.Raw, received time.Time) {
+	s.Events++
+	if op, ok := event.Lookup("operationType").StringValueOK(); ok == true {
+		s.OperationTypes[op]++
+	}
+	db, _ := event.Lookup("ns", "db").StringValueOK()
+	coll, _ := event.Lookup("ns", "coll").StringValueOK()
+	if db != "" {
+		if coll != "" {
+			db += "." + coll
+		}
+		s.Namespaces[db]++
+	}
+	if doc, ok := event.Lookup("fullDocument").DocumentOK(); ok == true {
+		s.fullDocuments++
+		s.fullDocumentBytes += int64(len(doc))
+	}
+	if t, _, ok := event.Lookup("clusterTime").TimestampOK(); ok == true {
+		lag := received.Sub(time.Unix(int64(t), 0)).Seconds()
+		if lag < 0 {
+			lag = 0
+		}
+		s.totalLagSeconds += lag
+		if lag > s.MaxLagSeconds {
+			s.MaxLagSeconds = lag
+		}
+	}
+	if token, ok := event.Lookup("_id", "_data").StringValueOK(); ok == true {
+		s.ResumeToken = token
+	}
+}
+
+// setRates sets events per second and averages
+func (s *ChangeStreamStats) setRates() {
+	if seconds := s.End.Sub(s.Start).Seconds(); seconds > 0 {
+		s.EventsPerSec = float64(s.Events) / seconds
+	}
+	if s.fullDocuments > 0 {
+		s.AvgFullDocumentSize = float64(s.fullDocumentBytes) / float64(s.fullDocuments)
+	}
+	if s.Events > 0 {
+		s.AvgLagSeconds = s.totalLagSeconds / float64(s.Events)
+	}
+}
+
+// GetChangeStreamStatsSummary returns rates, breakdowns by operation types and namespaces, sizes, and lags of
+// change events
+func GetChangeStreamStatsSummary(s *ChangeStreamStats) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("%d change event(s) from %v to %v, %.2f events/sec\n", s.Events, s.Start.Format(time.RFC3339),
+		s.End.Format(time.RFC3339), s.EventsPerSec))
+	for _, m := range []struct {
+		title  string
+		counts map[string]int
+	}{{"operationType", s.OperationTypes}, {"namespace", s.Namespaces}} {
+		keys := []string{}
+		for key := range m.counts {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if m.counts[keys[i]] != m.counts[keys[j]] {
+				return m.counts[keys[i]] > m.counts[keys[j]]
+			}
+			return keys[i] < keys[j]
+		})
+		for _, key := range keys {
+			buffer.WriteString(fmt.Sprintf("  %-14s %-40s %10d\n", m.title, key, m.counts[key]))
+		}
+	}
+	buffer.WriteString(fmt.Sprintf("Average fullDocument size: %.0f bytes\n", s.AvgFullDocumentSize))
+	buffer.WriteString(fmt.Sprintf("Lag of cluster times: avg %.1f, max %.1f seconds\n", s.AvgLagSeconds, s.MaxLagSeconds))
+	if s.ResumeToken != "" {
+		buffer.WriteString(fmt.Sprintf("Resume token: %v\n", s.ResumeToken))
+	}
+	return buffer.String()
+}
@@ -0,0 +1,45 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestChangeStreamStats(t *testing.T) {
+	start := time.Date(2019, 10, 1, 10, 0, 0, 0, time.UTC)
+	stats := newChangeStreamStats(start)
+	car := bson.D{{Key: "_id", Value: 1}, {Key: "color", Value: "Red"}}
+	size, _ := bson.Marshal(car)
+	events := []bson.D{
+		{{Key: "_id", Value: bson.D{{Key: "_data", Value: "token1"}}}, {Key: "operationType", Value: "insert"},
+			{Key: "clusterTime", Value: primitive.Timestamp{T: uint32(start.Unix())}}, {Key: "fullDocument", Value: car},
+			{Key: "ns", Value: bson.D{{Key: "db", Value: "keyhole"}, {Key: "coll", Value: "cars"}}}},
+		{{Key: "_id", Value: bson.D{{Key: "_data", Value: "token2"}}}, {Key: "operationType", Value: "delete"},
+			{Key: "clusterTime", Value: primitive.Timestamp{T: uint32(start.Unix()) + 1}},
+			{Key: "ns", Value: bson.D{{Key: "db", Value: "keyhole"}, {Key: "coll", Value: "cars"}}}},
+		{{Key: "_id", Value: bson.D{{Key: "_data", Value: "token3"}}}, {Key: "operationType", Value: "dropDatabase"},
+			{Key: "clusterTime", Value: primitive.Timestamp{T: uint32(start.Unix()) + 2}}, {Key: "ns", Value: bson.D{{Key: "db", Value: "test"}}}},
+	}
+	for i, event := range events {
+		data, _ := bson.Marshal(event)
+		stats.add(bson.Raw(data), start.Add(time.Duration(i+2)*time.Second))
+	}
+	stats.End = start.Add(10 * time.Second)
+	stats.setRates()
+	if stats.Events != 3 || stats.EventsPerSec != 0.3 || stats.OperationTypes["insert"] != 1 || stats.Namespaces["keyhole.cars"] != 2 ||
+		stats.Namespaces["test"] != 1 || stats.AvgFullDocumentSize != float64(len(size)) || stats.MaxLagSeconds != 2 ||
+		stats.AvgLagSeconds != 2 || stats.ResumeToken != "token3" {
+		t.Fatal(stats)
+	}
+	str := GetChangeStreamStatsSummary(stats)
+	t.Log(str)
+	if strings.Contains(str, "3 change event(s)") == false || strings.Contains(str, "Resume token: token3") == false {
+		t.Fatal(str)
+	}
+}
@@ -16,6 +16,7 @@ import (
 type ChangeStream struct {
 	collection string
 	database   string
+	dumpFile   string
 	pipeline   []bson.D
 }
 
@@ -60,21 +61,13 @@ func (cs *ChangeStream) Watch(client *mongo.Client, cb callback) {
 	opts.SetFullDocument("updateLookup")
 	if cs.collection != "" && cs.database != "" {
 		fmt.Println("Watching", cs.database+"."+cs.collection)
-		var coll = client.Database(cs.database).Collection(cs.collection)
-		if cur, err = coll.Watch(ctx, cs.pipeline, opts); err != nil {
-			panic(err)
-		}
 	} else if cs.database != "" {
 		fmt.Println("Watching", cs.database)
-		var db = client.Database(cs.database)
-		if cur, err = db.Watch(ctx, cs.pipeline, opts); err != nil {
-			panic(err)
-		}
 	} else {
 		fmt.Println("Watching all")
-		if cur, err = client.Watch(ctx, cs.pipeline, opts); err != nil {
-			panic(err)
-		}
+	}
+	if cur, err = cs.open(ctx, client, opts); err != nil {
+		panic(err)
 	}
 
 	defer cur.Close(ctx)
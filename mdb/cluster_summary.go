@@ -0,0 +1,322 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/simagix/gox"
+	"github.com/simagix/keyhole/atlas"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ClusterInfo discovers topology and members of a cluster and summarizes their builds and key settings
+type ClusterInfo struct {
+	atlas   *atlas.Cluster
+	client  *mongo.Client
+	uri     string
+	verbose bool
+}
+
+// ClusterSummary is topology and members of a cluster for health checks
+type ClusterSummary struct {
+	Generated  time.Time            `json:"generated"`
+	Topology   string               `json:"topology"` // standalone, replica, or sharded
+	Members    []MemberSummary      `json:"members"`  // mongos first, then members of replica sets
+	Atlas      *atlas.ClusterReport `json:"atlas,omitempty"`
+	AtlasError string               `json:"atlasError,omitempty"`
+}
+
+// MemberSummary is build, storage, and key settings of a member of a cluster
+type MemberSummary struct {
+	Host          string   `json:"host"`
+	ReplSet       string   `json:"replSet,omitempty"`
+	State         string   `json:"state"` // e.g. PRIMARY, SECONDARY, mongos, or standalone
+	Version       string   `json:"version"`
+	GitVersion    string   `json:"gitVersion"`
+	Modules       []string `json:"modules"`
+	StorageEngine string   `json:"storageEngine"`
+	CacheSizeGB   float64  `json:"cacheSizeGB"`
+	SlowMS        int      `json:"slowms"`
+	OplogWindow   float64  `json:"oplogWindowHours"` // 0 if not a member of a replica set
+	Error         string   `json:"error,omitempty"`
+}
+
+// NewClusterInfo returns a ClusterInfo, members are connected directly using credentials of uri
+func NewClusterInfo(client *mongo.Client, uri string) *ClusterInfo {
+	return &ClusterInfo{client: client, uri: uri}
+}
+
+// SetAtlasCluster sets an Atlas cluster of which configuration, measurements, and index suggestions are merged
+func (ci *ClusterInfo) SetAtlasCluster(cluster *atlas.Cluster) {
+	ci.atlas = cluster
+}
+
+// SetVerbose sets verbose level
+func (ci *ClusterInfo) SetVerbose(verbose bool) {
+	ci.verbose = verbose
+}
+
+// GetClusterSummary returns topology and summaries of members of a cluster
+func (ci *ClusterInfo) GetClusterSummary() (ClusterSummary, error) {
+	return ci.GetClusterSummaryContext(context.Background())
+}
+
+// GetClusterSummaryContext returns topology and summaries of members of a cluster, stops when ctx is done.
+// Errors of a member are kept in its summary and don't stop discovering other members, neither do errors of Atlas.
+func (ci *ClusterInfo) GetClusterSummaryContext(ctx context.Context) (ClusterSummary, error) {
+	summary, err := ci.getMembersSummary(ctx)
+	if err == nil && ci.atlas != nil {
+		report, aerr := ci.atlas.GetReport()
+		if aerr != nil {
+			summary.AtlasError = aerr.Error()
+		} else {
+			summary.Atlas = &report
+		}
+	}
+	return summary, err
+}
+
+// getMembersSummary returns topology and summaries of members of a cluster
+func (ci *ClusterInfo) getMembersSummary(ctx context.Context) (ClusterSummary, error) {
+	var err error
+	var doc isMasterDoc
+	summary := ClusterSummary{Generated: time.Now(), Members: []MemberSummary{}}
+	if err = ci.client.Database("admin").RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&doc); err != nil {
+		return summary, err
+	}
+	summary.Topology = getTopology(doc)
+	if summary.Topology == STANDALONE {
+		member := MemberSummary{Host: doc.Me, State: STANDALONE}
+		setMemberSummary(ctx, ci.client, &member)
+		summary.Members = append(summary.Members, member)
+		return summary, err
+	} else if summary.Topology == REPLICA {
+		summary.Members = append(summary.Members, ci.getReplSetMembers(ctx, ci.client)...)
+		return summary, err
+	}
+	mongos := MemberSummary{State: "mongos"}
+	setMemberSummary(ctx, ci.client, &mongos)
+	summary.Members = append(summary.Members, mongos)
+	var uriList []string
+	if uriList, err = GetShards(ci.client, ci.uri); err != nil {
+		return summary, err
+	}
+	for _, shardURI := range uriList {
+		if err = ctx.Err(); err != nil {
+			return summary, err
+		}
+		var client *mongo.Client
+		if client, err = NewMongoClient(shardURI); err != nil {
+			return summary, err
+		}
+		summary.Members = append(summary.Members, ci.getReplSetMembers(ctx, client)...)
+		client.Disconnect(ctx)
+	}
+	return summary, err
+}
+
+// isMasterDoc is a result of isMaster
+type isMasterDoc struct {
+	Hosts    []string `bson:"hosts"`
+	Me       string   `bson:"me"`
+	Msg      string   `bson:"msg"`
+	Passives []string `bson:"passives"`
+	SetName  string   `bson:"setName"`
+}
+
+// getTopology returns sharded, replica, or standalone from isMaster
+func getTopology(doc isMasterDoc) string {
+	if doc.Msg == "isdbgrid" {
+		return SHARDED
+	} else if doc.SetName != "" {
+		return REPLICA
+	}
+	return STANDALONE
+}
+
+// getReplSetMembers returns summaries of members of a replica set, each connected directly
+func (ci *ClusterInfo) getReplSetMembers(ctx context.Context, client *mongo.Client) []MemberSummary {
+	var status struct {
+		Set     string          `bson:"set"`
+		Members []replSetMember `bson:"members"`
+	}
+	members := []MemberSummary{}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "replSetGetStatus", Value: 1}}).Decode(&status); err != nil {
+		return append(members, MemberSummary{Error: err.Error()})
+	}
+	for _, m := range status.Members {
+		member := MemberSummary{Host: m.Name, ReplSet: status.Set, State: m.StateStr}
+		if ci.verbose == true {
+			fmt.Println("summarizing", status.Set, m.Name, m.StateStr)
+		}
+		if m.StateStr == "ARBITER" {
+			members = append(members, member)
+			continue
+		}
+		mc, err := NewMongoClient(getDirectURI(ci.uri, m.Name))
+		if err != nil {
+			member.Error = err.Error()
+			members = append(members, member)
+			continue
+		}
+		setMemberSummary(ctx, mc, &member)
+		mc.Disconnect(ctx)
+		members = append(members, member)
+	}
+	return members
+}
+
+// setMemberSummary sets build info, storage engine, WiredTiger cache size, slowms, and oplog window of a member
+func setMemberSummary(ctx context.Context, client *mongo.Client, member *MemberSummary) {
+	var err error
+	opCtx, cancel := context.WithTimeout(ctx, memberPingTimeout)
+	defer cancel()
+	admin := client.Database("admin")
+	var buildInfo struct {
+		Version    string   `bson:"version"`
+		GitVersion string   `bson:"gitVersion"`
+		Modules    []string `bson:"modules"`
+	}
+	if err = admin.RunCommand(opCtx, bson.D{{Key: "buildInfo", Value: 1}}).Decode(&buildInfo); err != nil {
+		member.Error = err.Error()
+		return
+	}
+	member.Version, member.GitVersion, member.Modules = buildInfo.Version, buildInfo.GitVersion, buildInfo.Modules
+	var status struct {
+		Host          string `bson:"host"`
+		StorageEngine struct {
+			Name string `bson:"name"`
+		} `bson:"storageEngine"`
+		WiredTiger struct {
+			Cache struct {
+				MaxBytes float64 `bson:"maximum bytes configured"`
+			} `bson:"cache"`
+		} `bson:"wiredTiger"`
+	}
+	cmd := bson.D{{Key: "serverStatus", Value: 1}, {Key: "locks", Value: 0}, {Key: "metrics", Value: 0}, {Key: "repl", Value: 0}}
+	if err = admin.RunCommand(opCtx, cmd).Decode(&status); err != nil {
+		member.Error = err.Error()
+		return
+	}
+	if member.Host == "" {
+		member.Host = status.Host
+	}
+	member.StorageEngine = status.StorageEngine.Name
+	member.CacheSizeGB = status.WiredTiger.Cache.MaxBytes / (1024 * 1024 * 1024)
+	var profile struct {
+		SlowMS int `bson:"slowms"`
+	}
+	if err = admin.RunCommand(opCtx, bson.D{{Key: "profile", Value: -1}}).Decode(&profile); err == nil {
+		member.SlowMS = profile.SlowMS
+	}
+	if member.ReplSet != "" {
+		if member.OplogWindow, err = getOplogWindow(opCtx, client); err != nil {
+			member.Error = err.Error()
+		}
+	}
+}
+
+// getOplogWindow returns hours between the first and the last entries of the oplog
+func getOplogWindow(ctx context.Context, client *mongo.Client) (float64, error) {
+	var err error
+	oplog := client.Database("local").Collection("oplog.rs")
+	var first, last struct {
+		TS primitive.Timestamp `bson:"ts"`
+	}
+	opts := options.FindOne().SetProjection(bson.D{{Key: "ts", Value: 1}})
+	if err = oplog.FindOne(ctx, bson.D{}, opts.SetSort(bson.D{{Key: "$natural", Value: 1}})).Decode(&first); err != nil {
+		return 0, err
+	}
+	if err = oplog.FindOne(ctx, bson.D{}, opts.SetSort(bson.D{{Key: "$natural", Value: -1}})).Decode(&last); err != nil {
+		return 0, err
+	}
+	return getOplogWindowHours(first.TS, last.TS), err
+}
+
+// getOplogWindowHours returns hours between timestamps of the first and the last entries of an oplog
+func getOplogWindowHours(first primitive.Timestamp, last primitive.Timestamp) float64 {
+	if last.T < first.T {
+		return 0
+	}
+	return float64(last.T-first.T) / 3600
+}
+
+// GetClusterSummaryTable returns topology and a table of members of a cluster
+func GetClusterSummaryTable(summary ClusterSummary) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("Topology: %v, %d member(s)\n", summary.Topology, len(summary.Members)))
+	buffer.WriteString(fmt.Sprintf("%-30s %-12s %-10s %-10s %-12s %8s %7s %12s\n", "host", "replSet", "state", "version",
+		"engine", "cache GB", "slowms", "oplog hours"))
+	for _, m := range summary.Members {
+		buffer.WriteString(fmt.Sprintf("%-30s %-12s %-10s %-10s %-12s %8.1f %7d %12.1f\n", m.Host, m.ReplSet, m.State, m.Version,
+			m.StorageEngine, m.CacheSizeGB, m.SlowMS, m.OplogWindow))
+		if m.Error != "" {
+			buffer.WriteString(fmt.Sprintf("\terror: %v\n", m.Error))
+		}
+	}
+	if summary.Atlas != nil {
+		buffer.WriteString("\n" + atlas.GetClusterReportSummary(*summary.Atlas))
+	} else if summary.AtlasError != "" {
+		buffer.WriteString(fmt.Sprintf("\nAtlas error: %v\n", summary.AtlasError))
+	}
+	return buffer.String()
+}
+
+// WriteClusterSummary writes a cluster summary into a file of json or html and returns the file name
+func WriteClusterSummary(summary ClusterSummary, basename string, format string) (string, error) {
+	basename = strings.Replace(basename, ":", "_", -1)
+	if format == "html" {
+		ofile := basename + "-cluster.html"
+		return ofile, ioutil.WriteFile(ofile, []byte(getClusterSummaryHTML(summary)), 0644)
+	}
+	ofile := basename + "-cluster.json"
+	return ofile, ioutil.WriteFile(ofile, []byte(gox.Stringify(summary, "", "  ")), 0644)
+}
+
+// getClusterSummaryHTML returns a self-contained HTML page of a cluster summary
+func getClusterSummaryHTML(summary ClusterSummary) string {
+	var buffer bytes.Buffer
+	title := "Keyhole Cluster Summary - " + summary.Topology
+	buffer.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	buffer.WriteString("<title>" + html.EscapeString(title) + "</title>\n")
+	buffer.WriteString(htmlStyle)
+	buffer.WriteString(htmlScript)
+	buffer.WriteString("</head>\n<body>\n")
+	buffer.WriteString("<h1>" + html.EscapeString(title) + "</h1>\n")
+	buffer.WriteString("<p>Generated at " + summary.Generated.Format(time.RFC3339) + "</p>\n")
+	buffer.WriteString("<table>\n<thead><tr>")
+	for _, name := range []string{"Host", "Replica Set", "State", "Version", "Git Version", "Modules", "Storage Engine",
+		"Cache GB", "slowms", "Oplog Hours", "Error"} {
+		buffer.WriteString("<th onclick=\"sortTable(this)\">" + name + "</th>")
+	}
+	buffer.WriteString("</tr></thead>\n<tbody>\n")
+	for _, m := range summary.Members {
+		class := ""
+		if m.Error != "" {
+			class = " class=\"collscan\""
+		}
+		buffer.WriteString(fmt.Sprintf("<tr%s><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td class=\"pattern\">%s</td><td>%s</td><td>%s</td>",
+			class, html.EscapeString(m.Host), html.EscapeString(m.ReplSet), html.EscapeString(m.State), html.EscapeString(m.Version),
+			html.EscapeString(m.GitVersion), html.EscapeString(strings.Join(m.Modules, ", ")), html.EscapeString(m.StorageEngine)))
+		buffer.WriteString(fmt.Sprintf("<td class=\"num\">%.1f</td><td class=\"num\">%d</td><td class=\"num\">%.1f</td><td>%s</td></tr>\n",
+			m.CacheSizeGB, m.SlowMS, m.OplogWindow, html.EscapeString(m.Error)))
+	}
+	buffer.WriteString("</tbody>\n</table>\n")
+	if summary.Atlas != nil {
+		buffer.WriteString("<h2>Atlas</h2>\n<pre>" + html.EscapeString(atlas.GetClusterReportSummary(*summary.Atlas)) + "</pre>\n")
+	} else if summary.AtlasError != "" {
+		buffer.WriteString("<h2>Atlas</h2>\n<p>" + html.EscapeString(summary.AtlasError) + "</p>\n")
+	}
+	buffer.WriteString("</body>\n</html>\n")
+	return buffer.String()
+}
@@ -0,0 +1,75 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func getTestClusterSummary() ClusterSummary {
+	return ClusterSummary{Generated: time.Now(), Topology: SHARDED, Members: []MemberSummary{
+		{Host: "mongos.local:27017", State: "mongos", Version: "4.2.0"},
+		{Host: "shard0.local:27018", ReplSet: "shard0", State: "PRIMARY", Version: "4.2.0", StorageEngine: "wiredTiger",
+			CacheSizeGB: 1.5, SlowMS: 100, OplogWindow: 25.5, Modules: []string{"enterprise"}},
+		{Host: "shard0.local:27019", ReplSet: "shard0", State: "SECONDARY", Error: "<auth failed>"},
+	}}
+}
+
+func TestGetTopology(t *testing.T) {
+	if topology := getTopology(isMasterDoc{Msg: "isdbgrid"}); topology != SHARDED {
+		t.Fatal(topology)
+	}
+	if topology := getTopology(isMasterDoc{SetName: "replset"}); topology != REPLICA {
+		t.Fatal(topology)
+	}
+	if topology := getTopology(isMasterDoc{}); topology != STANDALONE {
+		t.Fatal(topology)
+	}
+}
+
+func TestGetOplogWindowHours(t *testing.T) {
+	if hours := getOplogWindowHours(primitive.Timestamp{T: 1569924000}, primitive.Timestamp{T: 1569924000 + 5400}); hours != 1.5 {
+		t.Fatal(hours)
+	}
+	if hours := getOplogWindowHours(primitive.Timestamp{T: 10}, primitive.Timestamp{T: 5}); hours != 0 {
+		t.Fatal(hours)
+	}
+}
+
+func TestGetClusterSummaryTable(t *testing.T) {
+	str := GetClusterSummaryTable(getTestClusterSummary())
+	t.Log(str)
+	if strings.Contains(str, "Topology: sharded, 3 member(s)") == false || strings.Contains(str, "error: <auth failed>") == false {
+		t.Fatal(str)
+	}
+}
+
+func TestWriteClusterSummary(t *testing.T) {
+	summary := getTestClusterSummary()
+	ofile, err := WriteClusterSummary(summary, "localhost:27017", "html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(ofile)
+	data, _ := ioutil.ReadFile(ofile)
+	if ofile != "localhost_27017-cluster.html" || strings.Contains(string(data), "&lt;auth failed&gt;") == false ||
+		strings.Contains(string(data), "<td>enterprise</td>") == false {
+		t.Fatal(ofile, string(data))
+	}
+	if ofile, err = WriteClusterSummary(summary, "localhost:27017", "json"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(ofile)
+	data, _ = ioutil.ReadFile(ofile)
+	var doc ClusterSummary
+	if err = json.Unmarshal(data, &doc); err != nil || len(doc.Members) != 3 || doc.Members[1].OplogWindow != 25.5 {
+		t.Fatal(err, string(data))
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// compactFragmentationRatio is the ratio of reusable bytes to storage size above which a collection is fragmented
+const compactFragmentationRatio = 0.3
+
+// compactMinReclaimable is the least reusable bytes for compact to be worthwhile
+const compactMinReclaimable = 64 * 1024 * 1024
+
+// CollStatsReader reads storage statistics of collections
+type CollStatsReader struct {
+	client  *mongo.Client
+	dbName  string
+	sortBy  string
+	verbose bool
+}
+
+// CollStatsDoc holds storage statistics of a collection from collStats
+type CollStatsDoc struct {
+	Namespace        string         `json:"namespace"`
+	Count            int            `json:"count"`
+	AvgObjSize       int            `json:"avgObjSize"`
+	Size             int            `json:"size"` // uncompressed
+	StorageSize      int            `json:"storageSize"`
+	FreeStorageSize  int            `json:"freeStorageSize"` // reusable bytes of storageSize
+	TotalIndexSize   int            `json:"totalIndexSize"`
+	IndexSizes       map[string]int `json:"indexSizes"`
+	CompressionRatio float64        `json:"compressionRatio"` // size to used bytes of storageSize
+	Fragmentation    float64        `json:"fragmentation"`    // freeStorageSize to storageSize
+	IsFragmented     bool           `json:"fragmented"`
+}
+
+// NewCollStatsReader returns a CollStatsReader
+func NewCollStatsReader(client *mongo.Client) *CollStatsReader {
+	return &CollStatsReader{client: client, sortBy: "namespace"}
+}
+
+// SetDBName sets database name, all databases if empty
+func (cr *CollStatsReader) SetDBName(dbName string) {
+	cr.dbName = dbName
+}
+
+// SetSortBy sets field to sort collections by, namespace, count, size, storageSize, freeStorageSize,
+// totalIndexSize, compressionRatio, or fragmentation.  Collections are sorted by namespace in ascending
+// order, and by others in descending order.
+func (cr *CollStatsReader) SetSortBy(sortBy string) {
+	cr.sortBy = sortBy
+}
+
+// SetVerbose sets verbose level
+func (cr *CollStatsReader) SetVerbose(verbose bool) {
+	cr.verbose = verbose
+}
+
+// GetCollStats returns storage statistics of collections of all databases or of the database set
+func (cr *CollStatsReader) GetCollStats() ([]CollStatsDoc, error) {
+	var err error
+	var dbNames []string
+	ctx := context.Background()
+	docs := []CollStatsDoc{}
+	if err = validateCollStatsSortBy(cr.sortBy); err != nil {
+		return docs, err
+	}
+	if cr.dbName != "" {
+		dbNames = []string{cr.dbName}
+	} else if dbNames, err = ListDatabaseNamesContext(ctx, cr.client); err != nil {
+		return docs, err
+	}
+	for _, dbName := range dbNames {
+		if dbName == "admin" || dbName == "config" || dbName == "local" {
+			continue
+		}
+		var names []string
+		filter := bson.D{{Key: "type", Value: "collection"}}
+		if names, err = cr.client.Database(dbName).ListCollectionNames(ctx, filter); err != nil {
+			return docs, err
+		}
+		for _, name := range names {
+			if strings.HasPrefix(name, "system.") {
+				continue
+			}
+			var stats bson.M
+			if stats, err = getCollStats(ctx, cr.client.Database(dbName).Collection(name), nil); err != nil {
+				return docs, fmt.Errorf("%v.%v: %v", dbName, name, err)
+			}
+			doc := getCollStatsDoc(dbName+"."+name, stats)
+			if cr.verbose == true {
+				fmt.Println(doc.Namespace, doc.Count, "docs")
+			}
+			docs = append(docs, doc)
+		}
+	}
+	sortCollStats(docs, cr.sortBy)
+	return docs, err
+}
+
+// getCollStatsDoc returns storage statistics of a collection from collStats of a mongod or a mongos
+func getCollStatsDoc(namespace string, stats bson.M) CollStatsDoc {
+	doc := CollStatsDoc{Namespace: namespace, Count: toInt(stats["count"]), AvgObjSize: toInt(stats["avgObjSize"]),
+		Size: toInt(stats["size"]), StorageSize: toInt(stats["storageSize"]), TotalIndexSize: toInt(stats["totalIndexSize"]),
+		FreeStorageSize: getFreeStorageSize(stats), IndexSizes: map[string]int{}}
+	if indexSizes, ok := stats["indexSizes"].(bson.M); ok == true {
+		for name, size := range indexSizes {
+			doc.IndexSizes[name] = toInt(size)
+		}
+	}
+	if used := doc.StorageSize - doc.FreeStorageSize; used > 0 {
+		doc.CompressionRatio = float64(doc.Size) / float64(used)
+	}
+	if doc.StorageSize > 0 {
+		doc.Fragmentation = float64(doc.FreeStorageSize) / float64(doc.StorageSize)
+	}
+	doc.IsFragmented = doc.Fragmentation >= compactFragmentationRatio && doc.FreeStorageSize >= compactMinReclaimable
+	return doc
+}
+
+// getFreeStorageSize returns freeStorageSize of collStats (4.4+), or bytes available for reuse of
+// WiredTiger block manager before, summed from shards of a mongos
+func getFreeStorageSize(stats bson.M) int {
+	if stats["freeStorageSize"] != nil {
+		return toInt(stats["freeStorageSize"])
+	}
+	if wt, ok := stats["wiredTiger"].(bson.M); ok == true {
+		if bm, ok := wt["block-manager"].(bson.M); ok == true {
+			return toInt(bm["file bytes available for reuse"])
+		}
+	}
+	size := 0
+	if shards, ok := stats["shards"].(bson.M); ok == true {
+		for _, shard := range shards {
+			if s, ok := shard.(bson.M); ok == true {
+				size += getFreeStorageSize(s)
+			}
+		}
+	}
+	return size
+}
+
+// validateCollStatsSortBy returns an error if collections can't be sorted by a field
+func validateCollStatsSortBy(sortBy string) error {
+	if contains([]string{"namespace", "count", "size", "storageSize", "freeStorageSize", "totalIndexSize",
+		"compressionRatio", "fragmentation"}, sortBy) == false {
+		return fmt.Errorf("invalid sort field %v", sortBy)
+	}
+	return nil
+}
+
+// sortCollStats sorts collections by namespace in ascending order, or by a statistic in descending order
+func sortCollStats(docs []CollStatsDoc, sortBy string) {
+	value := func(doc CollStatsDoc) float64 {
+		switch sortBy {
+		case "count":
+			return float64(doc.Count)
+		case "size":
+			return float64(doc.Size)
+		case "storageSize":
+			return float64(doc.StorageSize)
+		case "freeStorageSize":
+			return float64(doc.FreeStorageSize)
+		case "totalIndexSize":
+			return float64(doc.TotalIndexSize)
+		case "compressionRatio":
+			return doc.CompressionRatio
+		case "fragmentation":
+			return doc.Fragmentation
+		}
+		return 0
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		if vi, vj := value(docs[i]), value(docs[j]); vi != vj {
+			return vi > vj
+		}
+		return docs[i].Namespace < docs[j].Namespace
+	})
+}
+
+// GetCollStatsSummary returns a table of storage statistics of collections, fragmented ones are marked
+// with x as candidates of compact
+func GetCollStatsSummary(docs []CollStatsDoc) string {
+	var buffer bytes.Buffer
+	if len(docs) == 0 {
+		return "No collections found"
+	}
+	buffer.WriteString(fmt.Sprintf("  %-40s %12s %10s %10s %10s %10s %10s %6s %6s\n", "namespace", "count", "avgObjSize",
+		"size", "storage", "free", "indexes", "ratio", "frag %"))
+	for _, doc := range docs {
+		mark := "  "
+		if doc.IsFragmented {
+			mark = "x "
+		}
+		buffer.WriteString(fmt.Sprintf("%s%-40s %12d %10s %10s %10s %10s %10s %6.2f %6.1f\n", mark, doc.Namespace, doc.Count,
+			GetStorageSize(doc.AvgObjSize), GetStorageSize(doc.Size), GetStorageSize(doc.StorageSize),
+			GetStorageSize(doc.FreeStorageSize), GetStorageSize(doc.TotalIndexSize), doc.CompressionRatio, 100*doc.Fragmentation))
+	}
+	return buffer.String()
+}
@@ -0,0 +1,65 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGetCollStatsDoc(t *testing.T) {
+	mb := 1024 * 1024
+	stats := bson.M{"count": int32(1000), "avgObjSize": int32(400), "size": int64(800 * mb), "storageSize": int64(400 * mb),
+		"freeStorageSize": int64(200 * mb), "totalIndexSize": int32(10 * mb), "indexSizes": bson.M{"_id_": int32(10 * mb)}}
+	doc := getCollStatsDoc("keyhole.cars", stats)
+	if doc.CompressionRatio != 4 || doc.Fragmentation != 0.5 || doc.IsFragmented == false || doc.IndexSizes["_id_"] != 10*mb {
+		t.Fatal(doc)
+	}
+	stats["freeStorageSize"] = int64(mb) // too few bytes to reclaim
+	stats["storageSize"] = int64(2 * mb)
+	if doc = getCollStatsDoc("keyhole.cars", stats); doc.IsFragmented == true {
+		t.Fatal(doc)
+	}
+}
+
+func TestGetFreeStorageSize(t *testing.T) {
+	stats := bson.M{"wiredTiger": bson.M{"block-manager": bson.M{"file bytes available for reuse": int32(4096)}}}
+	if size := getFreeStorageSize(stats); size != 4096 {
+		t.Fatal(size)
+	}
+	stats = bson.M{"shards": bson.M{"shard0": stats, "shard1": bson.M{"freeStorageSize": int32(1024)}}}
+	if size := getFreeStorageSize(stats); size != 5120 {
+		t.Fatal(size)
+	}
+}
+
+func TestSortCollStats(t *testing.T) {
+	docs := []CollStatsDoc{{Namespace: "b", Count: 10, Fragmentation: 0.1}, {Namespace: "a", Count: 5, Fragmentation: 0.6},
+		{Namespace: "c", Count: 10}}
+	sortCollStats(docs, "count")
+	if docs[0].Namespace != "b" || docs[1].Namespace != "c" || docs[2].Namespace != "a" {
+		t.Fatal(docs)
+	}
+	sortCollStats(docs, "fragmentation")
+	if docs[0].Namespace != "a" {
+		t.Fatal(docs)
+	}
+	sortCollStats(docs, "namespace")
+	if docs[0].Namespace != "a" || docs[2].Namespace != "c" {
+		t.Fatal(docs)
+	}
+	if err := validateCollStatsSortBy("avgObjSize"); err == nil {
+		t.Fatal("expected an error of an invalid sort field")
+	}
+}
+
+func TestGetCollStatsSummary(t *testing.T) {
+	docs := []CollStatsDoc{{Namespace: "keyhole.cars", Count: 10, IsFragmented: true}}
+	str := GetCollStatsSummary(docs)
+	t.Log(str)
+	if strings.Contains(str, "x keyhole.cars") == false {
+		t.Fatal(str)
+	}
+}
@@ -0,0 +1,331 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+// probeCollection is the collection of KEYHOLEDB written with the write concern, dropped after probing
+const probeCollection = "connectionProbe"
+
+// ConnectionProbe opens concurrent connections to each member and measures TCP connect, handshake and
+// authentication, and ping latencies, and counts servers selected by a read preference with a pooled client
+type ConnectionProbe struct {
+	connections  int
+	readPref     string
+	uri          string
+	verbose      bool
+	writeConcern string // w of writes to probe, no writes if empty
+}
+
+// MemberProbe is latencies of connections to a member
+type MemberProbe struct {
+	Host      string             `json:"host"`
+	Connected int                `json:"connected"`
+	Failed    int                `json:"failed"`
+	TCP       LatencyPercentiles `json:"tcp"`
+	Handshake LatencyPercentiles `json:"handshake"` // handshake, authentication, and the first round trip
+	Ping      LatencyPercentiles `json:"ping"`
+	Errors    []string           `json:"errors"` // distinct errors
+	handshake LatencyHistogram
+	ping      LatencyHistogram
+	tcp       LatencyHistogram
+}
+
+// ConnectionProbeReport is latencies of members, servers selected, and writes
+type ConnectionProbeReport struct {
+	Connections    int                `json:"connections"`
+	ReadPreference string             `json:"readPreference"`
+	WriteConcern   string             `json:"writeConcern"`
+	Members        []MemberProbe      `json:"members"`
+	Selected       map[string]int     `json:"selected"` // commands by servers selected with the read preference
+	SelectErrors   int                `json:"selectErrors"`
+	Writes         LatencyPercentiles `json:"writes"`
+	WriteErrors    int                `json:"writeErrors"`
+}
+
+// NewConnectionProbe returns a ConnectionProbe of 10 connections per member with the primary read preference
+func NewConnectionProbe(uri string) *ConnectionProbe {
+	return &ConnectionProbe{connections: 10, readPref: "primary", uri: uri}
+}
+
+// SetConnections sets number of concurrent connections per member
+func (cp *ConnectionProbe) SetConnections(connections int) {
+	if connections > 0 {
+		cp.connections = connections
+	}
+}
+
+// SetReadPreference sets a read preference mode, e.g. secondaryPreferred
+func (cp *ConnectionProbe) SetReadPreference(readPref string) {
+	cp.readPref = readPref
+}
+
+// SetVerbose sets verbose level
+func (cp *ConnectionProbe) SetVerbose(verbose bool) {
+	cp.verbose = verbose
+}
+
+// SetWriteConcern sets w of writes to probe, e.g. majority or 2, none are written if empty
+func (cp *ConnectionProbe) SetWriteConcern(writeConcern string) {
+	cp.writeConcern = writeConcern
+}
+
+// Probe probes members and server selection
+func (cp *ConnectionProbe) Probe() (ConnectionProbeReport, error) {
+	var err error
+	var client *mongo.Client
+	var mode readpref.Mode
+	var connString connstring.ConnString
+	var wc *writeconcern.WriteConcern
+	report := ConnectionProbeReport{Connections: cp.connections, ReadPreference: cp.readPref, WriteConcern: cp.writeConcern,
+		Members: []MemberProbe{}, Selected: map[string]int{}}
+	if mode, err = readpref.ModeFromString(cp.readPref); err != nil {
+		return report, err
+	}
+	if cp.writeConcern != "" {
+		if wc, err = getWriteConcern(cp.writeConcern); err != nil {
+			return report, err
+		}
+	}
+	if connString, err = connstring.Parse(cp.uri); err != nil {
+		return report, err
+	}
+	var mutex sync.Mutex
+	monitor := &event.CommandMonitor{Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+		if evt.CommandName == "ping" {
+			mutex.Lock()
+			report.Selected[getConnectionHost(evt.ConnectionID)]++
+			mutex.Unlock()
+		}
+	}}
+	opts := options.Client().ApplyURI(cp.uri).SetMonitor(monitor).SetMaxPoolSize(uint64(cp.connections))
+	if connString.Username == "" {
+		opts.Auth = nil
+	}
+	if client, err = mongo.Connect(context.Background(), opts); err != nil {
+		return report, err
+	}
+	defer client.Disconnect(context.Background())
+	hosts := getMemberHosts(client, connString)
+	if cp.verbose == true {
+		fmt.Println("probing", len(hosts), "member(s):", strings.Join(hosts, ", "))
+	}
+	for _, host := range hosts {
+		report.Members = append(report.Members, cp.probeMember(host))
+	}
+	report.SelectErrors = cp.probeSelection(client, mode)
+	if cp.writeConcern != "" {
+		report.Writes, report.WriteErrors = cp.probeWrites(client, wc)
+	}
+	return report, nil
+}
+
+// getMemberHosts returns members of a replica set, or hosts of the URI of a standalone or mongos
+func getMemberHosts(client *mongo.Client, connString connstring.ConnString) []string {
+	var doc isMasterDoc
+	ctx, cancel := context.WithTimeout(context.Background(), memberPingTimeout)
+	defer cancel()
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&doc); err == nil &&
+		getTopology(doc) == REPLICA {
+		return append(doc.Hosts, doc.Passives...)
+	}
+	return connString.Hosts
+}
+
+// probeMember opens concurrent connections to a member, each measured for TCP connect, handshake, and ping
+func (cp *ConnectionProbe) probeMember(host string) MemberProbe {
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	member := MemberProbe{Host: host, Errors: []string{}, handshake: NewLatencyHistogram(), ping: NewLatencyHistogram(),
+		tcp: NewLatencyHistogram()}
+	for i := 0; i < cp.connections; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tcp, handshake, ping, err := cp.probeConnection(host)
+			mutex.Lock()
+			defer mutex.Unlock()
+			member.addConnection(tcp, handshake, ping, err)
+		}()
+	}
+	wg.Wait()
+	member.TCP = member.tcp.GetMilliPercentiles()
+	member.Handshake = member.handshake.GetMilliPercentiles()
+	member.Ping = member.ping.GetMilliPercentiles()
+	return member
+}
+
+// probeConnection returns latencies of a TCP connect, of a handshake with authentication, and of a ping
+func (cp *ConnectionProbe) probeConnection(host string) (time.Duration, time.Duration, time.Duration, error) {
+	var err error
+	var conn net.Conn
+	var client *mongo.Client
+	t := time.Now()
+	if conn, err = net.DialTimeout("tcp", host, memberPingTimeout); err != nil {
+		return 0, 0, 0, err
+	}
+	tcp := time.Since(t)
+	conn.Close()
+	opts := options.Client().ApplyURI(getDirectURI(cp.uri, host)).SetMaxPoolSize(1)
+	if connString, _ := connstring.Parse(cp.uri); connString.Username == "" {
+		opts.Auth = nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), memberPingTimeout)
+	defer cancel()
+	if client, err = mongo.Connect(ctx, opts); err != nil {
+		return tcp, 0, 0, err
+	}
+	defer client.Disconnect(context.Background())
+	t = time.Now()
+	if err = client.Ping(ctx, readpref.Nearest()); err != nil {
+		return tcp, 0, 0, err
+	}
+	handshake := time.Since(t)
+	t = time.Now()
+	if err = client.Ping(ctx, readpref.Nearest()); err != nil {
+		return tcp, handshake, 0, err
+	}
+	return tcp, handshake, time.Since(t), nil
+}
+
+// addConnection adds latencies of a connection, or its error
+func (m *MemberProbe) addConnection(tcp time.Duration, handshake time.Duration, ping time.Duration, err error) {
+	if err != nil {
+		m.Failed++
+		if contains(m.Errors, err.Error()) == false {
+			m.Errors = append(m.Errors, err.Error())
+		}
+		return
+	}
+	m.Connected++
+	m.tcp.Add(int(tcp / time.Microsecond))
+	m.handshake.Add(int(handshake / time.Microsecond))
+	m.ping.Add(int(ping / time.Microsecond))
+}
+
+// probeSelection pings concurrently with the read preference and returns number of failures, servers selected are
+// counted by the command monitor
+func (cp *ConnectionProbe) probeSelection(client *mongo.Client, mode readpref.Mode) int {
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	rp, _ := readpref.New(mode)
+	failures := 0
+	for i := 0; i < cp.connections*10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), memberPingTimeout)
+			defer cancel()
+			opts := options.RunCmd().SetReadPreference(rp)
+			if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "ping", Value: 1}}, opts).Err(); err != nil {
+				mutex.Lock()
+				failures++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return failures
+}
+
+// getWriteConcern returns a write concern of w, i.e. majority, a number of members, or a tag set name
+func getWriteConcern(w string) (*writeconcern.WriteConcern, error) {
+	if w == "majority" {
+		return writeconcern.New(writeconcern.WMajority()), nil
+	}
+	if n, err := strconv.Atoi(w); err == nil {
+		if n < 0 {
+			return nil, fmt.Errorf("invalid write concern %v", w)
+		}
+		return writeconcern.New(writeconcern.W(n)), nil
+	}
+	if w == "" || strings.ContainsAny(w[:1], "+-.0123456789") { // not a number nor a tag set name
+		return nil, fmt.Errorf("invalid write concern %v", w)
+	}
+	return writeconcern.New(writeconcern.WTagSet(w)), nil
+}
+
+// probeWrites inserts documents concurrently with the write concern and returns latencies and number of failures
+func (cp *ConnectionProbe) probeWrites(client *mongo.Client, wc *writeconcern.WriteConcern) (LatencyPercentiles, int) {
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	h := NewLatencyHistogram()
+	failures := 0
+	coll := client.Database(KEYHOLEDB).Collection(probeCollection, options.Collection().SetWriteConcern(wc))
+	defer coll.Drop(context.Background())
+	for i := 0; i < cp.connections*10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), memberPingTimeout)
+			defer cancel()
+			t := time.Now()
+			_, err := coll.InsertOne(ctx, bson.D{{Key: "n", Value: i}, {Key: "ts", Value: t}})
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				failures++
+				return
+			}
+			h.Add(int(time.Since(t) / time.Microsecond))
+		}(i)
+	}
+	wg.Wait()
+	return h.GetMilliPercentiles(), failures
+}
+
+// getConnectionHost returns the host of a connection id, e.g. host:27017 of host:27017[-12]
+func getConnectionHost(connectionID string) string {
+	if pos := strings.Index(connectionID, "["); pos > 0 {
+		return connectionID[:pos]
+	}
+	return connectionID
+}
+
+// GetConnectionProbeSummary returns latencies of members, servers selected, and writes
+func GetConnectionProbeSummary(report ConnectionProbeReport) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("%d connection(s) per member, p50/p95/max ms\n", report.Connections))
+	buffer.WriteString(fmt.Sprintf("%-30s %6s %6s | %-20s | %-20s | %-20s\n", "member", "ok", "failed", "tcp", "handshake+auth", "ping"))
+	for _, m := range report.Members {
+		buffer.WriteString(fmt.Sprintf("%-30s %6d %6d | %6.1f %6.1f %6.1f | %6.1f %6.1f %6.1f | %6.1f %6.1f %6.1f\n", m.Host, m.Connected,
+			m.Failed, m.TCP.P50, m.TCP.P95, m.TCP.Max, m.Handshake.P50, m.Handshake.P95, m.Handshake.Max, m.Ping.P50, m.Ping.P95, m.Ping.Max))
+		for _, e := range m.Errors {
+			buffer.WriteString(fmt.Sprintf("    error: %v\n", e))
+		}
+	}
+	hosts := []string{}
+	total := 0
+	for host, n := range report.Selected {
+		hosts = append(hosts, host)
+		total += n
+	}
+	sort.Strings(hosts)
+	buffer.WriteString(fmt.Sprintf("\nServers selected with read preference %v, %d failed\n", report.ReadPreference, report.SelectErrors))
+	for _, host := range hosts {
+		buffer.WriteString(fmt.Sprintf("%-30s %6d %5.1f%%\n", host, report.Selected[host], 100*float64(report.Selected[host])/float64(total)))
+	}
+	if report.WriteConcern != "" {
+		buffer.WriteString(fmt.Sprintf("\nWrites with w: %v, p50 %.1f, p95 %.1f, p99 %.1f, max %.1f ms, %d failed\n", report.WriteConcern,
+			report.Writes.P50, report.Writes.P95, report.Writes.P99, report.Writes.Max, report.WriteErrors))
+	}
+	return buffer.String()
+}
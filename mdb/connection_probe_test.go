@@ -0,0 +1,51 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetConnectionHost(t *testing.T) {
+	for id, host := range map[string]string{"localhost:27017[-12]": "localhost:27017", "h1:27018": "h1:27018"} {
+		if h := getConnectionHost(id); h != host {
+			t.Fatal(id, h)
+		}
+	}
+}
+
+func TestGetWriteConcern(t *testing.T) {
+	for w, valid := range map[string]bool{"majority": true, "0": true, "2": true, "dcs": true, "-1": false, "1.5": false, "": false} {
+		if _, err := getWriteConcern(w); (err == nil) != valid {
+			t.Fatal(w, err)
+		}
+	}
+}
+
+func TestMemberProbeAddConnection(t *testing.T) {
+	m := MemberProbe{Host: "h1:27017", Errors: []string{}}
+	for i := 1; i <= 10; i++ {
+		m.addConnection(time.Millisecond, time.Duration(i)*time.Millisecond, 500*time.Microsecond, nil)
+	}
+	m.addConnection(0, 0, 0, errors.New("auth failed"))
+	m.addConnection(0, 0, 0, errors.New("auth failed"))
+	m.Handshake = m.handshake.GetMilliPercentiles()
+	if m.Connected != 10 || m.Failed != 2 || len(m.Errors) != 1 || m.Handshake.Max != 10 || m.Handshake.P50 < 4 || m.Handshake.P50 > 6 {
+		t.Fatal(m)
+	}
+}
+
+func TestGetConnectionProbeSummary(t *testing.T) {
+	report := ConnectionProbeReport{Connections: 10, ReadPreference: "secondaryPreferred", WriteConcern: "majority",
+		Members:  []MemberProbe{{Host: "h1:27017", Connected: 9, Failed: 1, Errors: []string{"connection refused"}}},
+		Selected: map[string]int{"h1:27017": 25, "h2:27017": 75}}
+	str := GetConnectionProbeSummary(report)
+	t.Log(str)
+	if strings.Contains(str, "75.0%") == false || strings.Contains(str, "error: connection refused") == false ||
+		strings.Contains(str, "Writes with w: majority") == false {
+		t.Fatal(str)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var connAcceptedRegex = regexp.MustCompile(`^(\S+) .*\[\w+\] connection accepted from (\S+):\d+ #\d+ \((\d+) connections? now open\)`)
+var connEndedRegex = regexp.MustCompile(`^(\S+) .*\[\w+\] end connection (\S+):\d+ \((\d+) connections? now open\)`)
+
+// ConnectionsInfo summarizes connections opened and closed from NETWORK log lines
+type ConnectionsInfo struct {
+	Accepted        int
+	Ended           int
+	PeakConnections int
+	PeakTime        string
+	PerMinute       []ConnectionsPerMinute
+	TopIPs          []IPConnections
+	filenames       []string
+	verbose         bool
+}
+
+// ConnectionsPerMinute holds connections opened and closed in a minute
+type ConnectionsPerMinute struct {
+	Minute string
+	Opened int
+	Closed int
+}
+
+// IPConnections holds number of connections accepted from a source IP
+type IPConnections struct {
+	IP    string
+	Count int
+}
+
+// NewConnectionsInfo returns ConnectionsInfo of log files, directories, or glob patterns
+func NewConnectionsInfo(filenames []string) *ConnectionsInfo {
+	return &ConnectionsInfo{filenames: filenames}
+}
+
+// SetVerbose sets verbose level
+func (ci *ConnectionsInfo) SetVerbose(verbose bool) {
+	ci.verbose = verbose
+}
+
+// Parse scans connection accepted and end connection lines
+func (ci *ConnectionsInfo) Parse() error {
+	minutes := map[string]*ConnectionsPerMinute{}
+	ips := map[string]int{}
+	err := readLogLines(ci.filenames, func(str string) {
+		if strings.Contains(str, "connection") == false {
+			return
+		}
+		var result []string
+		opened := false
+		if result = connAcceptedRegex.FindStringSubmatch(str); len(result) > 0 {
+			opened = true
+		} else if result = connEndedRegex.FindStringSubmatch(str); len(result) == 0 {
+			return
+		}
+		minute := result[1]
+		if len(minute) > 16 {
+			minute = minute[:16]
+		}
+		if minutes[minute] == nil {
+			minutes[minute] = &ConnectionsPerMinute{Minute: minute}
+		}
+		if opened == true {
+			ci.Accepted++
+			minutes[minute].Opened++
+			ips[result[2]]++
+		} else {
+			ci.Ended++
+			minutes[minute].Closed++
+		}
+		if n, _ := strconv.Atoi(result[3]); n > ci.PeakConnections {
+			ci.PeakConnections = n
+			ci.PeakTime = result[1]
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	ci.PerMinute = []ConnectionsPerMinute{}
+	for _, m := range minutes {
+		ci.PerMinute = append(ci.PerMinute, *m)
+	}
+	sort.Slice(ci.PerMinute, func(i, j int) bool {
+		return ci.PerMinute[i].Minute < ci.PerMinute[j].Minute
+	})
+	ci.TopIPs = []IPConnections{}
+	for ip, count := range ips {
+		ci.TopIPs = append(ci.TopIPs, IPConnections{IP: ip, Count: count})
+	}
+	sort.Slice(ci.TopIPs, func(i, j int) bool {
+		if ci.TopIPs[i].Count == ci.TopIPs[j].Count {
+			return ci.TopIPs[i].IP < ci.TopIPs[j].IP
+		}
+		return ci.TopIPs[i].Count > ci.TopIPs[j].Count
+	})
+	if len(ci.TopIPs) > 10 {
+		ci.TopIPs = ci.TopIPs[:10]
+	}
+	return nil
+}
+
+// GetSummary returns connections summary, per minute details are listed in verbose mode
+func (ci *ConnectionsInfo) GetSummary() string {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("Connections accepted: %d, ended: %d\n", ci.Accepted, ci.Ended))
+	if ci.PeakConnections > 0 {
+		buffer.WriteString(fmt.Sprintf("Peak concurrent connections: %d at %s\n", ci.PeakConnections, ci.PeakTime))
+	}
+	busiest := ConnectionsPerMinute{}
+	for _, m := range ci.PerMinute {
+		if m.Opened+m.Closed > busiest.Opened+busiest.Closed {
+			busiest = m
+		}
+	}
+	if busiest.Minute != "" {
+		buffer.WriteString(fmt.Sprintf("Busiest minute: %s, opened: %d, closed: %d\n", busiest.Minute, busiest.Opened, busiest.Closed))
+	}
+	if len(ci.TopIPs) > 0 {
+		buffer.WriteString(fmt.Sprintf("\nTop %d source IPs:\n", len(ci.TopIPs)))
+		for _, ip := range ci.TopIPs {
+			buffer.WriteString(fmt.Sprintf("%-40s %8d\n", ip.IP, ip.Count))
+		}
+	}
+	if ci.verbose == true && len(ci.PerMinute) > 0 {
+		buffer.WriteString(fmt.Sprintf("\n%-20s %8s %8s\n", "minute", "opened", "closed"))
+		for _, m := range ci.PerMinute {
+			buffer.WriteString(fmt.Sprintf("%-20s %8d %8d\n", m.Minute, m.Opened, m.Closed))
+		}
+	}
+	return buffer.String()
+}
@@ -0,0 +1,28 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConnectionsInfo(t *testing.T) {
+	ci := NewConnectionsInfo([]string{"testdata/mongod.log"})
+	ci.SetVerbose(true)
+	if err := ci.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if ci.Accepted != 5 || ci.Ended != 2 || ci.PeakConnections != 5 {
+		t.Fatal(ci.Accepted, ci.Ended, ci.PeakConnections)
+	}
+	if len(ci.TopIPs) == 0 || ci.TopIPs[0].IP != "10.0.0.7" || ci.TopIPs[0].Count != 3 {
+		t.Fatal(ci.TopIPs)
+	}
+	if len(ci.PerMinute) != 2 || ci.PerMinute[0].Opened != 5 || ci.PerMinute[1].Closed != 2 {
+		t.Fatal(ci.PerMinute)
+	}
+	if strings.Index(ci.GetSummary(), "Peak concurrent connections: 5") < 0 {
+		t.Fatal(ci.GetSummary())
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is times of a cron expression of minute, hour, day of month, month, and day of week fields, or
+// of @hourly, @daily, @weekly, and @every duration
+type CronSchedule struct {
+	days     []bool
+	every    time.Duration
+	hours    []bool
+	minutes  []bool
+	months   []bool
+	weekdays []bool
+	anyDay   bool // day of month is *
+	anyWeek  bool // day of week is *
+}
+
+// ParseCronSchedule returns a CronSchedule of an expression, e.g. */30 * * * *, 0 2 * * 1-5, or @every 6h
+func ParseCronSchedule(expr string) (*CronSchedule, error) {
+	var err error
+	expr = strings.TrimSpace(expr)
+	switch expr {
+	case "@hourly":
+		expr = "0 * * * *"
+	case "@daily":
+		expr = "0 0 * * *"
+	case "@weekly":
+		expr = "0 0 * * 0"
+	}
+	if strings.HasPrefix(expr, "@every ") {
+		cs := &CronSchedule{}
+		if cs.every, err = time.ParseDuration(strings.TrimSpace(expr[len("@every "):])); err != nil {
+			return nil, err
+		} else if cs.every < time.Minute {
+			return nil, errors.New("@every must be at least a minute")
+		}
+		return cs, nil
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, errors.New("expected 5 fields of minute, hour, day of month, month, and day of week, but got " + expr)
+	}
+	cs := &CronSchedule{anyDay: fields[2] == "*", anyWeek: fields[4] == "*"}
+	for i, f := range []struct {
+		values *[]bool
+		min    int
+		max    int
+	}{{&cs.minutes, 0, 59}, {&cs.hours, 0, 23}, {&cs.days, 1, 31}, {&cs.months, 1, 12}, {&cs.weekdays, 0, 6}} {
+		if *f.values, err = parseCronField(fields[i], f.min, f.max); err != nil {
+			return nil, err
+		}
+	}
+	return cs, nil
+}
+
+// parseCronField returns values of a field of *, */n, a, a-b, a-b/n, or a list of them
+func parseCronField(field string, min int, max int) ([]bool, error) {
+	var err error
+	values := make([]bool, max+1)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if pos := strings.Index(part, "/"); pos > 0 {
+			if step, err = strconv.Atoi(part[pos+1:]); err != nil || step < 1 {
+				return nil, errors.New("invalid step of " + field)
+			}
+			part = part[:pos]
+		}
+		lo, hi := min, max
+		if part != "*" {
+			toks := strings.SplitN(part, "-", 2)
+			if lo, err = strconv.Atoi(toks[0]); err != nil {
+				return nil, errors.New("invalid value of " + field)
+			}
+			hi = lo
+			if len(toks) == 2 {
+				if hi, err = strconv.Atoi(toks[1]); err != nil {
+					return nil, errors.New("invalid range of " + field)
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return nil, errors.New("out of range " + field)
+		}
+		for v := lo; v <= hi; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+// Next returns the first time of the schedule after t, zero if none in a year
+func (cs *CronSchedule) Next(t time.Time) time.Time {
+	if cs.every > 0 {
+		return t.Add(cs.every)
+	}
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	for end := next.AddDate(1, 0, 0); next.Before(end); next = next.Add(time.Minute) {
+		if cs.months[int(next.Month())] == false || cs.matchDay(next) == false {
+			next = time.Date(next.Year(), next.Month(), next.Day(), 23, 59, 0, 0, next.Location())
+		} else if cs.hours[next.Hour()] == false {
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour(), 59, 0, 0, next.Location())
+		} else if cs.minutes[next.Minute()] == true {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// matchDay returns true if a day matches, either of day of month and day of week if both are restricted
+func (cs *CronSchedule) matchDay(t time.Time) bool {
+	day, weekday := cs.days[t.Day()], cs.weekdays[int(t.Weekday())]
+	if cs.anyDay == false && cs.anyWeek == false {
+		return day || weekday
+	}
+	return day && weekday
+}
@@ -0,0 +1,35 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	now := time.Date(2019, 10, 1, 10, 7, 30, 0, time.UTC) // a Tuesday
+	for expr, expected := range map[string]string{
+		"*/30 * * * *":   "2019-10-01T10:30:00Z",
+		"0 2 * * *":      "2019-10-02T02:00:00Z",
+		"15 9 * * 1":     "2019-10-07T09:15:00Z",
+		"0 0 1,15 * *":   "2019-10-15T00:00:00Z",
+		"0 0 13 * 5":     "2019-10-04T00:00:00Z", // either the 13th or a Friday
+		"@hourly":        "2019-10-01T11:00:00Z",
+		"@every 90m":     "2019-10-01T11:37:30Z",
+		"0 8-10/2 * * *": "2019-10-02T08:00:00Z",
+	} {
+		cs, err := ParseCronSchedule(expr)
+		if err != nil {
+			t.Fatal(expr, err)
+		}
+		if next := cs.Next(now).Format(time.RFC3339); next != expected {
+			t.Fatal(expr, next)
+		}
+	}
+	for _, expr := range []string{"* * *", "60 * * * *", "*/0 * * * *", "@every 10s", "5-1 * * * *"} {
+		if _, err := ParseCronSchedule(expr); err == nil {
+			t.Fatal("expected an error of", expr)
+		}
+	}
+}
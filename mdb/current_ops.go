@@ -0,0 +1,300 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// CurrentOpSampler samples in-flight operations from $currentOp at an interval for a duration, aggregates them by
+// shapes, and kills long running operations of a namespace if enabled
+type CurrentOpSampler struct {
+	client    *mongo.Client
+	duration  time.Duration
+	interval  time.Duration
+	killNS    string // namespace or database of operations to kill, none if empty
+	threshold time.Duration
+	verbose   bool
+}
+
+// CurrentOp is an in-flight operation
+type CurrentOp struct {
+	OpID        interface{} `json:"opid"` // a number, or shard:opid of a mongos
+	Namespace   string      `json:"ns"`
+	Command     string      `json:"command"`
+	Shape       string      `json:"shape"`
+	AppName     string      `json:"appName"`
+	Client      string      `json:"client"`
+	SecsRunning float64     `json:"secsRunning"`
+}
+
+// CurrentOpShape is in-flight operations of the same shape
+type CurrentOpShape struct {
+	Namespace string  `json:"ns"`
+	Command   string  `json:"command"`
+	Shape     string  `json:"shape"`
+	Count     int     `json:"count"`    // number of distinct operations
+	InFlight  float64 `json:"inFlight"` // average number of operations per sample
+	MaxSecs   float64 `json:"maxSecs"`
+}
+
+// CurrentOpReport is shapes of operations sampled, operations running longer than the threshold, and operations
+// killed
+type CurrentOpReport struct {
+	Samples     int              `json:"samples"`
+	Shapes      []CurrentOpShape `json:"shapes"`
+	LongRunning []CurrentOp      `json:"longRunning"`
+	Killed      []CurrentOp      `json:"killed"`
+	ops         map[string]CurrentOp
+	occurrences map[string]int // number of times operations of shapes sampled
+}
+
+// NewCurrentOpSampler returns a CurrentOpSampler sampling every 5 seconds for a minute and reporting operations
+// running longer than a minute
+func NewCurrentOpSampler(client *mongo.Client) *CurrentOpSampler {
+	return &CurrentOpSampler{client: client, duration: time.Minute, interval: 5 * time.Second, threshold: time.Minute}
+}
+
+// SetDuration sets how long to sample
+func (cs *CurrentOpSampler) SetDuration(duration time.Duration) {
+	cs.duration = duration
+}
+
+// SetInterval sets time between samples
+func (cs *CurrentOpSampler) SetInterval(interval time.Duration) {
+	cs.interval = interval
+}
+
+// SetKillNamespace sets to kill operations of a namespace, or of a database, running longer than the threshold
+func (cs *CurrentOpSampler) SetKillNamespace(namespace string) {
+	cs.killNS = namespace
+}
+
+// SetThreshold sets running time of operations to report as long running and to kill
+func (cs *CurrentOpSampler) SetThreshold(threshold time.Duration) {
+	cs.threshold = threshold
+}
+
+// SetVerbose sets verbose level
+func (cs *CurrentOpSampler) SetVerbose(verbose bool) {
+	cs.verbose = verbose
+}
+
+// Sample samples operations until the duration ends
+func (cs *CurrentOpSampler) Sample() (CurrentOpReport, error) {
+	return cs.SampleContext(context.Background())
+}
+
+// SampleContext samples operations until the duration ends or ctx is done
+func (cs *CurrentOpSampler) SampleContext(ctx context.Context) (CurrentOpReport, error) {
+	var err error
+	report := newCurrentOpReport()
+	if dbName := strings.SplitN(cs.killNS, ".", 2)[0]; contains([]string{"admin", "config", "local"}, dbName) {
+		return report.getReport(cs.threshold), errors.New("operations of admin, config, and local aren't killed")
+	}
+	deadline := time.Now().Add(cs.duration)
+	ticker := time.NewTicker(cs.interval)
+	defer ticker.Stop()
+	for {
+		var ops []CurrentOp
+		if ops, err = cs.getCurrentOps(ctx); err != nil {
+			return report.getReport(cs.threshold), err
+		}
+		report.add(ops)
+		for _, op := range ops {
+			if shouldKillOp(op, cs.killNS, cs.threshold) == false || report.isKilled(op) == true {
+				continue
+			}
+			if err = cs.killOp(ctx, op); err != nil {
+				return report.getReport(cs.threshold), err
+			}
+			report.Killed = append(report.Killed, op)
+			if cs.verbose == true {
+				fmt.Println("killed", op.OpID, op.Namespace, op.Shape)
+			}
+		}
+		if time.Now().Add(cs.interval).After(deadline) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return report.getReport(cs.threshold), ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return report.getReport(cs.threshold), err
+}
+
+// getCurrentOps returns active operations of clients from $currentOp
+func (cs *CurrentOpSampler) getCurrentOps(ctx context.Context) ([]CurrentOp, error) {
+	var err error
+	var cur *mongo.Cursor
+	pipeline := mongo.Pipeline{{{Key: "$currentOp", Value: bson.D{{Key: "allUsers", Value: true}}}},
+		{{Key: "$match", Value: bson.D{{Key: "active", Value: true}}}}}
+	opCtx, cancel := context.WithTimeout(ctx, memberPingTimeout)
+	defer cancel()
+	if cur, err = cs.client.Database("admin").Aggregate(opCtx, pipeline); err != nil {
+		return nil, err
+	}
+	defer cur.Close(opCtx)
+	ops := []CurrentOp{}
+	for cur.Next(opCtx) {
+		var doc bson.D
+		if err = cur.Decode(&doc); err != nil {
+			return ops, err
+		}
+		if op, ok := getCurrentOp(doc); ok == true {
+			ops = append(ops, op)
+		}
+	}
+	return ops, cur.Err()
+}
+
+// getCurrentOp returns an operation of a client from a document of $currentOp, false for internal threads. Operations
+// of shards through a mongos report the client in client_s.
+func getCurrentOp(doc bson.D) (CurrentOp, bool) {
+	m := doc.Map()
+	op := CurrentOp{OpID: m["opid"], Namespace: toString(m["ns"]), AppName: toString(m["appName"]), Client: toString(m["client"]),
+		SecsRunning: float64(toInt(m["microsecs_running"])) / 1000000}
+	if op.Client == "" {
+		op.Client = toString(m["client_s"])
+	}
+	if op.Namespace == "" || op.OpID == nil || isInternalOp(toString(m["desc"]), op.Client) == true {
+		return op, false
+	}
+	if stats, ok := getOpStatsFromProfile(doc); ok == true {
+		op.Command, op.Shape = stats.command, stats.filter
+	} else if cmd, ok := m["command"].(bson.D); ok == true && len(cmd) > 0 {
+		op.Command = cmd[0].Key
+	} else {
+		op.Command = toString(m["op"])
+	}
+	return op, true
+}
+
+// isInternalOp returns true for an internal thread, e.g. replication or TTL monitor, whose desc isn't of a client
+// connection, i.e. conn123
+func isInternalOp(desc string, client string) bool {
+	if desc != "" {
+		return strings.HasPrefix(desc, "conn") == false
+	}
+	return client == ""
+}
+
+// shouldKillOp returns true if an operation of a namespace, or of a database, runs longer than the threshold
+func shouldKillOp(op CurrentOp, namespace string, threshold time.Duration) bool {
+	if namespace == "" || op.SecsRunning < threshold.Seconds() {
+		return false
+	}
+	return op.Namespace == namespace || strings.HasPrefix(op.Namespace, namespace+".")
+}
+
+// killOp kills an operation
+func (cs *CurrentOpSampler) killOp(ctx context.Context, op CurrentOp) error {
+	cmd := bson.D{{Key: "killOp", Value: 1}, {Key: "op", Value: op.OpID}}
+	return cs.client.Database("admin").RunCommand(ctx, cmd).Err()
+}
+
+// newCurrentOpReport returns an empty CurrentOpReport
+func newCurrentOpReport() *CurrentOpReport {
+	return &CurrentOpReport{Shapes: []CurrentOpShape{}, LongRunning: []CurrentOp{}, Killed: []CurrentOp{},
+		ops: map[string]CurrentOp{}, occurrences: map[string]int{}}
+}
+
+// add adds operations of a sample, the longest running time of an operation is kept
+func (r *CurrentOpReport) add(ops []CurrentOp) {
+	r.Samples++
+	for _, op := range ops {
+		r.occurrences[getCurrentOpShapeKey(op)]++
+		key := fmt.Sprintf("%v", op.OpID)
+		if prev, ok := r.ops[key]; ok == false || op.SecsRunning > prev.SecsRunning {
+			r.ops[key] = op
+		}
+	}
+}
+
+// getReport returns shapes of operations sampled, the most in flight first, and operations running longer than
+// the threshold, the longest running first
+func (r *CurrentOpReport) getReport(threshold time.Duration) CurrentOpReport {
+	shapes := map[string]*CurrentOpShape{}
+	report := CurrentOpReport{Samples: r.Samples, Shapes: []CurrentOpShape{}, LongRunning: []CurrentOp{}, Killed: r.Killed}
+	for _, op := range r.ops {
+		key := getCurrentOpShapeKey(op)
+		shape, ok := shapes[key]
+		if ok == false {
+			shape = &CurrentOpShape{Namespace: op.Namespace, Command: op.Command, Shape: op.Shape}
+			shapes[key] = shape
+		}
+		shape.Count++
+		if op.SecsRunning > shape.MaxSecs {
+			shape.MaxSecs = op.SecsRunning
+		}
+		if op.SecsRunning >= threshold.Seconds() {
+			report.LongRunning = append(report.LongRunning, op)
+		}
+	}
+	for key, shape := range shapes {
+		shape.InFlight = float64(r.occurrences[key]) / float64(r.Samples)
+		report.Shapes = append(report.Shapes, *shape)
+	}
+	sort.Slice(report.Shapes, func(i, j int) bool {
+		if report.Shapes[i].InFlight != report.Shapes[j].InFlight {
+			return report.Shapes[i].InFlight > report.Shapes[j].InFlight
+		}
+		return report.Shapes[i].MaxSecs > report.Shapes[j].MaxSecs
+	})
+	sort.Slice(report.LongRunning, func(i, j int) bool { return report.LongRunning[i].SecsRunning > report.LongRunning[j].SecsRunning })
+	return report
+}
+
+// isKilled returns true if an operation was killed, it may still run until it checks for interrupts
+func (r *CurrentOpReport) isKilled(op CurrentOp) bool {
+	for _, killed := range r.Killed {
+		if fmt.Sprintf("%v", killed.OpID) == fmt.Sprintf("%v", op.OpID) {
+			return true
+		}
+	}
+	return false
+}
+
+// getCurrentOpShapeKey returns a key of a shape of an operation
+func getCurrentOpShapeKey(op CurrentOp) string {
+	return op.Namespace + " " + op.Command + " " + op.Shape
+}
+
+// GetCurrentOpSummary returns shapes of in-flight operations, long running operations, and operations killed
+func GetCurrentOpSummary(report CurrentOpReport) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("%d sample(s) of $currentOp\n", report.Samples))
+	if len(report.Shapes) == 0 {
+		buffer.WriteString("No in-flight operations found\n")
+		return buffer.String()
+	}
+	buffer.WriteString(fmt.Sprintf("%-30s %-14s %6s %9s %9s  %s\n", "namespace", "command", "ops", "in-flight", "max secs", "shape"))
+	for _, s := range report.Shapes {
+		buffer.WriteString(fmt.Sprintf("%-30s %-14s %6d %9.1f %9.1f  %s\n", s.Namespace, s.Command, s.Count, s.InFlight, s.MaxSecs, s.Shape))
+	}
+	if len(report.LongRunning) > 0 {
+		buffer.WriteString("\nLong running operations\n")
+		for _, op := range report.LongRunning {
+			buffer.WriteString(fmt.Sprintf("opid %v %v %v %v %.1f secs, client %v %v\n", op.OpID, op.Namespace, op.Command, op.Shape,
+				op.SecsRunning, op.Client, op.AppName))
+		}
+	}
+	if len(report.Killed) > 0 {
+		buffer.WriteString("\nKilled operations\n")
+		for _, op := range report.Killed {
+			buffer.WriteString(fmt.Sprintf("opid %v %v %v %v %.1f secs\n", op.OpID, op.Namespace, op.Command, op.Shape, op.SecsRunning))
+		}
+	}
+	return buffer.String()
+}
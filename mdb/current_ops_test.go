@@ -0,0 +1,79 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGetCurrentOp(t *testing.T) {
+	doc := bson.D{{Key: "opid", Value: int32(123)}, {Key: "active", Value: true}, {Key: "client", Value: "127.0.0.1:50000"},
+		{Key: "appName", Value: "keyhole"}, {Key: "microsecs_running", Value: int64(90500000)}, {Key: "op", Value: "query"},
+		{Key: "ns", Value: "keyhole.cars"}, {Key: "command", Value: bson.D{{Key: "find", Value: "cars"},
+			{Key: "filter", Value: bson.D{{Key: "color", Value: "Red"}, {Key: "year", Value: bson.D{{Key: "$gt", Value: 2000}}}}}}}}
+	op, ok := getCurrentOp(doc)
+	if ok == false || op.Command != "find" || op.Shape != "{color: 1, year: {$gt: 1}}" || op.SecsRunning != 90.5 || op.AppName != "keyhole" {
+		t.Fatal(op)
+	}
+	doc = bson.D{{Key: "shard", Value: "shard0"}, {Key: "opid", Value: "shard0:456"}, {Key: "desc", Value: "conn42"},
+		{Key: "client_s", Value: "127.0.0.1:50001"}, {Key: "active", Value: true}, {Key: "microsecs_running", Value: int64(61000000)},
+		{Key: "op", Value: "getmore"}, {Key: "ns", Value: "keyhole.cars"},
+		{Key: "command", Value: bson.D{{Key: "getMore", Value: int64(1)}, {Key: "collection", Value: "cars"}}}}
+	if op, ok = getCurrentOp(doc); ok == false || op.Command != "getMore" || op.OpID != "shard0:456" ||
+		op.Client != "127.0.0.1:50001" || shouldKillOp(op, "keyhole", time.Minute) == false {
+		t.Fatal(op)
+	}
+	doc = bson.D{{Key: "shard", Value: "shard0"}, {Key: "opid", Value: "shard0:789"}, {Key: "desc", Value: "TTLMonitor"},
+		{Key: "active", Value: true}, {Key: "op", Value: "none"}, {Key: "ns", Value: "config.system.sessions"}}
+	if op, ok = getCurrentOp(doc); ok == true {
+		t.Fatal("expected an internal thread", op)
+	}
+	doc = bson.D{{Key: "opid", Value: int32(790)}, {Key: "active", Value: true}, {Key: "op", Value: "none"}, {Key: "ns", Value: "local.oplog.rs"}}
+	if op, ok = getCurrentOp(doc); ok == true {
+		t.Fatal("expected no client", op)
+	}
+}
+
+func TestShouldKillOp(t *testing.T) {
+	op := CurrentOp{Namespace: "keyhole.cars", SecsRunning: 120}
+	for _, c := range []struct {
+		namespace string
+		threshold time.Duration
+		expected  bool
+	}{{"", time.Minute, false}, {"keyhole.cars", time.Minute, true}, {"keyhole", time.Minute, true},
+		{"key", time.Minute, false}, {"keyhole.cars", 5 * time.Minute, false}, {"keyhole.dealers", time.Minute, false}} {
+		if shouldKillOp(op, c.namespace, c.threshold) != c.expected {
+			t.Fatal(c)
+		}
+	}
+}
+
+func TestCurrentOpReport(t *testing.T) {
+	r := newCurrentOpReport()
+	find := CurrentOp{OpID: int32(1), Namespace: "keyhole.cars", Command: "find", Shape: "{color: 1}", SecsRunning: 30}
+	update := CurrentOp{OpID: int32(2), Namespace: "keyhole.cars", Command: "update", Shape: "{_id: 1}", SecsRunning: 1}
+	r.add([]CurrentOp{find, update})
+	find.SecsRunning = 90
+	r.add([]CurrentOp{find, {OpID: int32(3), Namespace: "keyhole.cars", Command: "find", Shape: "{color: 1}", SecsRunning: 2}})
+	r.Killed = append(r.Killed, find)
+	if r.isKilled(find) == false || r.isKilled(update) == true {
+		t.Fatal(r.Killed)
+	}
+	report := r.getReport(time.Minute)
+	if report.Samples != 2 || len(report.Shapes) != 2 || report.Shapes[0].Command != "find" || report.Shapes[0].Count != 2 ||
+		report.Shapes[0].InFlight != 1.5 || report.Shapes[0].MaxSecs != 90 || report.Shapes[1].InFlight != 0.5 {
+		t.Fatal(report.Shapes)
+	}
+	if len(report.LongRunning) != 1 || report.LongRunning[0].SecsRunning != 90 {
+		t.Fatal(report.LongRunning)
+	}
+	str := GetCurrentOpSummary(report)
+	t.Log(str)
+	if strings.Contains(str, "Long running operations") == false || strings.Contains(str, "Killed operations") == false {
+		t.Fatal(str)
+	}
+}
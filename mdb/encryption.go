@@ -0,0 +1,167 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// EncryptionKeyEnv is env of a base64 or hex encoded AES key of 16, 24, or 32 bytes
+const EncryptionKeyEnv = "KEYHOLE_ENCRYPTION_KEY"
+
+// EncryptionKeyCommandEnv is env of a command printing an encoded key, e.g. a KMS CLI decrypting a data key
+const EncryptionKeyCommandEnv = "KEYHOLE_ENCRYPTION_KEY_COMMAND"
+
+// encryptedMagic leads contents of encrypted files, followed by a nonce and AES-GCM sealed data
+var encryptedMagic = []byte("KEYHOLE-AES-GCM1")
+
+// GetEncryptionKey returns a key of KEYHOLE_ENCRYPTION_KEY, or of output of KEYHOLE_ENCRYPTION_KEY_COMMAND
+func GetEncryptionKey() ([]byte, error) {
+	var err error
+	value := os.Getenv(EncryptionKeyEnv)
+	if value == "" {
+		command := os.Getenv(EncryptionKeyCommandEnv)
+		if command == "" {
+			return nil, errors.New(EncryptionKeyEnv + " or " + EncryptionKeyCommandEnv + " is required")
+		}
+		var output []byte
+		if output, err = exec.Command("sh", "-c", command).Output(); err != nil {
+			return nil, errors.New(EncryptionKeyCommandEnv + ": " + err.Error())
+		}
+		value = string(output)
+	}
+	return ParseEncryptionKey(value)
+}
+
+// ParseEncryptionKey returns a key of a hex or base64 encoded value of 16, 24, or 32 bytes
+func ParseEncryptionKey(value string) ([]byte, error) {
+	var err error
+	var key []byte
+	value = strings.TrimSpace(value)
+	if key, err = hex.DecodeString(value); err != nil {
+		if key, err = base64.StdEncoding.DecodeString(value); err != nil {
+			return nil, errors.New("encryption key is neither hex nor base64 encoded")
+		}
+	}
+	if len(key) != 16 && len(key) != 24 && len(key) != 32 {
+		return nil, errors.New("encryption key must be 16, 24, or 32 bytes")
+	}
+	return key, err
+}
+
+// IsEncrypted returns true if data are encrypted by EncryptData
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, encryptedMagic)
+}
+
+// EncryptData seals data with AES-GCM of a random nonce
+func EncryptData(key []byte, data []byte) ([]byte, error) {
+	gcm, err := getGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	sealed := append(append([]byte{}, encryptedMagic...), nonce...)
+	return gcm.Seal(sealed, nonce, data, encryptedMagic), nil
+}
+
+// DecryptData opens data encrypted by EncryptData
+func DecryptData(key []byte, data []byte) ([]byte, error) {
+	if IsEncrypted(data) == false {
+		return nil, errors.New("data are not encrypted")
+	}
+	gcm, err := getGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	data = data[len(encryptedMagic):]
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("encrypted data are truncated")
+	}
+	if data, err = gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], encryptedMagic); err != nil {
+		return nil, errors.New("decryption failed, wrong key or tampered data")
+	}
+	return data, err
+}
+
+// getGCM returns an AES-GCM cipher of a key
+func getGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// decryptArtifact returns data as is if not encrypted, or decrypted with a key, with the key of env if nil
+func decryptArtifact(data []byte, key []byte) ([]byte, error) {
+	var err error
+	if IsEncrypted(data) == false {
+		return data, err
+	}
+	if key == nil {
+		if key, err = GetEncryptionKey(); err != nil {
+			return nil, errors.New("file is encrypted, " + err.Error())
+		}
+	}
+	return DecryptData(key, data)
+}
+
+// readArtifact reads a file written by writeArtifact, decrypted with a key, with the key of env if nil, and
+// gunzipped if gzipped
+func readArtifact(filename string, key []byte) ([]byte, error) {
+	var err error
+	var data []byte
+	if data, err = ioutil.ReadFile(filename); err != nil {
+		return nil, err
+	}
+	if data, err = decryptArtifact(data, key); err != nil {
+		return nil, err
+	}
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		var reader *gzip.Reader
+		if reader, err = gzip.NewReader(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return ioutil.ReadAll(reader)
+	}
+	return data, err
+}
+
+// writeArtifact writes data to a file, gzipped if gzipped is true, and encrypted if key is not nil
+func writeArtifact(filename string, data []byte, gzipped bool, key []byte) error {
+	var err error
+	if gzipped == true {
+		var buffer bytes.Buffer
+		zw := gzip.NewWriter(&buffer)
+		if _, err = zw.Write(data); err != nil {
+			return err
+		}
+		if err = zw.Close(); err != nil {
+			return err
+		}
+		data = buffer.Bytes()
+	}
+	if key != nil {
+		if data, err = EncryptData(key, data); err != nil {
+			return err
+		}
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
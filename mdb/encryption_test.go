@@ -0,0 +1,92 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testEncryptionKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+func TestEncryptData(t *testing.T) {
+	key, err := ParseEncryptionKey(testEncryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := []byte("slow op with a sensitive literal")
+	var data []byte
+	if data, err = EncryptData(key, plain); err != nil {
+		t.Fatal(err)
+	}
+	if IsEncrypted(data) == false || bytes.Contains(data, plain) {
+		t.Fatal("expected encrypted data")
+	}
+	if decrypted, err := DecryptData(key, data); err != nil || bytes.Equal(decrypted, plain) == false {
+		t.Fatal(err, string(decrypted))
+	}
+	data[len(data)-1] ^= 0xff
+	if _, err = DecryptData(key, data); err == nil {
+		t.Fatal("expected an error of tampered data")
+	}
+	other := make([]byte, 32)
+	if _, err = DecryptData(other, data); err == nil {
+		t.Fatal("expected an error of a wrong key")
+	}
+}
+
+func TestParseEncryptionKey(t *testing.T) {
+	if key, err := ParseEncryptionKey("AAECAwQFBgcICQoLDA0ODw=="); err != nil || len(key) != 16 {
+		t.Fatal(err, key)
+	}
+	for _, value := range []string{"", "0001", "not a key"} {
+		if _, err := ParseEncryptionKey(value); err == nil {
+			t.Fatal("expected an error of", value)
+		}
+	}
+}
+
+func TestReadArtifact(t *testing.T) {
+	key, _ := ParseEncryptionKey(testEncryptionKey)
+	filename := filepath.Join(os.TempDir(), "keyhole-artifact-test.json.gz")
+	defer os.Remove(filename)
+	if err := writeArtifact(filename, []byte(`{"ok": 1}`), true, key); err != nil {
+		t.Fatal(err)
+	}
+	os.Unsetenv(EncryptionKeyEnv)
+	os.Unsetenv(EncryptionKeyCommandEnv)
+	if _, err := readArtifact(filename, nil); err == nil {
+		t.Fatal("expected an error of a missing key")
+	}
+	os.Setenv(EncryptionKeyEnv, testEncryptionKey)
+	defer os.Unsetenv(EncryptionKeyEnv)
+	if data, err := readArtifact(filename, nil); err != nil || string(data) != `{"ok": 1}` {
+		t.Fatal(err, string(data))
+	}
+}
+
+func TestLogInfoEncryption(t *testing.T) {
+	key, _ := ParseEncryptionKey(testEncryptionKey)
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	li.SetEncryptionKey(key)
+	if _, err := li.Analyze(); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(li.OutputFilename)
+	if data, _ := ioutil.ReadFile(li.OutputFilename); IsEncrypted(data) == false {
+		t.Fatal("expected an encrypted .enc file")
+	}
+	enc := NewLogInfo(li.OutputFilename, "")
+	enc.SetSilent(true)
+	enc.SetEncryptionKey(key)
+	if _, err := enc.Analyze(); err != nil {
+		t.Fatal(err)
+	}
+	if len(enc.OpsPatterns) != len(li.OpsPatterns) {
+		t.Fatal(len(enc.OpsPatterns), len(li.OpsPatterns))
+	}
+}
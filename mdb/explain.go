@@ -4,22 +4,31 @@ package mdb
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/simagix/gox"
+	"github.com/simagix/keyhole/sim/util"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // Explain stores explain object info
 type Explain struct {
-	verbose bool
+	compareHints  bool          // to run executionStats of queries hinted with each index and a collection scan
+	createIndexes bool          // to write index suggestions as createIndex statements
+	encryptionKey []byte        // to encrypt JSON files of results with if not nil
+	report        string        // json or html to write all results into a file
+	sink          OutputSink    // to write files of results also to
+	timeout       time.Duration // timeout of each cardinality and explain operation
+	verbose       bool
 }
 
 // NewExplain returns Explain struct
@@ -32,8 +41,28 @@ func (e *Explain) SetVerbose(verbose bool) {
 	e.verbose = verbose
 }
 
+// SetEncryptionKey sets an AES key to encrypt JSON files of results with, nil for not encrypted
+func (e *Explain) SetEncryptionKey(key []byte) {
+	e.encryptionKey = key
+}
+
+// SetOutputSink sets a sink files of results are also written to
+func (e *Explain) SetOutputSink(sink OutputSink) {
+	e.sink = sink
+}
+
+// SetTimeout sets timeout of each cardinality and explain operation, 0 for no timeout
+func (e *Explain) SetTimeout(timeout time.Duration) {
+	e.timeout = timeout
+}
+
 // ExecuteAllPlans calls queryPlanner and cardinality
 func (e *Explain) ExecuteAllPlans(client *mongo.Client, filename string) error {
+	return e.ExecuteAllPlansContext(context.Background(), client, filename)
+}
+
+// ExecuteAllPlansContext calls queryPlanner and cardinality, stops when ctx is done
+func (e *Explain) ExecuteAllPlansContext(ctx context.Context, client *mongo.Client, filename string) error {
 	var err error
 	var file *os.File
 	var reader *bufio.Reader
@@ -41,7 +70,7 @@ func (e *Explain) ExecuteAllPlans(client *mongo.Client, filename string) error {
 	if file, err = os.Open(filename); err != nil {
 		return err
 	}
-	if reader, err = gox.NewReader(file); err != nil {
+	if reader, err = util.NewReader(file); err != nil {
 		return err
 	}
 	qe := NewQueryExplainer(client)
@@ -50,7 +79,12 @@ func (e *Explain) ExecuteAllPlans(client *mongo.Client, filename string) error {
 	card.SetVerbose(e.verbose)
 	stdout := ""
 	counter := 0
+	results := []ExplainResult{}
+	specs := []IndexSpec{}
 	for {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
 		buffer, _, rerr := reader.ReadLine()
 		if rerr != nil {
 			break
@@ -60,70 +94,295 @@ func (e *Explain) ExecuteAllPlans(client *mongo.Client, filename string) error {
 		if err = qe.ReadQueryShape(buffer); err != nil {
 			continue
 		}
-		var summary CardinalitySummary
-		keys := GetKeys(qe.ExplainCmd.Filter)
-		keys = append(keys, GetKeys(qe.ExplainCmd.Sort)...)
-		pos := strings.Index(qe.NameSpace, ".")
-		db := qe.NameSpace[:pos]
-		collection := qe.NameSpace[pos+1:]
-		if summary, err = card.GetCardinalityArray(db, collection, keys); err != nil {
+		var document map[string]interface{}
+		if document, stdout, err = e.explainQuery(ctx, qe, card); err != nil {
 			return err
 		}
-		var explainSummary ExplainSummary
-		if explainSummary, err = qe.Explain(); err != nil {
-			fmt.Println(err.Error())
-		}
-		strs := []string{}
-		strs = append(strs, qe.GetSummary(explainSummary))
-		strs = append(strs, "=> All Applicable Indexes Scores")
-		strs = append(strs, "=========================================")
-		scores := qe.GetIndexesScores(keys)
-		strs = append(strs, gox.Stringify(scores, "", "  "))
-		strs = append(strs, card.GetSummary(summary)+"\n")
-		document := make(map[string]interface{})
-		document["ns"] = qe.NameSpace
-		document["cardinality"] = summary
-		document["explain"] = explainSummary
-		document["scores"] = scores
-		if len(summary.List) > 0 {
-			recommendedIndex := GetIndexSuggestion(qe.ExplainCmd, summary.List)
-			document["recommendedIndex"] = recommendedIndex
-			strs = append(strs, "Index Suggestion:", gox.Stringify(recommendedIndex))
-		}
-		strs = append(strs, "")
-		stdout = strings.Join(strs, "\n")
-		document["stdout"] = stdout
 		counter++
-		if counter == 1 {
-			fmt.Println(stdout)
+		if spec, ok := getSuggestedIndexSpec(qe.NameSpace, document); ok == true {
+			specs = append(specs, spec)
+		}
+		if e.report != "" {
+			results = append(results, getExplainResult(qe, document))
+			continue
 		}
-		ofile := fmt.Sprintf("%v-explain-%03d.json.gz", filepath.Base(filename), counter)
-		if err = gox.OutputGzipped([]byte(gox.Stringify(document)), ofile); err != nil {
+		if err = e.writeExplainDocument(filename, counter, document, stdout); err != nil {
 			return err
 		}
-		fmt.Println("* Explain JSON written to", ofile)
 	}
-	return err
+	if err = e.outputExplainReport(filename, results); err != nil {
+		return err
+	}
+	return e.outputCreateIndexes(ctx, client, filename, specs)
 }
 
-// PrintExplainResults prints explain results
-func (e *Explain) PrintExplainResults(filename string) error {
-	var err error
-	var data []byte
-	var file *os.File
-	var reader *bufio.Reader
+// writeExplainDocument writes explain results of a query into a gzipped JSON file, stdout of the first query
+// is printed
+func (e *Explain) writeExplainDocument(filename string, counter int, document map[string]interface{}, stdout string) error {
+	if counter == 1 {
+		fmt.Println(stdout)
+	}
+	ofile := fmt.Sprintf("%v-explain-%03d.json.gz", filepath.Base(filename), counter)
+	if err := writeArtifact(ofile, []byte(gox.Stringify(document)), true, e.encryptionKey); err != nil {
+		return err
+	}
+	fmt.Println("* Explain JSON written to", ofile)
+	return e.writeToSink(ofile)
+}
 
-	if file, err = os.Open(filename); err != nil {
+// outputExplainReport writes explain results of all queries into a report and prints the summary
+func (e *Explain) outputExplainReport(filename string, results []ExplainResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+	ofile, err := e.writeExplainReport(filename, results)
+	if err != nil {
 		return err
 	}
-	if reader, err = gox.NewReader(file); err != nil {
+	fmt.Println(GetExplainReportSummary(results))
+	fmt.Println("* Explain report written to", ofile)
+	return e.writeToSink(ofile)
+}
+
+// writeToSink writes a file of results to the output sink if set
+func (e *Explain) writeToSink(filename string) error {
+	if e.sink == nil {
+		return nil
+	}
+	if err := WriteFileToSink(e.sink, filename); err != nil {
 		return err
 	}
-	if data, err = ioutil.ReadAll(reader); err != nil {
+	fmt.Println("*", filename, "written to", e.sink.String())
+	return nil
+}
+
+// ExplainOpsPatterns explains the top n slowest ops patterns of a loginfo with their slowest example statements,
+// returns a report of patterns stats, explain plans, cardinalities, and index suggestions
+func (e *Explain) ExplainOpsPatterns(client *mongo.Client, li *LogInfo, n int) (string, error) {
+	return e.ExplainOpsPatternsContext(context.Background(), client, li, n)
+}
+
+// ExplainOpsPatternsContext explains the top n slowest ops patterns of a loginfo, stops when ctx is done
+func (e *Explain) ExplainOpsPatternsContext(ctx context.Context, client *mongo.Client, li *LogInfo, n int) (string, error) {
+	qe := NewQueryExplainer(client)
+	qe.SetVerbose(e.verbose)
+	card := NewCardinality(client)
+	card.SetVerbose(e.verbose)
+	strs := []string{}
+	counter := 0
+	for _, doc := range li.OpsPatterns {
+		if counter >= n {
+			break
+		}
+		explainCmd, ok := getExplainCommand(doc)
+		if ok == false {
+			continue
+		}
+		counter++
+		qe.ExplainCmd = explainCmd
+		qe.NameSpace = doc.Namespace
+		strs = append(strs, fmt.Sprintf("=> %d. %s %s, count: %d, avg: %s, max: %s", counter, doc.Command, doc.Namespace, doc.Count,
+			strings.TrimSpace(MilliToTimeString(float64(doc.TotalMilli)/float64(doc.Count))),
+			strings.TrimSpace(MilliToTimeString(float64(doc.MaxMilli)))))
+		strs = append(strs, "=========================================")
+		strs = append(strs, "query pattern: "+doc.Filter)
+		if len(doc.Examples) > 0 {
+			strs = append(strs, "example: "+doc.Examples[0].Statement)
+		}
+		if err := ctx.Err(); err != nil {
+			return strings.Join(strs, "\n"), err
+		}
+		_, stdout, err := e.explainQuery(ctx, qe, card)
+		if err != nil {
+			strs = append(strs, "explain failed: "+err.Error()+"\n")
+			continue
+		}
+		strs = append(strs, stdout)
+	}
+	if suggestions := li.SuggestIndexes(); len(suggestions) > 0 {
+		strs = append(strs, "=> Index Suggestions of Ops Patterns")
+		strs = append(strs, "=========================================")
+		strs = append(strs, GetIndexSuggestionsSummary(suggestions))
+	}
+	return strings.Join(strs, "\n"), nil
+}
+
+// GetOpsPatternCommand returns a find command of an ops pattern, from its slowest example statement if retained,
+// false if the ops pattern has no filter
+func GetOpsPatternCommand(doc OpPerformanceDoc) (ExplainCommand, bool) {
+	return getExplainCommand(doc)
+}
+
+// getExplainCommand returns a find command to explain an ops pattern, from its slowest example statement if
+// retained, or from the query pattern
+func getExplainCommand(doc OpPerformanceDoc) (ExplainCommand, bool) {
+	var err error
+	var filter, stages bson.D
+	pos := strings.Index(doc.Namespace, ".")
+	if pos < 0 || strings.HasSuffix(doc.Namespace, ".$cmd") || hasFilter(doc.Command) == false {
+		return ExplainCommand{}, false
+	}
+	explainCmd := ExplainCommand{Collection: doc.Namespace[pos+1:]}
+	if len(doc.Examples) > 0 {
+		statement := doc.Examples[0].Statement
+		if idx := strings.Index(statement, "{"); idx > 0 {
+			var cmd bson.D
+			if cmd, err = parseShellDoc(statement[idx:]); err == nil {
+				filter, stages = getExampleFilter(cmd)
+			}
+		}
+	}
+	if filter == nil {
+		if filter, stages, err = parseQueryPattern(doc.Filter); err != nil {
+			return explainCmd, false
+		}
+	}
+	explainCmd.Filter, _ = toBSONValue(filter).(bson.D)
+	for _, stage := range stages {
+		value, _ := stage.Value.(bson.D)
+		if stage.Key == "sort" {
+			explainCmd.Sort, _ = toBSONValue(value).(bson.D)
+		} else if stage.Key == "group" && len(value) > 0 && value[0].Key == "_id" {
+			if groupFields := getGroupFields(value[0].Value); len(groupFields) > 0 {
+				explainCmd.Group = groupFields[0]
+			}
+		}
+	}
+	return explainCmd, true
+}
+
+// getExampleFilter returns a filter and sort and group stages of a command of logs
+func getExampleFilter(cmd bson.D) (bson.D, bson.D) {
+	var filter, stages bson.D
+	m := cmd.Map()
+	for _, key := range []string{"filter", "query", "q"} {
+		if doc, ok := m[key].(bson.D); ok == true {
+			filter = doc
+			break
+		}
+	}
+	if doc, ok := m["sort"].(bson.D); ok == true {
+		stages = append(stages, bson.E{Key: "sort", Value: doc})
+	}
+	if pipeline, ok := m["pipeline"].(primitive.A); ok == true {
+		for _, stage := range toDocs(pipeline) {
+			if len(stage) == 0 {
+				continue
+			}
+			value, _ := stage[0].Value.(bson.D)
+			switch stage[0].Key {
+			case "$match":
+				if filter == nil {
+					filter = value
+				}
+			case "$group":
+				stages = append(stages, bson.E{Key: "group", Value: value})
+			case "$sort":
+				stages = append(stages, bson.E{Key: "sort", Value: value})
+			}
+		}
+	}
+	if filter == nil && len(stages) > 0 {
+		filter = bson.D{}
+	}
+	return filter, stages
+}
+
+// explainQuery returns explain plans, cardinalities, and index scores and suggestion of a query
+func (e *Explain) explainQuery(ctx context.Context, qe *QueryExplainer, card *Cardinality) (map[string]interface{}, string, error) {
+	var err error
+	var summary CardinalitySummary
+	keys := GetKeys(qe.ExplainCmd.Filter)
+	keys = append(keys, GetKeys(qe.ExplainCmd.Sort)...)
+	pos := strings.Index(qe.NameSpace, ".")
+	db := qe.NameSpace[:pos]
+	collection := qe.NameSpace[pos+1:]
+	opCtx, cancel := withTimeout(ctx, e.timeout)
+	summary, err = card.GetCardinalityArrayContext(opCtx, db, collection, keys)
+	cancel()
+	if err != nil {
+		return nil, "", err
+	}
+	var explainSummary ExplainSummary
+	opCtx, cancel = withTimeout(ctx, e.timeout)
+	explainSummary, err = qe.ExplainContext(opCtx)
+	cancel()
+	if err != nil {
+		fmt.Println(err.Error())
+	}
+	strs := []string{}
+	strs = append(strs, qe.GetSummary(explainSummary))
+	strs = append(strs, "=> All Applicable Indexes Scores")
+	strs = append(strs, "=========================================")
+	scores := qe.GetIndexesScores(keys)
+	strs = append(strs, gox.Stringify(scores, "", "  "))
+	var hints []HintComparison
+	if e.compareHints == true { // runs the query under every index and a collection scan
+		opCtx, cancel = withTimeout(ctx, e.timeout)
+		var herr error
+		hints, herr = qe.CompareHintsContext(opCtx)
+		cancel()
+		if herr == nil {
+			strs = append(strs, "=> Hinted Indexes Comparison")
+			strs = append(strs, "=========================================")
+			strs = append(strs, GetHintComparisonsSummary(hints))
+		}
+	}
+	strs = append(strs, card.GetSummary(summary)+"\n")
+	document := make(map[string]interface{})
+	document["ns"] = qe.NameSpace
+	document["cardinality"] = summary
+	document["explain"] = explainSummary
+	document["hints"] = hints
+	document["scores"] = scores
+	if len(summary.List) > 0 {
+		recommendedIndex := GetIndexSuggestion(qe.ExplainCmd, summary.List)
+		document["recommendedIndex"] = recommendedIndex
+		strs = append(strs, "Index Suggestion:", gox.Stringify(recommendedIndex))
+	}
+	strs = append(strs, "")
+	stdout := strings.Join(strs, "\n")
+	document["stdout"] = stdout
+	return document, stdout, nil
+}
+
+// ExplainQuery explains a query of a JSON document of {ns, filter, sort, projection, hint} or of a log line, and
+// returns its explain result
+func (e *Explain) ExplainQuery(ctx context.Context, client *mongo.Client, query []byte) (ExplainResult, error) {
+	qe := NewQueryExplainer(client)
+	qe.SetVerbose(e.verbose)
+	card := NewCardinality(client)
+	card.SetVerbose(e.verbose)
+	if err := qe.ReadQueryShape(query); err != nil {
+		return ExplainResult{}, err
+	} else if strings.Contains(qe.NameSpace, ".") == false {
+		return ExplainResult{}, errors.New("namespace not found of query " + string(query))
+	}
+	document, _, err := e.explainQuery(ctx, qe, card)
+	if err != nil {
+		return ExplainResult{}, err
+	}
+	return getExplainResult(qe, document), nil
+}
+
+// PrintExplainResults prints explain results
+func (e *Explain) PrintExplainResults(filename string) error {
+	var err error
+	var data []byte
+
+	if data, err = readArtifact(filename, e.encryptionKey); err != nil {
 		return err
 	}
 	doc := bson.M{}
 	json.Unmarshal(data, &doc)
+	if results, ok := doc["results"].([]interface{}); ok == true { // a report of SetReport
+		for _, result := range results {
+			if m, ok := result.(map[string]interface{}); ok == true {
+				fmt.Println(m["stdout"])
+			}
+		}
+		return err
+	}
 	if doc["stdout"] == nil {
 		usage := "Usage: keyhole --explain <mongod.log> <uri> | <result.json.gz>"
 		return errors.New(usage)
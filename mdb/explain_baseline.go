@@ -0,0 +1,169 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/simagix/gox"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PlanBaseline is the winning plan of a query shape saved as a baseline
+type PlanBaseline struct {
+	Namespace string `json:"ns"`
+	Query     string `json:"query"`   // query shape in relaxed extended JSON
+	Indexes   string `json:"indexes"` // names of indexes used, COLLSCAN if none
+	Stages    string `json:"stages"`  // e.g. FETCH > IXSCAN {color: 1}
+}
+
+// PlanBaselines is a baseline file of winning plans
+type PlanBaselines struct {
+	Created   time.Time      `json:"created"`
+	Baselines []PlanBaseline `json:"baselines"`
+}
+
+// PlanDiff is a winning plan changed from its baseline
+type PlanDiff struct {
+	Namespace string `json:"ns"`
+	Query     string `json:"query"`
+	Expected  string `json:"expected"`
+	Actual    string `json:"actual"`
+}
+
+// SaveBaseline explains query shapes of a file and saves their winning plans into a baseline file
+func (e *Explain) SaveBaseline(client *mongo.Client, filename string, baseline string) error {
+	var err error
+	var shapes []QueryShape
+	if shapes, err = ReadQueryShapes(filename); err != nil {
+		return err
+	}
+	doc := PlanBaselines{Created: time.Now(), Baselines: []PlanBaseline{}}
+	for _, shape := range shapes {
+		var plan PlanBaseline
+		if plan, err = e.getPlanBaseline(client, shape); err != nil {
+			return fmt.Errorf("%v: %v", shape.Namespace, err)
+		}
+		if e.verbose == true {
+			fmt.Println(plan.Namespace, plan.Query, "=>", plan.Indexes, plan.Stages)
+		}
+		doc.Baselines = append(doc.Baselines, plan)
+	}
+	return ioutil.WriteFile(baseline, []byte(gox.Stringify(doc, "", "  ")), 0644)
+}
+
+// VerifyBaseline re-explains query shapes of a baseline file and returns winning plans changed
+func (e *Explain) VerifyBaseline(client *mongo.Client, baseline string) ([]PlanDiff, error) {
+	var err error
+	var data []byte
+	var doc PlanBaselines
+	diffs := []PlanDiff{}
+	if data, err = ioutil.ReadFile(baseline); err != nil {
+		return diffs, err
+	}
+	if err = json.Unmarshal(data, &doc); err != nil {
+		return diffs, err
+	}
+	for _, expected := range doc.Baselines {
+		var shapes []QueryShape
+		if shapes, err = parseQueryShapes([]byte(expected.Query)); err != nil || len(shapes) != 1 {
+			return diffs, fmt.Errorf("invalid query shape %v: %v", expected.Query, err)
+		}
+		var actual PlanBaseline
+		if actual, err = e.getPlanBaseline(client, shapes[0]); err != nil {
+			return diffs, fmt.Errorf("%v: %v", expected.Namespace, err)
+		}
+		if diff, ok := comparePlanBaseline(expected, actual); ok == false {
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs, err
+}
+
+// getPlanBaseline returns the winning plan of a query shape from queryPlanner
+func (e *Explain) getPlanBaseline(client *mongo.Client, shape QueryShape) (PlanBaseline, error) {
+	var err error
+	var data []byte
+	plan := PlanBaseline{Namespace: shape.Namespace}
+	if data, err = bson.MarshalExtJSON(shape, false, false); err != nil {
+		return plan, err
+	}
+	plan.Query = string(data)
+	explainCmd := shape.getExplainCommand()
+	cmd := bson.D{{Key: "explain", Value: explainCmd}, {Key: "verbosity", Value: "queryPlanner"}}
+	var doc struct {
+		QueryPlanner struct {
+			WinningPlan bson.D `bson:"winningPlan"`
+		} `bson:"queryPlanner"`
+	}
+	ctx, cancel := withTimeout(context.Background(), e.timeout)
+	defer cancel()
+	idx := strings.Index(shape.Namespace, ".")
+	if err = client.Database(shape.Namespace[:idx]).RunCommand(ctx, cmd).Decode(&doc); err != nil {
+		return plan, err
+	}
+	plan.Stages = strings.Join(getPlanStages(doc.QueryPlanner.WinningPlan), " > ")
+	plan.Indexes = strings.Join(getPlanIndexNames(doc.QueryPlanner.WinningPlan), ", ")
+	if plan.Indexes == "" {
+		plan.Indexes = COLLSCAN
+	}
+	return plan, err
+}
+
+// getPlanIndexNames returns names of indexes used by a plan, of all shards of a mongos
+func getPlanIndexNames(plan bson.D) []string {
+	names := []string{}
+	m := plan.Map()
+	if name, ok := m["indexName"].(string); ok == true && contains(names, name) == false {
+		names = append(names, name)
+	}
+	inputs := []bson.D{}
+	for _, key := range []string{"inputStage", "winningPlan"} {
+		if input, ok := m[key].(bson.D); ok == true {
+			inputs = append(inputs, input)
+		}
+	}
+	for _, key := range []string{"inputStages", "shards"} {
+		if list, ok := m[key].(primitive.A); ok == true {
+			inputs = append(inputs, toDocs(list)...)
+		}
+	}
+	for _, input := range inputs {
+		for _, name := range getPlanIndexNames(input) {
+			if contains(names, name) == false {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// comparePlanBaseline returns a diff and false if indexes or stages of a winning plan changed
+func comparePlanBaseline(expected PlanBaseline, actual PlanBaseline) (PlanDiff, bool) {
+	if expected.Indexes == actual.Indexes && expected.Stages == actual.Stages {
+		return PlanDiff{}, true
+	}
+	return PlanDiff{Namespace: expected.Namespace, Query: expected.Query, Expected: expected.Indexes + ": " + expected.Stages,
+		Actual: actual.Indexes + ": " + actual.Stages}, false
+}
+
+// GetPlanDiffsSummary returns winning plans changed in a diff format
+func GetPlanDiffsSummary(diffs []PlanDiff) string {
+	var buffer bytes.Buffer
+	if len(diffs) == 0 {
+		return "All winning plans match the baseline"
+	}
+	buffer.WriteString(fmt.Sprintf("%d winning plan(s) changed\n", len(diffs)))
+	for _, diff := range diffs {
+		buffer.WriteString(fmt.Sprintf("%v %v\n- %v\n+ %v\n", diff.Namespace, diff.Query, diff.Expected, diff.Actual))
+	}
+	return buffer.String()
+}
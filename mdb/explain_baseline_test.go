@@ -0,0 +1,64 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGetPlanIndexNames(t *testing.T) {
+	ixscan := func(name string) bson.D {
+		return bson.D{{Key: "stage", Value: "IXSCAN"}, {Key: "indexName", Value: name}}
+	}
+	shard := func(name string, index string) bson.D {
+		return bson.D{{Key: "shardName", Value: name}, {Key: "winningPlan", Value: bson.D{{Key: "stage", Value: "FETCH"},
+			{Key: "inputStage", Value: ixscan(index)}}}}
+	}
+	plan := getPlanCacheEntry(t, bson.D{{Key: "cachedPlan", Value: bson.D{{Key: "stage", Value: "SHARD_MERGE"},
+		{Key: "shards", Value: bson.A{shard("shard01", "color_1"), shard("shard02", "color_1")}}}}}).CachedPlan
+	if names := getPlanIndexNames(plan); strings.Join(names, ",") != "color_1" {
+		t.Fatal(names)
+	}
+	if stages := strings.Join(getPlanStages(plan), " > "); stages != "SHARD_MERGE > FETCH > IXSCAN > FETCH > IXSCAN" {
+		t.Fatal(stages)
+	}
+	plan = getPlanCacheEntry(t, bson.D{{Key: "cachedPlan", Value: bson.D{{Key: "stage", Value: "OR"},
+		{Key: "inputStages", Value: bson.A{ixscan("a_1"), ixscan("b_1")}}}}}).CachedPlan
+	if names := getPlanIndexNames(plan); strings.Join(names, ",") != "a_1,b_1" {
+		t.Fatal(names)
+	}
+}
+
+func TestComparePlanBaseline(t *testing.T) {
+	expected := PlanBaseline{Namespace: "keyhole.cars", Query: `{"ns":"keyhole.cars"}`, Indexes: "color_1", Stages: "FETCH > IXSCAN {color: 1}"}
+	if _, ok := comparePlanBaseline(expected, expected); ok == false {
+		t.Fatal("expected same plans")
+	}
+	actual := PlanBaseline{Namespace: "keyhole.cars", Indexes: COLLSCAN, Stages: COLLSCAN}
+	diff, ok := comparePlanBaseline(expected, actual)
+	if ok == true || diff.Actual != "COLLSCAN: COLLSCAN" {
+		t.Fatal(diff)
+	}
+	str := GetPlanDiffsSummary([]PlanDiff{diff})
+	t.Log(str)
+	if strings.Contains(str, "- color_1: FETCH > IXSCAN {color: 1}") == false || strings.Contains(str, "+ COLLSCAN") == false {
+		t.Fatal(str)
+	}
+}
+
+func TestPlanBaselineQuery(t *testing.T) {
+	shapes, err := parseQueryShapes([]byte(`{"ns": "keyhole.cars", "filter": {"color": "Red"}, "sort": {"year": -1}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := bson.MarshalExtJSON(shapes[0], false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shapes, err = parseQueryShapes(data); err != nil || getShapeString(shapes[0].Sort) != "{year: -1}" {
+		t.Fatal(string(data), err)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// isCoveredPlan returns true if a winning plan is answered by index keys without fetching documents
+func isCoveredPlan(stats StageStats) bool {
+	if stats.TotalKeysExamined == 0 || stats.TotalDocsExamined > 0 {
+		return false
+	}
+	stages := []string{stats.Stage}
+	for _, stage := range stats.InputStages {
+		stages = append(stages, stage.Stage)
+	}
+	return hasStage("IXSCAN", stages) && hasStage("FETCH", stages) == false && hasStage("COLLSCAN", stages) == false
+}
+
+// getCoveringProjectionFields returns fields of an inclusion projection excluding _id, false if the projection
+// can't be covered by an index
+func getCoveringProjectionFields(projection bson.D) ([]string, bool) {
+	fields := []string{}
+	excludeID := false
+	for _, elem := range projection {
+		included := elem.Value == true || toInt(elem.Value) != 0
+		if elem.Key == "_id" {
+			excludeID = included == false
+			continue
+		}
+		if included == false || strings.HasPrefix(elem.Key, "$") {
+			return fields, false
+		}
+		if _, ok := elem.Value.(bson.D); ok == true { // e.g. $slice or $elemMatch
+			return fields, false
+		}
+		fields = append(fields, elem.Key)
+	}
+	return fields, excludeID && len(fields) > 0
+}
+
+// getCoveredQueryNote returns whether a query is covered, or fields an index needs to cover the query, empty if
+// the query doesn't have a projection to be covered
+func getCoveredQueryNote(explainCmd ExplainCommand, summary ExplainSummary) string {
+	if summary.IsCovered == true {
+		return "yes"
+	}
+	fields, ok := getCoveringProjectionFields(explainCmd.Projection)
+	if ok == false {
+		return ""
+	}
+	keys := append(GetKeys(explainCmd.Filter), GetKeys(explainCmd.Sort)...)
+	for _, field := range fields {
+		if contains(keys, field) == false {
+			keys = append(keys, field)
+		}
+	}
+	return fmt.Sprintf("no, an index of fields %v would cover it", strings.Join(keys, ", "))
+}
+
+// getCollationString returns locale and strength of a collation, simple if none
+func getCollationString(collation bson.D) string {
+	m := collation.Map()
+	if len(collation) == 0 || m["locale"] == nil || m["locale"] == "simple" {
+		return "simple"
+	}
+	if m["strength"] == nil {
+		return toString(m["locale"])
+	}
+	return fmt.Sprintf("%v/%v", m["locale"], toInt(m["strength"]))
+}
+
+// getCollationMismatch returns indexes used of collations different from the collation of the query, string
+// comparisons can't use bounds of these indexes
+func (qe *QueryExplainer) getCollationMismatch(ctx context.Context, indexNames []string) string {
+	pos := strings.Index(qe.NameSpace, ".")
+	if pos < 0 || len(indexNames) == 0 {
+		return ""
+	}
+	cur, err := qe.client.Database(qe.NameSpace[:pos]).Collection(qe.NameSpace[pos+1:]).Indexes().List(ctx)
+	if err != nil {
+		return ""
+	}
+	defer cur.Close(ctx)
+	query := getCollationString(qe.ExplainCmd.Collation)
+	strs := []string{}
+	for cur.Next(ctx) {
+		var index struct {
+			Name      string `bson:"name"`
+			Collation bson.D `bson:"collation"`
+		}
+		if cur.Decode(&index) != nil || contains(indexNames, index.Name) == false {
+			continue
+		}
+		if collation := getCollationString(index.Collation); collation != query {
+			strs = append(strs, fmt.Sprintf("index %v of collation %v, query of collation %v", index.Name, collation, query))
+		}
+	}
+	return strings.Join(strs, "; ")
+}
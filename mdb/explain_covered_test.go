@@ -0,0 +1,64 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestIsCoveredPlan(t *testing.T) {
+	stats := StageStats{Stage: "PROJECTION_COVERED", TotalKeysExamined: 10,
+		InputStages: []StageStats{{Stage: "IXSCAN"}}}
+	if isCoveredPlan(stats) == false {
+		t.Fatal("expected a covered plan")
+	}
+	stats = StageStats{Stage: "PROJECTION_SIMPLE", TotalKeysExamined: 10, TotalDocsExamined: 10,
+		InputStages: []StageStats{{Stage: "FETCH"}, {Stage: "IXSCAN"}}}
+	if isCoveredPlan(stats) == true {
+		t.Fatal("expected a plan fetching documents")
+	}
+}
+
+func TestGetCoveredQueryNote(t *testing.T) {
+	explainCmd := ExplainCommand{Filter: bson.D{{Key: "color", Value: "Red"}},
+		Projection: bson.D{{Key: "_id", Value: int32(0)}, {Key: "color", Value: int32(1)}, {Key: "year", Value: true}}}
+	if note := getCoveredQueryNote(explainCmd, ExplainSummary{}); note != "no, an index of fields color, year would cover it" {
+		t.Fatal(note)
+	}
+	if note := getCoveredQueryNote(explainCmd, ExplainSummary{IsCovered: true}); note != "yes" {
+		t.Fatal(note)
+	}
+	explainCmd.Projection = bson.D{{Key: "color", Value: int32(1)}} // _id isn't excluded
+	if note := getCoveredQueryNote(explainCmd, ExplainSummary{}); note != "" {
+		t.Fatal(note)
+	}
+	explainCmd.Projection = bson.D{{Key: "_id", Value: int32(0)}, {Key: "colors", Value: bson.D{{Key: "$slice", Value: int32(1)}}}}
+	if _, ok := getCoveringProjectionFields(explainCmd.Projection); ok == true {
+		t.Fatal("$slice can't be covered")
+	}
+}
+
+func TestGetCollationString(t *testing.T) {
+	if str := getCollationString(nil); str != "simple" {
+		t.Fatal(str)
+	}
+	collation := bson.D{{Key: "locale", Value: "fr"}, {Key: "strength", Value: int32(2)}}
+	if str := getCollationString(collation); str != "fr/2" {
+		t.Fatal(str)
+	}
+}
+
+func TestReadQueryShapeProjection(t *testing.T) {
+	qe := NewQueryExplainer(nil)
+	shape := `{"ns": "keyhole.cars", "filter": {"color": "Red"}, "projection": {"_id": 0, "color": 1}, "collation": {"locale": "fr"}}`
+	if err := qe.ReadQueryShape([]byte(shape)); err != nil {
+		t.Fatal(err)
+	}
+	if qe.NameSpace != "keyhole.cars" || len(qe.ExplainCmd.Projection) != 2 || getCollationString(qe.ExplainCmd.Collation) != "fr" ||
+		strings.Contains(getShapeString(qe.ExplainCmd.Filter), "color") == false {
+		t.Fatal(qe.ExplainCmd)
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/simagix/gox"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// HintComparison is executionStats of a query hinted with an index, or estimated of a proposed index
+type HintComparison struct {
+	Index               string `json:"index"`
+	Stage               string `json:"stage"` // of executionStages, e.g. FETCH, COLLSCAN
+	NReturned           int    `json:"nReturned"`
+	KeysExamined        int    `json:"totalKeysExamined"`
+	DocsExamined        int    `json:"totalDocsExamined"`
+	ExecutionTimeMillis int    `json:"executionTimeMillis"` // -1 if estimated
+	IsProposed          bool   `json:"proposed"`
+	Error               string `json:"error,omitempty"`
+}
+
+// SetCompareHints sets to compare executionStats of queries hinted with each index and a collection scan,
+// queries are run on the cluster as many times
+func (e *Explain) SetCompareHints(compareHints bool) {
+	e.compareHints = compareHints
+}
+
+// CompareHints explains a query hinted with each index of the collection and a collection scan, and
+// estimates a proposed index from cardinalities of fields of the query if it doesn't exist
+func (qe *QueryExplainer) CompareHints() ([]HintComparison, error) {
+	return qe.CompareHintsContext(context.Background())
+}
+
+// CompareHintsContext compares executionStats of a query hinted with indexes, stops when ctx is done
+func (qe *QueryExplainer) CompareHintsContext(ctx context.Context) ([]HintComparison, error) {
+	var err error
+	var cur *mongo.Cursor
+	pos := strings.Index(qe.NameSpace, ".")
+	if pos < 0 {
+		return nil, fmt.Errorf("invalid namespace %v", qe.NameSpace)
+	}
+	collection := qe.client.Database(qe.NameSpace[:pos]).Collection(qe.NameSpace[pos+1:])
+	if cur, err = collection.Indexes().List(ctx); err != nil {
+		return nil, err
+	}
+	hints := []bson.D{}
+	for cur.Next(ctx) {
+		var idx struct {
+			Key bson.D `bson:"key"`
+		}
+		if err = cur.Decode(&idx); err == nil && len(idx.Key) > 0 {
+			hints = append(hints, idx.Key)
+		}
+	}
+	cur.Close(ctx)
+	hints = append(hints, bson.D{{Key: "$natural", Value: 1}})
+	list := []HintComparison{}
+	for _, hint := range hints {
+		if err = ctx.Err(); err != nil {
+			return list, err
+		}
+		list = append(list, qe.explainHint(ctx, collection, hint))
+	}
+
+	card := NewCardinality(qe.client)
+	keys := append(GetKeys(qe.ExplainCmd.Filter), GetKeys(qe.ExplainCmd.Sort)...)
+	summary, e := card.GetCardinalityArrayContext(ctx, collection.Database().Name(), collection.Name(), keys)
+	if e != nil || len(summary.List) == 0 {
+		return list, err
+	}
+	var proposed bson.D
+	om := GetIndexSuggestion(qe.ExplainCmd, summary.List)
+	if e = bson.UnmarshalExtJSON([]byte(gox.Stringify(om)), false, &proposed); e != nil || len(proposed) == 0 {
+		return list, err
+	}
+	for _, hint := range hints {
+		if getShapeString(hint) == getShapeString(toBSONValue(proposed)) {
+			return list, err // the proposed index exists
+		}
+	}
+	list = append(list, getProposedIndexComparison(qe.ExplainCmd, proposed, summary))
+	return list, err
+}
+
+// explainHint returns executionStats of a query hinted with an index
+func (qe *QueryExplainer) explainHint(ctx context.Context, collection *mongo.Collection, hint bson.D) HintComparison {
+	comparison := HintComparison{Index: getShapeString(hint)}
+	find := bson.D{{Key: "find", Value: collection.Name()}, {Key: "filter", Value: qe.ExplainCmd.Filter}}
+	if len(qe.ExplainCmd.Filter) == 0 {
+		find[1].Value = bson.D{}
+	}
+	if len(qe.ExplainCmd.Sort) > 0 {
+		find = append(find, bson.E{Key: "sort", Value: qe.ExplainCmd.Sort})
+	}
+	if len(qe.ExplainCmd.Projection) > 0 {
+		find = append(find, bson.E{Key: "projection", Value: qe.ExplainCmd.Projection})
+	}
+	if len(qe.ExplainCmd.Collation) > 0 {
+		find = append(find, bson.E{Key: "collation", Value: qe.ExplainCmd.Collation})
+	}
+	find = append(find, bson.E{Key: "hint", Value: hint})
+	cmd := bson.D{{Key: "explain", Value: find}, {Key: "verbosity", Value: "executionStats"}}
+	var doc struct {
+		ExecutionStats struct {
+			NReturned           int `bson:"nReturned"`
+			ExecutionTimeMillis int `bson:"executionTimeMillis"`
+			TotalKeysExamined   int `bson:"totalKeysExamined"`
+			TotalDocsExamined   int `bson:"totalDocsExamined"`
+			ExecutionStages     struct {
+				Stage string `bson:"stage"`
+			} `bson:"executionStages"`
+		} `bson:"executionStats"`
+	}
+	if err := collection.Database().RunCommand(ctx, cmd).Decode(&doc); err != nil {
+		comparison.Error = err.Error() // e.g. a sort can't use a sparse or a partial index
+		return comparison
+	}
+	stats := doc.ExecutionStats
+	comparison.Stage = stats.ExecutionStages.Stage
+	comparison.NReturned = stats.NReturned
+	comparison.KeysExamined = stats.TotalKeysExamined
+	comparison.DocsExamined = stats.TotalDocsExamined
+	comparison.ExecutionTimeMillis = stats.ExecutionTimeMillis
+	return comparison
+}
+
+// getProposedIndexComparison estimates keys and documents examined of a proposed index, documents sampled are
+// divided by cardinalities of leading fields of the index with equality conditions of the query
+func getProposedIndexComparison(explainCmd ExplainCommand, proposed bson.D, summary CardinalitySummary) HintComparison {
+	equalityKeys := GetKeys(explainCmd.Filter, false)
+	counts := map[string]int64{}
+	for _, c := range summary.List {
+		counts[c.Field] = c.Count
+	}
+	examined := float64(summary.SampledCount)
+	for _, elem := range proposed {
+		if contains(equalityKeys, elem.Key) == false || counts[elem.Key] == 0 {
+			break
+		}
+		examined /= float64(counts[elem.Key])
+	}
+	n := int(examined + 0.5)
+	if n < 1 && summary.SampledCount > 0 {
+		n = 1
+	}
+	return HintComparison{Index: getShapeString(toBSONValue(proposed)), Stage: "estimated", KeysExamined: n, DocsExamined: n,
+		ExecutionTimeMillis: -1, IsProposed: true}
+}
+
+// GetHintComparisonsSummary returns executionStats of hinted indexes side by side, the index examining the fewest
+// keys and documents is marked with *
+func GetHintComparisonsSummary(list []HintComparison) string {
+	var buffer bytes.Buffer
+	if len(list) == 0 {
+		return "No indexes to compare"
+	}
+	sorted := make([]HintComparison, len(list))
+	copy(sorted, list)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if (sorted[i].Error == "") != (sorted[j].Error == "") {
+			return sorted[i].Error == ""
+		}
+		return sorted[i].KeysExamined+sorted[i].DocsExamined < sorted[j].KeysExamined+sorted[j].DocsExamined
+	})
+	buffer.WriteString(fmt.Sprintf("  %-40s %-10s %10s %12s %12s %10s\n", "index", "stage", "nReturned", "keysExamined", "docsExamined", "millis"))
+	for i, c := range sorted {
+		mark := "  "
+		if i == 0 && c.Error == "" {
+			mark = "* "
+		}
+		name := c.Index
+		if c.IsProposed {
+			name += " (proposed)"
+		}
+		if c.Error != "" {
+			buffer.WriteString(fmt.Sprintf("%s%-40s %s\n", mark, name, c.Error))
+			continue
+		}
+		millis := "-"
+		if c.ExecutionTimeMillis >= 0 {
+			millis = fmt.Sprintf("%d", c.ExecutionTimeMillis)
+		}
+		nReturned := "-"
+		if c.IsProposed == false {
+			nReturned = fmt.Sprintf("%d", c.NReturned)
+		}
+		buffer.WriteString(fmt.Sprintf("%s%-40s %-10s %10s %12d %12d %10s\n", mark, name, c.Stage, nReturned, c.KeysExamined,
+			c.DocsExamined, millis))
+	}
+	return buffer.String()
+}
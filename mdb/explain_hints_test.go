@@ -0,0 +1,42 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGetProposedIndexComparison(t *testing.T) {
+	explainCmd := ExplainCommand{Collection: "cars", Filter: bson.D{{Key: "color", Value: "Red"},
+		{Key: "year", Value: bson.D{{Key: "$gt", Value: 2010}}}}}
+	summary := CardinalitySummary{SampledCount: 1000, List: []CardinalityCount{{Field: "color", Count: 20}, {Field: "year", Count: 50}}}
+	proposed := bson.D{{Key: "color", Value: int32(1)}, {Key: "year", Value: int32(1)}}
+	c := getProposedIndexComparison(explainCmd, proposed, summary)
+	if c.IsProposed == false || c.KeysExamined != 50 || c.DocsExamined != 50 || c.ExecutionTimeMillis != -1 {
+		t.Fatal(c)
+	}
+	if c.Index != "{color: 1, year: 1}" {
+		t.Fatal(c.Index)
+	}
+}
+
+func TestGetHintComparisonsSummary(t *testing.T) {
+	list := []HintComparison{
+		{Index: "{color: 1}", Stage: "FETCH", NReturned: 10, KeysExamined: 50, DocsExamined: 50, ExecutionTimeMillis: 2},
+		{Index: "{$natural: 1}", Stage: "COLLSCAN", NReturned: 10, DocsExamined: 1000, ExecutionTimeMillis: 12},
+		{Index: "{color: 1, year: 1}", Stage: "FETCH", NReturned: 10, KeysExamined: 10, DocsExamined: 10, ExecutionTimeMillis: 0},
+		{Index: "{year: 1}", Error: "hint provided does not correspond to an existing index"},
+	}
+	str := GetHintComparisonsSummary(list)
+	t.Log(str)
+	lines := strings.Split(strings.TrimSpace(str), "\n")
+	if len(lines) != 5 || strings.HasPrefix(lines[1], "* {color: 1, year: 1}") == false || strings.Contains(lines[4], "{year: 1}") == false {
+		t.Fatal(str)
+	}
+	if list[0].Index != "{color: 1}" {
+		t.Fatal("expected list unchanged")
+	}
+}
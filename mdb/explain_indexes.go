@@ -0,0 +1,171 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/simagix/gox"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// IndexSpec is an index suggested by explain to create
+type IndexSpec struct {
+	Namespace  string         `json:"ns"`
+	Key        gox.OrderedMap `json:"key"`
+	Name       string         `json:"name"`
+	Background bool           `json:"background"`
+	keys       bson.D
+}
+
+// SetCreateIndexes sets to write index suggestions of all queries as createIndex statements and index specs
+func (e *Explain) SetCreateIndexes(createIndexes bool) {
+	e.createIndexes = createIndexes
+}
+
+// getSuggestedIndexSpec returns an index spec of the index suggestion of an explained query, false if none
+func getSuggestedIndexSpec(namespace string, document map[string]interface{}) (IndexSpec, bool) {
+	var keys bson.D
+	om, ok := document["recommendedIndex"].(gox.OrderedMap)
+	if ok == false {
+		return IndexSpec{}, false
+	}
+	if err := bson.UnmarshalExtJSON([]byte(gox.Stringify(om)), false, &keys); err != nil || len(keys) == 0 {
+		return IndexSpec{}, false
+	}
+	return IndexSpec{Namespace: namespace, Key: om, Name: getIndexName(keys), Background: true, keys: keys}, true
+}
+
+// dedupeIndexSpecs removes indexes that are prefixes of others or of existing indexes of the same namespace, and
+// sorts them by namespaces
+func dedupeIndexSpecs(specs []IndexSpec, existing map[string][]bson.D) []IndexSpec {
+	sorted := make([]IndexSpec, len(specs))
+	copy(sorted, specs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Namespace != sorted[j].Namespace {
+			return sorted[i].Namespace < sorted[j].Namespace
+		}
+		return len(sorted[i].keys) > len(sorted[j].keys)
+	})
+	results := []IndexSpec{}
+	for _, spec := range sorted {
+		covered := false
+		for _, keys := range existing[spec.Namespace] {
+			if isIndexKeyPrefix(spec.keys, keys) {
+				covered = true
+				break
+			}
+		}
+		for _, r := range results {
+			if covered == false && r.Namespace == spec.Namespace && isIndexKeyPrefix(spec.keys, r.keys) {
+				covered = true
+			}
+		}
+		if covered == false {
+			results = append(results, spec)
+		}
+	}
+	return results
+}
+
+// isIndexKeyPrefix returns true if keys of an index are a prefix of keys of another index
+func isIndexKeyPrefix(prefix bson.D, keys bson.D) bool {
+	if len(prefix) > len(keys) {
+		return false
+	}
+	value := func(v interface{}) string {
+		switch v.(type) {
+		case int32, int64, float64, int:
+			return fmt.Sprintf("%d", toInt(v))
+		}
+		return toString(v)
+	}
+	for i, elem := range prefix {
+		if elem.Key != keys[i].Key || value(elem.Value) != value(keys[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// getExistingIndexKeys returns keys of existing indexes of namespaces
+func getExistingIndexKeys(ctx context.Context, client *mongo.Client, specs []IndexSpec) (map[string][]bson.D, error) {
+	existing := map[string][]bson.D{}
+	for _, spec := range specs {
+		if _, ok := existing[spec.Namespace]; ok == true {
+			continue
+		}
+		existing[spec.Namespace] = []bson.D{}
+		idx := strings.Index(spec.Namespace, ".")
+		cur, err := client.Database(spec.Namespace[:idx]).Collection(spec.Namespace[idx+1:]).Indexes().List(ctx)
+		if err != nil {
+			return existing, err
+		}
+		for cur.Next(ctx) {
+			var index struct {
+				Key bson.D `bson:"key"`
+			}
+			if err = cur.Decode(&index); err == nil {
+				existing[spec.Namespace] = append(existing[spec.Namespace], index.Key)
+			}
+		}
+		cur.Close(ctx)
+	}
+	return existing, nil
+}
+
+// GetCreateIndexStatements returns createIndex statements of mongo shell grouped by namespaces
+func GetCreateIndexStatements(specs []IndexSpec) string {
+	var buffer bytes.Buffer
+	namespace := ""
+	for _, spec := range specs {
+		if spec.Namespace != namespace {
+			namespace = spec.Namespace
+			buffer.WriteString("// " + namespace + "\n")
+		}
+		idx := strings.Index(spec.Namespace, ".")
+		buffer.WriteString(fmt.Sprintf("db.getSiblingDB(%q).getCollection(%q).createIndex(%s, {name: %q, background: %v})\n",
+			spec.Namespace[:idx], spec.Namespace[idx+1:], getShapeString(spec.keys), spec.Name, spec.Background))
+	}
+	return buffer.String()
+}
+
+// outputCreateIndexes writes index suggestions, deduplicated against existing indexes, into files of createIndex
+// statements and of a JSON array of index specs
+func (e *Explain) outputCreateIndexes(ctx context.Context, client *mongo.Client, filename string, specs []IndexSpec) error {
+	if e.createIndexes == false {
+		return nil
+	}
+	existing, err := getExistingIndexKeys(ctx, client, specs)
+	if err != nil {
+		return err
+	}
+	specs = dedupeIndexSpecs(specs, existing)
+	if len(specs) == 0 {
+		fmt.Println("* No indexes to create")
+		return nil
+	}
+	statements := GetCreateIndexStatements(specs)
+	fmt.Println(statements)
+	ofile := fmt.Sprintf("%v-indexes.js", filepath.Base(filename))
+	if err = ioutil.WriteFile(ofile, []byte(statements), 0644); err != nil {
+		return err
+	}
+	fmt.Println("* createIndex statements written to", ofile)
+	if err = e.writeToSink(ofile); err != nil {
+		return err
+	}
+	ofile = fmt.Sprintf("%v-indexes.json", filepath.Base(filename))
+	if err = ioutil.WriteFile(ofile, []byte(gox.Stringify(specs, "", "  ")), 0644); err != nil {
+		return err
+	}
+	fmt.Println("* Index specs written to", ofile)
+	return e.writeToSink(ofile)
+}
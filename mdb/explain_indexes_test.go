@@ -0,0 +1,55 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/simagix/gox"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGetSuggestedIndexSpec(t *testing.T) {
+	document := map[string]interface{}{"recommendedIndex": *gox.NewOrderedMap(`{"color": 1, "year": 1}`)}
+	spec, ok := getSuggestedIndexSpec("keyhole.cars", document)
+	if ok == false || spec.Name != "color_1_year_1" || len(spec.keys) != 2 || spec.Background == false {
+		t.Fatal(spec)
+	}
+	if _, ok = getSuggestedIndexSpec("keyhole.cars", map[string]interface{}{}); ok == true {
+		t.Fatal("expected no index spec")
+	}
+}
+
+func TestDedupeIndexSpecs(t *testing.T) {
+	spec := func(ns string, keys string) IndexSpec {
+		s, _ := getSuggestedIndexSpec(ns, map[string]interface{}{"recommendedIndex": *gox.NewOrderedMap(keys)})
+		return s
+	}
+	specs := []IndexSpec{spec("keyhole.cars", `{"color": 1}`), spec("keyhole.cars", `{"color": 1, "year": 1}`),
+		spec("keyhole.cars", `{"brand": 1}`), spec("keyhole.dealers", `{"city": 1}`), spec("keyhole.cars", `{"color": 1, "year": 1}`)}
+	existing := map[string][]bson.D{"keyhole.cars": {{{Key: "brand", Value: float64(1)}, {Key: "model", Value: int32(1)}}}}
+	results := dedupeIndexSpecs(specs, existing)
+	if len(results) != 2 || results[0].Name != "color_1_year_1" || results[1].Namespace != "keyhole.dealers" {
+		t.Fatal(results)
+	}
+	str := GetCreateIndexStatements(results)
+	t.Log(str)
+	if strings.Contains(str, `db.getSiblingDB("keyhole").getCollection("cars").createIndex({color: 1, year: 1}, {name: "color_1_year_1", background: true})`) == false ||
+		strings.Contains(str, "// keyhole.dealers") == false {
+		t.Fatal(str)
+	}
+}
+
+func TestIsIndexKeyPrefix(t *testing.T) {
+	keys := bson.D{{Key: "a", Value: int32(1)}, {Key: "b", Value: int32(-1)}}
+	if isIndexKeyPrefix(bson.D{{Key: "a", Value: float64(1)}}, keys) == false {
+		t.Fatal("{a: 1} is a prefix")
+	}
+	if isIndexKeyPrefix(bson.D{{Key: "a", Value: int32(1)}, {Key: "b", Value: int32(1)}}, keys) == true {
+		t.Fatal("{a: 1, b: 1} isn't a prefix")
+	}
+	if isIndexKeyPrefix(bson.D{{Key: "a", Value: "text"}}, keys) == true {
+		t.Fatal("{a: 'text'} isn't a prefix")
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/simagix/gox"
+)
+
+// ExplainReport holds explain results of all queries of a file
+type ExplainReport struct {
+	Filename  string          `json:"filename"`
+	Generated time.Time       `json:"generated"`
+	Results   []ExplainResult `json:"results"` // sorted by potential improvement
+}
+
+// ExplainResult is the summary of explain results of a query
+type ExplainResult struct {
+	Namespace    string                 `json:"ns"`
+	Pattern      string                 `json:"pattern"`
+	WinningPlan  string                 `json:"winningPlan"`
+	KeysExamined int                    `json:"totalKeysExamined"`
+	DocsExamined int                    `json:"totalDocsExamined"`
+	BestIndex    string                 `json:"bestIndex"` // examining the fewest keys and documents when hinted
+	BestExamined int                    `json:"bestExamined"`
+	Improvement  float64                `json:"improvement"` // examined of the winning plan to examined of the best index
+	Suggestion   string                 `json:"suggestion"`
+	Stdout       string                 `json:"stdout"`
+	Details      map[string]interface{} `json:"details"` // explain document of the query
+}
+
+// SetReport sets to write explain results of all queries into a single report of json or html, instead of
+// a gzipped JSON file per query
+func (e *Explain) SetReport(report string) {
+	e.report = report
+}
+
+// getExplainResult summarizes explain results of a query from a document of explainQuery
+func getExplainResult(qe *QueryExplainer, document map[string]interface{}) ExplainResult {
+	result := ExplainResult{Namespace: qe.NameSpace, Pattern: getShapeString(qe.ExplainCmd.Filter), Details: document}
+	if len(qe.ExplainCmd.Filter) == 0 {
+		result.Pattern = "{}"
+	}
+	if len(qe.ExplainCmd.Sort) > 0 {
+		result.Pattern += ", sort: " + getShapeString(qe.ExplainCmd.Sort)
+	}
+	result.Stdout, _ = document["stdout"].(string)
+	if index, ok := document["recommendedIndex"].(gox.OrderedMap); ok == true {
+		result.Suggestion = gox.Stringify(index)
+	}
+	hints, _ := document["hints"].([]HintComparison)
+	summary, _ := document["explain"].(ExplainSummary)
+	if summary.ExecutionStats.Stage != "" {
+		result.WinningPlan = getWinningPlanString(summary.ExecutionStats)
+		result.KeysExamined = int(summary.ExecutionStats.TotalKeysExamined)
+		result.DocsExamined = int(summary.ExecutionStats.TotalDocsExamined)
+	} else { // no index selected, executionStats of the collection scan
+		for _, hint := range hints {
+			if hint.Index == "{$natural: 1}" && hint.Error == "" {
+				result.WinningPlan = COLLSCAN
+				result.KeysExamined, result.DocsExamined = hint.KeysExamined, hint.DocsExamined
+			}
+		}
+	}
+	result.BestExamined = -1
+	for _, hint := range hints {
+		if hint.Error != "" {
+			continue
+		}
+		if examined := hint.KeysExamined + hint.DocsExamined; result.BestExamined < 0 || examined < result.BestExamined {
+			result.BestIndex, result.BestExamined = hint.Index, examined
+		}
+	}
+	result.Improvement = getExplainImprovement(result.KeysExamined+result.DocsExamined, result.BestExamined)
+	return result
+}
+
+// getExplainImprovement returns ratio of keys and documents examined by the winning plan to by the best index
+func getExplainImprovement(examined int, best int) float64 {
+	if best < 0 || examined <= best {
+		return 1
+	}
+	if best == 0 {
+		best = 1
+	}
+	return float64(examined) / float64(best)
+}
+
+// getWinningPlanString returns stages of a winning plan, e.g. FETCH > IXSCAN {"a":1}
+func getWinningPlanString(stats StageStats) string {
+	strs := []string{stats.Stage}
+	for _, stage := range stats.InputStages {
+		str := stage.Stage
+		if stage.KeyPattern != nil {
+			str += " " + gox.Stringify(stage.KeyPattern)
+		}
+		strs = append(strs, str)
+	}
+	return strings.Join(strs, " > ")
+}
+
+// writeExplainReport writes explain results into a file of json.gz or html, sorted by potential improvement
+func (e *Explain) writeExplainReport(filename string, results []ExplainResult) (string, error) {
+	var err error
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Improvement > results[j].Improvement })
+	report := ExplainReport{Filename: filepath.Base(filename), Generated: time.Now(), Results: results}
+	ofile := fmt.Sprintf("%v-explain.json.gz", filepath.Base(filename))
+	if e.report == "html" {
+		ofile = fmt.Sprintf("%v-explain.html", filepath.Base(filename))
+		err = ioutil.WriteFile(ofile, []byte(getExplainReportHTML(report)), 0644)
+	} else {
+		err = writeArtifact(ofile, []byte(gox.Stringify(report)), true, e.encryptionKey)
+	}
+	return ofile, err
+}
+
+// GetExplainReportSummary returns a table of explain results
+func GetExplainReportSummary(results []ExplainResult) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("%-30s %-50s %-40s %10s %s\n", "namespace", "pattern", "winning plan", "improve", "suggestion"))
+	for _, r := range results {
+		buffer.WriteString(fmt.Sprintf("%-30s %-50s %-40s %9.1fx %s\n", r.Namespace, r.Pattern, r.WinningPlan, r.Improvement, r.Suggestion))
+	}
+	return buffer.String()
+}
+
+// getExplainReportHTML returns a self-contained HTML page of explain results
+func getExplainReportHTML(report ExplainReport) string {
+	var buffer bytes.Buffer
+	title := "Keyhole Explain Report - " + report.Filename
+	buffer.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	buffer.WriteString("<title>" + html.EscapeString(title) + "</title>\n")
+	buffer.WriteString(htmlStyle)
+	buffer.WriteString(htmlScript)
+	buffer.WriteString("</head>\n<body>\n")
+	buffer.WriteString("<h1>" + html.EscapeString(title) + "</h1>\n")
+	buffer.WriteString("<p>Generated at " + report.Generated.Format(time.RFC3339) + "</p>\n")
+	buffer.WriteString("<table>\n<thead><tr>")
+	for _, name := range []string{"Namespace", "Query Pattern", "Winning Plan", "keysExamined", "docsExamined", "Best Index",
+		"Best Examined", "Improvement", "Suggestion"} {
+		buffer.WriteString("<th onclick=\"sortTable(this)\">" + name + "</th>")
+	}
+	buffer.WriteString("</tr></thead>\n<tbody>\n")
+	for _, r := range report.Results {
+		class := ""
+		if r.WinningPlan == COLLSCAN {
+			class = " class=\"collscan\""
+		} else if r.Improvement > 1 {
+			class = " class=\"inefficient\""
+		}
+		buffer.WriteString(fmt.Sprintf("<tr%s><td>%s</td><td class=\"pattern\">%s<details><summary>explain</summary><pre>%s</pre></details></td><td class=\"pattern\">%s</td>",
+			class, html.EscapeString(r.Namespace), html.EscapeString(r.Pattern), html.EscapeString(r.Stdout), html.EscapeString(r.WinningPlan)))
+		buffer.WriteString(fmt.Sprintf("<td class=\"num\">%d</td><td class=\"num\">%d</td><td class=\"pattern\">%s</td><td class=\"num\">%d</td><td class=\"num\">%.1f</td><td class=\"pattern\">%s</td></tr>\n",
+			r.KeysExamined, r.DocsExamined, html.EscapeString(r.BestIndex), r.BestExamined, r.Improvement, html.EscapeString(r.Suggestion)))
+	}
+	buffer.WriteString("</tbody>\n</table>\n</body>\n</html>\n")
+	return buffer.String()
+}
@@ -0,0 +1,63 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/simagix/gox"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGetExplainResult(t *testing.T) {
+	qe := NewQueryExplainer(nil)
+	qe.NameSpace = "keyhole.cars"
+	qe.ExplainCmd = ExplainCommand{Collection: "cars", Filter: bson.D{{Key: "color", Value: "Red"}},
+		Sort: bson.D{{Key: "year", Value: int32(-1)}}}
+	summary := ExplainSummary{ExecutionStats: StageStats{Stage: "SORT", TotalKeysExamined: 100, TotalDocsExamined: 100,
+		InputStages: []StageStats{{Stage: "FETCH"}, {Stage: "IXSCAN", KeyPattern: gox.NewOrderedMap(`{"color":1}`)}}}}
+	hints := []HintComparison{
+		{Index: "{color: 1}", KeysExamined: 100, DocsExamined: 100},
+		{Index: "{$natural: 1}", DocsExamined: 1000},
+		{Index: "{color: 1, year: -1}", KeysExamined: 10, DocsExamined: 10, IsProposed: true},
+		{Index: "{year: 1}", Error: "bad hint"},
+	}
+	document := map[string]interface{}{"ns": qe.NameSpace, "explain": summary, "hints": hints, "stdout": "explain output"}
+	result := getExplainResult(qe, document)
+	if result.Pattern != `{color: "Red"}, sort: {year: -1}` || result.WinningPlan != `SORT > FETCH > IXSCAN {"color":1}` {
+		t.Fatal(result.Pattern, result.WinningPlan)
+	}
+	if result.BestIndex != "{color: 1, year: -1}" || result.BestExamined != 20 || result.Improvement != 10 {
+		t.Fatal(result.BestIndex, result.BestExamined, result.Improvement)
+	}
+
+	document["explain"] = ExplainSummary{} // no index selected
+	result = getExplainResult(qe, document)
+	if result.WinningPlan != COLLSCAN || result.DocsExamined != 1000 || result.Improvement != 50 {
+		t.Fatal(result.WinningPlan, result.DocsExamined, result.Improvement)
+	}
+}
+
+func TestGetExplainImprovement(t *testing.T) {
+	for _, test := range []struct {
+		examined, best int
+		improvement    float64
+	}{{100, 10, 10}, {10, 10, 1}, {5, 10, 1}, {100, -1, 1}, {100, 0, 100}} {
+		if n := getExplainImprovement(test.examined, test.best); n != test.improvement {
+			t.Fatal(test, n)
+		}
+	}
+}
+
+func TestGetExplainReportHTML(t *testing.T) {
+	report := ExplainReport{Filename: "mongod.log", Results: []ExplainResult{
+		{Namespace: "keyhole.cars", Pattern: `{color: "<Red>"}`, WinningPlan: COLLSCAN, Improvement: 50}}}
+	str := getExplainReportHTML(report)
+	if strings.Contains(str, "<tr class=\"collscan\"><td>keyhole.cars</td>") == false || strings.Contains(str, "&lt;Red&gt;") == false {
+		t.Fatal(str)
+	}
+	if summary := GetExplainReportSummary(report.Results); strings.Contains(summary, "50.0x") == false {
+		t.Fatal(summary)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/simagix/keyhole/sim/util"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gopkg.in/yaml.v2"
+)
+
+// QueryShape is a planned query to explain, e.g.
+// {"ns": "keyhole.cars", "filter": {"color": "Red"}, "sort": {"year": -1}, "projection": {"_id": 0}, "hint": {"color": 1},
+// "collation": {"locale": "fr"}}
+type QueryShape struct {
+	Namespace  string `bson:"ns"`
+	Filter     bson.D `bson:"filter"`
+	Sort       bson.D `bson:"sort"`
+	Projection bson.D `bson:"projection"`
+	Hint       bson.D `bson:"hint"`
+	Collation  bson.D `bson:"collation,omitempty"`
+}
+
+// ReadQueryShapes reads an array of query shapes in extended JSON, or in YAML of .yaml or .yml files, from a file,
+// or a single query shape
+func ReadQueryShapes(filename string) ([]QueryShape, error) {
+	var err error
+	var data []byte
+	var file *os.File
+	if file, err = os.Open(filename); err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader, err := util.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = ioutil.ReadAll(reader); err != nil {
+		return nil, err
+	}
+	if ext := strings.ToLower(filepath.Ext(strings.TrimSuffix(filename, ".gz"))); ext == ".yaml" || ext == ".yml" {
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, fmt.Errorf("%v: %v", filename, err)
+		}
+	}
+	return parseQueryShapes(data)
+}
+
+// yamlToJSON converts YAML of an array of documents, or of a document, to JSON, fields order is kept
+func yamlToJSON(data []byte) ([]byte, error) {
+	var list []yaml.MapSlice
+	if err := yaml.Unmarshal(data, &list); err == nil {
+		return getYAMLValueJSON(list)
+	}
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return getYAMLValueJSON(doc)
+}
+
+// getYAMLValueJSON returns JSON of a YAML value, mappings are decoded as yaml.MapSlice to keep fields order
+func getYAMLValueJSON(value interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		buffer.WriteString("{")
+		for i, item := range v {
+			if i > 0 {
+				buffer.WriteString(",")
+			}
+			key, _ := json.Marshal(fmt.Sprint(item.Key))
+			b, err := getYAMLValueJSON(item.Value)
+			if err != nil {
+				return nil, err
+			}
+			buffer.Write(key)
+			buffer.WriteString(":")
+			buffer.Write(b)
+		}
+		buffer.WriteString("}")
+	case []yaml.MapSlice:
+		elems := make([]interface{}, len(v))
+		for i, elem := range v {
+			elems[i] = elem
+		}
+		return getYAMLValueJSON(elems)
+	case []interface{}:
+		buffer.WriteString("[")
+		for i, elem := range v {
+			if i > 0 {
+				buffer.WriteString(",")
+			}
+			b, err := getYAMLValueJSON(elem)
+			if err != nil {
+				return nil, err
+			}
+			buffer.Write(b)
+		}
+		buffer.WriteString("]")
+	default:
+		return json.Marshal(v)
+	}
+	return buffer.Bytes(), nil
+}
+
+// parseQueryShapes parses an array of query shapes, or a single query shape, in extended JSON
+func parseQueryShapes(data []byte) ([]QueryShape, error) {
+	var err error
+	var doc struct {
+		Shapes []QueryShape `bson:"shapes"`
+	}
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) == false {
+		data = append(append([]byte("["), data...), ']')
+	}
+	data = append(append([]byte(`{"shapes": `), data...), '}')
+	if err = bson.UnmarshalExtJSON(data, false, &doc); err != nil {
+		return nil, err
+	}
+	for i, shape := range doc.Shapes {
+		if pos := strings.Index(shape.Namespace, "."); pos <= 0 || pos == len(shape.Namespace)-1 {
+			return nil, fmt.Errorf("invalid namespace '%v' of query shape %d", shape.Namespace, i+1)
+		}
+		if shape.Filter == nil {
+			doc.Shapes[i].Filter = bson.D{}
+		}
+	}
+	return doc.Shapes, err
+}
+
+// getExplainCommand returns a command to explain a query shape
+func (shape QueryShape) getExplainCommand() ExplainCommand {
+	return ExplainCommand{Collection: shape.Namespace[strings.Index(shape.Namespace, ".")+1:], Filter: shape.Filter,
+		Sort: shape.Sort, Projection: shape.Projection, Hint: shape.Hint, Collation: shape.Collation}
+}
+
+// ExecuteQueryShapes explains, gets cardinalities, and suggests indexes of planned queries from a file of query
+// shapes, before they hit logs
+func (e *Explain) ExecuteQueryShapes(client *mongo.Client, filename string) error {
+	return e.ExecuteQueryShapesContext(context.Background(), client, filename)
+}
+
+// ExecuteQueryShapesContext explains query shapes from a file, stops when ctx is done
+func (e *Explain) ExecuteQueryShapesContext(ctx context.Context, client *mongo.Client, filename string) error {
+	var err error
+	var shapes []QueryShape
+	if shapes, err = ReadQueryShapes(filename); err != nil {
+		return err
+	}
+	qe := NewQueryExplainer(client)
+	qe.SetVerbose(e.verbose)
+	card := NewCardinality(client)
+	card.SetVerbose(e.verbose)
+	results := []ExplainResult{}
+	specs := []IndexSpec{}
+	for i, shape := range shapes {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		qe.NameSpace = shape.Namespace
+		qe.ExplainCmd = shape.getExplainCommand()
+		var stdout string
+		var document map[string]interface{}
+		if document, stdout, err = e.explainQuery(ctx, qe, card); err != nil {
+			return fmt.Errorf("%v: %v", shape.Namespace, err)
+		}
+		if spec, ok := getSuggestedIndexSpec(qe.NameSpace, document); ok == true {
+			specs = append(specs, spec)
+		}
+		if e.report != "" {
+			results = append(results, getExplainResult(qe, document))
+			continue
+		}
+		if err = e.writeExplainDocument(filename, i+1, document, stdout); err != nil {
+			return err
+		}
+	}
+	if err = e.outputExplainReport(filename, results); err != nil {
+		return err
+	}
+	return e.outputCreateIndexes(ctx, client, filename, specs)
+}
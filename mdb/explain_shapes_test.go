@@ -0,0 +1,66 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"testing"
+)
+
+func TestParseQueryShapes(t *testing.T) {
+	data := `[
+  {"ns": "keyhole.cars", "filter": {"color": "Red", "year": {"$gt": 2015}}, "sort": {"year": -1}, "projection": {"_id": 0}},
+  {"ns": "keyhole.dealers", "filter": {"updated": {"$gte": {"$date": "2019-10-01T00:00:00Z"}}}, "hint": {"updated": 1}}
+]`
+	shapes, err := parseQueryShapes([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shapes) != 2 || len(shapes[0].Filter) != 2 || len(shapes[0].Projection) != 1 || len(shapes[1].Hint) != 1 {
+		t.Fatal(shapes)
+	}
+	explainCmd := shapes[0].getExplainCommand()
+	if explainCmd.Collection != "cars" || getShapeString(explainCmd.Sort) != "{year: -1}" {
+		t.Fatal(explainCmd)
+	}
+
+	if shapes, err = parseQueryShapes([]byte(`{"ns": "keyhole.cars"}`)); err != nil || len(shapes) != 1 || shapes[0].Filter == nil {
+		t.Fatal(shapes, err)
+	}
+	if _, err = parseQueryShapes([]byte(`[{"ns": "cars", "filter": {}}]`)); err == nil {
+		t.Fatal("expected an error of an invalid namespace")
+	}
+}
+
+func TestYAMLToJSON(t *testing.T) {
+	data := `
+- ns: keyhole.cars
+  filter:
+    color: Red
+    year:
+      $gt: 2015
+  sort:
+    year: -1
+    color: 1
+- ns: keyhole.dealers
+  filter:
+    state: [NY, NJ]
+`
+	b, err := yamlToJSON([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shapes, err := parseQueryShapes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shapes) != 2 || len(shapes[0].Filter) != 2 || getShapeString(shapes[0].Sort) != "{year: -1, color: 1}" {
+		t.Fatal(string(b))
+	}
+
+	if b, err = yamlToJSON([]byte("ns: keyhole.cars\nfilter:\n  color: Red\n")); err != nil {
+		t.Fatal(err)
+	}
+	if shapes, err = parseQueryShapes(b); err != nil || len(shapes) != 1 || len(shapes[0].Filter) != 1 {
+		t.Fatal(string(b), err)
+	}
+}
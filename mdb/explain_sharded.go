@@ -0,0 +1,94 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ShardExplain is executionStats of a shard targeted by a query on a mongos
+type ShardExplain struct {
+	ShardName           string     `json:"shardName"`
+	NReturned           int        `json:"nReturned"`
+	KeysExamined        int        `json:"totalKeysExamined"`
+	DocsExamined        int        `json:"totalDocsExamined"`
+	ExecutionTimeMillis int        `json:"executionTimeMillis"`
+	ExecutionStats      StageStats `json:"executionStats"` // stage tree of the winning plan of the shard
+}
+
+// getShardsExplain returns the stage on the mongos, e.g. SINGLE_SHARD, SHARD_MERGE, or SHARD_MERGE_SORT, and
+// executionStats of shards targeted from executionStats of an explain on a mongos
+func getShardsExplain(executionStats bson.D) (string, []ShardExplain) {
+	shards := []ShardExplain{}
+	executionStages, ok := executionStats.Map()["executionStages"].(bson.D)
+	if ok == false {
+		return "", shards
+	}
+	stages := executionStages.Map()
+	list, _ := stages["shards"].(primitive.A)
+	for _, doc := range toDocs(list) {
+		m := doc.Map()
+		shard := ShardExplain{ShardName: toString(m["shardName"]), NReturned: toInt(m["nReturned"]),
+			KeysExamined: toInt(m["totalKeysExamined"]), DocsExamined: toInt(m["totalDocsExamined"]),
+			ExecutionTimeMillis: toInt(m["executionTimeMillis"])}
+		if m["executionStages"] != nil {
+			shard.ExecutionStats = (&QueryExplainer{}).getStageStats(doc)
+		}
+		shards = append(shards, shard)
+	}
+	return toString(stages["stage"]), shards
+}
+
+// setShardsTargeted sets number of shards of the cluster and flags a query targeting all of more than one shard
+// as a scatter-gather query, or any of more than one shard if the number of shards is unknown
+func setShardsTargeted(summary *ExplainSummary, totalShards int) {
+	summary.TotalShards = totalShards
+	summary.IsScatterGather = len(summary.Shards) > 1 && (totalShards == 0 || len(summary.Shards) >= totalShards)
+}
+
+// getShardsCount returns number of shards of a cluster, 0 if unknown
+func (qe *QueryExplainer) getShardsCount(ctx context.Context) int {
+	count, err := qe.client.Database("config").Collection("shards").CountDocuments(ctx, bson.D{})
+	if err != nil {
+		return 0
+	}
+	return int(count)
+}
+
+// isMergedOnMongos returns true if results of shards are merged on the mongos
+func isMergedOnMongos(stage string) bool {
+	return stage == "SHARD_MERGE" || stage == "SHARD_MERGE_SORT"
+}
+
+// getShardsSummary returns shards targeted and stage trees of their winning plans
+func getShardsSummary(summary ExplainSummary) string {
+	var buffer bytes.Buffer
+	if len(summary.Shards) == 0 {
+		return ""
+	}
+	total := "?"
+	if summary.TotalShards > 0 {
+		total = fmt.Sprintf("%d", summary.TotalShards)
+	}
+	buffer.WriteString(fmt.Sprintf("\n=> Shards Targeted: %d of %s, stage on mongos: %v\n", len(summary.Shards), total, summary.MongosStage))
+	buffer.WriteString("=========================================\n")
+	if isMergedOnMongos(summary.MongosStage) {
+		buffer.WriteString(fmt.Sprintf("Results merged on mongos (%v)\n", summary.MongosStage))
+	}
+	if summary.IsScatterGather {
+		buffer.WriteString("Scatter-gather query, include the shard key in the filter to target fewer shards\n")
+	}
+	for _, shard := range summary.Shards {
+		buffer.WriteString(fmt.Sprintf("Shard %v: nReturned: %d, totalKeysExamined: %d, totalDocsExamined: %d, executionTimeMillis: %d\n",
+			shard.ShardName, shard.NReturned, shard.KeysExamined, shard.DocsExamined, shard.ExecutionTimeMillis))
+		if shard.ExecutionStats.Stage != "" {
+			buffer.WriteString(getStageStatsSummaryString(shard.ExecutionStats, 1))
+		}
+	}
+	return buffer.String()
+}
@@ -0,0 +1,75 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const mongosExecutionStats = `{
+  "nReturned": 20, "totalKeysExamined": 20, "totalDocsExamined": 20,
+  "executionStages": {
+    "stage": "SHARD_MERGE_SORT", "nReturned": 20,
+    "shards": [{
+      "shardName": "shard01", "executionSuccess": true, "nReturned": 12, "executionTimeMillis": 3,
+      "totalKeysExamined": 12, "totalDocsExamined": 12,
+      "executionStages": {
+        "stage": "FETCH", "advanced": 12, "works": 13, "executionTimeMillisEstimate": 0,
+        "inputStage": {"stage": "IXSCAN", "keyPattern": {"color": 1}, "advanced": 12, "works": 13, "executionTimeMillisEstimate": 0}
+      }
+    }, {
+      "shardName": "shard02", "executionSuccess": true, "nReturned": 8, "executionTimeMillis": 1,
+      "totalKeysExamined": 8, "totalDocsExamined": 8,
+      "executionStages": {
+        "stage": "FETCH", "advanced": 8, "works": 9, "executionTimeMillisEstimate": 0,
+        "inputStage": {"stage": "IXSCAN", "keyPattern": {"color": 1}, "advanced": 8, "works": 9, "executionTimeMillisEstimate": 0}
+      }
+    }]
+  }
+}`
+
+func TestGetShardsExplain(t *testing.T) {
+	var doc bson.D
+	if err := bson.UnmarshalExtJSON([]byte(mongosExecutionStats), false, &doc); err != nil {
+		t.Fatal(err)
+	}
+	stage, shards := getShardsExplain(doc)
+	if stage != "SHARD_MERGE_SORT" || len(shards) != 2 || isMergedOnMongos(stage) == false {
+		t.Fatal(stage, shards)
+	}
+	if shards[0].ShardName != "shard01" || shards[0].NReturned != 12 || shards[0].ExecutionStats.Stage != "FETCH" ||
+		len(shards[0].ExecutionStats.InputStages) != 1 || shards[1].DocsExamined != 8 {
+		t.Fatal(shards)
+	}
+}
+
+func TestSetShardsTargeted(t *testing.T) {
+	summary := ExplainSummary{Shards: []ShardExplain{{ShardName: "shard01"}, {ShardName: "shard02"}}}
+	if setShardsTargeted(&summary, 3); summary.IsScatterGather == true {
+		t.Fatal("2 of 3 shards targeted")
+	}
+	if setShardsTargeted(&summary, 2); summary.IsScatterGather == false {
+		t.Fatal("all shards targeted")
+	}
+	summary.Shards = summary.Shards[:1]
+	if setShardsTargeted(&summary, 0); summary.IsScatterGather == true {
+		t.Fatal("a single shard targeted")
+	}
+}
+
+func TestGetShardsSummary(t *testing.T) {
+	summary := ExplainSummary{MongosStage: "SHARD_MERGE", TotalShards: 2, IsScatterGather: true,
+		Shards: []ShardExplain{{ShardName: "shard01"}, {ShardName: "shard02"}}}
+	str := getShardsSummary(summary)
+	t.Log(str)
+	if strings.Contains(str, "2 of 2") == false || strings.Contains(str, "merged on mongos") == false ||
+		strings.Contains(str, "Scatter-gather") == false || strings.Contains(str, "Shard shard02") == false {
+		t.Fatal(str)
+	}
+	if str = getShardsSummary(ExplainSummary{}); str != "" {
+		t.Fatal(str)
+	}
+}
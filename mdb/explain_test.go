@@ -0,0 +1,78 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestGetExplainCommand(t *testing.T) {
+	doc := OpPerformanceDoc{Command: "find", Namespace: "keyhole.cars", Filter: "{color: 1, year: 1}",
+		Examples: []ExampleDoc{{Milli: 150, Statement: `find { find: "cars", filter: { year: NumberLong(2018), color: "Blue" }, sort: { brand: -1 }, $db: "keyhole" }`}}}
+	explainCmd, ok := getExplainCommand(doc)
+	if ok == false || explainCmd.Collection != "cars" {
+		t.Fatal(explainCmd)
+	}
+	if len(explainCmd.Filter) != 2 || explainCmd.Filter[0].Value != int64(2018) || explainCmd.Filter[1].Value != "Blue" {
+		t.Fatal(explainCmd.Filter)
+	}
+	if len(explainCmd.Sort) != 1 || explainCmd.Sort[0].Key != "brand" {
+		t.Fatal(explainCmd.Sort)
+	}
+
+	doc = OpPerformanceDoc{Command: "aggregate", Namespace: "keyhole.cars", Filter: `{color: 1}, group: {_id: "$brand"}, sort: {year: -1}`}
+	if explainCmd, ok = getExplainCommand(doc); ok == false {
+		t.Fatal("expected an explain command of the query pattern")
+	}
+	if len(explainCmd.Filter) != 1 || explainCmd.Filter[0].Key != "color" || explainCmd.Group != "brand" || explainCmd.Sort[0].Key != "year" {
+		t.Fatal(explainCmd)
+	}
+
+	if _, ok = getExplainCommand(OpPerformanceDoc{Command: "insert", Namespace: "keyhole.cars", Filter: "{}"}); ok == true {
+		t.Fatal("expected insert not explained")
+	}
+}
+
+func TestToBSONValue(t *testing.T) {
+	doc, err := parseShellDoc(`{ a: new Date(1569679200000), b: ObjectId('5d8f4f4b2a1e4c3f9c6e1a2b'), c: NumberInt(3), d: [ true, null ] }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := toBSONValue(doc).(bson.D).Map()
+	if m["a"] != primitive.DateTime(1569679200000) || m["c"] != int32(3) {
+		t.Fatal(m)
+	}
+	if _, ok := m["b"].(primitive.ObjectID); ok == false {
+		t.Fatal(m["b"])
+	}
+	if arr := m["d"].(primitive.A); arr[0] != true || arr[1] != nil {
+		t.Fatal(arr)
+	}
+}
+
+func TestExecuteAllPlansContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewExplain().ExecuteAllPlansContext(ctx, nil, "testdata/mongod.log"); err != context.Canceled {
+		t.Fatal("expected context canceled, but got", err)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	ctx, cancel := withTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Fatal("expected deadline exceeded, but got", ctx.Err())
+	}
+	ctx, cancel = withTimeout(context.Background(), 0)
+	if _, ok := ctx.Deadline(); ok == true {
+		t.Fatal("expected no deadline")
+	}
+	cancel()
+}
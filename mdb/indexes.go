@@ -7,18 +7,29 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 // IndexesReader holder indexes reader struct
 type IndexesReader struct {
-	client  *mongo.Client
-	dbName  string
-	verbose bool
+	client      *mongo.Client
+	collSizes   map[string]CollectionSizeDoc
+	concurrency int
+	dbName      string
+	errors      []CollectionError
+	mutex       sync.Mutex
+	readPref    *readpref.ReadPref // of reading indexes, usage, and stats, the client's if nil
+	selectivity bool
+	skipSystem  bool
+	timeout     time.Duration // timeout of reading indexes of each collection
+	verbose     bool
 }
 
 // AccessesDoc - accessss
@@ -33,9 +44,15 @@ type UsageDoc struct {
 	Accesses AccessesDoc `json:"accesses"`
 }
 
+// CollectionError is an error of reading indexes of a namespace
+type CollectionError struct {
+	Namespace string `json:"namespace"`
+	Error     string `json:"error"`
+}
+
 // IndexStatsDoc -
 type IndexStatsDoc struct {
-	Fields       []string
+	Fields       []string   `json:"fields"`
 	Key          string     `json:"key"`
 	Name         string     `json:"name"`
 	EffectiveKey string     `json:"effectiveKey"`
@@ -43,11 +60,27 @@ type IndexStatsDoc struct {
 	IsShardKey   bool       `json:"shardKey"`
 	TotalOps     int        `json:"totalOps"`
 	Usage        []UsageDoc `json:"stats"`
+	PrimaryOps   *int       `json:"primaryOps,omitempty"` // set if accesses of all members are merged
+	MemberOps    bool       `json:"memberOps,omitempty"`  // accesses of a member read with a read preference other than primary
+	Size         int        `json:"size"`                 // on disk
+	CacheBytes   int        `json:"cacheBytes"`           // in WiredTiger cache
+
+	IsLowSelectivity bool     `json:"lowSelectivity,omitempty"`
+	Selectivity      *float64 `json:"selectivity,omitempty"` // distinct values of the leading key per sampled document
+
+	Collation               string `json:"collation,omitempty"`
+	ExpireAfterSeconds      *int   `json:"expireAfterSeconds,omitempty"`
+	Hidden                  bool   `json:"hidden,omitempty"`
+	PartialFilterExpression string `json:"partialFilterExpression,omitempty"`
+	Sparse                  bool   `json:"sparse,omitempty"`
+	Unique                  bool   `json:"unique,omitempty"`
+	WildcardProjection      string `json:"wildcardProjection,omitempty"`
+	Weights                 string `json:"weights,omitempty"` // of text indexes
 }
 
 // NewIndexesReader establish seeding parameters
 func NewIndexesReader(client *mongo.Client) *IndexesReader {
-	return &IndexesReader{client: client}
+	return &IndexesReader{client: client, collSizes: map[string]CollectionSizeDoc{}, skipSystem: true}
 }
 
 // SetVerbose sets verbose level
@@ -60,22 +93,70 @@ func (ir *IndexesReader) SetDBName(dbName string) {
 	ir.dbName = dbName
 }
 
+// SetReadPreference sets a read preference of reading indexes, usage, and stats of collections, e.g. of
+// secondaries or analytics nodes to spare primaries.  Usage of other than primary is of the member read and
+// indexes aren't reported unused.
+func (ir *IndexesReader) SetReadPreference(rp *readpref.ReadPref) {
+	ir.readPref = rp
+}
+
+// SetConcurrency sets number of collections to read at the same time, defaults to 1
+func (ir *IndexesReader) SetConcurrency(concurrency int) {
+	ir.concurrency = concurrency
+}
+
+// SetSkipSystem sets to skip views and system namespaces, e.g. buckets of time series collections, defaults
+// to true.  Errors of namespaces not skipped are reported by GetErrors.
+func (ir *IndexesReader) SetSkipSystem(skip bool) {
+	ir.skipSystem = skip
+}
+
+// GetErrors returns errors of namespaces whose indexes or stats couldn't be read
+func (ir *IndexesReader) GetErrors() []CollectionError {
+	ir.mutex.Lock()
+	defer ir.mutex.Unlock()
+	errs := append([]CollectionError{}, ir.errors...)
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Namespace < errs[j].Namespace })
+	return errs
+}
+
+// addError keeps an error of a namespace
+func (ir *IndexesReader) addError(namespace string, err error) {
+	ir.mutex.Lock()
+	defer ir.mutex.Unlock()
+	ir.errors = append(ir.errors, CollectionError{Namespace: namespace, Error: err.Error()})
+}
+
+// SetTimeout sets timeout of reading indexes of each collection, 0 for no timeout
+func (ir *IndexesReader) SetTimeout(timeout time.Duration) {
+	ir.timeout = timeout
+}
+
 // GetIndexes list all indexes of collections of databases
 func (ir *IndexesReader) GetIndexes() (bson.M, error) {
+	return ir.GetIndexesContext(context.Background())
+}
+
+// GetIndexesContext list all indexes of collections of databases, stops when ctx is done
+func (ir *IndexesReader) GetIndexesContext(ctx context.Context) (bson.M, error) {
 	var err error
 	indexesMap := bson.M{}
 	if ir.dbName != "" {
-		indexesMap[ir.dbName], err = ir.GetIndexesFromDB(ir.dbName)
+		indexesMap[ir.dbName], err = ir.GetIndexesFromDBContext(ctx, ir.dbName)
 		return indexesMap, err
 	}
 
-	dbNames, _ := ListDatabaseNames(ir.client)
+	dbNames, _ := ListDatabaseNamesContext(ctx, ir.client)
 	for _, name := range dbNames {
 		if name == "admin" || name == "config" || name == "local" {
 			continue
 		}
-		if indexesMap[name], err = ir.GetIndexesFromDB(name); err != nil {
-			return indexesMap, err
+		if indexesMap[name], err = ir.GetIndexesFromDBContext(ctx, name); err != nil {
+			if ctx.Err() != nil {
+				return indexesMap, err
+			}
+			ir.addError(name, err) // e.g. not authorized on a database
+			err = nil
 		}
 	}
 	return indexesMap, err
@@ -83,11 +164,15 @@ func (ir *IndexesReader) GetIndexes() (bson.M, error) {
 
 // GetIndexesFromDB list all indexes of collections of a database
 func (ir *IndexesReader) GetIndexesFromDB(dbName string) (bson.M, error) {
+	return ir.GetIndexesFromDBContext(context.Background(), dbName)
+}
+
+// GetIndexesFromDBContext list all indexes of collections of a database, stops when ctx is done
+func (ir *IndexesReader) GetIndexesFromDBContext(ctx context.Context, dbName string) (bson.M, error) {
 	var err error
 	var cur *mongo.Cursor
-	var ctx = context.Background()
 	var indexesMap = bson.M{}
-	if cur, err = ir.client.Database(dbName).ListCollections(ctx, bson.M{}); err != nil {
+	if cur, err = ir.client.Database(dbName, getDatabaseOptions(ir.readPref)).ListCollections(ctx, bson.M{}); err != nil {
 		return indexesMap, err
 	}
 	defer cur.Close(ctx)
@@ -99,43 +184,63 @@ func (ir *IndexesReader) GetIndexesFromDB(dbName string) (bson.M, error) {
 		}
 		coll := fmt.Sprintf("%v", elem["name"])
 		collType := fmt.Sprintf("%v", elem["type"])
-		if strings.Index(coll, "system.") == 0 || (elem["type"] != nil && collType != "collection") {
+		if ir.skipSystem == true && (strings.Index(coll, "system.") == 0 || (elem["type"] != nil && collType != "collection")) {
 			continue
 		}
 		collections = append(collections, coll)
 	}
 
 	sort.Strings(collections)
-	for _, collection := range collections {
-		indexesMap[collection] = ir.GetIndexesFromCollection(ir.client.Database(dbName).Collection(collection))
+	results := make([][]IndexStatsDoc, len(collections))
+	concurrency := ir.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, concurrency)
+	started := 0
+	for i, collection := range collections {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		started++
+		semaphore <- struct{}{}
+		wg.Add(1)
+		go func(i int, collection string) {
+			defer func() { <-semaphore; wg.Done() }()
+			opCtx, cancel := withTimeout(ctx, ir.timeout)
+			results[i] = ir.GetIndexesFromCollectionContext(opCtx, ir.client.Database(dbName, getDatabaseOptions(ir.readPref)).Collection(collection))
+			cancel()
+		}(i, collection)
+	}
+	wg.Wait()
+	for i, collection := range collections[:started] { // merged in order of names
+		indexesMap[collection] = results[i]
 	}
 	return indexesMap, err
 }
 
 // GetIndexesFromCollection gets indexes from a collection
 func (ir *IndexesReader) GetIndexesFromCollection(collection *mongo.Collection) []IndexStatsDoc {
+	return ir.GetIndexesFromCollectionContext(context.Background(), collection)
+}
+
+// GetIndexesFromCollectionContext gets indexes from a collection, stops when ctx is done
+func (ir *IndexesReader) GetIndexesFromCollectionContext(ctx context.Context, collection *mongo.Collection) []IndexStatsDoc {
 	var err error
-	var ctx = context.Background()
-	var pipeline = MongoPipeline(`{"$indexStats": {}}`)
 	var list []IndexStatsDoc
 	var icur *mongo.Cursor
-	var scur *mongo.Cursor
+	var indexStats []bson.M
 
-	if scur, err = collection.Aggregate(ctx, pipeline); err != nil {
-		// fmt.Println(err)
-		return list
-	}
-	var indexStats = []bson.M{}
-	for scur.Next(ctx) {
-		var result = bson.M{}
-		if err = scur.Decode(&result); err != nil {
-			continue
-		}
-		indexStats = append(indexStats, result)
+	ns := collection.Database().Name() + "." + collection.Name()
+	hasUsage := true
+	if indexStats, err = getIndexStats(ctx, collection); err != nil {
+		ir.addError(ns, fmt.Errorf("$indexStats: %v", err)) // indexes are listed without usage
+		hasUsage = false
 	}
-	scur.Close(ctx)
 	indexView := collection.Indexes()
 	if icur, err = indexView.List(ctx); err != nil {
+		ir.addError(ns, fmt.Errorf("listIndexes: %v", err))
 		return list
 	}
 	defer icur.Close(ctx)
@@ -146,64 +251,246 @@ func (ir *IndexesReader) GetIndexesFromCollection(collection *mongo.Collection)
 			continue
 		}
 
-		var keys bson.D
-		var indexName string
-		for _, v := range idx {
-			if v.Key == "name" {
-				indexName = v.Value.(string)
-			} else if v.Key == "key" {
-				keys = v.Value.(bson.D)
-			}
-		}
-		var strbuf bytes.Buffer
-		fields := []string{}
-		for n, value := range keys {
-			fields = append(fields, value.Key)
-			if n == 0 {
-				strbuf.WriteString("{ ")
-			}
-			strbuf.WriteString(value.Key + ": " + fmt.Sprint(value.Value))
-			if n == len(keys)-1 {
-				strbuf.WriteString(" }")
-			} else {
-				strbuf.WriteString(", ")
-			}
-		}
-		o := IndexStatsDoc{Key: strbuf.String(), Fields: fields, Name: indexName}
+		o, keys := getIndexStatsDoc(idx)
 		// Check shard keys
 		var v bson.M
-		ns := collection.Database().Name() + "." + collection.Name()
 		if err = ir.client.Database("config").Collection("collections").FindOne(ctx, bson.M{"_id": ns, "key": keys}).Decode(&v); err == nil {
 			o.IsShardKey = true
 		}
-		o.EffectiveKey = strings.Replace(o.Key[2:len(o.Key)-2], ": -1", ": 1", -1)
-		o.Usage = []UsageDoc{}
-		for _, result := range indexStats {
-			if result["name"].(string) == indexName {
-				b, _ := bson.Marshal(result)
-				var usage UsageDoc
-				bson.Unmarshal(b, &usage)
-				o.TotalOps += usage.Accesses.Ops
-				o.Usage = append(o.Usage, usage)
-			}
+		if hasUsage == true { // nil usage is unknown, not unused
+			o.Usage = []UsageDoc{}
+			addIndexUsage(&o, indexStats)
+			o.MemberOps = IsMemberReadPreference(ir.readPref)
 		}
 		list = append(list, o)
 	}
 	icur.Close(ctx)
+	if stats, e := getCollStats(ctx, collection, ir.readPref); e == nil {
+		setIndexSizes(list, stats)
+		ir.mutex.Lock()
+		ir.collSizes[ns] = getCollectionSize(stats)
+		ir.mutex.Unlock()
+	}
+	if ir.selectivity == true {
+		if err = ir.setIndexesSelectivity(ctx, collection, list); err != nil {
+			ir.addError(ns, fmt.Errorf("selectivity: %v", err))
+		}
+	}
+	setDuppedIndexes(list)
+	return list
+}
+
+// getIndexStatsDoc returns an index from a listIndexes document and its keys
+func getIndexStatsDoc(idx bson.D) (IndexStatsDoc, bson.D) {
+	var keys bson.D
+	var indexName string
+	for _, v := range idx {
+		if v.Key == "name" {
+			indexName, _ = v.Value.(string)
+		} else if v.Key == "key" {
+			keys, _ = v.Value.(bson.D)
+		}
+	}
+	var strbuf bytes.Buffer
+	fields := []string{}
+	for n, value := range keys {
+		fields = append(fields, value.Key)
+		if n == 0 {
+			strbuf.WriteString("{ ")
+		}
+		strbuf.WriteString(value.Key + ": " + fmt.Sprint(value.Value))
+		if n == len(keys)-1 {
+			strbuf.WriteString(" }")
+		} else {
+			strbuf.WriteString(", ")
+		}
+	}
+	o := IndexStatsDoc{Key: strbuf.String(), Fields: fields, Name: indexName}
+	if len(o.Key) > 4 {
+		o.EffectiveKey = strings.Replace(o.Key[2:len(o.Key)-2], ": -1", ": 1", -1)
+	}
+	setIndexOptions(&o, idx)
+	return o, keys
+}
+
+// setDuppedIndexes sorts indexes by effective keys and marks indexes covered by others
+func setDuppedIndexes(list []IndexStatsDoc) {
 	sort.Slice(list, func(i, j int) bool { return (list[i].EffectiveKey < list[j].EffectiveKey) })
 	for i, o := range list {
 		if o.Key != "{ _id: 1 }" && o.IsShardKey == false {
 			list[i].IsDupped = checkIfDupped(o, list)
 		}
 	}
-	return list
+}
+
+// getIndexStats returns $indexStats results of a collection
+func getIndexStats(ctx context.Context, collection *mongo.Collection) ([]bson.M, error) {
+	var err error
+	var cur *mongo.Cursor
+	var indexStats = []bson.M{}
+	if cur, err = collection.Aggregate(ctx, MongoPipeline(`{"$indexStats": {}}`)); err != nil {
+		return indexStats, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var result = bson.M{}
+		if err = cur.Decode(&result); err != nil {
+			continue
+		}
+		indexStats = append(indexStats, result)
+	}
+	return indexStats, cur.Err()
+}
+
+// addIndexUsage adds accesses of an index from $indexStats results, a result per host
+func addIndexUsage(o *IndexStatsDoc, indexStats []bson.M) {
+	for _, result := range indexStats {
+		if name, ok := result["name"].(string); ok == false || name != o.Name {
+			continue
+		}
+		b, _ := bson.Marshal(result)
+		var usage UsageDoc
+		bson.Unmarshal(b, &usage)
+		o.TotalOps += usage.Accesses.Ops
+		o.Usage = append(o.Usage, usage)
+	}
+}
+
+// setIndexOptions decodes options of an index from listIndexes
+func setIndexOptions(o *IndexStatsDoc, idx bson.D) {
+	for _, v := range idx {
+		switch v.Key {
+		case "collation":
+			o.Collation = getShapeString(v.Value)
+		case "expireAfterSeconds":
+			seconds := toInt(v.Value)
+			o.ExpireAfterSeconds = &seconds
+		case "hidden":
+			o.Hidden = isTrue(v.Value)
+		case "partialFilterExpression":
+			o.PartialFilterExpression = getShapeString(v.Value)
+		case "sparse":
+			o.Sparse = isTrue(v.Value)
+		case "unique":
+			o.Unique = isTrue(v.Value)
+		case "weights":
+			o.Weights = getShapeString(v.Value)
+		case "wildcardProjection":
+			o.WildcardProjection = getShapeString(v.Value)
+		}
+	}
+}
+
+// isTrue returns true of a bool or a non-zero number, options were set as numbers by old drivers, e.g. {unique: 1}
+func isTrue(value interface{}) bool {
+	if b, ok := value.(bool); ok {
+		return b
+	}
+	return toInt(value) != 0
+}
+
+// getIndexOptionsString returns options of an index, e.g. unique: true, TTL: 3600s
+func getIndexOptionsString(o IndexStatsDoc) string {
+	strs := []string{}
+	if o.Unique == true {
+		strs = append(strs, "unique: true")
+	}
+	if o.Sparse == true {
+		strs = append(strs, "sparse: true")
+	}
+	if o.ExpireAfterSeconds != nil {
+		strs = append(strs, fmt.Sprintf("TTL: %ds", *o.ExpireAfterSeconds))
+	}
+	if o.PartialFilterExpression != "" {
+		strs = append(strs, "partial: "+o.PartialFilterExpression)
+	}
+	if o.Collation != "" {
+		strs = append(strs, "collation: "+o.Collation)
+	}
+	if o.WildcardProjection != "" {
+		strs = append(strs, "wildcardProjection: "+o.WildcardProjection)
+	}
+	if o.Weights != "" {
+		strs = append(strs, "weights: "+o.Weights)
+	}
+	if o.Hidden == true {
+		strs = append(strs, "hidden: true")
+	}
+	return strings.Join(strs, ", ")
+}
+
+// isCoveredBy returns true if queries using an index can use the other index with the same results,
+// unique and TTL indexes enforce constraints, and hidden indexes aren't used
+func isCoveredBy(doc IndexStatsDoc, o IndexStatsDoc) bool {
+	if doc.Unique == true || doc.ExpireAfterSeconds != nil || doc.Hidden == true || o.Hidden == true {
+		return false
+	}
+	if doc.WildcardProjection != "" || o.WildcardProjection != "" {
+		return false
+	}
+	if doc.PartialFilterExpression != o.PartialFilterExpression || doc.Collation != o.Collation || doc.Weights != o.Weights {
+		return false
+	}
+	if isKeyTypesCovered(doc, o) == false {
+		return false
+	}
+	return doc.Sparse == true || o.Sparse == false
+}
+
+// getIndexKeyTypes returns types of keys of an index by fields, 1 of ascending and descending keys,
+// and text, 2d, 2dsphere, geoHaystack, or hashed of others
+func getIndexKeyTypes(o IndexStatsDoc) map[string]string {
+	types := map[string]string{}
+	key := strings.TrimSuffix(strings.TrimPrefix(o.Key, "{ "), " }")
+	for _, elem := range strings.Split(key, ", ") {
+		idx := strings.LastIndex(elem, ": ")
+		if idx < 0 {
+			continue
+		}
+		value := elem[idx+2:]
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			value = "1"
+		}
+		types[elem[:idx]] = value
+	}
+	return types
+}
+
+// isSpecialKey returns true if a key isn't of a regular index, text, geospatial, and wildcard indexes
+// only reference documents with the field
+func isSpecialKey(field string, keyType string) bool {
+	if field == "_fts" || field == "_ftsx" || field == "$**" || strings.HasSuffix(field, ".$**") {
+		return true
+	}
+	return keyType == "text" || keyType == "2d" || keyType == "2dsphere" || keyType == "geoHaystack"
+}
+
+// isKeyTypesCovered returns true if keys of an index are of the same types of the other index, text and
+// wildcard indexes aren't covered, and an index with extra text or geospatial keys doesn't cover queries
+// without them
+func isKeyTypesCovered(doc IndexStatsDoc, o IndexStatsDoc) bool {
+	dtypes, otypes := getIndexKeyTypes(doc), getIndexKeyTypes(o)
+	for _, field := range doc.Fields {
+		if isSpecialKey(field, dtypes[field]) && dtypes[field] != "2dsphere" && dtypes[field] != "2d" {
+			return false
+		}
+		if dtypes[field] != otypes[field] {
+			return false
+		}
+	}
+	for _, field := range o.Fields {
+		if _, ok := dtypes[field]; ok == false && isSpecialKey(field, otypes[field]) {
+			return false
+		}
+	}
+	return true
 }
 
 // check if an index is a dup of others
 func checkIfDupped(doc IndexStatsDoc, list []IndexStatsDoc) bool {
 	for _, o := range list {
 		// check indexes if not marked as dupped, has the same first field, and more or equal number of fields
-		if o.IsDupped == false && doc.Fields[0] == o.Fields[0] && doc.Key != o.Key && len(o.Fields) >= len(doc.Fields) {
+		if o.IsDupped == false && doc.Fields[0] == o.Fields[0] && doc.Key != o.Key && len(o.Fields) >= len(doc.Fields) && isCoveredBy(doc, o) {
 			nmatched := 0
 			for i, fld := range doc.Fields {
 				if i == 0 {
@@ -234,6 +521,9 @@ func (ir *IndexesReader) Print(indexesMap bson.M) {
 			ns := key + "." + k
 			buffer.WriteString("\n")
 			buffer.WriteString(ns)
+			if doc, ok := ir.collSizes[ns]; ok == true {
+				buffer.WriteString(" (" + getCollectionSizeString(doc) + ")")
+			}
 			buffer.WriteString(":\n")
 			for _, o := range list {
 				font := "\x1b[0m  "
@@ -242,11 +532,27 @@ func (ir *IndexesReader) Print(indexesMap bson.M) {
 					font = "\x1b[0m* "
 				} else if o.IsDupped == true {
 					font = "\x1b[31;1mx " // red
-				} else if o.TotalOps == 0 {
+				} else if isUnusedIndex(o) {
 					font = "\x1b[34;1m? " // blue
+				} else if isUnusedOnPrimary(o) {
+					font = "\x1b[33;1m~ " // yellow, used by secondaries only
+				} else if o.IsLowSelectivity == true {
+					font = "\x1b[35;1m! " // magenta
 				}
 
 				buffer.WriteString(font + o.Key + "\x1b[0m")
+				if options := getIndexOptionsString(o); options != "" {
+					buffer.WriteString(" " + options)
+				}
+				if sizes := getIndexSizeString(o); sizes != "" {
+					buffer.WriteString(" (" + sizes + ")")
+				}
+				if selectivity := getSelectivityString(o); selectivity != "" {
+					buffer.WriteString(" " + selectivity)
+				}
+				if o.MemberOps == true && len(o.Usage) > 0 {
+					buffer.WriteString(" (usage of a member)")
+				}
 				for _, u := range o.Usage {
 					buffer.Write([]byte("\n\thost: " + u.Host + ", ops: " + fmt.Sprintf("%v", u.Accesses.Ops) + ", since: " + fmt.Sprintf("%v", u.Accesses.Since)))
 				}
@@ -255,6 +561,12 @@ func (ir *IndexesReader) Print(indexesMap bson.M) {
 			fmt.Println(buffer.String())
 		}
 	}
+	if errs := ir.GetErrors(); len(errs) > 0 {
+		fmt.Println("\nErrors:")
+		for _, e := range errs {
+			fmt.Println(e.Namespace + ": " + e.Error)
+		}
+	}
 }
 
 func getSortedKeys(rmap bson.M) []string {
@@ -0,0 +1,167 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// IndexesApplier creates indexes of a source missing from a target cluster
+type IndexesApplier struct {
+	background bool
+	client     *mongo.Client
+	dryRun     bool
+	verbose    bool
+}
+
+// NewIndexesApplier returns an IndexesApplier of a target cluster
+func NewIndexesApplier(client *mongo.Client) *IndexesApplier {
+	return &IndexesApplier{client: client}
+}
+
+// SetBackground sets to build indexes in the background, ignored by 4.2 and later
+func (ia *IndexesApplier) SetBackground(background bool) {
+	ia.background = background
+}
+
+// SetDryRun sets to print createIndex commands without running them
+func (ia *IndexesApplier) SetDryRun(dryRun bool) {
+	ia.dryRun = dryRun
+}
+
+// SetVerbose sets verbose level
+func (ia *IndexesApplier) SetVerbose(verbose bool) {
+	ia.verbose = verbose
+}
+
+// ApplyIndexes creates indexes of databases of source missing from the target cluster, one at a time.  Each
+// build waits for the majority of members to commit before the next starts.
+func (ia *IndexesApplier) ApplyIndexes(source bson.M) ([]IndexDiff, error) {
+	var err error
+	target := bson.M{}
+	ir := NewIndexesReader(ia.client)
+	for _, dbName := range getSortedKeys(source) {
+		if target[dbName], err = ir.GetIndexesFromDB(dbName); err != nil {
+			return nil, err
+		}
+	}
+	indexes := getIndexesByKey(source)
+	missing := []IndexDiff{}
+	for _, d := range CompareIndexes(source, target).Missing {
+		if strings.Replace(d.Key, " ", "", -1) != "{_id:1}" {
+			missing = append(missing, d)
+		}
+	}
+	for i, d := range missing {
+		o := indexes[d.Namespace+" "+strings.Replace(d.Key, " ", "", -1)].index
+		progress := fmt.Sprintf("[%d/%d] %s %s", i+1, len(missing), d.Namespace, o.Name)
+		if ia.dryRun == true {
+			fmt.Println(progress)
+			fmt.Println(getCreateIndexScript(d.Namespace, o))
+			continue
+		}
+		fmt.Println(progress, "creating", o.Key)
+		t := time.Now()
+		if err = ia.createIndex(d.Namespace, o); err != nil {
+			return missing, fmt.Errorf("%v %v: %v", d.Namespace, o.Name, err)
+		}
+		fmt.Println(progress, "created in", time.Since(t).Round(time.Millisecond))
+	}
+	return missing, err
+}
+
+// createIndex runs createIndexes of an index with the majority write concern
+func (ia *IndexesApplier) createIndex(namespace string, o IndexStatsDoc) error {
+	var err error
+	var spec bson.D
+	idx := strings.Index(namespace, ".")
+	if idx < 0 {
+		return fmt.Errorf("invalid namespace %v", namespace)
+	}
+	if spec, err = getIndexSpec(o); err != nil {
+		return err
+	}
+	if ia.background == true {
+		spec = append(spec, bson.E{Key: "background", Value: true})
+	}
+	cmd := bson.D{{Key: "createIndexes", Value: namespace[idx+1:]}, {Key: "indexes", Value: bson.A{spec}},
+		{Key: "writeConcern", Value: bson.D{{Key: "w", Value: "majority"}}}}
+	if ia.verbose == true {
+		fmt.Println(getShapeString(cmd))
+	}
+	return ia.client.Database(namespace[:idx]).RunCommand(context.Background(), cmd).Err()
+}
+
+// getIndexSpec returns an index specification of createIndexes
+func getIndexSpec(o IndexStatsDoc) (bson.D, error) {
+	var err error
+	var keys bson.D
+	if keys, err = parseShellDoc(getIndexKeyScript(o.Key)); err != nil {
+		return nil, err
+	}
+	for i, elem := range keys {
+		if f, ok := elem.Value.(float64); ok == true && f == float64(int32(f)) {
+			keys[i].Value = int32(f)
+		}
+	}
+	spec := bson.D{{Key: "key", Value: keys}, {Key: "name", Value: o.Name}}
+	if o.Unique == true {
+		spec = append(spec, bson.E{Key: "unique", Value: true})
+	}
+	if o.Sparse == true {
+		spec = append(spec, bson.E{Key: "sparse", Value: true})
+	}
+	if o.ExpireAfterSeconds != nil {
+		spec = append(spec, bson.E{Key: "expireAfterSeconds", Value: int32(*o.ExpireAfterSeconds)})
+	}
+	if o.Hidden == true {
+		spec = append(spec, bson.E{Key: "hidden", Value: true})
+	}
+	for _, option := range []struct{ key, value string }{{"partialFilterExpression", o.PartialFilterExpression},
+		{"collation", o.Collation}, {"wildcardProjection", o.WildcardProjection}, {"weights", o.Weights}} {
+		if option.value == "" {
+			continue
+		}
+		var doc bson.D
+		if doc, err = parseShellDoc(option.value); err != nil {
+			return nil, fmt.Errorf("%v: %v", option.key, err)
+		}
+		spec = append(spec, bson.E{Key: option.key, Value: toIndexOptionValue(toBSONValue(doc))})
+	}
+	return spec, err
+}
+
+// toIndexOptionValue converts whole numbers to int32, e.g. strength of collation and wildcardProjection
+func toIndexOptionValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.D:
+		for i, elem := range v {
+			v[i].Value = toIndexOptionValue(elem.Value)
+		}
+		return v
+	case primitive.A:
+		for i, elem := range v {
+			v[i] = toIndexOptionValue(elem)
+		}
+		return v
+	case float64:
+		if v == float64(int32(v)) {
+			return int32(v)
+		}
+	}
+	return value
+}
+
+// getCreateIndexScript returns a createIndex command of mongo shell of an index with its options
+func getCreateIndexScript(namespace string, o IndexStatsDoc) string {
+	idx := strings.Index(namespace, ".")
+	return fmt.Sprintf("db.getSiblingDB(%q).getCollection(%q).createIndex(%s, %s)", namespace[:idx], namespace[idx+1:],
+		getIndexKeyScript(o.Key), getIndexOptionsScript(o))
+}
@@ -0,0 +1,44 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGetIndexSpec(t *testing.T) {
+	seconds := 3600
+	o := IndexStatsDoc{Key: "{ a.b: 1, loc: 2dsphere }", Name: "a.b_1_loc_2dsphere", Unique: true, ExpireAfterSeconds: &seconds,
+		PartialFilterExpression: `{b: {$exists: true}}`, Collation: `{locale: "fr", strength: 2}`}
+	spec, err := getIndexSpec(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(getShapeString(spec))
+	m := spec.Map()
+	keys := m["key"].(bson.D)
+	if keys[0].Key != "a.b" || keys[0].Value != int32(1) || keys[1].Value != "2dsphere" {
+		t.Fatal(keys)
+	}
+	if m["name"] != o.Name || m["unique"] != true || m["expireAfterSeconds"] != int32(3600) {
+		t.Fatal(spec)
+	}
+	partial := m["partialFilterExpression"].(bson.D)
+	if partial[0].Value.(bson.D)[0].Value != true {
+		t.Fatal(partial)
+	}
+	collation := m["collation"].(bson.D).Map()
+	if collation["locale"] != "fr" || collation["strength"] != int32(2) {
+		t.Fatal(collation)
+	}
+}
+
+func TestGetCreateIndexScript(t *testing.T) {
+	o := IndexStatsDoc{Key: "{ a: 1, b: -1 }", Name: "a_1_b_-1", Unique: true}
+	str := getCreateIndexScript("keyhole.examples", o)
+	if str != `db.getSiblingDB("keyhole").getCollection("examples").createIndex({ "a": 1, "b": -1 }, { name: "a_1_b_-1", unique: true })` {
+		t.Fatal(str)
+	}
+}
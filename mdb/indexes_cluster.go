@@ -0,0 +1,215 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+// memberPingTimeout is the time to wait for a member of a cluster to respond
+const memberPingTimeout = 10 * time.Second
+
+// GetClusterIndexes list all indexes with accesses from all data bearing members of replica sets and shards
+func (ir *IndexesReader) GetClusterIndexes(uri string) (bson.M, error) {
+	return ir.GetClusterIndexesContext(context.Background(), uri)
+}
+
+// GetClusterIndexesContext list all indexes with accesses from all data bearing members of replica sets and
+// shards, stops when ctx is done.  Accesses from the primaries are read from the connected client and secondaries
+// are connected directly using credentials of uri.
+func (ir *IndexesReader) GetClusterIndexesContext(ctx context.Context, uri string) (bson.M, error) {
+	var err error
+	var indexesMap bson.M
+	var hosts []string
+	rp := ir.readPref // accesses of all members are merged, read from primaries first
+	ir.readPref = nil
+	indexesMap, err = ir.GetIndexesContext(ctx)
+	ir.readPref = rp
+	if err != nil {
+		return indexesMap, err
+	}
+	setPrimaryOps(indexesMap)
+	if hosts, err = getSecondaryHosts(ir.client, uri); err != nil {
+		return indexesMap, err
+	}
+	for _, host := range hosts {
+		if err = ctx.Err(); err != nil {
+			return indexesMap, err
+		}
+		if ir.verbose {
+			fmt.Println("reading $indexStats from", host)
+		}
+		if err = ir.addMemberIndexUsage(ctx, indexesMap, getDirectURI(uri, host)); err != nil {
+			return indexesMap, fmt.Errorf("%v: %v", host, err)
+		}
+	}
+	return indexesMap, err
+}
+
+// addMemberIndexUsage adds accesses of indexes of a member, connected directly
+func (ir *IndexesReader) addMemberIndexUsage(ctx context.Context, indexesMap bson.M, uri string) error {
+	var err error
+	var client *mongo.Client
+	if client, err = NewMongoClient(uri); err != nil {
+		return err
+	}
+	defer client.Disconnect(ctx)
+	pingCtx, cancel := context.WithTimeout(ctx, memberPingTimeout)
+	err = client.Ping(pingCtx, nil)
+	cancel()
+	if err != nil {
+		return err
+	}
+	for _, dbName := range getSortedKeys(indexesMap) {
+		collections, ok := indexesMap[dbName].(bson.M)
+		if ok == false {
+			continue
+		}
+		for _, collName := range getSortedKeys(collections) {
+			if err = ctx.Err(); err != nil {
+				return err
+			}
+			opCtx, cancel := withTimeout(ctx, ir.timeout)
+			indexStats, e := getIndexStats(opCtx, client.Database(dbName).Collection(collName))
+			cancel()
+			if e != nil { // collection of a sharded cluster may not exist on all shards
+				continue
+			}
+			collections[collName] = mergeIndexUsage(collections[collName].([]IndexStatsDoc), indexStats)
+		}
+	}
+	return err
+}
+
+// setPrimaryOps sets accesses read from primaries before accesses of other members are merged
+func setPrimaryOps(indexesMap bson.M) {
+	for _, val := range indexesMap {
+		collections, ok := val.(bson.M)
+		if ok == false {
+			continue
+		}
+		for _, v := range collections {
+			list, ok := v.([]IndexStatsDoc)
+			if ok == false {
+				continue
+			}
+			for i := range list {
+				ops := list[i].TotalOps
+				list[i].PrimaryOps = &ops
+			}
+		}
+	}
+}
+
+// mergeIndexUsage adds accesses of $indexStats results of another host, hosts already counted are skipped
+func mergeIndexUsage(list []IndexStatsDoc, indexStats []bson.M) []IndexStatsDoc {
+	for i := range list {
+		hosts := map[string]bool{}
+		for _, usage := range list[i].Usage {
+			hosts[usage.Host] = true
+		}
+		results := []bson.M{}
+		for _, result := range indexStats {
+			if hosts[toString(result["host"])] == false {
+				results = append(results, result)
+			}
+		}
+		addIndexUsage(&list[i], results)
+	}
+	return list
+}
+
+// isUnusedOnPrimary returns true if an index is only used by other members, e.g. by secondary reads
+func isUnusedOnPrimary(o IndexStatsDoc) bool {
+	return o.PrimaryOps != nil && *o.PrimaryOps == 0 && o.TotalOps > 0
+}
+
+// getSecondaryHosts returns hosts of secondaries of a replica set, or of all shards of a sharded cluster
+func getSecondaryHosts(client *mongo.Client, uri string) ([]string, error) {
+	var err error
+	var info ServerInfo
+	var uriList []string
+	if info, err = GetServerInfo(client); err != nil {
+		return nil, err
+	}
+	if info.Cluster != SHARDED {
+		return getReplSetSecondaries(client)
+	}
+	if uriList, err = GetShards(client, uri); err != nil {
+		return nil, err
+	}
+	hosts := []string{}
+	for _, shardURI := range uriList {
+		var shardClient *mongo.Client
+		if shardClient, err = NewMongoClient(shardURI); err != nil {
+			return hosts, err
+		}
+		secondaries, e := getReplSetSecondaries(shardClient)
+		shardClient.Disconnect(context.Background())
+		if e != nil {
+			return hosts, e
+		}
+		hosts = append(hosts, secondaries...)
+	}
+	return hosts, err
+}
+
+// getReplSetSecondaries returns hosts of secondaries from replSetGetStatus
+func getReplSetSecondaries(client *mongo.Client) ([]string, error) {
+	var err error
+	var status struct {
+		Members []struct {
+			Name  string `bson:"name"`
+			State int    `bson:"state"`
+		} `bson:"members"`
+	}
+	ctx := context.Background()
+	if err = client.Database("admin").RunCommand(ctx, bson.D{{Key: "replSetGetStatus", Value: 1}}).Decode(&status); err != nil {
+		if strings.Contains(err.Error(), "not running with --replSet") {
+			return []string{}, nil // standalone
+		}
+		return nil, err
+	}
+	hosts := []string{}
+	for _, member := range status.Members {
+		if member.State == 2 { // SECONDARY
+			hosts = append(hosts, member.Name)
+		}
+	}
+	if len(status.Members) == 0 {
+		return hosts, errors.New("no members found from replSetGetStatus")
+	}
+	return hosts, err
+}
+
+// getDirectURI returns a URI to connect to a host directly with credentials of uri
+func getDirectURI(uri string, host string) string {
+	isSRV := false
+	if strings.Index(uri, "mongodb+srv") == 0 {
+		isSRV = true
+		uri = strings.Replace(uri, "mongodb+srv", "mongodb", 1)
+	}
+	connString, _ := connstring.Parse(uri)
+	ruri := "mongodb://"
+	if connString.Username != "" {
+		ruri += connString.Username + ":" + connString.Password + "@"
+	}
+	ruri += host + "/?connect=direct"
+	if connString.AuthSource != "" {
+		ruri += "&authSource=" + connString.AuthSource
+	} else if isSRV == true {
+		ruri += "&authSource=admin"
+	}
+	if isSRV == true || connString.SSL == true {
+		ruri += "&ssl=true"
+	}
+	return ruri
+}
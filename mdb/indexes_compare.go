@@ -0,0 +1,162 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// IndexDiff holds an index of a namespace from two environments
+type IndexDiff struct {
+	Namespace    string `json:"namespace"`
+	Key          string `json:"key"`
+	Name         string `json:"name"`         // name in source
+	Options      string `json:"options"`      // options in source
+	OtherName    string `json:"otherName"`    // name in target
+	OtherOptions string `json:"otherOptions"` // options in target
+}
+
+// IndexesDiff holds differences of index definitions between a source and a target, e.g. production and staging
+type IndexesDiff struct {
+	Different []IndexDiff `json:"different"` // indexes of the same keys with different names or options
+	Extra     []IndexDiff `json:"extra"`     // indexes only in target
+	Missing   []IndexDiff `json:"missing"`   // indexes only in source
+}
+
+// LoadIndexesSnapshot reads indexes of a JSON file written from ToJSON
+func LoadIndexesSnapshot(filename string) (bson.M, error) {
+	var err error
+	var data []byte
+	var snapshot map[string]json.RawMessage
+	if data, err = ioutil.ReadFile(filename); err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	indexesMap := bson.M{}
+	for dbName, raw := range snapshot {
+		if dbName == indexesErrorsKey {
+			continue
+		}
+		var collections map[string][]IndexStatsDoc
+		if err = json.Unmarshal(raw, &collections); err != nil {
+			return nil, err
+		}
+		m := bson.M{}
+		for collName, list := range collections {
+			m[collName] = list
+		}
+		indexesMap[dbName] = m
+	}
+	return indexesMap, err
+}
+
+// LoadIndexes reads indexes of a database, or all databases if dbName is empty, from a snapshot JSON file
+// or a MongoDB URI
+func LoadIndexes(source string, dbName string) (bson.M, error) {
+	var err error
+	var client *mongo.Client
+	if strings.HasPrefix(source, "mongodb://") == false && strings.HasPrefix(source, "mongodb+srv://") == false {
+		return LoadIndexesSnapshot(source)
+	}
+	if client, err = NewMongoClient(source); err != nil {
+		return nil, err
+	}
+	defer client.Disconnect(context.Background())
+	ir := NewIndexesReader(client)
+	ir.SetDBName(dbName)
+	return ir.GetIndexes()
+}
+
+// CompareIndexes matches indexes by namespaces and keys, and returns indexes missing from, extra in, or
+// different in target
+func CompareIndexes(source bson.M, target bson.M) IndexesDiff {
+	diff := IndexesDiff{Different: []IndexDiff{}, Extra: []IndexDiff{}, Missing: []IndexDiff{}}
+	sources := getIndexesByKey(source)
+	targets := getIndexesByKey(target)
+	for key, s := range sources {
+		d := IndexDiff{Namespace: s.namespace, Key: s.index.Key, Name: s.index.Name, Options: getIndexOptionsString(s.index)}
+		t, ok := targets[key]
+		if ok == false {
+			diff.Missing = append(diff.Missing, d)
+			continue
+		}
+		d.OtherName = t.index.Name
+		d.OtherOptions = getIndexOptionsString(t.index)
+		if d.Name != d.OtherName || d.Options != d.OtherOptions {
+			diff.Different = append(diff.Different, d)
+		}
+	}
+	for key, t := range targets {
+		if _, ok := sources[key]; ok == false {
+			diff.Extra = append(diff.Extra, IndexDiff{Namespace: t.namespace, Key: t.index.Key,
+				OtherName: t.index.Name, OtherOptions: getIndexOptionsString(t.index)})
+		}
+	}
+	for _, list := range [][]IndexDiff{diff.Different, diff.Extra, diff.Missing} {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Namespace == list[j].Namespace {
+				return list[i].Key < list[j].Key
+			}
+			return list[i].Namespace < list[j].Namespace
+		})
+	}
+	return diff
+}
+
+type namespaceIndex struct {
+	namespace string
+	index     IndexStatsDoc
+}
+
+// getIndexesByKey returns indexes keyed by namespaces and keys without spaces
+func getIndexesByKey(indexesMap bson.M) map[string]namespaceIndex {
+	indexes := map[string]namespaceIndex{}
+	for dbName, val := range indexesMap {
+		collections, ok := val.(bson.M)
+		if ok == false {
+			continue
+		}
+		for collName, v := range collections {
+			list, ok := v.([]IndexStatsDoc)
+			if ok == false {
+				continue
+			}
+			ns := dbName + "." + collName
+			for _, o := range list {
+				indexes[ns+" "+strings.Replace(o.Key, " ", "", -1)] = namespaceIndex{namespace: ns, index: o}
+			}
+		}
+	}
+	return indexes
+}
+
+// GetSummary returns differences of indexes
+func (diff IndexesDiff) GetSummary() string {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("Indexes missing from target: %d\n", len(diff.Missing)))
+	for _, d := range diff.Missing {
+		buffer.WriteString(fmt.Sprintf("  - %s %s %s %s\n", d.Namespace, d.Name, d.Key, d.Options))
+	}
+	buffer.WriteString(fmt.Sprintf("Indexes only in target: %d\n", len(diff.Extra)))
+	for _, d := range diff.Extra {
+		buffer.WriteString(fmt.Sprintf("  + %s %s %s %s\n", d.Namespace, d.OtherName, d.Key, d.OtherOptions))
+	}
+	buffer.WriteString(fmt.Sprintf("Indexes of different names or options: %d\n", len(diff.Different)))
+	for _, d := range diff.Different {
+		buffer.WriteString(fmt.Sprintf("  ~ %s %s\n", d.Namespace, d.Key))
+		buffer.WriteString(fmt.Sprintf("      source: %s %s\n", d.Name, d.Options))
+		buffer.WriteString(fmt.Sprintf("      target: %s %s\n", d.OtherName, d.OtherOptions))
+	}
+	return buffer.String()
+}
@@ -0,0 +1,56 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCompareIndexes(t *testing.T) {
+	source := getTestIndexesMap()
+	target := bson.M{"keyhole": bson.M{"examples": []IndexStatsDoc{
+		{Key: "{ _id: 1 }", Name: "_id_", Fields: []string{"_id"}},
+		{Key: "{ a: 1, b: 1 }", Name: "a_1_b_1", Fields: []string{"a", "b"}},
+		{Key: "{ createdAt: 1 }", Name: "createdAt_1", Fields: []string{"createdAt"}},
+		{Key: "{ c: 1 }", Name: "c_1", Fields: []string{"c"}},
+	}}}
+	diff := CompareIndexes(source, target)
+	t.Log(diff.GetSummary())
+	if len(diff.Missing) != 1 || diff.Missing[0].Name != "a_1" {
+		t.Fatal(diff.Missing)
+	}
+	if len(diff.Extra) != 1 || diff.Extra[0].OtherName != "c_1" {
+		t.Fatal(diff.Extra)
+	}
+	// a_1_b_1 isn't unique and createdAt_1 isn't TTL in target
+	if len(diff.Different) != 2 || diff.Different[0].Key != "{ a: 1, b: 1 }" || diff.Different[0].Options != "unique: true" {
+		t.Fatal(diff.Different)
+	}
+}
+
+func TestLoadIndexesSnapshot(t *testing.T) {
+	ir := NewIndexesReader(nil)
+	ir.addError("keyhole.view", errors.New("Namespace keyhole.view is a view, not a collection"))
+	b, err := ir.ToJSON(getTestIndexesMap())
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := os.TempDir() + "/keyhole_indexes_snapshot.json"
+	if err = ioutil.WriteFile(filename, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(filename)
+	indexesMap, err := LoadIndexesSnapshot(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff := CompareIndexes(getTestIndexesMap(), indexesMap)
+	if len(diff.Missing) != 0 || len(diff.Extra) != 0 || len(diff.Different) != 0 {
+		t.Fatal(diff.GetSummary())
+	}
+}
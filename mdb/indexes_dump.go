@@ -0,0 +1,135 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetIndexesFromDump reads index definitions without cluster access from mongodump *.metadata.json(.gz) files
+// under a directory, or from a JSON array of collection infos with their indexes, e.g. exported by
+// db.getCollectionInfos().map(c => Object.assign(c, { indexes: db.getCollection(c.name).getIndexes() })).
+// Collection infos are of the database set by SetDBName, or named after the file if not set.
+func (ir *IndexesReader) GetIndexesFromDump(path string) (bson.M, error) {
+	var err error
+	var fi os.FileInfo
+	indexesMap := bson.M{}
+	if fi, err = os.Stat(path); err != nil {
+		return indexesMap, err
+	}
+	if fi.IsDir() == false {
+		dbName := ir.dbName
+		if dbName == "" {
+			dbName = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		}
+		return indexesMap, ir.readCollectionInfos(indexesMap, path, dbName)
+	}
+	err = filepath.Walk(path, func(filename string, info os.FileInfo, e error) error {
+		if e != nil {
+			return e
+		}
+		if info.IsDir() || (strings.HasSuffix(filename, ".metadata.json") == false && strings.HasSuffix(filename, ".metadata.json.gz") == false) {
+			return nil
+		}
+		dbName := filepath.Base(filepath.Dir(filename))
+		if ir.dbName != "" && ir.dbName != dbName {
+			return nil
+		}
+		return ir.readMetadata(indexesMap, filename, dbName)
+	})
+	return indexesMap, err
+}
+
+// readMetadata reads indexes of a collection from a metadata file of mongodump
+func (ir *IndexesReader) readMetadata(indexesMap bson.M, filename string, dbName string) error {
+	var err error
+	var data []byte
+	var doc bson.D
+	if data, err = readDumpFile(filename); err != nil {
+		return err
+	}
+	if err = bson.UnmarshalExtJSON(data, false, &doc); err != nil {
+		return fmt.Errorf("%v: %v", filename, err)
+	}
+	m := doc.Map()
+	collName := toString(m["collectionName"])
+	if collName == "" { // before 4.2, metadata.json is named after the collection
+		collName = strings.TrimSuffix(strings.TrimSuffix(filepath.Base(filename), ".gz"), ".metadata.json")
+	}
+	ir.addDumpIndexes(indexesMap, dbName, collName, m["indexes"])
+	return err
+}
+
+// readCollectionInfos reads indexes of collections from a JSON array of collection infos
+func (ir *IndexesReader) readCollectionInfos(indexesMap bson.M, filename string, dbName string) error {
+	var err error
+	var data []byte
+	var doc bson.D
+	if data, err = readDumpFile(filename); err != nil {
+		return err
+	}
+	// UnmarshalExtJSON requires a document at the top level
+	data = append(append([]byte(`{"infos": `), bytes.TrimSpace(data)...), '}')
+	if err = bson.UnmarshalExtJSON(data, false, &doc); err != nil {
+		return fmt.Errorf("%v: %v", filename, err)
+	}
+	infos, ok := doc.Map()["infos"].(primitive.A)
+	if ok == false {
+		return fmt.Errorf("%v: expected an array of collection infos", filename)
+	}
+	for _, info := range toDocs(infos) {
+		m := info.Map()
+		if ir.skipSystem == true && (strings.HasPrefix(toString(m["name"]), "system.") || (m["type"] != nil && toString(m["type"]) != "collection")) {
+			continue
+		}
+		indexes := m["indexes"]
+		if indexes == nil && m["idIndex"] != nil {
+			indexes = primitive.A{m["idIndex"]}
+		}
+		ir.addDumpIndexes(indexesMap, dbName, toString(m["name"]), indexes)
+	}
+	return err
+}
+
+// addDumpIndexes adds indexes of a collection and marks redundant ones
+func (ir *IndexesReader) addDumpIndexes(indexesMap bson.M, dbName string, collName string, indexes interface{}) {
+	list := []IndexStatsDoc{}
+	for _, idx := range toDocs(indexes) {
+		if o, _ := getIndexStatsDoc(idx); len(o.Fields) > 0 { // usage is unknown, nil
+			list = append(list, o)
+		}
+	}
+	setDuppedIndexes(list)
+	if _, ok := indexesMap[dbName]; ok == false {
+		indexesMap[dbName] = bson.M{}
+	}
+	indexesMap[dbName].(bson.M)[collName] = list
+}
+
+// readDumpFile reads a file, gunzipped if it ends with .gz
+func readDumpFile(filename string) ([]byte, error) {
+	var err error
+	var file *os.File
+	if strings.HasSuffix(filename, ".gz") == false {
+		return ioutil.ReadFile(filename)
+	}
+	if file, err = os.Open(filename); err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var reader *gzip.Reader
+	if reader, err = gzip.NewReader(file); err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
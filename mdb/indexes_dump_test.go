@@ -0,0 +1,68 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGetIndexesFromDump(t *testing.T) {
+	ir := NewIndexesReader(nil)
+	indexesMap, err := ir.GetIndexesFromDump("testdata/dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := indexesMap["keyhole"].(bson.M)["examples"].([]IndexStatsDoc)
+	if len(list) != 5 {
+		t.Fatal(list)
+	}
+	for _, o := range list {
+		if (o.Name == "a_1") != o.IsDupped {
+			t.Fatal(o)
+		}
+		if o.Name == "createdAt_1" && (o.ExpireAfterSeconds == nil || *o.ExpireAfterSeconds != 3600) {
+			t.Fatal(o)
+		}
+		if o.Name == "a_1_c_-1" && (o.Unique == false || o.Key != "{ a: 1, c: -1 }") {
+			t.Fatal(o)
+		}
+	}
+}
+
+func TestGetIndexesFromCollectionInfos(t *testing.T) {
+	ir := NewIndexesReader(nil)
+	ir.SetDBName("dealership")
+	indexesMap, err := ir.GetIndexesFromDump("testdata/collinfos.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	collections := indexesMap["dealership"].(bson.M)
+	if len(collections) != 2 || len(collections["dealers"].([]IndexStatsDoc)) != 1 {
+		t.Fatal(collections)
+	}
+	for _, o := range collections["cars"].([]IndexStatsDoc) {
+		if (o.Name == "color_1") != o.IsDupped {
+			t.Fatal(o)
+		}
+		if o.Name == "brand_1" && o.PartialFilterExpression != "{year: {$gt: 2010}}" {
+			t.Fatal(o.PartialFilterExpression)
+		}
+	}
+}
+
+func TestIsUnusedIndexOffline(t *testing.T) {
+	o := IndexStatsDoc{Key: "{ a: 1 }", Name: "a_1", Fields: []string{"a"}}
+	if isUnusedIndex(o) == true {
+		t.Fatal("usage of an index read offline is unknown")
+	}
+	o.Usage = []UsageDoc{}
+	if isUnusedIndex(o) == false {
+		t.Fatal("expected unused")
+	}
+	o.MemberOps = true
+	if isUnusedIndex(o) == true {
+		t.Fatal("usage of a member isn't of the cluster")
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ESRViolation is an index used by an ops pattern, whose keys order doesn't follow the equality, sort,
+// and range rule of the pattern
+type ESRViolation struct {
+	Command       string `json:"command"`
+	Filter        string `json:"filter"` // query pattern
+	Index         string `json:"index"`  // index keys
+	IndexName     string `json:"indexName"`
+	InMemorySorts int    `json:"inMemorySorts"`
+	Namespace     string `json:"namespace"`
+	Ops           int    `json:"ops"`
+	Reason        string `json:"reason"`
+	SuggestedKey  string `json:"suggestedKey"`
+}
+
+// GetESRViolations returns indexes of indexesMap used by ops patterns of loginfo or system.profile results,
+// whose keys order prevents covering the sort or bounding equality fields, the most ops first
+func (ir *IndexesReader) GetESRViolations(indexesMap bson.M, li *LogInfo) []ESRViolation {
+	violations := []ESRViolation{}
+	for _, doc := range li.OpsPatterns {
+		if doc.Index == "" || doc.Scan == COLLSCAN {
+			continue
+		}
+		o, ok := findIndexByKey(indexesMap, doc.Namespace, doc.Index)
+		if ok == false {
+			continue
+		}
+		keys, ok := getESRKeys(doc.Filter, doc.Sort)
+		if ok == false {
+			continue
+		}
+		reason := getESRViolationReason(o.Fields, keys)
+		if reason == "" {
+			continue
+		}
+		violations = append(violations, ESRViolation{Command: doc.Command, Filter: doc.Filter, Index: o.Key, IndexName: o.Name,
+			InMemorySorts: doc.InMemorySorts, Namespace: doc.Namespace, Ops: doc.Count, Reason: reason,
+			SuggestedKey: "{" + strings.Join(getESRFields(doc.Filter, doc.Sort), ", ") + "}"})
+	}
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].Ops > violations[j].Ops })
+	return violations
+}
+
+// findIndexByKey returns an index of a namespace by keys of a plan summary, e.g. { a: 1, b: -1 }
+func findIndexByKey(indexesMap bson.M, namespace string, key string) (IndexStatsDoc, bool) {
+	idx := strings.Index(namespace, ".")
+	if idx < 0 {
+		return IndexStatsDoc{}, false
+	}
+	collections, ok := indexesMap[namespace[:idx]].(bson.M)
+	if ok == false {
+		return IndexStatsDoc{}, false
+	}
+	list, ok := collections[namespace[idx+1:]].([]IndexStatsDoc)
+	if ok == false {
+		return IndexStatsDoc{}, false
+	}
+	key = strings.Replace(key, " ", "", -1)
+	for _, o := range list {
+		if strings.Replace(o.Key, " ", "", -1) == key {
+			return o, true
+		}
+	}
+	return IndexStatsDoc{}, false
+}
+
+// getESRViolationReason returns why fields of an index are out of order of a query pattern, empty if they
+// aren't.  Fields after the first one not in the query pattern can't bound the scan and are ignored.
+func getESRViolationReason(fields []string, keys esrKeys) string {
+	rangeField := ""
+	for _, field := range fields {
+		if contains(keys.equalities, field) || contains(keys.groupFields, field) {
+			if rangeField != "" {
+				return fmt.Sprintf("range field %v before equality field %v", rangeField, field)
+			}
+		} else if isSortField(keys.sortFields, field) {
+			if rangeField != "" {
+				return fmt.Sprintf("range field %v before sort field %v, sort in memory", rangeField, field)
+			}
+		} else if contains(keys.ranges, field) {
+			if rangeField == "" {
+				rangeField = field
+			}
+		} else {
+			break
+		}
+	}
+	return ""
+}
+
+// isSortField returns true if a field is in sort fields with directions, e.g. year: -1
+func isSortField(sortFields []string, field string) bool {
+	for _, s := range sortFields {
+		if strings.HasPrefix(s, field+": ") {
+			return true
+		}
+	}
+	return false
+}
+
+// GetESRViolationsSummary returns indexes out of order of ops patterns grouped by namespaces
+func GetESRViolationsSummary(violations []ESRViolation) string {
+	lines := []string{}
+	for _, v := range violations {
+		lines = append(lines, fmt.Sprintf("%s %s %s, %d ops, in-memory sorts: %d", v.Command, v.Namespace, v.Filter, v.Ops, v.InMemorySorts))
+		lines = append(lines, fmt.Sprintf("    index %s %s: %s", v.IndexName, v.Index, v.Reason))
+		lines = append(lines, fmt.Sprintf("    suggested: %s", getCreateIndexCommand(v.Namespace, v.SuggestedKey)))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,40 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGetESRViolations(t *testing.T) {
+	indexesMap := bson.M{"keyhole": bson.M{"cars": []IndexStatsDoc{
+		{Key: "{ color: 1, year: 1, brand: 1 }", Name: "color_1_year_1_brand_1", Fields: []string{"color", "year", "brand"}},
+		{Key: "{ color: 1, brand: 1, year: 1 }", Name: "color_1_brand_1_year_1", Fields: []string{"color", "brand", "year"}},
+		{Key: "{ year: 1, color: 1 }", Name: "year_1_color_1", Fields: []string{"year", "color"}},
+	}}}
+	li := &LogInfo{OpsPatterns: []OpPerformanceDoc{
+		{Command: "find", Namespace: "keyhole.cars", Filter: "{brand: 1, color: 1, year: {$gt: 1}}", Sort: "{brand: 1}",
+			Index: "{ color: 1, year: 1, brand: 1 }", Count: 10, InMemorySorts: 10},
+		{Command: "find", Namespace: "keyhole.cars", Filter: "{brand: 1, color: 1, year: {$gt: 1}}", Sort: "{brand: 1}",
+			Index: "{ color: 1, brand: 1, year: 1 }", Count: 20},
+		{Command: "find", Namespace: "keyhole.cars", Filter: "{color: 1, year: {$gte: 1}}",
+			Index: "{ year: 1, color: 1 }", Count: 30},
+	}}
+	ir := NewIndexesReader(nil)
+	violations := ir.GetESRViolations(indexesMap, li)
+	t.Log(GetESRViolationsSummary(violations))
+	if len(violations) != 2 {
+		t.Fatal(violations)
+	}
+	if violations[0].IndexName != "year_1_color_1" || strings.Contains(violations[0].Reason, "equality field color") == false ||
+		violations[0].SuggestedKey != "{color: 1, year: 1}" {
+		t.Fatal(violations[0])
+	}
+	if violations[1].IndexName != "color_1_year_1_brand_1" || strings.Contains(violations[1].Reason, "sort field brand") == false ||
+		violations[1].SuggestedKey != "{color: 1, brand: 1, year: 1}" {
+		t.Fatal(violations[1])
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// indexesErrorsKey is the field of errors of namespaces in JSON of indexes, not a name of a database because
+// database names can't have $
+const indexesErrorsKey = "$errors"
+
+// ToJSON returns indexes of databases as JSON, i.e. {db: {collection: [indexes]}}, and errors of namespaces in
+// $errors if any
+func (ir *IndexesReader) ToJSON(indexesMap bson.M) ([]byte, error) {
+	return json.MarshalIndent(ir.GetIndexesWithErrors(indexesMap), "", "  ")
+}
+
+// GetIndexesWithErrors returns indexes of databases and errors of namespaces in $errors, indexes are returned as
+// is without errors
+func (ir *IndexesReader) GetIndexesWithErrors(indexesMap bson.M) bson.M {
+	errs := ir.GetErrors()
+	if len(errs) == 0 {
+		return indexesMap
+	}
+	m := bson.M{indexesErrorsKey: errs}
+	for dbName, collections := range indexesMap {
+		m[dbName] = collections
+	}
+	return m
+}
+
+// LogErrors logs errors of namespaces whose indexes or stats couldn't be read
+func (ir *IndexesReader) LogErrors() {
+	for _, e := range ir.GetErrors() {
+		log.Println(e.Namespace+":", e.Error)
+	}
+}
+
+// ToCSV returns indexes as CSV, a row per index
+func (ir *IndexesReader) ToCSV(indexesMap bson.M) ([]byte, error) {
+	var err error
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	writer.Write([]string{"database", "collection", "name", "key", "shardKey", "dupped", "unused", "unusedOnPrimary", "totalOps", "size", "cacheBytes",
+		"unique", "sparse", "expireAfterSeconds", "partialFilterExpression", "collation", "hidden", "wildcardProjection", "selectivity", "lowSelectivity"})
+	for _, dbName := range getSortedKeys(indexesMap) {
+		collections, ok := indexesMap[dbName].(bson.M)
+		if ok == false {
+			continue
+		}
+		for _, collName := range getSortedKeys(collections) {
+			list, ok := collections[collName].([]IndexStatsDoc)
+			if ok == false {
+				continue
+			}
+			for _, o := range list {
+				ttl := ""
+				if o.ExpireAfterSeconds != nil {
+					ttl = fmt.Sprintf("%d", *o.ExpireAfterSeconds)
+				}
+				selectivity := ""
+				if o.Selectivity != nil {
+					selectivity = fmt.Sprintf("%v", *o.Selectivity)
+				}
+				writer.Write([]string{dbName, collName, o.Name, o.Key, fmt.Sprintf("%v", o.IsShardKey), fmt.Sprintf("%v", o.IsDupped),
+					fmt.Sprintf("%v", isUnusedIndex(o)), fmt.Sprintf("%v", isUnusedOnPrimary(o)), fmt.Sprintf("%d", o.TotalOps), fmt.Sprintf("%d", o.Size), fmt.Sprintf("%d", o.CacheBytes), fmt.Sprintf("%v", o.Unique), fmt.Sprintf("%v", o.Sparse),
+					ttl, o.PartialFilterExpression, o.Collation, fmt.Sprintf("%v", o.Hidden), o.WildcardProjection,
+					selectivity, fmt.Sprintf("%v", o.IsLowSelectivity)})
+			}
+		}
+	}
+	writer.Flush()
+	if err = writer.Error(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), err
+}
+
+// isUnusedIndex returns true if an index has no ops since stats collected, _id and shard key indexes are required,
+// usage of indexes read offline is unknown, and usage of a member isn't of the cluster
+func isUnusedIndex(o IndexStatsDoc) bool {
+	return o.Usage != nil && o.MemberOps == false && o.TotalOps == 0 && o.Key != "{ _id: 1 }" && o.IsShardKey == false
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func getTestIndexesMap() bson.M {
+	seconds := 3600
+	list := []IndexStatsDoc{
+		{Key: "{ _id: 1 }", Name: "_id_", Fields: []string{"_id"}, TotalOps: 10},
+		{Key: "{ a: 1 }", Name: "a_1", Fields: []string{"a"}, IsDupped: true},
+		{Key: "{ a: 1, b: 1 }", Name: "a_1_b_1", Fields: []string{"a", "b"}, TotalOps: 5, Unique: true},
+		{Key: "{ createdAt: 1 }", Name: "createdAt_1", Fields: []string{"createdAt"}, ExpireAfterSeconds: &seconds},
+	}
+	for i := range list {
+		list[i].Usage = []UsageDoc{}
+	}
+	return bson.M{"keyhole": bson.M{"examples": list}}
+}
+
+func TestIndexesToJSON(t *testing.T) {
+	ir := NewIndexesReader(nil)
+	b, err := ir.ToJSON(getTestIndexesMap())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]map[string][]IndexStatsDoc
+	if err = json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	list := doc["keyhole"]["examples"]
+	if len(list) != 4 || list[1].IsDupped == false || list[2].Unique == false || *list[3].ExpireAfterSeconds != 3600 {
+		t.Fatal(string(b))
+	}
+}
+
+func TestIndexesToJSONErrors(t *testing.T) {
+	ir := NewIndexesReader(nil)
+	ir.addError("keyhole.view", errors.New("Namespace keyhole.view is a view, not a collection"))
+	b, err := ir.ToJSON(getTestIndexesMap())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Errors  []CollectionError          `json:"$errors"`
+		Keyhole map[string][]IndexStatsDoc `json:"keyhole"`
+	}
+	if err = json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Errors) != 1 || doc.Errors[0].Namespace != "keyhole.view" || len(doc.Keyhole["examples"]) != 4 {
+		t.Fatal(string(b))
+	}
+}
+
+func TestIndexesToCSV(t *testing.T) {
+	ir := NewIndexesReader(nil)
+	b, err := ir.ToCSV(getTestIndexesMap())
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(strings.NewReader(string(b))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 5 {
+		t.Fatal(string(b))
+	}
+	for i, name := range []string{"_id_", "a_1", "a_1_b_1", "createdAt_1"} {
+		if records[i+1][2] != name {
+			t.Fatal(records[i+1])
+		}
+	}
+	if records[1][6] != "false" || records[2][5] != "true" || records[2][6] != "true" || records[4][13] != "3600" {
+		t.Fatal(string(b))
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// hiddenIndexesCollection keeps accesses of indexes when they were hidden
+const hiddenIndexesCollection = "hiddenIndexes"
+
+// HiddenIndexDoc is a hidden index and its accesses since it was hidden by HideIndex
+type HiddenIndexDoc struct {
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Key       string    `json:"key"`
+	HiddenAt  time.Time `json:"hiddenAt"`  // zero if not hidden by HideIndex
+	HiddenOps int       `json:"hiddenOps"` // accesses when hidden
+	Ops       int       `json:"ops"`       // accesses now
+	Status    string    `json:"status"`
+}
+
+// hiddenIndexRecord is kept in _KEYHOLE_.hiddenIndexes when an index is hidden
+type hiddenIndexRecord struct {
+	ID        string    `bson:"_id"`
+	Namespace string    `bson:"namespace"`
+	Name      string    `bson:"name"`
+	HiddenAt  time.Time `bson:"hiddenAt"`
+	Ops       int       `bson:"ops"`
+	Since     time.Time `bson:"since"` // the earliest accesses.since of hosts
+}
+
+// HideIndex hides an index of a namespace (4.4+) and keeps its accesses to compare later, a hidden index
+// is maintained but not used by queries, and can be unhidden without a rebuild
+func (ir *IndexesReader) HideIndex(namespace string, name string) error {
+	var err error
+	var collection *mongo.Collection
+	var indexStats []bson.M
+	ctx := context.Background()
+	if collection, err = ir.getCollection(namespace); err != nil {
+		return err
+	}
+	if indexStats, err = getIndexStats(ctx, collection); err != nil {
+		return err
+	}
+	o := IndexStatsDoc{Name: name}
+	addIndexUsage(&o, indexStats)
+	if len(o.Usage) == 0 {
+		return fmt.Errorf("index %v not found in %v", name, namespace)
+	}
+	if err = setIndexHidden(ctx, collection, name, true); err != nil {
+		return err
+	}
+	record := hiddenIndexRecord{ID: namespace + ":" + name, Namespace: namespace, Name: name, HiddenAt: time.Now(),
+		Ops: o.TotalOps, Since: getEarliestSince(o.Usage)}
+	opts := options.Replace().SetUpsert(true)
+	_, err = ir.client.Database(KEYHOLEDB).Collection(hiddenIndexesCollection).ReplaceOne(ctx, bson.M{"_id": record.ID}, record, opts)
+	return err
+}
+
+// UnhideIndex unhides an index of a namespace
+func (ir *IndexesReader) UnhideIndex(namespace string, name string) error {
+	var err error
+	var collection *mongo.Collection
+	ctx := context.Background()
+	if collection, err = ir.getCollection(namespace); err != nil {
+		return err
+	}
+	if err = setIndexHidden(ctx, collection, name, false); err != nil {
+		return err
+	}
+	_, err = ir.client.Database(KEYHOLEDB).Collection(hiddenIndexesCollection).DeleteOne(ctx, bson.M{"_id": namespace + ":" + name})
+	return err
+}
+
+// GetHiddenIndexes returns hidden indexes of indexesMap, and if their accesses changed since hidden by HideIndex
+func (ir *IndexesReader) GetHiddenIndexes(indexesMap bson.M) ([]HiddenIndexDoc, error) {
+	var err error
+	var cur *mongo.Cursor
+	ctx := context.Background()
+	records := map[string]hiddenIndexRecord{}
+	if cur, err = ir.client.Database(KEYHOLEDB).Collection(hiddenIndexesCollection).Find(ctx, bson.M{}); err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var record hiddenIndexRecord
+		if err = cur.Decode(&record); err != nil {
+			return nil, err
+		}
+		records[record.ID] = record
+	}
+	return getHiddenIndexDocs(indexesMap, records), cur.Err()
+}
+
+// getHiddenIndexDocs matches hidden indexes with records of HideIndex
+func getHiddenIndexDocs(indexesMap bson.M, records map[string]hiddenIndexRecord) []HiddenIndexDoc {
+	docs := []HiddenIndexDoc{}
+	for _, dbName := range getSortedKeys(indexesMap) {
+		collections, ok := indexesMap[dbName].(bson.M)
+		if ok == false {
+			continue
+		}
+		for _, collName := range getSortedKeys(collections) {
+			ns := dbName + "." + collName
+			for _, o := range collections[collName].([]IndexStatsDoc) {
+				if o.Hidden == false {
+					continue
+				}
+				doc := HiddenIndexDoc{Namespace: ns, Name: o.Name, Key: o.Key, Ops: o.TotalOps}
+				record, ok := records[ns+":"+o.Name]
+				if ok == true {
+					doc.HiddenAt, doc.HiddenOps = record.HiddenAt, record.Ops
+				}
+				if ok == false {
+					doc.Status = "hidden outside of keyhole, accesses unknown"
+				} else if getEarliestSince(o.Usage).After(record.Since) {
+					doc.Status = "counters reset by restarts since hidden"
+				} else if o.TotalOps != record.Ops {
+					doc.Status = fmt.Sprintf("used %d times since hidden, check before dropping", o.TotalOps-record.Ops)
+				} else {
+					doc.Status = fmt.Sprintf("unused since hidden for %v", time.Since(record.HiddenAt).Round(time.Minute))
+				}
+				docs = append(docs, doc)
+			}
+		}
+	}
+	return docs
+}
+
+// getEarliestSince returns the earliest accesses.since of hosts
+func getEarliestSince(usage []UsageDoc) time.Time {
+	var since time.Time
+	for _, u := range usage {
+		if since.IsZero() || u.Accesses.Since.Before(since) {
+			since = u.Accesses.Since
+		}
+	}
+	return since
+}
+
+// setIndexHidden runs collMod to hide or unhide an index
+func setIndexHidden(ctx context.Context, collection *mongo.Collection, name string, hidden bool) error {
+	cmd := bson.D{{Key: "collMod", Value: collection.Name()},
+		{Key: "index", Value: bson.D{{Key: "name", Value: name}, {Key: "hidden", Value: hidden}}}}
+	return collection.Database().RunCommand(ctx, cmd).Err()
+}
+
+// getCollection returns a collection of a namespace
+func (ir *IndexesReader) getCollection(namespace string) (*mongo.Collection, error) {
+	idx := strings.Index(namespace, ".")
+	if idx <= 0 || idx == len(namespace)-1 {
+		return nil, fmt.Errorf("invalid namespace %v", namespace)
+	}
+	return ir.client.Database(namespace[:idx]).Collection(namespace[idx+1:]), nil
+}
+
+// GetHiddenIndexesSummary returns hidden indexes and their accesses since hidden
+func GetHiddenIndexesSummary(docs []HiddenIndexDoc) string {
+	var buffer bytes.Buffer
+	if len(docs) == 0 {
+		return "No hidden indexes found"
+	}
+	for _, doc := range docs {
+		buffer.WriteString(fmt.Sprintf("%s %s %s\n\t%s\n", doc.Namespace, doc.Name, doc.Key, doc.Status))
+	}
+	return buffer.String()
+}
@@ -0,0 +1,41 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGetHiddenIndexDocs(t *testing.T) {
+	restarted := time.Now().Add(-24 * time.Hour)
+	usage := func(ops int, since time.Time) []UsageDoc {
+		return []UsageDoc{{Host: "primary:27017", Accesses: AccessesDoc{Ops: ops, Since: since}}}
+	}
+	indexesMap := bson.M{"keyhole": bson.M{"examples": []IndexStatsDoc{
+		{Key: "{ a: 1 }", Name: "a_1", Hidden: true, TotalOps: 10, Usage: usage(10, restarted)},
+		{Key: "{ b: 1 }", Name: "b_1", Hidden: true, TotalOps: 12, Usage: usage(12, restarted)},
+		{Key: "{ c: 1 }", Name: "c_1", Hidden: true, TotalOps: 0, Usage: usage(0, time.Now())},
+		{Key: "{ d: 1 }", Name: "d_1", Hidden: true, Usage: usage(0, restarted)},
+		{Key: "{ e: 1 }", Name: "e_1", TotalOps: 5, Usage: usage(5, restarted)},
+	}}}
+	hiddenAt := time.Now().Add(-2 * time.Hour)
+	records := map[string]hiddenIndexRecord{
+		"keyhole.examples:a_1": {Namespace: "keyhole.examples", Name: "a_1", HiddenAt: hiddenAt, Ops: 10, Since: restarted},
+		"keyhole.examples:b_1": {Namespace: "keyhole.examples", Name: "b_1", HiddenAt: hiddenAt, Ops: 10, Since: restarted},
+		"keyhole.examples:c_1": {Namespace: "keyhole.examples", Name: "c_1", HiddenAt: hiddenAt, Ops: 3, Since: restarted},
+	}
+	docs := getHiddenIndexDocs(indexesMap, records)
+	t.Log(GetHiddenIndexesSummary(docs))
+	if len(docs) != 4 {
+		t.Fatal(docs)
+	}
+	for i, prefix := range []string{"unused since hidden", "used 2 times", "counters reset", "hidden outside"} {
+		if strings.HasPrefix(docs[i].Status, prefix) == false {
+			t.Fatal(docs[i])
+		}
+	}
+}
@@ -0,0 +1,335 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RollingIndexBuilder builds an index on members of a replica set one at a time, secondaries first and then
+// the primary after it steps down.  Each member is restarted as a standalone by an operator, the index is
+// built on it, and the next member starts after it rejoins and catches up.
+type RollingIndexBuilder struct {
+	caFile         string
+	client         *mongo.Client
+	clientPEMFile  string
+	dryRun         bool
+	interval       time.Duration // of polling members and progress
+	maxLag         time.Duration
+	standalonePort int
+	timeout        time.Duration // of waiting for a member to restart
+	uri            string
+	verbose        bool
+}
+
+// replSetMember is a member from replSetGetStatus
+type replSetMember struct {
+	Name       string    `bson:"name"`
+	State      int       `bson:"state"`
+	StateStr   string    `bson:"stateStr"`
+	OptimeDate time.Time `bson:"optimeDate"`
+}
+
+// indexBuildOp is an index build from currentOp
+type indexBuildOp struct {
+	Msg      string `bson:"msg"`
+	Progress struct {
+		Done  int `bson:"done"`
+		Total int `bson:"total"`
+	} `bson:"progress"`
+}
+
+// NewRollingIndexBuilder returns a RollingIndexBuilder of a replica set
+func NewRollingIndexBuilder(client *mongo.Client, uri string) *RollingIndexBuilder {
+	return &RollingIndexBuilder{client: client, interval: 10 * time.Second, maxLag: 10 * time.Second,
+		timeout: time.Hour, uri: uri}
+}
+
+// SetDryRun sets to print steps without running them
+func (rb *RollingIndexBuilder) SetDryRun(dryRun bool) {
+	rb.dryRun = dryRun
+}
+
+// SetMaxLag sets replication lag a member has to catch up within before the next member starts
+func (rb *RollingIndexBuilder) SetMaxLag(maxLag time.Duration) {
+	rb.maxLag = maxLag
+}
+
+// SetStandalonePort sets port members are restarted on as standalones, the same port if 0.  A different
+// port keeps applications away from a member under maintenance.
+func (rb *RollingIndexBuilder) SetStandalonePort(port int) {
+	rb.standalonePort = port
+}
+
+// SetTimeout sets time to wait for a member to restart
+func (rb *RollingIndexBuilder) SetTimeout(timeout time.Duration) {
+	rb.timeout = timeout
+}
+
+// SetTLSFiles sets a CA file and a client PEM key file of direct connections to members
+func (rb *RollingIndexBuilder) SetTLSFiles(caFile string, clientPEMFile string) {
+	rb.caFile, rb.clientPEMFile = caFile, clientPEMFile
+}
+
+// SetVerbose sets verbose level
+func (rb *RollingIndexBuilder) SetVerbose(verbose bool) {
+	rb.verbose = verbose
+}
+
+// BuildIndex builds an index of keys, e.g. {a: 1, b: -1}, of a namespace on members one at a time
+func (rb *RollingIndexBuilder) BuildIndex(namespace string, key string) error {
+	var err error
+	var keys bson.D
+	var members []replSetMember
+	idx := strings.Index(namespace, ".")
+	if idx <= 0 || idx == len(namespace)-1 {
+		return fmt.Errorf("invalid namespace %v", namespace)
+	}
+	if keys, err = parseShellDoc(key); err != nil {
+		return err
+	}
+	for i, elem := range keys {
+		keys[i].Value = toIndexOptionValue(elem.Value)
+	}
+	spec := bson.D{{Key: "key", Value: keys}, {Key: "name", Value: getIndexName(keys)}}
+	if members, err = rb.getMembers(); err != nil {
+		return err
+	}
+	secondaries, primary := getRollingPlan(members)
+	if primary == "" {
+		return errors.New("no primary found")
+	}
+	hosts := append(secondaries, primary)
+	for i, host := range hosts {
+		progress := fmt.Sprintf("[%d/%d] %s", i+1, len(hosts), host)
+		if rb.dryRun == true {
+			if host == primary {
+				fmt.Println(progress, "step down the primary")
+			}
+			fmt.Println(progress, "restart as a standalone on", getStandaloneHost(host, rb.standalonePort))
+			fmt.Println(progress, "db.getSiblingDB(\""+namespace[:idx]+"\").runCommand("+getShapeString(
+				bson.D{{Key: "createIndexes", Value: namespace[idx+1:]}, {Key: "indexes", Value: bson.A{spec}}})+")")
+			fmt.Println(progress, "restart as a member of the replica set and wait for it to catch up")
+			continue
+		}
+		if host == primary {
+			if err = rb.stepDown(progress, host); err != nil {
+				return err
+			}
+		}
+		if err = rb.buildOnMember(progress, host, namespace, spec); err != nil {
+			return fmt.Errorf("%v: %v", host, err)
+		}
+	}
+	return err
+}
+
+// buildOnMember waits for a member to restart as a standalone, builds the index, and waits for the member
+// to rejoin the replica set and catch up
+func (rb *RollingIndexBuilder) buildOnMember(progress string, host string, namespace string, spec bson.D) error {
+	var err error
+	var client *mongo.Client
+	standalone := getStandaloneHost(host, rb.standalonePort)
+	fmt.Println(progress, "restart as a standalone, without --replSet, on", standalone)
+	if client, err = rb.waitForStandalone(standalone); err != nil {
+		return err
+	}
+	defer client.Disconnect(context.Background())
+	fmt.Println(progress, "building index", getShapeString(spec))
+	t := time.Now()
+	if err = rb.createIndex(client, namespace, spec); err != nil {
+		return err
+	}
+	fmt.Println(progress, "built in", time.Since(t).Round(time.Second))
+	fmt.Println(progress, "restart as a member of the replica set")
+	return rb.waitForMember(progress, host)
+}
+
+// waitForStandalone returns a client of a host once it runs as a standalone
+func (rb *RollingIndexBuilder) waitForStandalone(host string) (*mongo.Client, error) {
+	var err error
+	var client *mongo.Client
+	if client, err = NewMongoClient(getDirectURI(rb.uri, host), rb.caFile, rb.clientPEMFile); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(rb.timeout)
+	for time.Now().Before(deadline) {
+		var doc bson.M
+		ctx, cancel := context.WithTimeout(context.Background(), memberPingTimeout)
+		err = client.Database("admin").RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&doc)
+		cancel()
+		if err == nil && doc["setName"] == nil {
+			return client, nil
+		}
+		if rb.verbose == true {
+			fmt.Println("waiting for", host, "to run as a standalone")
+		}
+		time.Sleep(rb.interval)
+	}
+	client.Disconnect(context.Background())
+	return nil, fmt.Errorf("%v didn't run as a standalone in %v", host, rb.timeout)
+}
+
+// createIndex runs createIndexes on a standalone and prints progress of the build from currentOp
+func (rb *RollingIndexBuilder) createIndex(client *mongo.Client, namespace string, spec bson.D) error {
+	idx := strings.Index(namespace, ".")
+	cmd := bson.D{{Key: "createIndexes", Value: namespace[idx+1:]}, {Key: "indexes", Value: bson.A{spec}}}
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Database(namespace[:idx]).RunCommand(context.Background(), cmd).Err()
+	}()
+	ticker := time.NewTicker(rb.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-ticker.C:
+			var result struct {
+				Inprog []indexBuildOp `bson:"inprog"`
+			}
+			filter := bson.D{{Key: "currentOp", Value: 1}, {Key: "msg", Value: bson.D{{Key: "$regex", Value: "^Index Build"}}}}
+			if err := client.Database("admin").RunCommand(context.Background(), filter).Decode(&result); err == nil {
+				fmt.Println(getIndexBuildProgress(result.Inprog))
+			}
+		}
+	}
+}
+
+// waitForMember waits for a member to be a secondary and catch up within the max lag
+func (rb *RollingIndexBuilder) waitForMember(progress string, host string) error {
+	var err error
+	var members []replSetMember
+	deadline := time.Now().Add(rb.timeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(rb.interval)
+		if members, err = rb.getMembers(); err != nil {
+			if rb.verbose == true {
+				fmt.Println(progress, err)
+			}
+			continue
+		}
+		lag, state := getReplicationLag(members, host)
+		if state == "SECONDARY" && lag <= rb.maxLag {
+			fmt.Println(progress, "caught up, lag:", lag)
+			return nil
+		}
+		fmt.Println(progress, "state:", state, "lag:", lag)
+	}
+	return fmt.Errorf("%v didn't catch up in %v", host, rb.timeout)
+}
+
+// stepDown steps down the primary and waits for another member to be elected
+func (rb *RollingIndexBuilder) stepDown(progress string, host string) error {
+	var err error
+	var members []replSetMember
+	fmt.Println(progress, "stepping down")
+	cmd := bson.D{{Key: "replSetStepDown", Value: 60}}
+	if err = rb.client.Database("admin").RunCommand(context.Background(), cmd).Err(); err != nil && rb.verbose == true {
+		fmt.Println(progress, err) // connections are closed by stepdown
+	}
+	deadline := time.Now().Add(rb.timeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(rb.interval)
+		if members, err = rb.getMembers(); err != nil {
+			continue
+		}
+		if _, primary := getRollingPlan(members); primary != "" && primary != host {
+			fmt.Println(progress, "stepped down,", primary, "is the primary")
+			return nil
+		}
+	}
+	return fmt.Errorf("no other primary elected in %v", rb.timeout)
+}
+
+// getMembers returns members from replSetGetStatus
+func (rb *RollingIndexBuilder) getMembers() ([]replSetMember, error) {
+	var status struct {
+		Members []replSetMember `bson:"members"`
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), memberPingTimeout)
+	defer cancel()
+	err := rb.client.Database("admin").RunCommand(ctx, bson.D{{Key: "replSetGetStatus", Value: 1}}).Decode(&status)
+	return status.Members, err
+}
+
+// getRollingPlan returns secondaries sorted by names and the primary, arbiters and members of other states
+// are excluded
+func getRollingPlan(members []replSetMember) ([]string, string) {
+	primary := ""
+	secondaries := []string{}
+	for _, member := range members {
+		if member.State == 1 {
+			primary = member.Name
+		} else if member.State == 2 {
+			secondaries = append(secondaries, member.Name)
+		}
+	}
+	sort.Strings(secondaries)
+	return secondaries, primary
+}
+
+// getReplicationLag returns lag of a member behind the primary and its state
+func getReplicationLag(members []replSetMember, host string) (time.Duration, string) {
+	var primary, member *replSetMember
+	for i, m := range members {
+		if m.State == 1 {
+			primary = &members[i]
+		}
+		if m.Name == host {
+			member = &members[i]
+		}
+	}
+	if member == nil {
+		return 0, "NOT FOUND"
+	}
+	if primary == nil || member.OptimeDate.After(primary.OptimeDate) {
+		return 0, member.StateStr
+	}
+	return primary.OptimeDate.Sub(member.OptimeDate), member.StateStr
+}
+
+// getStandaloneHost returns a host of another port, or the host if port is 0
+func getStandaloneHost(host string, port int) string {
+	if port == 0 {
+		return host
+	}
+	if idx := strings.LastIndex(host, ":"); idx > 0 {
+		host = host[:idx]
+	}
+	return fmt.Sprintf("%v:%d", host, port)
+}
+
+// getIndexName returns the default name of an index of keys, e.g. a_1_b_-1
+func getIndexName(keys bson.D) string {
+	strs := []string{}
+	for _, elem := range keys {
+		strs = append(strs, fmt.Sprintf("%v_%v", elem.Key, elem.Value))
+	}
+	return strings.Join(strs, "_")
+}
+
+// getIndexBuildProgress returns progress of index builds from currentOp
+func getIndexBuildProgress(ops []indexBuildOp) string {
+	strs := []string{}
+	for _, op := range ops {
+		str := op.Msg
+		if op.Progress.Total > 0 {
+			str = fmt.Sprintf("%v (%d/%d, %d%%)", strings.TrimSpace(strings.Split(op.Msg, ":")[0]), op.Progress.Done,
+				op.Progress.Total, op.Progress.Done*100/op.Progress.Total)
+		}
+		strs = append(strs, str)
+	}
+	if len(strs) == 0 {
+		return "index build pending"
+	}
+	return strings.Join(strs, "\n")
+}
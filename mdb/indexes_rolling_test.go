@@ -0,0 +1,67 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func getTestReplSetMembers() []replSetMember {
+	now := time.Now()
+	return []replSetMember{
+		{Name: "host3:27017", State: 2, StateStr: "SECONDARY", OptimeDate: now.Add(-30 * time.Second)},
+		{Name: "host1:27017", State: 1, StateStr: "PRIMARY", OptimeDate: now},
+		{Name: "host4:27017", State: 7, StateStr: "ARBITER"},
+		{Name: "host2:27017", State: 2, StateStr: "SECONDARY", OptimeDate: now.Add(-time.Second)},
+	}
+}
+
+func TestGetRollingPlan(t *testing.T) {
+	secondaries, primary := getRollingPlan(getTestReplSetMembers())
+	if primary != "host1:27017" || len(secondaries) != 2 || secondaries[0] != "host2:27017" || secondaries[1] != "host3:27017" {
+		t.Fatal(secondaries, primary)
+	}
+}
+
+func TestGetReplicationLag(t *testing.T) {
+	members := getTestReplSetMembers()
+	if lag, state := getReplicationLag(members, "host3:27017"); lag != 30*time.Second || state != "SECONDARY" {
+		t.Fatal(lag, state)
+	}
+	if lag, state := getReplicationLag(members, "host1:27017"); lag != 0 || state != "PRIMARY" {
+		t.Fatal(lag, state)
+	}
+	if _, state := getReplicationLag(members, "host5:27017"); state != "NOT FOUND" {
+		t.Fatal(state)
+	}
+}
+
+func TestGetStandaloneHost(t *testing.T) {
+	if host := getStandaloneHost("host1:27017", 0); host != "host1:27017" {
+		t.Fatal(host)
+	}
+	if host := getStandaloneHost("host1:27017", 27117); host != "host1:27117" {
+		t.Fatal(host)
+	}
+}
+
+func TestGetIndexName(t *testing.T) {
+	keys := bson.D{{Key: "a", Value: int32(1)}, {Key: "b", Value: int32(-1)}, {Key: "loc", Value: "2dsphere"}}
+	if name := getIndexName(keys); name != "a_1_b_-1_loc_2dsphere" {
+		t.Fatal(name)
+	}
+}
+
+func TestGetIndexBuildProgress(t *testing.T) {
+	op := indexBuildOp{Msg: "Index Build: scanning collection Index Build: scanning collection: 250/1000 25%"}
+	op.Progress.Done, op.Progress.Total = 250, 1000
+	if str := getIndexBuildProgress([]indexBuildOp{op}); str != "Index Build (250/1000, 25%)" {
+		t.Fatal(str)
+	}
+	if str := getIndexBuildProgress(nil); str != "index build pending" {
+		t.Fatal(str)
+	}
+}
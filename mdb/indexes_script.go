@@ -0,0 +1,125 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// GetIndexesScript returns a mongo shell script to remove redundant and unused indexes, indexes are
+// hidden (4.4+) instead of dropped if hide is true, and each is followed by a commented rollback command
+func (ir *IndexesReader) GetIndexesScript(indexesMap bson.M, hide bool) string {
+	var buffer bytes.Buffer
+	buffer.WriteString("// Generated by keyhole, review before running.\n")
+	buffer.WriteString("// Index usage is collected since the last restart of each host, make sure it covers\n")
+	buffer.WriteString("// periodic jobs (e.g. month end reports) and all members of the cluster.\n")
+	if hide == false {
+		buffer.WriteString("// Consider hiding indexes (4.4+) first, a hidden index is maintained but not used by queries\n")
+		buffer.WriteString("// and can be unhidden without a rebuild.\n")
+	}
+	for _, dbName := range getSortedKeys(indexesMap) {
+		collections, ok := indexesMap[dbName].(bson.M)
+		if ok == false {
+			continue
+		}
+		for _, collName := range getSortedKeys(collections) {
+			list, ok := collections[collName].([]IndexStatsDoc)
+			if ok == false {
+				continue
+			}
+			coll := fmt.Sprintf("db.getSiblingDB(%s).getCollection(%s)", strconv.Quote(dbName), strconv.Quote(collName))
+			header := false
+			for _, o := range list {
+				reason := getIndexRemovalReason(o)
+				if reason == "" || (hide == true && o.Hidden == true) {
+					continue
+				}
+				if header == false {
+					buffer.WriteString("\n// " + dbName + "." + collName + "\n")
+					header = true
+				}
+				buffer.WriteString("// " + o.Key + " " + reason)
+				if o.Size > 0 {
+					buffer.WriteString(", size: " + GetStorageSize(o.Size))
+				}
+				buffer.WriteString("\n")
+				if o.Unique == true || o.ExpireAfterSeconds != nil {
+					buffer.WriteString("// skipped, unique and TTL indexes are used without being counted by $indexStats\n")
+					continue
+				}
+				name := strconv.Quote(o.Name)
+				if hide == true {
+					buffer.WriteString(coll + ".hideIndex(" + name + ");\n")
+					buffer.WriteString("// rollback: " + coll + ".unhideIndex(" + name + ");\n")
+				} else {
+					buffer.WriteString(coll + ".dropIndex(" + name + ");\n")
+					buffer.WriteString("// rollback: " + coll + ".createIndex(" + getIndexKeyScript(o.Key) + ", " + getIndexOptionsScript(o) + ");\n")
+				}
+			}
+		}
+	}
+	return buffer.String()
+}
+
+// getIndexRemovalReason returns why an index is a candidate to remove, or empty if it isn't
+func getIndexRemovalReason(o IndexStatsDoc) string {
+	if o.IsDupped == true {
+		return "is redundant, its fields are covered by another index"
+	} else if isUnusedIndex(o) {
+		return "has no ops since stats were collected"
+	}
+	return ""
+}
+
+// getIndexKeyScript quotes fields and non-numeric types of a key, e.g. { "a.b": 1, "loc": "2dsphere" }
+func getIndexKeyScript(key string) string {
+	key = strings.TrimSuffix(strings.TrimPrefix(key, "{ "), " }")
+	strs := []string{}
+	for _, elem := range strings.Split(key, ", ") {
+		idx := strings.LastIndex(elem, ": ")
+		if idx < 0 {
+			continue
+		}
+		value := elem[idx+2:]
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			value = strconv.Quote(value)
+		}
+		strs = append(strs, strconv.Quote(elem[:idx])+": "+value)
+	}
+	return "{ " + strings.Join(strs, ", ") + " }"
+}
+
+// getIndexOptionsScript returns options to recreate an index
+func getIndexOptionsScript(o IndexStatsDoc) string {
+	strs := []string{"name: " + strconv.Quote(o.Name)}
+	if o.Unique == true {
+		strs = append(strs, "unique: true")
+	}
+	if o.ExpireAfterSeconds != nil {
+		strs = append(strs, fmt.Sprintf("expireAfterSeconds: %d", *o.ExpireAfterSeconds))
+	}
+	if o.Sparse == true {
+		strs = append(strs, "sparse: true")
+	}
+	if o.PartialFilterExpression != "" {
+		strs = append(strs, "partialFilterExpression: "+o.PartialFilterExpression)
+	}
+	if o.Collation != "" {
+		strs = append(strs, "collation: "+o.Collation)
+	}
+	if o.WildcardProjection != "" {
+		strs = append(strs, "wildcardProjection: "+o.WildcardProjection)
+	}
+	if o.Weights != "" {
+		strs = append(strs, "weights: "+o.Weights)
+	}
+	if o.Hidden == true {
+		strs = append(strs, "hidden: true")
+	}
+	return "{ " + strings.Join(strs, ", ") + " }"
+}
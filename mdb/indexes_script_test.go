@@ -0,0 +1,35 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetIndexesScript(t *testing.T) {
+	ir := NewIndexesReader(nil)
+	script := ir.GetIndexesScript(getTestIndexesMap(), false)
+	t.Log(script)
+	coll := `db.getSiblingDB("keyhole").getCollection("examples")`
+	if strings.Contains(script, coll+`.dropIndex("a_1");`) == false {
+		t.Fatal("expected to drop a_1")
+	}
+	if strings.Contains(script, `// rollback: `+coll+`.createIndex({ "a": 1 }, { name: "a_1" });`) == false {
+		t.Fatal("expected rollback of a_1")
+	}
+	if strings.Contains(script, `dropIndex("_id_")`) || strings.Contains(script, `dropIndex("a_1_b_1")`) || strings.Contains(script, `dropIndex("createdAt_1")`) {
+		t.Fatal("unexpected drop")
+	}
+
+	script = ir.GetIndexesScript(getTestIndexesMap(), true)
+	if strings.Contains(script, coll+`.hideIndex("a_1");`) == false || strings.Contains(script, "dropIndex") {
+		t.Fatal(script)
+	}
+}
+
+func TestGetIndexKeyScript(t *testing.T) {
+	if str := getIndexKeyScript("{ a.b: 1, c: -1, loc: 2dsphere }"); str != `{ "a.b": 1, "c": -1, "loc": "2dsphere" }` {
+		t.Fatal(str)
+	}
+}
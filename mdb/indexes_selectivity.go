@@ -0,0 +1,92 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// lowSelectivityRatio is the ratio of distinct values of a leading key to sampled documents below which
+// an index hardly narrows down documents, e.g. indexes leading with a boolean or a status
+const lowSelectivityRatio = 0.01
+
+// minSelectivitySamples is number of documents a collection requires before selectivity is estimated
+const minSelectivitySamples = 100
+
+// SetSelectivity sets to sample collections and estimate selectivity of leading keys of indexes
+func (ir *IndexesReader) SetSelectivity(selectivity bool) {
+	ir.selectivity = selectivity
+}
+
+// setIndexesSelectivity estimates selectivity of indexes of a collection from cardinality of their leading
+// keys of sampled documents, a leading key is sampled once for all indexes leading with it
+func (ir *IndexesReader) setIndexesSelectivity(ctx context.Context, collection *mongo.Collection, list []IndexStatsDoc) error {
+	var err error
+	var count int64
+	var summary CardinalitySummary
+	fields := []string{}
+	for _, o := range list {
+		if field := getSelectivityField(o); field != "" && contains(fields, field) == false {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return err
+	}
+	if count, err = collection.EstimatedDocumentCount(ctx); err != nil || count < minSelectivitySamples {
+		return err
+	}
+	card := NewCardinality(ir.client)
+	card.SetVerbose(ir.verbose)
+	if summary, err = card.GetCardinalityArrayContext(ctx, collection.Database().Name(), collection.Name(), fields); err != nil {
+		return err
+	}
+	counts := map[string]int64{} // fields missing from all sampled documents are absent, count 0
+	for _, c := range summary.List {
+		counts[c.Field] = c.Count
+	}
+	for i, o := range list {
+		if field := getSelectivityField(o); field != "" {
+			setIndexSelectivity(&list[i], counts[field], summary.SampledCount)
+		}
+	}
+	return err
+}
+
+// getSelectivityField returns the leading key of an index, empty if selectivity doesn't apply, i.e. _id,
+// unique, partial, sparse, text, and wildcard indexes
+func getSelectivityField(o IndexStatsDoc) string {
+	if len(o.Fields) == 0 || o.Key == "{ _id: 1 }" || o.Unique == true || o.Sparse == true || o.PartialFilterExpression != "" {
+		return ""
+	}
+	field := o.Fields[0]
+	if field == "_fts" || field == "$**" || strings.HasSuffix(field, ".$**") {
+		return ""
+	}
+	return field
+}
+
+// setIndexSelectivity sets selectivity of an index from distinct values of its leading key in sampled documents
+func setIndexSelectivity(o *IndexStatsDoc, distinct int64, sampled int64) {
+	if sampled < minSelectivitySamples {
+		return
+	}
+	selectivity := float64(distinct) / float64(sampled)
+	if selectivity > 1 { // array values are counted separately
+		selectivity = 1
+	}
+	o.Selectivity = &selectivity
+	o.IsLowSelectivity = selectivity < lowSelectivityRatio && o.IsShardKey == false
+}
+
+// getSelectivityString returns selectivity of an index, empty if unknown
+func getSelectivityString(o IndexStatsDoc) string {
+	if o.Selectivity == nil {
+		return ""
+	}
+	return fmt.Sprintf("selectivity: %.2f%%", *o.Selectivity*100)
+}
@@ -0,0 +1,56 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"testing"
+)
+
+func TestGetSelectivityField(t *testing.T) {
+	tests := map[string]IndexStatsDoc{
+		"":       {Key: "{ _id: 1 }", Fields: []string{"_id"}},
+		"status": {Key: "{ status: 1, date: -1 }", Fields: []string{"status", "date"}},
+		"email":  {Key: "{ email: 1 }", Fields: []string{"email"}},
+	}
+	for field, o := range tests {
+		if s := getSelectivityField(o); s != field {
+			t.Fatal(o.Key, s)
+		}
+	}
+	for _, o := range []IndexStatsDoc{
+		{Key: "{ email: 1 }", Fields: []string{"email"}, Unique: true},
+		{Key: "{ status: 1 }", Fields: []string{"status"}, PartialFilterExpression: "{status: {$eq: 'active'}}"},
+		{Key: "{ _fts: text, _ftsx: 1 }", Fields: []string{"_fts", "_ftsx"}},
+		{Key: "{ $**: 1 }", Fields: []string{"$**"}},
+		{Key: "{ attrs.$**: 1 }", Fields: []string{"attrs.$**"}},
+	} {
+		if s := getSelectivityField(o); s != "" {
+			t.Fatal(o.Key, s)
+		}
+	}
+}
+
+func TestSetIndexSelectivity(t *testing.T) {
+	o := IndexStatsDoc{Key: "{ active: 1 }", Fields: []string{"active"}}
+	setIndexSelectivity(&o, 2, 50)
+	if o.Selectivity != nil {
+		t.Fatal("expected unknown selectivity of few samples")
+	}
+	setIndexSelectivity(&o, 2, 1000)
+	if o.Selectivity == nil || *o.Selectivity != 0.002 || o.IsLowSelectivity == false {
+		t.Fatal(o)
+	}
+	if s := getSelectivityString(o); s != "selectivity: 0.20%" {
+		t.Fatal(s)
+	}
+	o = IndexStatsDoc{Key: "{ email: 1 }", Fields: []string{"email"}}
+	setIndexSelectivity(&o, 1200, 1000)
+	if *o.Selectivity != 1 || o.IsLowSelectivity == true {
+		t.Fatal(o)
+	}
+	o = IndexStatsDoc{Key: "{ region: 1 }", Fields: []string{"region"}, IsShardKey: true}
+	setIndexSelectivity(&o, 3, 1000)
+	if o.IsLowSelectivity == true {
+		t.Fatal("shard key indexes are required")
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// CollectionSizeDoc holds storage statistics of a collection from collStats
+type CollectionSizeDoc struct {
+	Count          int `json:"count"`
+	Size           int `json:"size"`
+	StorageSize    int `json:"storageSize"`
+	TotalIndexSize int `json:"totalIndexSize"`
+}
+
+// GetCollectionSizes returns storage statistics of collections read, keyed by namespace
+func (ir *IndexesReader) GetCollectionSizes() map[string]CollectionSizeDoc {
+	return ir.collSizes
+}
+
+// getCollStats returns collStats of a collection of a read preference, primary if nil
+func getCollStats(ctx context.Context, collection *mongo.Collection, rp *readpref.ReadPref) (bson.M, error) {
+	var stats bson.M
+	cmd := bson.D{{Key: "collStats", Value: collection.Name()}}
+	err := collection.Database().RunCommand(ctx, cmd, getRunCmdOptions(rp)).Decode(&stats)
+	return stats, err
+}
+
+// getCollectionSize returns storage statistics of a collection from collStats
+func getCollectionSize(stats bson.M) CollectionSizeDoc {
+	return CollectionSizeDoc{Count: toInt(stats["count"]), Size: toInt(stats["size"]),
+		StorageSize: toInt(stats["storageSize"]), TotalIndexSize: toInt(stats["totalIndexSize"])}
+}
+
+// setIndexSizes sets index sizes on disk and in WiredTiger cache from collStats, cache bytes
+// are summed from all shards if collStats is from a mongos
+func setIndexSizes(list []IndexStatsDoc, stats bson.M) {
+	indexSizes, _ := stats["indexSizes"].(bson.M)
+	details := []bson.M{}
+	if doc, ok := stats["indexDetails"].(bson.M); ok == true {
+		details = append(details, doc)
+	} else if shards, ok := stats["shards"].(bson.M); ok == true {
+		for _, shard := range shards {
+			if s, ok := shard.(bson.M); ok == true {
+				if doc, ok := s["indexDetails"].(bson.M); ok == true {
+					details = append(details, doc)
+				}
+			}
+		}
+	}
+	for i, o := range list {
+		list[i].Size = toInt(indexSizes[o.Name])
+		list[i].CacheBytes = 0
+		for _, doc := range details {
+			if index, ok := doc[o.Name].(bson.M); ok == true {
+				if cache, ok := index["cache"].(bson.M); ok == true {
+					list[i].CacheBytes += toInt(cache["bytes currently in the cache"])
+				}
+			}
+		}
+	}
+}
+
+// getCollectionSizeString returns statistics of a collection, e.g. 1000 docs, size: 1.2 MB, ...
+func getCollectionSizeString(doc CollectionSizeDoc) string {
+	return fmt.Sprintf("%d docs, size: %s, storage: %s, indexes: %s", doc.Count, GetStorageSize(doc.Size),
+		GetStorageSize(doc.StorageSize), GetStorageSize(doc.TotalIndexSize))
+}
+
+// getIndexSizeString returns size of an index on disk and in cache, empty if unknown
+func getIndexSizeString(o IndexStatsDoc) string {
+	if o.Size == 0 {
+		return ""
+	}
+	return fmt.Sprintf("size: %s, cache: %s", GetStorageSize(o.Size), GetStorageSize(o.CacheBytes))
+}
@@ -0,0 +1,39 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSetIndexSizes(t *testing.T) {
+	list := []IndexStatsDoc{{Key: "{ _id: 1 }", Name: "_id_"}, {Key: "{ a: 1 }", Name: "a_1"}}
+	stats := bson.M{"count": int32(1000), "size": int32(48000), "storageSize": int32(20480), "totalIndexSize": int32(61440),
+		"indexSizes":   bson.M{"_id_": int32(20480), "a_1": int32(40960)},
+		"indexDetails": bson.M{"a_1": bson.M{"cache": bson.M{"bytes currently in the cache": int64(1024)}}}}
+	setIndexSizes(list, stats)
+	if list[0].Size != 20480 || list[1].Size != 40960 || list[1].CacheBytes != 1024 {
+		t.Fatal(list)
+	}
+	if str := getIndexSizeString(list[1]); str != "size: 40 KB, cache: 1 KB" {
+		t.Fatal(str)
+	}
+	doc := getCollectionSize(stats)
+	if doc.Count != 1000 || doc.TotalIndexSize != 61440 {
+		t.Fatal(doc)
+	}
+	t.Log(getCollectionSizeString(doc))
+}
+
+func TestSetIndexSizesSharded(t *testing.T) {
+	list := []IndexStatsDoc{{Key: "{ a: 1 }", Name: "a_1"}}
+	stats := bson.M{"indexSizes": bson.M{"a_1": int64(4096)}, "shards": bson.M{
+		"shard0": bson.M{"indexDetails": bson.M{"a_1": bson.M{"cache": bson.M{"bytes currently in the cache": int64(100)}}}},
+		"shard1": bson.M{"indexDetails": bson.M{"a_1": bson.M{"cache": bson.M{"bytes currently in the cache": int64(200)}}}}}}
+	setIndexSizes(list, stats)
+	if list[0].Size != 4096 || list[0].CacheBytes != 300 {
+		t.Fatal(list)
+	}
+}
@@ -4,8 +4,10 @@ package mdb
 
 import (
 	"context"
+	"errors"
 	"log"
 	"math/rand"
+	"strings"
 	"testing"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -41,6 +43,27 @@ func TestGetIndexes(t *testing.T) {
 	t.Log(str)
 }
 
+func TestGetIndexesFromDBConcurrency(t *testing.T) {
+	var client *mongo.Client
+	client = getMongoClient()
+	defer client.Disconnect(context.Background())
+	c := client.Database(dbName).Collection("examples")
+	seedNumbers(c)
+
+	ir := NewIndexesReader(client)
+	serial, _ := ir.GetIndexesFromDB(dbName)
+	ir.SetConcurrency(4)
+	parallel, _ := ir.GetIndexesFromDB(dbName)
+	if len(serial) != len(parallel) {
+		t.Fatal(len(serial), len(parallel))
+	}
+	for name, list := range serial {
+		if len(list.([]IndexStatsDoc)) != len(parallel[name].([]IndexStatsDoc)) {
+			t.Fatal(name)
+		}
+	}
+}
+
 func seedNumbers(c *mongo.Collection) {
 	var err error
 	var ctx = context.Background()
@@ -72,3 +95,105 @@ func seedNumbers(c *mongo.Collection) {
 	}
 	indexView.CreateOne(ctx, idx)
 }
+
+func TestCheckIfDuppedWithOptions(t *testing.T) {
+	ab := IndexStatsDoc{Key: "{ a: 1, b: 1 }", Fields: []string{"a", "b"}}
+	a := IndexStatsDoc{Key: "{ a: 1 }", Fields: []string{"a"}}
+	if checkIfDupped(a, []IndexStatsDoc{a, ab}) == false {
+		t.Fatal("expected { a: 1 } to be dupped")
+	}
+	unique := a
+	unique.Unique = true
+	if checkIfDupped(unique, []IndexStatsDoc{unique, ab}) == true {
+		t.Fatal("unique index should not be dupped")
+	}
+	seconds := 3600
+	ttl := a
+	ttl.ExpireAfterSeconds = &seconds
+	if checkIfDupped(ttl, []IndexStatsDoc{ttl, ab}) == true {
+		t.Fatal("TTL index should not be dupped")
+	}
+	partial := ab
+	partial.PartialFilterExpression = `{ b: { $exists: true } }`
+	if checkIfDupped(a, []IndexStatsDoc{a, partial}) == true {
+		t.Fatal("index should not be dupped by a partial index")
+	}
+	sparse := ab
+	sparse.Sparse = true
+	if checkIfDupped(a, []IndexStatsDoc{a, sparse}) == true {
+		t.Fatal("index should not be dupped by a sparse index")
+	}
+}
+
+func TestCheckIfDuppedWithKeyTypes(t *testing.T) {
+	tests := []struct {
+		doc    IndexStatsDoc
+		o      IndexStatsDoc
+		dupped bool
+	}{
+		{IndexStatsDoc{Key: "{ loc: 2dsphere }", Fields: []string{"loc"}},
+			IndexStatsDoc{Key: "{ loc: 2dsphere, category: 1 }", Fields: []string{"loc", "category"}}, true},
+		{IndexStatsDoc{Key: "{ loc: 2dsphere }", Fields: []string{"loc"}},
+			IndexStatsDoc{Key: "{ loc: 1, category: 1 }", Fields: []string{"loc", "category"}}, false},
+		{IndexStatsDoc{Key: "{ category: 1 }", Fields: []string{"category"}},
+			IndexStatsDoc{Key: "{ category: 1, loc: 2dsphere }", Fields: []string{"category", "loc"}}, false},
+		{IndexStatsDoc{Key: "{ a: 1 }", Fields: []string{"a"}},
+			IndexStatsDoc{Key: "{ a: hashed }", Fields: []string{"a"}}, false},
+		{IndexStatsDoc{Key: "{ a: 1 }", Fields: []string{"a"}},
+			IndexStatsDoc{Key: "{ a: 1, _fts: text, _ftsx: 1 }", Fields: []string{"a", "_fts", "_ftsx"}}, false},
+		{IndexStatsDoc{Key: "{ a: -1 }", Fields: []string{"a"}},
+			IndexStatsDoc{Key: "{ a: 1, b: -1 }", Fields: []string{"a", "b"}}, true},
+		{IndexStatsDoc{Key: "{ $**: 1 }", Fields: []string{"$**"}},
+			IndexStatsDoc{Key: "{ $**: 1, b: 1 }", Fields: []string{"$**", "b"}}, false},
+		{IndexStatsDoc{Key: "{ a: 1 }", Fields: []string{"a"}},
+			IndexStatsDoc{Key: "{ a: 1, attrs.$**: 1 }", Fields: []string{"a", "attrs.$**"}}, false},
+	}
+	for _, test := range tests {
+		if dupped := checkIfDupped(test.doc, []IndexStatsDoc{test.doc, test.o}); dupped != test.dupped {
+			t.Fatal(test.doc.Key, test.o.Key, dupped)
+		}
+	}
+	text := IndexStatsDoc{Key: "{ a: 1 }", Fields: []string{"a"}}
+	weighted := IndexStatsDoc{Key: "{ a: 1, b: 1 }", Fields: []string{"a", "b"}, Weights: `{title: 10}`}
+	if checkIfDupped(text, []IndexStatsDoc{text, weighted}) == true {
+		t.Fatal("index should not be dupped by an index of different weights")
+	}
+}
+
+func TestGetIndexKeyTypes(t *testing.T) {
+	types := getIndexKeyTypes(IndexStatsDoc{Key: "{ a: -1, loc: 2dsphere, _fts: text, _ftsx: 1, b: 1.0 }"})
+	for field, keyType := range map[string]string{"a": "1", "loc": "2dsphere", "_fts": "text", "_ftsx": "1", "b": "1"} {
+		if types[field] != keyType {
+			t.Fatal(field, types)
+		}
+	}
+}
+
+func TestSetIndexOptions(t *testing.T) {
+	idx := bson.D{{Key: "v", Value: int32(2)}, {Key: "unique", Value: true}, {Key: "key", Value: bson.D{{Key: "a", Value: int32(1)}}},
+		{Key: "name", Value: "a_1"}, {Key: "sparse", Value: float64(1)}, {Key: "expireAfterSeconds", Value: int32(3600)},
+		{Key: "partialFilterExpression", Value: bson.D{{Key: "b", Value: bson.D{{Key: "$gt", Value: int32(5)}}}}}}
+	var o IndexStatsDoc
+	setIndexOptions(&o, idx)
+	if o.Unique == false || o.Sparse == false || o.ExpireAfterSeconds == nil || *o.ExpireAfterSeconds != 3600 || o.PartialFilterExpression == "" {
+		t.Fatal(o)
+	}
+	str := getIndexOptionsString(o)
+	if strings.Contains(str, "unique: true") == false || strings.Contains(str, "TTL: 3600s") == false {
+		t.Fatal(str)
+	}
+	t.Log(str)
+}
+
+func TestIndexesReaderErrors(t *testing.T) {
+	ir := NewIndexesReader(nil)
+	if len(ir.GetErrors()) != 0 {
+		t.Fatal("expected no errors")
+	}
+	ir.addError("keyhole.view", errors.New("Namespace keyhole.view is a view, not a collection"))
+	ir.addError("keyhole.buckets", errors.New("not authorized"))
+	errs := ir.GetErrors()
+	if len(errs) != 2 || errs[0].Namespace != "keyhole.buckets" || errs[1].Error != "Namespace keyhole.view is a view, not a collection" {
+		t.Fatal(errs)
+	}
+}
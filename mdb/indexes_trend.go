@@ -0,0 +1,213 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// indexUsageCollection keeps snapshots of index usage in _KEYHOLE_
+const indexUsageCollection = "indexUsage"
+
+// UsageSnapshotMongoDB is the store of TrackUsage to keep snapshots in _KEYHOLE_.indexUsage
+const UsageSnapshotMongoDB = "mongodb"
+
+// UsageSnapshot holds $indexStats accesses of indexes at a time
+type UsageSnapshot struct {
+	Time    time.Time          `json:"time" bson:"time"`
+	Indexes []IndexUsageRecord `json:"indexes" bson:"indexes"`
+}
+
+// IndexUsageRecord is accesses of an index on a host
+type IndexUsageRecord struct {
+	Namespace string    `json:"namespace" bson:"namespace"`
+	Name      string    `json:"name" bson:"name"`
+	Host      string    `json:"host" bson:"host"`
+	Ops       int       `json:"ops" bson:"ops"`
+	Since     time.Time `json:"since" bson:"since"`
+}
+
+// IndexUsageTrend is accesses of an index across snapshots, counters reset by restarts are added up
+type IndexUsageTrend struct {
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	First     time.Time `json:"first"` // time of the first snapshot of the index
+	Last      time.Time `json:"last"`  // time of the last snapshot of the index
+	Ops       int       `json:"ops"`   // accesses between the first and the last snapshots
+	Resets    int       `json:"resets"`
+	Snapshots int       `json:"snapshots"`
+}
+
+// GetUsageSnapshot returns accesses of indexes of indexesMap, indexes of unknown usage are excluded
+func GetUsageSnapshot(indexesMap bson.M) UsageSnapshot {
+	snapshot := UsageSnapshot{Time: time.Now(), Indexes: []IndexUsageRecord{}}
+	for _, dbName := range getSortedKeys(indexesMap) {
+		collections, ok := indexesMap[dbName].(bson.M)
+		if ok == false {
+			continue
+		}
+		for _, collName := range getSortedKeys(collections) {
+			for _, o := range collections[collName].([]IndexStatsDoc) {
+				for _, u := range o.Usage {
+					snapshot.Indexes = append(snapshot.Indexes, IndexUsageRecord{Namespace: dbName + "." + collName,
+						Name: o.Name, Host: u.Host, Ops: u.Accesses.Ops, Since: u.Accesses.Since})
+				}
+			}
+		}
+	}
+	return snapshot
+}
+
+// TrackUsage keeps a snapshot of accesses of indexesMap in a file, or in _KEYHOLE_.indexUsage if store is
+// mongodb, and returns accesses of indexes across all snapshots kept
+func (ir *IndexesReader) TrackUsage(indexesMap bson.M, store string) ([]IndexUsageTrend, error) {
+	var err error
+	var snapshots []UsageSnapshot
+	snapshot := GetUsageSnapshot(indexesMap)
+	if store == UsageSnapshotMongoDB {
+		if err = ir.SaveUsageSnapshot(snapshot); err != nil {
+			return nil, err
+		}
+		snapshots, err = ir.GetUsageSnapshots()
+	} else {
+		if err = AppendUsageSnapshot(store, snapshot); err != nil {
+			return nil, err
+		}
+		snapshots, err = ReadUsageSnapshots(store)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return GetUsageTrends(snapshots), err
+}
+
+// SaveUsageSnapshot inserts a snapshot into _KEYHOLE_.indexUsage
+func (ir *IndexesReader) SaveUsageSnapshot(snapshot UsageSnapshot) error {
+	_, err := ir.client.Database(KEYHOLEDB).Collection(indexUsageCollection).InsertOne(context.Background(), snapshot)
+	return err
+}
+
+// GetUsageSnapshots returns snapshots of _KEYHOLE_.indexUsage
+func (ir *IndexesReader) GetUsageSnapshots() ([]UsageSnapshot, error) {
+	var err error
+	var cur *mongo.Cursor
+	ctx := context.Background()
+	snapshots := []UsageSnapshot{}
+	opts := options.Find().SetSort(bson.D{{Key: "time", Value: 1}})
+	if cur, err = ir.client.Database(KEYHOLEDB).Collection(indexUsageCollection).Find(ctx, bson.M{}, opts); err != nil {
+		return snapshots, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var snapshot UsageSnapshot
+		if err = cur.Decode(&snapshot); err != nil {
+			return snapshots, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, cur.Err()
+}
+
+// AppendUsageSnapshot appends a snapshot to a file, a line of JSON per snapshot
+func AppendUsageSnapshot(filename string, snapshot UsageSnapshot) error {
+	return appendJSONLine(filename, snapshot)
+}
+
+// ReadUsageSnapshots reads snapshots appended to a file by AppendUsageSnapshot
+func ReadUsageSnapshots(filename string) ([]UsageSnapshot, error) {
+	var err error
+	var file *os.File
+	snapshots := []UsageSnapshot{}
+	if file, err = os.Open(filename); err != nil {
+		return snapshots, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var snapshot UsageSnapshot
+		if err = json.Unmarshal(line, &snapshot); err != nil {
+			return snapshots, fmt.Errorf("%v: %v", filename, err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, scanner.Err()
+}
+
+// GetUsageTrends returns accesses of indexes between their first and last snapshots.  Accesses of a host are
+// the difference from its previous snapshot, or all accesses since the counter was reset by a restart.
+func GetUsageTrends(snapshots []UsageSnapshot) []IndexUsageTrend {
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Time.Before(snapshots[j].Time) })
+	trends := map[string]*IndexUsageTrend{}
+	previous := map[string]IndexUsageRecord{}
+	for _, snapshot := range snapshots {
+		for _, r := range snapshot.Indexes {
+			id := r.Namespace + ":" + r.Name
+			trend, ok := trends[id]
+			if ok == false {
+				trend = &IndexUsageTrend{Namespace: r.Namespace, Name: r.Name, First: snapshot.Time}
+				trends[id] = trend
+			}
+			if trend.Snapshots == 0 || trend.Last.Equal(snapshot.Time) == false {
+				trend.Snapshots++
+				trend.Last = snapshot.Time
+			}
+			key := id + ":" + r.Host
+			if p, ok := previous[key]; ok == true {
+				if r.Since.Equal(p.Since) && r.Ops >= p.Ops {
+					trend.Ops += r.Ops - p.Ops
+				} else {
+					trend.Resets++
+					trend.Ops += r.Ops
+				}
+			} else if r.Since.After(trend.First) { // a host added after the first snapshot
+				trend.Ops += r.Ops
+			}
+			previous[key] = r
+		}
+	}
+	list := []IndexUsageTrend{}
+	for _, trend := range trends {
+		list = append(list, *trend)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Namespace == list[j].Namespace {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].Namespace < list[j].Namespace
+	})
+	return list
+}
+
+// GetUsageTrendsSummary returns accesses of indexes across snapshots, indexes unused across
+// snapshots are marked with ?
+func GetUsageTrendsSummary(trends []IndexUsageTrend) string {
+	var buffer bytes.Buffer
+	if len(trends) == 0 {
+		return "No index usage snapshots found"
+	}
+	for _, trend := range trends {
+		mark := "  "
+		if trend.Snapshots > 1 && trend.Ops == 0 {
+			mark = "? "
+		}
+		buffer.WriteString(fmt.Sprintf("%s%s %s: %d ops in %v (%d snapshots, %d resets)\n", mark, trend.Namespace, trend.Name,
+			trend.Ops, trend.Last.Sub(trend.First).Round(time.Minute), trend.Snapshots, trend.Resets))
+	}
+	return buffer.String()
+}
@@ -0,0 +1,76 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func getTestUsageSnapshots() []UsageSnapshot {
+	since := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	restarted := since.Add(20 * 24 * time.Hour)
+	return []UsageSnapshot{
+		{Time: since.Add(30 * 24 * time.Hour), Indexes: []IndexUsageRecord{ // restarted, out of order
+			{Namespace: "keyhole.cars", Name: "color_1", Host: "h1", Ops: 4, Since: restarted},
+			{Namespace: "keyhole.cars", Name: "year_1", Host: "h1", Ops: 0, Since: restarted}}},
+		{Time: since.Add(24 * time.Hour), Indexes: []IndexUsageRecord{
+			{Namespace: "keyhole.cars", Name: "color_1", Host: "h1", Ops: 100, Since: since},
+			{Namespace: "keyhole.cars", Name: "year_1", Host: "h1", Ops: 7, Since: since}}},
+		{Time: since.Add(10 * 24 * time.Hour), Indexes: []IndexUsageRecord{
+			{Namespace: "keyhole.cars", Name: "color_1", Host: "h1", Ops: 150, Since: since},
+			{Namespace: "keyhole.cars", Name: "year_1", Host: "h1", Ops: 7, Since: since}}},
+	}
+}
+
+func TestGetUsageTrends(t *testing.T) {
+	trends := GetUsageTrends(getTestUsageSnapshots())
+	if len(trends) != 2 {
+		t.Fatal(trends)
+	}
+	color, year := trends[0], trends[1]
+	if color.Name != "color_1" || color.Ops != 54 || color.Resets != 1 || color.Snapshots != 3 {
+		t.Fatal(color)
+	}
+	if year.Ops != 0 || year.Resets != 1 || year.Last.Sub(year.First) != 29*24*time.Hour {
+		t.Fatal(year)
+	}
+	str := GetUsageTrendsSummary(trends)
+	t.Log(str)
+	if strings.Contains(str, "? keyhole.cars year_1: 0 ops") == false {
+		t.Fatal(str)
+	}
+}
+
+func TestGetUsageSnapshot(t *testing.T) {
+	indexesMap := bson.M{"keyhole": bson.M{"cars": []IndexStatsDoc{
+		{Name: "color_1", Usage: []UsageDoc{{Host: "h1", Accesses: AccessesDoc{Ops: 3}}, {Host: "h2", Accesses: AccessesDoc{Ops: 5}}}},
+		{Name: "year_1"}}}} // usage unknown
+	snapshot := GetUsageSnapshot(indexesMap)
+	if len(snapshot.Indexes) != 2 || snapshot.Indexes[1].Host != "h2" || snapshot.Indexes[1].Ops != 5 {
+		t.Fatal(snapshot)
+	}
+}
+
+func TestUsageSnapshotsFile(t *testing.T) {
+	filename := filepath.Join(os.TempDir(), "keyhole-usage-test.json")
+	os.Remove(filename)
+	defer os.Remove(filename)
+	for _, snapshot := range getTestUsageSnapshots() {
+		if err := AppendUsageSnapshot(filename, snapshot); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snapshots, err := ReadUsageSnapshots(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 3 || len(snapshots[2].Indexes) != 2 || snapshots[2].Indexes[0].Ops != 150 {
+		t.Fatal(snapshots)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"math"
+	"sort"
+)
+
+// subBuckets is the number of buckets per power of 2, about 9% of precision
+const subBuckets = 8
+
+// LatencyHistogram keeps counts of latencies in log-scaled buckets
+type LatencyHistogram struct {
+	Buckets map[int]int // bucket index and counts
+	Count   int
+	Min     int
+	Max     int
+}
+
+// LatencyPercentiles is percentiles in milliseconds of latencies in microseconds
+type LatencyPercentiles struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// NewLatencyHistogram returns an empty histogram
+func NewLatencyHistogram() LatencyHistogram {
+	return LatencyHistogram{Buckets: map[int]int{}}
+}
+
+// Add records a latency
+func (h *LatencyHistogram) Add(value int) {
+	if h.Buckets == nil {
+		h.Buckets = map[int]int{}
+	}
+	if h.Count == 0 || value < h.Min {
+		h.Min = value
+	}
+	if h.Count == 0 || value > h.Max {
+		h.Max = value
+	}
+	h.Count++
+	h.Buckets[getBucketIndex(value)]++
+}
+
+// Merge adds counts of another histogram
+func (h *LatencyHistogram) Merge(other LatencyHistogram) {
+	if other.Count == 0 {
+		return
+	}
+	if h.Buckets == nil {
+		h.Buckets = map[int]int{}
+	}
+	if h.Count == 0 || other.Min < h.Min {
+		h.Min = other.Min
+	}
+	if h.Count == 0 || other.Max > h.Max {
+		h.Max = other.Max
+	}
+	h.Count += other.Count
+	for k, v := range other.Buckets {
+		h.Buckets[k] += v
+	}
+}
+
+// Percentile returns an estimated value of a percentile, e.g. 95 for p95
+func (h *LatencyHistogram) Percentile(pct float64) int {
+	if h.Count == 0 {
+		return 0
+	}
+	indexes := make([]int, 0, len(h.Buckets))
+	for k := range h.Buckets {
+		indexes = append(indexes, k)
+	}
+	sort.Ints(indexes)
+	rank := int(math.Ceil(pct / 100 * float64(h.Count)))
+	if rank < 1 {
+		rank = 1
+	}
+	cnt := 0
+	for _, idx := range indexes {
+		cnt += h.Buckets[idx]
+		if cnt >= rank {
+			value := getBucketValue(idx)
+			if value < h.Min {
+				value = h.Min
+			} else if value > h.Max {
+				value = h.Max
+			}
+			return value
+		}
+	}
+	return h.Max
+}
+
+// GetMilliPercentiles returns p50, p95, p99, and max in milliseconds of latencies recorded in microseconds
+func (h *LatencyHistogram) GetMilliPercentiles() LatencyPercentiles {
+	return LatencyPercentiles{P50: float64(h.Percentile(50)) / 1000, P95: float64(h.Percentile(95)) / 1000,
+		P99: float64(h.Percentile(99)) / 1000, Max: float64(h.Max) / 1000}
+}
+
+// CountAtMost returns an estimated number of latencies less than or equal to a value
+func (h *LatencyHistogram) CountAtMost(value int) int {
+	if value >= h.Max {
+		return h.Count
+	}
+	cnt := 0
+	for idx, n := range h.Buckets {
+		if getBucketValue(idx) <= value {
+			cnt += n
+		}
+	}
+	return cnt
+}
+
+// bucket 0 holds values <= 0, otherwise log2 scaled buckets
+func getBucketIndex(value int) int {
+	if value <= 0 {
+		return 0
+	}
+	return int(math.Floor(math.Log2(float64(value))*subBuckets)) + 1
+}
+
+// returns the upper bound of a bucket
+func getBucketValue(index int) int {
+	if index <= 0 {
+		return 0
+	}
+	return int(math.Ceil(math.Pow(2, float64(index)/subBuckets)))
+}
@@ -0,0 +1,50 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"testing"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	h := NewLatencyHistogram()
+	for i := 1; i <= 100; i++ {
+		h.Add(i)
+	}
+	if h.Count != 100 || h.Min != 1 || h.Max != 100 {
+		t.Fatal(h.Count, h.Min, h.Max)
+	}
+	p50 := h.Percentile(50)
+	if p50 < 45 || p50 > 55 {
+		t.Fatal("expected p50 close to 50, but got", p50)
+	}
+	p99 := h.Percentile(99)
+	if p99 < 90 || p99 > 100 {
+		t.Fatal("expected p99 close to 99, but got", p99)
+	}
+}
+
+func TestLatencyHistogramMerge(t *testing.T) {
+	h := NewLatencyHistogram()
+	h.Add(10)
+	other := NewLatencyHistogram()
+	other.Add(1000)
+	other.Add(2000)
+	h.Merge(other)
+	if h.Count != 3 || h.Min != 10 || h.Max != 2000 {
+		t.Fatal(h.Count, h.Min, h.Max)
+	}
+	if h.Percentile(100) != 2000 {
+		t.Fatal(h.Percentile(100))
+	}
+}
+
+func TestLatencyHistogramCountAtMost(t *testing.T) {
+	h := NewLatencyHistogram()
+	for _, v := range []int{50, 150, 800, 3000} {
+		h.Add(v)
+	}
+	if h.CountAtMost(100) != 1 || h.CountAtMost(1000) != 3 || h.CountAtMost(5000) != 4 || h.CountAtMost(10) != 0 {
+		t.Fatal(h.CountAtMost(100), h.CountAtMost(1000), h.CountAtMost(5000), h.CountAtMost(10))
+	}
+}
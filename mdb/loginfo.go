@@ -5,9 +5,12 @@ package mdb
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/gob"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
@@ -16,6 +19,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/simagix/gox"
 	"github.com/simagix/keyhole/sim/util"
@@ -24,36 +28,157 @@ import (
 // COLLSCAN constance
 const COLLSCAN = "COLLSCAN"
 
+// inefficientRatio is the scanned-to-returned ratio of an inefficient query
+const inefficientRatio = 100
+
 // LogInfo keeps loginfo struct
 type LogInfo struct {
-	OpsPatterns    []OpPerformanceDoc
-	OutputFilename string
-	SlowOps        []SlowOps
-	collscan       bool
-	filename       string
-	mongoInfo      string
-	silent         bool
-	verbose        bool
+	Apps            []AppStatsDoc
+	BroadcastWrites []BroadcastWriteDoc
+	CachePressure   []CachePressureDoc
+	Cursors         []CursorDoc
+	EndTime         time.Time // time of the last op analyzed
+	Host            string
+	Hosts           []HostStatsDoc // ops by hosts of logs interleaved from multiple nodes
+	IndexBuilds     []IndexBuild
+	NumShards       int // number of shards, the max nShards of mongos logs
+	OpsPatterns     []OpPerformanceDoc
+	OutputFilename  string
+	ShardKeys       []ShardKeyCandidate
+	SlowOps         []SlowOps
+	StartTime       time.Time // time of the first op analyzed
+	TimeSeries      []TimeBucketDoc
+	Transactions    []TransactionDoc
+	Variants        []PatternVariantsDoc // query patterns of different fields orders or value types
+	appsMap         map[string]*appStats
+	bucketsMap      map[string]*TimeBucketDoc
+	cacheEvents     []cacheEvent
+	collscan        bool
+	cursorsMap      map[string]*CursorDoc
+	encryptionKey   []byte // to encrypt the .enc file with if not nil
+	examples        int    // number of example statements to keep per ops pattern
+	exportType      string
+	exposeMetrics   bool // serves metrics at addresses other than localhost
+	filename        string
+	filenames       []string
+	formatter       OutputFormatterBase
+	getMores        bool
+	hostsMap        map[string]*hostStats
+	indexBuilds     []IndexBuild // finished index builds
+	limit           int          // number of ops patterns of screen, html, and markdown outputs, 0 for all
+	lineHost        string       // host prefix of the line parsed
+	maxPatterns     int
+	mongoInfo       string
+	numShards       int
+	openCursors     map[string]*cursorStats // cursors by host qualified cursor ids
+	opsMap          map[string]OpPerformanceDoc
+	opsSeconds      map[int64]*opsSecond   // slow ops by unix seconds
+	pendingBuilds   map[string]*IndexBuild // index builds in progress
+	pendingTxns     map[string][]string    // statement patterns of open transactions
+	redact          bool
+	shardKeys       map[string][]string // shard key fields by namespaces
+	silent          bool
+	sortBy          string // order of ops patterns, average time if empty
+	source          string
+	span            int
+	spillEncoder    *gob.Encoder
+	spillFile       *os.File
+	topSlowOps      int
+	txnsMap         map[string]*TransactionDoc
+	variantCounts   map[string]map[string]int // shapes counts by ops patterns keys
+	variants        bool
+	variantsMap     map[string]*PatternVariantsDoc
+	verbose         bool
 }
 
 // OpPerformanceDoc stores performance data
 type OpPerformanceDoc struct {
-	Command    string // count, delete, find, remove, and update
-	Count      int    // number of ops
-	Filter     string // query pattern
-	MaxMilli   int    // max millisecond
-	Namespace  string // database.collectin
-	Scan       string // COLLSCAN
-	TotalMilli int    // total milliseconds
-	Index      string // index used
+	Apps             map[string]int   // ops counts by appName
+	Broadcasts       int              // number of writes broadcast by mongos, of the IGNORED shardVersion
+	Command          string           // count, delete, find, remove, and update
+	Count            int              // number of ops
+	DocsExamined     int              // total docsExamined
+	Examples         []ExampleDoc     // the slowest statements with literal values if retained
+	Filter           string           // query pattern
+	FromMultiPlanner int              // number of ops planned by the multi-planner
+	FromRouter       int              // number of ops routed from mongos
+	Histogram        LatencyHistogram // latencies distribution
+	Hosts            map[string]int   // ops counts by hosts of interleaved logs
+	InMemorySorts    int              // number of ops with a blocking sort stage
+	KeysExamined     int              // total keysExamined
+	LockWaitMicros   int              // total timeAcquiringMicros of locks
+	LockWaits        int              // number of ops waited for locks
+	MaxMilli         int              // max millisecond
+	NShards          map[int]int      // ops counts by nShards of mongos
+	Namespace        string           // database.collectin
+	NReturned        int              // total nreturned
+	PlanCacheKey     string           // planCacheKey of 4.2 and later
+	QueryHash        string           // queryHash of 4.2 and later, ops are aggregated by it if logged
+	Replanned        int              // number of ops replanned
+	ResLen           int              // total reslen
+	ScatterGather    int              // number of ops targeted all shards
+	Scan             string           // COLLSCAN
+	Sort             string           // sort of find, kept out of the query pattern
+	SpilledSorts     int              // number of ops used disk to sort
+	TotalMilli       int              // total milliseconds
+	WriteConflicts   int              // total writeConflicts
+	Index            string           // index used
 }
 
-// SlowOps holds slow ops log and time
+// defaultTopSlowOps is number of slowest ops to keep
+const defaultTopSlowOps = 10
+
+// SlowOps holds a slow op parsed from a log line or a profile document
 type SlowOps struct {
-	Milli int
-	Log   string
+	Command     string    `json:"command"`
+	Log         string    `json:"log"`
+	Milli       int       `json:"milli"`
+	Namespace   string    `json:"namespace"`
+	PlanSummary string    `json:"planSummary"`
+	Source      string    `json:"source"`
+	Time        time.Time `json:"time"`
+}
+
+// opStats holds stats of a slow op from a log line or a profile document
+type opStats struct {
+	appName          string
+	broadcast        bool
+	command          string
+	conn             string
+	docsExamined     int
+	filter           string
+	fromMultiPlanner bool
+	fromRouter       bool
+	hasSortStage     bool
+	host             string
+	index            string
+	keysExamined     int
+	lockWaitMicros   int
+	log              string
+	milli            int
+	namespace        string
+	nreturned        int
+	nShards          int
+	planCacheKey     string
+	queryHash        string
+	replanned        bool
+	reslen           int
+	scan             string
+	sort             string
+	statement        string // command with literal values, e.g. find { find: "cars", filter: { color: "Red" } }
+	ts               time.Time
+	txnKey           string
+	usedDisk         bool
+	variant          string // shape of the filter, fields order and value types are kept
+	writeConflicts   int
 }
 
+var lockWaitRegex = regexp.MustCompile(`timeAcquiringMicros: \{([^}]*)\}`)
+var digitsRegex = regexp.MustCompile(`\d+`)
+var configRegex = regexp.MustCompile(`^\S+ .? CONTROL\s+\[\w+\] (\w+(:)?) (.*)$`)
+var slowOpRegex = regexp.MustCompile(`^\S+ \S+\s+(\w+)\s+\[\w+\] (\w+) (\S+) \S+: (.*) (\d+)ms$`) // SERVER-37743
+
+// OutputFormatterBase defines how a loginfo summary is rendered
 type OutputFormatterBase interface {
 	WriteHeader(buffer *bytes.Buffer)
 	WriteLine(buffer *bytes.Buffer, value *LogInfoLineAnalytics)
@@ -61,29 +186,56 @@ type OutputFormatterBase interface {
 	GetOutput(li *LogInfo) string
 }
 
+// ScreenOutputFormatter writes a table of ops patterns for terminals
 type ScreenOutputFormatter struct {
 	OutputFormatterBase
 }
 
+// JSONOutputFormatter writes a JSON array, or one object per line if NDJSON is set
 type JSONOutputFormatter struct {
 	OutputFormatterBase
-	// FormatType string
-	// Filename   string
-	// Extension  string
+	NDJSON bool // newline delimited JSON, no array wrapper
+	lines  int
 }
 
 // OpPerformanceDoc stores performance data
 type LogInfoLineAnalytics struct {
-	Namespace         string  `json:"namespace"`           // database.collectin
-	Command           string  `json:"command"`             // count, delete, find, remove, and update
-	QueryPattern      string  `json:"queryPattern"`        // query pattern
-	Count             int     `json:"count"`               // number of ops
-	MinMilliseconds   int     `json:"minMilliseconds"`     // min millisecond
-	MaxMilliseconds   int     `json:"maxMilliseconds"`     // max millisecond
-	AvgMilliseconds   float64 `json:"averageMilliseconds"` // max millisecond
-	TotalMilliseconds int     `json:"totalMilliseconds"`   // total milliseconds
-	IsCollectionScan  bool    `json:"isCollectionScan"`    // COLLSCAN
-	IndexUsed         string  `json:"indexUsed"`           // index used
+	Namespace         string       `json:"namespace"`            // database.collectin
+	Command           string       `json:"command"`              // count, delete, find, remove, and update
+	QueryPattern      string       `json:"queryPattern"`         // query pattern
+	Count             int          `json:"count"`                // number of ops
+	MinMilliseconds   int          `json:"minMilliseconds"`      // min millisecond
+	MaxMilliseconds   int          `json:"maxMilliseconds"`      // max millisecond
+	AvgMilliseconds   float64      `json:"averageMilliseconds"`  // max millisecond
+	P50Milliseconds   int          `json:"p50Milliseconds"`      // 50th percentile
+	P90Milliseconds   int          `json:"p90Milliseconds"`      // 90th percentile
+	P95Milliseconds   int          `json:"p95Milliseconds"`      // 95th percentile
+	P99Milliseconds   int          `json:"p99Milliseconds"`      // 99th percentile
+	TotalMilliseconds int          `json:"totalMilliseconds"`    // total milliseconds
+	IsCollectionScan  bool         `json:"isCollectionScan"`     // COLLSCAN
+	IndexUsed         string       `json:"indexUsed"`            // index used
+	QueryHash         string       `json:"queryHash"`            // queryHash of 4.2 and later
+	PlanCacheKey      string       `json:"planCacheKey"`         // planCacheKey of 4.2 and later
+	KeysExamined      int          `json:"keysExamined"`         // total keysExamined
+	DocsExamined      int          `json:"docsExamined"`         // total docsExamined
+	NReturned         int          `json:"nreturned"`            // total nreturned
+	AvgResLen         int          `json:"averageReslen"`        // average reslen
+	ScannedRatio      float64      `json:"scannedReturnedRatio"` // examined / returned
+	IsInefficient     bool         `json:"isInefficient"`        // indexed but scanned too many
+	Replanned         int          `json:"replanned"`            // number of ops replanned
+	FromMultiPlanner  int          `json:"fromMultiPlanner"`     // number of ops planned by the multi-planner
+	InMemorySorts     int          `json:"inMemorySorts"`        // number of ops with a blocking sort stage
+	SpilledSorts      int          `json:"usedDisk"`             // number of ops used disk to sort
+	WriteConflicts    int          `json:"writeConflicts"`       // total writeConflicts
+	LockWaits         int          `json:"lockWaits"`            // number of ops waited for locks
+	LockWaitMicros    int          `json:"timeAcquiringMicros"`  // total time waited for locks
+	MaxShards         int          `json:"maxShards"`            // max number of shards targeted
+	AvgShards         float64      `json:"averageShards"`        // average number of shards targeted
+	ScatterGather     int          `json:"scatterGather"`        // number of ops targeted all shards
+	FromRouter        int          `json:"fromRouter"`           // number of ops routed from mongos
+	AppNames          []string     `json:"appNames"`             // client applications, the most frequent first
+	Hosts             []string     `json:"hosts"`                // hosts of interleaved logs, the most frequent first
+	Examples          []ExampleDoc `json:"examples,omitempty"`   // the slowest statements with literal values if retained
 }
 
 // Write header in the ScreenOutputFormatter
@@ -149,6 +301,67 @@ func (formatter *ScreenOutputFormatter) WriteLine(buffer *bytes.Buffer, value *L
 		output = fmt.Sprintf("|...index:  \x1b[32;1m%-128s\x1b[0m|\n", value.IndexUsed)
 		buffer.WriteString(output)
 	}
+	if value.IsInefficient {
+		estr := fmt.Sprintf("keys: %d, docs: %d, returned: %d, ratio: %.0f", value.KeysExamined, value.DocsExamined, value.NReturned, value.ScannedRatio)
+		output = fmt.Sprintf("|...examined: \x1b[33;1m%-127s\x1b[0m|\n", estr)
+		buffer.WriteString(output)
+	}
+	if len(value.AppNames) > 0 && (len(value.AppNames) > 1 || value.AppNames[0] != noAppName) {
+		output = fmt.Sprintf("|...apps:    %-127s|\n", strings.Join(value.AppNames, ", "))
+		buffer.WriteString(output)
+	}
+	if len(value.Hosts) > 1 {
+		output = fmt.Sprintf("|...hosts:   %-127s|\n", strings.Join(value.Hosts, ", "))
+		buffer.WriteString(output)
+	}
+	if value.WriteConflicts > 0 || value.LockWaits > 0 {
+		pstr := fmt.Sprintf("writeConflicts: %d, lock waits: %d of %d, timeAcquiring: %s", value.WriteConflicts, value.LockWaits,
+			value.Count, strings.TrimSpace(MilliToTimeString(float64(value.LockWaitMicros)/1000)))
+		output = fmt.Sprintf("|...locks:   \x1b[33;1m%-127s\x1b[0m|\n", pstr)
+		buffer.WriteString(output)
+	}
+	if value.MaxShards > 0 {
+		pstr := fmt.Sprintf("avg: %.1f, max: %d, scatter-gather: %d of %d", value.AvgShards, value.MaxShards, value.ScatterGather, value.Count)
+		if value.ScatterGather > 0 {
+			output = fmt.Sprintf("|...shards:  \x1b[31;1m%-127s\x1b[0m|\n", pstr)
+		} else {
+			output = fmt.Sprintf("|...shards:  %-127s|\n", pstr)
+		}
+		buffer.WriteString(output)
+	}
+	if value.Replanned > 0 {
+		pstr := fmt.Sprintf("replanned: %d of %d, fromMultiPlanner: %d", value.Replanned, value.Count, value.FromMultiPlanner)
+		output = fmt.Sprintf("|...plans:   \x1b[33;1m%-127s\x1b[0m|\n", pstr)
+		buffer.WriteString(output)
+	}
+	if value.InMemorySorts > 0 || value.SpilledSorts > 0 {
+		pstr := fmt.Sprintf("in-memory sorts: %d of %d, usedDisk: %d", value.InMemorySorts, value.Count, value.SpilledSorts)
+		if value.SpilledSorts > 0 {
+			output = fmt.Sprintf("|...sorts:   \x1b[31;1m%-127s\x1b[0m|\n", pstr)
+		} else {
+			output = fmt.Sprintf("|...sorts:   \x1b[33;1m%-127s\x1b[0m|\n", pstr)
+		}
+		buffer.WriteString(output)
+	}
+	if value.QueryHash != "" {
+		output = fmt.Sprintf("|...hash:    %-127s|\n", "queryHash: "+value.QueryHash+", planCacheKey: "+value.PlanCacheKey)
+		buffer.WriteString(output)
+	}
+	for _, example := range value.Examples {
+		pstr := fmt.Sprintf("%s %s", strings.TrimSpace(MilliToTimeString(float64(example.Milli))), example.Statement)
+		if len(pstr) > 127 {
+			pstr = pstr[:124] + "..."
+		}
+		output = fmt.Sprintf("|...example: %-127s|\n", pstr)
+		buffer.WriteString(output)
+	}
+	if value.Count > 1 {
+		pstr := fmt.Sprintf("p50: %s, p90: %s, p95: %s, p99: %s", strings.TrimSpace(MilliToTimeString(float64(value.P50Milliseconds))),
+			strings.TrimSpace(MilliToTimeString(float64(value.P90Milliseconds))), strings.TrimSpace(MilliToTimeString(float64(value.P95Milliseconds))),
+			strings.TrimSpace(MilliToTimeString(float64(value.P99Milliseconds))))
+		output = fmt.Sprintf("|...latency: %-127s|\n", pstr)
+		buffer.WriteString(output)
+	}
 }
 
 // Write Footer for the ScreenOutputFormatter
@@ -156,12 +369,52 @@ func (formatter *ScreenOutputFormatter) WriteFooter(buffer *bytes.Buffer) {
 	buffer.WriteString("+----------+--------+------+--------+------+---------------------------------+--------------------------------------------------------------+\n")
 }
 
+// GetOutput returns server info, slow ops, and a table of ops patterns
+func (formatter *ScreenOutputFormatter) GetOutput(li *LogInfo) string {
+	var buffer bytes.Buffer
+	summaries := li.getSlowOpsSummaries()
+	if len(li.OpsPatterns) > 0 {
+		summaries = append(summaries, li.getRollupsSummary())
+	}
+	patterns, more := li.getLimitedOpsPatterns()
+	formatter.WriteHeader(&buffer)
+	for _, value := range patterns {
+		line := ConverOpPerformanceDocumentToLogInfoLineAnalytics(&value)
+		formatter.WriteLine(&buffer, &line)
+	}
+	formatter.WriteFooter(&buffer)
+	if more > 0 {
+		buffer.WriteString(fmt.Sprintf("... and %d more patterns\n", more))
+	}
+	summaries = append(summaries, buffer.String())
+	return strings.Join(summaries, "\n")
+}
+
+// WriteHeader starts a JSON array
 func (formatter *JSONOutputFormatter) WriteHeader(buffer *bytes.Buffer) {
-	buffer.WriteString("[\n")
+	formatter.lines = 0
+	if formatter.NDJSON == false {
+		buffer.WriteString("[")
+	}
 }
 
+// WriteFooter ends a JSON array
 func (formatter *JSONOutputFormatter) WriteFooter(buffer *bytes.Buffer) {
-	buffer.WriteString("]\n")
+	if formatter.NDJSON == false {
+		buffer.WriteString("\n]\n")
+	}
+}
+
+// GetOutput returns ops patterns in JSON
+func (formatter *JSONOutputFormatter) GetOutput(li *LogInfo) string {
+	var buffer bytes.Buffer
+	formatter.WriteHeader(&buffer)
+	for _, value := range li.OpsPatterns {
+		line := ConverOpPerformanceDocumentToLogInfoLineAnalytics(&value)
+		formatter.WriteLine(&buffer, &line)
+	}
+	formatter.WriteFooter(&buffer)
+	return buffer.String()
 }
 
 func ConverOpPerformanceDocumentToLogInfoLineAnalytics(value *OpPerformanceDoc) LogInfoLineAnalytics {
@@ -172,34 +425,83 @@ func ConverOpPerformanceDocumentToLogInfoLineAnalytics(value *OpPerformanceDoc)
 	stats.Namespace = value.Namespace
 	stats.TotalMilliseconds = value.TotalMilli
 	stats.Count = value.Count
+	stats.MinMilliseconds = value.Histogram.Min
+	stats.MaxMilliseconds = value.MaxMilli
 	stats.AvgMilliseconds = float64(value.TotalMilli) / float64(value.Count)
+	stats.P50Milliseconds = value.Histogram.Percentile(50)
+	stats.P90Milliseconds = value.Histogram.Percentile(90)
+	stats.P95Milliseconds = value.Histogram.Percentile(95)
+	stats.P99Milliseconds = value.Histogram.Percentile(99)
 	stats.IsCollectionScan = value.Scan == COLLSCAN
 
 	if value.Index != "" {
 		stats.IndexUsed = value.Index
 	}
+	stats.KeysExamined = value.KeysExamined
+	stats.DocsExamined = value.DocsExamined
+	stats.NReturned = value.NReturned
+	stats.AvgResLen = value.ResLen / value.Count
+	stats.ScannedRatio = getScannedRatio(value.KeysExamined, value.DocsExamined, value.NReturned)
+	stats.IsInefficient = stats.IsCollectionScan == false && stats.ScannedRatio >= inefficientRatio
+	stats.AppNames = getAppNames(value.Apps)
+	stats.Hosts = getAppNames(value.Hosts)
+	stats.Replanned = value.Replanned
+	stats.FromMultiPlanner = value.FromMultiPlanner
+	stats.InMemorySorts = value.InMemorySorts
+	stats.SpilledSorts = value.SpilledSorts
+	stats.QueryHash = value.QueryHash
+	stats.PlanCacheKey = value.PlanCacheKey
+	stats.WriteConflicts = value.WriteConflicts
+	stats.LockWaits = value.LockWaits
+	stats.LockWaitMicros = value.LockWaitMicros
+	stats.MaxShards, stats.AvgShards = getShardsStats(value.NShards)
+	stats.ScatterGather = value.ScatterGather
+	stats.FromRouter = value.FromRouter
+	stats.Examples = value.Examples
 
 	return stats
 }
 
+// WriteLine writes an ops pattern as a JSON object
 func (formatter *JSONOutputFormatter) WriteLine(buffer *bytes.Buffer, value *LogInfoLineAnalytics) {
 	// filter, command, namespace
 	data, _ := json.Marshal(value)
-	buffer.Write(data)
+	if formatter.NDJSON == true {
+		buffer.Write(data)
+		buffer.WriteString("\n")
+		return
+	}
+	if formatter.lines > 0 {
+		buffer.WriteString(",")
+	}
 	buffer.WriteString("\n")
+	buffer.Write(data)
+	formatter.lines++
 }
 
 // NewLogInfo -
 func NewLogInfo(filename string, exportType string) *LogInfo {
-	li := LogInfo{filename: filename, collscan: false, silent: false, verbose: false}
-	li.OutputFilename = filepath.Base(filename)
+	li, _ := NewLogInfoFromFiles([]string{filename}, exportType)
+	return li
+}
+
+// NewLogInfoFromFiles returns LogInfo of log files, directories, or glob patterns
+func NewLogInfoFromFiles(filenames []string, exportType string) (*LogInfo, error) {
+	if len(filenames) == 0 {
+		return nil, errors.New("no log files")
+	}
+	filename := filenames[0]
+	li := LogInfo{exportType: exportType, filename: filename, filenames: filenames, collscan: false, silent: false,
+		topSlowOps: defaultTopSlowOps, verbose: false}
+	li.OutputFilename = strings.Replace(filepath.Base(filename), "*", "", -1)
 	if strings.HasSuffix(li.OutputFilename, ".gz") {
 		li.OutputFilename = li.OutputFilename[:len(li.OutputFilename)-3]
 	}
-	// li.FormattedOutputFile += ".json" // csv, tsv etc
-	// li.Formatter = new
 	li.OutputFilename += ".enc"
-	return &li
+	if strings.HasPrefix(exportType, "grafana") {
+		li.span = defaultGrafanaSpan
+	}
+	return &li, nil
 }
 
 // SetCollscan -
@@ -207,6 +509,24 @@ func (li *LogInfo) SetCollscan(collscan bool) {
 	li.collscan = collscan
 }
 
+// SetTopSlowOps sets number of slowest ops to keep, 0 to keep none
+func (li *LogInfo) SetTopSlowOps(n int) {
+	li.topSlowOps = n
+	if n >= 0 && len(li.SlowOps) > n {
+		li.SlowOps = li.SlowOps[:n]
+	}
+}
+
+// SetEncryptionKey sets an AES key to encrypt the .enc file with, nil for not encrypted
+func (li *LogInfo) SetEncryptionKey(key []byte) {
+	li.encryptionKey = key
+}
+
+// SetFormatter sets output formatter, e.g. &HTMLOutputFormatter{}
+func (li *LogInfo) SetFormatter(formatter OutputFormatterBase) {
+	li.formatter = formatter
+}
+
 // SetSilent -
 func (li *LogInfo) SetSilent(silent bool) {
 	li.silent = silent
@@ -217,53 +537,65 @@ func (li *LogInfo) SetVerbose(verbose bool) {
 	li.verbose = verbose
 }
 
-func getConfigOptions(reader *bufio.Reader) []string {
-	matched := regexp.MustCompile(`^\S+ .? CONTROL\s+\[\w+\] (\w+(:)?) (.*)$`)
-	var err error
-	var buf []byte
-	var strs []string
-
-	for {
-		buf, _, err = reader.ReadLine() // 0x0A separator = newline
-		if err != nil {
-			break
-		} else if matched.MatchString(string(buf)) == true {
-			result := matched.FindStringSubmatch(string(buf))
-			if result[1] == "db" {
-				s := "db " + result[3]
-				strs = append(strs, s)
-			} else if result[1] == "options:" {
-				re := regexp.MustCompile(`((\S+):)`)
-				body := re.ReplaceAllString(result[3], "\"$1\":")
-				var buf bytes.Buffer
-				json.Indent(&buf, []byte(body), "", "  ")
-
-				strs = append(strs, "config options:")
-				strs = append(strs, string(buf.Bytes()))
-				return strs
-			}
+// getConfigOptions returns db version or config options of a CONTROL log line, isOptions is true for config options
+func getConfigOptions(str string) (strs []string, isOptions bool) {
+	if strings.Contains(str, " CONTROL ") == false {
+		return strs, false
+	}
+	if result := configRegex.FindStringSubmatch(str); len(result) > 0 {
+		if result[1] == "db" {
+			strs = append(strs, "db "+result[3])
+		} else if result[1] == "options:" {
+			re := regexp.MustCompile(`((\S+):)`)
+			body := re.ReplaceAllString(result[3], "\"$1\":")
+			var buf bytes.Buffer
+			json.Indent(&buf, []byte(body), "", "  ")
+			strs = append(strs, "config options:", string(buf.Bytes()))
+			return strs, true
 		}
 	}
-	return strs
+	return strs, false
 }
 
 // Analyze -
 func (li *LogInfo) Analyze() (string, error) {
+	return li.AnalyzeContext(context.Background())
+}
+
+// AnalyzeContext parses logs, or reads results of an .enc file, and returns a summary, parsing stops when ctx is done
+func (li *LogInfo) AnalyzeContext(ctx context.Context) (string, error) {
 	var err error
 
+	if err = validateOpsSortBy(li.sortBy); err != nil {
+		return "", err
+	}
+	if li.formatter == nil && li.exportType != "" {
+		if li.formatter, err = GetFormatter(li.exportType); err != nil {
+			return "", err
+		}
+	}
+
 	if strings.HasSuffix(li.filename, ".enc") == true {
 		var data []byte
 		if data, err = ioutil.ReadFile(li.filename); err != nil {
 			return "", err
 		}
+		if data, err = decryptArtifact(data, li.encryptionKey); err != nil {
+			return "", err
+		}
 		buffer := bytes.NewBuffer(data)
 		dec := gob.NewDecoder(buffer)
 		if err = dec.Decode(li); err != nil {
 			return "", err
 		}
+		// encoded in the order of the run persisted it, e.g. by namespace
+		sortOpsPatternsBy(li.OpsPatterns, li.sortBy)
+		if li.redact == true { // encoded by an earlier run without redaction
+			li.redactResults()
+		}
 		li.OutputFilename = ""
 	} else {
-		if err = li.Parse(); err != nil {
+		if err = li.ParseContext(ctx); err != nil {
 			return "", err
 		}
 		var data bytes.Buffer
@@ -271,48 +603,139 @@ func (li *LogInfo) Analyze() (string, error) {
 		if err = enc.Encode(li); err != nil {
 			log.Println("encode error:", err)
 		}
-		ioutil.WriteFile(li.OutputFilename, data.Bytes(), 0644)
+		if err = writeArtifact(li.OutputFilename, data.Bytes(), false, li.encryptionKey); err != nil {
+			log.Println("write error:", err)
+		}
 	}
 	return li.printLogsSummary(), nil
 }
 
 // Parse -
 func (li *LogInfo) Parse() error {
+	return li.ParseContext(context.Background())
+}
+
+// ParseContext parses log files and stops when ctx is done
+func (li *LogInfo) ParseContext(ctx context.Context) error {
 	var err error
-	var reader *bufio.Reader
-	var file *os.File
-	var opsMap map[string]OpPerformanceDoc
+	var filenames []string
 
-	opsMap = make(map[string]OpPerformanceDoc)
-	if file, err = os.Open(li.filename); err != nil {
+	li.opsMap = make(map[string]OpPerformanceDoc)
+	defer li.removeSpilledPatterns() // merged and removed when sorted, otherwise left by an error
+	if filenames, err = getLogFilenames(li.filenames); err != nil {
 		return err
 	}
-	defer file.Close()
+	infos := []string{}
+	for _, filename := range filenames {
+		if err = li.parseFile(ctx, filename); err != nil {
+			return err
+		}
+		if len(filenames) > 1 && li.mongoInfo != "" {
+			infos = append(infos, "== "+filename+"\n"+li.mongoInfo)
+		} else if li.mongoInfo != "" {
+			infos = append(infos, li.mongoInfo)
+		}
+	}
+	li.mongoInfo = strings.Join(infos, "\n")
+	li.source = ""
+	li.closeCursors()
+	li.sortOpsPatterns()
+	return nil
+}
 
-	if reader, err = util.NewReader(file); err != nil {
-		return err
+// getLogFilenames expands directories and glob patterns to log files
+func getLogFilenames(names []string) ([]string, error) {
+	filenames := []string{}
+	for _, name := range names {
+		if fi, err := os.Stat(name); err == nil && fi.IsDir() == true {
+			var files []os.FileInfo
+			if files, err = ioutil.ReadDir(name); err != nil {
+				return filenames, err
+			}
+			for _, f := range files {
+				if f.IsDir() == true || strings.HasPrefix(f.Name(), ".") || strings.HasSuffix(f.Name(), ".enc") {
+					continue
+				}
+				filenames = append(filenames, filepath.Join(name, f.Name()))
+			}
+			continue
+		}
+		matches, err := filepath.Glob(name)
+		if err != nil {
+			return filenames, err
+		} else if len(matches) == 0 {
+			filenames = append(filenames, name) // let it fail when opened
+			continue
+		}
+		filenames = append(filenames, matches...)
 	}
-	lineCounts, _ := util.CountLines(reader)
+	return filenames, nil
+}
 
-	file.Seek(0, 0)
-	reader, _ = util.NewReader(file)
-	var buffer bytes.Buffer
-	if strs := getConfigOptions(reader); len(strs) > 0 {
-		for _, s := range strs {
-			buffer.WriteString(s + "\n")
+// readLogLines calls fn with every line of log files, directories, or glob patterns
+func readLogLines(names []string, fn func(line string)) error {
+	var err error
+	var filenames []string
+	if filenames, err = getLogFilenames(names); err != nil {
+		return err
+	}
+	for _, filename := range filenames {
+		var file *os.File
+		var reader *bufio.Reader
+		if file, err = os.Open(filename); err != nil {
+			return err
+		}
+		if reader, err = util.NewReader(file); err != nil {
+			file.Close()
+			return err
+		}
+		for {
+			buf, isPrefix, rerr := reader.ReadLine()
+			str := string(buf)
+			for isPrefix == true && rerr == nil {
+				var bbuf []byte
+				bbuf, isPrefix, rerr = reader.ReadLine()
+				str += string(bbuf)
+			}
+			if rerr != nil {
+				break
+			}
+			fn(str)
 		}
+		file.Close()
 	}
-	li.mongoInfo = buffer.String()
+	return nil
+}
+
+// parseFile parses a log file and aggregates its slow ops
+func (li *LogInfo) parseFile(ctx context.Context, filename string) error {
+	var err error
+	var reader *bufio.Reader
+	var file *os.File
+
+	li.source = filename
+	if file, err = os.Open(filename); err != nil {
+		return err
+	}
+	defer file.Close()
 
-	matched := regexp.MustCompile(`^\S+ \S+\s+(\w+)\s+\[\w+\] (\w+) (\S+) \S+: (.*) (\d+)ms$`) // SERVER-37743
-	file.Seek(0, 0)
+	var stat os.FileInfo
+	if stat, err = file.Stat(); err != nil {
+		return err
+	}
 	if reader, err = util.NewReader(file); err != nil {
 		return err
 	}
+	var buffer bytes.Buffer
+	hasOptions := false
 	index := 0
 	for {
-		if index%25 == 1 && li.silent == false {
-			fmt.Fprintf(os.Stderr, "\r%3d%% ", (100*index)/lineCounts)
+		if index%1000 == 1 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if index%1000 == 1 && li.silent == false && stat.Size() > 0 {
+			pos, _ := file.Seek(0, io.SeekCurrent) // bytes read of the file, compressed or not
+			fmt.Fprintf(os.Stderr, "\r%3d%% ", (100*pos)/stat.Size())
 		}
 		var buf []byte
 		var isPrefix bool
@@ -324,241 +747,526 @@ func (li *LogInfo) Parse() error {
 			str += string(bbuf)
 		}
 		index++
-		scan := ""
-		aggStages := ""
 		if err != nil {
 			break
-		} else if matched.MatchString(str) == true {
-			if strings.Index(str, "COLLSCAN") >= 0 {
-				scan = COLLSCAN
-			}
-			if li.collscan == true && scan != COLLSCAN {
-				continue
+		}
+		str = li.stripHostPrefix(str)
+		if hasOptions == false {
+			var strs []string
+			strs, hasOptions = getConfigOptions(str)
+			for _, s := range strs {
+				buffer.WriteString(s + "\n")
 			}
-			result := matched.FindStringSubmatch(str)
-			isFound := false
-			bpos := 0 // begin position
-			epos := 0 // end position
-			for _, r := range result[4] {
-				epos++
-				if isFound == false && r == '{' {
-					isFound = true
-					bpos++
-				} else if isFound == true {
-					if r == '{' {
-						bpos++
-					} else if r == '}' {
-						bpos--
-					}
-				}
+		}
+		li.parseLine(str)
+	}
+	li.mongoInfo = buffer.String()
 
-				if isFound == true && bpos == 0 {
-					break
-				}
-			}
+	if li.silent == false {
+		fmt.Fprintf(os.Stderr, "\r     \r")
+	}
+	return nil
+}
 
-			re := regexp.MustCompile(`^(\w+) ({.*})$`)
-			op := result[2]
-			ns := result[3]
-			if ns == "local.oplog.rs" || strings.HasSuffix(ns, ".$cmd") == true {
-				continue
+// Follow tails a growing log file like tail -f, aggregates slow ops incrementally,
+// and sends a summary to the channel every interval until quit receives
+func (li *LogInfo) Follow(channel chan string, interval time.Duration, quit chan bool) error {
+	var err error
+	var file *os.File
+	var stat os.FileInfo
+
+	if strings.HasSuffix(li.filename, ".gz") == true {
+		return errors.New("cannot follow a compressed file " + li.filename)
+	}
+	if file, err = os.Open(li.filename); err != nil {
+		return err
+	}
+	defer func() { file.Close() }()
+	li.opsMap = make(map[string]OpPerformanceDoc)
+	li.source = li.filename
+	reader := bufio.NewReader(file)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	partial := ""
+	for {
+		select {
+		case <-quit:
+			return nil
+		case <-ticker.C:
+			li.sortOpsPatterns()
+			msg := li.printLogsSummary()
+			if li.formatter == nil {
+				msg = strings.Join(append(li.GetSummaries(), msg), "\n")
 			}
-			filter := result[4][:epos]
-			ms := result[5]
-			if op == "command" {
-				idx := strings.Index(filter, "command: ")
-				if idx > 0 {
-					filter = filter[idx+len("command: "):]
-				}
-				res := re.FindStringSubmatch(filter)
-				if len(res) < 3 {
-					continue
+			select {
+			case channel <- msg:
+			case <-quit:
+				return nil
+			}
+		default:
+		}
+		line, rerr := reader.ReadString('\n')
+		if rerr == nil {
+			li.parseLine(li.stripHostPrefix(strings.TrimRight(partial+line, "\r\n")))
+			partial = ""
+			continue
+		} else if rerr != io.EOF {
+			return rerr
+		}
+		partial += line
+		// reopen if the log is rotated or truncated
+		pos, _ := file.Seek(0, io.SeekCurrent)
+		if stat, err = os.Stat(li.filename); err == nil {
+			fi, _ := file.Stat()
+			if os.SameFile(fi, stat) == false || stat.Size() < pos {
+				file.Close()
+				if file, err = os.Open(li.filename); err != nil {
+					return err
 				}
-				op = res[1]
-				filter = res[2]
+				reader.Reset(file)
+				partial = ""
 			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
 
-			if hasFilter(op) == false {
-				continue
+// parseLine parses a log line and aggregates a slow op
+func (li *LogInfo) parseLine(str string) {
+	scan := ""
+	aggStages := ""
+	sortStr := ""
+	if li.parseTransaction(str) == true || li.parseIndexBuild(str) == true || li.parseCachePressure(str) == true {
+		return
+	}
+	if slowOpRegex.MatchString(str) == false {
+		li.setHost(str)
+		return
+	}
+	if strings.Index(str, "COLLSCAN") >= 0 {
+		scan = COLLSCAN
+	}
+	if li.collscan == true && scan != COLLSCAN {
+		return
+	}
+	result := slowOpRegex.FindStringSubmatch(str)
+	isFound := false
+	bpos := 0 // begin position
+	epos := 0 // end position
+	for _, r := range result[4] {
+		epos++
+		if isFound == false && r == '{' {
+			isFound = true
+			bpos++
+		} else if isFound == true {
+			if r == '{' {
+				bpos++
+			} else if r == '}' {
+				bpos--
 			}
-			if op == "delete" && strings.Index(filter, "writeConcern:") >= 0 {
-				continue
-			} else if op == "find" {
-				nstr := "{ }"
-				s := getDocByField(filter, "filter: ")
-				if s != "" {
-					nstr = s
-				}
-				s = getDocByField(filter, "sort: ")
-				if s != "" {
-					nstr = nstr + ", sort: " + s
-				}
-				filter = nstr
-			} else if op == "count" || op == "distinct" {
-				nstr := ""
-				s := getDocByField(filter, "query: ")
-				if s != "" {
-					nstr = s
-				}
-				filter = nstr
-			} else if op == "delete" || op == "update" || op == "remove" || op == "findAndModify" {
-				var s string
-				// if result[1] == "WRITE" {
-				if strings.Index(filter, "query: ") >= 0 {
-					s = getDocByField(filter, "query: ")
-				} else {
-					s = getDocByField(filter, "q: ")
-				}
-				if s != "" {
-					filter = s
-				}
-			} else if op == "aggregate" || (op == "getmore" && strings.Index(filter, "pipeline:") > 0) {
-				s := ""
-				for _, mstr := range []string{"pipeline: [ { $match: ", "pipeline: [ { $sort: "} {
-					s = getDocByField(result[4], mstr)
-					if s != "" {
-						filter = s
-						x := strings.Index(result[4], "$group: ")
-						y := strings.Index(result[4], "$sort: ")
-						if x > 0 && (x < y || y < 0) {
-							aggStages = ", group: " + strings.ReplaceAll(getDocByField(result[4], "$group: "), "1.0", "1")
-						}
-						srt := getDocByField(result[4], "$sort: ")
-						if srt != "" {
-							aggStages += ", sort: " + strings.ReplaceAll(srt, "1.0", "1")
-						}
-						break
-					}
-				}
-				if s == "" {
-					if scan == "COLLSCAN" { // it's a collection scan without $match or $sort
-						filter = "{}"
-					} else {
-						continue
-					}
+		}
+
+		if isFound == true && bpos == 0 {
+			break
+		}
+	}
+
+	re := regexp.MustCompile(`^(\w+) ({.*})$`)
+	op := result[2]
+	ns := result[3]
+	if ns == "local.oplog.rs" || (strings.HasSuffix(ns, ".$cmd") == true && isTxnCommand(result[4]) == false) {
+		return
+	}
+	filter := result[4][:epos]
+	ms := result[5]
+	if op == "command" {
+		idx := strings.Index(filter, "command: ")
+		if idx > 0 {
+			filter = filter[idx+len("command: "):]
+		}
+		res := re.FindStringSubmatch(filter)
+		if len(res) < 3 {
+			return
+		}
+		op = res[1]
+		filter = res[2]
+	}
+	if op == "commitTransaction" || op == "abortTransaction" {
+		milli, _ := strconv.Atoi(ms)
+		li.aggregateTxnCommand(str, op, ns, milli)
+		return
+	}
+
+	if hasFilter(op) == false {
+		return
+	}
+	statement := op + " " + filter
+	if op == "delete" && strings.Index(filter, "writeConcern:") >= 0 {
+		return
+	} else if op == "find" {
+		nstr := "{ }"
+		s := getDocByField(filter, "filter: ")
+		if s != "" {
+			nstr = s
+		}
+		sortStr = getDocByField(filter, "sort: ")
+		filter = nstr
+	} else if op == "count" || op == "distinct" {
+		nstr := ""
+		s := getDocByField(filter, "query: ")
+		if s != "" {
+			nstr = s
+		}
+		filter = nstr
+	} else if op == "delete" || op == "update" || op == "remove" || op == "findAndModify" {
+		var s string
+		// if result[1] == "WRITE" {
+		if strings.Index(filter, "query: ") >= 0 {
+			s = getDocByField(filter, "query: ")
+		} else {
+			s = getDocByField(filter, "q: ")
+		}
+		if s != "" {
+			filter = s
+		}
+	} else if op == "aggregate" || (op == "getmore" && strings.Index(filter, "pipeline:") > 0) {
+		s := ""
+		for _, mstr := range []string{"pipeline: [ { $match: ", "pipeline: [ { $sort: "} {
+			s = getDocByField(result[4], mstr)
+			if s != "" {
+				filter = s
+				x := strings.Index(result[4], "$group: ")
+				y := strings.Index(result[4], "$sort: ")
+				if x > 0 && (x < y || y < 0) {
+					aggStages = ", group: " + getStageShape(getDocByField(result[4], "$group: "))
 				}
-			} else if op == "getMore" || op == "getmore" {
-				s := getDocByField(result[4], "originatingCommand: ")
-				if s != "" {
-					s = getDocByField(s, "filter: ")
-					for _, mstr := range []string{"filter: ", "pipeline: [ { $match: ", "pipeline: [ { $sort: "} {
-						s = getDocByField(result[4], mstr)
-						if s != "" {
-							filter = s
-							break
-						}
-					}
-					if s == "" {
-						continue
-					}
-				} else {
-					continue
+				srt := getDocByField(result[4], "$sort: ")
+				if srt != "" {
+					aggStages += ", sort: " + getStageShape(srt)
 				}
+				break
 			}
-			index := getDocByField(str, "planSummary: IXSCAN")
-			if index == "" && strings.Index(str, "planSummary: EOF") >= 0 {
-				index = "EOF"
-			}
-			if index == "" && strings.Index(str, "planSummary: IDHACK") >= 0 {
-				index = "IDHACK"
-			}
-			if scan == "" && strings.Index(str, "planSummary: COUNT_SCAN") >= 0 {
-				index = "COUNT_SCAN"
+		}
+		if s == "" {
+			if scan == "COLLSCAN" { // it's a collection scan without $match or $sort
+				filter = "{}"
+			} else {
+				return
 			}
-			filter = removeInElements(filter, "$in: [ ")
-			filter = removeInElements(filter, "$nin: [ ")
-			filter = removeInElements(filter, "$in: [ ")
-			filter = removeInElements(filter, "$nin: [ ")
-
-			isRegex := strings.Index(filter, "{ $regex: ")
-			if isRegex >= 0 {
-				cnt := 0
-				for _, r := range filter[isRegex:] {
-					if r == '}' {
-						break
-					}
-					cnt++
+		}
+	} else if op == "getMore" || op == "getmore" {
+		s := getDocByField(result[4], "originatingCommand: ")
+		if s != "" {
+			s = getDocByField(s, "filter: ")
+			for _, mstr := range []string{"filter: ", "pipeline: [ { $match: ", "pipeline: [ { $sort: "} {
+				s = getDocByField(result[4], mstr)
+				if s != "" {
+					filter = s
+					break
 				}
-				filter = filter[:(isRegex+10)] + "/.../.../" + filter[(isRegex+cnt):]
 			}
-			re = regexp.MustCompile(`(: "[^"]*"|: -?\d+(\.\d+)?|: new Date\(\d+?\)|: true|: false)`)
-			filter = re.ReplaceAllString(filter, ":1")
-			re = regexp.MustCompile(`, shardVersion: \[.*\]`)
-			filter = re.ReplaceAllString(filter, "")
-			re = regexp.MustCompile(`( ObjectId\('\S+'\))|(UUID\("\S+"\))|( Timestamp\(\d+, \d+\))|(BinData\(\d+, \S+\))`)
-			filter = re.ReplaceAllString(filter, "1")
-			re = regexp.MustCompile(`(: \/.*\/(.?) })`)
-			filter = re.ReplaceAllString(filter, ": /regex/$2}")
-			filter = strings.Replace(strings.Replace(filter, "{ ", "{", -1), " }", "}", -1)
-			filter = reorderFilterFields(filter)
-			filter += aggStages
-			key := op + "." + filter + "." + scan
-			_, ok := opsMap[key]
-			milli, _ := strconv.Atoi(ms)
-			if len(li.SlowOps) < 10 || milli > li.SlowOps[9].Milli {
-				li.SlowOps = append(li.SlowOps, SlowOps{Milli: milli, Log: str})
-				sort.Slice(li.SlowOps, func(i, j int) bool {
-					return li.SlowOps[i].Milli > li.SlowOps[j].Milli
-				})
-				if len(li.SlowOps) > 10 {
-					li.SlowOps = li.SlowOps[:10]
-				}
+			if s == "" {
+				return
 			}
+		} else {
+			return
+		}
+	}
+	index := getDocByField(str, "planSummary: IXSCAN")
+	if index == "" && strings.Index(str, "planSummary: EOF") >= 0 {
+		index = "EOF"
+	}
+	if index == "" && strings.Index(str, "planSummary: IDHACK") >= 0 {
+		index = "IDHACK"
+	}
+	if scan == "" && strings.Index(str, "planSummary: COUNT_SCAN") >= 0 {
+		index = "COUNT_SCAN"
+	}
+	sortPattern := ""
+	if sortDoc, err := parseShellDoc(sortStr); err == nil && sortStr != "" {
+		sortPattern = getSortPattern(sortDoc)
+	}
+	variant := ""
+	if li.variants == true {
+		variant = getFilterVariant(filter)
+	}
+	filter = getFilterPattern(filter)
+	filter += aggStages
+	milli, _ := strconv.Atoi(ms)
+	if li.getMores == true && (op == "getMore" || op == "getmore") {
+		li.aggregateGetMore(str, ns, filter, milli)
+		return
+	} else if li.getMores == true {
+		li.openCursor(str, milli)
+	}
+	li.aggregate(opStats{appName: getAppName(str), conn: li.getHostKey(getConnID(str)), command: op, namespace: ns, filter: filter, scan: scan, index: index, milli: milli, log: str,
+		keysExamined: getLogMetric(str, "keysExamined"), docsExamined: getLogMetric(str, "docsExamined"),
+		nreturned: getReturnedCount(str), reslen: getLogMetric(str, "reslen"),
+		replanned: getLogMetric(str, "replanned") > 0, fromMultiPlanner: getLogMetric(str, "fromMultiPlanner") > 0,
+		hasSortStage: getLogMetric(str, "hasSortStage") > 0, usedDisk: getLogMetric(str, "usedDisk") > 0,
+		writeConflicts: getLogMetric(str, "writeConflicts"), lockWaitMicros: getLockWaitMicros(str),
+		nShards: getLogMetric(str, "nShards"), fromRouter: isFromRouter(str), broadcast: isBroadcastShardVersion(str), sort: sortPattern,
+		queryHash: getLogHash(str, "queryHash"), planCacheKey: getLogHash(str, "planCacheKey"), host: li.lineHost, ts: getLogTime(str), txnKey: getTxnKey(str), variant: variant, statement: statement})
 
-			if ok {
-				max := opsMap[key].MaxMilli
-				if milli > max {
-					max = milli
-				}
-				x := opsMap[key].TotalMilli + milli
-				y := opsMap[key].Count + 1
-				opsMap[key] = OpPerformanceDoc{Command: opsMap[key].Command, Namespace: ns, Filter: opsMap[key].Filter, MaxMilli: max, TotalMilli: x, Count: y, Scan: scan, Index: index}
-			} else {
-				opsMap[key] = OpPerformanceDoc{Command: op, Namespace: ns, Filter: filter, TotalMilli: milli, MaxMilli: milli, Count: 1, Scan: scan, Index: index}
-			}
+}
+
+// aggregate merges stats of an op into ops patterns and keeps top slow ops
+func (li *LogInfo) aggregate(stats opStats) {
+	if li.opsMap == nil {
+		li.opsMap = make(map[string]OpPerformanceDoc)
+	}
+	milli := stats.milli
+	key := stats.command + "." + stats.namespace + "." + stats.filter + "." + stats.sort + "." + stats.scan
+	if stats.queryHash != "" { // the same shape of different namespaces has the same queryHash
+		key = stats.command + "." + stats.namespace + ".queryHash:" + stats.queryHash + "." + stats.scan
+	}
+	if stats.variant != "" {
+		li.aggregateVariant(key, stats)
+	}
+	doc, ok := li.opsMap[key]
+	if li.topSlowOps > 0 && (len(li.SlowOps) < li.topSlowOps || milli > li.SlowOps[li.topSlowOps-1].Milli) {
+		if li.redact == true {
+			stats.log = redactLog(stats.log)
+		}
+		li.SlowOps = append(li.SlowOps, SlowOps{Command: stats.command, Log: stats.log, Milli: milli, Namespace: stats.namespace,
+			PlanSummary: getPlanSummary(stats.scan, stats.index), Source: li.source, Time: stats.ts})
+		sort.Slice(li.SlowOps, func(i, j int) bool {
+			return li.SlowOps[i].Milli > li.SlowOps[j].Milli
+		})
+		if len(li.SlowOps) > li.topSlowOps {
+			li.SlowOps = li.SlowOps[:li.topSlowOps]
 		}
 	}
 
-	li.OpsPatterns = make([]OpPerformanceDoc, 0, len(opsMap))
-	for _, value := range opsMap {
-		li.OpsPatterns = append(li.OpsPatterns, value)
+	if ok == false {
+		doc = OpPerformanceDoc{Apps: map[string]int{}, Command: stats.command, Filter: stats.filter, Histogram: NewLatencyHistogram()}
 	}
-	sort.Slice(li.OpsPatterns, func(i, j int) bool {
-		return float64(li.OpsPatterns[i].TotalMilli)/float64(li.OpsPatterns[i].Count) > float64(li.OpsPatterns[j].TotalMilli)/float64(li.OpsPatterns[j].Count)
-	})
-	if li.silent == false {
-		fmt.Fprintf(os.Stderr, "\r     \r")
+	if doc.Apps == nil {
+		doc.Apps = map[string]int{}
 	}
-	return nil
+	if stats.appName == "" {
+		doc.Apps[noAppName]++
+	} else {
+		doc.Apps[stats.appName]++
+	}
+	li.aggregateApp(stats, key)
+	li.aggregateHost(stats, key)
+	if stats.host != "" {
+		if doc.Hosts == nil {
+			doc.Hosts = map[string]int{}
+		}
+		doc.Hosts[stats.host]++
+	}
+	li.addTxnStatement(stats)
+	li.aggregateTimeBucket(stats)
+	li.addOpsSecond(stats)
+	li.setTimeRange(stats.ts)
+	li.countSlowOpsDuringBuilds(stats)
+	doc.Count++
+	doc.TotalMilli += milli
+	if milli > doc.MaxMilli {
+		doc.MaxMilli = milli
+	}
+	doc.Namespace = stats.namespace
+	doc.Scan = stats.scan
+	if stats.queryHash != "" {
+		doc.QueryHash = stats.queryHash
+		doc.PlanCacheKey = stats.planCacheKey
+	}
+	if stats.sort != "" {
+		doc.Sort = stats.sort
+	}
+	doc.Index = stats.index
+	if li.examples > 0 {
+		if li.redact == true {
+			stats.statement = redactLog(stats.statement)
+		}
+		doc.Examples = addExample(doc.Examples, stats, li.examples)
+	}
+	doc.Histogram.Add(milli)
+	doc.KeysExamined += stats.keysExamined
+	doc.DocsExamined += stats.docsExamined
+	doc.NReturned += stats.nreturned
+	doc.ResLen += stats.reslen
+	doc.WriteConflicts += stats.writeConflicts
+	if stats.nShards > 0 {
+		if doc.NShards == nil {
+			doc.NShards = map[int]int{}
+		}
+		doc.NShards[stats.nShards]++
+	}
+	if stats.broadcast == true {
+		doc.Broadcasts++
+	}
+	if stats.fromRouter == true {
+		doc.FromRouter++
+	}
+	if stats.lockWaitMicros > 0 {
+		doc.LockWaits++
+		doc.LockWaitMicros += stats.lockWaitMicros
+	}
+	if stats.replanned == true {
+		doc.Replanned++
+	}
+	if stats.fromMultiPlanner == true {
+		doc.FromMultiPlanner++
+	}
+	if stats.hasSortStage == true {
+		doc.InMemorySorts++
+	}
+	if stats.usedDisk == true {
+		doc.SpilledSorts++
+	}
+	li.opsMap[key] = doc
+	if li.maxPatterns > 0 && len(li.opsMap) > li.maxPatterns {
+		if err := li.spillPatterns(); err != nil {
+			log.Println("spilling ops patterns failed:", err)
+			li.maxPatterns = 0
+		}
+	}
+}
+
+// sortOpsPatterns sets ops patterns from aggregated results, sorted by average time or the order set
+func (li *LogInfo) sortOpsPatterns() {
+	if err := li.mergeSpilledPatterns(); err != nil {
+		log.Println("reading spilled ops patterns failed:", err)
+	}
+	li.sortApps()
+	li.sortHosts()
+	li.sortTimeSeries()
+	li.sortTransactions()
+	li.sortIndexBuilds()
+	li.sortCachePressure()
+	li.sortCursors()
+	li.sortVariants()
+	li.setNumShards()
+	li.OpsPatterns = make([]OpPerformanceDoc, 0, len(li.opsMap))
+	for _, value := range li.opsMap {
+		if li.NumShards > 1 {
+			value.ScatterGather = value.NShards[li.NumShards]
+		}
+		li.OpsPatterns = append(li.OpsPatterns, value)
+	}
+	sortOpsPatternsBy(li.OpsPatterns, li.sortBy)
+	li.setShardKeyCandidates()
+	li.setBroadcastWrites()
 }
 
 // printLogsSummary prints loginfo summary
 func (li *LogInfo) printLogsSummary() string {
+	if li.formatter != nil {
+		return li.formatter.GetOutput(li)
+	}
+	var buffer bytes.Buffer
+	var formatter JSONOutputFormatter = JSONOutputFormatter{}
+
+	formatter.WriteHeader(&buffer)
+	for _, value := range li.OpsPatterns {
+		var line LogInfoLineAnalytics = ConverOpPerformanceDocumentToLogInfoLineAnalytics(&value)
+		formatter.WriteLine(&buffer, &line)
+	}
+	formatter.WriteFooter(&buffer)
+	return buffer.String()
+}
+
+// GetSummaries returns summaries of hosts, broadcasts, index builds, cache pressure, cursors, variants,
+// transactions, and time series, and server info and top slow ops in verbose mode. They aren't in the JSON output
+// without a formatter so that it stays valid.
+func (li *LogInfo) GetSummaries() []string {
+	return li.getSlowOpsSummaries()
+}
+
+// getSlowOpsSummaries returns server info and top slow ops in verbose mode
+func (li *LogInfo) getSlowOpsSummaries() []string {
 	summaries := []string{}
 	if li.verbose == true {
 		summaries = append([]string{}, li.mongoInfo)
 	}
 	if len(li.SlowOps) > 0 && li.verbose == true {
-		summaries = append(summaries, fmt.Sprintf("Ops slower than 10 seconds (list top %d):", len(li.SlowOps)))
+		summaries = append(summaries, fmt.Sprintf("Slowest ops (list top %d):", len(li.SlowOps)))
+		multiSources := false
+		for _, op := range li.SlowOps {
+			if op.Source != li.SlowOps[0].Source {
+				multiSources = true
+			}
+		}
+		summaries = append(summaries, fmt.Sprintf("%-29s %8s %-14s %-33s %s", "time", "duration", "command", "namespace", "plan summary"))
 		for _, op := range li.SlowOps {
-			// summaries = append(summaries, MilliToTimeString(float64(op.Milli))+" => "+op.Log)
-			summaries = append(summaries, fmt.Sprintf("%s (%s) %dms", op.Log, MilliToTimeString(float64(op.Milli)), op.Milli))
+			ts := ""
+			if op.Time.IsZero() == false {
+				ts = op.Time.Format(logTimeLayout)
+			}
+			str := fmt.Sprintf("%-29s %8s %-14s %-33s %s", ts, strings.TrimSpace(MilliToTimeString(float64(op.Milli))), op.Command, op.Namespace, op.PlanSummary)
+			if multiSources == true {
+				str += " [" + filepath.Base(op.Source) + "]"
+			}
+			summaries = append(summaries, str)
 		}
 		summaries = append(summaries, "\n")
 	}
-	var buffer bytes.Buffer
-	// var formatter ScreenOutputFormatter = ScreenOutputFormatter{}
-	var formatter JSONOutputFormatter = JSONOutputFormatter{}
+	summaries = append(summaries, li.getHostsSummaries()...)
+	if li.verbose == true {
+		summaries = append(summaries, li.getAppsSummaries()...)
+		summaries = append(summaries, li.getShardsSummaries()...)
+		summaries = append(summaries, li.getShardKeysSummaries()...)
+	}
+	summaries = append(summaries, li.getBroadcastWritesSummaries()...)
+	summaries = append(summaries, li.getIndexBuildsSummaries()...)
+	summaries = append(summaries, li.getCachePressureSummaries()...)
+	summaries = append(summaries, li.getCursorsSummaries()...)
+	summaries = append(summaries, li.getVariantsSummaries()...)
+	summaries = append(summaries, li.getTransactionsSummaries()...)
+	summaries = append(summaries, li.getTimeSeriesSummaries()...)
+	return summaries
+}
 
-	formatter.WriteHeader(&buffer)
-	for _, value := range li.OpsPatterns {
-		var line LogInfoLineAnalytics = ConverOpPerformanceDocumentToLogInfoLineAnalytics(&value)
-		formatter.WriteLine(&buffer, &line)
+// getFilterPattern returns a query pattern of a filter of logs, fields are sorted at every level
+func getFilterPattern(filter string) string {
+	doc, err := parseShellDoc(filter)
+	if err != nil { // truncated by mongod, falls back to string replacements
+		return getLegacyFilterPattern(filter)
 	}
-	formatter.WriteFooter(&buffer)
+	return getQueryPattern(doc)
+}
 
-	summaries = append(summaries, buffer.String())
-	return strings.Join(summaries, "\n")
+// getStageShape returns an aggregation stage in a compact form, e.g. {_id: "$color", count: {$sum: 1}}
+func getStageShape(str string) string {
+	doc, err := parseShellDoc(str)
+	if err != nil {
+		return strings.ReplaceAll(str, "1.0", "1")
+	}
+	return getShapeString(doc)
+}
+
+// getLegacyFilterPattern returns a query pattern by string replacements
+func getLegacyFilterPattern(filter string) string {
+	filter = removeInElements(filter, "$in: [ ")
+	filter = removeInElements(filter, "$nin: [ ")
+	filter = removeInElements(filter, "$in: [ ")
+	filter = removeInElements(filter, "$nin: [ ")
+
+	isRegex := strings.Index(filter, "{ $regex: ")
+	if isRegex >= 0 {
+		cnt := 0
+		for _, r := range filter[isRegex:] {
+			if r == '}' {
+				break
+			}
+			cnt++
+		}
+		filter = filter[:(isRegex+10)] + "/.../.../" + filter[(isRegex+cnt):]
+	}
+	re := regexp.MustCompile(`(: "[^"]*"|: -?\d+(\.\d+)?|: new Date\(\d+?\)|: true|: false)`)
+	filter = re.ReplaceAllString(filter, ":1")
+	re = regexp.MustCompile(`, shardVersion: \[.*\]`)
+	filter = re.ReplaceAllString(filter, "")
+	re = regexp.MustCompile(`( ObjectId\('\S+'\))|(UUID\("\S+"\))|( Timestamp\(\d+, \d+\))|(BinData\(\d+, \S+\))`)
+	filter = re.ReplaceAllString(filter, "1")
+	re = regexp.MustCompile(`(: \/.*\/(.?) })`)
+	filter = re.ReplaceAllString(filter, ": /regex/$2}")
+	filter = strings.Replace(strings.Replace(filter, "{ ", "{", -1), " }", "}", -1)
+	filter = reorderFilterFields(filter)
+	return filter
 }
 
 // convert $in: [...] to $in: [ ]
@@ -617,6 +1325,84 @@ func MilliToTimeString(milli float64) string {
 	return avgstr
 }
 
+// getLogMetric returns a numeric metric from a log line, e.g. docsExamined:100
+func getLogMetric(str string, name string) int {
+	idx := strings.Index(str, " "+name+":")
+	if idx < 0 {
+		return 0
+	}
+	value := str[idx+len(name)+2:]
+	n := 0
+	for n < len(value) && value[n] >= '0' && value[n] <= '9' {
+		n++
+	}
+	num, _ := strconv.Atoi(value[:n])
+	return num
+}
+
+// getLogHash returns a hex value of a log line, e.g. queryHash:8E5BC1E9 or "queryHash":"8E5BC1E9"
+func getLogHash(str string, name string) string {
+	idx := strings.Index(str, " "+name+":")
+	if idx < 0 {
+		if idx = strings.Index(str, `"`+name+`":"`); idx < 0 {
+			return ""
+		}
+		idx++
+	}
+	value := strings.TrimPrefix(str[idx+len(name)+2:], `"`)
+	n := 0
+	for n < len(value) && strings.IndexByte("0123456789ABCDEFabcdef", value[n]) >= 0 {
+		n++
+	}
+	return value[:n]
+}
+
+// getPlanSummary returns plan summary of a scan or an index used
+func getPlanSummary(scan string, index string) string {
+	if scan != "" {
+		return scan
+	} else if index == "EOF" || index == "IDHACK" || index == "COUNT_SCAN" {
+		return index
+	} else if index != "" {
+		return "IXSCAN " + index
+	}
+	return ""
+}
+
+// getLockWaitMicros returns total timeAcquiringMicros of all lock types
+func getLockWaitMicros(str string) int {
+	total := 0
+	for _, result := range lockWaitRegex.FindAllStringSubmatch(str, -1) {
+		for _, n := range digitsRegex.FindAllString(result[1], -1) {
+			v, _ := strconv.Atoi(n)
+			total += v
+		}
+	}
+	return total
+}
+
+// getReturnedCount returns nreturned, or number of matched/deleted docs of writes
+func getReturnedCount(str string) int {
+	for _, name := range []string{"nreturned", "nMatched", "ndeleted"} {
+		if strings.Index(str, " "+name+":") >= 0 {
+			return getLogMetric(str, name)
+		}
+	}
+	return 0
+}
+
+// getScannedRatio returns ratio of examined keys or docs to returned docs
+func getScannedRatio(keysExamined int, docsExamined int, nreturned int) float64 {
+	examined := keysExamined
+	if docsExamined > examined {
+		examined = docsExamined
+	}
+	if nreturned == 0 {
+		nreturned = 1
+	}
+	return float64(examined) / float64(nreturned)
+}
+
 func getDocByField(str string, key string) string {
 	ml := gox.NewMongoLog(str)
 	return ml.Get(key)
@@ -0,0 +1,22 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+// GetOpsPatterns returns analytics of ops patterns, sorted by average time, after logs are parsed
+func (li *LogInfo) GetOpsPatterns() []LogInfoLineAnalytics {
+	lines := make([]LogInfoLineAnalytics, 0, len(li.OpsPatterns))
+	for _, value := range li.OpsPatterns {
+		lines = append(lines, ConverOpPerformanceDocumentToLogInfoLineAnalytics(&value))
+	}
+	return lines
+}
+
+// GetSlowOps returns the slowest ops, the slowest first
+func (li *LogInfo) GetSlowOps() []SlowOps {
+	return append([]SlowOps{}, li.SlowOps...)
+}
+
+// GetServerInfo returns server version and config options parsed from logs
+func (li *LogInfo) GetServerInfo() string {
+	return li.mongoInfo
+}
@@ -0,0 +1,48 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestLogInfoAPI(t *testing.T) {
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	if err := li.ParseContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	lines := li.GetOpsPatterns()
+	if len(lines) == 0 || len(lines) != len(li.OpsPatterns) || lines[0].Command != li.OpsPatterns[0].Command {
+		t.Fatal("unexpected ops patterns", lines)
+	}
+	slowOps := li.GetSlowOps()
+	if len(slowOps) == 0 || slowOps[0].Milli < slowOps[len(slowOps)-1].Milli {
+		t.Fatal("unexpected slow ops", slowOps)
+	}
+	if strings.Contains(li.GetServerInfo(), "db version") == false {
+		t.Fatal("unexpected server info", li.GetServerInfo())
+	}
+}
+
+func TestLogInfoParseContextCanceled(t *testing.T) {
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := li.ParseContext(ctx); err != context.Canceled {
+		t.Fatal("expected context canceled, but got", err)
+	}
+}
+
+func TestLogInfoAnalyzeContextCanceled(t *testing.T) {
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := li.AnalyzeContext(ctx); err != context.Canceled {
+		t.Fatal("expected context canceled, but got", err)
+	}
+}
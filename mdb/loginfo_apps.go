@@ -0,0 +1,123 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// noAppName labels ops from clients without an appName
+const noAppName = "(none)"
+
+var appNameRegex = regexp.MustCompile(`appName: "([^"]*)"`)
+var connIDRegex = regexp.MustCompile(`^\S+ \S+\s+\w+\s+\[(\w+)\]`)
+
+// AppStatsDoc holds ops stats of a client application
+type AppStatsDoc struct {
+	AppName     string
+	Collscans   int // number of COLLSCAN ops
+	Connections int // number of distinct connections
+	Count       int // number of ops
+	MaxMilli    int // max millisecond
+	Patterns    int // number of distinct ops patterns
+	TotalMilli  int // total milliseconds
+}
+
+type appStats struct {
+	doc      AppStatsDoc
+	conns    map[string]bool
+	patterns map[string]bool
+}
+
+// getAppName returns appName of a log line
+func getAppName(str string) string {
+	if result := appNameRegex.FindStringSubmatch(str); len(result) > 1 {
+		return result[1]
+	}
+	return ""
+}
+
+// getConnID returns connection id of a log line, e.g. conn10
+func getConnID(str string) string {
+	if result := connIDRegex.FindStringSubmatch(str); len(result) > 1 {
+		return result[1]
+	}
+	return ""
+}
+
+// aggregateApp adds an op to stats of its application
+func (li *LogInfo) aggregateApp(stats opStats, key string) {
+	if li.appsMap == nil {
+		li.appsMap = map[string]*appStats{}
+	}
+	name := stats.appName
+	if name == "" {
+		name = noAppName
+	}
+	app, ok := li.appsMap[name]
+	if ok == false {
+		app = &appStats{doc: AppStatsDoc{AppName: name}, conns: map[string]bool{}, patterns: map[string]bool{}}
+		li.appsMap[name] = app
+	}
+	app.doc.Count++
+	app.doc.TotalMilli += stats.milli
+	if stats.milli > app.doc.MaxMilli {
+		app.doc.MaxMilli = stats.milli
+	}
+	if stats.scan == COLLSCAN {
+		app.doc.Collscans++
+	}
+	if stats.conn != "" {
+		app.conns[stats.conn] = true
+	}
+	app.patterns[key] = true
+}
+
+// sortApps sets application stats, sorted by total time
+func (li *LogInfo) sortApps() {
+	li.Apps = make([]AppStatsDoc, 0, len(li.appsMap))
+	for _, app := range li.appsMap {
+		app.doc.Connections = len(app.conns)
+		app.doc.Patterns = len(app.patterns)
+		li.Apps = append(li.Apps, app.doc)
+	}
+	sort.Slice(li.Apps, func(i, j int) bool {
+		return li.Apps[i].TotalMilli > li.Apps[j].TotalMilli
+	})
+}
+
+// getAppNames returns appNames of an ops pattern, the most frequent first
+func getAppNames(apps map[string]int) []string {
+	names := make([]string, 0, len(apps))
+	for name := range apps {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if apps[names[i]] == apps[names[j]] {
+			return names[i] < names[j]
+		}
+		return apps[names[i]] > apps[names[j]]
+	})
+	return names
+}
+
+// getAppsSummaries returns a table of ops by applications
+func (li *LogInfo) getAppsSummaries() []string {
+	if len(li.Apps) == 0 {
+		return []string{}
+	}
+	summaries := []string{"Ops by application:"}
+	summaries = append(summaries, fmt.Sprintf("%-32s %8s %8s %10s %10s %8s %8s", "appName", "count", "conns", "avg ms", "max ms", "COLLSCAN", "patterns"))
+	for _, app := range li.Apps {
+		name := app.AppName
+		if len(name) > 32 {
+			name = name[:31] + "*"
+		}
+		summaries = append(summaries, fmt.Sprintf("%-32s %8d %8d %10s %10d %8d %8d", name, app.Count, app.Connections,
+			strings.TrimSpace(MilliToTimeString(float64(app.TotalMilli)/float64(app.Count))), app.MaxMilli, app.Collscans, app.Patterns))
+	}
+	return append(summaries, "\n")
+}
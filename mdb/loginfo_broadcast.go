@@ -0,0 +1,89 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ignoredShardVersionRegex matches the IGNORED shardVersion mongos sends with writes broadcast to all shards,
+// e.g. shardVersion: [ Timestamp(0, 0), ObjectId('000000000000000000000000') ] or of 4.4 logs
+// "shardVersion":[{"$timestamp":{"t":0,"i":0}},{"$oid":"000000000000000000000000"}]
+var ignoredShardVersionRegex = regexp.MustCompile(`shardVersion"?: ?\[ ?(?:Timestamp\(0, 0\)|\{"\$timestamp":\{"t":0,"i":0\}\}), ?(?:ObjectId\('0{24}'\)|\{"\$oid":"0{24}"\}) ?\]`)
+
+// BroadcastWriteDoc is an update or delete pattern broadcast to all shards
+type BroadcastWriteDoc struct {
+	Command   string
+	Count     int // number of ops of the pattern
+	Filter    string
+	Namespace string
+	Reason    string // missing shard key or number of shards targeted
+}
+
+// SetShardKeys sets shard key fields by namespaces, e.g. from config metadata by GetShardKeys
+func (li *LogInfo) SetShardKeys(shardKeys map[string][]string) {
+	li.shardKeys = shardKeys
+}
+
+// setBroadcastWrites flags update and delete patterns without shard keys in filters, or, if
+// shard keys are unknown, routed to all shards by mongos or logged by shards with the IGNORED shardVersion
+func (li *LogInfo) setBroadcastWrites() {
+	li.BroadcastWrites = []BroadcastWriteDoc{}
+	for _, doc := range li.OpsPatterns {
+		if contains(writeCommands, doc.Command) == false {
+			continue
+		}
+		reason := ""
+		if keys, ok := li.shardKeys[doc.Namespace]; ok == true {
+			reason = getMissingShardKeyReason(doc.Filter, keys)
+		} else if li.NumShards > 1 && doc.NShards[li.NumShards] > 0 {
+			reason = fmt.Sprintf("%d of %d ops targeted all %d shards", doc.NShards[li.NumShards], doc.Count, li.NumShards)
+		} else if doc.Broadcasts > 0 {
+			reason = fmt.Sprintf("%d of %d ops broadcast with the IGNORED shardVersion", doc.Broadcasts, doc.Count)
+		}
+		if reason != "" {
+			li.BroadcastWrites = append(li.BroadcastWrites, BroadcastWriteDoc{Command: doc.Command, Count: doc.Count, Filter: doc.Filter,
+				Namespace: doc.Namespace, Reason: reason})
+		}
+	}
+	sort.Slice(li.BroadcastWrites, func(i, j int) bool { return li.BroadcastWrites[i].Count > li.BroadcastWrites[j].Count })
+}
+
+// isBroadcastShardVersion returns true if a write on a shard was broadcast by mongos to all shards
+func isBroadcastShardVersion(str string) bool {
+	return strings.Contains(str, "shardVersion") && ignoredShardVersionRegex.MatchString(str)
+}
+
+// getMissingShardKeyReason returns shard key fields not matched by equality in a filter, empty if targeted
+func getMissingShardKeyReason(pattern string, keys []string) string {
+	filter, _, err := parseQueryPattern(pattern)
+	if err != nil {
+		return ""
+	}
+	fields := getFilterFields(filter)
+	missing := []string{}
+	for _, key := range keys {
+		if isRange, ok := fields[key]; ok == false || isRange == true {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return ""
+	}
+	return "missing shard key " + strings.Join(missing, ", ")
+}
+
+// getBroadcastWritesSummaries returns update and delete patterns broadcast to all shards
+func (li *LogInfo) getBroadcastWritesSummaries() []string {
+	if len(li.BroadcastWrites) == 0 {
+		return []string{}
+	}
+	summaries := []string{"Broadcast writes:"}
+	for _, doc := range li.BroadcastWrites {
+		summaries = append(summaries, fmt.Sprintf("%8d %-14s %-33s %s (%s)", doc.Count, doc.Command, doc.Namespace, doc.Filter, doc.Reason))
+	}
+	return append(summaries, "\n")
+}
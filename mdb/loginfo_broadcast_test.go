@@ -0,0 +1,48 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLogInfoBroadcastWrites(t *testing.T) {
+	li := &LogInfo{NumShards: 3, OpsPatterns: []OpPerformanceDoc{
+		{Command: "update", Count: 10, Filter: "{color: 1}", Namespace: "keyhole.cars"},
+		{Command: "update", Count: 20, Filter: "{vin: 1}", Namespace: "keyhole.cars"},
+		{Command: "delete", Count: 5, Filter: "{vin: {$gt: 1}}", Namespace: "keyhole.cars"},
+		{Command: "find", Count: 50, Filter: "{color: 1}", Namespace: "keyhole.cars"},
+		{Command: "remove", Count: 3, Filter: "{name: 1}", Namespace: "keyhole.dealers", NShards: map[int]int{1: 1, 3: 2}},
+		{Command: "update", Count: 2, Filter: "{state: 1}", Namespace: "keyhole.dealers", Broadcasts: 2},
+	}}
+	li.SetShardKeys(map[string][]string{"keyhole.cars": {"vin"}})
+	li.setBroadcastWrites()
+	if len(li.BroadcastWrites) != 4 {
+		t.Fatal("expected 4 broadcast writes, but got", li.BroadcastWrites)
+	}
+	if doc := li.BroadcastWrites[0]; doc.Filter != "{color: 1}" || doc.Reason != "missing shard key vin" {
+		t.Fatal(doc)
+	}
+	if doc := li.BroadcastWrites[1]; doc.Command != "delete" || doc.Reason != "missing shard key vin" {
+		t.Fatal(doc)
+	}
+	if doc := li.BroadcastWrites[2]; doc.Namespace != "keyhole.dealers" || doc.Reason != "2 of 3 ops targeted all 3 shards" {
+		t.Fatal(doc)
+	}
+	if doc := li.BroadcastWrites[3]; doc.Filter != "{state: 1}" || doc.Reason != "2 of 2 ops broadcast with the IGNORED shardVersion" {
+		t.Fatal(doc)
+	}
+	for str, broadcast := range map[string]bool{
+		`update: "cars", shardVersion: [ Timestamp(0, 0), ObjectId('000000000000000000000000') ]`:           true,
+		`"update":"cars","shardVersion":[{"$timestamp":{"t":0,"i":0}},{"$oid":"000000000000000000000000"}]`: true,
+		`update: "cars", shardVersion: [ Timestamp(12, 3), ObjectId('5e9f6c7e8a1b2c3d4e5f6a7b') ]`:          false,
+	} {
+		if isBroadcastShardVersion(str) != broadcast {
+			t.Fatal(str)
+		}
+	}
+	if strings.Contains(strings.Join(li.getBroadcastWritesSummaries(), "\n"), "Broadcast writes:") == false {
+		t.Fatal(li.getBroadcastWritesSummaries())
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// cachePressureGap is the max seconds between WiredTiger cache warnings of a period
+const cachePressureGap = 60
+
+var storageLogRegex = regexp.MustCompile(`^(\S+)\s+\w\s+STORAGE\s+\[[^\]]+\] (.*)$`)
+var cachePressureRegex = regexp.MustCompile(`(?i)evict|cache (?:capacity|full|overflow|stuck)|WT_CACHE_FULL`)
+
+// CachePressureDoc holds a period of WiredTiger cache eviction warnings and slow ops logged in the period
+type CachePressureDoc struct {
+	End        time.Time
+	Events     int    // number of cache warnings
+	Message    string // first cache warning of the period
+	SlowOps    int
+	Start      time.Time
+	TotalMilli int // total milliseconds of slow ops
+}
+
+// cacheEvent is a WiredTiger cache warning
+type cacheEvent struct {
+	message string
+	ts      time.Time
+}
+
+// opsSecond holds slow ops counts and latencies of a second
+type opsSecond struct {
+	count      int
+	totalMilli int
+}
+
+// parseCachePressure keeps WiredTiger eviction and cache warnings, returns false if not a cache warning
+func (li *LogInfo) parseCachePressure(str string) bool {
+	var ts time.Time
+	var message string
+	if strings.HasPrefix(str, "{") {
+		if strings.Contains(str, `"c":"STORAGE"`) == false {
+			return false
+		}
+		var doc logDoc
+		if err := json.Unmarshal([]byte(str), &doc); err != nil {
+			return false
+		}
+		ts, message = getLogTime(doc.Time.Date), doc.Message+": "+doc.Attr.Message
+	} else {
+		if strings.Contains(str, " STORAGE ") == false {
+			return false
+		}
+		result := storageLogRegex.FindStringSubmatch(str)
+		if len(result) == 0 {
+			return false
+		}
+		ts, message = getLogTime(result[1]), result[2]
+	}
+	if ts.IsZero() || cachePressureRegex.MatchString(message) == false {
+		return false
+	}
+	li.cacheEvents = append(li.cacheEvents, cacheEvent{message: message, ts: ts})
+	return true
+}
+
+// addOpsSecond counts a slow op by its second for correlating with cache warnings
+func (li *LogInfo) addOpsSecond(stats opStats) {
+	if stats.ts.IsZero() {
+		return
+	}
+	if li.opsSeconds == nil {
+		li.opsSeconds = map[int64]*opsSecond{}
+	}
+	sec, ok := li.opsSeconds[stats.ts.Unix()]
+	if ok == false {
+		sec = &opsSecond{}
+		li.opsSeconds[stats.ts.Unix()] = sec
+	}
+	sec.count++
+	sec.totalMilli += stats.milli
+}
+
+// sortCachePressure folds new cache warnings into periods and counts slow ops of each period
+func (li *LogInfo) sortCachePressure() {
+	if len(li.cacheEvents) == 0 {
+		return
+	}
+	periods := li.CachePressure
+	for _, event := range li.cacheEvents { // periods of earlier calls are kept, e.g. ticks of Follow
+		message := event.message
+		if len(message) > 120 {
+			message = message[:120] + "..."
+		}
+		periods = append(periods, CachePressureDoc{End: event.ts, Events: 1, Message: message, Start: event.ts})
+	}
+	sort.SliceStable(periods, func(i, j int) bool { return periods[i].Start.Before(periods[j].Start) })
+	li.CachePressure = []CachePressureDoc{}
+	for _, doc := range periods {
+		n := len(li.CachePressure)
+		if n > 0 && doc.Start.Sub(li.CachePressure[n-1].End) <= cachePressureGap*time.Second {
+			if doc.End.After(li.CachePressure[n-1].End) {
+				li.CachePressure[n-1].End = doc.End
+			}
+			li.CachePressure[n-1].Events += doc.Events
+			continue
+		}
+		doc.SlowOps, doc.TotalMilli = 0, 0
+		li.CachePressure = append(li.CachePressure, doc)
+	}
+	for i, doc := range li.CachePressure { // slow ops are counted again as periods may have grown
+		for t := doc.Start.Unix(); t <= doc.End.Unix(); t++ {
+			if sec, ok := li.opsSeconds[t]; ok {
+				li.CachePressure[i].SlowOps += sec.count
+				li.CachePressure[i].TotalMilli += sec.totalMilli
+			}
+		}
+	}
+	li.cacheEvents = nil
+}
+
+// isCachePressured returns true if a time range overlaps a cache pressure period
+func (li *LogInfo) isCachePressured(start time.Time, end time.Time) bool {
+	for _, doc := range li.CachePressure {
+		if start.After(doc.End) == false && end.Before(doc.Start) == false {
+			return true
+		}
+	}
+	return false
+}
+
+// getCachePressureSummaries returns cache pressure periods and slow ops logged in them
+func (li *LogInfo) getCachePressureSummaries() []string {
+	if len(li.CachePressure) == 0 {
+		return []string{}
+	}
+	count, total := 0, 0
+	for _, sec := range li.opsSeconds {
+		count += sec.count
+		total += sec.totalMilli
+	}
+	summaries := []string{"Cache pressure periods:"}
+	if count > 0 {
+		summaries = append(summaries, fmt.Sprintf("(avg ms of all slow ops: %.1f)", float64(total)/float64(count)))
+	}
+	summaries = append(summaries, fmt.Sprintf("%-29s %-29s %6s %8s %10s %s", "start", "end", "events", "slow ops", "avg ms", "message"))
+	for _, doc := range li.CachePressure {
+		avg := 0.0
+		if doc.SlowOps > 0 {
+			avg = float64(doc.TotalMilli) / float64(doc.SlowOps)
+		}
+		summaries = append(summaries, fmt.Sprintf("%-29s %-29s %6d %8d %10.1f %s", doc.Start.Format(logTimeLayout), doc.End.Format(logTimeLayout),
+			doc.Events, doc.SlowOps, avg, doc.Message))
+	}
+	return append(summaries, "\n")
+}
@@ -0,0 +1,145 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// regressionRatio flags a pattern whose average latency increased by 20% or more
+const regressionRatio = 1.2
+
+// PatternDiff holds stats of a query pattern from two runs
+type PatternDiff struct {
+	Command      string
+	Filter       string
+	Namespace    string
+	Count        int     // count of this run
+	AvgMilli     float64 // average milliseconds of this run
+	MaxMilli     int     // max milliseconds of this run
+	Plan         string  // COLLSCAN or index used of this run
+	OtherCount   int     // count of the other run
+	OtherAvg     float64 // average milliseconds of the other run
+	OtherMax     int     // max milliseconds of the other run
+	OtherPlan    string  // COLLSCAN or index used of the other run
+	IsRegression bool
+}
+
+// LogInfoDiff holds differences of query patterns between two runs
+type LogInfoDiff struct {
+	Changed     []PatternDiff // patterns found in both runs
+	Disappeared []PatternDiff // patterns only in the other run
+	New         []PatternDiff // patterns only in this run
+}
+
+type patternTotals struct {
+	command, filter, namespace  string
+	count, maxMilli, totalMilli int
+	plans                       []string
+}
+
+// Compare matches query patterns of this run against the other run, e.g. li after and other before an index change
+func (li *LogInfo) Compare(other *LogInfo) LogInfoDiff {
+	diff := LogInfoDiff{Changed: []PatternDiff{}, Disappeared: []PatternDiff{}, New: []PatternDiff{}}
+	current := getPatternTotals(li.OpsPatterns)
+	previous := getPatternTotals(other.OpsPatterns)
+	for key, c := range current {
+		d := PatternDiff{Command: c.command, Filter: c.filter, Namespace: c.namespace, Count: c.count,
+			AvgMilli: float64(c.totalMilli) / float64(c.count), MaxMilli: c.maxMilli, Plan: strings.Join(c.plans, ", ")}
+		p, ok := previous[key]
+		if ok == false {
+			diff.New = append(diff.New, d)
+			continue
+		}
+		d.OtherCount = p.count
+		d.OtherAvg = float64(p.totalMilli) / float64(p.count)
+		d.OtherMax = p.maxMilli
+		d.OtherPlan = strings.Join(p.plans, ", ")
+		d.IsRegression = d.AvgMilli >= d.OtherAvg*regressionRatio
+		diff.Changed = append(diff.Changed, d)
+	}
+	for key, p := range previous {
+		if _, ok := current[key]; ok == true {
+			continue
+		}
+		diff.Disappeared = append(diff.Disappeared, PatternDiff{Command: p.command, Filter: p.filter, Namespace: p.namespace,
+			OtherCount: p.count, OtherAvg: float64(p.totalMilli) / float64(p.count), OtherMax: p.maxMilli,
+			OtherPlan: strings.Join(p.plans, ", ")})
+	}
+	sort.Slice(diff.Changed, func(i, j int) bool {
+		return diff.Changed[i].AvgMilli-diff.Changed[i].OtherAvg > diff.Changed[j].AvgMilli-diff.Changed[j].OtherAvg
+	})
+	sort.Slice(diff.New, func(i, j int) bool { return diff.New[i].AvgMilli > diff.New[j].AvgMilli })
+	sort.Slice(diff.Disappeared, func(i, j int) bool { return diff.Disappeared[i].OtherAvg > diff.Disappeared[j].OtherAvg })
+	return diff
+}
+
+// getPatternTotals sums stats of the same pattern regardless of plans
+func getPatternTotals(docs []OpPerformanceDoc) map[string]*patternTotals {
+	totals := map[string]*patternTotals{}
+	for _, doc := range docs {
+		key := doc.Command + "." + doc.Namespace + "." + doc.Filter
+		t, ok := totals[key]
+		if ok == false {
+			t = &patternTotals{command: doc.Command, filter: doc.Filter, namespace: doc.Namespace}
+			totals[key] = t
+		}
+		t.count += doc.Count
+		t.totalMilli += doc.TotalMilli
+		if doc.MaxMilli > t.maxMilli {
+			t.maxMilli = doc.MaxMilli
+		}
+		plan := doc.Scan
+		if doc.Index != "" {
+			plan = doc.Index
+		}
+		if plan != "" {
+			t.plans = append(t.plans, plan)
+			sort.Strings(t.plans)
+		}
+	}
+	return totals
+}
+
+// GetSummary returns differences of patterns, regressions are marked with a !
+func (diff LogInfoDiff) GetSummary() string {
+	var buffer bytes.Buffer
+	regressions := 0
+	for _, d := range diff.Changed {
+		if d.IsRegression {
+			regressions++
+		}
+	}
+	buffer.WriteString(fmt.Sprintf("Patterns compared: %d, regressions: %d, new: %d, disappeared: %d\n",
+		len(diff.Changed), regressions, len(diff.New), len(diff.Disappeared)))
+	if len(diff.Changed) > 0 {
+		buffer.WriteString(fmt.Sprintf("\n  %-10s %-33s %17s %21s %19s  %s\n", "command", "namespace", "count", "avg ms", "max ms", "query pattern"))
+		for _, d := range diff.Changed {
+			mark := " "
+			if d.IsRegression {
+				mark = "!"
+			}
+			buffer.WriteString(fmt.Sprintf("%s %-10s %-33s %7d -> %-7d %9.1f -> %-9.1f %8d -> %-8d %s\n", mark, d.Command, d.Namespace,
+				d.OtherCount, d.Count, d.OtherAvg, d.AvgMilli, d.OtherMax, d.MaxMilli, d.Filter))
+			if d.Plan != d.OtherPlan {
+				buffer.WriteString(fmt.Sprintf("  ...plan: %s -> %s\n", d.OtherPlan, d.Plan))
+			}
+		}
+	}
+	if len(diff.New) > 0 {
+		buffer.WriteString("\nNew patterns:\n")
+		for _, d := range diff.New {
+			buffer.WriteString(fmt.Sprintf("+ %-10s %-33s %7d %9.1f %8d %s\n", d.Command, d.Namespace, d.Count, d.AvgMilli, d.MaxMilli, d.Filter))
+		}
+	}
+	if len(diff.Disappeared) > 0 {
+		buffer.WriteString("\nDisappeared patterns:\n")
+		for _, d := range diff.Disappeared {
+			buffer.WriteString(fmt.Sprintf("- %-10s %-33s %7d %9.1f %8d %s\n", d.Command, d.Namespace, d.OtherCount, d.OtherAvg, d.OtherMax, d.Filter))
+		}
+	}
+	return buffer.String()
+}
@@ -0,0 +1,36 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLogInfoCompare(t *testing.T) {
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	other := &LogInfo{}
+	other.aggregate(opStats{command: "find", namespace: "keyhole.cars", filter: "{style: 1}", index: "{ style: 1 }", milli: 15})
+	other.aggregate(opStats{command: "find", namespace: "keyhole.owners", filter: "{name: 1}", scan: COLLSCAN, milli: 700})
+	other.sortOpsPatterns()
+
+	diff := li.Compare(other)
+	if len(diff.Changed) != 1 || len(diff.Disappeared) != 1 || len(diff.New) != len(li.OpsPatterns)-1 {
+		t.Fatal(len(diff.Changed), len(diff.Disappeared), len(diff.New))
+	}
+	d := diff.Changed[0]
+	if d.IsRegression == false || d.Plan != COLLSCAN || d.OtherPlan != "{ style: 1 }" || d.OtherAvg != 15 {
+		t.Fatal(d)
+	}
+	if diff.Disappeared[0].Namespace != "keyhole.owners" {
+		t.Fatal(diff.Disappeared[0])
+	}
+	summary := diff.GetSummary()
+	if strings.Index(summary, "regressions: 1") < 0 || strings.Index(summary, "...plan: { style: 1 } -> COLLSCAN") < 0 {
+		t.Fatal(summary)
+	}
+}
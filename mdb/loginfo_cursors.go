@@ -0,0 +1,162 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var cursorIDRegex = regexp.MustCompile(`(?:cursorid:|getMore: )(\d+)`)
+var originatingCommandRegex = regexp.MustCompile(`originatingCommand: \{ (\w+): `)
+
+// CursorDoc holds getMore batches of cursors of an originating query pattern
+type CursorDoc struct {
+	Command       string // originating command, e.g. find or aggregate
+	Cursors       int    // number of cursors
+	Filter        string // query pattern of the originating command
+	GetMores      int    // number of getMore batches
+	LifetimeMilli int    // total milliseconds from the first batch to the last getMore of cursors
+	MaxMilli      int    // max milliseconds of a getMore
+	Namespace     string
+	TotalMilli    int // total milliseconds of getMore batches
+}
+
+// cursorStats holds times of getMore batches of a cursor
+type cursorStats struct {
+	first time.Time // start of the originating command if logged, or of the first getMore
+	key   string    // key of the originating pattern
+	last  time.Time // end of the last getMore
+}
+
+// SetGetMores sets to report getMore batches by originating patterns instead of folding them into ops patterns
+func (li *LogInfo) SetGetMores(getMores bool) {
+	li.getMores = getMores
+}
+
+// openCursor keeps start time of an originating command of a cursor left open
+func (li *LogInfo) openCursor(str string, milli int) {
+	result := cursorIDRegex.FindStringSubmatch(str)
+	ts := getLogTime(str)
+	if len(result) < 2 || result[1] == "0" || ts.IsZero() {
+		return
+	}
+	if li.openCursors == nil {
+		li.openCursors = map[string]*cursorStats{}
+	}
+	li.openCursors[li.getHostKey(result[1])] = &cursorStats{first: ts.Add(-time.Duration(milli) * time.Millisecond), last: ts}
+}
+
+// aggregateGetMore adds a getMore batch to its originating pattern
+func (li *LogInfo) aggregateGetMore(str string, ns string, filter string, milli int) {
+	if li.cursorsMap == nil {
+		li.cursorsMap = map[string]*CursorDoc{}
+	}
+	if li.openCursors == nil {
+		li.openCursors = map[string]*cursorStats{}
+	}
+	command := "find"
+	if result := originatingCommandRegex.FindStringSubmatch(str); len(result) > 1 {
+		command = result[1]
+	}
+	key := command + "." + ns + "." + filter
+	doc, ok := li.cursorsMap[key]
+	if ok == false {
+		doc = &CursorDoc{Command: command, Filter: filter, Namespace: ns}
+		li.cursorsMap[key] = doc
+	}
+	doc.GetMores++
+	doc.TotalMilli += milli
+	if milli > doc.MaxMilli {
+		doc.MaxMilli = milli
+	}
+	ts := getLogTime(str)
+	result := cursorIDRegex.FindStringSubmatch(str)
+	if len(result) < 2 || ts.IsZero() {
+		doc.Cursors++ // untracked cursor
+		return
+	}
+	id := li.getHostKey(result[1])
+	cursor, ok := li.openCursors[id]
+	if ok == false {
+		cursor = &cursorStats{first: ts.Add(-time.Duration(milli) * time.Millisecond)}
+		li.openCursors[id] = cursor
+	}
+	if cursor.key == "" { // the first getMore of the cursor
+		doc.Cursors++
+		cursor.key = key
+	}
+	cursor.last = ts
+	if strings.Contains(str, "cursorExhausted:1") {
+		li.closeCursor(id)
+	}
+}
+
+// closeCursor adds lifetime of a cursor to its originating pattern
+func (li *LogInfo) closeCursor(id string) {
+	cursor := li.openCursors[id]
+	delete(li.openCursors, id)
+	li.cursorsMap[cursor.key].LifetimeMilli += int(cursor.last.Sub(cursor.first) / time.Millisecond)
+}
+
+// closeCursors adds lifetimes of cursors left open at end of input to their originating patterns
+func (li *LogInfo) closeCursors() {
+	for id, cursor := range li.openCursors {
+		if cursor.key != "" { // originating commands without getMore are ignored
+			li.closeCursor(id)
+		}
+	}
+	li.openCursors = nil
+}
+
+// sortCursors sets cursors stats sorted by total getMore time, cursors still open count lifetimes so far
+func (li *LogInfo) sortCursors() {
+	if len(li.cursorsMap) == 0 {
+		return
+	}
+	open := map[string]int{}
+	for _, cursor := range li.openCursors {
+		if cursor.key != "" {
+			open[cursor.key] += int(cursor.last.Sub(cursor.first) / time.Millisecond)
+		}
+	}
+	li.Cursors = make([]CursorDoc, 0, len(li.cursorsMap))
+	for key, doc := range li.cursorsMap {
+		cursor := *doc
+		cursor.LifetimeMilli += open[key]
+		li.Cursors = append(li.Cursors, cursor)
+	}
+	sort.Slice(li.Cursors, func(i, j int) bool {
+		if li.Cursors[i].TotalMilli == li.Cursors[j].TotalMilli {
+			return li.Cursors[i].Command+li.Cursors[i].Filter < li.Cursors[j].Command+li.Cursors[j].Filter
+		}
+		return li.Cursors[i].TotalMilli > li.Cursors[j].TotalMilli
+	})
+}
+
+// getCursorsSummaries returns getMore batches by originating patterns
+func (li *LogInfo) getCursorsSummaries() []string {
+	if len(li.Cursors) == 0 {
+		return []string{}
+	}
+	summaries := []string{"getMore batches by originating patterns:"}
+	summaries = append(summaries, fmt.Sprintf("%-10s %-33s %7s %8s %10s %10s %12s %s", "command", "namespace", "cursors", "getMores",
+		"avg ms", "max ms", "avg lifetime", "query pattern"))
+	for _, doc := range li.Cursors {
+		summaries = append(summaries, fmt.Sprintf("%-10s %-33s %7d %8d %10.1f %10d %12s %s", doc.Command, doc.Namespace, doc.Cursors,
+			doc.GetMores, float64(doc.TotalMilli)/float64(doc.GetMores), doc.MaxMilli,
+			strings.TrimSpace(MilliToTimeString(float64(getAvgLifetime(doc)))), doc.Filter))
+	}
+	return append(summaries, "\n")
+}
+
+// getAvgLifetime returns average milliseconds of lifetimes of cursors
+func getAvgLifetime(doc CursorDoc) int {
+	if doc.Cursors == 0 {
+		return 0
+	}
+	return doc.LifetimeMilli / doc.Cursors
+}
@@ -0,0 +1,52 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"sort"
+	"time"
+)
+
+// ExampleDoc is a statement of an ops pattern with literal values, to be pasted into explain()
+type ExampleDoc struct {
+	Milli     int
+	Statement string // e.g. find { find: "cars", filter: { color: "Red" } }
+	Time      time.Time
+}
+
+// SetExamples sets number of the slowest example statements to keep per ops pattern, 0 to keep none
+func (li *LogInfo) SetExamples(n int) {
+	li.examples = n
+}
+
+// addExample keeps a statement of an op if it's among the slowest of its pattern
+func addExample(examples []ExampleDoc, stats opStats, n int) []ExampleDoc {
+	if n <= 0 || stats.statement == "" {
+		return examples
+	}
+	if len(examples) >= n && stats.milli <= examples[n-1].Milli {
+		return examples
+	}
+	examples = append(examples, ExampleDoc{Milli: stats.milli, Statement: stats.statement, Time: stats.ts})
+	sort.SliceStable(examples, func(i, j int) bool { return examples[i].Milli > examples[j].Milli })
+	if len(examples) > n {
+		examples = examples[:n]
+	}
+	return examples
+}
+
+// getExampleStatement returns the slowest example statement, or empty if none is retained
+func getExampleStatement(examples []ExampleDoc) string {
+	if len(examples) == 0 {
+		return ""
+	}
+	return examples[0].Statement
+}
+
+// mergeExamples returns the slowest examples of two patterns
+func mergeExamples(examples []ExampleDoc, others []ExampleDoc, n int) []ExampleDoc {
+	for _, example := range others {
+		examples = addExample(examples, opStats{milli: example.Milli, statement: example.Statement, ts: example.Time}, n)
+	}
+	return examples
+}
@@ -0,0 +1,76 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var hostRegex = regexp.MustCompile(`MongoDB starting : .* host=(\S+)`)
+
+// ExportToCollection writes ops patterns with metadata of the analysis to a collection, namespace is db.collection
+func (li *LogInfo) ExportToCollection(client *mongo.Client, namespace string) error {
+	var err error
+	idx := strings.Index(namespace, ".")
+	if idx <= 0 || idx == len(namespace)-1 {
+		return errors.New("invalid namespace " + namespace + ", expected db.collection")
+	}
+	docs := li.getExportDocs(time.Now())
+	if len(docs) == 0 {
+		return nil
+	}
+	collection := client.Database(namespace[:idx]).Collection(namespace[idx+1:])
+	_, err = collection.InsertMany(context.Background(), docs)
+	return err
+}
+
+// getExportDocs returns ops patterns as documents with source files, host, time range, and run time
+func (li *LogInfo) getExportDocs(analyzedAt time.Time) []interface{} {
+	docs := []interface{}{}
+	for _, value := range li.OpsPatterns {
+		line := ConverOpPerformanceDocumentToLogInfoLineAnalytics(&value)
+		data, _ := json.Marshal(line)
+		var doc bson.D
+		if err := bson.UnmarshalExtJSON(data, false, &doc); err != nil {
+			continue
+		}
+		doc = append(doc, bson.E{Key: "sources", Value: li.filenames}, bson.E{Key: "host", Value: li.Host},
+			bson.E{Key: "analyzedAt", Value: analyzedAt})
+		if li.StartTime.IsZero() == false {
+			doc = append(doc, bson.E{Key: "startTime", Value: li.StartTime}, bson.E{Key: "endTime", Value: li.EndTime})
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+// setTimeRange extends time range of analyzed ops
+func (li *LogInfo) setTimeRange(t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	if li.StartTime.IsZero() || t.Before(li.StartTime) {
+		li.StartTime = t
+	}
+	if t.After(li.EndTime) {
+		li.EndTime = t
+	}
+}
+
+// setHost sets host name from a log line of mongod starting
+func (li *LogInfo) setHost(str string) {
+	if li.Host != "" || strings.Contains(str, "MongoDB starting") == false {
+		return
+	}
+	if result := hostRegex.FindStringSubmatch(str); len(result) > 1 {
+		li.Host = result[1]
+	}
+}
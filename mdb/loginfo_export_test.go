@@ -0,0 +1,51 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGetExportDocs(t *testing.T) {
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if li.Host != "localhost" {
+		t.Fatal("expected localhost, but got", li.Host)
+	}
+	if li.StartTime.IsZero() || li.EndTime.Before(li.StartTime) {
+		t.Fatal(li.StartTime, li.EndTime)
+	}
+	docs := li.getExportDocs(time.Now())
+	if len(docs) != len(li.OpsPatterns) {
+		t.Fatal("expected", len(li.OpsPatterns), "but got", len(docs))
+	}
+	m := docs[0].(bson.D).Map()
+	for _, key := range []string{"namespace", "queryPattern", "sources", "host", "analyzedAt", "startTime", "endTime"} {
+		if _, ok := m[key]; ok == false {
+			t.Fatal("expected field", key)
+		}
+	}
+	if err := li.ExportToCollection(nil, "keyhole"); err == nil {
+		t.Fatal("expected invalid namespace error")
+	}
+}
+
+func TestExportToCollection(t *testing.T) {
+	client := getMongoClient()
+	defer client.Disconnect(context.Background())
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if err := li.ExportToCollection(client, "keyhole.loginfo"); err != nil {
+		t.Fatal(err)
+	}
+}
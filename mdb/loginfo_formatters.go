@@ -0,0 +1,116 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// formattersMutex guards formatters registered while others are resolved, e.g. by servers
+var formattersMutex sync.RWMutex
+
+// formatters holds output formatter constructors by name
+var formatters = map[string]func() OutputFormatterBase{
+	"csv":                 func() OutputFormatterBase { return &CSVOutputFormatter{Delimiter: ','} },
+	"grafana":             func() OutputFormatterBase { return &GrafanaOutputFormatter{} },
+	"grafana-annotations": func() OutputFormatterBase { return &GrafanaOutputFormatter{Annotations: true} },
+	"html":                func() OutputFormatterBase { return &HTMLOutputFormatter{} },
+	"json":                func() OutputFormatterBase { return &JSONOutputFormatter{} },
+	"markdown":            func() OutputFormatterBase { return &MarkdownOutputFormatter{} },
+	"ndjson":              func() OutputFormatterBase { return &JSONOutputFormatter{NDJSON: true} },
+	"prometheus":          func() OutputFormatterBase { return &PrometheusOutputFormatter{} },
+	"screen":              func() OutputFormatterBase { return &ScreenOutputFormatter{} },
+	"tsv":                 func() OutputFormatterBase { return &CSVOutputFormatter{Delimiter: '\t'} },
+	"xlsx":                func() OutputFormatterBase { return &XLSXOutputFormatter{} },
+}
+
+// RegisterFormatter registers an output formatter by name, replacing an existing one
+func RegisterFormatter(name string, newFormatter func() OutputFormatterBase) {
+	formattersMutex.Lock()
+	defer formattersMutex.Unlock()
+	formatters[strings.ToLower(name)] = newFormatter
+}
+
+// GetFormatter returns a new output formatter by name
+func GetFormatter(name string) (OutputFormatterBase, error) {
+	formattersMutex.RLock()
+	newFormatter, ok := formatters[strings.ToLower(name)]
+	formattersMutex.RUnlock()
+	if ok == false {
+		return nil, errors.New("unsupported format " + name + ", supported formats are " + strings.Join(GetFormatterNames(), ", "))
+	}
+	return newFormatter(), nil
+}
+
+// GetFormatterNames returns names of registered formatters
+func GetFormatterNames() []string {
+	formattersMutex.RLock()
+	defer formattersMutex.RUnlock()
+	names := make([]string, 0, len(formatters))
+	for name := range formatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CSVOutputFormatter writes ops patterns as delimiter separated values
+type CSVOutputFormatter struct {
+	OutputFormatterBase
+	Delimiter rune
+}
+
+func (formatter *CSVOutputFormatter) write(buffer *bytes.Buffer, record []string) {
+	writer := csv.NewWriter(buffer)
+	if formatter.Delimiter != 0 {
+		writer.Comma = formatter.Delimiter
+	}
+	writer.Write(record)
+	writer.Flush()
+}
+
+// WriteHeader writes column names
+func (formatter *CSVOutputFormatter) WriteHeader(buffer *bytes.Buffer) {
+	formatter.write(buffer, []string{"command", "namespace", "isCollectionScan", "count", "averageMilliseconds",
+		"p50Milliseconds", "p90Milliseconds", "p95Milliseconds", "p99Milliseconds", "maxMilliseconds", "totalMilliseconds",
+		"keysExamined", "docsExamined", "nreturned", "scannedReturnedRatio", "replanned", "fromMultiPlanner", "inMemorySorts", "usedDisk", "writeConflicts", "lockWaits", "timeAcquiringMicros", "maxShards", "averageShards", "scatterGather", "appNames", "indexUsed", "queryHash", "planCacheKey", "queryPattern", "example"})
+}
+
+// WriteLine writes a record of an ops pattern
+func (formatter *CSVOutputFormatter) WriteLine(buffer *bytes.Buffer, value *LogInfoLineAnalytics) {
+	formatter.write(buffer, []string{value.Command, value.Namespace, fmt.Sprintf("%v", value.IsCollectionScan),
+		fmt.Sprintf("%d", value.Count), fmt.Sprintf("%.1f", value.AvgMilliseconds),
+		fmt.Sprintf("%d", value.P50Milliseconds), fmt.Sprintf("%d", value.P90Milliseconds),
+		fmt.Sprintf("%d", value.P95Milliseconds), fmt.Sprintf("%d", value.P99Milliseconds),
+		fmt.Sprintf("%d", value.MaxMilliseconds),
+		fmt.Sprintf("%d", value.TotalMilliseconds), fmt.Sprintf("%d", value.KeysExamined),
+		fmt.Sprintf("%d", value.DocsExamined), fmt.Sprintf("%d", value.NReturned),
+		fmt.Sprintf("%.0f", value.ScannedRatio), fmt.Sprintf("%d", value.Replanned),
+		fmt.Sprintf("%d", value.FromMultiPlanner), fmt.Sprintf("%d", value.InMemorySorts),
+		fmt.Sprintf("%d", value.SpilledSorts), fmt.Sprintf("%d", value.WriteConflicts),
+		fmt.Sprintf("%d", value.LockWaits), fmt.Sprintf("%d", value.LockWaitMicros), fmt.Sprintf("%d", value.MaxShards),
+		fmt.Sprintf("%.1f", value.AvgShards), fmt.Sprintf("%d", value.ScatterGather), strings.Join(value.AppNames, ";"), value.IndexUsed,
+		value.QueryHash, value.PlanCacheKey, value.QueryPattern, getExampleStatement(value.Examples)})
+}
+
+// WriteFooter writes nothing
+func (formatter *CSVOutputFormatter) WriteFooter(buffer *bytes.Buffer) {
+}
+
+// GetOutput returns ops patterns as delimiter separated values
+func (formatter *CSVOutputFormatter) GetOutput(li *LogInfo) string {
+	var buffer bytes.Buffer
+	formatter.WriteHeader(&buffer)
+	for _, value := range li.OpsPatterns {
+		line := ConverOpPerformanceDocumentToLogInfoLineAnalytics(&value)
+		formatter.WriteLine(&buffer, &line)
+	}
+	formatter.WriteFooter(&buffer)
+	return buffer.String()
+}
@@ -0,0 +1,116 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type testOutputFormatter struct {
+	OutputFormatterBase
+}
+
+func (formatter *testOutputFormatter) GetOutput(li *LogInfo) string {
+	return "test formatter"
+}
+
+func TestGetFormatter(t *testing.T) {
+	for _, name := range []string{"screen", "json", "ndjson", "csv", "tsv", "html", "markdown", "prometheus", "xlsx", "grafana", "grafana-annotations"} {
+		if _, err := GetFormatter(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := GetFormatter("yaml"); err == nil {
+		t.Fatal("expected unsupported format error")
+	}
+
+	RegisterFormatter("test", func() OutputFormatterBase { return &testOutputFormatter{} })
+	defer func() {
+		formattersMutex.Lock()
+		delete(formatters, "test")
+		formattersMutex.Unlock()
+	}()
+	li := NewLogInfo("testdata/mongod.log", "test")
+	li.SetSilent(true)
+	str, err := li.Analyze()
+	os.Remove(li.OutputFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if str != "test formatter" {
+		t.Fatal(str)
+	}
+}
+
+func TestRegisterFormatterConcurrently(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("test%d", i)
+			RegisterFormatter(name, func() OutputFormatterBase { return &testOutputFormatter{} })
+			if _, err := GetFormatter(name); err != nil {
+				t.Error(err)
+			}
+			GetFormatterNames()
+		}(i)
+	}
+	wg.Wait()
+	formattersMutex.Lock()
+	for i := 0; i < 8; i++ {
+		delete(formatters, fmt.Sprintf("test%d", i))
+	}
+	formattersMutex.Unlock()
+}
+
+func TestCSVOutputFormatter(t *testing.T) {
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	for _, delimiter := range []rune{',', '\t'} {
+		li.SetFormatter(&CSVOutputFormatter{Delimiter: delimiter})
+		reader := csv.NewReader(bytes.NewBufferString(li.printLogsSummary()))
+		reader.Comma = delimiter
+		records, err := reader.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != len(li.OpsPatterns)+1 || records[0][0] != "command" {
+			t.Fatal(records)
+		}
+	}
+	li.SetFormatter(&ScreenOutputFormatter{})
+	if strings.Index(li.printLogsSummary(), "Query Pattern") < 0 {
+		t.Fatal("expected screen table header")
+	}
+}
+
+func TestOutputFormattersPercentiles(t *testing.T) {
+	for _, name := range []string{"csv", "tsv", "html", "markdown"} {
+		formatter, _ := GetFormatter(name)
+		var buffer bytes.Buffer
+		formatter.WriteHeader(&buffer)
+		for _, p := range []string{"p50", "p90", "p95", "p99"} {
+			if strings.Contains(buffer.String(), p) == false {
+				t.Fatal(name, "expected", p, "in", buffer.String())
+			}
+		}
+	}
+	formatter := &XLSXOutputFormatter{}
+	formatter.WriteHeader(nil)
+	header := fmt.Sprint(formatter.rows[0]...)
+	for _, p := range []string{"p50 ms", "p90 ms", "p95 ms", "p99 ms"} {
+		if strings.Contains(header, p) == false {
+			t.Fatal("xlsx expected", p, "in", header)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultGrafanaSpan is seconds of time buckets of Grafana series if --span isn't set
+const defaultGrafanaSpan = 60
+
+// GrafanaOutputFormatter writes time series of throughput and latencies as a response of
+// Grafana simple JSON datasource queries, or events as a response of annotations queries
+type GrafanaOutputFormatter struct {
+	OutputFormatterBase
+	Annotations bool
+}
+
+// GrafanaSeries is a time series target, datapoints are [value, unix milliseconds]
+type GrafanaSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// GrafanaAnnotation is an event of an incident window
+type GrafanaAnnotation struct {
+	Tags    []string `json:"tags"`
+	Text    string   `json:"text"`
+	Time    int64    `json:"time"`
+	TimeEnd int64    `json:"timeEnd,omitempty"`
+	Title   string   `json:"title"`
+}
+
+// GetOutput returns series or annotations in JSON
+func (formatter *GrafanaOutputFormatter) GetOutput(li *LogInfo) string {
+	var data []byte
+	if formatter.Annotations == true {
+		data, _ = json.MarshalIndent(li.GetGrafanaAnnotations(), "", "  ")
+	} else {
+		data, _ = json.MarshalIndent(li.GetGrafanaSeries(), "", "  ")
+	}
+	return string(data)
+}
+
+// GetGrafanaSeries returns ops counts, average and max milliseconds of commands by time buckets
+func (li *LogInfo) GetGrafanaSeries() []GrafanaSeries {
+	seriesMap := map[string]*GrafanaSeries{}
+	targets := []string{}
+	for _, bucket := range li.TimeSeries {
+		ts := float64(toUnixMilli(bucket.Time))
+		values := []float64{float64(bucket.Count), float64(bucket.TotalMilli) / float64(bucket.Count), float64(bucket.MaxMilli)}
+		for i, metric := range []string{"ops", "avg ms", "max ms"} {
+			target := bucket.Command + " " + metric
+			series, ok := seriesMap[target]
+			if ok == false {
+				series = &GrafanaSeries{Target: target, Datapoints: [][2]float64{}}
+				seriesMap[target] = series
+				targets = append(targets, target)
+			}
+			series.Datapoints = append(series.Datapoints, [2]float64{values[i], ts})
+		}
+	}
+	sort.Strings(targets)
+	results := make([]GrafanaSeries, 0, len(targets))
+	for _, target := range targets {
+		results = append(results, *seriesMap[target])
+	}
+	return results
+}
+
+// GetGrafanaAnnotations returns cache pressure windows, index builds, and slowest ops, sorted by time
+func (li *LogInfo) GetGrafanaAnnotations() []GrafanaAnnotation {
+	annotations := []GrafanaAnnotation{}
+	for _, doc := range li.CachePressure {
+		annotations = append(annotations, GrafanaAnnotation{Tags: []string{"keyhole", "cache pressure"}, Text: doc.Message,
+			Time: toUnixMilli(doc.Start), TimeEnd: toUnixMilli(doc.End), Title: fmt.Sprintf("cache pressure, %d slow ops", doc.SlowOps)})
+	}
+	for _, build := range li.IndexBuilds {
+		annotation := GrafanaAnnotation{Tags: []string{"keyhole", "index build"}, Text: build.Keys, Time: toUnixMilli(build.Start),
+			Title: fmt.Sprintf("index build %v on %v", build.Name, build.Namespace)}
+		if build.Done == true {
+			annotation.TimeEnd = toUnixMilli(build.End)
+		}
+		annotations = append(annotations, annotation)
+	}
+	for _, op := range li.SlowOps {
+		if op.Time.IsZero() {
+			continue
+		}
+		annotations = append(annotations, GrafanaAnnotation{Tags: []string{"keyhole", "slow op"}, Text: op.Log, Time: toUnixMilli(op.Time),
+			Title: fmt.Sprintf("%s %s %s", op.Command, op.Namespace, strings.TrimSpace(MilliToTimeString(float64(op.Milli))))})
+	}
+	sort.SliceStable(annotations, func(i, j int) bool { return annotations[i].Time < annotations[j].Time })
+	return annotations
+}
+
+func toUnixMilli(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
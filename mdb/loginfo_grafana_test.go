@@ -0,0 +1,48 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestGrafanaOutputFormatter(t *testing.T) {
+	li := NewLogInfo("testdata/cache_pressure.log", "grafana")
+	li.SetSilent(true)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	var series []GrafanaSeries
+	if err := json.Unmarshal([]byte((&GrafanaOutputFormatter{}).GetOutput(li)), &series); err != nil {
+		t.Fatal(err)
+	}
+	if len(series) == 0 || len(series)%3 != 0 {
+		t.Fatal("expected ops, avg ms, and max ms series of commands, but got", len(series))
+	}
+	for _, s := range series {
+		if len(s.Datapoints) == 0 || s.Datapoints[0][1] < 1e12 {
+			t.Fatal("expected datapoints of [value, unix milliseconds]", s)
+		}
+	}
+
+	var annotations []GrafanaAnnotation
+	if err := json.Unmarshal([]byte((&GrafanaOutputFormatter{Annotations: true}).GetOutput(li)), &annotations); err != nil {
+		t.Fatal(err)
+	}
+	pressures := 0
+	for i, a := range annotations {
+		if i > 0 && a.Time < annotations[i-1].Time {
+			t.Fatal("expected annotations sorted by time")
+		}
+		if a.Tags[1] == "cache pressure" {
+			pressures++
+			if a.TimeEnd < a.Time {
+				t.Fatal(a)
+			}
+		}
+	}
+	if pressures != len(li.CachePressure) {
+		t.Fatal("expected", len(li.CachePressure), "cache pressure annotations, but got", pressures)
+	}
+}
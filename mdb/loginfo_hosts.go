@@ -0,0 +1,103 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// hostPrefixRegex matches a line prefixed with a host name of Atlas and Ops Manager log bundles,
+// e.g. host1.example.net:27017 2019-09-28T10:00:00.000-0400 I COMMAND ... or [host1:27017] {"t":...}
+var hostPrefixRegex = regexp.MustCompile(`^\[?([A-Za-z][\w.-]*(?::\d+)?)\]?:?\s+(\d{4}-\d{2}-\d{2}T\S+\s.*|\{.*)$`)
+
+// HostStatsDoc holds ops stats of a host of logs interleaved from multiple nodes
+type HostStatsDoc struct {
+	Collscans  int // number of COLLSCAN ops
+	Count      int // number of ops
+	Host       string
+	MaxMilli   int // max millisecond
+	Patterns   int // number of distinct ops patterns
+	TotalMilli int // total milliseconds
+}
+
+type hostStats struct {
+	doc      HostStatsDoc
+	patterns map[string]bool
+}
+
+// stripHostPrefix returns a log line without the host prefix and keeps the host of the line
+func (li *LogInfo) stripHostPrefix(str string) string {
+	if len(str) == 0 || (str[0] >= '0' && str[0] <= '9') || str[0] == '{' {
+		li.lineHost = ""
+		return str
+	}
+	if result := hostPrefixRegex.FindStringSubmatch(str); len(result) > 2 {
+		li.lineHost = result[1]
+		return result[2]
+	}
+	li.lineHost = ""
+	return str
+}
+
+// getHostKey returns a connection or cursor id prefixed with the host of the line parsed, ids of hosts are unique
+// per host only
+func (li *LogInfo) getHostKey(id string) string {
+	if id == "" || li.lineHost == "" {
+		return id
+	}
+	return li.lineHost + "/" + id
+}
+
+// aggregateHost adds an op to stats of its host
+func (li *LogInfo) aggregateHost(stats opStats, key string) {
+	if stats.host == "" {
+		return
+	}
+	if li.hostsMap == nil {
+		li.hostsMap = map[string]*hostStats{}
+	}
+	host, ok := li.hostsMap[stats.host]
+	if ok == false {
+		host = &hostStats{doc: HostStatsDoc{Host: stats.host}, patterns: map[string]bool{}}
+		li.hostsMap[stats.host] = host
+	}
+	host.doc.Count++
+	host.doc.TotalMilli += stats.milli
+	if stats.milli > host.doc.MaxMilli {
+		host.doc.MaxMilli = stats.milli
+	}
+	if stats.scan == COLLSCAN {
+		host.doc.Collscans++
+	}
+	host.patterns[key] = true
+}
+
+// sortHosts sets host stats, sorted by host names
+func (li *LogInfo) sortHosts() {
+	if len(li.hostsMap) == 0 {
+		return
+	}
+	li.Hosts = make([]HostStatsDoc, 0, len(li.hostsMap))
+	for _, host := range li.hostsMap {
+		host.doc.Patterns = len(host.patterns)
+		li.Hosts = append(li.Hosts, host.doc)
+	}
+	sort.Slice(li.Hosts, func(i, j int) bool { return li.Hosts[i].Host < li.Hosts[j].Host })
+}
+
+// getHostsSummaries returns a table of ops by hosts
+func (li *LogInfo) getHostsSummaries() []string {
+	if len(li.Hosts) == 0 {
+		return []string{}
+	}
+	summaries := []string{"Ops by host:"}
+	summaries = append(summaries, fmt.Sprintf("%-40s %8s %10s %10s %8s %8s", "host", "count", "avg ms", "max ms", "COLLSCAN", "patterns"))
+	for _, host := range li.Hosts {
+		summaries = append(summaries, fmt.Sprintf("%-40s %8d %10s %10d %8d %8d", host.Host, host.Count,
+			strings.TrimSpace(MilliToTimeString(float64(host.TotalMilli)/float64(host.Count))), host.MaxMilli, host.Collscans, host.Patterns))
+	}
+	return append(summaries, "\n")
+}
@@ -0,0 +1,277 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// HTMLOutputFormatter renders loginfo summary as a standalone HTML page
+type HTMLOutputFormatter struct {
+	OutputFormatterBase
+}
+
+const htmlStyle = `<style>
+  body { font-family: Arial, Helvetica, sans-serif; font-size: 13px; margin: 20px; }
+  h1 { font-size: 20px; }
+  h2 { font-size: 16px; margin-top: 24px; }
+  table { border-collapse: collapse; width: 100%; }
+  th, td { border: 1px solid #ccc; padding: 4px 6px; text-align: left; vertical-align: top; }
+  th { background-color: #3d4f58; color: #fff; cursor: pointer; white-space: nowrap; }
+  th:hover { background-color: #21313c; }
+  td.num { text-align: right; font-family: monospace; }
+  td.pattern, pre { font-family: monospace; white-space: pre-wrap; word-break: break-all; }
+  tr:nth-child(even) { background-color: #f5f6f7; }
+  tr.collscan td { background-color: #fcebe2; }
+  tr.collscan td.scan { color: #cf4a22; font-weight: bold; }
+  tr.inefficient td { background-color: #fef7e3; }
+</style>
+`
+
+// sorts a table by a clicked column, numerically if values are numbers
+const htmlScript = `<script>
+function sortTable(th) {
+  var table = th.closest('table');
+  var idx = Array.prototype.indexOf.call(th.parentNode.children, th);
+  var asc = th.getAttribute('data-order') !== 'asc';
+  var rows = Array.prototype.slice.call(table.tBodies[0].rows);
+  rows.sort(function(a, b) {
+    var x = a.cells[idx].getAttribute('data-value') || a.cells[idx].innerText;
+    var y = b.cells[idx].getAttribute('data-value') || b.cells[idx].innerText;
+    var nx = parseFloat(x), ny = parseFloat(y);
+    var r = (isNaN(nx) || isNaN(ny)) ? x.localeCompare(y) : nx - ny;
+    return asc ? r : -r;
+  });
+  rows.forEach(function(row) { table.tBodies[0].appendChild(row); });
+  th.setAttribute('data-order', asc ? 'asc' : 'desc');
+}
+</script>
+`
+
+// WriteHeader writes table header of ops patterns
+func (formatter *HTMLOutputFormatter) WriteHeader(buffer *bytes.Buffer) {
+	buffer.WriteString("<table>\n<thead><tr>")
+	for _, name := range []string{"Command", "COLLSCAN", "Namespace", "Count", "avg ms", "p50 ms", "p90 ms", "p95 ms", "p99 ms", "max ms",
+		"total ms", "keysExamined", "docsExamined", "nreturned", "ratio", "replanned", "in-memory sorts", "usedDisk", "writeConflicts", "lock wait ms", "scatter-gather", "Apps", "Index", "queryHash", "Query Pattern"} {
+		buffer.WriteString("<th onclick=\"sortTable(this)\">" + name + "</th>")
+	}
+	buffer.WriteString("</tr></thead>\n<tbody>\n")
+}
+
+// WriteLine writes a table row of an ops pattern
+func (formatter *HTMLOutputFormatter) WriteLine(buffer *bytes.Buffer, value *LogInfoLineAnalytics) {
+	class := ""
+	scan := ""
+	if value.IsCollectionScan {
+		class = " class=\"collscan\""
+		scan = COLLSCAN
+	} else if value.IsInefficient || value.ScatterGather > 0 || value.InMemorySorts > 0 {
+		class = " class=\"inefficient\""
+	}
+	buffer.WriteString(fmt.Sprintf("<tr%s><td>%s</td><td class=\"scan\">%s</td><td>%s</td>", class,
+		html.EscapeString(value.Command), scan, html.EscapeString(value.Namespace)))
+	buffer.WriteString(fmt.Sprintf("<td class=\"num\">%d</td><td class=\"num\">%.1f</td>", value.Count, value.AvgMilliseconds))
+	for _, n := range []int{value.P50Milliseconds, value.P90Milliseconds, value.P95Milliseconds, value.P99Milliseconds, value.MaxMilliseconds,
+		value.TotalMilliseconds, value.KeysExamined, value.DocsExamined, value.NReturned} {
+		buffer.WriteString(fmt.Sprintf("<td class=\"num\">%d</td>", n))
+	}
+	buffer.WriteString(fmt.Sprintf("<td class=\"num\">%.0f</td><td class=\"num\">%d</td><td class=\"num\">%d</td><td class=\"num\">%d</td><td class=\"num\">%d</td><td class=\"num\">%.1f</td><td class=\"num\">%d</td><td>%s</td>",
+		value.ScannedRatio, value.Replanned, value.InMemorySorts, value.SpilledSorts, value.WriteConflicts, float64(value.LockWaitMicros)/1000, value.ScatterGather,
+		html.EscapeString(strings.Join(value.AppNames, ", "))))
+	examples := ""
+	if len(value.Examples) > 0 {
+		examples = "<details><summary>examples</summary>"
+		for _, example := range value.Examples {
+			examples += fmt.Sprintf("<pre>// %dms at %s\n%s</pre>", example.Milli, example.Time.Format(logTimeLayout), html.EscapeString(example.Statement))
+		}
+		examples += "</details>"
+	}
+	buffer.WriteString(fmt.Sprintf("<td class=\"pattern\">%s</td><td title=\"planCacheKey: %s\">%s</td><td class=\"pattern\">%s%s</td></tr>\n",
+		html.EscapeString(value.IndexUsed), html.EscapeString(value.PlanCacheKey), html.EscapeString(value.QueryHash), html.EscapeString(value.QueryPattern), examples))
+}
+
+// WriteFooter closes the table of ops patterns
+func (formatter *HTMLOutputFormatter) WriteFooter(buffer *bytes.Buffer) {
+	buffer.WriteString("</tbody>\n</table>\n")
+}
+
+// GetOutput returns a self-contained HTML page of config options, slow ops, and ops patterns
+func (formatter *HTMLOutputFormatter) GetOutput(li *LogInfo) string {
+	var buffer bytes.Buffer
+	title := "Keyhole Log Analytics - " + filepath.Base(li.filename)
+	buffer.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	buffer.WriteString("<title>" + html.EscapeString(title) + "</title>\n")
+	buffer.WriteString(htmlStyle)
+	buffer.WriteString(htmlScript)
+	buffer.WriteString("</head>\n<body>\n")
+	buffer.WriteString("<h1>" + html.EscapeString(title) + "</h1>\n")
+	buffer.WriteString("<p>Generated at " + time.Now().Format(time.RFC3339) + "</p>\n")
+	if strings.TrimSpace(li.mongoInfo) != "" {
+		buffer.WriteString("<h2>Server Info</h2>\n<pre>" + html.EscapeString(li.mongoInfo) + "</pre>\n")
+	}
+
+	if len(li.SlowOps) > 0 {
+		buffer.WriteString(fmt.Sprintf("<h2>Top %d Slowest Ops</h2>\n<table>\n", len(li.SlowOps)))
+		buffer.WriteString("<thead><tr>")
+		for _, name := range []string{"Time", "Duration", "Command", "Namespace", "Plan Summary", "Source"} {
+			buffer.WriteString("<th onclick=\"sortTable(this)\">" + name + "</th>")
+		}
+		buffer.WriteString("<th>Log</th></tr></thead>\n<tbody>\n")
+		for _, op := range li.SlowOps {
+			ts := ""
+			if op.Time.IsZero() == false {
+				ts = op.Time.Format(logTimeLayout)
+			}
+			buffer.WriteString(fmt.Sprintf("<tr><td>%s</td><td class=\"num\" data-value=\"%d\">%s</td><td>%s</td><td>%s</td><td class=\"pattern\">%s</td><td>%s</td><td class=\"pattern\">%s</td></tr>\n",
+				ts, op.Milli, strings.TrimSpace(MilliToTimeString(float64(op.Milli))), html.EscapeString(op.Command),
+				html.EscapeString(op.Namespace), html.EscapeString(op.PlanSummary), html.EscapeString(filepath.Base(op.Source)),
+				html.EscapeString(op.Log)))
+		}
+		buffer.WriteString("</tbody>\n</table>\n")
+	}
+
+	if len(li.Apps) > 0 {
+		buffer.WriteString("<h2>Ops by Application</h2>\n<table>\n<thead><tr>")
+		for _, name := range []string{"appName", "Count", "Connections", "avg ms", "max ms", "COLLSCAN", "Patterns"} {
+			buffer.WriteString("<th onclick=\"sortTable(this)\">" + name + "</th>")
+		}
+		buffer.WriteString("</tr></thead>\n<tbody>\n")
+		for _, app := range li.Apps {
+			class := ""
+			if app.Collscans > 0 {
+				class = " class=\"collscan\""
+			}
+			buffer.WriteString(fmt.Sprintf("<tr%s><td>%s</td><td class=\"num\">%d</td><td class=\"num\">%d</td><td class=\"num\">%.1f</td><td class=\"num\">%d</td><td class=\"num scan\">%d</td><td class=\"num\">%d</td></tr>\n",
+				class, html.EscapeString(app.AppName), app.Count, app.Connections, float64(app.TotalMilli)/float64(app.Count),
+				app.MaxMilli, app.Collscans, app.Patterns))
+		}
+		buffer.WriteString("</tbody>\n</table>\n")
+	}
+
+	if len(li.Hosts) > 0 {
+		buffer.WriteString("<h2>Ops by Host</h2>\n<table>\n<thead><tr>")
+		for _, name := range []string{"Host", "Count", "avg ms", "max ms", "COLLSCAN", "Patterns"} {
+			buffer.WriteString("<th onclick=\"sortTable(this)\">" + name + "</th>")
+		}
+		buffer.WriteString("</tr></thead>\n<tbody>\n")
+		for _, host := range li.Hosts {
+			buffer.WriteString(fmt.Sprintf("<tr><td>%s</td><td class=\"num\">%d</td><td class=\"num\">%.1f</td><td class=\"num\">%d</td><td class=\"num scan\">%d</td><td class=\"num\">%d</td></tr>\n",
+				html.EscapeString(host.Host), host.Count, float64(host.TotalMilli)/float64(host.Count), host.MaxMilli, host.Collscans, host.Patterns))
+		}
+		buffer.WriteString("</tbody>\n</table>\n")
+	}
+
+	if len(li.IndexBuilds) > 0 {
+		buffer.WriteString("<h2>Index Builds</h2>\n<table>\n<thead><tr>")
+		for _, name := range []string{"Start", "Duration", "Type", "Resumable", "Slow Ops", "Namespace", "Keys"} {
+			buffer.WriteString("<th onclick=\"sortTable(this)\">" + name + "</th>")
+		}
+		buffer.WriteString("</tr></thead>\n<tbody>\n")
+		for _, build := range li.IndexBuilds {
+			buffer.WriteString(fmt.Sprintf("<tr><td>%s</td><td class=\"num\" data-value=\"%d\">%s</td><td>%s</td><td>%v</td><td class=\"num\">%d</td><td>%s</td><td class=\"pattern\">%s</td></tr>\n",
+				build.Start.Format(logTimeLayout), build.Duration()/time.Millisecond, build.getDurationString(), build.Type, build.Resumable,
+				build.SlowOps, html.EscapeString(build.Namespace), html.EscapeString(build.Keys)))
+		}
+		buffer.WriteString("</tbody>\n</table>\n")
+	}
+
+	if len(li.CachePressure) > 0 {
+		buffer.WriteString("<h2>Cache Pressure Periods</h2>\n<table>\n<thead><tr>")
+		for _, name := range []string{"Start", "End", "Events", "Slow Ops", "avg ms", "Message"} {
+			buffer.WriteString("<th onclick=\"sortTable(this)\">" + name + "</th>")
+		}
+		buffer.WriteString("</tr></thead>\n<tbody>\n")
+		for _, doc := range li.CachePressure {
+			avg := 0.0
+			if doc.SlowOps > 0 {
+				avg = float64(doc.TotalMilli) / float64(doc.SlowOps)
+			}
+			buffer.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%s</td><td class=\"num\">%d</td><td class=\"num\">%d</td><td class=\"num\">%.1f</td><td class=\"pattern\">%s</td></tr>\n",
+				doc.Start.Format(logTimeLayout), doc.End.Format(logTimeLayout), doc.Events, doc.SlowOps, avg, html.EscapeString(doc.Message)))
+		}
+		buffer.WriteString("</tbody>\n</table>\n")
+	}
+
+	if len(li.Cursors) > 0 {
+		buffer.WriteString("<h2>getMore Batches</h2>\n<table>\n<thead><tr>")
+		for _, name := range []string{"Command", "Namespace", "Cursors", "getMores", "avg ms", "max ms", "avg lifetime", "Query Pattern"} {
+			buffer.WriteString("<th onclick=\"sortTable(this)\">" + name + "</th>")
+		}
+		buffer.WriteString("</tr></thead>\n<tbody>\n")
+		for _, doc := range li.Cursors {
+			lifetime := getAvgLifetime(doc)
+			buffer.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%s</td><td class=\"num\">%d</td><td class=\"num\">%d</td><td class=\"num\">%.1f</td><td class=\"num\">%d</td><td class=\"num\" data-value=\"%d\">%s</td><td class=\"pattern\">%s</td></tr>\n",
+				doc.Command, html.EscapeString(doc.Namespace), doc.Cursors, doc.GetMores, float64(doc.TotalMilli)/float64(doc.GetMores), doc.MaxMilli,
+				lifetime, strings.TrimSpace(MilliToTimeString(float64(lifetime))), html.EscapeString(doc.Filter)))
+		}
+		buffer.WriteString("</tbody>\n</table>\n")
+	}
+
+	if len(li.Variants) > 0 {
+		buffer.WriteString("<h2>Query Pattern Variants</h2>\n<table>\n<thead><tr>")
+		for _, name := range []string{"Command", "Namespace", "Query Pattern", "Count", "Shape"} {
+			buffer.WriteString("<th>" + name + "</th>")
+		}
+		buffer.WriteString("</tr></thead>\n<tbody>\n")
+		for _, doc := range li.Variants {
+			for i, variant := range doc.Variants {
+				command, ns, filter := "", "", ""
+				if i == 0 {
+					command, ns, filter = doc.Command, html.EscapeString(doc.Namespace), html.EscapeString(doc.Filter)
+				}
+				buffer.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%s</td><td class=\"pattern\">%s</td><td class=\"num\">%d</td><td class=\"pattern\">%s</td></tr>\n",
+					command, ns, filter, variant.Count, html.EscapeString(variant.Shape)))
+			}
+		}
+		buffer.WriteString("</tbody>\n</table>\n")
+	}
+
+	if len(li.Transactions) > 0 {
+		buffer.WriteString("<h2>Transactions</h2>\n<table>\n<thead><tr>")
+		for _, name := range []string{"Count", "Aborted", "avg ms", "max ms"} {
+			buffer.WriteString("<th onclick=\"sortTable(this)\">" + name + "</th>")
+		}
+		buffer.WriteString("<th>Statements</th></tr></thead>\n<tbody>\n")
+		for _, doc := range li.Transactions {
+			buffer.WriteString(fmt.Sprintf("<tr><td class=\"num\">%d</td><td class=\"num\">%d</td><td class=\"num\">%.1f</td><td class=\"num\">%d</td><td class=\"pattern\">%s</td></tr>\n",
+				doc.Count, doc.Aborted, float64(doc.TotalMilli)/float64(doc.Count), doc.MaxMilli,
+				html.EscapeString(strings.Join(doc.Statements, "; "))))
+		}
+		buffer.WriteString("</tbody>\n</table>\n")
+	}
+
+	if len(li.TimeSeries) > 0 {
+		buffer.WriteString(fmt.Sprintf("<h2>Throughput every %d seconds</h2>\n<table>\n<thead><tr>", li.span))
+		for _, name := range []string{"Time", "Command", "Count", "avg ms", "max ms"} {
+			buffer.WriteString("<th onclick=\"sortTable(this)\">" + name + "</th>")
+		}
+		buffer.WriteString("</tr></thead>\n<tbody>\n")
+		for _, bucket := range li.TimeSeries {
+			buffer.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%s</td><td class=\"num\">%d</td><td class=\"num\">%.1f</td><td class=\"num\">%d</td></tr>\n",
+				bucket.Time.Format(time.RFC3339), bucket.Command, bucket.Count, float64(bucket.TotalMilli)/float64(bucket.Count), bucket.MaxMilli))
+		}
+		buffer.WriteString("</tbody>\n</table>\n")
+	}
+
+	if len(li.OpsPatterns) > 0 {
+		buffer.WriteString(li.getRollupsHTML())
+	}
+	buffer.WriteString("<h2>Ops Patterns</h2>\n")
+	patterns, more := li.getLimitedOpsPatterns()
+	formatter.WriteHeader(&buffer)
+	for _, value := range patterns {
+		line := ConverOpPerformanceDocumentToLogInfoLineAnalytics(&value)
+		formatter.WriteLine(&buffer, &line)
+	}
+	formatter.WriteFooter(&buffer)
+	if more > 0 {
+		buffer.WriteString(fmt.Sprintf("<p>and %d more patterns</p>\n", more))
+	}
+	buffer.WriteString("</body>\n</html>\n")
+	return buffer.String()
+}
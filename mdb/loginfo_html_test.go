@@ -0,0 +1,30 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTMLOutputFormatter(t *testing.T) {
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	li.SetFormatter(&HTMLOutputFormatter{})
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	str := li.printLogsSummary()
+	if strings.HasPrefix(str, "<!DOCTYPE html>") == false || strings.Index(str, "</html>") < 0 {
+		t.Fatal("expected a HTML page")
+	}
+	if strings.Index(str, `<tr class="collscan">`) < 0 {
+		t.Fatal("expected COLLSCAN highlighted")
+	}
+	if strings.Index(str, "sortTable(this)") < 0 {
+		t.Fatal("expected sortable columns")
+	}
+	if strings.Index(str, `&#34;$color&#34;`) < 0 {
+		t.Fatal("expected escaped query patterns")
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// index build types
+const (
+	IndexBuildBackground = "background"
+	IndexBuildForeground = "foreground"
+	IndexBuildHybrid     = "hybrid"
+)
+
+var indexBuildLogRegex = regexp.MustCompile(`^(\S+)\s+\w\s+INDEX\s+\[([^\]]+)\] (.*)$`)
+var indexBuildStartRegex = regexp.MustCompile(`^(?:build index on|index build: starting on):? (\S+) properties: (\{.*\})(?: using method: (\w+))?`)
+var indexBuildDoneRegex = regexp.MustCompile(`^(?:build index done\.\s+scanned (\d+) total records\.|index build: done building index (\S+) on ns (\S+))`)
+
+// IndexBuild holds an index build from logs
+type IndexBuild struct {
+	Done      bool
+	End       time.Time
+	Keys      string
+	Name      string
+	Namespace string
+	Resumable bool
+	Scanned   int
+	SlowOps   int // slow ops logged during the build
+	Start     time.Time
+	Type      string // background, foreground, or hybrid
+}
+
+// logDoc is a structured log line of 4.4 and later
+type logDoc struct {
+	Attr struct {
+		BuildUUID  interface{} `json:"buildUUID"`
+		Message    string      `json:"message"`
+		Namespace  string      `json:"namespace"`
+		Properties struct {
+			Key  interface{} `json:"key"`
+			Name string      `json:"name"`
+		} `json:"properties"`
+	} `json:"attr"`
+	Message string `json:"msg"`
+	Time    struct {
+		Date string `json:"$date"`
+	} `json:"t"`
+}
+
+// Duration returns duration of a finished build
+func (build IndexBuild) Duration() time.Duration {
+	if build.Done == false || build.Start.IsZero() || build.End.IsZero() {
+		return 0
+	}
+	return build.End.Sub(build.Start)
+}
+
+// getDurationString returns duration of a finished build, failed, or unfinished
+func (build IndexBuild) getDurationString() string {
+	if build.Done == true {
+		return strings.TrimSpace(MilliToTimeString(float64(build.Duration() / time.Millisecond)))
+	} else if build.End.IsZero() == false {
+		return "failed"
+	}
+	return "unfinished"
+}
+
+// parseIndexBuild tracks index builds of a log line, returns false if not an index build line
+func (li *LogInfo) parseIndexBuild(str string) bool {
+	if strings.HasPrefix(str, "{") {
+		return li.parseStructuredIndexBuild(str)
+	}
+	if strings.Contains(str, " INDEX ") == false {
+		return false
+	}
+	result := indexBuildLogRegex.FindStringSubmatch(str)
+	if len(result) == 0 {
+		return false
+	}
+	ts, thread, message := getLogTime(result[1]), result[2], result[3]
+	if res := indexBuildStartRegex.FindStringSubmatch(message); len(res) > 0 {
+		build := &IndexBuild{Namespace: res[1], Start: ts, Type: IndexBuildForeground}
+		if doc, err := parseShellDoc(res[2]); err == nil {
+			m := doc.Map()
+			if key, ok := m["key"]; ok {
+				build.Keys = getShapeString(key)
+			}
+			build.Name = toString(m["name"])
+			if background, ok := m["background"].(shellLiteral); ok && background == "true" {
+				build.Type = IndexBuildBackground
+			}
+		}
+		key := thread
+		if res[3] != "" { // indexes of a build are logged one by one since 4.2
+			key = thread + "." + build.Name
+		}
+		if strings.EqualFold(res[3], "hybrid") {
+			build.Type = IndexBuildHybrid
+		}
+		li.startIndexBuild(key, build)
+		return true
+	}
+	if res := indexBuildDoneRegex.FindStringSubmatch(message); len(res) > 0 {
+		key := thread
+		if res[2] != "" {
+			key = thread + "." + res[2]
+		}
+		if build := li.pendingBuilds[key]; build != nil {
+			build.Scanned, _ = strconv.Atoi(res[1])
+			li.finishIndexBuild(key, ts, true)
+		}
+		return true
+	}
+	return false
+}
+
+// parseStructuredIndexBuild tracks index builds of a 4.4 structured log line
+func (li *LogInfo) parseStructuredIndexBuild(str string) bool {
+	if strings.Contains(str, `"c":"INDEX"`) == false || strings.Contains(str, "Index build") == false {
+		return false
+	}
+	var doc logDoc
+	if err := json.Unmarshal([]byte(str), &doc); err != nil {
+		return false
+	}
+	ts := getLogTime(doc.Time.Date)
+	data, _ := json.Marshal(doc.Attr.BuildUUID)
+	buildUUID := string(data) + "."
+	switch {
+	case strings.HasPrefix(doc.Message, "Index build: starting"), strings.HasPrefix(doc.Message, "Index build: resuming"):
+		build := &IndexBuild{Namespace: doc.Attr.Namespace, Name: doc.Attr.Properties.Name, Start: ts, Type: IndexBuildHybrid,
+			Resumable: strings.Contains(doc.Message, "resuming")}
+		if doc.Attr.Properties.Key != nil {
+			b, _ := json.Marshal(doc.Attr.Properties.Key)
+			build.Keys = string(b)
+		}
+		li.startIndexBuild(buildUUID+build.Name, build)
+	case strings.HasPrefix(doc.Message, "Index build: completed successfully"), strings.HasPrefix(doc.Message, "Index build: failed"),
+		strings.HasPrefix(doc.Message, "Index build: aborted"):
+		for key := range li.pendingBuilds { // all indexes of the build
+			if strings.HasPrefix(key, buildUUID) {
+				li.finishIndexBuild(key, ts, strings.Contains(doc.Message, "completed"))
+			}
+		}
+	}
+	return true
+}
+
+// startIndexBuild tracks a build in progress
+func (li *LogInfo) startIndexBuild(key string, build *IndexBuild) {
+	if li.pendingBuilds == nil {
+		li.pendingBuilds = map[string]*IndexBuild{}
+	}
+	li.pendingBuilds[key] = build
+}
+
+// finishIndexBuild ends a build, done is false if failed or aborted
+func (li *LogInfo) finishIndexBuild(key string, ts time.Time, done bool) {
+	build := li.pendingBuilds[key]
+	delete(li.pendingBuilds, key)
+	build.Done = done
+	build.End = ts
+	li.indexBuilds = append(li.indexBuilds, *build)
+}
+
+// countSlowOpsDuringBuilds counts a slow op toward index builds in progress
+func (li *LogInfo) countSlowOpsDuringBuilds(stats opStats) {
+	for _, build := range li.pendingBuilds {
+		if stats.ts.IsZero() == false && stats.ts.Before(build.Start) == false {
+			build.SlowOps++
+		}
+	}
+}
+
+// sortIndexBuilds sets finished and unfinished builds sorted by start time
+func (li *LogInfo) sortIndexBuilds() {
+	if len(li.indexBuilds)+len(li.pendingBuilds) == 0 {
+		return
+	}
+	li.IndexBuilds = append([]IndexBuild{}, li.indexBuilds...)
+	for _, build := range li.pendingBuilds {
+		li.IndexBuilds = append(li.IndexBuilds, *build)
+	}
+	sort.Slice(li.IndexBuilds, func(i, j int) bool { return li.IndexBuilds[i].Start.Before(li.IndexBuilds[j].Start) })
+}
+
+// getIndexBuildsSummaries returns index builds and their durations
+func (li *LogInfo) getIndexBuildsSummaries() []string {
+	if len(li.IndexBuilds) == 0 {
+		return []string{}
+	}
+	summaries := []string{"Index builds:"}
+	summaries = append(summaries, fmt.Sprintf("%-29s %10s %-10s %9s %8s %-33s %s", "start", "duration", "type", "resumable", "slow ops", "namespace", "keys"))
+	for _, build := range li.IndexBuilds {
+		duration := build.getDurationString()
+		summaries = append(summaries, fmt.Sprintf("%-29s %10s %-10s %9v %8d %-33s %s", build.Start.Format(logTimeLayout), duration,
+			build.Type, build.Resumable, build.SlowOps, build.Namespace, build.Keys))
+	}
+	return append(summaries, "\n")
+}
@@ -0,0 +1,213 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// IndexSuggestion is an index of a namespace suggested by ops patterns
+type IndexSuggestion struct {
+	Command   string   // createIndex command
+	Key       string   // index keys, e.g. {color: 1, year: -1}
+	Namespace string   // database.collection
+	Ops       int      // number of ops of patterns
+	Patterns  []string // query patterns supported
+	fields    []string // fields with directions of keys, e.g. color: 1
+}
+
+// SuggestIndexes returns createIndex commands of namespaces by the equality, sort, and range rule
+// applied to ops patterns, indexes that are prefixes of others are removed
+func (li *LogInfo) SuggestIndexes() []IndexSuggestion {
+	nsMap := map[string][]IndexSuggestion{}
+	for _, doc := range li.OpsPatterns {
+		if doc.Namespace == "" || strings.HasSuffix(doc.Namespace, ".$cmd") || doc.Index == "IDHACK" || doc.Index == "EOF" {
+			continue
+		}
+		fields := getESRFields(doc.Filter, doc.Sort)
+		if len(fields) == 0 || fields[0] == "_id: 1" { // _id is indexed
+			continue
+		}
+		nsMap[doc.Namespace] = append(nsMap[doc.Namespace], IndexSuggestion{Namespace: doc.Namespace, Ops: doc.Count,
+			Patterns: []string{doc.Command + " " + doc.Filter}, fields: fields})
+	}
+	suggestions := []IndexSuggestion{}
+	namespaces := make([]string, 0, len(nsMap))
+	for ns := range nsMap {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	for _, ns := range namespaces {
+		suggestions = append(suggestions, dedupeIndexSuggestions(nsMap[ns])...)
+	}
+	for i, s := range suggestions {
+		suggestions[i].Key = "{" + strings.Join(s.fields, ", ") + "}"
+		suggestions[i].Command = getCreateIndexCommand(s.Namespace, suggestions[i].Key)
+	}
+	return suggestions
+}
+
+// esrKeys are fields of a query pattern by the equality, sort, and range rule
+type esrKeys struct {
+	equalities  []string // sorted
+	groupFields []string // fields of $group _id not in equalities
+	sortFields  []string // with directions in order, e.g. year: -1
+	ranges      []string // sorted
+}
+
+// getESRFields returns index fields of a query pattern, equality fields first, then sort, then range
+func getESRFields(pattern string, sortPattern string) []string {
+	keys, ok := getESRKeys(pattern, sortPattern)
+	if ok == false {
+		return []string{}
+	}
+	fields := []string{}
+	for _, field := range keys.equalities {
+		fields = append(fields, field+": 1")
+	}
+	for _, field := range keys.groupFields {
+		fields = append(fields, field+": 1")
+	}
+	fields = append(fields, keys.sortFields...)
+	for _, field := range keys.ranges {
+		fields = append(fields, field+": 1")
+	}
+	return fields
+}
+
+// getESRKeys returns equality, sort, and range fields of a query pattern
+func getESRKeys(pattern string, sortPattern string) (esrKeys, bool) {
+	var err error
+	var keys esrKeys
+	var filter, stages, sortDoc bson.D
+	if filter, stages, err = parseQueryPattern(pattern); err != nil {
+		return keys, false
+	}
+	if sortPattern != "" {
+		sortDoc, _ = parseShellDoc(sortPattern)
+	}
+	groupFields := []string{}
+	for _, stage := range stages {
+		doc, _ := stage.Value.(bson.D)
+		if stage.Key == "sort" {
+			sortDoc = doc
+		} else if stage.Key == "group" && len(doc) > 0 && doc[0].Key == "_id" {
+			groupFields = getGroupFields(doc[0].Value)
+		}
+	}
+	sortFields := []string{}
+	for _, elem := range sortDoc {
+		direction := 1
+		if toInt(elem.Value) < 0 {
+			direction = -1
+		} else if _, ok := elem.Value.(bson.D); ok { // {$meta: "textScore"}
+			continue
+		}
+		sortFields = append(sortFields, fmt.Sprintf("%s: %d", elem.Key, direction))
+	}
+	equalities, ranges := []string{}, []string{}
+	for field, isRange := range getFilterFields(filter) {
+		if isIndexField(sortDoc, field) == true {
+			continue // placed by sort
+		} else if isRange == true {
+			ranges = append(ranges, field)
+		} else {
+			equalities = append(equalities, field)
+		}
+	}
+	sort.Strings(equalities)
+	sort.Strings(ranges)
+	keys.equalities, keys.sortFields, keys.ranges = equalities, sortFields, ranges
+	for _, field := range groupFields {
+		if contains(equalities, field) == false {
+			keys.groupFields = append(keys.groupFields, field)
+		}
+	}
+	return keys, true
+}
+
+// getGroupFields returns fields of _id of a $group stage, e.g. "$color" or {color: "$color", year: "$year"}
+func getGroupFields(id interface{}) []string {
+	fields := []string{}
+	switch v := id.(type) {
+	case string:
+		if strings.HasPrefix(v, "$") {
+			fields = append(fields, v[1:])
+		}
+	case bson.D:
+		for _, elem := range v {
+			fields = append(fields, getGroupFields(elem.Value)...)
+		}
+	}
+	return fields
+}
+
+func isIndexField(keys bson.D, field string) bool {
+	for _, elem := range keys {
+		if elem.Key == field {
+			return true
+		}
+	}
+	return false
+}
+
+// dedupeIndexSuggestions merges identical indexes and removes indexes that are prefixes of others
+func dedupeIndexSuggestions(suggestions []IndexSuggestion) []IndexSuggestion {
+	sort.SliceStable(suggestions, func(i, j int) bool { return len(suggestions[i].fields) > len(suggestions[j].fields) })
+	results := []IndexSuggestion{}
+	for _, s := range suggestions {
+		merged := false
+		for i, r := range results {
+			if isPrefixFields(s.fields, r.fields) {
+				results[i].Ops += s.Ops
+				results[i].Patterns = append(results[i].Patterns, s.Patterns...)
+				merged = true
+				break
+			}
+		}
+		if merged == false {
+			results = append(results, s)
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Ops > results[j].Ops })
+	return results
+}
+
+func isPrefixFields(prefix []string, fields []string) bool {
+	if len(prefix) > len(fields) {
+		return false
+	}
+	for i, field := range prefix {
+		if fields[i] != field {
+			return false
+		}
+	}
+	return true
+}
+
+// getCreateIndexCommand returns a createIndex command of mongo shell
+func getCreateIndexCommand(namespace string, key string) string {
+	idx := strings.Index(namespace, ".")
+	if idx < 0 {
+		return fmt.Sprintf("db.getCollection(%q).createIndex(%s)", namespace, key)
+	}
+	return fmt.Sprintf("db.getSiblingDB(%q).getCollection(%q).createIndex(%s)", namespace[:idx], namespace[idx+1:], key)
+}
+
+// GetIndexSuggestionsSummary returns createIndex commands grouped by namespaces
+func GetIndexSuggestionsSummary(suggestions []IndexSuggestion) string {
+	lines := []string{}
+	namespace := ""
+	for _, s := range suggestions {
+		if s.Namespace != namespace {
+			namespace = s.Namespace
+			lines = append(lines, "// "+namespace)
+		}
+		lines = append(lines, fmt.Sprintf("%s // %d ops of %d patterns", s.Command, s.Ops, len(s.Patterns)))
+	}
+	return strings.Join(lines, "\n")
+}
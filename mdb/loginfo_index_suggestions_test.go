@@ -0,0 +1,69 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSuggestIndexes(t *testing.T) {
+	li := &LogInfo{OpsPatterns: []OpPerformanceDoc{
+		{Command: "find", Count: 10, Filter: "{color: 1, price: {$gt: 1}, year: 1}", Namespace: "keyhole.cars", Sort: "{year: -1}"},
+		{Command: "find", Count: 5, Filter: "{color: 1}", Namespace: "keyhole.cars"},
+		{Command: "aggregate", Count: 3, Filter: `{brand: 1}, group: {_id: "$color", count: {$sum: 1}}`, Namespace: "keyhole.cars"},
+		{Command: "update", Count: 2, Filter: "{_id: 1}", Index: "IDHACK", Namespace: "keyhole.cars"},
+		{Command: "find", Count: 1, Filter: "{name: /regex/, state: {$in: [... ]}}", Namespace: "keyhole.dealers"},
+	}}
+	suggestions := li.SuggestIndexes()
+	if len(suggestions) != 3 {
+		t.Fatal("expected 3 suggestions, but got", suggestions)
+	}
+	if suggestions[0].Key != "{color: 1, year: -1, price: 1}" || suggestions[0].Ops != 15 || len(suggestions[0].Patterns) != 2 {
+		t.Fatal(suggestions[0])
+	}
+	if suggestions[1].Key != "{brand: 1, color: 1}" {
+		t.Fatal(suggestions[1])
+	}
+	if suggestions[2].Command != `db.getSiblingDB("keyhole").getCollection("dealers").createIndex({state: 1, name: 1})` {
+		t.Fatal(suggestions[2].Command)
+	}
+	summary := GetIndexSuggestionsSummary(suggestions)
+	if strings.Contains(summary, "// keyhole.cars\n") == false || strings.Contains(summary, "// 15 ops of 2 patterns") == false {
+		t.Fatal(summary)
+	}
+}
+
+func TestLogInfoSortPattern(t *testing.T) {
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	for _, doc := range li.OpsPatterns {
+		if doc.Filter == "{color: 1, year: {$gt: 1}}" && doc.Sort != "{brand: 1}" {
+			t.Fatal("expected sort {brand: 1}, but got", doc.Sort)
+		}
+	}
+	for _, s := range li.SuggestIndexes() {
+		if s.Namespace == "keyhole.cars" && strings.Contains(s.Key, "color: 1, brand: 1, year: 1") {
+			return
+		}
+	}
+	t.Fatal("expected index {color: 1, brand: 1, year: 1}", li.SuggestIndexes())
+}
+
+func TestLogInfoSortPatterns(t *testing.T) {
+	li := NewLogInfo("", "")
+	li.aggregate(opStats{command: "find", namespace: "keyhole.cars", filter: "{color: 1}", sort: "{year: 1}", milli: 100})
+	li.aggregate(opStats{command: "find", namespace: "keyhole.cars", filter: "{color: 1}", sort: "{year: -1}", milli: 200})
+	li.aggregate(opStats{command: "find", namespace: "keyhole.cars", filter: "{color: 1}", sort: "{year: 1}", milli: 300})
+	if len(li.opsMap) != 2 {
+		t.Fatal("expected 2 ops patterns of different sorts, but got", len(li.opsMap))
+	}
+	for _, doc := range li.opsMap {
+		if (doc.Sort == "{year: 1}" && doc.Count != 2) || (doc.Sort == "{year: -1}" && doc.Count != 1) {
+			t.Fatal(doc)
+		}
+	}
+}
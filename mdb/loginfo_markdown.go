@@ -0,0 +1,206 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MarkdownOutputFormatter renders loginfo summary in markdown for GitHub issues and Confluence
+type MarkdownOutputFormatter struct {
+	OutputFormatterBase
+	rows int
+}
+
+// WriteHeader writes table header of ops patterns
+func (formatter *MarkdownOutputFormatter) WriteHeader(buffer *bytes.Buffer) {
+	formatter.rows = 0
+	buffer.WriteString("| # | Command | COLLSCAN | Namespace | Count | avg ms | p50 ms | p90 ms | p95 ms | p99 ms | max ms | ratio | replanned | sorts | conflicts | lock wait ms | Index | queryHash |\n")
+	buffer.WriteString("|--:|---------|----------|-----------|------:|-------:|-------:|-------:|-------:|-------:|-------:|------:|----------:|------:|----------:|-------------:|-------|-----------|\n")
+}
+
+// WriteLine writes a table row of an ops pattern, the query pattern is written separately
+func (formatter *MarkdownOutputFormatter) WriteLine(buffer *bytes.Buffer, value *LogInfoLineAnalytics) {
+	formatter.rows++
+	scan := ""
+	if value.IsCollectionScan {
+		scan = "**" + COLLSCAN + "**"
+	}
+	index := ""
+	if value.IndexUsed != "" {
+		index = "`" + escapeMarkdownCell(value.IndexUsed) + "`"
+	}
+	buffer.WriteString(fmt.Sprintf("| %d | %s | %s | %s | %d | %.1f | %d | %d | %d | %d | %d | %.0f | %d | %s | %d | %.1f | %s | %s |\n", formatter.rows,
+		value.Command, scan, escapeMarkdownCell(value.Namespace), value.Count, value.AvgMilliseconds,
+		value.P50Milliseconds, value.P90Milliseconds, value.P95Milliseconds, value.P99Milliseconds, value.MaxMilliseconds, value.ScannedRatio, value.Replanned, getSortsCell(value),
+		value.WriteConflicts, float64(value.LockWaitMicros)/1000, index, value.QueryHash))
+}
+
+// getSortsCell returns number of in-memory sorts, spilled sorts are in bold
+func getSortsCell(value *LogInfoLineAnalytics) string {
+	if value.SpilledSorts > 0 {
+		return fmt.Sprintf("%d (**%d usedDisk**)", value.InMemorySorts, value.SpilledSorts)
+	}
+	return fmt.Sprintf("%d", value.InMemorySorts)
+}
+
+// WriteFooter ends the table of ops patterns
+func (formatter *MarkdownOutputFormatter) WriteFooter(buffer *bytes.Buffer) {
+	buffer.WriteString("\n")
+}
+
+// GetOutput returns markdown of config options, slow ops, and ops patterns
+func (formatter *MarkdownOutputFormatter) GetOutput(li *LogInfo) string {
+	var buffer bytes.Buffer
+	buffer.WriteString("# Log Analytics - " + filepath.Base(li.filename) + "\n\n")
+	if strings.TrimSpace(li.mongoInfo) != "" {
+		buffer.WriteString("## Server Info\n\n```\n" + strings.TrimSpace(li.mongoInfo) + "\n```\n\n")
+	}
+
+	if len(li.SlowOps) > 0 {
+		buffer.WriteString(fmt.Sprintf("## Top %d Slowest Ops\n\n", len(li.SlowOps)))
+		for i, op := range li.SlowOps {
+			buffer.WriteString(fmt.Sprintf("%d. **%s** (%dms) %s `%s`", i+1, strings.TrimSpace(MilliToTimeString(float64(op.Milli))),
+				op.Milli, op.Command, op.Namespace))
+			if op.PlanSummary != "" {
+				buffer.WriteString(" " + op.PlanSummary)
+			}
+			if op.Time.IsZero() == false {
+				buffer.WriteString(" at " + op.Time.Format(logTimeLayout))
+			}
+			if op.Source != "" {
+				buffer.WriteString(" `" + filepath.Base(op.Source) + "`")
+			}
+			buffer.WriteString("\n\n   ```\n   " + op.Log + "\n   ```\n\n")
+		}
+	}
+
+	if len(li.Apps) > 0 {
+		buffer.WriteString("## Ops by Application\n\n")
+		buffer.WriteString("| appName | Count | Connections | avg ms | max ms | COLLSCAN | Patterns |\n")
+		buffer.WriteString("|---------|------:|------------:|-------:|-------:|---------:|---------:|\n")
+		for _, app := range li.Apps {
+			buffer.WriteString(fmt.Sprintf("| %s | %d | %d | %.1f | %d | %d | %d |\n", escapeMarkdownCell(app.AppName), app.Count,
+				app.Connections, float64(app.TotalMilli)/float64(app.Count), app.MaxMilli, app.Collscans, app.Patterns))
+		}
+		buffer.WriteString("\n")
+	}
+
+	if len(li.Hosts) > 0 {
+		buffer.WriteString("## Ops by Host\n\n")
+		buffer.WriteString("| Host | Count | avg ms | max ms | COLLSCAN | Patterns |\n|------|------:|-------:|-------:|---------:|---------:|\n")
+		for _, host := range li.Hosts {
+			buffer.WriteString(fmt.Sprintf("| %s | %d | %.1f | %d | %d | %d |\n", escapeMarkdownCell(host.Host), host.Count,
+				float64(host.TotalMilli)/float64(host.Count), host.MaxMilli, host.Collscans, host.Patterns))
+		}
+		buffer.WriteString("\n")
+	}
+
+	if len(li.IndexBuilds) > 0 {
+		buffer.WriteString("## Index Builds\n\n")
+		buffer.WriteString("| Start | Duration | Type | Resumable | Slow Ops | Namespace | Keys |\n|-------|---------:|------|-----------|---------:|-----------|------|\n")
+		for _, build := range li.IndexBuilds {
+			buffer.WriteString(fmt.Sprintf("| %s | %s | %s | %v | %d | %s | `%s` |\n", build.Start.Format(logTimeLayout), build.getDurationString(),
+				build.Type, build.Resumable, build.SlowOps, escapeMarkdownCell(build.Namespace), escapeMarkdownCell(build.Keys)))
+		}
+		buffer.WriteString("\n")
+	}
+
+	if len(li.CachePressure) > 0 {
+		buffer.WriteString("## Cache Pressure Periods\n\n")
+		buffer.WriteString("| Start | End | Events | Slow Ops | avg ms | Message |\n|-------|-----|-------:|---------:|-------:|---------|\n")
+		for _, doc := range li.CachePressure {
+			avg := 0.0
+			if doc.SlowOps > 0 {
+				avg = float64(doc.TotalMilli) / float64(doc.SlowOps)
+			}
+			buffer.WriteString(fmt.Sprintf("| %s | %s | %d | %d | %.1f | %s |\n", doc.Start.Format(logTimeLayout), doc.End.Format(logTimeLayout),
+				doc.Events, doc.SlowOps, avg, escapeMarkdownCell(doc.Message)))
+		}
+		buffer.WriteString("\n")
+	}
+
+	if len(li.Cursors) > 0 {
+		buffer.WriteString("## getMore Batches\n\n")
+		buffer.WriteString("| Command | Namespace | Cursors | getMores | avg ms | max ms | avg lifetime | Query Pattern |\n")
+		buffer.WriteString("|---------|-----------|--------:|---------:|-------:|-------:|-------------:|---------------|\n")
+		for _, doc := range li.Cursors {
+			buffer.WriteString(fmt.Sprintf("| %s | %s | %d | %d | %.1f | %d | %s | `%s` |\n", doc.Command, escapeMarkdownCell(doc.Namespace),
+				doc.Cursors, doc.GetMores, float64(doc.TotalMilli)/float64(doc.GetMores), doc.MaxMilli,
+				strings.TrimSpace(MilliToTimeString(float64(doc.LifetimeMilli)/float64(doc.Cursors))), escapeMarkdownCell(doc.Filter)))
+		}
+		buffer.WriteString("\n")
+	}
+
+	if len(li.Variants) > 0 {
+		buffer.WriteString("## Query Pattern Variants\n\n")
+		buffer.WriteString("| Command | Namespace | Query Pattern | Count | Shape |\n|---------|-----------|---------------|------:|-------|\n")
+		for _, doc := range li.Variants {
+			for _, variant := range doc.Variants {
+				buffer.WriteString(fmt.Sprintf("| %s | %s | `%s` | %d | `%s` |\n", doc.Command, escapeMarkdownCell(doc.Namespace),
+					escapeMarkdownCell(doc.Filter), variant.Count, escapeMarkdownCell(variant.Shape)))
+			}
+		}
+		buffer.WriteString("\n")
+	}
+
+	if len(li.Transactions) > 0 {
+		buffer.WriteString("## Transactions\n\n")
+		buffer.WriteString("| Count | Aborted | avg ms | max ms | Statements |\n|------:|--------:|-------:|-------:|------------|\n")
+		for _, doc := range li.Transactions {
+			buffer.WriteString(fmt.Sprintf("| %d | %d | %.1f | %d | %s |\n", doc.Count, doc.Aborted, float64(doc.TotalMilli)/float64(doc.Count),
+				doc.MaxMilli, escapeMarkdownCell(strings.Join(doc.Statements, "; "))))
+		}
+		buffer.WriteString("\n")
+	}
+
+	if len(li.TimeSeries) > 0 {
+		buffer.WriteString(fmt.Sprintf("## Throughput every %d seconds\n\n", li.span))
+		buffer.WriteString("| Time | Command | Count | avg ms | max ms |\n|------|---------|------:|-------:|-------:|\n")
+		for _, bucket := range li.TimeSeries {
+			buffer.WriteString(fmt.Sprintf("| %s | %s | %d | %.1f | %d |\n", bucket.Time.Format(time.RFC3339), bucket.Command,
+				bucket.Count, float64(bucket.TotalMilli)/float64(bucket.Count), bucket.MaxMilli))
+		}
+		buffer.WriteString("\n")
+	}
+
+	lines := []LogInfoLineAnalytics{}
+	patterns, more := li.getLimitedOpsPatterns()
+	for _, value := range patterns {
+		lines = append(lines, ConverOpPerformanceDocumentToLogInfoLineAnalytics(&value))
+	}
+	if len(li.OpsPatterns) > 0 {
+		buffer.WriteString(li.getRollupsMarkdown())
+	}
+	buffer.WriteString("## Ops Patterns\n\n")
+	formatter.WriteHeader(&buffer)
+	for i := range lines {
+		formatter.WriteLine(&buffer, &lines[i])
+	}
+	formatter.WriteFooter(&buffer)
+	if more > 0 {
+		buffer.WriteString(fmt.Sprintf("_and %d more patterns_\n\n", more))
+	}
+
+	buffer.WriteString("## Query Patterns\n\n")
+	for i, line := range lines {
+		buffer.WriteString(fmt.Sprintf("%d. %s `%s`\n\n   ```js\n   %s\n   ```\n\n", i+1, line.Command, line.Namespace, line.QueryPattern))
+		if len(line.Examples) > 0 {
+			buffer.WriteString("   Examples:\n\n   ```js\n")
+			for _, example := range line.Examples {
+				buffer.WriteString(fmt.Sprintf("   // %dms at %s\n   %s\n", example.Milli, example.Time.Format(logTimeLayout), example.Statement))
+			}
+			buffer.WriteString("   ```\n\n")
+		}
+	}
+	return buffer.String()
+}
+
+// escapeMarkdownCell escapes pipes in a table cell
+func escapeMarkdownCell(str string) string {
+	return strings.Replace(str, "|", "\\|", -1)
+}
@@ -0,0 +1,26 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdownOutputFormatter(t *testing.T) {
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	li.SetFormatter(&MarkdownOutputFormatter{})
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	str := li.printLogsSummary()
+	for _, s := range []string{"## Top ", "## Ops Patterns", "| 1 | ", "**COLLSCAN**", "```js\n   {color: 1, year: {$gt: 1}}\n   ```"} {
+		if strings.Index(str, s) < 0 {
+			t.Fatal("expected", s)
+		}
+	}
+	if escapeMarkdownCell("a|b") != `a\|b` {
+		t.Fatal(escapeMarkdownCell("a|b"))
+	}
+}
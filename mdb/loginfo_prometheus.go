@@ -0,0 +1,163 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// prometheusBuckets are upper bounds in seconds of latency histograms
+var prometheusBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// PrometheusOutputFormatter writes ops patterns in Prometheus text exposition format, patterns of
+// the same namespace, command, and COLLSCAN are merged into a series
+type PrometheusOutputFormatter struct {
+	OutputFormatterBase
+	series map[string]*prometheusSeries
+}
+
+type prometheusSeries struct {
+	collscan     bool
+	command      string
+	docsExamined int
+	histogram    LatencyHistogram
+	keysExamined int
+	namespace    string
+	nreturned    int
+	totalMilli   int
+}
+
+// WriteHeader starts series of ops patterns
+func (formatter *PrometheusOutputFormatter) WriteHeader(buffer *bytes.Buffer) {
+	formatter.series = map[string]*prometheusSeries{}
+}
+
+// WriteLine merges an ops pattern into its series
+func (formatter *PrometheusOutputFormatter) WriteLine(buffer *bytes.Buffer, value *LogInfoLineAnalytics) {
+	formatter.addSeries(value.Namespace, value.Command, value.IsCollectionScan, value.TotalMilliseconds, value.KeysExamined,
+		value.DocsExamined, value.NReturned, getAverageHistogram(value.Count, value.TotalMilliseconds))
+}
+
+// WriteFooter writes metrics of all series
+func (formatter *PrometheusOutputFormatter) WriteFooter(buffer *bytes.Buffer) {
+	keys := make([]string, 0, len(formatter.series))
+	for key := range formatter.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	buffer.WriteString("# HELP keyhole_ops_total Number of slow ops logged.\n# TYPE keyhole_ops_total counter\n")
+	for _, key := range keys {
+		s := formatter.series[key]
+		buffer.WriteString(fmt.Sprintf("keyhole_ops_total{%s} %d\n", s.getLabels(), s.histogram.Count))
+	}
+	buffer.WriteString("# HELP keyhole_op_duration_seconds Latencies of slow ops logged.\n# TYPE keyhole_op_duration_seconds histogram\n")
+	for _, key := range keys {
+		s := formatter.series[key]
+		labels := s.getLabels()
+		for _, le := range prometheusBuckets {
+			buffer.WriteString(fmt.Sprintf("keyhole_op_duration_seconds_bucket{%s,le=\"%g\"} %d\n", labels, le,
+				s.histogram.CountAtMost(int(le*1000))))
+		}
+		buffer.WriteString(fmt.Sprintf("keyhole_op_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, s.histogram.Count))
+		buffer.WriteString(fmt.Sprintf("keyhole_op_duration_seconds_sum{%s} %g\n", labels, float64(s.totalMilli)/1000))
+		buffer.WriteString(fmt.Sprintf("keyhole_op_duration_seconds_count{%s} %d\n", labels, s.histogram.Count))
+	}
+	for _, metric := range []string{"keys_examined", "docs_examined", "nreturned"} {
+		buffer.WriteString(fmt.Sprintf("# HELP keyhole_%s_total Total %s of slow ops logged.\n# TYPE keyhole_%s_total counter\n",
+			metric, strings.Replace(metric, "_", " ", -1), metric))
+		for _, key := range keys {
+			s := formatter.series[key]
+			value := s.nreturned
+			if metric == "keys_examined" {
+				value = s.keysExamined
+			} else if metric == "docs_examined" {
+				value = s.docsExamined
+			}
+			buffer.WriteString(fmt.Sprintf("keyhole_%s_total{%s} %d\n", metric, s.getLabels(), value))
+		}
+	}
+}
+
+// GetOutput returns metrics of ops patterns, latency histograms are from patterns' latencies distributions
+func (formatter *PrometheusOutputFormatter) GetOutput(li *LogInfo) string {
+	var buffer bytes.Buffer
+	formatter.WriteHeader(&buffer)
+	for _, doc := range li.OpsPatterns {
+		histogram := doc.Histogram
+		if histogram.Count == 0 { // patterns of old .enc files
+			histogram = getAverageHistogram(doc.Count, doc.TotalMilli)
+		}
+		formatter.addSeries(doc.Namespace, doc.Command, doc.Scan == COLLSCAN, doc.TotalMilli, doc.KeysExamined, doc.DocsExamined,
+			doc.NReturned, histogram)
+	}
+	formatter.WriteFooter(&buffer)
+	return buffer.String()
+}
+
+// addSeries merges stats into a series of a namespace, command, and COLLSCAN
+func (formatter *PrometheusOutputFormatter) addSeries(ns string, command string, collscan bool, totalMilli int, keysExamined int,
+	docsExamined int, nreturned int, histogram LatencyHistogram) {
+	if formatter.series == nil {
+		formatter.series = map[string]*prometheusSeries{}
+	}
+	key := fmt.Sprintf("%s\x00%s\x00%v", ns, command, collscan)
+	s, ok := formatter.series[key]
+	if ok == false {
+		s = &prometheusSeries{collscan: collscan, command: command, histogram: NewLatencyHistogram(), namespace: ns}
+		formatter.series[key] = s
+	}
+	s.docsExamined += docsExamined
+	s.keysExamined += keysExamined
+	s.nreturned += nreturned
+	s.totalMilli += totalMilli
+	s.histogram.Merge(histogram)
+}
+
+// getAverageHistogram returns a histogram of ops at their average latency, distributions aren't known
+func getAverageHistogram(count int, totalMilli int) LatencyHistogram {
+	histogram := NewLatencyHistogram()
+	if count > 0 {
+		avg := totalMilli / count
+		histogram.Buckets[getBucketIndex(avg)] = count
+		histogram.Count, histogram.Min, histogram.Max = count, avg, avg
+	}
+	return histogram
+}
+
+func (s *prometheusSeries) getLabels() string {
+	return fmt.Sprintf(`ns="%s",op="%s",collscan="%v"`, escapeLabelValue(s.namespace), escapeLabelValue(s.command), s.collscan)
+}
+
+// escapeLabelValue escapes backslashes, double quotes, and line feeds of a label value
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// SetExposeMetrics sets to serve metrics at addresses other than localhost, e.g. 0.0.0.0:9216, without
+// authentication
+func (li *LogInfo) SetExposeMetrics(expose bool) {
+	li.exposeMetrics = expose
+}
+
+// ServeMetrics serves metrics of ops patterns in Prometheus format at /metrics of an address, e.g. :9216 of
+// localhost, other hosts only if exposed
+func (li *LogInfo) ServeMetrics(addr string) error {
+	if addr = getListenAddr(addr); isLoopbackAddr(addr) == false && li.exposeMetrics == false {
+		return errors.New("metrics aren't exposed at " + addr + ", serve at localhost or expose them")
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", li.getMetricsHandler())
+	return http.ListenAndServe(addr, mux)
+}
+
+func (li *LogInfo) getMetricsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.Write([]byte((&PrometheusOutputFormatter{}).GetOutput(li)))
+	}
+}
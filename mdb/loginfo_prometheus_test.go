@@ -0,0 +1,51 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPrometheusOutputFormatter(t *testing.T) {
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	str := (&PrometheusOutputFormatter{}).GetOutput(li)
+	for _, s := range []string{"# TYPE keyhole_ops_total counter", "# TYPE keyhole_op_duration_seconds histogram",
+		`collscan="true"`, `le="+Inf"}`, "keyhole_op_duration_seconds_sum{", "keyhole_docs_examined_total{"} {
+		if strings.Contains(str, s) == false {
+			t.Fatal("expected", s)
+		}
+	}
+	series := map[string]bool{}
+	for _, line := range strings.Split(str, "\n") {
+		if strings.HasPrefix(line, "keyhole_ops_total{") {
+			labels := line[:strings.LastIndex(line, " ")]
+			if series[labels] == true {
+				t.Fatal("duplicate series", labels)
+			}
+			series[labels] = true
+		}
+	}
+	if escapeLabelValue(`a"b\c`) != `a\"b\\c` {
+		t.Fatal(escapeLabelValue(`a"b\c`))
+	}
+
+	w := httptest.NewRecorder()
+	handler := li.getMetricsHandler()
+	handler(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Body.String() != str {
+		t.Fatal("expected metrics served")
+	}
+}
+
+func TestServeMetricsNotExposed(t *testing.T) {
+	li := NewLogInfo("testdata/mongod.log", "")
+	if err := li.ServeMetrics("0.0.0.0:0"); err == nil || strings.Contains(err.Error(), "aren't exposed") == false {
+		t.Fatal("expected an error of metrics not exposed, but got", err)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+)
+
+// RollupDoc is ops stats of ops patterns of a database or a namespace
+type RollupDoc struct {
+	Name       string
+	Collscans  int // number of COLLSCAN ops
+	Count      int // number of ops
+	MaxMilli   int // max millisecond
+	Patterns   int // number of distinct ops patterns
+	TotalMilli int // total milliseconds
+}
+
+// GetCollscanPercent returns percentage of COLLSCAN ops
+func (doc RollupDoc) GetCollscanPercent() float64 {
+	if doc.Count == 0 {
+		return 0
+	}
+	return 100 * float64(doc.Collscans) / float64(doc.Count)
+}
+
+// GetDatabasesRollup returns ops stats by databases, sorted by total time
+func (li *LogInfo) GetDatabasesRollup() []RollupDoc {
+	return getRollups(li.OpsPatterns, func(ns string) string {
+		if idx := strings.Index(ns, "."); idx > 0 {
+			return ns[:idx]
+		}
+		return ns
+	})
+}
+
+// GetNamespacesRollup returns ops stats by namespaces, sorted by total time
+func (li *LogInfo) GetNamespacesRollup() []RollupDoc {
+	return getRollups(li.OpsPatterns, func(ns string) string { return ns })
+}
+
+// getRollups returns ops stats of ops patterns grouped by names of namespaces, sorted by total time
+func getRollups(patterns []OpPerformanceDoc, nameOf func(ns string) string) []RollupDoc {
+	rollupsMap := map[string]*RollupDoc{}
+	for _, doc := range patterns {
+		name := nameOf(doc.Namespace)
+		rollup, ok := rollupsMap[name]
+		if ok == false {
+			rollup = &RollupDoc{Name: name}
+			rollupsMap[name] = rollup
+		}
+		rollup.Count += doc.Count
+		rollup.Patterns++
+		rollup.TotalMilli += doc.TotalMilli
+		if doc.MaxMilli > rollup.MaxMilli {
+			rollup.MaxMilli = doc.MaxMilli
+		}
+		if doc.Scan == COLLSCAN {
+			rollup.Collscans += doc.Count
+		}
+	}
+	rollups := make([]RollupDoc, 0, len(rollupsMap))
+	for _, rollup := range rollupsMap {
+		rollups = append(rollups, *rollup)
+	}
+	sort.Slice(rollups, func(i, j int) bool {
+		if rollups[i].TotalMilli == rollups[j].TotalMilli {
+			return rollups[i].Name < rollups[j].Name
+		}
+		return rollups[i].TotalMilli > rollups[j].TotalMilli
+	})
+	return rollups
+}
+
+// getRollupsSummary returns tables of ops stats by databases and by namespaces
+func (li *LogInfo) getRollupsSummary() string {
+	var buffer bytes.Buffer
+	for i, rollups := range [][]RollupDoc{li.GetDatabasesRollup(), li.GetNamespacesRollup()} {
+		name := []string{"Database", "Namespace"}[i]
+		buffer.WriteString(fmt.Sprintf("%-48s %10s %12s %10s %10s %9s\n", name, "count", "total ms", "max ms", "COLLSCAN %", "patterns"))
+		for _, doc := range rollups {
+			buffer.WriteString(fmt.Sprintf("%-48s %10d %12d %10d %10.1f %9d\n", doc.Name, doc.Count, doc.TotalMilli, doc.MaxMilli,
+				doc.GetCollscanPercent(), doc.Patterns))
+		}
+		buffer.WriteString("\n")
+	}
+	return buffer.String()
+}
+
+// getRollupsHTML returns HTML tables of ops stats by databases and by namespaces
+func (li *LogInfo) getRollupsHTML() string {
+	var buffer bytes.Buffer
+	for i, rollups := range [][]RollupDoc{li.GetDatabasesRollup(), li.GetNamespacesRollup()} {
+		name := []string{"Database", "Namespace"}[i]
+		buffer.WriteString("<h2>Ops by " + name + "</h2>\n<table>\n<thead><tr>")
+		for _, column := range []string{name, "Count", "total ms", "max ms", "COLLSCAN %", "Patterns"} {
+			buffer.WriteString("<th onclick=\"sortTable(this)\">" + column + "</th>")
+		}
+		buffer.WriteString("</tr></thead>\n<tbody>\n")
+		for _, doc := range rollups {
+			class := ""
+			if doc.Collscans > 0 {
+				class = " class=\"collscan\""
+			}
+			buffer.WriteString(fmt.Sprintf("<tr%s><td>%s</td><td class=\"num\">%d</td><td class=\"num\">%d</td><td class=\"num\">%d</td><td class=\"num scan\">%.1f</td><td class=\"num\">%d</td></tr>\n",
+				class, html.EscapeString(doc.Name), doc.Count, doc.TotalMilli, doc.MaxMilli, doc.GetCollscanPercent(), doc.Patterns))
+		}
+		buffer.WriteString("</tbody>\n</table>\n")
+	}
+	return buffer.String()
+}
+
+// getRollupsMarkdown returns markdown tables of ops stats by databases and by namespaces
+func (li *LogInfo) getRollupsMarkdown() string {
+	var buffer bytes.Buffer
+	for i, rollups := range [][]RollupDoc{li.GetDatabasesRollup(), li.GetNamespacesRollup()} {
+		name := []string{"Database", "Namespace"}[i]
+		buffer.WriteString("## Ops by " + name + "\n\n")
+		buffer.WriteString("| " + name + " | Count | total ms | max ms | COLLSCAN % | Patterns |\n")
+		buffer.WriteString("|" + strings.Repeat("-", len(name)+2) + "|------:|---------:|-------:|-----------:|---------:|\n")
+		for _, doc := range rollups {
+			buffer.WriteString(fmt.Sprintf("| %s | %d | %d | %d | %.1f | %d |\n", escapeMarkdownCell(doc.Name), doc.Count, doc.TotalMilli,
+				doc.MaxMilli, doc.GetCollscanPercent(), doc.Patterns))
+		}
+		buffer.WriteString("\n")
+	}
+	return buffer.String()
+}
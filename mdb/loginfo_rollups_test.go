@@ -0,0 +1,60 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func getTestRollupsLogInfo() *LogInfo {
+	return &LogInfo{OpsPatterns: []OpPerformanceDoc{
+		{Command: "find", Namespace: "demo.cars", Count: 10, TotalMilli: 1000, MaxMilli: 300, Scan: COLLSCAN},
+		{Command: "find", Namespace: "demo.cars", Count: 30, TotalMilli: 600, MaxMilli: 50},
+		{Command: "update", Namespace: "demo.dealers", Count: 5, TotalMilli: 2000, MaxMilli: 900},
+		{Command: "find", Namespace: "sales.orders", Count: 20, TotalMilli: 400, MaxMilli: 40},
+	}}
+}
+
+func TestGetDatabasesRollup(t *testing.T) {
+	rollups := getTestRollupsLogInfo().GetDatabasesRollup()
+	if len(rollups) != 2 || rollups[0].Name != "demo" || rollups[0].Count != 45 || rollups[0].Patterns != 3 ||
+		rollups[0].TotalMilli != 3600 || rollups[0].MaxMilli != 900 {
+		t.Fatal(rollups)
+	}
+	if pct := rollups[0].GetCollscanPercent(); int(pct) != 22 {
+		t.Fatal(pct)
+	}
+}
+
+func TestGetNamespacesRollup(t *testing.T) {
+	rollups := getTestRollupsLogInfo().GetNamespacesRollup()
+	if len(rollups) != 3 || rollups[0].Name != "demo.dealers" || rollups[1].Name != "demo.cars" || rollups[1].Collscans != 10 {
+		t.Fatal(rollups)
+	}
+}
+
+func TestRollupsSummary(t *testing.T) {
+	li := getTestRollupsLogInfo()
+	str := li.getRollupsSummary()
+	if strings.Index(str, "Database") > strings.Index(str, "Namespace") || strings.Contains(str, "sales.orders") == false {
+		t.Fatal(str)
+	}
+	if str = li.getRollupsMarkdown(); strings.Contains(str, "## Ops by Namespace") == false {
+		t.Fatal(str)
+	}
+	if str = li.getRollupsHTML(); strings.Contains(str, "<h2>Ops by Database</h2>") == false {
+		t.Fatal(str)
+	}
+}
+
+func TestGetNamespacesRollupSameFilter(t *testing.T) {
+	li := NewLogInfo("", "")
+	li.aggregate(opStats{command: "find", namespace: "demo.cars", filter: "{color: 1}", milli: 100})
+	li.aggregate(opStats{command: "find", namespace: "demo.dealers", filter: "{color: 1}", milli: 300})
+	li.sortOpsPatterns()
+	rollups := li.GetNamespacesRollup()
+	if len(rollups) != 2 || rollups[0].Name != "demo.dealers" || rollups[0].TotalMilli != 300 || rollups[1].TotalMilli != 100 {
+		t.Fatal(rollups)
+	}
+}
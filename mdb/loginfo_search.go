@@ -0,0 +1,132 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PatternsSearch filters ops patterns of persisted loginfo results by namespaces, query patterns, and plans
+type PatternsSearch struct {
+	collscan  bool
+	filter    *regexp.Regexp
+	namespace *regexp.Regexp
+	noIndex   bool
+}
+
+// NewPatternsSearch returns a PatternsSearch matching all ops patterns
+func NewPatternsSearch() *PatternsSearch {
+	return &PatternsSearch{}
+}
+
+// SetCollscan sets to match only COLLSCAN ops patterns
+func (ps *PatternsSearch) SetCollscan(collscan bool) {
+	ps.collscan = collscan
+}
+
+// SetFilter sets a regular expression query patterns must match, all if empty
+func (ps *PatternsSearch) SetFilter(expr string) error {
+	var err error
+	ps.filter = nil
+	if expr != "" {
+		ps.filter, err = regexp.Compile(expr)
+	}
+	return err
+}
+
+// SetNamespace sets a regular expression namespaces must match, all if empty
+func (ps *PatternsSearch) SetNamespace(expr string) error {
+	var err error
+	ps.namespace = nil
+	if expr != "" {
+		ps.namespace, err = regexp.Compile(expr)
+	}
+	return err
+}
+
+// SetNoIndex sets to match only ops patterns without an index used
+func (ps *PatternsSearch) SetNoIndex(noIndex bool) {
+	ps.noIndex = noIndex
+}
+
+// Match returns true if an ops pattern matches all conditions set
+func (ps *PatternsSearch) Match(value LogInfoLineAnalytics) bool {
+	if ps.collscan == true && value.IsCollectionScan == false {
+		return false
+	} else if ps.noIndex == true && value.IndexUsed != "" {
+		return false
+	} else if ps.namespace != nil && ps.namespace.MatchString(value.Namespace) == false {
+		return false
+	} else if ps.filter != nil && ps.filter.MatchString(value.QueryPattern) == false {
+		return false
+	}
+	return true
+}
+
+// Search returns ops patterns matching all conditions set, in the order given
+func (ps *PatternsSearch) Search(patterns []LogInfoLineAnalytics) []LogInfoLineAnalytics {
+	matched := []LogInfoLineAnalytics{}
+	for _, value := range patterns {
+		if ps.Match(value) {
+			matched = append(matched, value)
+		}
+	}
+	return matched
+}
+
+// LoadOpsPatterns returns ops patterns of a persisted .enc file, or of a JSON array or newline delimited JSON
+// of --format json or ndjson outputs, logs aren't parsed again
+func LoadOpsPatterns(filename string) ([]LogInfoLineAnalytics, error) {
+	var err error
+	var data []byte
+	if strings.HasSuffix(filename, ".enc") {
+		li := NewLogInfo(filename, "")
+		li.SetSilent(true)
+		if _, err = li.Analyze(); err != nil {
+			return nil, err
+		}
+		return li.GetOpsPatterns(), err
+	}
+	if data, err = readArtifact(filename, nil); err != nil {
+		return nil, err
+	}
+	patterns := []LogInfoLineAnalytics{}
+	if data = bytes.TrimSpace(data); bytes.HasPrefix(data, []byte("[")) {
+		if err = json.Unmarshal(data, &patterns); err != nil {
+			return nil, fmt.Errorf("%v: %v", filename, err)
+		}
+		return patterns, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var value LogInfoLineAnalytics
+		if err = json.Unmarshal(scanner.Bytes(), &value); err != nil {
+			return nil, fmt.Errorf("%v: %v", filename, err)
+		}
+		patterns = append(patterns, value)
+	}
+	return patterns, scanner.Err()
+}
+
+// GetPatternsSearchSummary returns a table of ops patterns matched and numbers of patterns matched and loaded
+func GetPatternsSearchSummary(matched []LogInfoLineAnalytics, total int) string {
+	var buffer bytes.Buffer
+	formatter := &ScreenOutputFormatter{}
+	formatter.WriteHeader(&buffer)
+	for i := range matched {
+		value := matched[i] // WriteLine truncates fields
+		formatter.WriteLine(&buffer, &value)
+	}
+	formatter.WriteFooter(&buffer)
+	buffer.WriteString(fmt.Sprintf("%d of %d patterns matched\n", len(matched), total))
+	return buffer.String()
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func getTestSearchPatterns() []LogInfoLineAnalytics {
+	return getTestRollupsLogInfo().GetOpsPatterns()
+}
+
+func TestPatternsSearch(t *testing.T) {
+	patterns := getTestSearchPatterns()
+	patterns[1].IndexUsed = "{ color: 1 }"
+	ps := NewPatternsSearch()
+	if matched := ps.Search(patterns); len(matched) != len(patterns) {
+		t.Fatal(matched)
+	}
+	if err := ps.SetNamespace(`^demo\.`); err != nil {
+		t.Fatal(err)
+	}
+	if matched := ps.Search(patterns); len(matched) != 3 {
+		t.Fatal(matched)
+	}
+	ps.SetNoIndex(true)
+	if matched := ps.Search(patterns); len(matched) != 2 || matched[1].Namespace != "demo.dealers" {
+		t.Fatal(matched)
+	}
+	ps.SetCollscan(true)
+	if matched := ps.Search(patterns); len(matched) != 1 || matched[0].IsCollectionScan == false {
+		t.Fatal(matched)
+	}
+	if err := ps.SetFilter("(unknown"); err == nil {
+		t.Fatal("expected an error of an invalid regular expression")
+	}
+	if err := ps.SetNamespace(""); err != nil {
+		t.Fatal(err)
+	}
+	ps.SetCollscan(false)
+	ps.SetNoIndex(false)
+	if matched := ps.Search(patterns); len(matched) != len(patterns) {
+		t.Fatal(matched)
+	}
+	str := GetPatternsSearchSummary(ps.Search(patterns), len(patterns))
+	if strings.Contains(str, "4 of 4 patterns matched") == false {
+		t.Fatal(str)
+	}
+}
+
+func TestLoadOpsPatterns(t *testing.T) {
+	var err error
+	var loaded []LogInfoLineAnalytics
+	dir, _ := ioutil.TempDir("", "keyhole")
+	defer os.RemoveAll(dir)
+	patterns := getTestSearchPatterns()
+	data, _ := json.Marshal(patterns)
+	filename := filepath.Join(dir, "patterns.json")
+	ioutil.WriteFile(filename, data, 0644)
+	if loaded, err = LoadOpsPatterns(filename); err != nil || len(loaded) != len(patterns) {
+		t.Fatal(err, loaded)
+	}
+	lines := []string{}
+	for _, value := range patterns {
+		data, _ = json.Marshal(value)
+		lines = append(lines, string(data))
+	}
+	filename = filepath.Join(dir, "patterns.ndjson")
+	ioutil.WriteFile(filename, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+	if loaded, err = LoadOpsPatterns(filename); err != nil || len(loaded) != len(patterns) || loaded[3].Namespace != "sales.orders" {
+		t.Fatal(err, loaded)
+	}
+
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	if _, err = li.Analyze(); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(li.OutputFilename)
+	if loaded, err = LoadOpsPatterns(li.OutputFilename); err != nil || len(loaded) != len(li.OpsPatterns) {
+		t.Fatal(err, len(loaded))
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ShardKeyCandidate is a field used by the majority of ops of a namespace
+type ShardKeyCandidate struct {
+	Equality  int    // number of ops of equality matches
+	Field     string // field name
+	Key       string // suggested shard key, hashed if never used in ranges
+	Namespace string
+	Ops       int // number of ops filtered by the field
+	Range     int // number of ops of range matches
+	TotalOps  int // number of ops of the namespace
+	Writes    int // number of writes filtered by the field
+}
+
+// writeCommands are commands targeted by shard keys when written
+var writeCommands = []string{"delete", "findAndModify", "remove", "update"}
+
+// setShardKeyCandidates ranks fields used by the majority of ops patterns of each namespace
+func (li *LogInfo) setShardKeyCandidates() {
+	type fieldStats struct {
+		equality, ops, rng, writes int
+	}
+	totals := map[string]int{}
+	fieldsMap := map[string]map[string]*fieldStats{}
+	for _, doc := range li.OpsPatterns {
+		if doc.Namespace == "" || strings.HasSuffix(doc.Namespace, ".$cmd") {
+			continue
+		}
+		totals[doc.Namespace] += doc.Count
+		filter, _, err := parseQueryPattern(doc.Filter)
+		if err != nil {
+			continue
+		}
+		if fieldsMap[doc.Namespace] == nil {
+			fieldsMap[doc.Namespace] = map[string]*fieldStats{}
+		}
+		var sortDoc bson.D
+		if doc.Sort != "" {
+			sortDoc, _ = parseShellDoc(doc.Sort)
+		}
+		for field, isRange := range getFilterFields(filter) {
+			if isRange == false && isIndexField(sortDoc, field) == true {
+				continue // sort fields don't target shards, and were merged as equalities into patterns persisted earlier
+			}
+			stats, ok := fieldsMap[doc.Namespace][field]
+			if ok == false {
+				stats = &fieldStats{}
+				fieldsMap[doc.Namespace][field] = stats
+			}
+			stats.ops += doc.Count
+			if isRange == true {
+				stats.rng += doc.Count
+			} else {
+				stats.equality += doc.Count
+			}
+			if contains(writeCommands, doc.Command) {
+				stats.writes += doc.Count
+			}
+		}
+	}
+	li.ShardKeys = []ShardKeyCandidate{}
+	for ns, fields := range fieldsMap {
+		for field, stats := range fields {
+			if 2*stats.ops <= totals[ns] { // not in the majority of ops
+				continue
+			}
+			key := "{" + field + `: "hashed"}`
+			if stats.rng > 0 {
+				key = "{" + field + ": 1}"
+			}
+			li.ShardKeys = append(li.ShardKeys, ShardKeyCandidate{Equality: stats.equality, Field: field, Key: key, Namespace: ns,
+				Ops: stats.ops, Range: stats.rng, TotalOps: totals[ns], Writes: stats.writes})
+		}
+	}
+	sort.Slice(li.ShardKeys, func(i, j int) bool {
+		x, y := li.ShardKeys[i], li.ShardKeys[j]
+		if x.Namespace != y.Namespace {
+			return x.Namespace < y.Namespace
+		} else if x.Ops != y.Ops {
+			return x.Ops > y.Ops
+		} else if x.Equality != y.Equality { // equality matches target a shard
+			return x.Equality > y.Equality
+		}
+		return x.Field < y.Field
+	})
+}
+
+// getFilterFields returns fields of a filter and whether each is matched by a range,
+// a field of $or counts only if it is in all clauses
+func getFilterFields(filter bson.D) map[string]bool {
+	fields := map[string]bool{}
+	for _, elem := range filter {
+		switch elem.Key {
+		case "$and":
+			for _, clause := range toDocs(elem.Value) {
+				for k, v := range getFilterFields(clause) {
+					fields[k] = fields[k] || v
+				}
+			}
+		case "$or":
+			clauses := toDocs(elem.Value)
+			counts := map[string]int{}
+			ranges := map[string]bool{}
+			for _, clause := range clauses {
+				for k, v := range getFilterFields(clause) {
+					counts[k]++
+					ranges[k] = ranges[k] || v
+				}
+			}
+			for k, n := range counts {
+				if n == len(clauses) {
+					fields[k] = fields[k] || ranges[k]
+				}
+			}
+		default:
+			if strings.HasPrefix(elem.Key, "$") == false {
+				fields[elem.Key] = isRangeMatch(elem.Value)
+			}
+		}
+	}
+	return fields
+}
+
+// isRangeMatch returns true if a value of a filter isn't an equality match
+func isRangeMatch(value interface{}) bool {
+	switch v := value.(type) {
+	case bson.D:
+		for _, elem := range v {
+			if elem.Key != "$eq" && elem.Key != "$in" {
+				return true
+			}
+		}
+	case primitive.Regex:
+		return true
+	}
+	return false
+}
+
+func toDocs(value interface{}) []bson.D {
+	docs := []bson.D{}
+	if arr, ok := value.(primitive.A); ok {
+		for _, elem := range arr {
+			if doc, ok := elem.(bson.D); ok {
+				docs = append(docs, doc)
+			}
+		}
+	}
+	return docs
+}
+
+// getShardKeysSummaries returns ranked shard key candidates of namespaces
+func (li *LogInfo) getShardKeysSummaries() []string {
+	if len(li.ShardKeys) == 0 {
+		return []string{}
+	}
+	summaries := []string{"Shard key candidates:"}
+	summaries = append(summaries, fmt.Sprintf("%-33s %-20s %6s %8s %8s %8s  %s", "namespace", "field", "ops %", "equality", "range",
+		"writes", "shard key"))
+	for _, doc := range li.ShardKeys {
+		summaries = append(summaries, fmt.Sprintf("%-33s %-20s %6.1f %8d %8d %8d  %s", doc.Namespace, doc.Field,
+			100*float64(doc.Ops)/float64(doc.TotalOps), doc.Equality, doc.Range, doc.Writes, doc.Key))
+	}
+	return append(summaries, "\n")
+}
@@ -0,0 +1,35 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLogInfoShardKeyCandidates(t *testing.T) {
+	li := &LogInfo{OpsPatterns: []OpPerformanceDoc{
+		{Command: "find", Count: 50, Filter: "{color: 1, vin: 1}", Namespace: "keyhole.cars"},
+		{Command: "find", Count: 20, Filter: "{vin: {$in: [... ]}, year: {$gt: 1}}", Namespace: "keyhole.cars"},
+		{Command: "update", Count: 20, Filter: "{$or: [{vin: 1}, {vin: 1, year: 1}]}", Namespace: "keyhole.cars"},
+		{Command: "find", Count: 10, Filter: "{year: {$gte: 1}}, sort: {year: -1}", Namespace: "keyhole.cars"},
+		{Command: "find", Count: 5, Filter: "{name: /regex/}", Namespace: "keyhole.dealers"},
+		{Command: "find", Count: 10, Filter: "{name: /regex/, state: 1}", Namespace: "keyhole.dealers", Sort: "{state: 1}"},
+	}}
+	li.setShardKeyCandidates()
+	if len(li.ShardKeys) != 2 { // color of 50% of ops and year aren't in the majority, state is a sort field
+		t.Fatal("expected 2 candidates, but got", li.ShardKeys)
+	}
+	vin := li.ShardKeys[0]
+	if vin.Field != "vin" || vin.Ops != 90 || vin.TotalOps != 100 || vin.Equality != 90 || vin.Range != 0 || vin.Writes != 20 ||
+		vin.Key != `{vin: "hashed"}` {
+		t.Fatal(vin)
+	}
+	name := li.ShardKeys[1]
+	if name.Namespace != "keyhole.dealers" || name.Range != 15 || name.Key != "{name: 1}" {
+		t.Fatal(name)
+	}
+	if strings.Contains(strings.Join(li.getShardKeysSummaries(), "\n"), "Shard key candidates:") == false {
+		t.Fatal(li.getShardKeysSummaries())
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SetNumShards sets number of shards, otherwise the max nShards from mongos logs is used
+func (li *LogInfo) SetNumShards(numShards int) {
+	li.numShards = numShards
+}
+
+// setNumShards sets number of shards to decide scatter-gather ops
+func (li *LogInfo) setNumShards() {
+	li.NumShards = li.numShards
+	if li.NumShards > 0 {
+		return
+	}
+	for _, doc := range li.opsMap {
+		for n := range doc.NShards {
+			if n > li.NumShards {
+				li.NumShards = n
+			}
+		}
+	}
+}
+
+// isFromRouter returns true if an op on a shard was routed from mongos
+func isFromRouter(str string) bool {
+	return strings.Contains(str, "fromRouter") || strings.Contains(str, "shardVersion:")
+}
+
+// getShardsStats returns max and average number of shards targeted
+func getShardsStats(nShards map[int]int) (int, float64) {
+	max, total, count := 0, 0, 0
+	for n, c := range nShards {
+		if n > max {
+			max = n
+		}
+		total += n * c
+		count += c
+	}
+	if count == 0 {
+		return 0, 0
+	}
+	return max, float64(total) / float64(count)
+}
+
+// getShardsSummaries returns ops counts by number of shards targeted and scatter-gather patterns
+func (li *LogInfo) getShardsSummaries() []string {
+	counts := map[int]int{}
+	patterns := []OpPerformanceDoc{}
+	for _, doc := range li.OpsPatterns {
+		for n, c := range doc.NShards {
+			counts[n] += c
+		}
+		if doc.ScatterGather > 0 {
+			patterns = append(patterns, doc)
+		}
+	}
+	if len(counts) == 0 {
+		return []string{}
+	}
+	keys := []int{}
+	for n := range counts {
+		keys = append(keys, n)
+	}
+	sort.Ints(keys)
+	summaries := []string{fmt.Sprintf("Ops by shards targeted (%d shards):", li.NumShards)}
+	for _, n := range keys {
+		label := "targeted"
+		if n == li.NumShards && n > 1 {
+			label = "scatter-gather"
+		} else if n > 1 {
+			label = "multi-shard"
+		}
+		summaries = append(summaries, fmt.Sprintf("%3d shard(s) %-15s %8d", n, label, counts[n]))
+	}
+	if len(patterns) > 0 {
+		summaries = append(summaries, "Patterns targeted all shards:")
+		for _, doc := range patterns {
+			summaries = append(summaries, fmt.Sprintf("%8d of %-8d %s %s %s", doc.ScatterGather, doc.Count, doc.Command, doc.Namespace, doc.Filter))
+		}
+	}
+	return append(summaries, "\n")
+}
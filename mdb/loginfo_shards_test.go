@@ -0,0 +1,39 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLogInfoShards(t *testing.T) {
+	li := NewLogInfo("testdata/mongos.log", "")
+	li.SetSilent(true)
+	li.SetVerbose(true)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if li.NumShards != 3 {
+		t.Fatal("expected 3 shards, but got", li.NumShards)
+	}
+	for _, doc := range li.OpsPatterns {
+		stats := ConverOpPerformanceDocumentToLogInfoLineAnalytics(&doc)
+		if doc.Filter == "{vin: 1}" && (stats.MaxShards != 1 || stats.ScatterGather != 0) {
+			t.Fatal("expected targeted ops", doc.Filter, stats.MaxShards)
+		} else if doc.Filter == "{color: 1}" && (stats.MaxShards != 3 || stats.AvgShards != 2.5 || stats.ScatterGather != 1) {
+			t.Fatal("expected scatter-gather ops", doc.Filter, stats.MaxShards, stats.AvgShards, stats.ScatterGather)
+		}
+	}
+	if summary := strings.Join(li.getShardsSummaries(), "\n"); strings.Index(summary, "scatter-gather") < 0 {
+		t.Fatal(summary)
+	}
+
+	li.SetNumShards(4)
+	li.sortOpsPatterns()
+	for _, doc := range li.OpsPatterns {
+		if doc.ScatterGather > 0 {
+			t.Fatal("expected no scatter-gather ops with 4 shards", doc.Filter)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"fmt"
+	"sort"
+)
+
+// orders of ops patterns
+const (
+	SortByAvg       = "avg"       // average milliseconds, the default
+	SortByCollscan  = "collscan"  // COLLSCAN patterns first, then by average milliseconds
+	SortByCount     = "count"     // number of ops
+	SortByMax       = "max"       // max milliseconds
+	SortByNamespace = "namespace" // namespaces in ascending order, then by average milliseconds
+	SortByTotal     = "total"     // total milliseconds
+)
+
+// SetSortBy sets order of ops patterns of all formatters, avg, total, count, max, namespace, or collscan,
+// avg if empty
+func (li *LogInfo) SetSortBy(sortBy string) {
+	li.sortBy = sortBy
+}
+
+// SetLimit sets number of the top ops patterns by the order set written by the screen, html, and markdown
+// formatters, followed by a line of number of patterns omitted, 0 for all.  Data formats, e.g. json and csv,
+// keep all patterns.
+func (li *LogInfo) SetLimit(limit int) {
+	li.limit = limit
+}
+
+// getLimitedOpsPatterns returns the top ops patterns of the limit and number of patterns omitted
+func (li *LogInfo) getLimitedOpsPatterns() ([]OpPerformanceDoc, int) {
+	if li.limit <= 0 || len(li.OpsPatterns) <= li.limit {
+		return li.OpsPatterns, 0
+	}
+	return li.OpsPatterns[:li.limit], len(li.OpsPatterns) - li.limit
+}
+
+// validateOpsSortBy returns an error of an unsupported order of ops patterns
+func validateOpsSortBy(sortBy string) error {
+	if sortBy != "" && contains([]string{SortByAvg, SortByCollscan, SortByCount, SortByMax, SortByNamespace, SortByTotal}, sortBy) == false {
+		return fmt.Errorf("invalid sort key %v of ops patterns, avg, total, count, max, namespace, or collscan", sortBy)
+	}
+	return nil
+}
+
+// sortOpsPatternsBy sorts ops patterns by an order, ties are sorted by average milliseconds
+func sortOpsPatternsBy(patterns []OpPerformanceDoc, sortBy string) {
+	sort.Slice(patterns, func(i, j int) bool {
+		a, b := patterns[i], patterns[j]
+		switch sortBy {
+		case SortByCollscan:
+			if (a.Scan == COLLSCAN) != (b.Scan == COLLSCAN) {
+				return a.Scan == COLLSCAN
+			}
+		case SortByCount:
+			if a.Count != b.Count {
+				return a.Count > b.Count
+			}
+		case SortByMax:
+			if a.MaxMilli != b.MaxMilli {
+				return a.MaxMilli > b.MaxMilli
+			}
+		case SortByNamespace:
+			if a.Namespace != b.Namespace {
+				return a.Namespace < b.Namespace
+			}
+		case SortByTotal:
+			if a.TotalMilli != b.TotalMilli {
+				return a.TotalMilli > b.TotalMilli
+			}
+		}
+		return isSlowerOpsPattern(a, b)
+	})
+}
+
+// isSlowerOpsPattern returns true if a is slower than b by average milliseconds, ties are ordered by counts
+// and then patterns to keep the order stable among runs
+func isSlowerOpsPattern(a OpPerformanceDoc, b OpPerformanceDoc) bool {
+	x := float64(a.TotalMilli) / float64(a.Count)
+	y := float64(b.TotalMilli) / float64(b.Count)
+	if x == y {
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Command+a.Filter < b.Command+b.Filter
+	}
+	return x > y
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSortOpsPatternsBy(t *testing.T) {
+	for sortBy, expected := range map[string]string{
+		"":              "demo.dealers",
+		SortByAvg:       "demo.dealers",
+		SortByCollscan:  "demo.cars",
+		SortByCount:     "demo.cars",
+		SortByMax:       "demo.dealers",
+		SortByNamespace: "demo.cars",
+		SortByTotal:     "demo.dealers",
+	} {
+		patterns := getTestRollupsLogInfo().OpsPatterns
+		sortOpsPatternsBy(patterns, sortBy)
+		if patterns[0].Namespace != expected {
+			t.Fatal(sortBy, patterns[0])
+		}
+		if sortBy == SortByCollscan && patterns[0].Scan != COLLSCAN {
+			t.Fatal(sortBy, patterns[0])
+		}
+		if sortBy == SortByCount && patterns[0].Count != 30 {
+			t.Fatal(sortBy, patterns[0])
+		}
+	}
+	if err := validateOpsSortBy("latency"); err == nil {
+		t.Fatal("expected an error of an invalid sort key")
+	}
+}
+
+func TestLogInfoSortBy(t *testing.T) {
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	li.SetSortBy(SortByCount)
+	if _, err := li.Analyze(); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(li.OutputFilename)
+	for i := 1; i < len(li.OpsPatterns); i++ {
+		if li.OpsPatterns[i-1].Count < li.OpsPatterns[i].Count {
+			t.Fatal("expected ops patterns sorted by count")
+		}
+	}
+	enc := NewLogInfo(li.OutputFilename, "")
+	enc.SetSilent(true)
+	enc.SetSortBy(SortByTotal)
+	if _, err := enc.Analyze(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(enc.OpsPatterns); i++ {
+		if enc.OpsPatterns[i-1].TotalMilli < enc.OpsPatterns[i].TotalMilli {
+			t.Fatal("expected ops patterns of .enc sorted by total time")
+		}
+	}
+}
+
+func TestSetLimit(t *testing.T) {
+	li := getTestRollupsLogInfo()
+	li.SetLimit(3)
+	for _, formatter := range []OutputFormatterBase{&ScreenOutputFormatter{}, &HTMLOutputFormatter{}, &MarkdownOutputFormatter{}} {
+		if str := formatter.GetOutput(li); strings.Contains(str, "and 1 more patterns") == false {
+			t.Fatal(str)
+		}
+	}
+	formatter := &JSONOutputFormatter{NDJSON: true}
+	if str := formatter.GetOutput(li); strings.Count(str, "\n") != 4 {
+		t.Fatal(str)
+	}
+	li.SetLimit(4)
+	if patterns, more := li.getLimitedOpsPatterns(); len(patterns) != 4 || more != 0 {
+		t.Fatal(len(patterns), more)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"encoding/gob"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// spilledPattern is an ops pattern written to the temporary store
+type spilledPattern struct {
+	Key string
+	Doc OpPerformanceDoc
+}
+
+// SetMaxPatterns sets max number of ops patterns kept in memory while parsing, rare patterns
+// are spilled to a temporary file and merged back when sorted, 0 for no limit. Only ops
+// patterns are capped, apps, hosts, variants, transactions, and time series still grow with
+// the log.
+func (li *LogInfo) SetMaxPatterns(maxPatterns int) {
+	li.maxPatterns = maxPatterns
+}
+
+// spillPatterns writes the rarest half of ops patterns to a temporary file
+func (li *LogInfo) spillPatterns() error {
+	var err error
+	if li.spillFile == nil {
+		if li.spillFile, err = ioutil.TempFile("", "keyhole-patterns-"); err != nil {
+			return err
+		}
+		li.spillEncoder = gob.NewEncoder(li.spillFile)
+	}
+	keys := make([]string, 0, len(li.opsMap))
+	for key := range li.opsMap {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if li.opsMap[keys[i]].Count == li.opsMap[keys[j]].Count {
+			return keys[i] < keys[j]
+		}
+		return li.opsMap[keys[i]].Count < li.opsMap[keys[j]].Count
+	})
+	for _, key := range keys[:len(keys)-li.maxPatterns/2] {
+		if err = li.spillEncoder.Encode(spilledPattern{Key: key, Doc: li.opsMap[key]}); err != nil {
+			return err
+		}
+		delete(li.opsMap, key)
+	}
+	return nil
+}
+
+// mergeSpilledPatterns reads spilled ops patterns back to memory and removes the temporary file
+func (li *LogInfo) mergeSpilledPatterns() error {
+	var err error
+	if li.spillFile == nil {
+		return nil
+	}
+	defer li.removeSpilledPatterns()
+	if _, err = li.spillFile.Seek(0, 0); err != nil {
+		return err
+	}
+	decoder := gob.NewDecoder(li.spillFile)
+	for {
+		var spilled spilledPattern
+		if err = decoder.Decode(&spilled); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if doc, ok := li.opsMap[spilled.Key]; ok == true {
+			examples := mergeExamples(doc.Examples, spilled.Doc.Examples, li.examples)
+			doc = mergeOpPerformanceDocs(doc, spilled.Doc)
+			doc.Examples = examples
+			li.opsMap[spilled.Key] = doc
+		} else {
+			li.opsMap[spilled.Key] = spilled.Doc
+		}
+	}
+}
+
+// removeSpilledPatterns closes and removes the temporary file of spilled ops patterns, if any
+func (li *LogInfo) removeSpilledPatterns() {
+	if li.spillFile == nil {
+		return
+	}
+	li.spillFile.Close()
+	os.Remove(li.spillFile.Name())
+	li.spillFile = nil
+	li.spillEncoder = nil
+}
+
+// mergeOpPerformanceDocs returns stats of an ops pattern from two parts
+func mergeOpPerformanceDocs(doc OpPerformanceDoc, other OpPerformanceDoc) OpPerformanceDoc {
+	if doc.Apps == nil {
+		doc.Apps = map[string]int{}
+	}
+	for app, count := range other.Apps {
+		doc.Apps[app] += count
+	}
+	if len(other.Hosts) > 0 && doc.Hosts == nil {
+		doc.Hosts = map[string]int{}
+	}
+	for host, count := range other.Hosts {
+		doc.Hosts[host] += count
+	}
+	if len(other.NShards) > 0 && doc.NShards == nil {
+		doc.NShards = map[int]int{}
+	}
+	for n, count := range other.NShards {
+		doc.NShards[n] += count
+	}
+	doc.Broadcasts += other.Broadcasts
+	doc.Count += other.Count
+	doc.DocsExamined += other.DocsExamined
+	doc.FromMultiPlanner += other.FromMultiPlanner
+	doc.FromRouter += other.FromRouter
+	doc.Histogram.Merge(other.Histogram)
+	doc.InMemorySorts += other.InMemorySorts
+	doc.KeysExamined += other.KeysExamined
+	doc.LockWaitMicros += other.LockWaitMicros
+	doc.LockWaits += other.LockWaits
+	if other.MaxMilli > doc.MaxMilli {
+		doc.MaxMilli = other.MaxMilli
+	}
+	doc.NReturned += other.NReturned
+	doc.Replanned += other.Replanned
+	doc.ResLen += other.ResLen
+	doc.SpilledSorts += other.SpilledSorts
+	if doc.Sort == "" {
+		doc.Sort = other.Sort
+	}
+	if doc.QueryHash == "" {
+		doc.QueryHash, doc.PlanCacheKey = other.QueryHash, other.PlanCacheKey
+	}
+	doc.TotalMilli += other.TotalMilli
+	doc.WriteConflicts += other.WriteConflicts
+	return doc
+}
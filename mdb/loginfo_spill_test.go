@@ -0,0 +1,63 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLogInfoMaxPatterns(t *testing.T) {
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	spilled := NewLogInfo("testdata/mongod.log", "")
+	spilled.SetSilent(true)
+	spilled.SetMaxPatterns(2)
+	if err := spilled.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(spilled.mongoInfo, "db version v4.0.12") == false || strings.Contains(spilled.mongoInfo, "config options:") == false {
+		t.Fatal(spilled.mongoInfo)
+	}
+	if spilled.spillFile != nil {
+		t.Fatal("expected the temporary file removed")
+	}
+	if len(spilled.OpsPatterns) != len(li.OpsPatterns) {
+		t.Fatal("expected", len(li.OpsPatterns), "patterns, but got", len(spilled.OpsPatterns))
+	}
+	for i, doc := range li.OpsPatterns {
+		other := spilled.OpsPatterns[i]
+		if other.Filter != doc.Filter || other.Count != doc.Count || other.TotalMilli != doc.TotalMilli ||
+			other.Histogram.Count != doc.Histogram.Count || other.Apps[noAppName] != doc.Apps[noAppName] {
+			t.Fatal("expected", doc.Filter, doc.Count, "but got", other.Filter, other.Count)
+		}
+	}
+}
+
+func TestLogInfoMaxPatternsError(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "keyhole-spill-")
+	defer os.RemoveAll(dir)
+	tmpdir := os.Getenv("TMPDIR")
+	os.Setenv("TMPDIR", dir)
+	defer os.Setenv("TMPDIR", tmpdir)
+	li, err := NewLogInfoFromFiles([]string{"testdata/mongod.log", "testdata/no-such-file.log"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	li.SetSilent(true)
+	li.SetMaxPatterns(2)
+	if err = li.Parse(); err == nil {
+		t.Fatal("expected an error of a missing file")
+	}
+	if li.spillFile != nil {
+		t.Fatal("expected the temporary file closed")
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Fatal("expected the temporary file removed, but got", files[0].Name())
+	}
+}
@@ -3,8 +3,13 @@
 package mdb
 
 import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestRemoveInElements(t *testing.T) {
@@ -16,10 +21,555 @@ func TestRemoveInElements(t *testing.T) {
 }
 
 func TestLogInfo(t *testing.T) {
-	loginfo := NewLogInfo("testdata/mongod.log")
+	loginfo := NewLogInfo("testdata/mongod.log", "")
 	loginfo.SetSilent(true)
 	if _, err := loginfo.Analyze(); err != nil {
 		t.Fatal(err)
 	}
 	os.Remove(loginfo.OutputFilename)
 }
+
+func TestLogInfoParse(t *testing.T) {
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if len(li.OpsPatterns) == 0 {
+		t.Fatal("no ops patterns found")
+	}
+	for _, doc := range li.OpsPatterns {
+		if doc.Histogram.Count != doc.Count {
+			t.Fatal("expected histogram count", doc.Count, "but got", doc.Histogram.Count)
+		}
+		if doc.Histogram.Max != doc.MaxMilli {
+			t.Fatal("expected histogram max", doc.MaxMilli, "but got", doc.Histogram.Max)
+		}
+	}
+}
+
+func TestGetLogMetric(t *testing.T) {
+	str := "planSummary: IXSCAN { color: 1 } keysExamined:1200 docsExamined:1100 nreturned:12 reslen:4321 120ms"
+	if getLogMetric(str, "keysExamined") != 1200 || getLogMetric(str, "docsExamined") != 1100 {
+		t.Fatal(getLogMetric(str, "keysExamined"), getLogMetric(str, "docsExamined"))
+	}
+	if getReturnedCount(str) != 12 || getLogMetric(str, "reslen") != 4321 {
+		t.Fatal(getReturnedCount(str), getLogMetric(str, "reslen"))
+	}
+	if getLogMetric(str, "writeConflicts") != 0 {
+		t.Fatal(getLogMetric(str, "writeConflicts"))
+	}
+	if ratio := getScannedRatio(1200, 1100, 12); ratio != 100 {
+		t.Fatal(ratio)
+	}
+}
+
+func TestLogInfoExamined(t *testing.T) {
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, doc := range li.OpsPatterns {
+		stats := ConverOpPerformanceDocumentToLogInfoLineAnalytics(&doc)
+		if doc.Scan == COLLSCAN && stats.IsInefficient == true {
+			t.Fatal("COLLSCAN should not be flagged as inefficient", doc.Filter)
+		}
+		if doc.Command == "find" && doc.Namespace == "keyhole.cars" && doc.Scan == "" {
+			found = true
+			if doc.KeysExamined != 5000 || doc.NReturned != 21 || stats.IsInefficient == false {
+				t.Fatal(doc.KeysExamined, doc.NReturned, stats.ScannedRatio)
+			}
+		}
+	}
+	if found == false {
+		t.Fatal("indexed find pattern not found")
+	}
+}
+
+func TestLogInfoFollow(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/mongod.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := os.TempDir() + "/keyhole_follow.log"
+	defer os.Remove(filename)
+	if err = ioutil.WriteFile(filename, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	li := NewLogInfo(filename, "")
+	channel := make(chan string)
+	quit := make(chan bool)
+	defer close(quit)
+	go li.Follow(channel, 100*time.Millisecond, quit)
+	if summary := <-channel; strings.Index(summary, "keyhole.cars") < 0 {
+		t.Fatal("expected keyhole.cars in summary")
+	}
+
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`2019-09-28T10:03:00.000-0400 I COMMAND  [conn12] command keyhole.owners appName: "MongoDB Shell" command: find { find: "owners", filter: { name: "Ken" }, $db: "keyhole" } planSummary: COLLSCAN keysExamined:0 docsExamined:5000 cursorExhausted:1 numYields:40 nreturned:1 reslen:321 protocol:op_msg 700ms` + "\n")
+	file.Close()
+	for i := 0; i < 20; i++ {
+		if summary := <-channel; strings.Index(summary, "keyhole.owners") > 0 {
+			return
+		}
+	}
+	t.Fatal("expected keyhole.owners in summary")
+}
+
+func TestLogInfoFromFiles(t *testing.T) {
+	single := NewLogInfo("testdata/mongod.log", "")
+	single.SetSilent(true)
+	if err := single.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	li, err := NewLogInfoFromFiles([]string{"testdata/mongod.log", "testdata/mongod*.log"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	li.SetSilent(true)
+	if err = li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if len(li.OpsPatterns) != len(single.OpsPatterns) {
+		t.Fatal("expected", len(single.OpsPatterns), "patterns but got", len(li.OpsPatterns))
+	}
+	for i, doc := range li.OpsPatterns {
+		if doc.Count != 2*single.OpsPatterns[i].Count {
+			t.Fatal("expected merged counts", doc.Filter, doc.Count)
+		}
+	}
+	for _, op := range li.SlowOps {
+		if op.Source != "testdata/mongod.log" {
+			t.Fatal("expected source file, but got", op.Source)
+		}
+	}
+	if _, err = NewLogInfoFromFiles([]string{}, ""); err == nil {
+		t.Fatal("expected an error without log files")
+	}
+}
+
+func TestJSONOutputFormatter(t *testing.T) {
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	li.SetVerbose(true)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	var docs []LogInfoLineAnalytics
+	if err := json.Unmarshal([]byte(li.printLogsSummary()), &docs); err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != len(li.OpsPatterns) {
+		t.Fatal("expected", len(li.OpsPatterns), "but got", len(docs))
+	}
+
+	li.SetFormatter(&JSONOutputFormatter{NDJSON: true})
+	lines := strings.Split(strings.TrimSpace(li.printLogsSummary()), "\n")
+	if len(lines) != len(li.OpsPatterns) {
+		t.Fatal("expected", len(li.OpsPatterns), "lines but got", len(lines))
+	}
+	for _, line := range lines {
+		var doc LogInfoLineAnalytics
+		if err := json.Unmarshal([]byte(line), &doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	li = &LogInfo{}
+	li.SetFormatter(&JSONOutputFormatter{})
+	if err := json.Unmarshal([]byte(li.printLogsSummary()), &docs); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLogInfoApps(t *testing.T) {
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	apps := map[string]AppStatsDoc{}
+	for _, app := range li.Apps {
+		apps[app.AppName] = app
+	}
+	if app, ok := apps["inventory"]; ok == false || app.Collscans != 3 || app.Count != 3 || app.Connections != 2 {
+		t.Fatal("expected COLLSCANs from inventory", li.Apps)
+	}
+	if app, ok := apps["MongoDB Shell"]; ok == false || app.Count != 3 || app.Connections != 1 || app.Patterns != 1 {
+		t.Fatal("expected 3 ops from MongoDB Shell", li.Apps)
+	}
+	for _, doc := range li.OpsPatterns {
+		if doc.Scan == COLLSCAN && doc.Command == "find" && doc.Apps["inventory"] != 1 {
+			t.Fatal("expected the COLLSCAN attributed to inventory", doc.Apps)
+		}
+	}
+	if getConnID(`2019-09-28T10:00:01.100-0400 I COMMAND  [conn10] command keyhole.cars`) != "conn10" {
+		t.Fatal("expected conn10")
+	}
+}
+
+func TestLogInfoReplanned(t *testing.T) {
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	for _, doc := range li.OpsPatterns {
+		if doc.Namespace == "keyhole.dealers" && doc.Command == "find" {
+			stats := ConverOpPerformanceDocumentToLogInfoLineAnalytics(&doc)
+			if stats.Count != 2 || stats.Replanned != 1 || stats.FromMultiPlanner != 2 {
+				t.Fatal(stats.Count, stats.Replanned, stats.FromMultiPlanner)
+			}
+			return
+		}
+	}
+	t.Fatal("expected a find pattern on keyhole.dealers")
+}
+
+func TestLogInfoWriteConflicts(t *testing.T) {
+	str := `locks:{ Global: { acquireCount: { r: 391, w: 391 }, timeAcquiringMicros: { r: 10, w: 5123 } }, Collection: { acquireCount: { w: 391 }, timeAcquiringMicros: { w: 7 } } } 300ms`
+	if micros := getLockWaitMicros(str); micros != 5140 {
+		t.Fatal("expected 5140, but got", micros)
+	}
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	for _, doc := range li.OpsPatterns {
+		if doc.Command == "update" {
+			if doc.WriteConflicts != 2 || doc.LockWaits != 1 || doc.LockWaitMicros != 5123 {
+				t.Fatal(doc.WriteConflicts, doc.LockWaits, doc.LockWaitMicros)
+			}
+		} else if doc.WriteConflicts != 0 {
+			t.Fatal("unexpected writeConflicts", doc.Filter)
+		}
+	}
+}
+
+func TestLogInfoTimeSeries(t *testing.T) {
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	li.SetSpan(60)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if len(li.TimeSeries) == 0 {
+		t.Fatal("expected time series")
+	}
+	first := li.TimeSeries[0]
+	if first.Time.Minute() != 0 || first.Command != "find" || first.Count != 3 || first.MaxMilli != 1500 {
+		t.Fatal(first)
+	}
+	total := 0
+	for i, bucket := range li.TimeSeries {
+		total += bucket.Count
+		if i > 0 && bucket.Time.Before(li.TimeSeries[i-1].Time) {
+			t.Fatal("expected sorted by time")
+		}
+	}
+	count := 0
+	for _, doc := range li.OpsPatterns {
+		count += doc.Count
+	}
+	if total != count {
+		t.Fatal("expected", count, "ops, but got", total)
+	}
+}
+
+func TestLogInfoTopSlowOps(t *testing.T) {
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	li.SetTopSlowOps(3)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if len(li.SlowOps) != 3 {
+		t.Fatal("expected 3 slow ops, but got", len(li.SlowOps))
+	}
+	op := li.SlowOps[0]
+	if op.Milli != 2400 || op.Command != "find" || op.Namespace != "keyhole.cars" || op.PlanSummary != "IXSCAN { color: 1 }" ||
+		op.Time.Format(logTimeLayout) != "2019-09-28T10:02:07.100-0400" {
+		t.Fatal(op)
+	}
+	if li.SlowOps[1].PlanSummary != COLLSCAN {
+		t.Fatal(li.SlowOps[1])
+	}
+	data, _ := json.Marshal(li.SlowOps)
+	if strings.Index(string(data), `"planSummary":"COLLSCAN"`) < 0 {
+		t.Fatal(string(data))
+	}
+}
+
+func TestLogInfoTransactions(t *testing.T) {
+	li := NewLogInfo("testdata/transactions.log", "")
+	li.SetSilent(true)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if len(li.Transactions) != 2 {
+		t.Fatal("expected 2 transaction patterns, but got", len(li.Transactions))
+	}
+	doc := li.Transactions[1]
+	if doc.Count != 1 || doc.TotalMilli != 400 || doc.Aborted != 0 || len(doc.Statements) != 2 ||
+		doc.Statements[0] != "find keyhole.cars {vin: 1}" || doc.Statements[1] != "findAndModify keyhole.dealers {_id: 1}" {
+		t.Fatal(doc)
+	}
+	if li.Transactions[0].Count != 2 || li.Transactions[0].Aborted != 1 || li.Transactions[0].TotalMilli != 500 {
+		t.Fatal(li.Transactions[0])
+	}
+	commits := 0
+	for _, doc := range li.OpsPatterns {
+		if doc.Command == "commitTransaction" && doc.Namespace == "admin" {
+			commits = doc.Count
+		}
+	}
+	if commits != 1 {
+		t.Fatal("expected 1 commitTransaction, but got", commits)
+	}
+	if strings.Contains(strings.Join(li.getTransactionsSummaries(), "\n"), "Transactions by statement patterns") == false {
+		t.Fatal(li.getTransactionsSummaries())
+	}
+}
+
+func TestLogInfoSorts(t *testing.T) {
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	for _, doc := range li.OpsPatterns {
+		if doc.Command == "find" && doc.Filter == "{color: 1, year: {$gt: 1}}" && doc.InMemorySorts != doc.Count {
+			t.Fatal("expected in-memory sorts", doc.InMemorySorts, doc.Count)
+		} else if doc.Command == "aggregate" && doc.SpilledSorts != 1 {
+			t.Fatal("expected a sort used disk", doc.SpilledSorts)
+		} else if doc.Command == "update" && (doc.InMemorySorts != 0 || doc.SpilledSorts != 0) {
+			t.Fatal("unexpected sorts", doc.Filter)
+		}
+	}
+	var buffer bytes.Buffer
+	for _, doc := range li.OpsPatterns {
+		line := ConverOpPerformanceDocumentToLogInfoLineAnalytics(&doc)
+		(&ScreenOutputFormatter{}).WriteLine(&buffer, &line)
+	}
+	if strings.Contains(buffer.String(), "in-memory sorts: 3 of 3") == false {
+		t.Fatal(buffer.String())
+	}
+}
+
+func TestLogInfoIndexBuilds(t *testing.T) {
+	li := NewLogInfo("testdata/index_builds.log", "")
+	li.SetSilent(true)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if len(li.IndexBuilds) != 5 {
+		t.Fatal("expected 5 index builds, but got", len(li.IndexBuilds))
+	}
+	build := li.IndexBuilds[0]
+	if build.Namespace != "keyhole.cars" || build.Keys != "{color: 1, year: -1}" || build.Type != IndexBuildBackground ||
+		build.Duration() != 12*time.Second || build.Scanned != 100000 || build.SlowOps != 1 {
+		t.Fatal(build)
+	}
+	if li.IndexBuilds[1].Type != IndexBuildForeground || li.IndexBuilds[1].SlowOps != 0 {
+		t.Fatal(li.IndexBuilds[1])
+	}
+	if li.IndexBuilds[2].Type != IndexBuildHybrid || li.IndexBuilds[2].Duration() != 30*time.Second {
+		t.Fatal(li.IndexBuilds[2])
+	}
+	if li.IndexBuilds[3].Name != "brand_1" || li.IndexBuilds[3].Keys != `{"brand":1}` || li.IndexBuilds[3].Duration() != 45*time.Second {
+		t.Fatal(li.IndexBuilds[3])
+	}
+	if li.IndexBuilds[4].Resumable == false || li.IndexBuilds[4].Done == true {
+		t.Fatal(li.IndexBuilds[4])
+	}
+	if strings.Contains(strings.Join(li.getIndexBuildsSummaries(), "\n"), "unfinished") == false {
+		t.Fatal(li.getIndexBuildsSummaries())
+	}
+}
+
+func TestLogInfoCachePressure(t *testing.T) {
+	li := NewLogInfo("testdata/cache_pressure.log", "")
+	li.SetSilent(true)
+	li.SetSpan(60)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if len(li.CachePressure) != 2 {
+		t.Fatal("expected 2 cache pressure periods, but got", len(li.CachePressure))
+	}
+	doc := li.CachePressure[0]
+	if doc.Events != 2 || doc.SlowOps != 2 || doc.TotalMilli != 6000 || doc.End.Sub(doc.Start) != 30*time.Second {
+		t.Fatal(doc)
+	}
+	if li.CachePressure[1].Events != 1 || li.CachePressure[1].SlowOps != 0 {
+		t.Fatal(li.CachePressure[1])
+	}
+	li.cacheEvents = []cacheEvent{{message: "cache full", ts: doc.End.Add(10 * time.Second)}} // as of a tick of Follow
+	li.sortCachePressure()
+	if len(li.CachePressure) != 2 || li.CachePressure[0].Events != 3 || li.CachePressure[0].SlowOps != 2 {
+		t.Fatal(li.CachePressure)
+	}
+	summaries := strings.Join(li.GetSummaries(), "\n")
+	if strings.Contains(summaries, "Cache pressure periods:") == false || strings.Contains(summaries, "(cache pressure)") == false {
+		t.Fatal(summaries)
+	}
+}
+
+func TestLogInfoGetMores(t *testing.T) {
+	li := NewLogInfo("testdata/getmore.log", "")
+	li.SetSilent(true)
+	li.SetGetMores(true)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if len(li.Cursors) != 2 {
+		t.Fatal("expected 2 originating patterns, but got", len(li.Cursors))
+	}
+	for _, doc := range li.OpsPatterns {
+		if doc.Command == "getMore" {
+			t.Fatal("getMore should not be an ops pattern", doc)
+		}
+	}
+	doc := li.Cursors[1]
+	if doc.Command != "find" || doc.Filter != "{color: 1}" || doc.Cursors != 1 || doc.GetMores != 2 || doc.TotalMilli != 600 ||
+		doc.LifetimeMilli != 10000 {
+		t.Fatal(doc)
+	}
+	if li.Cursors[0].LifetimeMilli != 1000 || len(li.openCursors) != 0 {
+		t.Fatal(li.Cursors[0])
+	}
+	key := doc.Command + "." + doc.Namespace + "." + doc.Filter
+	now := time.Now()
+	li.openCursors = map[string]*cursorStats{"1": {first: now, key: key, last: now.Add(time.Second)}} // as of a tick of Follow
+	li.sortCursors()
+	li.sortCursors()
+	if li.Cursors[1].LifetimeMilli != 11000 || li.cursorsMap[key].LifetimeMilli != 10000 || len(li.openCursors) != 1 {
+		t.Fatal(li.Cursors[1])
+	}
+	if strings.Contains(strings.Join(li.getSlowOpsSummaries(), "\n"), "getMore batches by originating patterns:") == false {
+		t.Fatal(li.getSlowOpsSummaries())
+	}
+}
+
+func TestLogInfoQueryHash(t *testing.T) {
+	li := NewLogInfo("testdata/query_hash.log", "")
+	li.SetSilent(true)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if len(li.OpsPatterns) != 3 {
+		t.Fatal("expected 3 ops patterns, but got", len(li.OpsPatterns))
+	}
+	for _, doc := range li.OpsPatterns {
+		if doc.Namespace == "keyhole.cars" && doc.QueryHash != "" && (doc.Count != 2 || doc.PlanCacheKey != "C3A8D0E2") {
+			t.Fatal(doc)
+		} else if doc.Namespace == "keyhole.dealers" && (doc.QueryHash != "8E5BC1E9" || doc.PlanCacheKey != "A1B2C3D4") {
+			t.Fatal(doc)
+		} else if doc.Filter == "{year: 1}" && doc.QueryHash != "" {
+			t.Fatal(doc)
+		}
+	}
+	if hash := getLogHash(`{"attr":{"queryHash":"8E5BC1E9","planCacheKey":"C3A8D0E2"}}`, "planCacheKey"); hash != "C3A8D0E2" {
+		t.Fatal(hash)
+	}
+}
+
+func TestLogInfoHostPrefixed(t *testing.T) {
+	li := NewLogInfo("testdata/host_prefixed.log", "")
+	li.SetSilent(true)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if len(li.OpsPatterns) != 2 {
+		t.Fatal("expected 2 merged ops patterns, but got", len(li.OpsPatterns))
+	}
+	for _, doc := range li.OpsPatterns {
+		if doc.Filter == "{color: 1}" && (doc.Count != 3 || doc.Hosts["cluster0-shard-00-00.abcde.mongodb.net:27017"] != 2 ||
+			doc.Hosts["cluster0-shard-00-01.abcde.mongodb.net:27017"] != 1) {
+			t.Fatal(doc)
+		}
+	}
+	if len(li.Apps) != 1 || li.Apps[0].Connections != 2 { // conn10 of both hosts
+		t.Fatal(li.Apps)
+	}
+	if len(li.Hosts) != 2 || li.Hosts[0].Count != 2 || li.Hosts[0].Patterns != 1 || li.Hosts[1].Count != 2 || li.Hosts[1].MaxMilli != 500 {
+		t.Fatal(li.Hosts)
+	}
+	if strings.Contains(li.GetServerInfo(), "db version v4.0.12") == false {
+		t.Fatal(li.GetServerInfo())
+	}
+	if str := li.stripHostPrefix("2019-09-28T16:00:00.000-0400 I COMMAND  [conn10] command"); li.lineHost != "" || strings.HasPrefix(str, "2019") == false {
+		t.Fatal(str)
+	}
+	if key := li.getHostKey("conn10"); key != "conn10" {
+		t.Fatal(key)
+	}
+	li.stripHostPrefix("cluster0-shard-00-01.abcde.mongodb.net:27017 2019-09-28T16:00:00.000-0400 I COMMAND  [conn10] command")
+	if key := li.getHostKey("conn10"); key != "cluster0-shard-00-01.abcde.mongodb.net:27017/conn10" {
+		t.Fatal(key)
+	}
+}
+
+func TestLogInfoVariants(t *testing.T) {
+	li := NewLogInfo("testdata/variants.log", "")
+	li.SetSilent(true)
+	li.SetVariants(true)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if len(li.Variants) != 1 {
+		t.Fatal("expected 1 pattern of variants, but got", len(li.Variants))
+	}
+	doc := li.Variants[0]
+	if doc.Filter != "{color: 1, year: 1}" || len(doc.Variants) != 2 {
+		t.Fatal(doc)
+	}
+	if doc.Variants[0].Count != 2 || doc.Variants[0].Shape != "{color: string, year: number}" ||
+		doc.Variants[1].Shape != "{year: NumberLong, color: string}" {
+		t.Fatal(doc.Variants)
+	}
+	li.sortVariants()
+	if len(li.Variants) != 1 || len(li.Variants[0].Variants) != 2 {
+		t.Fatal("expected variants unchanged after sorting again, but got", li.Variants)
+	}
+	summaries := strings.Join(li.getSlowOpsSummaries(), "\n")
+	if strings.Contains(summaries, "Query patterns of different fields orders or value types:") == false {
+		t.Fatal(summaries)
+	}
+	if shape := getFilterVariant(`{ a: { $in: [ 1, 2 ] }, b: /x/, c: { $numberLong: "1" }, d: true }`); shape !=
+		"{a: {$in: [number]}, b: regex, c: $numberLong, d: bool}" {
+		t.Fatal(shape)
+	}
+}
+
+func TestLogInfoExamples(t *testing.T) {
+	li := NewLogInfo("testdata/variants.log", "")
+	li.SetSilent(true)
+	li.SetExamples(2)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	for _, doc := range li.OpsPatterns {
+		if doc.Filter != "{color: 1, year: 1}" {
+			continue
+		}
+		if len(doc.Examples) != 2 || doc.Examples[0].Milli != 150 || doc.Examples[1].Milli != 130 || doc.Examples[0].Time.IsZero() {
+			t.Fatal(doc.Examples)
+		}
+		if strings.HasPrefix(doc.Examples[0].Statement, `find { find: "cars", filter: { year: NumberLong(2018), color: "Blue" }`) == false {
+			t.Fatal(doc.Examples[0].Statement)
+		}
+		examples := mergeExamples(doc.Examples, []ExampleDoc{{Milli: 500, Statement: "find {}"}, {Milli: 100}}, 2)
+		if len(examples) != 2 || examples[0].Milli != 500 || examples[1].Milli != 150 {
+			t.Fatal(examples)
+		}
+		return
+	}
+	t.Fatal("expected pattern {color: 1, year: 1}")
+}
@@ -0,0 +1,96 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// logTimeLayout is the timestamp format of mongod logs, e.g. 2019-09-28T10:00:01.100-0400
+const logTimeLayout = "2006-01-02T15:04:05.000-0700"
+
+// TimeBucketDoc holds ops counts and latencies of a command in a time bucket
+type TimeBucketDoc struct {
+	Command    string
+	Count      int
+	MaxMilli   int
+	Time       time.Time
+	TotalMilli int
+}
+
+// SetSpan sets seconds of a time bucket, throughput is reported if span is greater than 0
+func (li *LogInfo) SetSpan(span int) {
+	li.span = span
+}
+
+// getLogTime returns timestamp of a log line
+func getLogTime(str string) time.Time {
+	if idx := strings.Index(str, " "); idx > 0 {
+		str = str[:idx]
+	}
+	t, err := time.Parse(logTimeLayout, str)
+	if err != nil {
+		t, _ = time.Parse(time.RFC3339Nano, str)
+	}
+	return t
+}
+
+// aggregateTimeBucket adds an op to its time bucket
+func (li *LogInfo) aggregateTimeBucket(stats opStats) {
+	if li.span <= 0 || stats.ts.IsZero() {
+		return
+	}
+	if li.bucketsMap == nil {
+		li.bucketsMap = map[string]*TimeBucketDoc{}
+	}
+	t := stats.ts.Truncate(time.Duration(li.span) * time.Second)
+	key := t.Format(time.RFC3339) + "." + stats.command
+	bucket, ok := li.bucketsMap[key]
+	if ok == false {
+		bucket = &TimeBucketDoc{Command: stats.command, Time: t}
+		li.bucketsMap[key] = bucket
+	}
+	bucket.Count++
+	bucket.TotalMilli += stats.milli
+	if stats.milli > bucket.MaxMilli {
+		bucket.MaxMilli = stats.milli
+	}
+}
+
+// sortTimeSeries sets time series sorted by time and command
+func (li *LogInfo) sortTimeSeries() {
+	if len(li.bucketsMap) == 0 {
+		return
+	}
+	li.TimeSeries = make([]TimeBucketDoc, 0, len(li.bucketsMap))
+	for _, bucket := range li.bucketsMap {
+		li.TimeSeries = append(li.TimeSeries, *bucket)
+	}
+	sort.Slice(li.TimeSeries, func(i, j int) bool {
+		if li.TimeSeries[i].Time.Equal(li.TimeSeries[j].Time) {
+			return li.TimeSeries[i].Command < li.TimeSeries[j].Command
+		}
+		return li.TimeSeries[i].Time.Before(li.TimeSeries[j].Time)
+	})
+}
+
+// getTimeSeriesSummaries returns ops counts and average latencies by time buckets
+func (li *LogInfo) getTimeSeriesSummaries() []string {
+	if len(li.TimeSeries) == 0 {
+		return []string{}
+	}
+	summaries := []string{fmt.Sprintf("Throughput every %d seconds:", li.span)}
+	summaries = append(summaries, fmt.Sprintf("%-25s %-14s %8s %10s %10s", "time", "command", "count", "avg ms", "max ms"))
+	for _, bucket := range li.TimeSeries {
+		str := fmt.Sprintf("%-25s %-14s %8d %10.1f %10d", bucket.Time.Format(time.RFC3339), bucket.Command,
+			bucket.Count, float64(bucket.TotalMilli)/float64(bucket.Count), bucket.MaxMilli)
+		if li.isCachePressured(bucket.Time, bucket.Time.Add(time.Duration(li.span)*time.Second-time.Nanosecond)) {
+			str += " (cache pressure)"
+		}
+		summaries = append(summaries, str)
+	}
+	return append(summaries, "\n")
+}
@@ -0,0 +1,147 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// noStatements is the pattern of a transaction without slow statements logged
+const noStatements = "(statements not logged)"
+
+var txnLogRegex = regexp.MustCompile(`^\S+\s+\w\s+(TXN|COMMAND)\s+\[\w+\] transaction parameters:(.*) (\d+)ms$`)
+var lsidRegex = regexp.MustCompile(`lsid: \{ id: UUID\("([^"]+)"\)`)
+var txnNumberRegex = regexp.MustCompile(`txnNumber: (\d+)`)
+var terminationRegex = regexp.MustCompile(`terminationCause:(\w+)`)
+
+// TransactionDoc holds stats of multi-document transactions of the same statement patterns
+type TransactionDoc struct {
+	Aborted    int      // number of transactions aborted
+	Count      int      // number of transactions
+	MaxMilli   int      // max milliseconds
+	Statements []string // statement patterns in order, e.g. update keyhole.cars {dealer:1}
+	TotalMilli int      // total milliseconds
+}
+
+// getTxnKey returns lsid and txnNumber of a statement in a multi-document transaction
+func getTxnKey(str string) string {
+	if strings.Contains(str, "autocommit: false") == false {
+		return "" // retryable writes have txnNumber, too
+	}
+	lsid := lsidRegex.FindStringSubmatch(str)
+	txnNumber := txnNumberRegex.FindStringSubmatch(str)
+	if len(lsid) < 2 || len(txnNumber) < 2 {
+		return ""
+	}
+	return lsid[1] + "." + txnNumber[1]
+}
+
+// isTxnCommand returns true if an admin.$cmd line is a commitTransaction or an abortTransaction
+func isTxnCommand(str string) bool {
+	return strings.Contains(str, "command: commitTransaction ") || strings.Contains(str, "command: abortTransaction ")
+}
+
+// parseTransaction aggregates a transaction parameters log line, returns false if not a transaction line
+func (li *LogInfo) parseTransaction(str string) bool {
+	result := txnLogRegex.FindStringSubmatch(str)
+	if len(result) == 0 {
+		return false
+	}
+	milli, _ := strconv.Atoi(result[3])
+	cause := ""
+	if res := terminationRegex.FindStringSubmatch(result[2]); len(res) > 1 {
+		cause = res[1]
+	}
+	li.closeTransaction(getTxnKey("autocommit: false "+result[2]), cause, milli)
+	return true
+}
+
+// aggregateTxnCommand aggregates latency of a commitTransaction or an abortTransaction
+func (li *LogInfo) aggregateTxnCommand(str string, op string, ns string, milli int) {
+	key := getTxnKey(str)
+	li.aggregate(opStats{appName: getAppName(str), conn: li.getHostKey(getConnID(str)), command: op, namespace: strings.TrimSuffix(ns, ".$cmd"),
+		filter: "{}", milli: milli, log: str, ts: getLogTime(str)})
+	if _, ok := li.pendingTxns[key]; ok == false {
+		return // closed by a transaction parameters line
+	}
+	cause := "committed"
+	if op == "abortTransaction" {
+		cause = "aborted"
+	}
+	li.closeTransaction(key, cause, milli)
+}
+
+// addTxnStatement adds a statement pattern to its open transaction
+func (li *LogInfo) addTxnStatement(stats opStats) {
+	if stats.txnKey == "" {
+		return
+	}
+	if li.pendingTxns == nil {
+		li.pendingTxns = map[string][]string{}
+	}
+	li.pendingTxns[stats.txnKey] = append(li.pendingTxns[stats.txnKey], stats.command+" "+stats.namespace+" "+stats.filter)
+}
+
+// closeTransaction aggregates a transaction by its statement patterns
+func (li *LogInfo) closeTransaction(key string, cause string, milli int) {
+	statements := li.pendingTxns[key]
+	delete(li.pendingTxns, key)
+	if len(statements) == 0 {
+		statements = []string{noStatements}
+	}
+	if li.txnsMap == nil {
+		li.txnsMap = map[string]*TransactionDoc{}
+	}
+	pattern := strings.Join(statements, "; ")
+	doc, ok := li.txnsMap[pattern]
+	if ok == false {
+		doc = &TransactionDoc{Statements: statements}
+		li.txnsMap[pattern] = doc
+	}
+	doc.Count++
+	doc.TotalMilli += milli
+	if milli > doc.MaxMilli {
+		doc.MaxMilli = milli
+	}
+	if cause != "" && cause != "committed" {
+		doc.Aborted++
+	}
+}
+
+// sortTransactions sets transactions sorted by total time
+func (li *LogInfo) sortTransactions() {
+	if len(li.txnsMap) == 0 {
+		return
+	}
+	li.Transactions = make([]TransactionDoc, 0, len(li.txnsMap))
+	for _, doc := range li.txnsMap {
+		li.Transactions = append(li.Transactions, *doc)
+	}
+	sort.Slice(li.Transactions, func(i, j int) bool {
+		if li.Transactions[i].TotalMilli == li.Transactions[j].TotalMilli {
+			return strings.Join(li.Transactions[i].Statements, ";") < strings.Join(li.Transactions[j].Statements, ";")
+		}
+		return li.Transactions[i].TotalMilli > li.Transactions[j].TotalMilli
+	})
+}
+
+// getTransactionsSummaries returns transactions latencies by statement patterns
+func (li *LogInfo) getTransactionsSummaries() []string {
+	if len(li.Transactions) == 0 {
+		return []string{}
+	}
+	summaries := []string{"Transactions by statement patterns:"}
+	summaries = append(summaries, fmt.Sprintf("%8s %8s %10s %10s  %s", "count", "aborted", "avg ms", "max ms", "statements"))
+	for _, doc := range li.Transactions {
+		summaries = append(summaries, fmt.Sprintf("%8d %8d %10.1f %10d  %s", doc.Count, doc.Aborted,
+			float64(doc.TotalMilli)/float64(doc.Count), doc.MaxMilli, doc.Statements[0]))
+		for _, statement := range doc.Statements[1:] {
+			summaries = append(summaries, fmt.Sprintf("%40s%s", "", statement))
+		}
+	}
+	return append(summaries, "\n")
+}
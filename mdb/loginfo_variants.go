@@ -0,0 +1,141 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PatternVariantsDoc holds shapes of a query pattern as constructed by applications, e.g. {a: number, b: number}
+// and {b: number, a: NumberLong} are different shapes of the query pattern {a: 1, b: 1}
+type PatternVariantsDoc struct {
+	Command   string
+	Filter    string // query pattern
+	Namespace string
+	Variants  []VariantDoc // the most frequent first
+}
+
+// VariantDoc is a shape of a query pattern, fields order and value types are kept
+type VariantDoc struct {
+	Count int
+	Shape string
+}
+
+// SetVariants sets to audit query patterns constructed with different fields orders or value types
+func (li *LogInfo) SetVariants(variants bool) {
+	li.variants = variants
+}
+
+// getFilterVariant returns a shape of a filter of logs, fields order and value types are kept,
+// e.g. {b: NumberLong, a: string}
+func getFilterVariant(filter string) string {
+	doc, err := parseShellDoc(filter)
+	if err != nil {
+		return ""
+	}
+	return getVariantShape(doc)
+}
+
+// getVariantShape returns types of values in fields order
+func getVariantShape(value interface{}) string {
+	switch v := value.(type) {
+	case bson.D:
+		if len(v) == 1 && contains([]string{"$date", "$numberDecimal", "$numberDouble", "$numberInt", "$numberLong", "$oid"}, v[0].Key) {
+			return v[0].Key // extended JSON of 4.4 logs
+		}
+		strs := []string{}
+		for _, elem := range v {
+			strs = append(strs, elem.Key+": "+getVariantShape(elem.Value))
+		}
+		return "{" + strings.Join(strs, ", ") + "}"
+	case primitive.A:
+		types := []string{}
+		for _, elem := range v {
+			if shape := getVariantShape(elem); contains(types, shape) == false {
+				types = append(types, shape)
+			}
+		}
+		return "[" + strings.Join(types, ", ") + "]"
+	case primitive.Regex:
+		return "regex"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case shellLiteral:
+		str := strings.TrimPrefix(string(v), "new ")
+		if str == "true" || str == "false" {
+			return "bool"
+		} else if idx := strings.Index(str, "("); idx > 0 {
+			return str[:idx]
+		}
+		return str
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// aggregateVariant counts a shape of an ops pattern
+func (li *LogInfo) aggregateVariant(key string, stats opStats) {
+	if li.variantsMap == nil {
+		li.variantsMap = map[string]*PatternVariantsDoc{}
+		li.variantCounts = map[string]map[string]int{}
+	}
+	if _, ok := li.variantsMap[key]; ok == false {
+		li.variantsMap[key] = &PatternVariantsDoc{Command: stats.command, Filter: stats.filter, Namespace: stats.namespace}
+		li.variantCounts[key] = map[string]int{}
+	}
+	li.variantCounts[key][stats.variant]++
+}
+
+// sortVariants sets query patterns of more than one shape, patterns of the most shapes first
+func (li *LogInfo) sortVariants() {
+	if len(li.variantsMap) == 0 {
+		return
+	}
+	li.Variants = []PatternVariantsDoc{}
+	for key, doc := range li.variantsMap {
+		counts := li.variantCounts[key]
+		if len(counts) < 2 {
+			continue
+		}
+		doc.Variants = []VariantDoc{} // rebuilt from counts on every sort, e.g. ticks of Follow
+		for shape, count := range counts {
+			doc.Variants = append(doc.Variants, VariantDoc{Count: count, Shape: shape})
+		}
+		sort.Slice(doc.Variants, func(i, j int) bool {
+			if doc.Variants[i].Count == doc.Variants[j].Count {
+				return doc.Variants[i].Shape < doc.Variants[j].Shape
+			}
+			return doc.Variants[i].Count > doc.Variants[j].Count
+		})
+		li.Variants = append(li.Variants, *doc)
+	}
+	sort.Slice(li.Variants, func(i, j int) bool {
+		x, y := li.Variants[i], li.Variants[j]
+		if len(x.Variants) != len(y.Variants) {
+			return len(x.Variants) > len(y.Variants)
+		}
+		return x.Command+x.Namespace+x.Filter < y.Command+y.Namespace+y.Filter
+	})
+}
+
+// getVariantsSummaries returns query patterns constructed inconsistently by applications
+func (li *LogInfo) getVariantsSummaries() []string {
+	if len(li.Variants) == 0 {
+		return []string{}
+	}
+	summaries := []string{"Query patterns of different fields orders or value types:"}
+	for _, doc := range li.Variants {
+		summaries = append(summaries, fmt.Sprintf("%s %s %s", doc.Command, doc.Namespace, doc.Filter))
+		for _, variant := range doc.Variants {
+			summaries = append(summaries, fmt.Sprintf("%10d  %s", variant.Count, variant.Shape))
+		}
+	}
+	return append(summaries, "\n")
+}
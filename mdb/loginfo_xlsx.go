@@ -0,0 +1,222 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// XLSXOutputFormatter writes an Excel workbook of ops patterns, slow ops, and namespaces worksheets
+type XLSXOutputFormatter struct {
+	OutputFormatterBase
+	rows [][]interface{} // rows of the ops patterns worksheet
+}
+
+// xlsxSheet is a worksheet, the first row is the header
+type xlsxSheet struct {
+	name string
+	rows [][]interface{}
+}
+
+// WriteHeader starts rows of ops patterns
+func (formatter *XLSXOutputFormatter) WriteHeader(buffer *bytes.Buffer) {
+	formatter.rows = [][]interface{}{{"command", "namespace", "COLLSCAN", "count", "avg ms", "p50 ms", "p90 ms", "p95 ms", "p99 ms", "max ms",
+		"total ms", "keysExamined", "docsExamined", "nreturned", "ratio", "replanned", "in-memory sorts", "usedDisk", "writeConflicts",
+		"lock wait ms", "scatter-gather", "apps", "index", "queryHash", "query pattern"}}
+}
+
+// WriteLine adds a row of an ops pattern
+func (formatter *XLSXOutputFormatter) WriteLine(buffer *bytes.Buffer, value *LogInfoLineAnalytics) {
+	formatter.rows = append(formatter.rows, []interface{}{value.Command, value.Namespace, value.IsCollectionScan, value.Count,
+		value.AvgMilliseconds, value.P50Milliseconds, value.P90Milliseconds, value.P95Milliseconds, value.P99Milliseconds, value.MaxMilliseconds,
+		value.TotalMilliseconds, value.KeysExamined, value.DocsExamined, value.NReturned, value.ScannedRatio, value.Replanned,
+		value.InMemorySorts, value.SpilledSorts, value.WriteConflicts, float64(value.LockWaitMicros) / 1000, value.ScatterGather,
+		strings.Join(value.AppNames, ", "), value.IndexUsed, value.QueryHash, value.QueryPattern})
+}
+
+// WriteFooter writes nothing, the workbook is written by GetOutput
+func (formatter *XLSXOutputFormatter) WriteFooter(buffer *bytes.Buffer) {
+}
+
+// GetOutput returns an Excel workbook, binary content in a string, empty if the workbook failed to write
+func (formatter *XLSXOutputFormatter) GetOutput(li *LogInfo) string {
+	var buffer bytes.Buffer
+	formatter.WriteHeader(&buffer)
+	for _, value := range li.OpsPatterns {
+		line := ConverOpPerformanceDocumentToLogInfoLineAnalytics(&value)
+		formatter.WriteLine(&buffer, &line)
+	}
+	formatter.WriteFooter(&buffer)
+	slowOps := [][]interface{}{{"time", "milli", "command", "namespace", "plan summary", "source", "log"}}
+	for _, op := range li.SlowOps {
+		ts := ""
+		if op.Time.IsZero() == false {
+			ts = op.Time.Format(logTimeLayout)
+		}
+		slowOps = append(slowOps, []interface{}{ts, op.Milli, op.Command, op.Namespace, op.PlanSummary, filepath.Base(op.Source), op.Log})
+	}
+	sheets := []xlsxSheet{{name: "Ops Patterns", rows: formatter.rows}, {name: "Slow Ops", rows: slowOps},
+		{name: "Databases", rows: getRollupsRows("database", li.GetDatabasesRollup())},
+		{name: "Namespaces", rows: getRollupsRows("namespace", li.GetNamespacesRollup())}}
+	if err := writeXLSX(&buffer, sheets); err != nil {
+		log.Println("xlsx error:", err)
+		return ""
+	}
+	return buffer.String()
+}
+
+// getRollupsRows returns rows of ops stats by databases or namespaces
+func getRollupsRows(name string, rollups []RollupDoc) [][]interface{} {
+	rows := [][]interface{}{{name, "count", "avg ms", "max ms", "total ms", "COLLSCAN", "COLLSCAN %", "patterns"}}
+	for _, s := range rollups {
+		rows = append(rows, []interface{}{s.Name, s.Count, float64(s.TotalMilli) / float64(s.Count), s.MaxMilli, s.TotalMilli,
+			s.Collscans, s.GetCollscanPercent(), s.Patterns})
+	}
+	return rows
+}
+
+// writeXLSX writes a workbook of worksheets with bold, frozen, and filtered headers
+func writeXLSX(buffer *bytes.Buffer, sheets []xlsxSheet) error {
+	var err error
+	var contentTypes, rels, workbook, definedNames bytes.Buffer
+	contentTypes.WriteString(xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	rels.WriteString(xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	workbook.WriteString(xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	files := map[string]string{}
+	for i, sheet := range sheets {
+		n := i + 1
+		contentTypes.WriteString(fmt.Sprintf(`<Override PartName="/xl/worksheets/sheet%d.xml" `+
+			`ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n))
+		rels.WriteString(fmt.Sprintf(`<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" `+
+			`Target="worksheets/sheet%d.xml"/>`, n, n))
+		workbook.WriteString(fmt.Sprintf(`<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escapeXML(sheet.name), n, n))
+		ref := getXLSXRange(sheet.rows)
+		definedNames.WriteString(fmt.Sprintf(`<definedName name="_xlnm._FilterDatabase" localSheetId="%d" hidden="1">'%s'!%s</definedName>`,
+			i, escapeXML(strings.Replace(sheet.name, "'", "''", -1)), getAbsoluteRange(ref)))
+		files[fmt.Sprintf("xl/worksheets/sheet%d.xml", n)] = getXLSXSheet(sheet.rows, ref)
+	}
+	contentTypes.WriteString(`</Types>`)
+	rels.WriteString(fmt.Sprintf(`<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" `+
+		`Target="styles.xml"/></Relationships>`, len(sheets)+1))
+	workbook.WriteString(`</sheets><definedNames>` + definedNames.String() + `</definedNames></workbook>`)
+	files["[Content_Types].xml"] = contentTypes.String()
+	files["_rels/.rels"] = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" ` +
+		`Target="xl/workbook.xml"/></Relationships>`
+	files["xl/workbook.xml"] = workbook.String()
+	files["xl/_rels/workbook.xml.rels"] = rels.String()
+	files["xl/styles.xml"] = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+		`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+		`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs></styleSheet>`
+
+	filenames := make([]string, 0, len(files))
+	for name := range files {
+		filenames = append(filenames, name)
+	}
+	sort.Strings(filenames)
+	writer := zip.NewWriter(buffer)
+	for _, name := range filenames {
+		var w io.Writer
+		if w, err = writer.Create(name); err != nil {
+			return err
+		}
+		if _, err = w.Write([]byte(files[name])); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+// getXLSXSheet returns a worksheet, the header row is bold, frozen, and filtered
+func getXLSXSheet(rows [][]interface{}, ref string) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>` +
+		`</sheetView></sheetViews><sheetData>`)
+	for r, row := range rows {
+		buffer.WriteString(fmt.Sprintf(`<row r="%d">`, r+1))
+		for c, value := range row {
+			cell := getColumnName(c) + fmt.Sprintf("%d", r+1)
+			style := ""
+			if r == 0 {
+				style = ` s="1"`
+			}
+			switch v := value.(type) {
+			case int:
+				buffer.WriteString(fmt.Sprintf(`<c r="%s"%s><v>%d</v></c>`, cell, style, v))
+			case float64:
+				buffer.WriteString(fmt.Sprintf(`<c r="%s"%s><v>%.1f</v></c>`, cell, style, v))
+			case bool:
+				b := 0
+				if v == true {
+					b = 1
+				}
+				buffer.WriteString(fmt.Sprintf(`<c r="%s"%s t="b"><v>%d</v></c>`, cell, style, b))
+			default:
+				buffer.WriteString(fmt.Sprintf(`<c r="%s"%s t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, cell, style,
+					escapeXML(fmt.Sprintf("%v", v))))
+			}
+		}
+		buffer.WriteString(`</row>`)
+	}
+	buffer.WriteString(`</sheetData><autoFilter ref="` + ref + `"/></worksheet>`)
+	return buffer.String()
+}
+
+// getXLSXRange returns the range of cells of rows, e.g. A1:X10
+func getXLSXRange(rows [][]interface{}) string {
+	cols := 1
+	for _, row := range rows {
+		if len(row) > cols {
+			cols = len(row)
+		}
+	}
+	n := len(rows)
+	if n == 0 {
+		n = 1
+	}
+	return fmt.Sprintf("A1:%s%d", getColumnName(cols-1), n)
+}
+
+// getAbsoluteRange returns an absolute reference of a range, e.g. $A$1:$X$10
+func getAbsoluteRange(ref string) string {
+	parts := strings.Split(ref, ":")
+	for i, part := range parts {
+		n := strings.IndexAny(part, "0123456789")
+		parts[i] = "$" + part[:n] + "$" + part[n:]
+	}
+	return strings.Join(parts, ":")
+}
+
+// getColumnName returns a column name of a 0-based index, e.g. 0 is A and 26 is AA
+func getColumnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+func escapeXML(str string) string {
+	var buffer bytes.Buffer
+	xml.EscapeText(&buffer, []byte(str))
+	return buffer.String()
+}
@@ -0,0 +1,57 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestXLSXOutputFormatter(t *testing.T) {
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	str := (&XLSXOutputFormatter{}).GetOutput(li)
+	reader, err := zip.NewReader(bytes.NewReader([]byte(str)), int64(len(str)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadAll(rc)
+		rc.Close()
+		files[file.Name] = string(data)
+	}
+	for _, s := range []string{`name="Ops Patterns"`, `name="Slow Ops"`, `name="Namespaces"`, "_xlnm._FilterDatabase"} {
+		if strings.Contains(files["xl/workbook.xml"], s) == false {
+			t.Fatal("expected", s)
+		}
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/_rels/workbook.xml.rels", "xl/styles.xml",
+		"xl/worksheets/sheet2.xml", "xl/worksheets/sheet3.xml"} {
+		if _, ok := files[name]; ok == false {
+			t.Fatal("expected", name)
+		}
+	}
+	sheet := files["xl/worksheets/sheet1.xml"]
+	for _, s := range []string{`state="frozen"`, `<autoFilter ref="A1:Y`, `s="1" t="inlineStr"><is><t xml:space="preserve">command</t>`, "COLLSCAN"} {
+		if strings.Contains(sheet, s) == false {
+			t.Fatal("expected", s)
+		}
+	}
+	if getColumnName(0) != "A" || getColumnName(25) != "Z" || getColumnName(26) != "AA" || getColumnName(701) != "ZZ" {
+		t.Fatal(getColumnName(26), getColumnName(701))
+	}
+	if getAbsoluteRange("A1:X10") != "$A$1:$X$10" {
+		t.Fatal(getAbsoluteRange("A1:X10"))
+	}
+}
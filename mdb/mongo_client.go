@@ -113,3 +113,11 @@ func ReadPasswordFromStdin() (string, error) {
 	}
 	return string(buffer), err
 }
+
+// withTimeout returns a context of an operation, canceled after timeout if greater than 0
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
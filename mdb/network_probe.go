@@ -0,0 +1,220 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+// networkCollection is the temp collection of KEYHOLEDB to measure throughputs, dropped after probing
+const networkCollection = "networkProbe"
+
+// NetworkProbe measures round trips to each member and throughputs of writing and reading a temp collection
+type NetworkProbe struct {
+	docSize int
+	docs    int
+	rounds  int
+	uri     string
+	verbose bool
+}
+
+// MemberNetwork is round trips to a member and throughput of reading the temp collection from it
+type MemberNetwork struct {
+	Host        string             `json:"host"`
+	RTT         LatencyPercentiles `json:"rtt"`
+	ReadSeconds float64            `json:"readSeconds"`
+	ReadMBPS    float64            `json:"readMBps"`
+	Error       string             `json:"error,omitempty"`
+}
+
+// NetworkProbeReport is throughput of writes and round trips and reads of members
+type NetworkProbeReport struct {
+	Documents    int             `json:"documents"`
+	Bytes        int64           `json:"bytes"`
+	WriteSeconds float64         `json:"writeSeconds"`
+	WriteMBPS    float64         `json:"writeMBps"`
+	Members      []MemberNetwork `json:"members"`
+}
+
+// NewNetworkProbe returns a NetworkProbe of 20 round trips and 1,000 documents of 4KB
+func NewNetworkProbe(uri string) *NetworkProbe {
+	return &NetworkProbe{docSize: 4096, docs: 1000, rounds: 20, uri: uri}
+}
+
+// SetDocuments sets number of documents written and read to measure throughputs
+func (np *NetworkProbe) SetDocuments(docs int) {
+	if docs > 0 {
+		np.docs = docs
+	}
+}
+
+// SetRounds sets number of round trips to each member
+func (np *NetworkProbe) SetRounds(rounds int) {
+	if rounds > 0 {
+		np.rounds = rounds
+	}
+}
+
+// SetVerbose sets verbose level
+func (np *NetworkProbe) SetVerbose(verbose bool) {
+	np.verbose = verbose
+}
+
+// Probe writes the temp collection with the majority write concern, and measures round trips to and reads from
+// each member
+func (np *NetworkProbe) Probe() (NetworkProbeReport, error) {
+	var err error
+	var client *mongo.Client
+	var connString connstring.ConnString
+	report := NetworkProbeReport{Documents: np.docs, Members: []MemberNetwork{}}
+	if connString, err = connstring.Parse(np.uri); err != nil {
+		return report, err
+	}
+	if client, err = NewMongoClient(np.uri); err != nil {
+		return report, err
+	}
+	defer client.Disconnect(context.Background())
+	wc := writeconcern.New(writeconcern.WMajority())
+	coll := client.Database(KEYHOLEDB).Collection(networkCollection, options.Collection().SetWriteConcern(wc))
+	ctx := context.Background()
+	coll.Drop(ctx)
+	defer coll.Drop(ctx)
+	docs := getNetworkProbeDocs(np.docs, np.docSize)
+	for _, doc := range docs {
+		data, _ := bson.Marshal(doc)
+		report.Bytes += int64(len(data))
+	}
+	t := time.Now()
+	for i := 0; i < len(docs); i += 100 {
+		end := i + 100
+		if end > len(docs) {
+			end = len(docs)
+		}
+		if _, err = coll.InsertMany(ctx, docs[i:end]); err != nil {
+			return report, err
+		}
+	}
+	elapsed := time.Since(t)
+	report.WriteSeconds = elapsed.Seconds()
+	report.WriteMBPS = getMBPerSec(report.Bytes, elapsed)
+	hosts := getMemberHosts(client, connString)
+	for _, host := range hosts {
+		if np.verbose == true {
+			fmt.Println("probing", host)
+		}
+		report.Members = append(report.Members, np.probeMember(host, report.Bytes))
+	}
+	return report, nil
+}
+
+// probeMember measures round trips to a member and reading all documents of the temp collection from it
+func (np *NetworkProbe) probeMember(host string, total int64) MemberNetwork {
+	var err error
+	var client *mongo.Client
+	member := MemberNetwork{Host: host}
+	if client, err = NewMongoClient(getDirectURI(np.uri, host)); err != nil {
+		member.Error = err.Error()
+		return member
+	}
+	defer client.Disconnect(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*memberPingTimeout)
+	defer cancel()
+	h := NewLatencyHistogram()
+	for i := 0; i < np.rounds; i++ {
+		t := time.Now()
+		if err = client.Ping(ctx, readpref.Nearest()); err != nil {
+			member.Error = err.Error()
+			return member
+		}
+		h.Add(int(time.Since(t) / time.Microsecond))
+	}
+	member.RTT = h.GetMilliPercentiles()
+	opts := options.Collection().SetReadPreference(readpref.Nearest())
+	coll := client.Database(KEYHOLEDB).Collection(networkCollection, opts)
+	if err = waitForDocuments(ctx, coll, np.docs); err != nil {
+		member.Error = err.Error()
+		return member
+	}
+	var cur *mongo.Cursor
+	t := time.Now()
+	if cur, err = coll.Find(ctx, bson.D{}); err != nil {
+		member.Error = err.Error()
+		return member
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+	}
+	if err = cur.Err(); err != nil {
+		member.Error = err.Error()
+		return member
+	}
+	elapsed := time.Since(t)
+	member.ReadSeconds = elapsed.Seconds()
+	member.ReadMBPS = getMBPerSec(total, elapsed)
+	return member
+}
+
+// waitForDocuments waits for a member to replicate all documents written
+func waitForDocuments(ctx context.Context, coll *mongo.Collection, docs int) error {
+	for {
+		n, err := coll.CountDocuments(ctx, bson.D{})
+		if err != nil {
+			return err
+		} else if n >= int64(docs) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return errors.New("timed out waiting for documents replicated")
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// getNetworkProbeDocs returns documents of a payload of a size
+func getNetworkProbeDocs(docs int, size int) []interface{} {
+	payload := strings.Repeat("x", size)
+	list := make([]interface{}, docs)
+	for i := range list {
+		list[i] = bson.D{{Key: "_id", Value: i}, {Key: "payload", Value: payload}}
+	}
+	return list
+}
+
+// getMBPerSec returns megabytes per second of bytes transferred in a duration
+func getMBPerSec(bytes int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(bytes) / (1024 * 1024) / d.Seconds()
+}
+
+// GetNetworkProbeSummary returns throughput of writes and round trips and reads of members
+func GetNetworkProbeSummary(report NetworkProbeReport) string {
+	var buffer bytes.Buffer
+	mb := float64(report.Bytes) / (1024 * 1024)
+	buffer.WriteString(fmt.Sprintf("Wrote %d document(s), %.1f MB, in %.2f seconds, %.1f MB/s\n", report.Documents, mb,
+		report.WriteSeconds, report.WriteMBPS))
+	buffer.WriteString(fmt.Sprintf("%-30s | %-27s | %8s %8s\n", "member", "rtt p50/p95/max ms", "read sec", "MB/s"))
+	for _, m := range report.Members {
+		if m.Error != "" {
+			buffer.WriteString(fmt.Sprintf("%-30s | error: %v\n", m.Host, m.Error))
+			continue
+		}
+		buffer.WriteString(fmt.Sprintf("%-30s | %8.2f %8.2f %9.2f | %8.2f %8.1f\n", m.Host, m.RTT.P50, m.RTT.P95, m.RTT.Max,
+			m.ReadSeconds, m.ReadMBPS))
+	}
+	return buffer.String()
+}
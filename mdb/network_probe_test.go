@@ -0,0 +1,40 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGetNetworkProbeDocs(t *testing.T) {
+	docs := getNetworkProbeDocs(10, 1024)
+	data, _ := bson.Marshal(docs[9])
+	if len(docs) != 10 || len(data) < 1024 || len(data) > 1100 {
+		t.Fatal(len(docs), len(data))
+	}
+}
+
+func TestGetMBPerSec(t *testing.T) {
+	if mbps := getMBPerSec(4*1024*1024, 2*time.Second); mbps != 2 {
+		t.Fatal(mbps)
+	}
+	if mbps := getMBPerSec(1024, 0); mbps != 0 {
+		t.Fatal(mbps)
+	}
+}
+
+func TestGetNetworkProbeSummary(t *testing.T) {
+	report := NetworkProbeReport{Documents: 1000, Bytes: 4 * 1024 * 1024, WriteSeconds: 2, WriteMBPS: 2,
+		Members: []MemberNetwork{{Host: "h1:27017", RTT: LatencyPercentiles{P50: 0.5, P95: 1.2, Max: 3}, ReadSeconds: 0.5, ReadMBPS: 8},
+			{Host: "h2:27017", Error: "connection refused"}}}
+	str := GetNetworkProbeSummary(report)
+	t.Log(str)
+	if strings.Contains(str, "4.0 MB, in 2.00 seconds, 2.0 MB/s") == false || strings.Contains(str, "h2:27017") == false ||
+		strings.Contains(str, "error: connection refused") == false {
+		t.Fatal(str)
+	}
+}
@@ -0,0 +1,272 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// OutputSink writes reports and artifacts to a destination
+type OutputSink interface {
+	Write(name string, data []byte) error
+	String() string
+}
+
+// FileSink writes artifacts to a local directory
+type FileSink struct {
+	dir string
+}
+
+// S3Sink writes artifacts to an AWS S3 bucket, credentials of AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY,
+// AWS_SESSION_TOKEN, and AWS_REGION of env, and AWS_ENDPOINT_URL for S3 compatible stores
+type S3Sink struct {
+	accessKey    string
+	bucket       string
+	client       *http.Client
+	endpoint     string // path style requests to an endpoint if not empty
+	prefix       string
+	region       string
+	secretKey    string
+	sessionToken string
+}
+
+// GCSSink writes artifacts to a Google Cloud Storage bucket with a token of GOOGLE_OAUTH_ACCESS_TOKEN of env,
+// and STORAGE_EMULATOR_HOST for emulators
+type GCSSink struct {
+	bucket   string
+	client   *http.Client
+	endpoint string
+	prefix   string
+	token    string
+}
+
+// AzureBlobSink writes artifacts to an Azure Blob Storage container of AZURE_STORAGE_ACCOUNT with a SAS token
+// of AZURE_STORAGE_SAS_TOKEN of env
+type AzureBlobSink struct {
+	client    *http.Client
+	container string
+	endpoint  string
+	prefix    string
+	sasToken  string
+}
+
+// NewOutputSink returns a sink of s3://bucket/path, gs://bucket/path, azblob://container/path, or a local
+// directory
+func NewOutputSink(destination string) (OutputSink, error) {
+	var err error
+	var u *url.URL
+	if strings.Contains(destination, "://") == false {
+		if err = os.MkdirAll(destination, 0755); err != nil {
+			return nil, err
+		}
+		return &FileSink{dir: destination}, err
+	}
+	if u, err = url.Parse(destination); err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, errors.New("missing bucket or container of " + destination)
+	}
+	client := &http.Client{Timeout: 5 * time.Minute}
+	prefix := strings.Trim(u.Path, "/")
+	switch u.Scheme {
+	case "s3":
+		sink := &S3Sink{accessKey: os.Getenv("AWS_ACCESS_KEY_ID"), bucket: u.Host, client: client,
+			endpoint: strings.TrimSuffix(os.Getenv("AWS_ENDPOINT_URL"), "/"), prefix: prefix, region: os.Getenv("AWS_REGION"),
+			secretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"), sessionToken: os.Getenv("AWS_SESSION_TOKEN")}
+		if sink.region == "" {
+			sink.region = "us-east-1"
+		}
+		if sink.accessKey == "" || sink.secretKey == "" {
+			return nil, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required")
+		}
+		return sink, err
+	case "gs":
+		sink := &GCSSink{bucket: u.Host, client: client, endpoint: "https://storage.googleapis.com", prefix: prefix,
+			token: os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")}
+		if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+			sink.endpoint = strings.TrimSuffix(host, "/")
+			if strings.Contains(sink.endpoint, "://") == false {
+				sink.endpoint = "http://" + sink.endpoint
+			}
+		} else if sink.token == "" {
+			return nil, errors.New("GOOGLE_OAUTH_ACCESS_TOKEN is required")
+		}
+		return sink, err
+	case "azblob":
+		account := os.Getenv("AZURE_STORAGE_ACCOUNT")
+		sink := &AzureBlobSink{client: client, container: u.Host, endpoint: "https://" + account + ".blob.core.windows.net",
+			prefix: prefix, sasToken: strings.TrimPrefix(os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "?")}
+		if account == "" || sink.sasToken == "" {
+			return nil, errors.New("AZURE_STORAGE_ACCOUNT and AZURE_STORAGE_SAS_TOKEN are required")
+		}
+		return sink, err
+	}
+	return nil, fmt.Errorf("unsupported destination scheme %v", u.Scheme)
+}
+
+// WriteFileToSink moves a local file to a sink with the base name of the file, the local file is removed once
+// written unless it is the file of the sink
+func WriteFileToSink(sink OutputSink, filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if err = sink.Write(filepath.Base(filename), data); err != nil {
+		return err
+	}
+	if fs, ok := sink.(*FileSink); ok == true {
+		src, _ := filepath.Abs(filename)
+		dst, _ := filepath.Abs(filepath.Join(fs.dir, filepath.Base(filename)))
+		if src == dst {
+			return nil
+		}
+	}
+	return os.Remove(filename)
+}
+
+// Write writes data to a file of the directory
+func (s *FileSink) Write(name string, data []byte) error {
+	return ioutil.WriteFile(filepath.Join(s.dir, filepath.Base(name)), data, 0644)
+}
+
+func (s *FileSink) String() string {
+	return s.dir
+}
+
+// Write puts an object of the prefix, signed with AWS signature version 4
+func (s *S3Sink) Write(name string, data []byte) error {
+	key := path.Join(s.prefix, filepath.Base(name))
+	uri := "/" + awsURIEncode(key)
+	host := s.bucket + ".s3." + s.region + ".amazonaws.com"
+	endpoint := "https://" + host
+	if s.endpoint != "" {
+		endpoint = s.endpoint
+		host = strings.TrimPrefix(strings.TrimPrefix(s.endpoint, "https://"), "http://")
+		uri = "/" + s.bucket + uri
+	}
+	req, err := http.NewRequest(http.MethodPut, endpoint+uri, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	s.sign(req, host, uri, data, time.Now().UTC())
+	return doSinkRequest(s.client, req)
+}
+
+func (s *S3Sink) String() string {
+	return "s3://" + s.bucket + "/" + s.prefix
+}
+
+// sign adds headers of AWS signature version 4 to a request
+func (s *S3Sink) sign(req *http.Request, host string, uri string, data []byte, t time.Time) {
+	amzDate := t.Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := getSHA256Hex(data)
+	headers := []string{"host:" + host, "x-amz-content-sha256:" + payloadHash, "x-amz-date:" + amzDate}
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	req.Header.Set("x-amz-date", amzDate)
+	if s.sessionToken != "" {
+		headers = append(headers, "x-amz-security-token:"+s.sessionToken)
+		signedHeaders += ";x-amz-security-token"
+		req.Header.Set("x-amz-security-token", s.sessionToken)
+	}
+	canonical := strings.Join([]string{req.Method, uri, "", strings.Join(headers, "\n") + "\n", signedHeaders, payloadHash}, "\n")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + getSHA256Hex([]byte(canonical))
+	key := []byte("AWS4" + s.secretKey)
+	for _, v := range []string{date, s.region, "s3", "aws4_request"} {
+		key = getHMACSHA256(key, v)
+	}
+	signature := hex.EncodeToString(getHMACSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%v/%v, SignedHeaders=%v, Signature=%v",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+// Write uploads an object of the prefix with a simple media upload
+func (s *GCSSink) Write(name string, data []byte) error {
+	object := path.Join(s.prefix, filepath.Base(name))
+	endpoint := fmt.Sprintf("%v/upload/storage/v1/b/%v/o?uploadType=media&name=%v", s.endpoint, url.PathEscape(s.bucket),
+		url.QueryEscape(object))
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	return doSinkRequest(s.client, req)
+}
+
+func (s *GCSSink) String() string {
+	return "gs://" + s.bucket + "/" + s.prefix
+}
+
+// Write puts a block blob of the prefix
+func (s *AzureBlobSink) Write(name string, data []byte) error {
+	blob := path.Join(s.prefix, filepath.Base(name))
+	endpoint := fmt.Sprintf("%v/%v/%v?%v", s.endpoint, url.PathEscape(s.container), awsURIEncode(blob), s.sasToken)
+	req, err := http.NewRequest(http.MethodPut, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+	req.Header.Set("x-ms-version", "2019-12-12")
+	return doSinkRequest(s.client, req)
+}
+
+func (s *AzureBlobSink) String() string {
+	return "azblob://" + s.container + "/" + s.prefix
+}
+
+// doSinkRequest sends a request and returns an error of a non 2xx status with the body of the response
+func doSinkRequest(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%v %v: %v %v", req.Method, req.URL.Host, resp.Status, string(body))
+	}
+	return nil
+}
+
+// awsURIEncode encodes a path of an object key, all but unreserved characters and / are escaped
+func awsURIEncode(key string) string {
+	var buffer bytes.Buffer
+	for _, b := range []byte(key) {
+		if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') || strings.IndexByte("-_.~/", b) >= 0 {
+			buffer.WriteByte(b)
+		} else {
+			buffer.WriteString(fmt.Sprintf("%%%02X", b))
+		}
+	}
+	return buffer.String()
+}
+
+func getSHA256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func getHMACSHA256(key []byte, value string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,114 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func getTestSinkServer(check func(w http.ResponseWriter, r *http.Request, body string)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		check(w, r, string(body))
+	}))
+}
+
+func TestFileSink(t *testing.T) {
+	dir := filepath.Join(os.TempDir(), "keyhole-sink-test")
+	defer os.RemoveAll(dir)
+	sink, err := NewOutputSink(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = sink.Write("../mongod.log.enc", []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(dir, "mongod.log.enc")); string(data) != "data" {
+		t.Fatal(string(data))
+	}
+
+	filename := filepath.Join(os.TempDir(), "keyhole-sink-test.json")
+	ioutil.WriteFile(filename, []byte("{}"), 0644)
+	if err = WriteFileToSink(sink, filename); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filename); os.IsNotExist(err) == false {
+		t.Fatal("expected the local file removed")
+	}
+	if err = WriteFileToSink(sink, filepath.Join(dir, "mongod.log.enc")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "mongod.log.enc")); err != nil {
+		t.Fatal("expected the file of the sink kept", err)
+	}
+}
+
+func TestS3Sink(t *testing.T) {
+	server := getTestSinkServer(func(w http.ResponseWriter, r *http.Request, body string) {
+		if r.Method != http.MethodPut || r.URL.Path != "/reports/daily/mongod.log.enc" || body != "data" {
+			t.Fatal(r.Method, r.URL.Path, body)
+		}
+		auth := r.Header.Get("Authorization")
+		if strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=key/") == false || r.Header.Get("x-amz-security-token") != "token" {
+			t.Fatal(auth)
+		}
+	})
+	defer server.Close()
+	sink := &S3Sink{accessKey: "key", bucket: "reports", client: server.Client(), endpoint: server.URL, prefix: "daily",
+		region: "us-east-1", secretKey: "secret", sessionToken: "token"}
+	if err := sink.Write("mongod.log.enc", []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGCSSink(t *testing.T) {
+	server := getTestSinkServer(func(w http.ResponseWriter, r *http.Request, body string) {
+		if r.URL.Path != "/upload/storage/v1/b/reports/o" || r.URL.Query().Get("name") != "daily/cluster.json" ||
+			r.Header.Get("Authorization") != "Bearer token" {
+			t.Fatal(r.URL.String())
+		}
+	})
+	defer server.Close()
+	sink := &GCSSink{bucket: "reports", client: server.Client(), endpoint: server.URL, prefix: "daily", token: "token"}
+	if err := sink.Write("cluster.json", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAzureBlobSink(t *testing.T) {
+	server := getTestSinkServer(func(w http.ResponseWriter, r *http.Request, body string) {
+		if r.URL.Path != "/reports/daily/cluster.json" || r.URL.Query().Get("sig") != "abc" ||
+			r.Header.Get("x-ms-blob-type") != "BlockBlob" {
+			t.Fatal(r.URL.String())
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+	defer server.Close()
+	sink := &AzureBlobSink{client: server.Client(), container: "reports", endpoint: server.URL, prefix: "daily", sasToken: "sv=2019&sig=abc"}
+	if err := sink.Write("cluster.json", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOutputSinkErrors(t *testing.T) {
+	server := getTestSinkServer(func(w http.ResponseWriter, r *http.Request, body string) {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+	})
+	defer server.Close()
+	sink := &GCSSink{bucket: "reports", client: server.Client(), endpoint: server.URL}
+	if err := sink.Write("cluster.json", []byte("{}")); err == nil || strings.Contains(err.Error(), "AccessDenied") == false {
+		t.Fatal(err)
+	}
+	if _, err := NewOutputSink("ftp://host/path"); err == nil {
+		t.Fatal("expected an error of unsupported scheme")
+	}
+	if str := awsURIEncode("a b/c$.enc"); str != "a%20b/c%24.enc" {
+		t.Fatal(str)
+	}
+}
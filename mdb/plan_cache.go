@@ -0,0 +1,239 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PlanCacheReader explains query shapes cached in plan caches of collections, without log files
+type PlanCacheReader struct {
+	client  *mongo.Client
+	dbName  string
+	explain bool
+	verbose bool
+}
+
+// PlanCacheDoc is a query shape from $planCacheStats
+type PlanCacheDoc struct {
+	Namespace    string   `json:"ns"`
+	QueryHash    string   `json:"queryHash"`
+	PlanCacheKey string   `json:"planCacheKey"`
+	Shape        string   `json:"shape"` // filter, sort, and projection the entry was created from
+	CachedPlan   string   `json:"cachedPlan"`
+	IsActive     bool     `json:"isActive"`
+	Works        int      `json:"works"`
+	Candidates   int      `json:"candidates"` // number of plans competed
+	Flags        []string `json:"flags"`
+	WinningPlan  string   `json:"winningPlan"` // by explain now
+	Error        string   `json:"error,omitempty"`
+}
+
+// planCacheEntry is an entry of $planCacheStats
+type planCacheEntry struct {
+	CreatedFromQuery    bson.RawValue `bson:"createdFromQuery"`
+	QueryHash           string        `bson:"queryHash"`
+	PlanCacheKey        string        `bson:"planCacheKey"`
+	IsActive            bool          `bson:"isActive"`
+	Works               int64         `bson:"works"`
+	CachedPlan          bson.D        `bson:"cachedPlan"`
+	CandidatePlanScores []float64     `bson:"candidatePlanScores"`
+}
+
+// NewPlanCacheReader returns a PlanCacheReader
+func NewPlanCacheReader(client *mongo.Client) *PlanCacheReader {
+	return &PlanCacheReader{client: client, explain: true}
+}
+
+// SetDBName sets database name, all databases if empty
+func (pc *PlanCacheReader) SetDBName(dbName string) {
+	pc.dbName = dbName
+}
+
+// SetExplain sets to explain cached query shapes, defaults to true
+func (pc *PlanCacheReader) SetExplain(explain bool) {
+	pc.explain = explain
+}
+
+// SetVerbose sets verbose level
+func (pc *PlanCacheReader) SetVerbose(verbose bool) {
+	pc.verbose = verbose
+}
+
+// GetPlanCaches returns query shapes of plan caches of collections (4.2+) and explains them
+func (pc *PlanCacheReader) GetPlanCaches() ([]PlanCacheDoc, error) {
+	var err error
+	var dbNames []string
+	ctx := context.Background()
+	docs := []PlanCacheDoc{}
+	if pc.dbName != "" {
+		dbNames = []string{pc.dbName}
+	} else if dbNames, err = ListDatabaseNamesContext(ctx, pc.client); err != nil {
+		return docs, err
+	}
+	for _, dbName := range dbNames {
+		if dbName == "admin" || dbName == "config" || dbName == "local" {
+			continue
+		}
+		var names []string
+		filter := bson.D{{Key: "type", Value: "collection"}}
+		if names, err = pc.client.Database(dbName).ListCollectionNames(ctx, filter); err != nil {
+			return docs, err
+		}
+		for _, name := range names {
+			if strings.HasPrefix(name, "system.") {
+				continue
+			}
+			list, e := pc.getPlanCache(ctx, pc.client.Database(dbName).Collection(name))
+			if e != nil { // e.g. $planCacheStats isn't supported before 4.2
+				if pc.verbose == true {
+					fmt.Println(dbName+"."+name, e)
+				}
+				continue
+			}
+			docs = append(docs, list...)
+		}
+	}
+	return docs, err
+}
+
+// getPlanCache returns query shapes of the plan cache of a collection
+func (pc *PlanCacheReader) getPlanCache(ctx context.Context, collection *mongo.Collection) ([]PlanCacheDoc, error) {
+	var err error
+	var cur *mongo.Cursor
+	ns := collection.Database().Name() + "." + collection.Name()
+	docs := []PlanCacheDoc{}
+	pipeline := []bson.D{{{Key: "$planCacheStats", Value: bson.D{}}}}
+	if cur, err = collection.Aggregate(ctx, pipeline); err != nil {
+		return docs, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var entry planCacheEntry
+		if err = cur.Decode(&entry); err != nil {
+			return docs, err
+		}
+		doc, explainCmd, ok := getPlanCacheDoc(ns, entry)
+		if pc.explain == true && ok == true {
+			qe := NewQueryExplainer(pc.client)
+			qe.SetVerbose(pc.verbose)
+			qe.NameSpace = ns
+			qe.ExplainCmd = explainCmd
+			if summary, e := qe.ExplainContext(ctx); e != nil {
+				doc.Error = e.Error()
+			} else {
+				doc.WinningPlan = getWinningPlanString(summary.ExecutionStats)
+			}
+		}
+		docs = append(docs, doc)
+	}
+	return docs, cur.Err()
+}
+
+// getPlanCacheDoc returns a query shape of an entry of $planCacheStats and a command to explain it, false if
+// the query isn't available, e.g. createdFromQuery is a string since 5.0
+func getPlanCacheDoc(ns string, entry planCacheEntry) (PlanCacheDoc, ExplainCommand, bool) {
+	doc := PlanCacheDoc{Namespace: ns, QueryHash: entry.QueryHash, PlanCacheKey: entry.PlanCacheKey, IsActive: entry.IsActive,
+		Works: int(entry.Works), Candidates: len(entry.CandidatePlanScores), Flags: []string{}}
+	doc.CachedPlan = strings.Join(getPlanStages(entry.CachedPlan), " > ")
+	if doc.Candidates > 1 {
+		doc.Flags = append(doc.Flags, fmt.Sprintf("%d competing plans", doc.Candidates))
+	}
+	if strings.Contains(" > "+doc.CachedPlan+" ", " SORT ") {
+		doc.Flags = append(doc.Flags, "blocking sort")
+	}
+	if entry.IsActive == false {
+		doc.Flags = append(doc.Flags, "inactive")
+	}
+	explainCmd := ExplainCommand{Collection: ns[strings.Index(ns, ".")+1:]}
+	var query struct {
+		Query      bson.D `bson:"query"`
+		Sort       bson.D `bson:"sort"`
+		Projection bson.D `bson:"projection"`
+	}
+	if entry.CreatedFromQuery.Type == bsontype.String {
+		doc.Shape = entry.CreatedFromQuery.StringValue()
+		return doc, explainCmd, false
+	} else if entry.CreatedFromQuery.Type != bsontype.EmbeddedDocument || entry.CreatedFromQuery.Unmarshal(&query) != nil {
+		return doc, explainCmd, false
+	}
+	doc.Shape = "filter: " + getShapeString(query.Query)
+	if len(query.Query) == 0 {
+		doc.Shape = "filter: {}"
+	}
+	if len(query.Sort) > 0 {
+		doc.Shape += ", sort: " + getShapeString(query.Sort)
+	}
+	if len(query.Projection) > 0 {
+		doc.Shape += ", projection: " + getShapeString(query.Projection)
+	}
+	explainCmd.Filter, explainCmd.Sort = query.Query, query.Sort
+	if explainCmd.Filter == nil {
+		explainCmd.Filter = bson.D{}
+	}
+	return doc, explainCmd, true
+}
+
+// getPlanStages returns stages of a plan and key patterns of index scans, e.g. FETCH, IXSCAN {a: 1}, of all
+// shards of a mongos
+func getPlanStages(plan bson.D) []string {
+	stages := []string{}
+	if len(plan) == 0 {
+		return stages
+	}
+	m := plan.Map()
+	stage := toString(m["stage"])
+	if keyPattern, ok := m["keyPattern"].(bson.D); ok == true {
+		stage += " " + getShapeString(keyPattern)
+	}
+	stages = append(stages, stage)
+	if input, ok := m["inputStage"].(bson.D); ok == true {
+		stages = append(stages, getPlanStages(input)...)
+	}
+	if inputs, ok := m["inputStages"].(primitive.A); ok == true {
+		for _, input := range toDocs(inputs) {
+			stages = append(stages, getPlanStages(input)...)
+		}
+	}
+	if shards, ok := m["shards"].(primitive.A); ok == true { // of a mongos
+		for _, shard := range toDocs(shards) {
+			if plan, ok := shard.Map()["winningPlan"].(bson.D); ok == true {
+				stages = append(stages, getPlanStages(plan)...)
+			}
+		}
+	}
+	return stages
+}
+
+// GetPlanCacheSummary returns cached query shapes, shapes with competing plans or blocking sorts are marked with x
+func GetPlanCacheSummary(docs []PlanCacheDoc) string {
+	var buffer bytes.Buffer
+	if len(docs) == 0 {
+		return "No cached query shapes found"
+	}
+	for _, doc := range docs {
+		mark := "  "
+		if len(doc.Flags) > 0 && (doc.Candidates > 1 || strings.Contains(strings.Join(doc.Flags, ","), "blocking sort")) {
+			mark = "x "
+		}
+		buffer.WriteString(fmt.Sprintf("%s%s queryHash: %s, planCacheKey: %s\n", mark, doc.Namespace, doc.QueryHash, doc.PlanCacheKey))
+		buffer.WriteString(fmt.Sprintf("\tshape: %s\n\tcached plan: %s, works: %d\n", doc.Shape, doc.CachedPlan, doc.Works))
+		if len(doc.Flags) > 0 {
+			buffer.WriteString("\tflags: " + strings.Join(doc.Flags, ", ") + "\n")
+		}
+		if doc.WinningPlan != "" {
+			buffer.WriteString("\twinning plan now: " + doc.WinningPlan + "\n")
+		} else if doc.Error != "" {
+			buffer.WriteString("\texplain: " + doc.Error + "\n")
+		}
+	}
+	return buffer.String()
+}
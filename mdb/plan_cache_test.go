@@ -0,0 +1,74 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func getPlanCacheEntry(t *testing.T, doc bson.D) planCacheEntry {
+	var entry planCacheEntry
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = bson.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+func TestGetPlanCacheDoc(t *testing.T) {
+	ixscan := bson.D{{Key: "stage", Value: "IXSCAN"}, {Key: "keyPattern", Value: bson.D{{Key: "color", Value: 1}}}}
+	plan := bson.D{{Key: "stage", Value: "SORT"}, {Key: "inputStage", Value: bson.D{{Key: "stage", Value: "FETCH"},
+		{Key: "inputStage", Value: ixscan}}}}
+	entry := getPlanCacheEntry(t, bson.D{
+		{Key: "createdFromQuery", Value: bson.D{{Key: "query", Value: bson.D{{Key: "color", Value: "Red"}}},
+			{Key: "sort", Value: bson.D{{Key: "year", Value: -1}}}, {Key: "projection", Value: bson.D{}}}},
+		{Key: "queryHash", Value: "8B8F1F6A"}, {Key: "planCacheKey", Value: "2C3A1E0D"}, {Key: "isActive", Value: true},
+		{Key: "works", Value: int64(12)}, {Key: "cachedPlan", Value: plan},
+		{Key: "candidatePlanScores", Value: bson.A{1.5003, 1.5002}}})
+	doc, explainCmd, ok := getPlanCacheDoc("keyhole.cars", entry)
+	t.Log(doc)
+	if ok == false || explainCmd.Collection != "cars" || len(explainCmd.Filter) != 1 || len(explainCmd.Sort) != 1 {
+		t.Fatal(explainCmd)
+	}
+	if doc.CachedPlan != `SORT > FETCH > IXSCAN {color: 1}` || doc.Works != 12 || doc.Candidates != 2 {
+		t.Fatal(doc.CachedPlan, doc.Works, doc.Candidates)
+	}
+	if strings.Join(doc.Flags, ",") != "2 competing plans,blocking sort" {
+		t.Fatal(doc.Flags)
+	}
+	if doc.Shape != `filter: {color: "Red"}, sort: {year: -1}` {
+		t.Fatal(doc.Shape)
+	}
+
+	entry = getPlanCacheEntry(t, bson.D{{Key: "createdFromQuery", Value: "query: { color: \"Red\" }"},
+		{Key: "isActive", Value: false}, {Key: "cachedPlan", Value: ixscan}})
+	if doc, _, ok = getPlanCacheDoc("keyhole.cars", entry); ok == true || strings.Join(doc.Flags, ",") != "inactive" {
+		t.Fatal(doc)
+	}
+}
+
+func TestGetPlanStages(t *testing.T) {
+	or := bson.D{{Key: "stage", Value: "OR"}, {Key: "inputStages", Value: bson.A{
+		bson.D{{Key: "stage", Value: "IXSCAN"}, {Key: "keyPattern", Value: bson.D{{Key: "a", Value: 1}}}},
+		bson.D{{Key: "stage", Value: "IXSCAN"}, {Key: "keyPattern", Value: bson.D{{Key: "b", Value: 1}}}}}}}
+	entry := getPlanCacheEntry(t, bson.D{{Key: "cachedPlan", Value: or}})
+	if stages := getPlanStages(entry.CachedPlan); strings.Join(stages, " > ") != "OR > IXSCAN {a: 1} > IXSCAN {b: 1}" {
+		t.Fatal(stages)
+	}
+}
+
+func TestGetPlanCacheSummary(t *testing.T) {
+	docs := []PlanCacheDoc{{Namespace: "keyhole.cars", Candidates: 2, Flags: []string{"2 competing plans"}},
+		{Namespace: "keyhole.dealers", Candidates: 1, Flags: []string{}}}
+	str := GetPlanCacheSummary(docs)
+	t.Log(str)
+	if strings.Contains(str, "x keyhole.cars") == false || strings.Contains(str, "x keyhole.dealers") == true {
+		t.Fatal(str)
+	}
+}
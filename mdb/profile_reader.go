@@ -0,0 +1,293 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ProfileReader reads system.profile and summarizes ops patterns as loginfo does
+type ProfileReader struct {
+	client  *mongo.Client
+	dbName  string
+	limit   int64
+	verbose bool
+}
+
+// defaultProfileLimit is number of the most recent system.profile documents read per database
+const defaultProfileLimit = 10000
+
+// NewProfileReader returns a ProfileReader
+func NewProfileReader(client *mongo.Client) *ProfileReader {
+	return &ProfileReader{client: client, limit: defaultProfileLimit}
+}
+
+// SetDBName sets database name, all databases if empty
+func (pr *ProfileReader) SetDBName(dbName string) {
+	pr.dbName = dbName
+}
+
+// SetLimit sets number of the most recent system.profile documents read per database
+func (pr *ProfileReader) SetLimit(limit int64) {
+	if limit > 0 {
+		pr.limit = limit
+	}
+}
+
+// SetVerbose sets verbose level
+func (pr *ProfileReader) SetVerbose(verbose bool) {
+	pr.verbose = verbose
+}
+
+// GetLogInfo reads system.profile and returns aggregated ops patterns
+func (pr *ProfileReader) GetLogInfo() (*LogInfo, error) {
+	return pr.GetLogInfoContext(context.Background())
+}
+
+// GetLogInfoContext reads system.profile and returns aggregated ops patterns, stops when ctx is done
+func (pr *ProfileReader) GetLogInfoContext(ctx context.Context) (*LogInfo, error) {
+	var err error
+	dbNames := []string{pr.dbName}
+	if pr.dbName == "" {
+		if dbNames, err = ListDatabaseNames(pr.client); err != nil {
+			return nil, err
+		}
+	}
+	li := &LogInfo{filename: "system.profile", silent: true, topSlowOps: defaultTopSlowOps, verbose: pr.verbose}
+	for _, dbName := range dbNames {
+		if dbName == "admin" || dbName == "config" || dbName == "local" {
+			continue
+		}
+		if err = pr.readProfile(ctx, li, dbName); err != nil {
+			return li, err
+		}
+	}
+	li.sortOpsPatterns()
+	return li, err
+}
+
+// Analyze reads system.profile and returns a summary
+func (pr *ProfileReader) Analyze() (string, error) {
+	li, err := pr.GetLogInfo()
+	if err != nil {
+		return "", err
+	}
+	return li.printLogsSummary(), err
+}
+
+func (pr *ProfileReader) readProfile(ctx context.Context, li *LogInfo, dbName string) error {
+	var err error
+	var cur *mongo.Cursor
+	collection := pr.client.Database(dbName).Collection("system.profile")
+	li.source = dbName + ".system.profile"
+	filter := bson.M{"ns": bson.M{"$ne": "local.oplog.rs", "$not": primitive.Regex{Pattern: `\.system\.`}},
+		"$or": []bson.M{{"command": bson.M{"$exists": true}}, {"query": bson.M{"$exists": true}}}}
+	opts := options.Find().SetSort(bson.D{{Key: "ts", Value: -1}}).SetLimit(pr.limit) // the most recent
+	if cur, err = collection.Find(ctx, filter, opts); err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var doc bson.D
+		if err = cur.Decode(&doc); err != nil {
+			continue
+		}
+		if stats, ok := getOpStatsFromProfile(doc); ok == true {
+			li.aggregate(stats)
+		}
+	}
+	return cur.Err()
+}
+
+// getOpStatsFromProfile converts a system.profile document to op stats
+func getOpStatsFromProfile(doc bson.D) (opStats, bool) {
+	m := doc.Map()
+	stats := opStats{appName: toString(m["appName"]), conn: toString(m["client"]), namespace: toString(m["ns"]), milli: toInt(m["millis"]),
+		keysExamined: toInt(m["keysExamined"]), docsExamined: toInt(m["docsExamined"]),
+		nreturned: toInt(m["nreturned"]), reslen: toInt(m["responseLength"])}
+	if stats.namespace == "local.oplog.rs" || strings.Contains(stats.namespace, ".system.") {
+		return stats, false
+	}
+	if ts, ok := m["ts"].(primitive.DateTime); ok == true {
+		stats.ts = time.Unix(0, int64(ts)*int64(time.Millisecond))
+	}
+	stats.writeConflicts = toInt(m["writeConflicts"])
+	stats.lockWaitMicros = getLockWaitMicrosFromDoc(m["locks"])
+	stats.replanned, _ = m["replanned"].(bool)
+	stats.fromMultiPlanner, _ = m["fromMultiPlanner"].(bool)
+	stats.hasSortStage, _ = m["hasSortStage"].(bool)
+	stats.usedDisk, _ = m["usedDisk"].(bool)
+	stats.queryHash = toString(m["queryHash"])
+	stats.planCacheKey = toString(m["planCacheKey"])
+	if stats.nreturned == 0 {
+		stats.nreturned = toInt(m["nMatched"]) + toInt(m["ndeleted"])
+	}
+	planSummary := toString(m["planSummary"])
+	if strings.HasPrefix(planSummary, COLLSCAN) {
+		stats.scan = COLLSCAN
+	} else if strings.HasPrefix(planSummary, "IXSCAN ") {
+		stats.index = strings.TrimPrefix(planSummary, "IXSCAN ")
+	} else if planSummary == "EOF" || planSummary == "IDHACK" || planSummary == "COUNT_SCAN" {
+		stats.index = planSummary
+	}
+
+	cmd, _ := m["command"].(bson.D)
+	if cmd == nil {
+		cmd, _ = m["query"].(bson.D) // legacy profile format
+	}
+	if len(cmd) == 0 {
+		return stats, false
+	}
+	cm := cmd.Map()
+	stats.command = cmd[0].Key
+	var filter, sortDoc bson.D
+	switch stats.command {
+	case "find":
+		filter, _ = cm["filter"].(bson.D)
+		sortDoc, _ = cm["sort"].(bson.D)
+	case "count", "distinct":
+		filter, _ = cm["query"].(bson.D)
+	case "findAndModify", "findandmodify":
+		stats.command = "findAndModify"
+		filter, _ = cm["query"].(bson.D)
+	case "update", "delete":
+		key := "updates"
+		if stats.command == "delete" {
+			key = "deletes"
+		}
+		if stmts, ok := cm[key].(primitive.A); ok == true && len(stmts) > 0 {
+			if stmt, ok := stmts[0].(bson.D); ok == true {
+				filter, _ = stmt.Map()["q"].(bson.D)
+			}
+		}
+	case "q": // update and remove ops are profiled as statements
+		stats.command = toString(m["op"])
+		filter, _ = cm["q"].(bson.D)
+	case "aggregate":
+		if stages, ok := cm["pipeline"].(primitive.A); ok == true && len(stages) > 0 {
+			if stage, ok := stages[0].(bson.D); ok == true && len(stage) > 0 {
+				if stage[0].Key == "$match" {
+					filter, _ = stage[0].Value.(bson.D)
+				} else if stage[0].Key == "$sort" {
+					sortDoc, _ = stage[0].Value.(bson.D)
+				}
+			}
+		}
+		if filter == nil && sortDoc == nil && stats.scan != COLLSCAN {
+			return stats, false
+		}
+	default:
+		return stats, false
+	}
+
+	stats.filter = getQueryPattern(filter)
+	stats.statement = stats.command + " " + getShapeString(cmd)
+	if stats.command == "find" && len(sortDoc) > 0 {
+		stats.sort = getSortPattern(sortDoc)
+	}
+	stats.log = fmt.Sprintf("%v %v %v %vms", stats.command, stats.namespace, stats.filter, stats.milli)
+	return stats, true
+}
+
+// getLockWaitMicrosFromDoc returns total timeAcquiringMicros of a profile locks document
+func getLockWaitMicrosFromDoc(locks interface{}) int {
+	total := 0
+	doc, ok := locks.(bson.D)
+	if ok == false {
+		return total
+	}
+	for _, lock := range doc {
+		if ldoc, ok := lock.Value.(bson.D); ok == true {
+			if micros, ok := ldoc.Map()["timeAcquiringMicros"].(bson.D); ok == true {
+				for _, elem := range micros {
+					total += toInt(elem.Value)
+				}
+			}
+		}
+	}
+	return total
+}
+
+// getSortPattern returns a sort pattern of directions in order, e.g. {year: -1, color: 1}
+func getSortPattern(sortDoc bson.D) string {
+	strs := []string{}
+	for _, elem := range sortDoc {
+		if _, ok := elem.Value.(bson.D); ok { // {$meta: "textScore"}
+			strs = append(strs, elem.Key+": "+getShapeString(elem.Value))
+		} else if toInt(elem.Value) < 0 {
+			strs = append(strs, elem.Key+": -1")
+		} else {
+			strs = append(strs, elem.Key+": 1")
+		}
+	}
+	return "{" + strings.Join(strs, ", ") + "}"
+}
+
+// getQueryPattern returns a query pattern of a filter, e.g. {a: 1, b: {$gt: 1}}
+func getQueryPattern(filter bson.D) string {
+	keys := make([]string, 0, len(filter))
+	values := map[string]string{}
+	for _, elem := range filter {
+		keys = append(keys, elem.Key)
+		values[elem.Key] = getPatternValue(elem.Key, elem.Value)
+	}
+	sort.Strings(keys)
+	strs := []string{}
+	for _, k := range keys {
+		strs = append(strs, k+": "+values[k])
+	}
+	return "{" + strings.Join(strs, ", ") + "}"
+}
+
+func getPatternValue(key string, value interface{}) string {
+	switch v := value.(type) {
+	case bson.D:
+		return getQueryPattern(v)
+	case primitive.A:
+		if key == "$in" || key == "$nin" || key == "$all" {
+			return "[... ]"
+		}
+		strs := []string{}
+		for _, elem := range v {
+			strs = append(strs, getPatternValue("", elem))
+		}
+		if key == "$and" || key == "$or" || key == "$nor" { // order of clauses doesn't matter
+			sort.Strings(strs)
+		}
+		return "[" + strings.Join(strs, ", ") + "]"
+	case primitive.Regex:
+		return "/regex/"
+	default:
+		return "1"
+	}
+}
+
+func toString(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func toInt(value interface{}) int {
+	switch v := value.(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
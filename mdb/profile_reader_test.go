@@ -0,0 +1,72 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestGetQueryPattern(t *testing.T) {
+	filter := bson.D{{Key: "year", Value: bson.D{{Key: "$gt", Value: 2010}}}, {Key: "color", Value: "Red"},
+		{Key: "brand", Value: bson.D{{Key: "$in", Value: primitive.A{"BMW", "Audi"}}}}}
+	pattern := getQueryPattern(filter)
+	if pattern != "{brand: {$in: [... ]}, color: 1, year: {$gt: 1}}" {
+		t.Fatal(pattern)
+	}
+}
+
+func TestGetOpStatsFromProfile(t *testing.T) {
+	doc := bson.D{{Key: "op", Value: "query"}, {Key: "ns", Value: "keyhole.cars"},
+		{Key: "command", Value: bson.D{{Key: "find", Value: "cars"},
+			{Key: "filter", Value: bson.D{{Key: "color", Value: "Red"}, {Key: "year", Value: bson.D{{Key: "$gt", Value: 2010}}}}},
+			{Key: "sort", Value: bson.D{{Key: "brand", Value: 1}}}}},
+		{Key: "keysExamined", Value: int32(1200)}, {Key: "docsExamined", Value: int32(1200)},
+		{Key: "nreturned", Value: int32(12)}, {Key: "responseLength", Value: int32(4321)},
+		{Key: "millis", Value: int32(120)}, {Key: "planSummary", Value: "IXSCAN { color: 1 }"}}
+	stats, ok := getOpStatsFromProfile(doc)
+	if ok == false {
+		t.Fatal("expected a find op")
+	}
+	if stats.command != "find" || stats.filter != "{color: 1, year: {$gt: 1}}" || stats.sort != "{brand: 1}" || stats.index != "{ color: 1 }" {
+		t.Fatal(stats.command, stats.filter, stats.index)
+	}
+	if stats.milli != 120 || stats.keysExamined != 1200 || stats.nreturned != 12 || stats.reslen != 4321 {
+		t.Fatal(stats)
+	}
+
+	li := &LogInfo{}
+	li.aggregate(stats)
+	li.aggregate(stats)
+	li.sortOpsPatterns()
+	if len(li.OpsPatterns) != 1 || li.OpsPatterns[0].Count != 2 || li.OpsPatterns[0].Histogram.Count != 2 {
+		t.Fatal(li.OpsPatterns)
+	}
+
+	doc = bson.D{{Key: "op", Value: "command"}, {Key: "ns", Value: "keyhole.cars"}, {Key: "command", Value: bson.D{}}}
+	if _, ok = getOpStatsFromProfile(doc); ok == true {
+		t.Fatal("expected an empty command to be skipped")
+	}
+}
+
+func TestProfileReader(t *testing.T) {
+	client := getMongoClient()
+	defer client.Disconnect(context.Background())
+	pr := NewProfileReader(client)
+	pr.SetDBName("keyhole")
+	if _, err := pr.Analyze(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetLockWaitMicrosFromDoc(t *testing.T) {
+	locks := bson.D{{Key: "Global", Value: bson.D{{Key: "acquireCount", Value: bson.D{{Key: "r", Value: int64(3)}}},
+		{Key: "timeAcquiringMicros", Value: bson.D{{Key: "r", Value: int64(100)}, {Key: "w", Value: int64(20)}}}}},
+		{Key: "Collection", Value: bson.D{{Key: "timeAcquiringMicros", Value: bson.D{{Key: "w", Value: int64(5)}}}}}}
+	if micros := getLockWaitMicrosFromDoc(locks); micros != 125 {
+		t.Fatal("expected 125, but got", micros)
+	}
+}
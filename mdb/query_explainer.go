@@ -38,6 +38,8 @@ type ExplainCommand struct {
 	Collection string `bson:"find"`
 	Filter     bson.D `bson:"filter"`
 	Sort       bson.D `bson:"sort,omitempty"`
+	Projection bson.D `bson:"projection,omitempty"`
+	Collation  bson.D `bson:"collation,omitempty"`
 	Hint       bson.D `bson:"hint,omitempty"`
 	Group      string `bson:"group,omitempty"`
 }
@@ -64,9 +66,15 @@ type StageStats struct {
 
 // ExplainSummary stores explain summary
 type ExplainSummary struct {
-	ShardName              string       `json:"shardName"`
-	ExecutionStats         StageStats   `json:"executionStats"`
-	AllPlansExecutionStats []StageStats `json:"allPlansExecution"`
+	ShardName              string         `json:"shardName"`
+	ExecutionStats         StageStats     `json:"executionStats"`
+	AllPlansExecutionStats []StageStats   `json:"allPlansExecution"`
+	MongosStage            string         `json:"mongosStage,omitempty"` // e.g. SINGLE_SHARD, SHARD_MERGE, SHARD_MERGE_SORT
+	Shards                 []ShardExplain `json:"shards,omitempty"`
+	TotalShards            int            `json:"totalShards,omitempty"`
+	IsScatterGather        bool           `json:"scatterGather"`
+	IsCovered              bool           `json:"covered"`
+	CollationMismatch      string         `json:"collationMismatch,omitempty"`
 }
 
 // IndexScore keeps index score
@@ -92,6 +100,11 @@ func (qe *QueryExplainer) GetFilter() bson.D {
 
 // Explain explains query plans
 func (qe *QueryExplainer) Explain() (ExplainSummary, error) {
+	return qe.ExplainContext(context.Background())
+}
+
+// ExplainContext explains query plans, stops when ctx is done
+func (qe *QueryExplainer) ExplainContext(ctx context.Context) (ExplainSummary, error) {
 	var err error
 	var command bson.D
 	o := QueryExplainer{}
@@ -101,7 +114,7 @@ func (qe *QueryExplainer) Explain() (ExplainSummary, error) {
 	b, _ = bson.Marshal(o)
 	bson.Unmarshal(b, &command)
 	db := strings.Split(qe.NameSpace, ".")[0]
-	if err = qe.client.Database(db).RunCommand(context.Background(), command).Decode(&qe.document); err != nil {
+	if err = qe.client.Database(db).RunCommand(ctx, command).Decode(&qe.document); err != nil {
 		return ExplainSummary{}, err
 	}
 	doc := qe.document.Map()
@@ -109,10 +122,20 @@ func (qe *QueryExplainer) Explain() (ExplainSummary, error) {
 	if winStage == "EOF" {
 		return ExplainSummary{}, errors.New("no data found to be explained")
 	} else if winStage == "COLLSCAN" {
+		if len(qe.ExplainCmd.Collation) > 0 {
+			return ExplainSummary{}, fmt.Errorf("no index selected (COLLSCAN), no index of collation %v", getShapeString(qe.ExplainCmd.Collation))
+		}
 		return ExplainSummary{}, errors.New("no index selected (COLLSCAN)")
 	}
 
-	return qe.GetExplainDetails(doc), err
+	summary := qe.GetExplainDetails(doc)
+	if qe.isSharded == true {
+		setShardsTargeted(&summary, qe.getShardsCount(ctx))
+	}
+	summary.IsCovered = isCoveredPlan(summary.ExecutionStats)
+	winningPlan, _ := doc["queryPlanner"].(bson.D).Map()["winningPlan"].(bson.D)
+	summary.CollationMismatch = qe.getCollationMismatch(ctx, getPlanIndexNames(winningPlan))
+	return summary, err
 }
 
 // GetExplainDetails returns summary from a doc
@@ -124,6 +147,10 @@ func (qe *QueryExplainer) GetExplainDetails(doc bson.M) ExplainSummary {
 	}
 	summary.ExecutionStats = qe.getStageStats(doc["executionStats"].(bson.D))
 	summary.AllPlansExecutionStats = []StageStats{}
+	if qe.isSharded == true {
+		summary.MongosStage, summary.Shards = getShardsExplain(doc["executionStats"].(bson.D))
+		setShardsTargeted(&summary, 0)
+	}
 
 	allPlansExecution := doc["executionStats"].(bson.D).Map()["allPlansExecution"].(primitive.A)
 	// pick a shard to evaluate if a sharded cluster
@@ -159,16 +186,26 @@ func (qe *QueryExplainer) GetSummary(summary ExplainSummary) string {
 		buffer.WriteString("Cluster: Replica Set\n")
 	} else {
 		buffer.WriteString("Cluster: Sharded, evaluated from shard " + summary.ShardName + "\n")
+		if summary.IsScatterGather {
+			buffer.WriteString(fmt.Sprintf("Scatter-gather: %d shards targeted\n", len(summary.Shards)))
+		}
 	}
 	b, _ := bson.Marshal(qe.ExplainCmd)
 	var qshape bson.M
 	bson.Unmarshal(b, &qshape)
 	delete(qshape, "find")
 	buffer.WriteString("Query Shape:\n" + gox.Stringify(qshape, "", "  ") + "\n")
+	if note := getCoveredQueryNote(qe.ExplainCmd, summary); note != "" {
+		buffer.WriteString("Covered Query: " + note + "\n")
+	}
+	if summary.CollationMismatch != "" {
+		buffer.WriteString("Collation Mismatch: " + summary.CollationMismatch + "\n")
+	}
 	buffer.WriteString("\n=> Execution Stats\n")
 	buffer.WriteString("=========================================\n")
 	buffer.WriteString("Winning Plan:\n")
 	buffer.WriteString(getStageStatsSummaryString(summary.ExecutionStats, 1))
+	buffer.WriteString(getShardsSummary(summary))
 
 	if len(summary.AllPlansExecutionStats) > 0 {
 		buffer.WriteString("\n=> All Plans Execution\n")
@@ -386,9 +423,13 @@ func (qe *QueryExplainer) ReadQueryShape(buffer []byte) error {
 		if doc.Map()["hint"] != nil {
 			explainCmd.Hint = doc.Map()["hint"].(bson.D)
 		}
+		explainCmd.Projection, _ = doc.Map()["projection"].(bson.D)
+		explainCmd.Collation, _ = doc.Map()["collation"].(bson.D)
 		ns = doc.Map()["ns"].(string)
 		pos := strings.Index(ns, ".")
 		explainCmd.Collection = ns[pos+1:]
+		qe.ExplainCmd = explainCmd
+		qe.NameSpace = ns
 		return err
 	}
 	err = nil
@@ -429,6 +470,12 @@ func (qe *QueryExplainer) ReadQueryShape(buffer []byte) error {
 		sort = ml.Get(`"$sort":`)
 	}
 	bson.UnmarshalExtJSON([]byte(sort), true, &(explainCmd.Sort))
+	if projection := ml.Get(`"projection":`); projection != "" {
+		bson.UnmarshalExtJSON([]byte(projection), true, &(explainCmd.Projection))
+	}
+	if collation := ml.Get(`"collation":`); collation != "" {
+		bson.UnmarshalExtJSON([]byte(collation), true, &(explainCmd.Collation))
+	}
 	xs := string(buffer)
 	i := strings.Index(xs, "] ")
 	ns = strings.Split(xs[i+2:], " ")[1]
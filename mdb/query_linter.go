@@ -0,0 +1,302 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// anti-patterns of queries
+const (
+	LintCaseInsensitiveRegex = "case-insensitive-regex"
+	LintNegationHeavy        = "negation-heavy"
+	LintNegationOnly         = "negation-only"
+	LintUnanchoredRegex      = "unanchored-regex"
+	LintUnindexedSort        = "unindexed-sort"
+	LintWhere                = "where"
+)
+
+// QueryLinter flags anti-patterns of query shapes from logs or a file, indexes of --uri are checked if a client
+// is given
+type QueryLinter struct {
+	client  *mongo.Client
+	indexes map[string][]lintIndex // cached by namespaces
+	verbose bool
+}
+
+// LintFinding is an anti-pattern found in a query shape
+type LintFinding struct {
+	Namespace string `json:"ns"`
+	Pattern   string `json:"pattern"`
+	Rule      string `json:"rule"`
+	Message   string `json:"message"`
+	Count     int    `json:"count"` // number of ops of the pattern from logs, 0 of a file
+}
+
+// lintIndex is keys and collation of an index
+type lintIndex struct {
+	Key       bson.D `bson:"key"`
+	Collation bson.D `bson:"collation"`
+}
+
+// lintPredicates are predicates collected from a filter
+type lintPredicates struct {
+	fields    int
+	negations int
+	regexes   map[string]primitive.Regex // by fields
+	where     bool
+}
+
+// NewQueryLinter returns a QueryLinter, client can be nil to skip checks of indexes
+func NewQueryLinter(client *mongo.Client) *QueryLinter {
+	return &QueryLinter{client: client, indexes: map[string][]lintIndex{}}
+}
+
+// SetVerbose sets verbose level
+func (ql *QueryLinter) SetVerbose(verbose bool) {
+	ql.verbose = verbose
+}
+
+// LintQueryShapes flags anti-patterns of query shapes of a file
+func (ql *QueryLinter) LintQueryShapes(shapes []QueryShape) []LintFinding {
+	findings := []LintFinding{}
+	for _, shape := range shapes {
+		findings = append(findings, ql.lint(shape.Namespace, shape.Filter, shape.Sort, 0)...)
+	}
+	return findings
+}
+
+// LintOpsPatterns flags anti-patterns of ops patterns of logs, example statements are linted if retained
+// because regex literals are redacted from query patterns
+func (ql *QueryLinter) LintOpsPatterns(docs []OpPerformanceDoc) []LintFinding {
+	findings := []LintFinding{}
+	for _, doc := range docs {
+		explainCmd, ok := getExplainCommand(doc)
+		if ok == false {
+			continue
+		}
+		if len(explainCmd.Sort) == 0 && doc.Sort != "" {
+			sortDoc, _ := parseShellDoc(doc.Sort)
+			explainCmd.Sort, _ = toBSONValue(sortDoc).(bson.D)
+		}
+		findings = append(findings, ql.lint(doc.Namespace, explainCmd.Filter, explainCmd.Sort, doc.Count)...)
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Count > findings[j].Count })
+	return findings
+}
+
+// lint returns anti-patterns of a filter and a sort of a namespace
+func (ql *QueryLinter) lint(namespace string, filter bson.D, sortDoc bson.D, count int) []LintFinding {
+	pattern := getShapeString(filter)
+	if len(sortDoc) > 0 {
+		pattern += ", sort: " + getShapeString(sortDoc)
+	}
+	indexes, known := ql.getIndexes(namespace)
+	findings := []LintFinding{}
+	for _, f := range lintFilter(filter, sortDoc, indexes, known) {
+		f.Namespace, f.Pattern, f.Count = namespace, pattern, count
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// getIndexes returns indexes of a namespace, false if unknown
+func (ql *QueryLinter) getIndexes(namespace string) ([]lintIndex, bool) {
+	if ql.client == nil {
+		return nil, false
+	}
+	if indexes, ok := ql.indexes[namespace]; ok == true {
+		return indexes, true
+	}
+	pos := strings.Index(namespace, ".")
+	if pos < 0 {
+		return nil, false
+	}
+	ctx := context.Background()
+	cur, err := ql.client.Database(namespace[:pos]).Collection(namespace[pos+1:]).Indexes().List(ctx)
+	if err != nil {
+		if ql.verbose == true {
+			fmt.Println(namespace, err)
+		}
+		return nil, false
+	}
+	defer cur.Close(ctx)
+	indexes := []lintIndex{}
+	for cur.Next(ctx) {
+		var index lintIndex
+		if cur.Decode(&index) == nil {
+			indexes = append(indexes, index)
+		}
+	}
+	ql.indexes[namespace] = indexes
+	return indexes, true
+}
+
+// lintFilter returns anti-patterns of a filter and a sort, checks of indexes are skipped if indexes aren't known
+func lintFilter(filter bson.D, sortDoc bson.D, indexes []lintIndex, known bool) []LintFinding {
+	findings := []LintFinding{}
+	p := lintPredicates{regexes: map[string]primitive.Regex{}}
+	collectLintPredicates(filter, &p, false)
+	if p.where == true {
+		findings = append(findings, LintFinding{Rule: LintWhere, Message: "$where runs JavaScript on every document and can't use indexes"})
+	}
+	if p.fields > 0 && p.negations == p.fields {
+		findings = append(findings, LintFinding{Rule: LintNegationOnly,
+			Message: "$ne, $nin, or $not as the only predicates match most documents and can't use index bounds selectively"})
+	} else if p.fields >= 2 && p.negations*2 > p.fields {
+		findings = append(findings, LintFinding{Rule: LintNegationHeavy,
+			Message: fmt.Sprintf("%d of %d predicates are negations, which are unselective", p.negations, p.fields)})
+	}
+	fields := make([]string, 0, len(p.regexes))
+	for field := range p.regexes {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		re := p.regexes[field]
+		if re.Pattern != "regex" && re.Pattern != "..." && strings.HasPrefix(re.Pattern, "^") == false &&
+			strings.HasPrefix(re.Pattern, `\A`) == false { // regex literals of query patterns are redacted
+			findings = append(findings, LintFinding{Rule: LintUnanchoredRegex,
+				Message: fmt.Sprintf("regex /%v/ of %v isn't anchored with ^ and scans all index keys", re.Pattern, field)})
+		}
+		if strings.Contains(re.Options, "i") && (known == false || hasCaseInsensitiveIndex(indexes, field) == false) {
+			findings = append(findings, LintFinding{Rule: LintCaseInsensitiveRegex,
+				Message: fmt.Sprintf("case-insensitive regex of %v, query an index of a collation of strength 1 or 2 instead", field)})
+		}
+	}
+	if known == true && len(sortDoc) > 0 && isSortIndexed(indexes, sortDoc) == false {
+		findings = append(findings, LintFinding{Rule: LintUnindexedSort,
+			Message: fmt.Sprintf("no index supports sort %v, documents are sorted in memory", getShapeString(sortDoc))})
+	}
+	return findings
+}
+
+// collectLintPredicates counts predicates and negations of fields and collects regexes of a filter
+func collectLintPredicates(filter bson.D, p *lintPredicates, negated bool) {
+	for _, elem := range filter {
+		switch elem.Key {
+		case "$where":
+			p.where = true
+			continue
+		case "$and", "$or", "$nor":
+			list, _ := elem.Value.(primitive.A)
+			for _, doc := range toDocs(list) {
+				collectLintPredicates(doc, p, negated || elem.Key == "$nor")
+			}
+			continue
+		}
+		if strings.HasPrefix(elem.Key, "$") {
+			continue // e.g. $expr, $text
+		}
+		p.fields++
+		if negated == true || isNegation(elem.Value) == true {
+			p.negations++
+		}
+		if re, ok := getLintRegex(elem.Value); ok == true {
+			p.regexes[elem.Key] = re
+		}
+	}
+}
+
+// isNegation returns true if a condition of a field is $ne, $nin, or $not
+func isNegation(value interface{}) bool {
+	doc, ok := value.(bson.D)
+	if ok == false {
+		return false
+	}
+	for _, elem := range doc {
+		if elem.Key == "$ne" || elem.Key == "$nin" || elem.Key == "$not" {
+			return true
+		}
+	}
+	return false
+}
+
+// getLintRegex returns a regex of a condition, /pattern/options or {$regex: pattern, $options: options}
+func getLintRegex(value interface{}) (primitive.Regex, bool) {
+	switch v := value.(type) {
+	case primitive.Regex:
+		return v, true
+	case bson.D:
+		m := v.Map()
+		switch re := m["$regex"].(type) {
+		case primitive.Regex:
+			if options, ok := m["$options"].(string); ok == true {
+				re.Options += options
+			}
+			return re, true
+		case string:
+			return primitive.Regex{Pattern: re, Options: toString(m["$options"])}, true
+		}
+	}
+	return primitive.Regex{}, false
+}
+
+// hasCaseInsensitiveIndex returns true if an index of a field has a collation of strength 1 or 2
+func hasCaseInsensitiveIndex(indexes []lintIndex, field string) bool {
+	for _, index := range indexes {
+		if len(index.Key) == 0 || index.Key[0].Key != field || len(index.Collation) == 0 {
+			continue
+		}
+		if strength := toInt(index.Collation.Map()["strength"]); strength == 1 || strength == 2 {
+			return true
+		}
+	}
+	return false
+}
+
+// isSortIndexed returns true if fields of a sort are in the same order and directions, or all reversed, in
+// an index
+func isSortIndexed(indexes []lintIndex, sortDoc bson.D) bool {
+	for _, index := range indexes {
+		for start := range index.Key {
+			if start+len(sortDoc) > len(index.Key) {
+				break
+			}
+			forward, backward := true, true
+			for i, elem := range sortDoc {
+				key := index.Key[start+i]
+				if key.Key != elem.Key {
+					forward, backward = false, false
+					break
+				}
+				same := (toInt(key.Value) < 0) == (toInt(elem.Value) < 0)
+				forward = forward && same
+				backward = backward && same == false
+			}
+			if forward || backward {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetLintFindingsSummary returns anti-patterns found grouped by query shapes
+func GetLintFindingsSummary(findings []LintFinding) string {
+	var buffer bytes.Buffer
+	if len(findings) == 0 {
+		return "No query anti-patterns found"
+	}
+	last := ""
+	for _, f := range findings {
+		if key := f.Namespace + " " + f.Pattern; key != last {
+			last = key
+			buffer.WriteString(key)
+			if f.Count > 0 {
+				buffer.WriteString(fmt.Sprintf(" (%d ops)", f.Count))
+			}
+			buffer.WriteString("\n")
+		}
+		buffer.WriteString(fmt.Sprintf("\t[%v] %v\n", f.Rule, f.Message))
+	}
+	return buffer.String()
+}
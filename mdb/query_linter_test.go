@@ -0,0 +1,64 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func getLintRules(findings []LintFinding) string {
+	rules := []string{}
+	for _, f := range findings {
+		rules = append(rules, f.Rule)
+	}
+	return strings.Join(rules, ",")
+}
+
+func TestLintFilter(t *testing.T) {
+	shapes, err := parseQueryShapes([]byte(`[
+  {"ns": "keyhole.cars", "filter": {"color": {"$regex": "red", "$options": "i"}}},
+  {"ns": "keyhole.cars", "filter": {"color": {"$ne": "Red"}}},
+  {"ns": "keyhole.cars", "filter": {"color": {"$nin": ["Red"]}, "style": {"$not": {"$eq": "SUV"}}, "year": 2019}},
+  {"ns": "keyhole.cars", "filter": {"$where": "this.year > 2015", "color": {"$regex": "^Red"}}},
+  {"ns": "keyhole.cars", "filter": {"color": "Red"}, "sort": {"year": -1}}
+]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"unanchored-regex,case-insensitive-regex", "negation-only", "negation-heavy", "where", ""}
+	for i, shape := range shapes {
+		if rules := getLintRules(lintFilter(shape.Filter, shape.Sort, nil, false)); rules != expected[i] {
+			t.Fatal(i, rules)
+		}
+	}
+	indexes := []lintIndex{{Key: bson.D{{Key: "color", Value: int32(1)}, {Key: "year", Value: int32(1)}}}}
+	if rules := getLintRules(lintFilter(shapes[4].Filter, shapes[4].Sort, indexes, true)); rules != "" {
+		t.Fatal(rules) // scanned backward
+	}
+	indexes = []lintIndex{{Key: bson.D{{Key: "color", Value: int32(1)}}}}
+	if rules := getLintRules(lintFilter(shapes[4].Filter, shapes[4].Sort, indexes, true)); rules != LintUnindexedSort {
+		t.Fatal(rules)
+	}
+	indexes = []lintIndex{{Key: bson.D{{Key: "color", Value: int32(1)}}, Collation: bson.D{{Key: "locale", Value: "en"}, {Key: "strength", Value: int32(2)}}}}
+	if rules := getLintRules(lintFilter(shapes[0].Filter, nil, indexes, true)); rules != LintUnanchoredRegex {
+		t.Fatal(rules)
+	}
+}
+
+func TestLintOpsPatterns(t *testing.T) {
+	docs := []OpPerformanceDoc{{Command: "find", Namespace: "keyhole.cars", Filter: `{ color: /regex/i }`, Count: 5},
+		{Command: "find", Namespace: "keyhole.cars", Filter: `{ color: 1 }`, Count: 10,
+			Examples: []ExampleDoc{{Statement: `find { find: "cars", filter: { color: { $ne: "Red" } } }`}}}}
+	findings := NewQueryLinter(nil).LintOpsPatterns(docs)
+	if rules := getLintRules(findings); rules != "negation-only,case-insensitive-regex" {
+		t.Fatal(rules)
+	}
+	str := GetLintFindingsSummary(findings)
+	t.Log(str)
+	if strings.Contains(str, "(10 ops)") == false {
+		t.Fatal(str)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"errors"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// ParseReadPreference returns a read preference of a mode, e.g. secondaryPreferred, and tags of comma separated
+// name:value pairs, e.g. nodeType:ANALYTICS,region:US_EAST_1, primary if mode is empty
+func ParseReadPreference(mode string, tags string) (*readpref.ReadPref, error) {
+	var err error
+	var m readpref.Mode
+	if mode == "" {
+		mode = "primary"
+	}
+	if m, err = readpref.ModeFromString(mode); err != nil {
+		return nil, err
+	}
+	pairs := []string{}
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag == "" {
+			continue
+		}
+		idx := strings.Index(tag, ":")
+		if idx <= 0 {
+			return nil, errors.New("expected name:value of a tag, but got " + tag)
+		}
+		pairs = append(pairs, strings.TrimSpace(tag[:idx]), strings.TrimSpace(tag[idx+1:]))
+	}
+	if len(pairs) == 0 {
+		return readpref.New(m)
+	} else if m == readpref.PrimaryMode {
+		return nil, errors.New("tags are not allowed with primary read preference")
+	}
+	return readpref.New(m, readpref.WithTags(pairs...))
+}
+
+// IsMemberReadPreference returns true if a read preference reads a member other than the primary, e.g. $indexStats
+// of a secondary counts only accesses of the secondary
+func IsMemberReadPreference(rp *readpref.ReadPref) bool {
+	return rp != nil && rp.Mode() != readpref.PrimaryMode
+}
+
+// getDatabaseOptions returns options of a database of a read preference, the client's if nil
+func getDatabaseOptions(rp *readpref.ReadPref) *options.DatabaseOptions {
+	opts := options.Database()
+	if rp != nil {
+		opts.SetReadPreference(rp)
+	}
+	return opts
+}
+
+// getRunCmdOptions returns options of a command of a read preference, primary if nil
+func getRunCmdOptions(rp *readpref.ReadPref) *options.RunCmdOptions {
+	opts := options.RunCmd()
+	if rp != nil {
+		opts.SetReadPreference(rp)
+	}
+	return opts
+}
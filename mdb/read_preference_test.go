@@ -0,0 +1,30 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func TestParseReadPreference(t *testing.T) {
+	rp, err := ParseReadPreference("", "")
+	if err != nil || rp.Mode() != readpref.PrimaryMode {
+		t.Fatal(err, rp)
+	}
+	if rp, err = ParseReadPreference("secondaryPreferred", "nodeType:ANALYTICS, region:US_EAST_1"); err != nil {
+		t.Fatal(err)
+	}
+	if rp.Mode() != readpref.SecondaryPreferredMode || len(rp.TagSets()) != 1 || len(rp.TagSets()[0]) != 2 {
+		t.Fatal(rp.TagSets())
+	}
+	if tag := rp.TagSets()[0][0]; tag.Name != "nodeType" || tag.Value != "ANALYTICS" {
+		t.Fatal(tag)
+	}
+	for _, v := range [][]string{{"secondary", "nodeType"}, {"primary", "nodeType:ANALYTICS"}, {"analytics", ""}} {
+		if _, err = ParseReadPreference(v[0], v[1]); err == nil {
+			t.Fatal("expected an error of", v)
+		}
+	}
+}
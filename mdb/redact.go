@@ -0,0 +1,132 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+)
+
+// redactedString replaces string and regex literals of redacted logs
+const redactedString = "xxx"
+
+// SetRedact sets to scrub string and number literals of retained log lines
+func (li *LogInfo) SetRedact(redact bool) {
+	li.redact = redact
+}
+
+// redactResults scrubs literals of slow ops and of example statements of ops patterns, e.g. of results encoded by
+// an earlier run without redaction
+func (li *LogInfo) redactResults() {
+	for i := range li.SlowOps {
+		li.SlowOps[i].Log = redactLog(li.SlowOps[i].Log)
+	}
+	for i := range li.OpsPatterns {
+		for j := range li.OpsPatterns[i].Examples {
+			li.OpsPatterns[i].Examples[j].Statement = redactLog(li.OpsPatterns[i].Examples[j].Statement)
+		}
+	}
+}
+
+// redactLog scrubs literals of the command document of a log line, keeps field names and metrics
+func redactLog(str string) string {
+	begin := -1
+	if strings.HasPrefix(str, "{") { // structured logs of 4.4
+		if idx := strings.Index(str, `"command":{`); idx >= 0 {
+			begin = idx + len(`"command":`)
+		}
+	} else {
+		begin = strings.Index(str, "{")
+	}
+	if begin < 0 {
+		return str
+	}
+	end := getDocEnd(str, begin)
+	return str[:begin] + redactLiterals(str[begin:end]) + str[end:]
+}
+
+// getDocEnd returns position after the brace matching the one at begin, quoted braces are skipped
+func getDocEnd(str string, begin int) int {
+	depth := 0
+	var quote byte
+	for i := begin; i < len(str); i++ {
+		c := str[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '"', '\'':
+			quote = c
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(str) // truncated by mongod
+}
+
+// redactLiterals replaces strings, regexes, and numbers of a document, quoted field names are kept
+func redactLiterals(doc string) string {
+	var buffer strings.Builder
+	prev := byte(0) // previous non-space character
+	for i := 0; i < len(doc); i++ {
+		c := doc[i]
+		isValue := prev == ':' || prev == ',' || prev == '[' || prev == '('
+		switch {
+		case c == '"' || c == '\'':
+			j := i + 1
+			for ; j < len(doc) && doc[j] != c; j++ {
+				if doc[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(doc) {
+				j = len(doc) - 1
+			}
+			k := j + 1
+			for k < len(doc) && doc[k] == ' ' {
+				k++
+			}
+			if k < len(doc) && doc[k] == ':' { // a field name
+				buffer.WriteString(doc[i : j+1])
+			} else {
+				buffer.WriteString(string(c) + redactedString + string(c))
+			}
+			i = j
+		case c == '/' && isValue:
+			j := i + 1
+			for ; j < len(doc) && doc[j] != '/'; j++ {
+				if doc[j] == '\\' {
+					j++
+				}
+			}
+			buffer.WriteString("/" + redactedString + "/")
+			i = j
+		case isValue && (isDigit(c) || (c == '-' && i+1 < len(doc) && isDigit(doc[i+1]))):
+			j := i + 1
+			for j < len(doc) && (isDigit(doc[j]) || strings.IndexByte(".eE+-", doc[j]) >= 0) {
+				j++
+			}
+			buffer.WriteString("1")
+			i = j - 1
+		default:
+			buffer.WriteByte(c)
+		}
+		if c != ' ' {
+			prev = c
+		}
+	}
+	return buffer.String()
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
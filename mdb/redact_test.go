@@ -0,0 +1,67 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactLog(t *testing.T) {
+	str := `2019-09-28T10:00:05.000-0400 I COMMAND  [conn10] command keyhole.cars appName: "MongoDB Shell" command: find { find: "cars", filter: { email: "john@example.com", age: { $gt: 21.5 }, name: /^Jo/, ids: [ 1, -2 ], "a.b": ObjectId('5d8f1c2e') }, $db: "keyhole" } planSummary: COLLSCAN keysExamined:0 docsExamined:100000 nreturned:10 reslen:1234 protocol:op_msg 900ms`
+	expected := `2019-09-28T10:00:05.000-0400 I COMMAND  [conn10] command keyhole.cars appName: "MongoDB Shell" command: find { find: "xxx", filter: { email: "xxx", age: { $gt: 1 }, name: /xxx/, ids: [ 1, 1 ], "a.b": ObjectId('xxx') }, $db: "xxx" } planSummary: COLLSCAN keysExamined:0 docsExamined:100000 nreturned:10 reslen:1234 protocol:op_msg 900ms`
+	if redacted := redactLog(str); redacted != expected {
+		t.Fatal(redacted)
+	}
+
+	str = `{"t":{"$date":"2020-08-01T10:00:00.000-04:00"},"s":"I","c":"COMMAND","msg":"Slow query","attr":{"ns":"keyhole.cars","command":{"find":"cars","filter":{"email":"john@example.com","age":21},"$db":"keyhole"},"planSummary":"COLLSCAN","durationMillis":900}}`
+	redacted := redactLog(str)
+	if strings.Contains(redacted, "john") || strings.Contains(redacted, `"filter":{"email":"xxx","age":1}`) == false ||
+		strings.HasSuffix(redacted, `"planSummary":"COLLSCAN","durationMillis":900}}`) == false {
+		t.Fatal(redacted)
+	}
+}
+
+func TestLogInfoRedact(t *testing.T) {
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	li.SetRedact(true)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if len(li.SlowOps) == 0 {
+		t.Fatal("expected slow ops")
+	}
+	for _, op := range li.SlowOps {
+		if op.Log != redactLog(op.Log) {
+			t.Fatal("not redacted", op.Log)
+		}
+	}
+}
+
+func TestLogInfoRedactResults(t *testing.T) {
+	li := NewLogInfo("testdata/mongod.log", "")
+	li.SetSilent(true)
+	li.SetExamples(1)
+	if err := li.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	li.redactResults()
+	examples := 0
+	for _, doc := range li.OpsPatterns {
+		for _, example := range doc.Examples {
+			if example.Statement != redactLog(example.Statement) || strings.Contains(example.Statement, redactedString) == false {
+				t.Fatal("not redacted", example.Statement)
+			}
+			examples++
+		}
+	}
+	if examples == 0 {
+		t.Fatal("expected example statements")
+	}
+	for _, op := range li.SlowOps {
+		if op.Log != redactLog(op.Log) {
+			t.Fatal("not redacted", op.Log)
+		}
+	}
+}
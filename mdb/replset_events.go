@@ -0,0 +1,109 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// replica set event types
+const (
+	EventElection   = "election"
+	EventRollback   = "rollback"
+	EventState      = "state"
+	EventStepDown   = "stepdown"
+	EventSyncSource = "sync source"
+)
+
+var replLogRegex = regexp.MustCompile(`^(\S+)\s+\w\s+(REPL|ELECTION|ROLLBACK)\s+\[[^\]]+\] (.*)$`)
+
+// rules are checked in order, the first match wins
+var replEventRules = []struct {
+	eventType string
+	regex     *regexp.Regexp
+}{
+	{EventState, regexp.MustCompile(`transition to \w+ from \w+|is now in state \w+`)},
+	{EventRollback, regexp.MustCompile(`(?i)rollback`)},
+	{EventStepDown, regexp.MustCompile(`(?i)stepping down|stepdown|step down`)},
+	{EventElection, regexp.MustCompile(`(?i)election|assuming primary|not running for primary|vote`)},
+	{EventSyncSource, regexp.MustCompile(`(?i)sync source|sync target|syncing from`)},
+}
+
+// ReplSetEvent is an event of replica set from logs
+type ReplSetEvent struct {
+	Time    string
+	Type    string
+	Message string
+}
+
+// ReplSetEvents builds a timeline of elections, stepdowns, sync source changes, and rollbacks
+type ReplSetEvents struct {
+	Events    []ReplSetEvent
+	filenames []string
+	verbose   bool
+}
+
+// NewReplSetEvents returns ReplSetEvents of log files, directories, or glob patterns
+func NewReplSetEvents(filenames []string) *ReplSetEvents {
+	return &ReplSetEvents{filenames: filenames}
+}
+
+// SetVerbose sets verbose level
+func (rse *ReplSetEvents) SetVerbose(verbose bool) {
+	rse.verbose = verbose
+}
+
+// Parse scans REPL, ELECTION, and ROLLBACK log lines
+func (rse *ReplSetEvents) Parse() error {
+	rse.Events = []ReplSetEvent{}
+	return readLogLines(rse.filenames, func(str string) {
+		result := replLogRegex.FindStringSubmatch(str)
+		if len(result) == 0 {
+			return
+		}
+		if eventType := getReplSetEventType(result[2], result[3]); eventType != "" {
+			rse.Events = append(rse.Events, ReplSetEvent{Time: result[1], Type: eventType, Message: result[3]})
+		}
+	})
+}
+
+// getReplSetEventType returns event type of a message, empty if not interested
+func getReplSetEventType(component string, message string) string {
+	if component == "ROLLBACK" {
+		return EventRollback
+	}
+	for _, rule := range replEventRules {
+		if rule.regex.MatchString(message) {
+			return rule.eventType
+		}
+	}
+	return ""
+}
+
+// GetSummary returns counts of events and a timeline, sync source events are listed in verbose mode
+func (rse *ReplSetEvents) GetSummary() string {
+	var buffer bytes.Buffer
+	counts := map[string]int{}
+	for _, event := range rse.Events {
+		counts[event.Type]++
+	}
+	strs := []string{}
+	for _, t := range []string{EventElection, EventStepDown, EventState, EventSyncSource, EventRollback} {
+		strs = append(strs, fmt.Sprintf("%s: %d", t, counts[t]))
+	}
+	buffer.WriteString("Replica set events, " + strings.Join(strs, ", ") + "\n")
+	for _, event := range rse.Events {
+		if event.Type == EventSyncSource && rse.verbose == false {
+			continue
+		}
+		msg := event.Message
+		if len(msg) > 120 && rse.verbose == false {
+			msg = msg[:117] + "..."
+		}
+		buffer.WriteString(fmt.Sprintf("%-29s %-11s %s\n", event.Time, event.Type, msg))
+	}
+	return buffer.String()
+}
@@ -0,0 +1,30 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReplSetEvents(t *testing.T) {
+	rse := NewReplSetEvents([]string{"testdata/mongod.log"})
+	if err := rse.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{}
+	for _, event := range rse.Events {
+		counts[event.Type]++
+	}
+	if counts[EventElection] != 3 || counts[EventStepDown] != 1 || counts[EventState] != 5 ||
+		counts[EventSyncSource] != 2 || counts[EventRollback] != 2 {
+		t.Fatal(counts)
+	}
+	if rse.Events[0].Time != "2019-09-28T10:01:30.000-0400" {
+		t.Fatal(rse.Events[0])
+	}
+	summary := rse.GetSummary()
+	if strings.Index(summary, "transition to PRIMARY from SECONDARY") < 0 || strings.Index(summary, "sync source candidate") >= 0 {
+		t.Fatal(summary)
+	}
+}
@@ -0,0 +1,454 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReportServer serves persisted analysis files of a directory as HTML pages, i.e. loginfo results of .enc files,
+// cluster summaries of -cluster.json files, explain reports of -explain.json.gz files, and index snapshots of
+// .json files of names containing "index".  Pages require the token as the password of basic authentication or as
+// a bearer token if a token is set, or a Host header of localhost if not.
+type ReportServer struct {
+	dir     string
+	token   string
+	verbose bool
+}
+
+// ReportFile is a persisted analysis file
+type ReportFile struct {
+	Name     string    `json:"name"`
+	Kind     string    `json:"kind"` // loginfo, cluster, explain, or indexes
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// NewReportServer returns a ReportServer of files of a directory
+func NewReportServer(dir string) *ReportServer {
+	return &ReportServer{dir: dir}
+}
+
+// SetToken sets a token required of requests
+func (rs *ReportServer) SetToken(token string) {
+	rs.token = token
+}
+
+// SetVerbose sets verbose level
+func (rs *ReportServer) SetVerbose(verbose bool) {
+	rs.verbose = verbose
+}
+
+// Serve serves pages at an address, e.g. :5408 of localhost, other than loopback addresses require a token
+func (rs *ReportServer) Serve(addr string) error {
+	if addr = getListenAddr(addr); isLoopbackAddr(addr) == false && rs.token == "" {
+		return errors.New("a token is required to serve at " + addr)
+	}
+	return http.ListenAndServe(addr, rs.Handler())
+}
+
+// Handler returns a handler of the list of files and pages of reports
+func (rs *ReportServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", rs.handle(func(r *http.Request) (string, error) {
+		if r.URL.Path != "/" {
+			return "", os.ErrNotExist
+		}
+		files, err := rs.GetReportFiles()
+		return getReportFilesHTML(rs.dir, files), err
+	}))
+	mux.HandleFunc("/loginfo", rs.handle(func(r *http.Request) (string, error) {
+		li, err := rs.getLogInfo(r.URL.Query().Get("file"))
+		if err != nil {
+			return "", err
+		}
+		return getLogInfoPageHTML(li, r.URL.Query().Get("file")), nil
+	}))
+	mux.HandleFunc("/loginfo/pattern", rs.handle(func(r *http.Request) (string, error) {
+		li, err := rs.getLogInfo(r.URL.Query().Get("file"))
+		if err != nil {
+			return "", err
+		}
+		id, _ := strconv.Atoi(r.URL.Query().Get("id"))
+		if id < 0 || id >= len(li.OpsPatterns) {
+			return "", os.ErrNotExist
+		}
+		return getOpsPatternPageHTML(li, r.URL.Query().Get("file"), li.OpsPatterns[id]), nil
+	}))
+	mux.HandleFunc("/cluster", rs.handle(func(r *http.Request) (string, error) {
+		var summary ClusterSummary
+		if err := rs.readJSON(r.URL.Query().Get("file"), &summary); err != nil {
+			return "", err
+		}
+		return getClusterSummaryHTML(summary), nil
+	}))
+	mux.HandleFunc("/explain", rs.handle(func(r *http.Request) (string, error) {
+		var report ExplainReport
+		if err := rs.readJSON(r.URL.Query().Get("file"), &report); err != nil {
+			return "", err
+		}
+		return getExplainReportHTML(report), nil
+	}))
+	mux.HandleFunc("/indexes", rs.handle(func(r *http.Request) (string, error) {
+		var snapshot map[string]map[string][]IndexStatsDoc
+		if err := rs.readJSON(r.URL.Query().Get("file"), &snapshot); err != nil {
+			return "", err
+		}
+		return getIndexesPageHTML(r.URL.Query().Get("file"), snapshot), nil
+	}))
+	return mux
+}
+
+// handle writes a page, or an error of not found or of reading a file
+func (rs *ReportServer) handle(page func(r *http.Request) (string, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if rs.verbose == true {
+			log.Println(r.Method, r.URL.RequestURI())
+		}
+		authorized := isPageAuthorized(r, rs.token)
+		if authorized == false && rs.token == "" { // other host names of loopback addresses, i.e. DNS rebinding
+			http.Error(w, "forbidden host "+r.Host, http.StatusForbidden)
+			return
+		} else if authorized == false { // browsers prompt for basic authentication
+			w.Header().Set("WWW-Authenticate", `Basic realm="keyhole"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		str, err := page(r)
+		if os.IsNotExist(err) {
+			http.NotFound(w, r)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(str))
+	}
+}
+
+// isPageAuthorized returns true if the request has the token as the password of basic authentication or as the bearer
+// token, or of a localhost Host header if no token is required
+func isPageAuthorized(r *http.Request, token string) bool {
+	if _, password, ok := r.BasicAuth(); ok == true && token != "" {
+		return subtle.ConstantTimeCompare([]byte(password), []byte(token)) == 1
+	}
+	return isAuthorized(r, token)
+}
+
+// GetReportFiles returns persisted analysis files of the directory, the latest first
+func (rs *ReportServer) GetReportFiles() ([]ReportFile, error) {
+	var err error
+	var infos []os.FileInfo
+	files := []ReportFile{}
+	if infos, err = ioutil.ReadDir(rs.dir); err != nil {
+		return files, err
+	}
+	for _, info := range infos {
+		if kind := getReportKind(info.Name()); kind != "" && info.IsDir() == false {
+			files = append(files, ReportFile{Name: info.Name(), Kind: kind, Size: info.Size(), Modified: info.ModTime()})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Modified.After(files[j].Modified) })
+	return files, err
+}
+
+// getReportKind returns the kind of a persisted analysis file, empty if not recognized
+func getReportKind(filename string) string {
+	if strings.HasSuffix(filename, ".enc") {
+		return "loginfo"
+	} else if strings.HasSuffix(filename, "-cluster.json") {
+		return "cluster"
+	} else if strings.HasSuffix(filename, "-explain.json.gz") {
+		return "explain"
+	} else if strings.HasSuffix(filename, ".json") && strings.Contains(strings.ToLower(filename), "index") {
+		return "indexes"
+	}
+	return ""
+}
+
+// getPath returns the path of a file of the directory, files of other directories aren't served
+func (rs *ReportServer) getPath(filename string) (string, error) {
+	if filename == "" || filename != filepath.Base(filename) || getReportKind(filename) == "" {
+		return "", os.ErrNotExist
+	}
+	return filepath.Join(rs.dir, filename), nil
+}
+
+// getLogInfo returns loginfo results of an .enc file
+func (rs *ReportServer) getLogInfo(filename string) (*LogInfo, error) {
+	path, err := rs.getPath(filename)
+	if err != nil {
+		return nil, err
+	} else if getReportKind(filename) != "loginfo" {
+		return nil, os.ErrNotExist
+	}
+	li := NewLogInfo(path, "")
+	li.SetSilent(true)
+	if _, err = li.Analyze(); err != nil {
+		return nil, err
+	}
+	return li, nil
+}
+
+// readJSON decodes a JSON file, or a gzipped or an encrypted one
+func (rs *ReportServer) readJSON(filename string, doc interface{}) error {
+	var err error
+	var path string
+	var data []byte
+	if path, err = rs.getPath(filename); err != nil {
+		return err
+	}
+	if data, err = readArtifact(path, nil); err != nil {
+		return err
+	}
+	if err = json.Unmarshal(data, doc); err != nil {
+		return errors.New(filename + ": " + err.Error())
+	}
+	return nil
+}
+
+// getPageHeader returns the head of a page and a link back to the list of files
+func getPageHeader(title string) string {
+	var buffer bytes.Buffer
+	buffer.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	buffer.WriteString("<title>" + html.EscapeString(title) + "</title>\n")
+	buffer.WriteString(htmlStyle)
+	buffer.WriteString(htmlScript)
+	buffer.WriteString("</head>\n<body>\n<p><a href=\"/\">All reports</a></p>\n")
+	buffer.WriteString("<h1>" + html.EscapeString(title) + "</h1>\n")
+	return buffer.String()
+}
+
+// getReportFilesHTML returns a page of links to reports of files
+func getReportFilesHTML(dir string, files []ReportFile) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(getPageHeader("Keyhole Reports - " + dir))
+	if len(files) == 0 {
+		buffer.WriteString("<p>No .enc, -cluster.json, -explain.json.gz, or index .json files found</p>\n</body>\n</html>\n")
+		return buffer.String()
+	}
+	buffer.WriteString("<table>\n<thead><tr>")
+	for _, name := range []string{"Report", "Kind", "Size", "Modified"} {
+		buffer.WriteString("<th onclick=\"sortTable(this)\">" + name + "</th>")
+	}
+	buffer.WriteString("</tr></thead>\n<tbody>\n")
+	for _, f := range files {
+		buffer.WriteString(fmt.Sprintf("<tr><td><a href=\"/%s?file=%s\">%s</a></td><td>%s</td><td class=\"num\">%d</td><td>%s</td></tr>\n",
+			f.Kind, url.QueryEscape(f.Name), html.EscapeString(f.Name), f.Kind, f.Size, f.Modified.Format(time.RFC3339)))
+	}
+	buffer.WriteString("</tbody>\n</table>\n</body>\n</html>\n")
+	return buffer.String()
+}
+
+// getSVGBarChart returns an inline SVG bar chart, labels are shown as tooltips
+func getSVGBarChart(title string, labels []string, values []float64) string {
+	var buffer bytes.Buffer
+	width, height := 960.0, 160.0
+	max := 0.0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	buffer.WriteString(fmt.Sprintf("<h2>%s</h2>\n<svg width=\"%.0f\" height=\"%.0f\" style=\"border: 1px solid #ccc\">\n",
+		html.EscapeString(title), width, height+20))
+	if len(values) == 0 || max == 0 {
+		buffer.WriteString("</svg>\n")
+		return buffer.String()
+	}
+	barWidth := width / float64(len(values))
+	for i, v := range values {
+		h := v / max * height
+		buffer.WriteString(fmt.Sprintf("<rect x=\"%.1f\" y=\"%.1f\" width=\"%.1f\" height=\"%.1f\" fill=\"#13aa52\"><title>%s: %.1f</title></rect>\n",
+			float64(i)*barWidth, height-h, barWidth*0.9, h, html.EscapeString(labels[i]), v))
+	}
+	buffer.WriteString(fmt.Sprintf("<text x=\"2\" y=\"%.0f\" font-size=\"11\">%s</text>", height+15, html.EscapeString(labels[0])))
+	buffer.WriteString(fmt.Sprintf("<text x=\"%.0f\" y=\"%.0f\" font-size=\"11\" text-anchor=\"end\">%s, max %.1f</text>\n</svg>\n",
+		width-2, height+15, html.EscapeString(labels[len(labels)-1]), max))
+	return buffer.String()
+}
+
+// getTimeSeriesCharts returns charts of ops and average milliseconds of time buckets of all commands
+func getTimeSeriesCharts(buckets []TimeBucketDoc) string {
+	totals := map[time.Time]*TimeBucketDoc{}
+	times := []time.Time{}
+	for _, b := range buckets {
+		total, ok := totals[b.Time]
+		if ok == false {
+			total = &TimeBucketDoc{Time: b.Time}
+			totals[b.Time] = total
+			times = append(times, b.Time)
+		}
+		total.Count += b.Count
+		total.TotalMilli += b.TotalMilli
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	labels := []string{}
+	counts := []float64{}
+	avgs := []float64{}
+	for _, t := range times {
+		labels = append(labels, t.Format(time.RFC3339))
+		counts = append(counts, float64(totals[t].Count))
+		avgs = append(avgs, float64(totals[t].TotalMilli)/float64(totals[t].Count))
+	}
+	return getSVGBarChart("Slow Ops", labels, counts) + getSVGBarChart("Average Milliseconds", labels, avgs)
+}
+
+// getLogInfoPageHTML returns a page of loginfo results with charts of throughputs, slow ops, and ops patterns
+// linked to their details
+func getLogInfoPageHTML(li *LogInfo, filename string) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(getPageHeader("Keyhole Log Analytics - " + filename))
+	buffer.WriteString(fmt.Sprintf("<p>%d ops pattern(s) from %s to %s</p>\n", len(li.OpsPatterns), li.StartTime.Format(time.RFC3339),
+		li.EndTime.Format(time.RFC3339)))
+	if len(li.TimeSeries) > 0 {
+		buffer.WriteString(getTimeSeriesCharts(li.TimeSeries))
+	}
+	if len(li.SlowOps) > 0 {
+		buffer.WriteString(fmt.Sprintf("<h2>Top %d Slowest Ops</h2>\n", len(li.SlowOps)))
+		buffer.WriteString(getSlowOpsTableHTML(li.SlowOps))
+	}
+	buffer.WriteString("<h2>Ops Patterns</h2>\n<table>\n<thead><tr>")
+	for _, name := range []string{"Command", "COLLSCAN", "Namespace", "Count", "avg ms", "p95 ms", "max ms", "total ms", "Index", "Query Pattern"} {
+		buffer.WriteString("<th onclick=\"sortTable(this)\">" + name + "</th>")
+	}
+	buffer.WriteString("</tr></thead>\n<tbody>\n")
+	for i, doc := range li.OpsPatterns {
+		class := ""
+		if doc.Scan == COLLSCAN {
+			class = " class=\"collscan\""
+		}
+		buffer.WriteString(fmt.Sprintf("<tr%s><td>%s</td><td class=\"scan\">%s</td><td>%s</td><td class=\"num\">%d</td><td class=\"num\">%.1f</td>",
+			class, html.EscapeString(doc.Command), doc.Scan, html.EscapeString(doc.Namespace), doc.Count, float64(doc.TotalMilli)/float64(doc.Count)))
+		buffer.WriteString(fmt.Sprintf("<td class=\"num\">%d</td><td class=\"num\">%d</td><td class=\"num\">%d</td><td class=\"pattern\">%s</td>",
+			doc.Histogram.Percentile(95), doc.MaxMilli, doc.TotalMilli, html.EscapeString(doc.Index)))
+		buffer.WriteString(fmt.Sprintf("<td class=\"pattern\"><a href=\"/loginfo/pattern?file=%s&id=%d\">%s</a></td></tr>\n",
+			url.QueryEscape(filename), i, html.EscapeString(doc.Filter)))
+	}
+	buffer.WriteString("</tbody>\n</table>\n</body>\n</html>\n")
+	return buffer.String()
+}
+
+// getSlowOpsTableHTML returns a table of slow ops
+func getSlowOpsTableHTML(ops []SlowOps) string {
+	var buffer bytes.Buffer
+	buffer.WriteString("<table>\n<thead><tr>")
+	for _, name := range []string{"Time", "Duration", "Command", "Namespace", "Plan Summary"} {
+		buffer.WriteString("<th onclick=\"sortTable(this)\">" + name + "</th>")
+	}
+	buffer.WriteString("<th>Log</th></tr></thead>\n<tbody>\n")
+	for _, op := range ops {
+		ts := ""
+		if op.Time.IsZero() == false {
+			ts = op.Time.Format(logTimeLayout)
+		}
+		buffer.WriteString(fmt.Sprintf("<tr><td>%s</td><td class=\"num\" data-value=\"%d\">%s</td><td>%s</td><td>%s</td><td class=\"pattern\">%s</td><td class=\"pattern\">%s</td></tr>\n",
+			ts, op.Milli, strings.TrimSpace(MilliToTimeString(float64(op.Milli))), html.EscapeString(op.Command),
+			html.EscapeString(op.Namespace), html.EscapeString(op.PlanSummary), html.EscapeString(op.Log)))
+	}
+	buffer.WriteString("</tbody>\n</table>\n")
+	return buffer.String()
+}
+
+// getOpsPatternPageHTML returns a page of an ops pattern with its latency distribution, examples, and slow ops of
+// the same namespace and command
+func getOpsPatternPageHTML(li *LogInfo, filename string, doc OpPerformanceDoc) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(getPageHeader(doc.Command + " " + doc.Namespace))
+	buffer.WriteString(fmt.Sprintf("<p><a href=\"/loginfo?file=%s\">%s</a></p>\n", url.QueryEscape(filename), html.EscapeString(filename)))
+	buffer.WriteString("<pre>" + html.EscapeString(doc.Filter) + "</pre>\n")
+	buffer.WriteString("<table>\n<tbody>\n")
+	for _, row := range [][2]string{{"Count", fmt.Sprintf("%d", doc.Count)}, {"avg ms", fmt.Sprintf("%.1f", float64(doc.TotalMilli)/float64(doc.Count))},
+		{"p50/p95/p99 ms", fmt.Sprintf("%d / %d / %d", doc.Histogram.Percentile(50), doc.Histogram.Percentile(95), doc.Histogram.Percentile(99))},
+		{"max ms", fmt.Sprintf("%d", doc.MaxMilli)}, {"Plan", strings.TrimSpace(doc.Scan + " " + doc.Index)},
+		{"keysExamined", fmt.Sprintf("%d", doc.KeysExamined)}, {"docsExamined", fmt.Sprintf("%d", doc.DocsExamined)},
+		{"nreturned", fmt.Sprintf("%d", doc.NReturned)}, {"queryHash", doc.QueryHash}} {
+		buffer.WriteString(fmt.Sprintf("<tr><th>%s</th><td class=\"pattern\">%s</td></tr>\n", row[0], html.EscapeString(row[1])))
+	}
+	buffer.WriteString("</tbody>\n</table>\n")
+	indexes := []int{}
+	for idx := range doc.Histogram.Buckets {
+		indexes = append(indexes, idx)
+	}
+	sort.Ints(indexes)
+	labels := []string{}
+	counts := []float64{}
+	for _, idx := range indexes {
+		labels = append(labels, fmt.Sprintf("<= %d ms", getBucketValue(idx)))
+		counts = append(counts, float64(doc.Histogram.Buckets[idx]))
+	}
+	buffer.WriteString(getSVGBarChart("Latency Distribution", labels, counts))
+	if len(doc.Examples) > 0 {
+		buffer.WriteString("<h2>Examples</h2>\n")
+		for _, example := range doc.Examples {
+			buffer.WriteString(fmt.Sprintf("<pre>// %dms at %s\n%s</pre>\n", example.Milli, example.Time.Format(logTimeLayout),
+				html.EscapeString(example.Statement)))
+		}
+	}
+	ops := []SlowOps{}
+	for _, op := range li.SlowOps {
+		if op.Namespace == doc.Namespace && op.Command == doc.Command {
+			ops = append(ops, op)
+		}
+	}
+	if len(ops) > 0 {
+		buffer.WriteString("<h2>Slow Ops</h2>\n")
+		buffer.WriteString(getSlowOpsTableHTML(ops))
+	}
+	buffer.WriteString("</body>\n</html>\n")
+	return buffer.String()
+}
+
+// getIndexesPageHTML returns a page of indexes of a snapshot, unused and duplicated ones highlighted
+func getIndexesPageHTML(filename string, snapshot map[string]map[string][]IndexStatsDoc) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(getPageHeader("Keyhole Indexes - " + filename))
+	buffer.WriteString("<table>\n<thead><tr>")
+	for _, name := range []string{"Namespace", "Name", "Key", "totalOps", "Size", "Cache Bytes", "Unused", "Dupped"} {
+		buffer.WriteString("<th onclick=\"sortTable(this)\">" + name + "</th>")
+	}
+	buffer.WriteString("</tr></thead>\n<tbody>\n")
+	dbNames := []string{}
+	for dbName := range snapshot {
+		dbNames = append(dbNames, dbName)
+	}
+	sort.Strings(dbNames)
+	for _, dbName := range dbNames {
+		collNames := []string{}
+		for collName := range snapshot[dbName] {
+			collNames = append(collNames, collName)
+		}
+		sort.Strings(collNames)
+		for _, collName := range collNames {
+			for _, o := range snapshot[dbName][collName] {
+				class := ""
+				if o.IsDupped == true {
+					class = " class=\"collscan\""
+				} else if isUnusedIndex(o) == true {
+					class = " class=\"inefficient\""
+				}
+				buffer.WriteString(fmt.Sprintf("<tr%s><td>%s</td><td>%s</td><td class=\"pattern\">%s</td><td class=\"num\">%d</td><td class=\"num\">%d</td><td class=\"num\">%d</td><td>%v</td><td>%v</td></tr>\n",
+					class, html.EscapeString(dbName+"."+collName), html.EscapeString(o.Name), html.EscapeString(o.Key), o.TotalOps, o.Size,
+					o.CacheBytes, isUnusedIndex(o), o.IsDupped))
+			}
+		}
+	}
+	buffer.WriteString("</tbody>\n</table>\n</body>\n</html>\n")
+	return buffer.String()
+}
@@ -0,0 +1,104 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/simagix/gox"
+)
+
+func TestGetReportKind(t *testing.T) {
+	for filename, kind := range map[string]string{"mongod.log.enc": "loginfo", "h1_27017-cluster.json": "cluster",
+		"shapes.json-explain.json.gz": "explain", "indexes.json": "indexes", "scenario.json": "", "mongod.log": ""} {
+		if k := getReportKind(filename); k != kind {
+			t.Fatal(filename, k)
+		}
+	}
+}
+
+func TestReportServer(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "keyhole-reports-")
+	defer os.RemoveAll(dir)
+	now := time.Date(2019, 10, 1, 10, 0, 0, 0, time.UTC)
+	histogram := NewLatencyHistogram()
+	histogram.Add(120)
+	li := LogInfo{OpsPatterns: []OpPerformanceDoc{{Command: "find", Namespace: "demo.orders", Filter: "{status: 1}", Count: 1,
+		TotalMilli: 120, MaxMilli: 120, Scan: COLLSCAN, Histogram: histogram}},
+		SlowOps:    []SlowOps{{Command: "find", Namespace: "demo.orders", Milli: 120, Log: "slow find of orders"}},
+		TimeSeries: []TimeBucketDoc{{Command: "find", Count: 1, TotalMilli: 120, MaxMilli: 120, Time: now}}}
+	var data bytes.Buffer
+	if err := gob.NewEncoder(&data).Encode(li); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "mongod.log.enc"), data.Bytes(), 0644)
+	summary := ClusterSummary{Generated: now, Topology: REPLICA, Members: []MemberSummary{{Host: "h1:27017", State: "PRIMARY"}}}
+	ioutil.WriteFile(filepath.Join(dir, "h1_27017-cluster.json"), []byte(gox.Stringify(summary)), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "indexes.json"), []byte(`{"demo": {"orders": [{"name": "_id_", "key": "{ _id: 1 }", "totalOps": 9}]}}`), 0644)
+
+	server := httptest.NewServer(NewReportServer(dir).Handler())
+	defer server.Close()
+	for path, expected := range map[string]string{"/": "/loginfo?file=mongod.log.enc", "/loginfo?file=mongod.log.enc": "/loginfo/pattern?file=mongod.log.enc&id=0",
+		"/loginfo/pattern?file=mongod.log.enc&id=0": "slow find of orders", "/cluster?file=h1_27017-cluster.json": "h1:27017",
+		"/indexes?file=indexes.json": "demo.orders"} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || strings.Contains(string(body), expected) == false {
+			t.Fatal(path, resp.Status, string(body))
+		}
+	}
+	for _, path := range []string{"/loginfo?file=../mongod.log.enc", "/loginfo/pattern?file=mongod.log.enc&id=1", "/cluster?file=missing-cluster.json"} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatal(path, resp.Status)
+		}
+	}
+}
+
+func TestReportServerToken(t *testing.T) {
+	rs := NewReportServer("testdata")
+	rs.SetToken("secret")
+	server := httptest.NewServer(rs.Handler())
+	defer server.Close()
+	resp, _ := http.Get(server.URL + "/")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+		t.Fatal(resp.Status)
+	}
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/", nil)
+	req.SetBasicAuth("keyhole", "secret")
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal(resp.Status)
+	}
+	server = httptest.NewServer(NewReportServer("testdata").Handler())
+	defer server.Close()
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/", nil)
+	req.Host = "rebind.example.com:5408" // resolved to a loopback address
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatal(resp.Status)
+	}
+	if err := NewReportServer("testdata").Serve("0.0.0.0:5408"); err == nil {
+		t.Fatal("expected a token required")
+	}
+}
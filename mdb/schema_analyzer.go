@@ -0,0 +1,281 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// defaultSchemaSampleSize is number of documents sampled to infer a schema if not set
+const defaultSchemaSampleSize = 1000
+
+// SchemaAnalyzer samples documents of a collection and infers presence, types, arrays, and depths of fields
+type SchemaAnalyzer struct {
+	client     *mongo.Client
+	sampleSize int64
+	verbose    bool
+}
+
+// SchemaSummary is fields inferred from documents sampled of a collection, parents before their children
+type SchemaSummary struct {
+	Namespace string        `json:"ns"`
+	Sampled   int           `json:"sampled"`
+	MaxDepth  int           `json:"maxDepth"`
+	Fields    []SchemaField `json:"fields"`
+}
+
+// SchemaField is presence and types of a field, elements of an array field are of path field[]
+type SchemaField struct {
+	Path           string         `json:"path"`
+	Depth          int            `json:"depth"`
+	Count          int            `json:"count"`    // number of documents having the field
+	Presence       float64        `json:"presence"` // count to documents sampled
+	Types          map[string]int `json:"types"`    // occurrences by BSON types
+	Mixed          bool           `json:"mixed"`    // more than one type other than null
+	MinArrayLength int            `json:"minArrayLength,omitempty"`
+	MaxArrayLength int            `json:"maxArrayLength,omitempty"`
+	AvgArrayLength float64        `json:"avgArrayLength,omitempty"`
+	arrays         int
+	totalLength    int
+}
+
+// schemaFields are fields by paths in order of first seen
+type schemaFields struct {
+	fields map[string]*SchemaField
+	paths  []string
+}
+
+// NewSchemaAnalyzer returns a SchemaAnalyzer
+func NewSchemaAnalyzer(client *mongo.Client) *SchemaAnalyzer {
+	return &SchemaAnalyzer{client: client, sampleSize: defaultSchemaSampleSize}
+}
+
+// SetSampleSize sets number of documents to sample, the default if 0
+func (sa *SchemaAnalyzer) SetSampleSize(sampleSize int64) {
+	if sampleSize > 0 {
+		sa.sampleSize = sampleSize
+	}
+}
+
+// SetVerbose sets verbose level
+func (sa *SchemaAnalyzer) SetVerbose(verbose bool) {
+	sa.verbose = verbose
+}
+
+// Analyze samples documents of a collection and returns fields inferred
+func (sa *SchemaAnalyzer) Analyze(dbName string, collection string) (SchemaSummary, error) {
+	var err error
+	var cur *mongo.Cursor
+	summary := SchemaSummary{Namespace: dbName + "." + collection, Fields: []SchemaField{}}
+	if dbName == "" || collection == "" {
+		return summary, errors.New("usage: keyhole --analyzeSchema --collection collection_name connection_uri")
+	}
+	ctx := context.Background()
+	pipeline := mongo.Pipeline{{{Key: "$sample", Value: bson.D{{Key: "size", Value: sa.sampleSize}}}}}
+	if cur, err = sa.client.Database(dbName).Collection(collection).Aggregate(ctx, pipeline); err != nil {
+		return summary, err
+	}
+	defer cur.Close(ctx)
+	docs := []bson.D{}
+	for cur.Next(ctx) {
+		var doc bson.D
+		if err = cur.Decode(&doc); err != nil {
+			return summary, err
+		}
+		docs = append(docs, doc)
+	}
+	if sa.verbose == true {
+		fmt.Println(len(docs), "documents sampled from", summary.Namespace)
+	}
+	summary.Sampled = len(docs)
+	summary.MaxDepth, summary.Fields = getSchemaFields(docs)
+	return summary, cur.Err()
+}
+
+// getSchemaFields returns the max depth and fields of documents, parents before their children
+func getSchemaFields(docs []bson.D) (int, []SchemaField) {
+	sf := &schemaFields{fields: map[string]*SchemaField{}}
+	for _, doc := range docs {
+		sf.walk(doc, "", 1, map[string]bool{})
+	}
+	maxDepth := 0
+	fields := []SchemaField{}
+	for _, path := range sf.getTreeOrder() {
+		field := sf.fields[path]
+		if len(docs) > 0 {
+			field.Presence = float64(field.Count) / float64(len(docs))
+		}
+		if field.arrays > 0 {
+			field.AvgArrayLength = float64(field.totalLength) / float64(field.arrays)
+		}
+		nonNull := 0
+		for name := range field.Types {
+			if name != "null" {
+				nonNull++
+			}
+		}
+		field.Mixed = nonNull > 1
+		if field.Depth > maxDepth {
+			maxDepth = field.Depth
+		}
+		fields = append(fields, *field)
+	}
+	return maxDepth, fields
+}
+
+// walk adds fields of a document, seen are paths already counted of the document
+func (sf *schemaFields) walk(doc bson.D, prefix string, depth int, seen map[string]bool) {
+	for _, elem := range doc {
+		sf.add(prefix+elem.Key, elem.Value, depth, seen)
+	}
+}
+
+// add adds a value of a path, elements of arrays are added to path[]
+func (sf *schemaFields) add(path string, value interface{}, depth int, seen map[string]bool) {
+	field, ok := sf.fields[path]
+	if ok == false {
+		field = &SchemaField{Path: path, Depth: depth, Types: map[string]int{}}
+		sf.fields[path] = field
+		sf.paths = append(sf.paths, path)
+	}
+	if seen[path] == false {
+		seen[path] = true
+		field.Count++
+	}
+	field.Types[getBSONTypeName(value)]++
+	switch v := value.(type) {
+	case bson.D:
+		sf.walk(v, path+".", depth+1, seen)
+	case primitive.A:
+		if field.arrays == 0 || len(v) < field.MinArrayLength {
+			field.MinArrayLength = len(v)
+		}
+		if len(v) > field.MaxArrayLength {
+			field.MaxArrayLength = len(v)
+		}
+		field.arrays++
+		field.totalLength += len(v)
+		for _, elem := range v {
+			sf.add(path+"[]", elem, depth+1, seen)
+		}
+	}
+}
+
+// getTreeOrder returns paths with children following their parents in order of first seen
+func (sf *schemaFields) getTreeOrder() []string {
+	children := map[string][]string{}
+	for _, path := range sf.paths {
+		parent := getSchemaParentPath(path)
+		children[parent] = append(children[parent], path)
+	}
+	paths := []string{}
+	var visit func(parent string)
+	visit = func(parent string) {
+		for _, path := range children[parent] {
+			paths = append(paths, path)
+			visit(path)
+		}
+	}
+	visit("")
+	return paths
+}
+
+// getSchemaParentPath returns the parent of a path, e.g. a of a.b and of a[], empty of a top level field
+func getSchemaParentPath(path string) string {
+	if strings.HasSuffix(path, "[]") {
+		return strings.TrimSuffix(path, "[]")
+	}
+	if pos := strings.LastIndex(path, "."); pos >= 0 {
+		return path[:pos]
+	}
+	return ""
+}
+
+// getBSONTypeName returns the BSON type name of a decoded value
+func getBSONTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bson.D:
+		return "object"
+	case primitive.A:
+		return "array"
+	case string:
+		return "string"
+	case int32:
+		return "int"
+	case int64:
+		return "long"
+	case float64:
+		return "double"
+	case bool:
+		return "bool"
+	case primitive.DateTime:
+		return "date"
+	case primitive.ObjectID:
+		return "objectId"
+	case primitive.Decimal128:
+		return "decimal"
+	case primitive.Binary:
+		return "binData"
+	case primitive.Regex:
+		return "regex"
+	case primitive.Timestamp:
+		return "timestamp"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// GetSchemaTree returns fields of a schema summary as an indented tree with presences and types
+func GetSchemaTree(summary SchemaSummary) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("%v, %d document(s) sampled, max depth %d\n", summary.Namespace, summary.Sampled, summary.MaxDepth))
+	for _, field := range summary.Fields {
+		name := field.Path
+		if parent := getSchemaParentPath(field.Path); parent != "" {
+			name = strings.TrimPrefix(strings.TrimPrefix(field.Path, parent), ".")
+		}
+		names := make([]string, 0, len(field.Types))
+		for t := range field.Types {
+			names = append(names, t)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			if field.Types[names[i]] != field.Types[names[j]] {
+				return field.Types[names[i]] > field.Types[names[j]]
+			}
+			return names[i] < names[j]
+		})
+		total := 0
+		for _, t := range names {
+			total += field.Types[t]
+		}
+		types := []string{}
+		for _, t := range names {
+			if len(names) == 1 {
+				types = append(types, t)
+			} else {
+				types = append(types, fmt.Sprintf("%v %.0f%%", t, 100*float64(field.Types[t])/float64(total)))
+			}
+		}
+		str := strings.Join(types, ", ")
+		if field.Types["array"] > 0 {
+			str += fmt.Sprintf(" (length %d-%d, avg %.1f)", field.MinArrayLength, field.MaxArrayLength, field.AvgArrayLength)
+		}
+		if field.Mixed == true {
+			str += "  MIXED"
+		}
+		label := strings.Repeat("  ", field.Depth-1) + name
+		buffer.WriteString(fmt.Sprintf("%-40s %6.1f%%  %s\n", label, 100*field.Presence, str))
+	}
+	return buffer.String()
+}
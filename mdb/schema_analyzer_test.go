@@ -0,0 +1,59 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestGetSchemaFields(t *testing.T) {
+	docs := []bson.D{
+		{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "year", Value: int32(2019)},
+			{Key: "tags", Value: primitive.A{"a", "b", "c"}}},
+		{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "year", Value: "2018"},
+			{Key: "specs", Value: bson.D{{Key: "engine", Value: bson.D{{Key: "hp", Value: 300.5}}}}},
+			{Key: "tags", Value: primitive.A{bson.D{{Key: "name", Value: "d"}}}}},
+		{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "year", Value: nil}, {Key: "tags", Value: primitive.A{}}},
+		{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "year", Value: int32(2017)}},
+	}
+	maxDepth, fields := getSchemaFields(docs)
+	m := map[string]SchemaField{}
+	paths := []string{}
+	for _, field := range fields {
+		m[field.Path] = field
+		paths = append(paths, field.Path)
+	}
+	if maxDepth != 3 || strings.Join(paths, ",") != "_id,year,tags,tags[],tags[].name,specs,specs.engine,specs.engine.hp" {
+		t.Fatal(maxDepth, paths)
+	}
+	if f := m["year"]; f.Presence != 1 || f.Mixed == false || f.Types["int"] != 2 || f.Types["null"] != 1 {
+		t.Fatal(f)
+	}
+	if f := m["tags"]; f.Presence != 0.75 || f.MinArrayLength != 0 || f.MaxArrayLength != 3 || f.AvgArrayLength != 4.0/3 {
+		t.Fatal(f)
+	}
+	if f := m["tags[]"]; f.Count != 2 || f.Types["string"] != 3 || f.Mixed == false {
+		t.Fatal(f)
+	}
+	if f := m["specs.engine.hp"]; f.Depth != 3 || f.Presence != 0.25 || f.Types["double"] != 1 {
+		t.Fatal(f)
+	}
+	str := GetSchemaTree(SchemaSummary{Namespace: "keyhole.cars", Sampled: len(docs), MaxDepth: maxDepth, Fields: fields})
+	t.Log(str)
+	if strings.Contains(str, "(length 0-3, avg 1.3)") == false || strings.Contains(str, "    hp") == false ||
+		strings.Contains(str, "MIXED") == false {
+		t.Fatal(str)
+	}
+}
+
+func TestGetSchemaParentPath(t *testing.T) {
+	for path, parent := range map[string]string{"a": "", "a.b": "a", "a[]": "a", "a[].b": "a[]", "a[][]": "a[]"} {
+		if p := getSchemaParentPath(path); p != parent {
+			t.Fatal(path, p)
+		}
+	}
+}
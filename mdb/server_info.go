@@ -79,10 +79,15 @@ func GetServerInfo(client *mongo.Client) (ServerInfo, error) {
 
 // ListDatabaseNames gets all database names
 func ListDatabaseNames(client *mongo.Client) ([]string, error) {
+	return ListDatabaseNamesContext(context.Background(), client)
+}
+
+// ListDatabaseNamesContext returns names of databases, stops when ctx is done
+func ListDatabaseNamesContext(ctx context.Context, client *mongo.Client) ([]string, error) {
 	var err error
 	var names []string
 	var result mongo.ListDatabasesResult
-	if result, err = client.ListDatabases(context.Background(), bson.M{}); err != nil {
+	if result, err = client.ListDatabases(ctx, bson.M{}); err != nil {
 		return names, err
 	}
 	for _, db := range result.Databases {
@@ -0,0 +1,313 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// spikeStdDevs is number of standard deviations above the mean a sample of a metric is a spike
+const spikeStdDevs = 3
+
+// ServerStatusCollector polls serverStatus at an interval for a duration and appends samples to a file
+type ServerStatusCollector struct {
+	client   *mongo.Client
+	duration time.Duration
+	filename string
+	interval time.Duration
+	readPref *readpref.ReadPref // of members to sample, primary if nil
+	verbose  bool
+}
+
+// ServerStatusSample is metrics of a serverStatus
+type ServerStatusSample struct {
+	Time       time.Time `json:"time"`
+	Host       string    `json:"host"`
+	Uptime     int64     `json:"uptime"`
+	Opcounters struct {
+		Insert  int64 `json:"insert" bson:"insert"`
+		Query   int64 `json:"query" bson:"query"`
+		Update  int64 `json:"update" bson:"update"`
+		Delete  int64 `json:"delete" bson:"delete"`
+		Getmore int64 `json:"getmore" bson:"getmore"`
+		Command int64 `json:"command" bson:"command"`
+	} `json:"opcounters"`
+	Connections struct {
+		Current   int64 `json:"current" bson:"current"`
+		Available int64 `json:"available" bson:"available"`
+	} `json:"connections"`
+	Cache struct {
+		BytesInCache int64 `json:"bytesInCache" bson:"bytes currently in the cache"`
+		MaxBytes     int64 `json:"maxBytes" bson:"maximum bytes configured"`
+		DirtyBytes   int64 `json:"dirtyBytes" bson:"tracked dirty bytes in the cache"`
+	} `json:"cache"`
+	Queues struct {
+		Readers int64 `json:"readers" bson:"readers"`
+		Writers int64 `json:"writers" bson:"writers"`
+	} `json:"queues"`
+	Tickets struct {
+		ReadOut        int64 `json:"readOut"`
+		ReadAvailable  int64 `json:"readAvailable"`
+		WriteOut       int64 `json:"writeOut"`
+		WriteAvailable int64 `json:"writeAvailable"`
+	} `json:"tickets"`
+}
+
+// ServerStatusMetric is statistics of a metric over samples, rates per second of counters
+type ServerStatusMetric struct {
+	Name   string      `json:"name"`
+	Avg    float64     `json:"avg"`
+	Max    float64     `json:"max"`
+	Spikes []time.Time `json:"spikes"` // times of samples above the mean by spikeStdDevs standard deviations
+}
+
+// serverStatusTickets is read or write tickets of serverStatus
+type serverStatusTickets struct {
+	Out       int64 `bson:"out"`
+	Available int64 `bson:"available"`
+}
+
+// NewServerStatusCollector returns a ServerStatusCollector writing samples to a file
+func NewServerStatusCollector(client *mongo.Client, filename string) *ServerStatusCollector {
+	return &ServerStatusCollector{client: client, duration: 5 * time.Minute, filename: filename, interval: 10 * time.Second}
+}
+
+// SetDuration sets how long to collect samples
+func (sc *ServerStatusCollector) SetDuration(duration time.Duration) {
+	sc.duration = duration
+}
+
+// SetInterval sets time between samples
+func (sc *ServerStatusCollector) SetInterval(interval time.Duration) {
+	sc.interval = interval
+}
+
+// SetReadPreference sets a read preference of members to sample, e.g. of secondaries or analytics nodes
+func (sc *ServerStatusCollector) SetReadPreference(rp *readpref.ReadPref) {
+	sc.readPref = rp
+}
+
+// SetVerbose sets verbose level
+func (sc *ServerStatusCollector) SetVerbose(verbose bool) {
+	sc.verbose = verbose
+}
+
+// Collect polls serverStatus until the duration ends and returns samples collected
+func (sc *ServerStatusCollector) Collect() ([]ServerStatusSample, error) {
+	return sc.CollectContext(context.Background())
+}
+
+// CollectContext polls serverStatus until the duration ends or ctx is done
+func (sc *ServerStatusCollector) CollectContext(ctx context.Context) ([]ServerStatusSample, error) {
+	samples := []ServerStatusSample{}
+	deadline := time.Now().Add(sc.duration)
+	ticker := time.NewTicker(sc.interval)
+	defer ticker.Stop()
+	for {
+		var doc bson.Raw
+		opCtx, cancel := context.WithTimeout(ctx, memberPingTimeout)
+		err := sc.client.Database("admin").RunCommand(opCtx, bson.D{{Key: "serverStatus", Value: 1}}, getRunCmdOptions(sc.readPref)).Decode(&doc)
+		cancel()
+		if err != nil {
+			return samples, err
+		}
+		var sample ServerStatusSample
+		if sample, err = getServerStatusSample(doc); err != nil {
+			return samples, err
+		}
+		if err = appendJSONLine(sc.filename, sample); err != nil {
+			return samples, err
+		}
+		samples = append(samples, sample)
+		if sc.verbose == true {
+			fmt.Println(sample.Time.Format(time.RFC3339), sample.Host, "connections:", sample.Connections.Current)
+		}
+		if time.Now().Add(sc.interval).After(deadline) {
+			return samples, nil
+		}
+		select {
+		case <-ctx.Done():
+			return samples, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// getServerStatusSample returns metrics of a serverStatus document
+func getServerStatusSample(doc bson.Raw) (ServerStatusSample, error) {
+	var status struct {
+		Host        string    `bson:"host"`
+		LocalTime   time.Time `bson:"localTime"`
+		Uptime      float64   `bson:"uptime"`
+		Opcounters  bson.Raw  `bson:"opcounters"`
+		Connections bson.Raw  `bson:"connections"`
+		GlobalLock  struct {
+			CurrentQueue bson.Raw `bson:"currentQueue"`
+		} `bson:"globalLock"`
+		WiredTiger struct {
+			Cache                  bson.Raw `bson:"cache"`
+			ConcurrentTransactions struct {
+				Read  serverStatusTickets `bson:"read"`
+				Write serverStatusTickets `bson:"write"`
+			} `bson:"concurrentTransactions"`
+		} `bson:"wiredTiger"`
+	}
+	var sample ServerStatusSample
+	if err := bson.Unmarshal(doc, &status); err != nil {
+		return sample, err
+	}
+	sample.Time, sample.Host, sample.Uptime = status.LocalTime, status.Host, int64(status.Uptime)
+	for _, elem := range []struct {
+		raw bson.Raw
+		v   interface{}
+	}{{status.Opcounters, &sample.Opcounters}, {status.Connections, &sample.Connections},
+		{status.GlobalLock.CurrentQueue, &sample.Queues}, {status.WiredTiger.Cache, &sample.Cache}} {
+		if len(elem.raw) == 0 {
+			continue // e.g. no wiredTiger of a mongos
+		}
+		if err := bson.Unmarshal(elem.raw, elem.v); err != nil {
+			return sample, err
+		}
+	}
+	tickets := status.WiredTiger.ConcurrentTransactions
+	sample.Tickets.ReadOut, sample.Tickets.ReadAvailable = tickets.Read.Out, tickets.Read.Available
+	sample.Tickets.WriteOut, sample.Tickets.WriteAvailable = tickets.Write.Out, tickets.Write.Available
+	return sample, nil
+}
+
+// appendJSONLine appends a value as a line of JSON to a file
+func appendJSONLine(filename string, value interface{}) error {
+	var err error
+	var data []byte
+	var file *os.File
+	if data, err = json.Marshal(value); err != nil {
+		return err
+	}
+	if file, err = os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// ReadServerStatusSamples reads samples appended to a file by a ServerStatusCollector
+func ReadServerStatusSamples(filename string) ([]ServerStatusSample, error) {
+	var err error
+	var file *os.File
+	samples := []ServerStatusSample{}
+	if file, err = os.Open(filename); err != nil {
+		return samples, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var sample ServerStatusSample
+		if err = json.Unmarshal(line, &sample); err != nil {
+			return samples, fmt.Errorf("%v: %v", filename, err)
+		}
+		samples = append(samples, sample)
+	}
+	return samples, scanner.Err()
+}
+
+// GetServerStatusMetrics returns statistics of metrics of samples, rates of opcounters are between consecutive
+// samples of the same host and skipped across restarts
+func GetServerStatusMetrics(samples []ServerStatusSample) []ServerStatusMetric {
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].Time.Before(samples[j].Time) })
+	series := map[string][]float64{}
+	times := map[string][]time.Time{}
+	names := []string{}
+	add := func(name string, t time.Time, value float64) {
+		if _, ok := series[name]; ok == false {
+			names = append(names, name)
+		}
+		series[name] = append(series[name], value)
+		times[name] = append(times[name], t)
+	}
+	previous := map[string]ServerStatusSample{}
+	for _, s := range samples {
+		if p, ok := previous[s.Host]; ok == true && s.Uptime >= p.Uptime && s.Time.After(p.Time) {
+			seconds := s.Time.Sub(p.Time).Seconds()
+			add("insert/s", s.Time, float64(s.Opcounters.Insert-p.Opcounters.Insert)/seconds)
+			add("query/s", s.Time, float64(s.Opcounters.Query-p.Opcounters.Query)/seconds)
+			add("update/s", s.Time, float64(s.Opcounters.Update-p.Opcounters.Update)/seconds)
+			add("delete/s", s.Time, float64(s.Opcounters.Delete-p.Opcounters.Delete)/seconds)
+			add("getmore/s", s.Time, float64(s.Opcounters.Getmore-p.Opcounters.Getmore)/seconds)
+			add("command/s", s.Time, float64(s.Opcounters.Command-p.Opcounters.Command)/seconds)
+		}
+		previous[s.Host] = s
+		add("connections", s.Time, float64(s.Connections.Current))
+		if s.Cache.MaxBytes > 0 {
+			add("cache used %", s.Time, 100*float64(s.Cache.BytesInCache)/float64(s.Cache.MaxBytes))
+			add("cache dirty %", s.Time, 100*float64(s.Cache.DirtyBytes)/float64(s.Cache.MaxBytes))
+		}
+		add("queued readers", s.Time, float64(s.Queues.Readers))
+		add("queued writers", s.Time, float64(s.Queues.Writers))
+		add("read tickets out", s.Time, float64(s.Tickets.ReadOut))
+		add("write tickets out", s.Time, float64(s.Tickets.WriteOut))
+	}
+	metrics := []ServerStatusMetric{}
+	for _, name := range names {
+		metric := ServerStatusMetric{Name: name, Spikes: []time.Time{}}
+		values := series[name]
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+			metric.Max = math.Max(metric.Max, v)
+		}
+		metric.Avg = sum / float64(len(values))
+		variance := 0.0
+		for _, v := range values {
+			variance += (v - metric.Avg) * (v - metric.Avg) / float64(len(values))
+		}
+		if stddev := math.Sqrt(variance); stddev > 0 {
+			for i, v := range values {
+				if v > metric.Avg+spikeStdDevs*stddev {
+					metric.Spikes = append(metric.Spikes, times[name][i])
+				}
+			}
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics
+}
+
+// GetServerStatusSummary returns averages, maximums, and spikes of metrics of samples
+func GetServerStatusSummary(samples []ServerStatusSample) string {
+	var buffer bytes.Buffer
+	if len(samples) == 0 {
+		return "No serverStatus samples"
+	}
+	metrics := GetServerStatusMetrics(samples)
+	first, last := samples[0].Time, samples[len(samples)-1].Time
+	buffer.WriteString(fmt.Sprintf("%d samples from %v to %v\n", len(samples), first.Format(time.RFC3339), last.Format(time.RFC3339)))
+	buffer.WriteString(fmt.Sprintf("%-20s %12s %12s  %s\n", "metric", "avg", "max", "spikes"))
+	for _, m := range metrics {
+		spikes := ""
+		for i, t := range m.Spikes {
+			if i > 0 {
+				spikes += ", "
+			}
+			spikes += t.Format("15:04:05")
+		}
+		buffer.WriteString(fmt.Sprintf("%-20s %12.1f %12.1f  %s\n", m.Name, m.Avg, m.Max, spikes))
+	}
+	return buffer.String()
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGetServerStatusSample(t *testing.T) {
+	now := time.Now()
+	doc := bson.D{{Key: "host", Value: "keyhole:27017"}, {Key: "localTime", Value: now}, {Key: "uptime", Value: float64(3600)},
+		{Key: "opcounters", Value: bson.D{{Key: "insert", Value: int64(100)}, {Key: "query", Value: int32(50)}}},
+		{Key: "connections", Value: bson.D{{Key: "current", Value: int32(12)}, {Key: "available", Value: int32(800)}}},
+		{Key: "globalLock", Value: bson.D{{Key: "currentQueue", Value: bson.D{{Key: "readers", Value: int32(1)}, {Key: "writers", Value: int32(2)}}}}},
+		{Key: "wiredTiger", Value: bson.D{
+			{Key: "cache", Value: bson.D{{Key: "bytes currently in the cache", Value: int64(512)}, {Key: "maximum bytes configured", Value: float64(1024)}}},
+			{Key: "concurrentTransactions", Value: bson.D{{Key: "read", Value: bson.D{{Key: "out", Value: int32(3)}, {Key: "available", Value: int32(125)}}}}}}}}
+	data, _ := bson.Marshal(doc)
+	sample, err := getServerStatusSample(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sample.Host != "keyhole:27017" || sample.Uptime != 3600 || sample.Opcounters.Insert != 100 || sample.Opcounters.Query != 50 ||
+		sample.Connections.Current != 12 || sample.Queues.Writers != 2 || sample.Cache.MaxBytes != 1024 || sample.Tickets.ReadOut != 3 {
+		t.Fatal(sample)
+	}
+}
+
+func TestGetServerStatusMetrics(t *testing.T) {
+	now := time.Now()
+	samples := []ServerStatusSample{}
+	for i := 0; i < 20; i++ {
+		var s ServerStatusSample
+		s.Host, s.Time, s.Uptime = "keyhole:27017", now.Add(time.Duration(i*10)*time.Second), int64(100+i*10)
+		s.Opcounters.Insert = int64(i * 100)
+		s.Connections.Current = 10
+		if i == 15 {
+			s.Connections.Current = 500
+		}
+		samples = append(samples, s)
+	}
+	samples[19].Uptime, samples[19].Opcounters.Insert = 5, 0 // restarted
+	metrics := GetServerStatusMetrics(samples)
+	for _, m := range metrics {
+		if m.Name == "insert/s" && (m.Avg != 10 || m.Max != 10) {
+			t.Fatal(m)
+		} else if m.Name == "connections" && (len(m.Spikes) != 1 || m.Max != 500) {
+			t.Fatal(m)
+		}
+	}
+	str := GetServerStatusSummary(samples)
+	t.Log(str)
+	if strings.Contains(str, "20 samples") == false || strings.Contains(str, samples[15].Time.Format("15:04:05")) == false {
+		t.Fatal(str)
+	}
+}
+
+func TestReadServerStatusSamples(t *testing.T) {
+	filename := os.TempDir() + "/keyhole_server_status_test.json"
+	os.Remove(filename)
+	defer os.Remove(filename)
+	var sample ServerStatusSample
+	sample.Host, sample.Time = "keyhole:27017", time.Now()
+	for i := 0; i < 2; i++ {
+		if err := appendJSONLine(filename, sample); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if samples, err := ReadServerStatusSamples(filename); err != nil || len(samples) != 2 || samples[1].Host != sample.Host {
+		t.Fatal(samples, err)
+	}
+}
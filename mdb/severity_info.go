@@ -0,0 +1,212 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var severityLogRegex = regexp.MustCompile(`^(\S+)\s+([IWEFD])\d?\s+(\S+)\s+\[[^\]]*\] (.*)$`)
+var messageHexRegex = regexp.MustCompile(`0x[0-9a-fA-F]+`)
+var messageQuotedRegex = regexp.MustCompile(`"[^"]*"`)
+var messageDigitsRegex = regexp.MustCompile(`\d+`)
+
+// severities lists severity levels of logs, from the least to the most severe
+var severities = []string{"D", "I", "W", "E", "F"}
+
+// ComponentCounts holds counts of log lines of a component by severity
+type ComponentCounts struct {
+	Component string
+	Counts    map[string]int
+	Total     int
+}
+
+// SeverityBucket holds counts of warnings, errors, and fatal errors in a time bucket
+type SeverityBucket struct {
+	Time     time.Time
+	Warnings int
+	Errors   int
+	Fatals   int
+}
+
+// MessageCount holds occurrences of a warning or an error message, numbers and strings are masked
+type MessageCount struct {
+	Component string
+	Count     int
+	Example   string
+	Message   string
+	Severity  string
+}
+
+// SeverityInfo tallies log lines by component and severity over time as a first-pass health summary
+type SeverityInfo struct {
+	Buckets     []SeverityBucket
+	Components  []ComponentCounts
+	TopMessages []MessageCount
+	filenames   []string
+	span        int
+	verbose     bool
+}
+
+// NewSeverityInfo returns SeverityInfo of log files, directories, or glob patterns
+func NewSeverityInfo(filenames []string) *SeverityInfo {
+	return &SeverityInfo{filenames: filenames, span: 3600}
+}
+
+// SetSpan sets seconds of a time bucket, 1 hour by default
+func (si *SeverityInfo) SetSpan(span int) {
+	if span > 0 {
+		si.span = span
+	}
+}
+
+// SetVerbose sets verbose level
+func (si *SeverityInfo) SetVerbose(verbose bool) {
+	si.verbose = verbose
+}
+
+// Parse tallies log lines by component and severity
+func (si *SeverityInfo) Parse() error {
+	components := map[string]*ComponentCounts{}
+	buckets := map[int64]*SeverityBucket{}
+	messages := map[string]*MessageCount{}
+	err := readLogLines(si.filenames, func(str string) {
+		result := severityLogRegex.FindStringSubmatch(str)
+		if len(result) == 0 {
+			return
+		}
+		severity, component := result[2], result[3]
+		if components[component] == nil {
+			components[component] = &ComponentCounts{Component: component, Counts: map[string]int{}}
+		}
+		components[component].Counts[severity]++
+		components[component].Total++
+		if severity == "I" || severity == "D" {
+			return
+		}
+		if t := getLogTime(result[1]); t.IsZero() == false {
+			t = t.Truncate(time.Duration(si.span) * time.Second)
+			if buckets[t.Unix()] == nil {
+				buckets[t.Unix()] = &SeverityBucket{Time: t}
+			}
+			switch severity {
+			case "W":
+				buckets[t.Unix()].Warnings++
+			case "E":
+				buckets[t.Unix()].Errors++
+			case "F":
+				buckets[t.Unix()].Fatals++
+			}
+		}
+		message := getMessagePattern(result[4])
+		key := severity + " " + component + " " + message
+		if messages[key] == nil {
+			messages[key] = &MessageCount{Component: component, Example: result[4], Message: message, Severity: severity}
+		}
+		messages[key].Count++
+	})
+	if err != nil {
+		return err
+	}
+
+	si.Components = []ComponentCounts{}
+	for _, c := range components {
+		si.Components = append(si.Components, *c)
+	}
+	sort.Slice(si.Components, func(i, j int) bool {
+		if si.Components[i].Total == si.Components[j].Total {
+			return si.Components[i].Component < si.Components[j].Component
+		}
+		return si.Components[i].Total > si.Components[j].Total
+	})
+	si.Buckets = []SeverityBucket{}
+	for _, b := range buckets {
+		si.Buckets = append(si.Buckets, *b)
+	}
+	sort.Slice(si.Buckets, func(i, j int) bool { return si.Buckets[i].Time.Before(si.Buckets[j].Time) })
+	si.TopMessages = []MessageCount{}
+	for _, m := range messages {
+		si.TopMessages = append(si.TopMessages, *m)
+	}
+	sort.Slice(si.TopMessages, func(i, j int) bool {
+		x, y := si.TopMessages[i], si.TopMessages[j]
+		if x.Count == y.Count {
+			if x.Severity == y.Severity {
+				return x.Message < y.Message
+			}
+			return getSeverityLevel(x.Severity) > getSeverityLevel(y.Severity)
+		}
+		return x.Count > y.Count
+	})
+	if len(si.TopMessages) > 10 {
+		si.TopMessages = si.TopMessages[:10]
+	}
+	return nil
+}
+
+// getSeverityLevel returns order of a severity, the greater the more severe
+func getSeverityLevel(severity string) int {
+	for i, s := range severities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// getMessagePattern masks numbers and quoted strings of a message, e.g. WiredTiger error (N)
+func getMessagePattern(message string) string {
+	message = messageHexRegex.ReplaceAllString(message, "X")
+	message = messageQuotedRegex.ReplaceAllString(message, `"..."`)
+	message = messageDigitsRegex.ReplaceAllString(message, "N")
+	if len(message) > 120 {
+		message = message[:117] + "..."
+	}
+	return message
+}
+
+// GetSummary returns counts by component and severity and the most frequent warnings and errors,
+// examples of messages are listed in verbose mode
+func (si *SeverityInfo) GetSummary() string {
+	var buffer bytes.Buffer
+	totals := map[string]int{}
+	for _, c := range si.Components {
+		for s, n := range c.Counts {
+			totals[s] += n
+		}
+	}
+	strs := []string{}
+	for _, s := range severities {
+		strs = append(strs, fmt.Sprintf("%s: %d", s, totals[s]))
+	}
+	buffer.WriteString("Log lines by severity, " + strings.Join(strs, ", ") + "\n")
+	if len(si.Components) > 0 {
+		buffer.WriteString(fmt.Sprintf("\n%-16s %8s %8s %8s %8s %8s %8s\n", "component", "total", "D", "I", "W", "E", "F"))
+		for _, c := range si.Components {
+			buffer.WriteString(fmt.Sprintf("%-16s %8d %8d %8d %8d %8d %8d\n", c.Component, c.Total, c.Counts["D"], c.Counts["I"],
+				c.Counts["W"], c.Counts["E"], c.Counts["F"]))
+		}
+	}
+	if len(si.Buckets) > 0 {
+		buffer.WriteString(fmt.Sprintf("\nWarnings and errors every %d seconds:\n", si.span))
+		buffer.WriteString(fmt.Sprintf("%-25s %8s %8s %8s\n", "time", "W", "E", "F"))
+		for _, b := range si.Buckets {
+			buffer.WriteString(fmt.Sprintf("%-25s %8d %8d %8d\n", b.Time.Format(time.RFC3339), b.Warnings, b.Errors, b.Fatals))
+		}
+	}
+	if len(si.TopMessages) > 0 {
+		buffer.WriteString(fmt.Sprintf("\nTop %d warning and error messages:\n", len(si.TopMessages)))
+		for _, m := range si.TopMessages {
+			buffer.WriteString(fmt.Sprintf("%8d %s %-10s %s\n", m.Count, m.Severity, m.Component, m.Message))
+			if si.verbose == true {
+				buffer.WriteString(fmt.Sprintf("%21s e.g. %s\n", "", m.Example))
+			}
+		}
+	}
+	return buffer.String()
+}
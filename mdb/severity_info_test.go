@@ -0,0 +1,37 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSeverityInfo(t *testing.T) {
+	si := NewSeverityInfo([]string{"testdata/mongod.log"})
+	si.SetVerbose(true)
+	if err := si.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]ComponentCounts{}
+	for _, c := range si.Components {
+		counts[c.Component] = c
+	}
+	if counts["COMMAND"].Counts["W"] != 2 || counts["STORAGE"].Counts["E"] != 1 || counts["NETWORK"].Counts["W"] != 1 ||
+		counts["CONTROL"].Counts["I"] != 3 {
+		t.Fatal(si.Components)
+	}
+	if len(si.Buckets) != 1 || si.Buckets[0].Warnings != 3 || si.Buckets[0].Errors != 1 {
+		t.Fatal(si.Buckets)
+	}
+	if len(si.TopMessages) != 3 || si.TopMessages[0].Count != 2 || si.TopMessages[0].Component != "COMMAND" ||
+		si.TopMessages[1].Severity != "E" {
+		t.Fatal(si.TopMessages)
+	}
+	if si.TopMessages[1].Message != "WiredTiger error (N) [N:N][N:X], file:collection-N--N.wt: No space left on device" {
+		t.Fatal(si.TopMessages[1].Message)
+	}
+	if strings.Contains(si.GetSummary(), "Top 3 warning and error messages") == false {
+		t.Fatal(si.GetSummary())
+	}
+}
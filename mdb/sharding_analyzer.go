@@ -0,0 +1,260 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultMigrations is number of recent migrations to report
+const defaultMigrations = 20
+
+// ShardingAnalyzer reports chunks distribution, balancer state, and migrations of a sharded cluster
+type ShardingAnalyzer struct {
+	client     *mongo.Client
+	migrations int
+	verbose    bool
+}
+
+// ChunkDistribution holds chunks of a sharded collection by shards
+type ChunkDistribution struct {
+	Namespace    string         `json:"namespace"`
+	ShardKey     string         `json:"shardKey"`
+	Chunks       map[string]int `json:"chunks"` // chunks counts by shards
+	Jumbo        int            `json:"jumbo"`
+	Total        int            `json:"total"`
+	IsImbalanced bool           `json:"imbalanced"`
+}
+
+// MigrationDoc is a chunk migration from config.changelog
+type MigrationDoc struct {
+	Time      time.Time `json:"time"`
+	Namespace string    `json:"namespace"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	What      string    `json:"what"` // moveChunk.commit or moveChunk.error
+}
+
+// ShardingReport holds chunks distribution, balancer state, and recent migrations
+type ShardingReport struct {
+	BalancerMode    string              `json:"balancerMode"` // full or off
+	BalancerRunning bool                `json:"balancerRunning"`
+	Collections     []ChunkDistribution `json:"collections"`
+	Migrations      []MigrationDoc      `json:"migrations"` // the most recent first
+	Shards          []string            `json:"shards"`
+}
+
+// NewShardingAnalyzer returns a ShardingAnalyzer of a mongos
+func NewShardingAnalyzer(client *mongo.Client) *ShardingAnalyzer {
+	return &ShardingAnalyzer{client: client, migrations: defaultMigrations}
+}
+
+// SetMigrations sets number of recent migrations to report
+func (sa *ShardingAnalyzer) SetMigrations(migrations int) {
+	sa.migrations = migrations
+}
+
+// SetVerbose sets verbose level
+func (sa *ShardingAnalyzer) SetVerbose(verbose bool) {
+	sa.verbose = verbose
+}
+
+// GetShardingReport reads config database and balancerStatus of a mongos
+func (sa *ShardingAnalyzer) GetShardingReport() (ShardingReport, error) {
+	var err error
+	var status bson.M
+	report := ShardingReport{Collections: []ChunkDistribution{}, Migrations: []MigrationDoc{}}
+	if status, err = RunAdminCommand(sa.client, "balancerStatus"); err != nil {
+		return report, err
+	}
+	report.BalancerMode = toString(status["mode"])
+	report.BalancerRunning, _ = status["inBalancerRound"].(bool)
+	if report.Shards, err = sa.getShardNames(); err != nil {
+		return report, err
+	}
+	if report.Collections, err = sa.getChunkDistributions(report.Shards); err != nil {
+		return report, err
+	}
+	report.Migrations, err = sa.getMigrations()
+	return report, err
+}
+
+func (sa *ShardingAnalyzer) getShardNames() ([]string, error) {
+	var err error
+	var cur *mongo.Cursor
+	ctx := context.Background()
+	names := []string{}
+	if cur, err = sa.client.Database("config").Collection("shards").Find(ctx, bson.M{}); err != nil {
+		return names, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var doc ShardDoc
+		if err = cur.Decode(&doc); err != nil {
+			return names, err
+		}
+		names = append(names, doc.ID)
+	}
+	sort.Strings(names)
+	return names, cur.Err()
+}
+
+// getChunkDistributions counts chunks of sharded collections by shards, chunks are of namespaces before
+// 5.0 and of collection UUIDs since
+func (sa *ShardingAnalyzer) getChunkDistributions(shards []string) ([]ChunkDistribution, error) {
+	var err error
+	var cur *mongo.Cursor
+	ctx := context.Background()
+	distributions := map[string]*ChunkDistribution{}
+	uuids := map[string]string{}
+	filter := bson.D{{Key: "dropped", Value: bson.D{{Key: "$ne", Value: true}}}}
+	if cur, err = sa.client.Database("config").Collection("collections").Find(ctx, filter); err != nil {
+		return nil, err
+	}
+	for cur.Next(ctx) {
+		var doc struct {
+			ID   string           `bson:"_id"`
+			Key  bson.D           `bson:"key"`
+			UUID primitive.Binary `bson:"uuid"`
+		}
+		if err = cur.Decode(&doc); err != nil {
+			cur.Close(ctx)
+			return nil, err
+		}
+		distributions[doc.ID] = &ChunkDistribution{Namespace: doc.ID, ShardKey: getShapeString(doc.Key), Chunks: map[string]int{}}
+		if len(doc.UUID.Data) > 0 {
+			uuids[fmt.Sprintf("%x", doc.UUID.Data)] = doc.ID
+		}
+	}
+	cur.Close(ctx)
+
+	pipeline := []bson.D{{{Key: "$group", Value: bson.D{
+		{Key: "_id", Value: bson.D{{Key: "ns", Value: "$ns"}, {Key: "uuid", Value: "$uuid"}, {Key: "shard", Value: "$shard"}}},
+		{Key: "chunks", Value: bson.D{{Key: "$sum", Value: 1}}},
+		{Key: "jumbo", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{bson.D{{Key: "$eq", Value: bson.A{"$jumbo", true}}}, 1, 0}}}}}}}}}}
+	if cur, err = sa.client.Database("config").Collection("chunks").Aggregate(ctx, pipeline); err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var doc bson.M
+		if err = cur.Decode(&doc); err != nil {
+			return nil, err
+		}
+		id, _ := doc["_id"].(bson.M)
+		ns := toString(id["ns"])
+		if uuid, ok := id["uuid"].(primitive.Binary); ok == true {
+			ns = uuids[fmt.Sprintf("%x", uuid.Data)]
+		}
+		d, ok := distributions[ns]
+		if ok == false {
+			continue
+		}
+		d.Chunks[toString(id["shard"])] += toInt(doc["chunks"])
+		d.Jumbo += toInt(doc["jumbo"])
+	}
+	list := []ChunkDistribution{}
+	for _, d := range distributions {
+		for _, count := range d.Chunks {
+			d.Total += count
+		}
+		if d.Total == 0 { // e.g. config.system.sessions before its chunks are created
+			continue
+		}
+		d.IsImbalanced = isChunksImbalanced(d.Chunks, shards)
+		list = append(list, *d)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Namespace < list[j].Namespace })
+	return list, cur.Err()
+}
+
+// getMigrationThreshold returns the difference of chunks between shards the balancer migrates at
+func getMigrationThreshold(total int) int {
+	if total < 20 {
+		return 2
+	} else if total < 80 {
+		return 4
+	}
+	return 8
+}
+
+// isChunksImbalanced returns true if the difference of chunks between the most and the least loaded shards
+// reaches the migration threshold, shards without chunks count as 0
+func isChunksImbalanced(chunks map[string]int, shards []string) bool {
+	total, max, min := 0, 0, -1
+	for _, count := range chunks {
+		total += count
+	}
+	for _, shard := range shards {
+		count := chunks[shard]
+		if count > max {
+			max = count
+		}
+		if min < 0 || count < min {
+			min = count
+		}
+	}
+	return len(shards) > 1 && max-min >= getMigrationThreshold(total)
+}
+
+// getMigrations returns recent chunk migrations from config.changelog
+func (sa *ShardingAnalyzer) getMigrations() ([]MigrationDoc, error) {
+	var err error
+	var cur *mongo.Cursor
+	ctx := context.Background()
+	migrations := []MigrationDoc{}
+	filter := bson.D{{Key: "what", Value: bson.D{{Key: "$in", Value: bson.A{"moveChunk.commit", "moveChunk.error"}}}}}
+	opts := options.Find().SetSort(bson.D{{Key: "time", Value: -1}}).SetLimit(int64(sa.migrations))
+	if cur, err = sa.client.Database("config").Collection("changelog").Find(ctx, filter, opts); err != nil {
+		return migrations, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var doc struct {
+			Time    time.Time `bson:"time"`
+			NS      string    `bson:"ns"`
+			What    string    `bson:"what"`
+			Details bson.M    `bson:"details"`
+		}
+		if err = cur.Decode(&doc); err != nil {
+			return migrations, err
+		}
+		migrations = append(migrations, MigrationDoc{Time: doc.Time, Namespace: doc.NS, What: doc.What,
+			From: toString(doc.Details["from"]), To: toString(doc.Details["to"])})
+	}
+	return migrations, cur.Err()
+}
+
+// GetSummary returns balancer state, chunks distribution, and recent migrations
+func (report ShardingReport) GetSummary() string {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("Balancer mode: %s, in balancer round: %v\n", report.BalancerMode, report.BalancerRunning))
+	buffer.WriteString("\nChunks distribution:\n")
+	for _, d := range report.Collections {
+		mark := "  "
+		if d.IsImbalanced {
+			mark = "x "
+		}
+		buffer.WriteString(fmt.Sprintf("%s%s %s, chunks: %d, jumbo: %d\n", mark, d.Namespace, d.ShardKey, d.Total, d.Jumbo))
+		for _, shard := range report.Shards {
+			buffer.WriteString(fmt.Sprintf("\t%s: %d\n", shard, d.Chunks[shard]))
+		}
+	}
+	buffer.WriteString("\nRecent migrations:\n")
+	if len(report.Migrations) == 0 {
+		buffer.WriteString("\tnone\n")
+	}
+	for _, m := range report.Migrations {
+		buffer.WriteString(fmt.Sprintf("\t%s %s %s -> %s %s\n", m.Time.Format(time.RFC3339), m.Namespace, m.From, m.To, m.What))
+	}
+	return buffer.String()
+}
@@ -0,0 +1,45 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsChunksImbalanced(t *testing.T) {
+	shards := []string{"shard0", "shard1", "shard2"}
+	if isChunksImbalanced(map[string]int{"shard0": 5, "shard1": 4, "shard2": 4}, shards) == true {
+		t.Fatal("expected balanced")
+	}
+	if isChunksImbalanced(map[string]int{"shard0": 10, "shard1": 5}, shards) == false {
+		t.Fatal("expected imbalanced, shard2 has no chunks")
+	}
+	if isChunksImbalanced(map[string]int{"shard0": 100, "shard1": 95, "shard2": 94}, shards) == true {
+		t.Fatal("expected balanced under the threshold of 8")
+	}
+	if isChunksImbalanced(map[string]int{"shard0": 10}, []string{"shard0"}) == true {
+		t.Fatal("a single shard is balanced")
+	}
+}
+
+func TestGetMigrationThreshold(t *testing.T) {
+	for total, threshold := range map[int]int{1: 2, 19: 2, 20: 4, 79: 4, 80: 8, 10000: 8} {
+		if n := getMigrationThreshold(total); n != threshold {
+			t.Fatal(total, n)
+		}
+	}
+}
+
+func TestShardingReportSummary(t *testing.T) {
+	report := ShardingReport{BalancerMode: "full", Shards: []string{"shard0", "shard1"},
+		Collections: []ChunkDistribution{{Namespace: "keyhole.cars", ShardKey: "{color: 1}", Chunks: map[string]int{"shard0": 12},
+			Total: 12, Jumbo: 1, IsImbalanced: true}},
+		Migrations: []MigrationDoc{{Time: time.Now(), Namespace: "keyhole.cars", From: "shard0", To: "shard1", What: "moveChunk.commit"}}}
+	str := report.GetSummary()
+	t.Log(str)
+	if strings.Contains(str, "x keyhole.cars {color: 1}, chunks: 12, jumbo: 1") == false || strings.Contains(str, "shard1: 0") == false {
+		t.Fatal(str)
+	}
+}
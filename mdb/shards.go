@@ -3,6 +3,7 @@
 package mdb
 
 import (
+	"context"
 	"encoding/json"
 	"strings"
 
@@ -73,6 +74,45 @@ func GetShards(client *mongo.Client, uri string) ([]string, error) {
 	return list, nil
 }
 
+// GetShardKeys returns shard key fields of sharded collections from config metadata
+func GetShardKeys(client *mongo.Client) (map[string][]string, error) {
+	var err error
+	var cur *mongo.Cursor
+	ctx := context.Background()
+	keys := map[string][]string{}
+	filter := bson.D{{Key: "dropped", Value: bson.D{{Key: "$ne", Value: true}}}}
+	if cur, err = client.Database("config").Collection("collections").Find(ctx, filter); err != nil {
+		return keys, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var ns string
+		var fields []string
+		if ns, fields, err = getShardKey(cur.Current); err != nil {
+			return keys, err
+		} else if len(fields) > 0 {
+			keys[ns] = fields
+		}
+	}
+	return keys, cur.Err()
+}
+
+// getShardKey returns the namespace and shard key fields, in order, of a document of config.collections
+func getShardKey(data bson.Raw) (string, []string, error) {
+	var doc struct {
+		ID  string `bson:"_id"`
+		Key bson.D `bson:"key"`
+	}
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return "", nil, err
+	}
+	fields := []string{}
+	for _, elem := range doc.Key {
+		fields = append(fields, elem.Key)
+	}
+	return doc.ID, fields, nil
+}
+
 // GetShardsURIList gets shards list
 func GetShardsURIList(client *mongo.Client, uri string) ([]string, error) {
 	var uriList []string
@@ -4,8 +4,12 @@ package mdb
 
 import (
 	"context"
+	"strings"
 	"testing"
+	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -28,3 +32,52 @@ func TestGetShardsURIList(t *testing.T) {
 		return
 	}
 }
+
+func TestGetShardKey(t *testing.T) {
+	for _, c := range []struct {
+		doc    bson.D
+		ns     string
+		fields []string
+	}{
+		{bson.D{{Key: "_id", Value: "keyhole.cars"}, {Key: "lastmodEpoch", Value: primitive.NewObjectID()},
+			{Key: "key", Value: bson.D{{Key: "dealer", Value: 1}, {Key: "vin", Value: 1}}}, {Key: "unique", Value: false}},
+			"keyhole.cars", []string{"dealer", "vin"}},
+		{bson.D{{Key: "_id", Value: "keyhole.dealers"}, {Key: "key", Value: bson.D{{Key: "_id", Value: "hashed"}}}},
+			"keyhole.dealers", []string{"_id"}},
+	} {
+		data, err := bson.Marshal(c.doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ns, fields, err := getShardKey(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ns != c.ns || strings.Join(fields, ",") != strings.Join(c.fields, ",") {
+			t.Fatal("expected", c.ns, c.fields, "but got", ns, fields)
+		}
+	}
+	if _, _, err := getShardKey(bson.Raw{0x05}); err == nil {
+		t.Fatal("expected an error of an invalid document")
+	}
+}
+
+func TestGetShardKeys(t *testing.T) {
+	var client *mongo.Client
+	client = getMongoClient()
+	defer client.Disconnect(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Skip("MongoDB unavailable:", err)
+	}
+	keys, err := GetShardKeys(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ns, fields := range keys {
+		if strings.Contains(ns, ".") == false || len(fields) == 0 {
+			t.Fatal("expected shard key fields of a namespace, but got", ns, fields)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// shellLiteral is a value kept as is, e.g. new Date(1569679200000), ObjectId('...'), or true
+type shellLiteral string
+
+// shellParser parses documents of mongo shell syntax in logs
+type shellParser struct {
+	str string
+	pos int
+}
+
+// parseShellDoc parses a document of logs, e.g. { color: "Red", year: { $gt: 2017 } }
+func parseShellDoc(str string) (bson.D, error) {
+	var err error
+	var doc bson.D
+	p := &shellParser{str: str}
+	p.skipSpaces()
+	if doc, err = p.parseDoc(); err != nil {
+		return doc, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.str) {
+		return doc, p.errorf("unexpected %q", p.str[p.pos:])
+	}
+	return doc, nil
+}
+
+// parseQueryPattern parses a query pattern of ops patterns, returns the filter and group and sort stages if any,
+// e.g. {a: 1, b: {$in: [... ]}}, group: {_id: "$c"}, sort: {d: -1}
+func parseQueryPattern(pattern string) (bson.D, bson.D, error) {
+	var err error
+	var filter, stages bson.D
+	pattern = strings.Replace(pattern, "[... ]", "[]", -1)
+	end := getDocEnd(pattern, 0)
+	if filter, err = parseShellDoc(pattern[:end]); err != nil {
+		return filter, stages, err
+	}
+	if rest := strings.TrimPrefix(pattern[end:], ", "); rest != "" {
+		stages, err = parseShellDoc("{" + rest + "}")
+	}
+	return filter, stages, err
+}
+
+// getShapeString returns a value in a compact form, fields order and values are kept, e.g. {_id: "$color"}
+func getShapeString(value interface{}) string {
+	switch v := value.(type) {
+	case bson.D:
+		strs := []string{}
+		for _, elem := range v {
+			strs = append(strs, elem.Key+": "+getShapeString(elem.Value))
+		}
+		return "{" + strings.Join(strs, ", ") + "}"
+	case primitive.A:
+		strs := []string{}
+		for _, elem := range v {
+			strs = append(strs, getShapeString(elem))
+		}
+		return "[" + strings.Join(strs, ", ") + "]"
+	case primitive.Regex:
+		return "/" + v.Pattern + "/" + v.Options
+	case string:
+		return strconv.Quote(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func (p *shellParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("position %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *shellParser) skipSpaces() {
+	for p.pos < len(p.str) && (p.str[p.pos] == ' ' || p.str[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// expect skips spaces and consumes c
+func (p *shellParser) expect(c byte) error {
+	p.skipSpaces()
+	if p.pos >= len(p.str) || p.str[p.pos] != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+// peek skips spaces and returns the next character, 0 at the end
+func (p *shellParser) peek() byte {
+	p.skipSpaces()
+	if p.pos >= len(p.str) {
+		return 0
+	}
+	return p.str[p.pos]
+}
+
+func (p *shellParser) parseDoc() (bson.D, error) {
+	var err error
+	doc := bson.D{}
+	if err = p.expect('{'); err != nil {
+		return doc, err
+	}
+	if p.peek() == '}' {
+		p.pos++
+		return doc, nil
+	}
+	for {
+		var key string
+		var value interface{}
+		if key, err = p.parseKey(); err != nil {
+			return doc, err
+		}
+		if err = p.expect(':'); err != nil {
+			return doc, err
+		}
+		if value, err = p.parseValue(); err != nil {
+			return doc, err
+		}
+		doc = append(doc, bson.E{Key: key, Value: value})
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return doc, nil
+		default:
+			return doc, p.errorf("expected , or }")
+		}
+	}
+}
+
+func (p *shellParser) parseArray() (primitive.A, error) {
+	var err error
+	arr := primitive.A{}
+	if err = p.expect('['); err != nil {
+		return arr, err
+	}
+	if p.peek() == ']' {
+		p.pos++
+		return arr, nil
+	}
+	for {
+		var value interface{}
+		if value, err = p.parseValue(); err != nil {
+			return arr, err
+		}
+		arr = append(arr, value)
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return arr, nil
+		default:
+			return arr, p.errorf("expected , or ]")
+		}
+	}
+}
+
+// parseKey returns a field name, either quoted or not, e.g. "attribs.color" or $gt
+func (p *shellParser) parseKey() (string, error) {
+	c := p.peek()
+	if c == '"' || c == '\'' {
+		return p.parseString()
+	}
+	begin := p.pos
+	for p.pos < len(p.str) && p.str[p.pos] != ':' && p.str[p.pos] != ' ' {
+		p.pos++
+	}
+	if p.pos == begin {
+		return "", p.errorf("expected a field name")
+	}
+	return p.str[begin:p.pos], nil
+}
+
+func (p *shellParser) parseString() (string, error) {
+	quote := p.str[p.pos]
+	begin := p.pos
+	p.pos++
+	for p.pos < len(p.str) {
+		if p.str[p.pos] == '\\' {
+			p.pos += 2
+			continue
+		}
+		if p.str[p.pos] == quote {
+			p.pos++
+			raw := p.str[begin:p.pos]
+			if quote == '\'' {
+				return raw[1 : len(raw)-1], nil
+			}
+			str, err := strconv.Unquote(raw)
+			if err != nil {
+				return raw[1 : len(raw)-1], nil
+			}
+			return str, nil
+		}
+		p.pos++
+	}
+	return "", errors.New("unterminated string")
+}
+
+func (p *shellParser) parseRegex() (primitive.Regex, error) {
+	begin := p.pos + 1
+	p.pos++
+	for p.pos < len(p.str) {
+		if p.str[p.pos] == '\\' {
+			p.pos += 2
+			continue
+		}
+		if p.str[p.pos] == '/' {
+			pattern := p.str[begin:p.pos]
+			p.pos++
+			options := p.pos
+			for p.pos < len(p.str) && strings.IndexByte("imxslu", p.str[p.pos]) >= 0 {
+				p.pos++
+			}
+			return primitive.Regex{Pattern: pattern, Options: p.str[options:p.pos]}, nil
+		}
+		p.pos++
+	}
+	return primitive.Regex{}, errors.New("unterminated regex")
+}
+
+// parseLiteral returns numbers as float64, others as is, e.g. true, MinKey, new Date(0), or UUID("...")
+func (p *shellParser) parseLiteral() (interface{}, error) {
+	begin := p.pos
+	depth := 0
+	for p.pos < len(p.str) {
+		c := p.str[p.pos]
+		if c == '"' || c == '\'' {
+			if _, err := p.parseString(); err != nil {
+				return nil, err
+			}
+			continue
+		} else if c == '(' {
+			depth++
+		} else if c == ')' {
+			depth--
+		} else if depth == 0 && (c == ',' || c == '}' || c == ']') {
+			break
+		}
+		p.pos++
+	}
+	if depth != 0 {
+		return nil, p.errorf("unbalanced parentheses")
+	}
+	str := strings.TrimSpace(p.str[begin:p.pos])
+	if str == "" {
+		return nil, p.errorf("expected a value")
+	} else if str == "..." { // truncated by mongod
+		return nil, p.errorf("truncated value")
+	}
+	if f, err := strconv.ParseFloat(str, 64); err == nil {
+		return f, nil
+	}
+	return shellLiteral(str), nil
+}
+
+func (p *shellParser) parseValue() (interface{}, error) {
+	switch p.peek() {
+	case '{':
+		return p.parseDoc()
+	case '[':
+		return p.parseArray()
+	case '"', '\'':
+		return p.parseString()
+	case '/':
+		return p.parseRegex()
+	case 0:
+		return nil, p.errorf("unexpected end")
+	default:
+		return p.parseLiteral()
+	}
+}
+
+// toBSONValue returns a value of logs with literals converted to BSON values, e.g. NumberLong(1) to int64
+// and new Date(0) to a date, unknown literals are kept as strings
+func toBSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.D:
+		doc := bson.D{}
+		for _, elem := range v {
+			doc = append(doc, bson.E{Key: elem.Key, Value: toBSONValue(elem.Value)})
+		}
+		return doc
+	case primitive.A:
+		arr := primitive.A{}
+		for _, elem := range v {
+			arr = append(arr, toBSONValue(elem))
+		}
+		return arr
+	case shellLiteral:
+		str := string(v)
+		arg := ""
+		if begin, end := strings.Index(str, "("), strings.LastIndex(str, ")"); begin > 0 && end > begin {
+			arg = strings.Trim(str[begin+1:end], `"' `)
+		}
+		switch {
+		case str == "true" || str == "false":
+			return str == "true"
+		case str == "null":
+			return nil
+		case strings.HasPrefix(str, "new Date("):
+			ms, _ := strconv.ParseInt(arg, 10, 64)
+			return primitive.DateTime(ms)
+		case strings.HasPrefix(str, "ObjectId("):
+			if oid, err := primitive.ObjectIDFromHex(arg); err == nil {
+				return oid
+			}
+		case strings.HasPrefix(str, "NumberLong("):
+			n, _ := strconv.ParseInt(arg, 10, 64)
+			return n
+		case strings.HasPrefix(str, "NumberInt("):
+			n, _ := strconv.ParseInt(arg, 10, 32)
+			return int32(n)
+		case strings.HasPrefix(str, "NumberDecimal("):
+			if d, err := primitive.ParseDecimal128(arg); err == nil {
+				return d
+			}
+		}
+		return str
+	default:
+		return v
+	}
+}
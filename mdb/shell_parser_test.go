@@ -0,0 +1,55 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseShellDoc(t *testing.T) {
+	str := `{ name: "a \"quoted\" }", "attribs.color": 'Red', ts: new Date(1569679200000), _id: ObjectId('5d8f6c4e2f3b4c0001a1b2c3'), ` +
+		`lsid: { id: UUID("0a4e7a57-a1fa-4cdb-a5c3-0d0a2a5b4b6c") }, brand: /^BM/i, n: -1.5, ok: true, tags: [ 1, "x", { a: 1 } ] }`
+	doc, err := parseShellDoc(str)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc) != 9 || doc[0].Value != `a "quoted" }` || doc[1].Key != "attribs.color" || doc[6].Value != -1.5 {
+		t.Fatal(doc)
+	}
+	expected := `{name: "a \"quoted\" }", attribs.color: "Red", ts: new Date(1569679200000), _id: ObjectId('5d8f6c4e2f3b4c0001a1b2c3'), ` +
+		`lsid: {id: UUID("0a4e7a57-a1fa-4cdb-a5c3-0d0a2a5b4b6c")}, brand: /^BM/i, n: -1.5, ok: true, tags: [1, "x", {a: 1}]}`
+	if shape := getShapeString(doc); shape != expected {
+		t.Fatal(shape)
+	}
+	if _, err = parseShellDoc(`{ name: "Ken", address: { city: ... } }`); err == nil {
+		t.Fatal("expected an error of a truncated document")
+	}
+}
+
+func TestGetFilterPattern(t *testing.T) {
+	a := getFilterPattern(`{ $or: [ { year: { $gt: 2017 }, color: "Red" }, { brand: { $in: [ "BMW", "Audi" ] } } ], ` +
+		`attribs: { $elemMatch: { v: 1, k: "color" } } }`)
+	b := getFilterPattern(`{ attribs: { $elemMatch: { k: "style", v: 2 } }, ` +
+		`$or: [ { brand: { $in: [ "Ford" ] } }, { color: "Blue", year: { $gt: 2010 } } ] }`)
+	expected := "{$or: [{brand: {$in: [... ]}}, {color: 1, year: {$gt: 1}}], attribs: {$elemMatch: {k: 1, v: 1}}}"
+	if a != expected || b != expected {
+		t.Fatal(a, b)
+	}
+	if pattern := getFilterPattern(`{ color: "Red", name: "Ke... }`); strings.HasPrefix(pattern, "{color: 1") == false {
+		t.Fatal(pattern)
+	}
+}
+
+func TestParseQueryPattern(t *testing.T) {
+	filter, stages, err := parseQueryPattern(`{color: {$in: [... ]}, name: /regex/}, group: {_id: "$year"}, sort: {year: -1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filter) != 2 || filter[0].Key != "color" || len(stages) != 2 || stages[0].Key != "group" || stages[1].Key != "sort" {
+		t.Fatal(filter, stages)
+	}
+	if _, _, err = parseQueryPattern(`{ color: "Red" , truncated`); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultTTLSampleSize is number of documents sampled to estimate expired documents
+const defaultTTLSampleSize = 1000
+
+// defaultTTLMonitorSleepSecs is the default interval of the TTL monitor
+const defaultTTLMonitorSleepSecs = 60
+
+// ttlBehindPasses is number of passes of the TTL monitor an expired document can stay before it's behind
+const ttlBehindPasses = 10
+
+// TTLChecker audits TTL indexes and the TTL monitor
+type TTLChecker struct {
+	client     *mongo.Client
+	dbName     string
+	sampleSize int
+	verbose    bool
+}
+
+// TTLIndexDoc holds a TTL index and its expired documents
+type TTLIndexDoc struct {
+	Namespace          string        `json:"namespace"`
+	Name               string        `json:"name"`
+	Field              string        `json:"field"`
+	ExpireAfterSeconds int           `json:"expireAfterSeconds"`
+	Count              int64         `json:"count"`            // estimated documents of the collection
+	Sampled            int           `json:"sampled"`          // documents sampled
+	SampledExpired     int           `json:"sampledExpired"`   // expired documents of sampled
+	EstimatedExpired   int64         `json:"estimatedExpired"` // expired but present documents estimated from sampled
+	Oldest             time.Time     `json:"oldest"`           // the oldest value of the field
+	Lag                time.Duration `json:"lag"`              // time the oldest expired document stays after expiration
+	IsBehind           bool          `json:"behind"`
+	Note               string        `json:"note,omitempty"` // e.g. compound indexes don't expire documents
+}
+
+// TTLReport holds TTL indexes and metrics of the TTL monitor
+type TTLReport struct {
+	DeletedDocuments int64         `json:"deletedDocuments"` // metrics.ttl.deletedDocuments of serverStatus
+	Indexes          []TTLIndexDoc `json:"indexes"`
+	MonitorSleepSecs int           `json:"ttlMonitorSleepSecs"`
+	Passes           int64         `json:"passes"` // metrics.ttl.passes of serverStatus
+}
+
+// NewTTLChecker returns a TTLChecker
+func NewTTLChecker(client *mongo.Client) *TTLChecker {
+	return &TTLChecker{client: client, sampleSize: defaultTTLSampleSize}
+}
+
+// SetDBName sets database name, all databases if empty
+func (tc *TTLChecker) SetDBName(dbName string) {
+	tc.dbName = dbName
+}
+
+// SetSampleSize sets number of documents sampled per collection
+func (tc *TTLChecker) SetSampleSize(sampleSize int) {
+	tc.sampleSize = sampleSize
+}
+
+// SetVerbose sets verbose level
+func (tc *TTLChecker) SetVerbose(verbose bool) {
+	tc.verbose = verbose
+}
+
+// GetTTLReport lists TTL indexes, samples their expired but present documents, and reads TTL monitor metrics
+func (tc *TTLChecker) GetTTLReport() (TTLReport, error) {
+	var err error
+	var indexesMap bson.M
+	var status bson.M
+	report := TTLReport{Indexes: []TTLIndexDoc{}, MonitorSleepSecs: defaultTTLMonitorSleepSecs}
+	if status, err = RunAdminCommand(tc.client, "serverStatus"); err != nil {
+		return report, err
+	}
+	report.DeletedDocuments, report.Passes = getTTLMetrics(status)
+	var param bson.M
+	cmd := bson.D{{Key: "getParameter", Value: 1}, {Key: "ttlMonitorSleepSecs", Value: 1}}
+	if e := tc.client.Database("admin").RunCommand(context.Background(), cmd).Decode(&param); e == nil && toInt(param["ttlMonitorSleepSecs"]) > 0 {
+		report.MonitorSleepSecs = toInt(param["ttlMonitorSleepSecs"])
+	}
+
+	ir := NewIndexesReader(tc.client)
+	ir.SetDBName(tc.dbName)
+	if indexesMap, err = ir.GetIndexes(); err != nil {
+		return report, err
+	}
+	now := time.Now()
+	for _, dbName := range getSortedKeys(indexesMap) {
+		collections, ok := indexesMap[dbName].(bson.M)
+		if ok == false {
+			continue
+		}
+		for _, collName := range getSortedKeys(collections) {
+			for _, o := range collections[collName].([]IndexStatsDoc) {
+				if o.ExpireAfterSeconds == nil || len(o.Fields) == 0 {
+					continue
+				}
+				doc := TTLIndexDoc{Namespace: dbName + "." + collName, Name: o.Name, Field: o.Fields[0], ExpireAfterSeconds: *o.ExpireAfterSeconds}
+				if len(o.Fields) > 1 {
+					doc.Note = "compound indexes don't expire documents"
+				} else if err = tc.sampleExpired(&doc, tc.client.Database(dbName).Collection(collName), now); err != nil {
+					return report, err
+				}
+				doc.IsBehind = isTTLBehind(doc, report.MonitorSleepSecs)
+				if tc.verbose {
+					fmt.Println(doc.Namespace, doc.Name, doc.EstimatedExpired, "expired")
+				}
+				report.Indexes = append(report.Indexes, doc)
+			}
+		}
+	}
+	return report, err
+}
+
+// sampleExpired estimates expired documents of a TTL index from sampled documents, and finds the oldest value
+func (tc *TTLChecker) sampleExpired(doc *TTLIndexDoc, collection *mongo.Collection, now time.Time) error {
+	var err error
+	var cur *mongo.Cursor
+	ctx := context.Background()
+	cutoff := now.Add(-time.Duration(doc.ExpireAfterSeconds) * time.Second)
+	if doc.Count, err = collection.EstimatedDocumentCount(ctx); err != nil {
+		return err
+	}
+	field := "$" + doc.Field
+	isExpired := bson.D{{Key: "$and", Value: bson.A{
+		bson.D{{Key: "$eq", Value: bson.A{bson.D{{Key: "$type", Value: field}}, "date"}}},
+		bson.D{{Key: "$lt", Value: bson.A{field, cutoff}}}}}}
+	pipeline := []bson.D{
+		{{Key: "$sample", Value: bson.D{{Key: "size", Value: tc.sampleSize}}}},
+		{{Key: "$group", Value: bson.D{{Key: "_id", Value: nil}, {Key: "sampled", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "expired", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{isExpired, 1, 0}}}}}}}}},
+	}
+	if cur, err = collection.Aggregate(ctx, pipeline); err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+	if cur.Next(ctx) {
+		var result bson.M
+		if err = cur.Decode(&result); err != nil {
+			return err
+		}
+		doc.Sampled, doc.SampledExpired = toInt(result["sampled"]), toInt(result["expired"])
+		doc.EstimatedExpired = getEstimatedExpired(doc.Count, doc.Sampled, doc.SampledExpired)
+	}
+
+	// the oldest date value, documents of other types or without the field aren't expired
+	var oldest bson.M
+	filter := bson.D{{Key: doc.Field, Value: bson.D{{Key: "$type", Value: "date"}}}}
+	opts := options.FindOne().SetSort(bson.D{{Key: doc.Field, Value: 1}}).SetProjection(bson.D{{Key: doc.Field, Value: 1}})
+	if err = collection.FindOne(ctx, filter, opts).Decode(&oldest); err == mongo.ErrNoDocuments {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if dt, ok := getNestedValue(oldest, doc.Field).(primitive.DateTime); ok == true {
+		doc.Oldest = time.Unix(0, int64(dt)*int64(time.Millisecond))
+		doc.Lag = getTTLLag(doc.Oldest, cutoff)
+	}
+	return err
+}
+
+// getNestedValue returns a value of a dotted field, e.g. meta.createdAt
+func getNestedValue(doc bson.M, field string) interface{} {
+	var value interface{} = doc
+	for _, key := range strings.Split(field, ".") {
+		m, ok := value.(bson.M)
+		if ok == false {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
+
+// getTTLMetrics returns metrics.ttl.deletedDocuments and metrics.ttl.passes of serverStatus
+func getTTLMetrics(status bson.M) (int64, int64) {
+	metrics, _ := status["metrics"].(bson.M)
+	ttl, _ := metrics["ttl"].(bson.M)
+	return int64(toInt(ttl["deletedDocuments"])), int64(toInt(ttl["passes"]))
+}
+
+// getEstimatedExpired extrapolates expired documents of samples to a collection
+func getEstimatedExpired(count int64, sampled int, expired int) int64 {
+	if sampled == 0 {
+		return 0
+	}
+	if int64(sampled) >= count { // $sample returns all documents of a small collection
+		return int64(expired)
+	}
+	return count * int64(expired) / int64(sampled)
+}
+
+// getTTLLag returns time the oldest document stays after its expiration, 0 if it isn't expired
+func getTTLLag(oldest time.Time, cutoff time.Time) time.Duration {
+	if oldest.IsZero() || oldest.After(cutoff) {
+		return 0
+	}
+	return cutoff.Sub(oldest)
+}
+
+// isTTLBehind returns true if the oldest expired document stays for many passes of the TTL monitor
+func isTTLBehind(doc TTLIndexDoc, sleepSecs int) bool {
+	return doc.Note == "" && doc.Lag > time.Duration(ttlBehindPasses*sleepSecs)*time.Second
+}
+
+// GetSummary returns TTL indexes and metrics of the TTL monitor
+func (report TTLReport) GetSummary() string {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("TTL monitor: every %ds, passes: %d, deleted documents: %d\n",
+		report.MonitorSleepSecs, report.Passes, report.DeletedDocuments))
+	if len(report.Indexes) == 0 {
+		buffer.WriteString("No TTL indexes found\n")
+		return buffer.String()
+	}
+	for _, doc := range report.Indexes {
+		mark := "  "
+		if doc.IsBehind {
+			mark = "x "
+		}
+		buffer.WriteString(fmt.Sprintf("%s%s %s {%s: 1} expireAfterSeconds: %d\n", mark, doc.Namespace, doc.Name, doc.Field, doc.ExpireAfterSeconds))
+		if doc.Note != "" {
+			buffer.WriteString("\t" + doc.Note + "\n")
+			continue
+		}
+		buffer.WriteString(fmt.Sprintf("\tdocuments: %d, expired: %d of %d sampled, estimated expired: %d\n",
+			doc.Count, doc.SampledExpired, doc.Sampled, doc.EstimatedExpired))
+		if doc.Oldest.IsZero() == false {
+			buffer.WriteString(fmt.Sprintf("\toldest: %s, lag: %v", doc.Oldest.Format(time.RFC3339), doc.Lag.Round(time.Second)))
+			if doc.IsBehind {
+				buffer.WriteString(", falling behind")
+			}
+			buffer.WriteString("\n")
+		}
+	}
+	return buffer.String()
+}
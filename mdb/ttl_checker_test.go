@@ -0,0 +1,69 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestGetTTLMetrics(t *testing.T) {
+	status := bson.M{"metrics": bson.M{"ttl": bson.M{"deletedDocuments": int64(1234), "passes": int64(56)}}}
+	if deleted, passes := getTTLMetrics(status); deleted != 1234 || passes != 56 {
+		t.Fatal(deleted, passes)
+	}
+	if deleted, passes := getTTLMetrics(bson.M{}); deleted != 0 || passes != 0 {
+		t.Fatal(deleted, passes)
+	}
+}
+
+func TestGetEstimatedExpired(t *testing.T) {
+	if n := getEstimatedExpired(100000, 1000, 25); n != 2500 {
+		t.Fatal(n)
+	}
+	if n := getEstimatedExpired(500, 500, 25); n != 25 {
+		t.Fatal(n)
+	}
+	if n := getEstimatedExpired(0, 0, 0); n != 0 {
+		t.Fatal(n)
+	}
+}
+
+func TestTTLBehind(t *testing.T) {
+	now := time.Now()
+	cutoff := now.Add(-time.Hour)
+	doc := TTLIndexDoc{Namespace: "keyhole.sessions", Name: "createdAt_1", Field: "createdAt", ExpireAfterSeconds: 3600}
+	doc.Oldest = cutoff.Add(-30 * time.Second)
+	doc.Lag = getTTLLag(doc.Oldest, cutoff)
+	if doc.Lag != 30*time.Second || isTTLBehind(doc, 60) == true {
+		t.Fatal(doc.Lag)
+	}
+	doc.Oldest = cutoff.Add(-2 * time.Hour)
+	doc.Lag = getTTLLag(doc.Oldest, cutoff)
+	doc.IsBehind = isTTLBehind(doc, 60)
+	if doc.IsBehind == false {
+		t.Fatal(doc.Lag)
+	}
+	if lag := getTTLLag(now, cutoff); lag != 0 {
+		t.Fatal(lag)
+	}
+	report := TTLReport{Indexes: []TTLIndexDoc{doc}, MonitorSleepSecs: 60}
+	if str := report.GetSummary(); strings.Contains(str, "falling behind") == false {
+		t.Fatal(str)
+	}
+}
+
+func TestGetNestedValue(t *testing.T) {
+	dt := primitive.DateTime(1500000000000)
+	doc := bson.M{"meta": bson.M{"createdAt": dt}}
+	if value := getNestedValue(doc, "meta.createdAt"); value != dt {
+		t.Fatal(value)
+	}
+	if value := getNestedValue(doc, "meta.updatedAt"); value != nil {
+		t.Fatal(value)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// digestTopPatterns is number of the slowest ops patterns of a digest
+const digestTopPatterns = 5
+
+// WebhookNotifier posts digests of analyses to a Slack or a Microsoft Teams incoming webhook
+type WebhookNotifier struct {
+	client *http.Client
+	url    string
+}
+
+// AnalysisDigest is a condensed summary of an analysis run, counts are -1 if not analyzed
+type AnalysisDigest struct {
+	Title         string                 `json:"title"`
+	SlowPatterns  []LogInfoLineAnalytics `json:"slowPatterns"`
+	Collscans     int                    `json:"collscans"` // number of COLLSCAN ops patterns
+	UnusedIndexes int                    `json:"unusedIndexes"`
+}
+
+// NewWebhookNotifier returns a WebhookNotifier of a webhook URL
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{client: &http.Client{Timeout: 30 * time.Second}, url: url}
+}
+
+// NewAnalysisDigest returns an empty digest of a title
+func NewAnalysisDigest(title string) *AnalysisDigest {
+	return &AnalysisDigest{Title: title, SlowPatterns: []LogInfoLineAnalytics{}, Collscans: -1, UnusedIndexes: -1}
+}
+
+// AddLogInfo adds the top ops patterns, the slowest by average unless sorted otherwise, and number of COLLSCAN
+// ops patterns of loginfo results
+func (d *AnalysisDigest) AddLogInfo(li *LogInfo) {
+	d.Collscans = 0
+	for i, value := range li.OpsPatterns {
+		if i < digestTopPatterns {
+			d.SlowPatterns = append(d.SlowPatterns, ConverOpPerformanceDocumentToLogInfoLineAnalytics(&value))
+		}
+		if value.Scan == COLLSCAN {
+			d.Collscans++
+		}
+	}
+}
+
+// AddIndexes adds number of unused indexes of indexes of databases
+func (d *AnalysisDigest) AddIndexes(indexesMap bson.M) {
+	d.UnusedIndexes = 0
+	for _, collections := range indexesMap {
+		m, _ := collections.(bson.M)
+		for _, list := range m {
+			indexes, _ := list.([]IndexStatsDoc)
+			for _, o := range indexes {
+				if isUnusedIndex(o) == true {
+					d.UnusedIndexes++
+				}
+			}
+		}
+	}
+}
+
+// GetText returns the digest in markdown supported by Slack and Teams
+func (d *AnalysisDigest) GetText() string {
+	var buffer bytes.Buffer
+	if d.Collscans >= 0 {
+		buffer.WriteString(fmt.Sprintf("*COLLSCAN ops patterns:* %d\n", d.Collscans))
+	}
+	if d.UnusedIndexes >= 0 {
+		buffer.WriteString(fmt.Sprintf("*Unused indexes:* %d\n", d.UnusedIndexes))
+	}
+	if len(d.SlowPatterns) > 0 {
+		buffer.WriteString(fmt.Sprintf("*Top %d slowest ops patterns:*\n", len(d.SlowPatterns)))
+		for i, p := range d.SlowPatterns {
+			scan := ""
+			if p.IsCollectionScan == true {
+				scan = " " + COLLSCAN
+			}
+			buffer.WriteString(fmt.Sprintf("%d. %s %s%s, count %d, avg %.1f ms, max %d ms `%s`\n", i+1, p.Command, p.Namespace, scan,
+				p.Count, p.AvgMilliseconds, p.MaxMilliseconds, p.QueryPattern))
+		}
+	}
+	return buffer.String()
+}
+
+// getPayload returns a message of a digest, a MessageCard of Teams or a message of Slack
+func (wn *WebhookNotifier) getPayload(digest *AnalysisDigest) map[string]interface{} {
+	if strings.Contains(wn.url, ".office.com/") || strings.Contains(wn.url, ".office365.com/") {
+		return map[string]interface{}{"@type": "MessageCard", "@context": "http://schema.org/extensions", "summary": digest.Title,
+			"title": digest.Title, "text": strings.Replace(digest.GetText(), "\n", "\n\n", -1)}
+	}
+	return map[string]interface{}{"text": "*" + digest.Title + "*\n" + digest.GetText()}
+}
+
+// Notify posts a digest to the webhook
+func (wn *WebhookNotifier) Notify(digest *AnalysisDigest) error {
+	var err error
+	var data []byte
+	var resp *http.Response
+	if data, err = json.Marshal(wn.getPayload(digest)); err != nil {
+		return err
+	}
+	if resp, err = wn.client.Post(wn.url, "application/json", bytes.NewReader(data)); err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("webhook: %s %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestAnalysisDigest(t *testing.T) {
+	li := &LogInfo{}
+	for i := 0; i < 7; i++ {
+		doc := OpPerformanceDoc{Command: "find", Namespace: "demo.orders", Filter: "{a: 1}", Count: 1, TotalMilli: 100 - i, MaxMilli: 100 - i}
+		if i%2 == 0 {
+			doc.Scan = COLLSCAN
+		}
+		li.OpsPatterns = append(li.OpsPatterns, doc)
+	}
+	digest := NewAnalysisDigest("nightly")
+	if str := digest.GetText(); str != "" {
+		t.Fatal(str)
+	}
+	digest.AddLogInfo(li)
+	digest.AddIndexes(bson.M{"demo": bson.M{"orders": []IndexStatsDoc{{Name: "_id_", Key: "{ _id: 1 }"}, {Name: "a_1", Key: "{ a: 1 }", Usage: []UsageDoc{}},
+		{Name: "b_1", Key: "{ b: 1 }", TotalOps: 3, Usage: []UsageDoc{}}}}})
+	str := digest.GetText()
+	t.Log(str)
+	if len(digest.SlowPatterns) != 5 || digest.Collscans != 4 || digest.UnusedIndexes != 1 ||
+		strings.Contains(str, "1. find demo.orders COLLSCAN, count 1, avg 100.0 ms") == false {
+		t.Fatal(digest.Collscans, digest.UnusedIndexes, str)
+	}
+}
+
+func TestWebhookNotifierNotify(t *testing.T) {
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid_payload"))
+		}
+	}))
+	defer server.Close()
+	digest := NewAnalysisDigest("nightly")
+	digest.Collscans = 2
+	if err := NewWebhookNotifier(server.URL + "/services/T0/B0/X").Notify(digest); err != nil {
+		t.Fatal(err)
+	}
+	if text, _ := payload["text"].(string); strings.HasPrefix(text, "*nightly*\n*COLLSCAN ops patterns:* 2") == false {
+		t.Fatal(payload)
+	}
+	if card := NewWebhookNotifier("https://outlook.office.com/webhook/x").getPayload(digest); card["@type"] != "MessageCard" || card["title"] != "nightly" {
+		t.Fatal(card)
+	}
+	if err := NewWebhookNotifier(server.URL + "/fail").Notify(digest); err == nil || strings.Contains(err.Error(), "invalid_payload") == false {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxShadowInFlight is the max number of commands mirrored at the same time, commands are skipped beyond it
+const maxShadowInFlight = 64
+
+// slowerRatio is the ratio of p95 of a target to a source above which a shape is flagged
+const slowerRatio = 1.5
+
+// shadowDropFields are fields of commands of sessions, transactions, and routing not mirrored
+var shadowDropFields = []string{"lsid", "txnNumber", "autocommit", "startTransaction", "readConcern", "$db", "$clusterTime",
+	"$readPreference", "$audit", "$client", "$configServerState", "shardVersion", "databaseVersion"}
+
+// Shadower tails system.profile of a database of a source cluster and mirrors read-only commands onto a target
+// cluster, latencies of query shapes are compared between the source and the target. Profiling has to be enabled on
+// the source; change streams carry writes only and have no reads to mirror.
+type Shadower struct {
+	dbName   string
+	duration time.Duration
+	mutex    sync.Mutex
+	source   *mongo.Client
+	target   *mongo.Client
+	verbose  bool
+}
+
+// ShadowShape is latencies of a query shape on a source and a target
+type ShadowShape struct {
+	Namespace string             `json:"ns"`
+	Command   string             `json:"command"`
+	Shape     string             `json:"shape"`
+	Count     int                `json:"count"`
+	Errors    int                `json:"errors"` // failed on the target
+	Source    LatencyPercentiles `json:"source"`
+	Target    LatencyPercentiles `json:"target"`
+	Slower    bool               `json:"slower"` // p95 of the target is 1.5x of the source or more
+	source    LatencyHistogram
+	target    LatencyHistogram
+}
+
+// ShadowReport is query shapes mirrored, the most frequent first
+type ShadowReport struct {
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Mirrored int           `json:"mirrored"`
+	Skipped  int           `json:"skipped"` // too many in flight
+	Shapes   []ShadowShape `json:"shapes"`
+	shapes   map[string]*ShadowShape
+}
+
+// NewShadower returns a Shadower of a database mirroring for 5 minutes
+func NewShadower(source *mongo.Client, target *mongo.Client, dbName string) *Shadower {
+	return &Shadower{dbName: dbName, duration: 5 * time.Minute, source: source, target: target}
+}
+
+// SetDuration sets how long to mirror
+func (s *Shadower) SetDuration(duration time.Duration) {
+	s.duration = duration
+}
+
+// SetVerbose sets verbose level
+func (s *Shadower) SetVerbose(verbose bool) {
+	s.verbose = verbose
+}
+
+// Shadow mirrors commands until the duration ends
+func (s *Shadower) Shadow() (ShadowReport, error) {
+	return s.ShadowContext(context.Background())
+}
+
+// ShadowContext mirrors commands until the duration ends or ctx is done
+func (s *Shadower) ShadowContext(ctx context.Context) (ShadowReport, error) {
+	var err error
+	var cur *mongo.Cursor
+	report := &ShadowReport{Start: time.Now(), shapes: map[string]*ShadowShape{}}
+	if s.dbName == "" || s.dbName == "admin" || s.dbName == "config" || s.dbName == "local" {
+		return report.getReport(), errors.New("usage: keyhole --shadow target_uri source_uri_with_database")
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.duration)
+	defer cancel()
+	filter := bson.D{{Key: "ts", Value: bson.D{{Key: "$gt", Value: primitive.NewDateTimeFromTime(report.Start)}}},
+		{Key: "op", Value: bson.D{{Key: "$in", Value: bson.A{"query", "command"}}}}}
+	opts := options.Find().SetCursorType(options.TailableAwait)
+	if cur, err = s.source.Database(s.dbName).Collection("system.profile").Find(ctx, filter, opts); err != nil {
+		return report.getReport(), err
+	}
+	defer cur.Close(context.Background())
+	inFlight := make(chan bool, maxShadowInFlight)
+	var wg sync.WaitGroup
+	for cur.Next(ctx) {
+		var doc bson.D
+		if err = cur.Decode(&doc); err != nil {
+			continue
+		}
+		dbName, cmd, stats, ok := getShadowCommand(doc)
+		if ok == false {
+			continue
+		}
+		select {
+		case inFlight <- true:
+		default:
+			s.mutex.Lock()
+			report.Skipped++
+			s.mutex.Unlock()
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t := time.Now()
+			err := s.runOnTarget(ctx, dbName, cmd)
+			elapsed := time.Since(t)
+			<-inFlight
+			if ctx.Err() != nil {
+				return
+			}
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+			report.add(stats, elapsed, err)
+			if err != nil && s.verbose == true {
+				fmt.Println(stats.namespace, stats.command, err)
+			}
+		}()
+	}
+	wg.Wait()
+	report.End = time.Now()
+	if err = cur.Err(); err != nil && ctx.Err() == nil {
+		return report.getReport(), err
+	}
+	return report.getReport(), nil
+}
+
+// getShadowCommand returns a read-only command of a system.profile document to mirror, false if not read-only
+func getShadowCommand(doc bson.D) (string, bson.D, opStats, bool) {
+	stats, ok := getOpStatsFromProfile(doc)
+	if ok == false {
+		return "", nil, stats, false
+	}
+	cmd, _ := doc.Map()["command"].(bson.D)
+	if len(cmd) == 0 || contains([]string{"find", "aggregate", "count", "distinct"}, cmd[0].Key) == false {
+		return "", nil, stats, false
+	}
+	if cmd[0].Key == "aggregate" {
+		if stages, ok := cmd.Map()["pipeline"].(primitive.A); ok == true {
+			for _, stage := range stages {
+				if s, ok := stage.(bson.D); ok == true && len(s) > 0 && (s[0].Key == "$out" || s[0].Key == "$merge") {
+					return "", nil, stats, false
+				}
+			}
+		}
+	}
+	mirror := bson.D{}
+	for _, elem := range cmd {
+		if contains(shadowDropFields, elem.Key) == false {
+			mirror = append(mirror, elem)
+		}
+	}
+	return strings.SplitN(stats.namespace, ".", 2)[0], mirror, stats, true
+}
+
+// runOnTarget runs a command on the target and kills its cursor if results remain
+func (s *Shadower) runOnTarget(ctx context.Context, dbName string, cmd bson.D) error {
+	raw, err := s.target.Database(dbName).RunCommand(ctx, cmd).DecodeBytes()
+	if err != nil {
+		return err
+	}
+	if id, ok := raw.Lookup("cursor", "id").Int64OK(); ok == true && id != 0 {
+		ns, _ := raw.Lookup("cursor", "ns").StringValueOK()
+		if coll := strings.TrimPrefix(ns, dbName+"."); coll != ns {
+			s.target.Database(dbName).RunCommand(ctx, bson.D{{Key: "killCursors", Value: coll}, {Key: "cursors", Value: bson.A{id}}})
+		}
+	}
+	return nil
+}
+
+// add adds a command mirrored of a shape and its latency on the target
+func (r *ShadowReport) add(stats opStats, elapsed time.Duration, err error) {
+	key := stats.namespace + " " + stats.command + " " + stats.filter
+	shape, ok := r.shapes[key]
+	if ok == false {
+		shape = &ShadowShape{Namespace: stats.namespace, Command: stats.command, Shape: stats.filter,
+			source: NewLatencyHistogram(), target: NewLatencyHistogram()}
+		r.shapes[key] = shape
+	}
+	r.Mirrored++
+	shape.Count++
+	if err != nil {
+		shape.Errors++
+		return
+	}
+	shape.source.Add(stats.milli * 1000)
+	shape.target.Add(int(elapsed / time.Microsecond))
+}
+
+// getReport returns shapes with latency percentiles, the most frequent first
+func (r *ShadowReport) getReport() ShadowReport {
+	report := ShadowReport{Start: r.Start, End: r.End, Mirrored: r.Mirrored, Skipped: r.Skipped, Shapes: []ShadowShape{}}
+	for _, shape := range r.shapes {
+		shape.Source = shape.source.GetMilliPercentiles()
+		shape.Target = shape.target.GetMilliPercentiles()
+		shape.Slower = shape.target.Count > 0 && shape.Target.P95 >= slowerRatio*shape.Source.P95 && shape.Target.P95 >= 1
+		report.Shapes = append(report.Shapes, *shape)
+	}
+	sort.Slice(report.Shapes, func(i, j int) bool {
+		if report.Shapes[i].Count != report.Shapes[j].Count {
+			return report.Shapes[i].Count > report.Shapes[j].Count
+		}
+		return report.Shapes[i].Namespace+report.Shapes[i].Shape < report.Shapes[j].Namespace+report.Shapes[j].Shape
+	})
+	return report
+}
+
+// GetShadowReportSummary returns latencies of query shapes on the source and the target side by side
+func GetShadowReportSummary(report ShadowReport) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("%d command(s) mirrored from %v to %v, %d skipped\n", report.Mirrored,
+		report.Start.Format(time.RFC3339), report.End.Format(time.RFC3339), report.Skipped))
+	if len(report.Shapes) == 0 {
+		return buffer.String()
+	}
+	buffer.WriteString(fmt.Sprintf("%-30s %-10s %7s %6s | %-26s | %-26s\n", "namespace", "command", "count", "errors",
+		"source p50/p95/p99 ms", "target p50/p95/p99 ms"))
+	for _, s := range report.Shapes {
+		buffer.WriteString(fmt.Sprintf("%-30s %-10s %7d %6d | %8.1f %8.1f %8.1f | %8.1f %8.1f %8.1f  %s", s.Namespace, s.Command,
+			s.Count, s.Errors, s.Source.P50, s.Source.P95, s.Source.P99, s.Target.P50, s.Target.P95, s.Target.P99, s.Shape))
+		if s.Slower == true {
+			buffer.WriteString("  SLOWER")
+		}
+		buffer.WriteString("\n")
+	}
+	return buffer.String()
+}
@@ -0,0 +1,55 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestGetShadowCommand(t *testing.T) {
+	cmd := bson.D{{Key: "find", Value: "cars"}, {Key: "filter", Value: bson.D{{Key: "color", Value: "Red"}}},
+		{Key: "lsid", Value: bson.D{{Key: "id", Value: "x"}}}, {Key: "$db", Value: "keyhole"}}
+	doc := bson.D{{Key: "op", Value: "query"}, {Key: "ns", Value: "keyhole.cars"}, {Key: "command", Value: cmd}, {Key: "millis", Value: int32(12)}}
+	dbName, mirror, stats, ok := getShadowCommand(doc)
+	if ok == false || dbName != "keyhole" || len(mirror) != 2 || stats.filter != "{color: 1}" || stats.milli != 12 {
+		t.Fatal(ok, dbName, mirror, stats)
+	}
+	cmd = bson.D{{Key: "aggregate", Value: "cars"}, {Key: "pipeline", Value: primitive.A{
+		bson.D{{Key: "$match", Value: bson.D{{Key: "color", Value: "Red"}}}}, bson.D{{Key: "$out", Value: "reds"}}}}}
+	doc[2].Value = cmd
+	if _, _, _, ok = getShadowCommand(doc); ok == true {
+		t.Fatal("$out should not be mirrored")
+	}
+	doc[2].Value = bson.D{{Key: "update", Value: "cars"}, {Key: "updates", Value: primitive.A{bson.D{{Key: "q", Value: bson.D{{Key: "a", Value: 1}}}}}}}
+	if _, _, _, ok = getShadowCommand(doc); ok == true {
+		t.Fatal("update should not be mirrored")
+	}
+}
+
+func TestGetShadowReportSummary(t *testing.T) {
+	r := &ShadowReport{Start: time.Now(), shapes: map[string]*ShadowShape{}}
+	stats := opStats{namespace: "keyhole.cars", command: "find", filter: "{color: 1}", milli: 2}
+	for i := 0; i < 20; i++ {
+		r.add(stats, 10*time.Millisecond, nil)
+	}
+	r.add(stats, 0, errors.New("timeout"))
+	r.add(opStats{namespace: "keyhole.dealers", command: "count", filter: "{}", milli: 5}, 5*time.Millisecond, nil)
+	report := r.getReport()
+	if report.Mirrored != 22 || len(report.Shapes) != 2 || report.Shapes[0].Count != 21 || report.Shapes[0].Errors != 1 {
+		t.Fatal(report)
+	}
+	if report.Shapes[0].Slower == false || report.Shapes[1].Slower == true || report.Shapes[0].Target.P95 < 9 {
+		t.Fatal(report.Shapes)
+	}
+	str := GetShadowReportSummary(report)
+	t.Log(str)
+	if strings.Contains(str, "SLOWER") == false || strings.Contains(str, "keyhole.dealers") == false {
+		t.Fatal(str)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+// Package repl monitors replication lag of members and the oplog window of a replica set
+package repl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Monitor computes replication lag of members and the oplog window of a replica set
+type Monitor struct {
+	client    *mongo.Client
+	minWindow time.Duration
+	timeout   time.Duration
+}
+
+// Status is replication lag of members and the oplog of a replica set
+type Status struct {
+	ReplSet  string      `json:"replSet"`
+	Members  []MemberLag `json:"members"`
+	Oplog    OplogStats  `json:"oplog"`
+	Warnings []string    `json:"warnings"`
+}
+
+// MemberLag is replication lag of a member behind the primary, or the most recent optime without a primary
+type MemberLag struct {
+	Name       string    `json:"name"`
+	State      string    `json:"state"`
+	OptimeDate time.Time `json:"optimeDate"`
+	LagSeconds float64   `json:"lagSeconds"`
+}
+
+// OplogStats is the window and churn rate of an oplog
+type OplogStats struct {
+	First           time.Time `json:"first"`
+	Last            time.Time `json:"last"`
+	WindowHours     float64   `json:"windowHours"`
+	Size            int64     `json:"size"`    // bytes of oplog entries
+	MaxSize         int64     `json:"maxSize"` // configured size in bytes
+	ChurnGBPerHour  float64   `json:"churnGBPerHour"`
+	ProjectedWindow float64   `json:"projectedWindowHours"` // window when the oplog is full at the current churn rate
+}
+
+// member is a member of replSetGetStatus
+type member struct {
+	Name       string    `bson:"name"`
+	State      int       `bson:"state"`
+	StateStr   string    `bson:"stateStr"`
+	OptimeDate time.Time `bson:"optimeDate"`
+}
+
+// NewMonitor returns a Monitor, a window shorter than 24 hours is warned by default
+func NewMonitor(client *mongo.Client) *Monitor {
+	return &Monitor{client: client, minWindow: 24 * time.Hour, timeout: 30 * time.Second}
+}
+
+// SetMinWindow sets the shortest oplog window to cover backups and maintenance, 0 not to warn
+func (m *Monitor) SetMinWindow(minWindow time.Duration) {
+	m.minWindow = minWindow
+}
+
+// GetStatus returns replication lag of members and the oplog window and churn rate
+func (m *Monitor) GetStatus() (Status, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+	return m.GetStatusContext(ctx)
+}
+
+// GetStatusContext returns replication lag of members and the oplog window and churn rate, stops when ctx is done
+func (m *Monitor) GetStatusContext(ctx context.Context) (Status, error) {
+	var err error
+	status := Status{Members: []MemberLag{}, Warnings: []string{}}
+	var doc struct {
+		Set     string   `bson:"set"`
+		Members []member `bson:"members"`
+	}
+	if err = m.client.Database("admin").RunCommand(ctx, bson.D{{Key: "replSetGetStatus", Value: 1}}).Decode(&doc); err != nil {
+		return status, err
+	}
+	status.ReplSet = doc.Set
+	var hasPrimary bool
+	if status.Members, hasPrimary = getMemberLags(doc.Members); hasPrimary == false {
+		status.Warnings = append(status.Warnings, "no primary, lags are behind the most recent optime")
+	}
+	if status.Oplog, err = m.getOplogStats(ctx); err != nil {
+		return status, err
+	}
+	if m.minWindow > 0 && status.Oplog.WindowHours < m.minWindow.Hours() {
+		status.Warnings = append(status.Warnings, fmt.Sprintf("oplog window %.1f hours is shorter than %.1f hours required for backups and maintenance",
+			status.Oplog.WindowHours, m.minWindow.Hours()))
+	}
+	return status, err
+}
+
+// getMemberLags returns lags of members behind the primary, or the most recent optime if no primary, arbiters
+// are skipped
+func getMemberLags(members []member) ([]MemberLag, bool) {
+	var latest time.Time
+	hasPrimary := false
+	for _, mem := range members {
+		if mem.State == 1 { // PRIMARY
+			latest, hasPrimary = mem.OptimeDate, true
+			break
+		} else if mem.OptimeDate.After(latest) {
+			latest = mem.OptimeDate
+		}
+	}
+	lags := []MemberLag{}
+	for _, mem := range members {
+		if mem.State == 7 { // ARBITER
+			continue
+		}
+		lag := MemberLag{Name: mem.Name, State: mem.StateStr, OptimeDate: mem.OptimeDate}
+		if latest.After(mem.OptimeDate) {
+			lag.LagSeconds = latest.Sub(mem.OptimeDate).Seconds()
+		}
+		lags = append(lags, lag)
+	}
+	return lags, hasPrimary
+}
+
+// getOplogStats returns times of the first and the last entries and sizes of the oplog
+func (m *Monitor) getOplogStats(ctx context.Context) (OplogStats, error) {
+	var err error
+	local := m.client.Database("local")
+	var first, last struct {
+		TS primitive.Timestamp `bson:"ts"`
+	}
+	opts := options.FindOne().SetProjection(bson.D{{Key: "ts", Value: 1}})
+	oplog := local.Collection("oplog.rs")
+	if err = oplog.FindOne(ctx, bson.D{}, opts.SetSort(bson.D{{Key: "$natural", Value: 1}})).Decode(&first); err != nil {
+		return OplogStats{}, err
+	}
+	if err = oplog.FindOne(ctx, bson.D{}, opts.SetSort(bson.D{{Key: "$natural", Value: -1}})).Decode(&last); err != nil {
+		return OplogStats{}, err
+	}
+	var stats struct {
+		Size    int64 `bson:"size"`
+		MaxSize int64 `bson:"maxSize"`
+	}
+	if err = local.RunCommand(ctx, bson.D{{Key: "collStats", Value: "oplog.rs"}}).Decode(&stats); err != nil {
+		return OplogStats{}, err
+	}
+	return getOplogStats(first.TS, last.TS, stats.Size, stats.MaxSize), err
+}
+
+// getOplogStats returns the window, churn rate, and the window projected when the oplog is full
+func getOplogStats(first primitive.Timestamp, last primitive.Timestamp, size int64, maxSize int64) OplogStats {
+	stats := OplogStats{First: time.Unix(int64(first.T), 0).UTC(), Last: time.Unix(int64(last.T), 0).UTC(), Size: size,
+		MaxSize: maxSize}
+	stats.WindowHours = stats.Last.Sub(stats.First).Hours()
+	if stats.WindowHours <= 0 {
+		return stats
+	}
+	stats.ChurnGBPerHour = float64(size) / (1024 * 1024 * 1024) / stats.WindowHours
+	if stats.ChurnGBPerHour > 0 {
+		stats.ProjectedWindow = float64(maxSize) / (1024 * 1024 * 1024) / stats.ChurnGBPerHour
+	}
+	return stats
+}
+
+// GetStatusSummary returns lags of members, the oplog window and churn rate, and warnings
+func GetStatusSummary(status Status) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("Replica set %v\n", status.ReplSet))
+	buffer.WriteString(fmt.Sprintf("%-30s %-10s %-20s %12s\n", "member", "state", "optime", "lag seconds"))
+	for _, lag := range status.Members {
+		buffer.WriteString(fmt.Sprintf("%-30s %-10s %-20s %12.0f\n", lag.Name, lag.State, lag.OptimeDate.Format(time.RFC3339),
+			lag.LagSeconds))
+	}
+	oplog := status.Oplog
+	buffer.WriteString(fmt.Sprintf("Oplog window: %.1f hours, from %v to %v\n", oplog.WindowHours, oplog.First.Format(time.RFC3339),
+		oplog.Last.Format(time.RFC3339)))
+	buffer.WriteString(fmt.Sprintf("Oplog size: %.2f of %.2f GB, churn: %.3f GB/hour", float64(oplog.Size)/(1024*1024*1024),
+		float64(oplog.MaxSize)/(1024*1024*1024), oplog.ChurnGBPerHour))
+	if oplog.ProjectedWindow > 0 {
+		buffer.WriteString(fmt.Sprintf(", %.1f hours when full", oplog.ProjectedWindow))
+	}
+	buffer.WriteString("\n")
+	for _, warning := range status.Warnings {
+		buffer.WriteString("WARNING: " + warning + "\n")
+	}
+	return buffer.String()
+}
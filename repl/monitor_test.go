@@ -0,0 +1,52 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package repl
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestGetMemberLags(t *testing.T) {
+	now := time.Date(2019, 10, 1, 10, 0, 0, 0, time.UTC)
+	members := []member{
+		{Name: "h1:27017", State: 2, StateStr: "SECONDARY", OptimeDate: now.Add(-30 * time.Second)},
+		{Name: "h2:27017", State: 1, StateStr: "PRIMARY", OptimeDate: now},
+		{Name: "h3:27017", State: 7, StateStr: "ARBITER"},
+	}
+	lags, hasPrimary := getMemberLags(members)
+	if hasPrimary == false || len(lags) != 2 || lags[0].LagSeconds != 30 || lags[1].LagSeconds != 0 {
+		t.Fatal(hasPrimary, lags)
+	}
+	members[1].State, members[1].StateStr = 2, "SECONDARY"
+	members[1].OptimeDate = now.Add(-10 * time.Second)
+	if lags, hasPrimary = getMemberLags(members); hasPrimary == true || lags[0].LagSeconds != 20 || lags[1].LagSeconds != 0 {
+		t.Fatal(hasPrimary, lags)
+	}
+}
+
+func TestGetOplogStats(t *testing.T) {
+	gb := int64(1024 * 1024 * 1024)
+	stats := getOplogStats(primitive.Timestamp{T: 1569924000}, primitive.Timestamp{T: 1569924000 + 4*3600}, 2*gb, 10*gb)
+	if stats.WindowHours != 4 || stats.ChurnGBPerHour != 0.5 || stats.ProjectedWindow != 20 {
+		t.Fatal(stats)
+	}
+	if stats = getOplogStats(primitive.Timestamp{T: 10}, primitive.Timestamp{T: 10}, gb, gb); stats.ChurnGBPerHour != 0 {
+		t.Fatal(stats)
+	}
+}
+
+func TestGetStatusSummary(t *testing.T) {
+	gb := int64(1024 * 1024 * 1024)
+	status := Status{ReplSet: "replset", Members: []MemberLag{{Name: "h1:27017", State: "PRIMARY"}},
+		Oplog:    getOplogStats(primitive.Timestamp{T: 1569924000}, primitive.Timestamp{T: 1569924000 + 4*3600}, 2*gb, 10*gb),
+		Warnings: []string{"oplog window 4.0 hours is shorter than 24.0 hours required for backups and maintenance"}}
+	str := GetStatusSummary(status)
+	t.Log(str)
+	if strings.Contains(str, "churn: 0.500 GB/hour, 20.0 hours when full") == false || strings.Contains(str, "WARNING: oplog window") == false {
+		t.Fatal(str)
+	}
+}
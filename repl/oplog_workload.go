@@ -0,0 +1,193 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package repl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OplogAnalyzer aggregates write operations of the oplog by namespaces and op types
+type OplogAnalyzer struct {
+	client  *mongo.Client
+	end     time.Time
+	follow  bool
+	start   time.Time
+	verbose bool
+}
+
+// OplogWorkload is writes of an op type to a namespace
+type OplogWorkload struct {
+	Namespace  string  `json:"ns"`
+	Op         string  `json:"op"` // i, u, or d
+	Count      int     `json:"count"`
+	TotalBytes int64   `json:"totalBytes"`
+	AvgSize    float64 `json:"avgSize"` // bytes of documents inserted, of updates, or of keys deleted
+	OpsPerSec  float64 `json:"opsPerSec"`
+}
+
+// oplogWorkloads are writes by namespaces and op types and the time range of entries
+type oplogWorkloads struct {
+	first   uint32
+	last    uint32
+	entries map[string]*OplogWorkload
+}
+
+// NewOplogAnalyzer returns an OplogAnalyzer of entries of the last hour
+func NewOplogAnalyzer(client *mongo.Client) *OplogAnalyzer {
+	end := time.Now()
+	return &OplogAnalyzer{client: client, end: end, start: end.Add(-time.Hour)}
+}
+
+// SetFollow sets to tail new entries until the end of the time range instead of scanning existing entries
+func (oa *OplogAnalyzer) SetFollow(follow bool) {
+	oa.follow = follow
+}
+
+// SetTimeRange sets times of entries to analyze
+func (oa *OplogAnalyzer) SetTimeRange(start time.Time, end time.Time) {
+	oa.start, oa.end = start, end
+}
+
+// SetVerbose sets verbose level
+func (oa *OplogAnalyzer) SetVerbose(verbose bool) {
+	oa.verbose = verbose
+}
+
+// Analyze returns writes by namespaces and op types, the most frequent first
+func (oa *OplogAnalyzer) Analyze() ([]OplogWorkload, error) {
+	return oa.AnalyzeContext(context.Background())
+}
+
+// AnalyzeContext returns writes by namespaces and op types, stops when ctx is done or at the end of the time
+// range if following
+func (oa *OplogAnalyzer) AnalyzeContext(ctx context.Context) ([]OplogWorkload, error) {
+	var err error
+	var cur *mongo.Cursor
+	workloads := &oplogWorkloads{entries: map[string]*OplogWorkload{}}
+	filter := bson.D{{Key: "ts", Value: bson.D{{Key: "$gte", Value: primitive.Timestamp{T: uint32(oa.start.Unix())}},
+		{Key: "$lte", Value: primitive.Timestamp{T: uint32(oa.end.Unix())}}}}, {Key: "op", Value: bson.D{{Key: "$in", Value: bson.A{"i", "u", "d", "c"}}}}}
+	opts := options.Find()
+	if oa.follow == true {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, oa.end)
+		defer cancel()
+		filter[0] = bson.E{Key: "ts", Value: bson.D{{Key: "$gt", Value: primitive.Timestamp{T: uint32(time.Now().Unix())}}}}
+		opts.SetCursorType(options.TailableAwait)
+	}
+	if cur, err = oa.client.Database("local").Collection("oplog.rs").Find(ctx, filter, opts); err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+	n := 0
+	for cur.Next(ctx) {
+		workloads.add(cur.Current)
+		if n++; oa.verbose == true && n%10000 == 0 {
+			fmt.Println(n, "oplog entries read")
+		}
+	}
+	if err = cur.Err(); err != nil && ctx.Err() == nil {
+		return nil, err
+	}
+	return workloads.getOplogWorkloads(), nil
+}
+
+// add adds an oplog entry, operations of applyOps of transactions are added individually
+func (w *oplogWorkloads) add(entry bson.Raw) {
+	if ts, _, ok := entry.Lookup("ts").TimestampOK(); ok == true {
+		if w.first == 0 || ts < w.first {
+			w.first = ts
+		}
+		if ts > w.last {
+			w.last = ts
+		}
+	}
+	op, _ := entry.Lookup("op").StringValueOK()
+	if op == "c" {
+		if ops, ok := entry.Lookup("o", "applyOps").ArrayOK(); ok == true {
+			values, _ := ops.Values()
+			for _, value := range values {
+				if doc, ok := value.DocumentOK(); ok == true {
+					w.addOp(doc)
+				}
+			}
+		}
+		return
+	}
+	w.addOp(entry)
+}
+
+// addOp adds an insert, update, or delete
+func (w *oplogWorkloads) addOp(entry bson.Raw) {
+	op, _ := entry.Lookup("op").StringValueOK()
+	ns, _ := entry.Lookup("ns").StringValueOK()
+	if op != "i" && op != "u" && op != "d" {
+		return
+	}
+	key := ns + " " + op
+	workload, ok := w.entries[key]
+	if ok == false {
+		workload = &OplogWorkload{Namespace: ns, Op: op}
+		w.entries[key] = workload
+	}
+	workload.Count++
+	if doc, ok := entry.Lookup("o").DocumentOK(); ok == true {
+		workload.TotalBytes += int64(len(doc))
+	}
+}
+
+// count returns number of operations added
+func (w *oplogWorkloads) count() int {
+	n := 0
+	for _, workload := range w.entries {
+		n += workload.Count
+	}
+	return n
+}
+
+// getOplogWorkloads returns writes with averages and throughputs over the time range of entries, the most
+// frequent first
+func (w *oplogWorkloads) getOplogWorkloads() []OplogWorkload {
+	seconds := float64(w.last - w.first)
+	if seconds < 1 {
+		seconds = 1
+	}
+	list := []OplogWorkload{}
+	for _, workload := range w.entries {
+		workload.AvgSize = float64(workload.TotalBytes) / float64(workload.Count)
+		workload.OpsPerSec = float64(workload.Count) / seconds
+		list = append(list, *workload)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		} else if list[i].TotalBytes != list[j].TotalBytes {
+			return list[i].TotalBytes > list[j].TotalBytes
+		}
+		return list[i].Namespace+list[i].Op < list[j].Namespace+list[j].Op
+	})
+	return list
+}
+
+// GetOplogWorkloadSummary returns a table of writes by namespaces and op types
+func GetOplogWorkloadSummary(workloads []OplogWorkload) string {
+	var buffer bytes.Buffer
+	if len(workloads) == 0 {
+		return "No writes found in the oplog"
+	}
+	names := map[string]string{"i": "insert", "u": "update", "d": "delete"}
+	buffer.WriteString(fmt.Sprintf("%-40s %-8s %10s %12s %10s %12s\n", "namespace", "op", "count", "ops/sec", "avg bytes", "total bytes"))
+	for _, w := range workloads {
+		buffer.WriteString(fmt.Sprintf("%-40s %-8s %10d %12.2f %10.0f %12d\n", w.Namespace, names[w.Op], w.Count, w.OpsPerSec,
+			w.AvgSize, w.TotalBytes))
+	}
+	return buffer.String()
+}
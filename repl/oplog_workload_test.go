@@ -0,0 +1,52 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package repl
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func getOplogEntry(t *testing.T, doc bson.D) bson.Raw {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bson.Raw(data)
+}
+
+func TestOplogWorkloads(t *testing.T) {
+	w := &oplogWorkloads{entries: map[string]*OplogWorkload{}}
+	car := bson.D{{Key: "_id", Value: 1}, {Key: "color", Value: "Red"}}
+	entries := []bson.D{
+		{{Key: "ts", Value: primitive.Timestamp{T: 1000}}, {Key: "op", Value: "i"}, {Key: "ns", Value: "keyhole.cars"}, {Key: "o", Value: car}},
+		{{Key: "ts", Value: primitive.Timestamp{T: 1005}}, {Key: "op", Value: "i"}, {Key: "ns", Value: "keyhole.cars"}, {Key: "o", Value: car}},
+		{{Key: "ts", Value: primitive.Timestamp{T: 1010}}, {Key: "op", Value: "c"}, {Key: "ns", Value: "admin.$cmd"}, {Key: "o", Value: bson.D{
+			{Key: "applyOps", Value: bson.A{
+				bson.D{{Key: "op", Value: "i"}, {Key: "ns", Value: "keyhole.cars"}, {Key: "o", Value: car}},
+				bson.D{{Key: "op", Value: "d"}, {Key: "ns", Value: "keyhole.dealers"}, {Key: "o", Value: bson.D{{Key: "_id", Value: 2}}}},
+			}}}}},
+		{{Key: "ts", Value: primitive.Timestamp{T: 1010}}, {Key: "op", Value: "c"}, {Key: "ns", Value: "keyhole.$cmd"}, {Key: "o", Value: bson.D{{Key: "create", Value: "cars"}}}},
+		{{Key: "ts", Value: primitive.Timestamp{T: 1010}}, {Key: "op", Value: "n"}, {Key: "ns", Value: ""}, {Key: "o", Value: bson.D{{Key: "msg", Value: "periodic noop"}}}},
+	}
+	for _, entry := range entries {
+		w.add(getOplogEntry(t, entry))
+	}
+	if w.count() != 4 || w.first != 1000 || w.last != 1010 {
+		t.Fatal(w.count(), w.first, w.last)
+	}
+	workloads := w.getOplogWorkloads()
+	size, _ := bson.Marshal(car)
+	if len(workloads) != 2 || workloads[0].Namespace != "keyhole.cars" || workloads[0].Count != 3 || workloads[0].AvgSize != float64(len(size)) ||
+		workloads[0].OpsPerSec != 0.3 || workloads[1].Op != "d" {
+		t.Fatal(workloads)
+	}
+	str := GetOplogWorkloadSummary(workloads)
+	t.Log(str)
+	if strings.Contains(str, "keyhole.dealers") == false || strings.Contains(str, "delete") == false {
+		t.Fatal(str)
+	}
+}
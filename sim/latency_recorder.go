@@ -0,0 +1,126 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package sim
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/simagix/keyhole/mdb"
+)
+
+// LatencyRecorder records latencies of operations in log-scaled histograms by operation types, overall and by
+// intervals, values are in microseconds
+type LatencyRecorder struct {
+	interval  time.Duration
+	intervals map[int64]map[string]*mdb.LatencyHistogram // by unix seconds of starts of intervals
+	mutex     sync.Mutex
+	overall   map[string]*mdb.LatencyHistogram
+}
+
+// LatencyStats is latency percentiles in milliseconds of an operation type, overall or of an interval
+type LatencyStats struct {
+	Op    string    `json:"op"`
+	Start time.Time `json:"start"` // of the interval, zero if overall
+	Count int       `json:"count"`
+	P50   float64   `json:"p50"`
+	P95   float64   `json:"p95"`
+	P99   float64   `json:"p99"`
+	Max   float64   `json:"max"`
+}
+
+// LatencyReport is latency percentiles by operation types, overall and by intervals
+type LatencyReport struct {
+	Interval  string         `json:"interval"`
+	Overall   []LatencyStats `json:"overall"`
+	Intervals []LatencyStats `json:"intervals"`
+}
+
+// NewLatencyRecorder returns a LatencyRecorder of intervals of a duration
+func NewLatencyRecorder(interval time.Duration) *LatencyRecorder {
+	return &LatencyRecorder{interval: interval, intervals: map[int64]map[string]*mdb.LatencyHistogram{},
+		overall: map[string]*mdb.LatencyHistogram{}}
+}
+
+// Add records a latency of an operation type finished at a time
+func (lr *LatencyRecorder) Add(op string, t time.Time, elapsed time.Duration) {
+	micros := int(elapsed / time.Microsecond)
+	start := t.Truncate(lr.interval).Unix()
+	lr.mutex.Lock()
+	defer lr.mutex.Unlock()
+	if lr.intervals[start] == nil {
+		lr.intervals[start] = map[string]*mdb.LatencyHistogram{}
+	}
+	for _, m := range []map[string]*mdb.LatencyHistogram{lr.overall, lr.intervals[start]} {
+		if m[op] == nil {
+			h := mdb.NewLatencyHistogram()
+			m[op] = &h
+		}
+		m[op].Add(micros)
+	}
+}
+
+// GetReport returns latency percentiles by operation types, overall and by intervals in time order
+func (lr *LatencyRecorder) GetReport() LatencyReport {
+	lr.mutex.Lock()
+	defer lr.mutex.Unlock()
+	report := LatencyReport{Interval: lr.interval.String(), Overall: getLatencyStats(lr.overall, time.Time{}),
+		Intervals: []LatencyStats{}}
+	starts := []int64{}
+	for start := range lr.intervals {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+	for _, start := range starts {
+		report.Intervals = append(report.Intervals, getLatencyStats(lr.intervals[start], time.Unix(start, 0))...)
+	}
+	return report
+}
+
+// getLatencyStats returns percentiles of histograms sorted by operation types
+func getLatencyStats(histograms map[string]*mdb.LatencyHistogram, start time.Time) []LatencyStats {
+	list := []LatencyStats{}
+	for op, h := range histograms {
+		list = append(list, LatencyStats{Op: op, Start: start, Count: h.Count, P50: toMilli(h.Percentile(50)),
+			P95: toMilli(h.Percentile(95)), P99: toMilli(h.Percentile(99)), Max: toMilli(h.Max)})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Op < list[j].Op })
+	return list
+}
+
+// toMilli returns milliseconds of microseconds
+func toMilli(micros int) float64 {
+	return float64(micros) / 1000
+}
+
+// WriteLatencyReport writes a latency report to a JSON file
+func WriteLatencyReport(report LatencyReport, filename string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+// GetLatencyReportTable returns latency percentiles by operation types, overall and by intervals
+func GetLatencyReportTable(report LatencyReport) string {
+	var buffer bytes.Buffer
+	header := fmt.Sprintf("%-20s %-12s %10s %10s %10s %10s %10s\n", "interval", "op", "count", "p50 ms", "p95 ms", "p99 ms", "max ms")
+	buffer.WriteString(fmt.Sprintf("Latencies of every %v\n", report.Interval))
+	buffer.WriteString(header)
+	for _, s := range report.Intervals {
+		buffer.WriteString(fmt.Sprintf("%-20s %-12s %10d %10.2f %10.2f %10.2f %10.2f\n", s.Start.Format("2006-01-02T15:04:05"), s.Op,
+			s.Count, s.P50, s.P95, s.P99, s.Max))
+	}
+	buffer.WriteString("Overall latencies\n")
+	buffer.WriteString(header)
+	for _, s := range report.Overall {
+		buffer.WriteString(fmt.Sprintf("%-20s %-12s %10d %10.2f %10.2f %10.2f %10.2f\n", "-", s.Op, s.Count, s.P50, s.P95, s.P99, s.Max))
+	}
+	return buffer.String()
+}
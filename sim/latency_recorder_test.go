@@ -0,0 +1,41 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package sim
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatencyRecorder(t *testing.T) {
+	lr := NewLatencyRecorder(time.Minute)
+	start := time.Date(2019, 10, 1, 10, 0, 0, 0, time.UTC)
+	for i := 1; i <= 100; i++ {
+		lr.Add("find", start.Add(time.Duration(i)*time.Second), time.Duration(i)*time.Millisecond)
+	}
+	lr.Add("insert", start, 500*time.Microsecond)
+	report := lr.GetReport()
+	if len(report.Overall) != 2 || report.Overall[0].Op != "find" || report.Overall[0].Count != 100 || report.Overall[0].Max != 100 {
+		t.Fatal(report.Overall)
+	}
+	find := report.Overall[0]
+	if find.P50 < 45 || find.P50 > 55 || find.P95 < 90 || find.P99 < 95 || find.P99 > 100 {
+		t.Fatal(find)
+	}
+	if len(report.Intervals) != 3 || report.Intervals[0].Count != 59 || report.Intervals[1].Op != "insert" ||
+		report.Intervals[2].Count != 41 || report.Intervals[2].Start.Equal(start.Add(time.Minute)) == false {
+		t.Fatal(report.Intervals)
+	}
+	str := GetLatencyReportTable(report)
+	t.Log(str)
+	if strings.Contains(str, "Overall latencies") == false {
+		t.Fatal(str)
+	}
+	filename := os.TempDir() + "/keyhole_latencies_test.json"
+	defer os.Remove(filename)
+	if err := WriteLatencyReport(report, filename); err != nil {
+		t.Fatal(err)
+	}
+}
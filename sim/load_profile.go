@@ -0,0 +1,178 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package sim
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/simagix/keyhole/mdb"
+)
+
+// load profiles shaping TPS of minutes of a simulation
+const (
+	ProfileFlat  = "flat"
+	ProfileRamp  = "ramp"
+	ProfileSpike = "spike"
+	ProfileStep  = "step"
+)
+
+// spikeFactor is the multiplier of TPS of minutes of spikes
+const spikeFactor = 4
+
+// saturatedRatio is the ratio of TPS achieved to targeted below which a step is saturated
+const saturatedRatio = 0.95
+
+// LoadProfile shapes TPS of minutes of a simulation, minutes are of ramps, of steps, or between spikes
+type LoadProfile struct {
+	Type    string `json:"type"`
+	Minutes int    `json:"minutes"`
+}
+
+// LoadStep is TPS targeted and achieved and latencies of a minute of a simulation
+type LoadStep struct {
+	Step      int       `json:"step"`
+	Start     time.Time `json:"start"`
+	TargetTPS int       `json:"targetTPS"`
+	TPS       float64   `json:"tps"`
+	P50       float64   `json:"p50"` // milliseconds
+	P95       float64   `json:"p95"`
+	P99       float64   `json:"p99"`
+	Saturated bool      `json:"saturated"`
+	histogram mdb.LatencyHistogram
+	ops       int
+}
+
+// loadSteps is steps of all threads of a simulation
+type loadSteps struct {
+	mutex sync.Mutex
+	steps map[int]*LoadStep
+}
+
+// ParseLoadProfile returns a load profile of type[:minutes], e.g. ramp:3, step:2, or spike:5
+func ParseLoadProfile(str string) (LoadProfile, error) {
+	var err error
+	toks := strings.SplitN(str, ":", 2)
+	profile := LoadProfile{Type: toks[0]}
+	if profile.Type == "" {
+		profile.Type = ProfileFlat
+	}
+	if profile.Type != ProfileFlat && profile.Type != ProfileRamp && profile.Type != ProfileSpike && profile.Type != ProfileStep {
+		return profile, fmt.Errorf("unknown load profile %v, flat, ramp[:minutes], step[:minutes], or spike[:minutes]", str)
+	}
+	if len(toks) > 1 {
+		if profile.Minutes, err = strconv.Atoi(toks[1]); err != nil || profile.Minutes < 1 {
+			return profile, fmt.Errorf("invalid minutes of load profile %v", str)
+		}
+	}
+	return profile, nil
+}
+
+// GetTPS returns TPS of a minute of a simulation of a duration in minutes. A ramp increases linearly to tps over
+// the first minutes, holds, and decreases over the last minutes, a third of the duration if 0. A step increases by
+// tps every minutes, every minute if 0. A spike is 4x tps of a minute every minutes, every 5 minutes if 0.
+func (p LoadProfile) GetTPS(tps int, minute int, duration int) int {
+	switch p.Type {
+	case ProfileRamp:
+		n := p.Minutes
+		if n == 0 {
+			n = duration / 3
+		}
+		if n < 1 {
+			return tps
+		}
+		steps := minute + 1
+		if down := duration - minute; down < steps {
+			steps = down
+		}
+		if steps >= n {
+			return tps
+		}
+		return int(math.Ceil(float64(tps) * float64(steps) / float64(n)))
+	case ProfileStep:
+		n := p.Minutes
+		if n == 0 {
+			n = 1
+		}
+		return tps * (1 + minute/n)
+	case ProfileSpike:
+		n := p.Minutes
+		if n == 0 {
+			n = 5
+		}
+		if (minute+1)%n == 0 {
+			return tps * spikeFactor
+		}
+		return tps
+	default:
+		return tps
+	}
+}
+
+// newLoadSteps returns empty loadSteps
+func newLoadSteps() *loadSteps {
+	return &loadSteps{steps: map[int]*LoadStep{}}
+}
+
+// getStep returns a step, created if not exists, the caller holds the lock
+func (ls *loadSteps) getStep(step int, start time.Time) *LoadStep {
+	s, ok := ls.steps[step]
+	if ok == false {
+		s = &LoadStep{Step: step, Start: start, histogram: mdb.NewLatencyHistogram()}
+		ls.steps[step] = s
+	}
+	return s
+}
+
+// addTarget adds TPS targeted by a thread of a step
+func (ls *loadSteps) addTarget(step int, start time.Time, tps int) {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+	ls.getStep(step, start).TargetTPS += tps
+}
+
+// addOp adds a latency of an operation of a step
+func (ls *loadSteps) addOp(step int, elapsed time.Duration) {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+	s := ls.getStep(step, time.Now())
+	s.ops++
+	s.histogram.Add(int(elapsed / time.Microsecond))
+}
+
+// getLoadSteps returns steps in order with TPS achieved over a minute and latency percentiles
+func (ls *loadSteps) getLoadSteps() []LoadStep {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+	list := []LoadStep{}
+	for _, s := range ls.steps {
+		s.TPS = float64(s.ops) / time.Minute.Seconds()
+		s.P50, s.P95, s.P99 = toMilli(s.histogram.Percentile(50)), toMilli(s.histogram.Percentile(95)), toMilli(s.histogram.Percentile(99))
+		s.Saturated = s.TargetTPS > 0 && s.TPS < saturatedRatio*float64(s.TargetTPS)
+		list = append(list, *s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Step < list[j].Step })
+	return list
+}
+
+// GetLoadStepsSummary returns TPS and latencies of steps, steps not achieving TPS targeted are flagged
+func GetLoadStepsSummary(profile LoadProfile, steps []LoadStep) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("Load profile %v\n", profile.Type))
+	buffer.WriteString(fmt.Sprintf("%5s %-20s %10s %10s %10s %10s %10s\n", "step", "start", "target", "tps", "p50 ms", "p95 ms", "p99 ms"))
+	for _, s := range steps {
+		buffer.WriteString(fmt.Sprintf("%5d %-20s %10d %10.1f %10.2f %10.2f %10.2f", s.Step, s.Start.Format("2006-01-02T15:04:05"),
+			s.TargetTPS, s.TPS, s.P50, s.P95, s.P99))
+		if s.Saturated == true {
+			buffer.WriteString("  SATURATED")
+		}
+		buffer.WriteString("\n")
+	}
+	return buffer.String()
+}
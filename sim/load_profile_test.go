@@ -0,0 +1,64 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package sim
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLoadProfile(t *testing.T) {
+	if profile, err := ParseLoadProfile("step:2"); err != nil || profile.Type != ProfileStep || profile.Minutes != 2 {
+		t.Fatal(profile, err)
+	}
+	if profile, err := ParseLoadProfile(""); err != nil || profile.Type != ProfileFlat {
+		t.Fatal(profile, err)
+	}
+	for _, str := range []string{"wave", "ramp:x", "spike:0"} {
+		if _, err := ParseLoadProfile(str); err == nil {
+			t.Fatal(str)
+		}
+	}
+}
+
+func TestLoadProfileGetTPS(t *testing.T) {
+	tests := []struct {
+		profile LoadProfile
+		tps     []int
+	}{
+		{LoadProfile{Type: ProfileFlat}, []int{100, 100, 100, 100, 100, 100}},
+		{LoadProfile{Type: ProfileRamp, Minutes: 2}, []int{50, 100, 100, 100, 100, 50}},
+		{LoadProfile{Type: ProfileRamp}, []int{50, 100, 100, 100, 100, 50}},
+		{LoadProfile{Type: ProfileStep, Minutes: 2}, []int{100, 100, 200, 200, 300, 300}},
+		{LoadProfile{Type: ProfileSpike, Minutes: 3}, []int{100, 100, 400, 100, 100, 400}},
+	}
+	for _, test := range tests {
+		for minute, tps := range test.tps {
+			if n := test.profile.GetTPS(100, minute, len(test.tps)); n != tps {
+				t.Fatal(test.profile, minute, n)
+			}
+		}
+	}
+}
+
+func TestLoadSteps(t *testing.T) {
+	ls := newLoadSteps()
+	start := time.Date(2019, 10, 1, 10, 0, 0, 0, time.UTC)
+	ls.addTarget(0, start, 1)
+	ls.addTarget(0, start, 1)
+	for i := 0; i < 120; i++ {
+		ls.addOp(0, time.Millisecond)
+	}
+	ls.addTarget(1, start.Add(time.Minute), 4)
+	ls.addOp(1, 10*time.Millisecond)
+	steps := ls.getLoadSteps()
+	if len(steps) != 2 || steps[0].TargetTPS != 2 || steps[0].TPS != 2 || steps[0].Saturated == true || steps[1].Saturated == false {
+		t.Fatal(steps)
+	}
+	str := GetLoadStepsSummary(LoadProfile{Type: ProfileStep}, steps)
+	t.Log(str)
+	if strings.Contains(str, "SATURATED") == false {
+		t.Fatal(str)
+	}
+}
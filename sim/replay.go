@@ -0,0 +1,262 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package sim
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/simagix/keyhole/mdb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ReplayField is the field set by updates replayed, documents matched are otherwise unchanged
+const ReplayField = "_keyholeReplayedAt"
+
+// maxReplayInFlight is the max number of operations in flight, ticks are dropped beyond it
+const maxReplayInFlight = 256
+
+// minReplayInterval is the min interval between operations of an ops pattern, rates beyond it are capped
+const minReplayInterval = 100 * time.Microsecond
+
+// replayCommands are commands of ops patterns replayed, deletes are never replayed and updates only if set
+var replayCommands = []string{"aggregate", "count", "find", "update"}
+
+// Replayer replays ops patterns of a loginfo result as a proportional mix of operations against a cluster
+type Replayer struct {
+	client     *mongo.Client
+	duration   time.Duration
+	multiplier float64
+	mutex      sync.Mutex
+	updates    bool
+	verbose    bool
+}
+
+// ReplayOp is an ops pattern replayed at a rate of its count over the time range of logs
+type ReplayOp struct {
+	Namespace  string  `json:"ns"`
+	Command    string  `json:"command"`
+	Filter     string  `json:"filter"`
+	TargetRate float64 `json:"targetRate"` // ops per second
+	Ops        int     `json:"ops"`
+	OpsPerSec  float64 `json:"opsPerSec"`
+	AvgMilli   float64 `json:"avgMilli"`
+	Errors     int     `json:"errors"`
+	Dropped    int     `json:"dropped"` // ticks skipped as too many operations in flight
+	cmd        mdb.ExplainCommand
+	database   string
+	limit      int64
+	totalMilli float64
+}
+
+// NewReplayer returns a Replayer replaying ops patterns at their rates for 5 minutes
+func NewReplayer(client *mongo.Client) *Replayer {
+	return &Replayer{client: client, duration: 5 * time.Minute, multiplier: 1}
+}
+
+// SetDuration sets how long to replay
+func (rp *Replayer) SetDuration(duration time.Duration) {
+	rp.duration = duration
+}
+
+// SetMultiplier sets a multiplier of rates of ops patterns, e.g. 2 to replay at twice the rates logged
+func (rp *Replayer) SetMultiplier(multiplier float64) {
+	if multiplier > 0 {
+		rp.multiplier = multiplier
+	}
+}
+
+// SetUpdates sets to replay update ops patterns, documents matched are written with ReplayField
+func (rp *Replayer) SetUpdates(updates bool) {
+	rp.updates = updates
+}
+
+// SetVerbose sets verbose level
+func (rp *Replayer) SetVerbose(verbose bool) {
+	rp.verbose = verbose
+}
+
+// Replay replays ops patterns of a loginfo result until the duration ends
+func (rp *Replayer) Replay(li *mdb.LogInfo) ([]ReplayOp, error) {
+	return rp.ReplayContext(context.Background(), li)
+}
+
+// ReplayContext replays ops patterns of a loginfo result until the duration ends or ctx is done
+func (rp *Replayer) ReplayContext(ctx context.Context, li *mdb.LogInfo) ([]ReplayOp, error) {
+	ops := getReplayOps(li, rp.multiplier, rp.updates)
+	if len(ops) == 0 {
+		return []ReplayOp{}, errors.New("no find, count, aggregate, or update ops patterns to replay")
+	}
+	ctx, cancel := context.WithTimeout(ctx, rp.duration)
+	defer cancel()
+	start := time.Now()
+	inFlight := make(chan bool, maxReplayInFlight)
+	var wg sync.WaitGroup
+	var runners sync.WaitGroup
+	for _, op := range ops {
+		runners.Add(1)
+		go func(op *ReplayOp) {
+			defer runners.Done()
+			ticker := time.NewTicker(getReplayInterval(op.TargetRate))
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+				select {
+				case inFlight <- true:
+				default:
+					rp.mutex.Lock()
+					op.Dropped++
+					rp.mutex.Unlock()
+					continue
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					t := time.Now()
+					err := rp.execute(ctx, op)
+					<-inFlight
+					rp.mutex.Lock()
+					defer rp.mutex.Unlock()
+					if err != nil && ctx.Err() == nil {
+						op.Errors++
+						if rp.verbose == true {
+							fmt.Println(op.Command, op.Namespace, err)
+						}
+						return
+					}
+					op.Ops++
+					op.totalMilli += float64(time.Since(t)) / float64(time.Millisecond)
+				}()
+			}
+		}(op)
+	}
+	runners.Wait()
+	wg.Wait()
+	results := []ReplayOp{}
+	seconds := time.Since(start).Seconds()
+	for _, op := range ops {
+		op.OpsPerSec = float64(op.Ops) / seconds
+		if op.Ops > 0 {
+			op.AvgMilli = op.totalMilli / float64(op.Ops)
+		}
+		results = append(results, *op)
+	}
+	return results, nil
+}
+
+// getReplayOps returns ops patterns to replay, rates are counts over the time range of logs, the most frequent first
+func getReplayOps(li *mdb.LogInfo, multiplier float64, updates bool) []*ReplayOp {
+	seconds := li.EndTime.Sub(li.StartTime).Seconds()
+	if seconds < 1 {
+		seconds = 1
+	}
+	ops := []*ReplayOp{}
+	for _, doc := range li.OpsPatterns {
+		if isReplayCommand(doc.Command) == false || doc.Count == 0 || (doc.Command == "update" && updates == false) {
+			continue
+		}
+		cmd, ok := mdb.GetOpsPatternCommand(doc)
+		if ok == false {
+			continue
+		}
+		if cmd.Filter == nil {
+			cmd.Filter = bson.D{}
+		}
+		limit := int64(math.Ceil(float64(doc.NReturned) / float64(doc.Count)))
+		if limit < 1 {
+			limit = 1
+		}
+		ops = append(ops, &ReplayOp{Namespace: doc.Namespace, Command: doc.Command, Filter: doc.Filter, cmd: cmd,
+			database: strings.SplitN(doc.Namespace, ".", 2)[0], limit: limit,
+			TargetRate: multiplier * float64(doc.Count) / seconds})
+	}
+	sort.SliceStable(ops, func(i, j int) bool { return ops[i].TargetRate > ops[j].TargetRate })
+	return ops
+}
+
+// getReplayInterval returns the interval between operations of a rate, no shorter than minReplayInterval
+func getReplayInterval(rate float64) time.Duration {
+	interval := time.Duration(float64(time.Second) / rate)
+	if rate <= 0 || interval < minReplayInterval {
+		return minReplayInterval
+	}
+	return interval
+}
+
+// isReplayCommand returns true if a command is replayed
+func isReplayCommand(command string) bool {
+	for _, c := range replayCommands {
+		if c == command {
+			return true
+		}
+	}
+	return false
+}
+
+// getReplayPipeline returns a pipeline of an aggregate ops pattern
+func getReplayPipeline(cmd mdb.ExplainCommand) mongo.Pipeline {
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: cmd.Filter}}}
+	if len(cmd.Sort) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$sort", Value: cmd.Sort}})
+	}
+	if cmd.Group != "" {
+		pipeline = append(pipeline, bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$" + cmd.Group},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}}}}})
+	}
+	return pipeline
+}
+
+// execute executes an operation of an ops pattern, results are read and discarded
+func (rp *Replayer) execute(ctx context.Context, op *ReplayOp) error {
+	var err error
+	var cur *mongo.Cursor
+	coll := rp.client.Database(op.database).Collection(op.cmd.Collection)
+	switch op.Command {
+	case "count":
+		_, err = coll.CountDocuments(ctx, op.cmd.Filter)
+		return err
+	case "update":
+		_, err = coll.UpdateOne(ctx, op.cmd.Filter, bson.D{{Key: "$currentDate", Value: bson.D{{Key: ReplayField, Value: true}}}})
+		return err
+	case "aggregate":
+		cur, err = coll.Aggregate(ctx, getReplayPipeline(op.cmd))
+	default:
+		opts := options.Find().SetLimit(op.limit)
+		if len(op.cmd.Sort) > 0 {
+			opts.SetSort(op.cmd.Sort)
+		}
+		cur, err = coll.Find(ctx, op.cmd.Filter, opts)
+	}
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+	}
+	return cur.Err()
+}
+
+// GetReplaySummary returns target and achieved rates and latencies of ops patterns replayed
+func GetReplaySummary(ops []ReplayOp) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("%-8s %-30s %10s %10s %10s %8s %8s  %s\n", "command", "namespace", "target/s", "ops/s",
+		"avg ms", "errors", "dropped", "query pattern"))
+	for _, op := range ops {
+		buffer.WriteString(fmt.Sprintf("%-8s %-30s %10.2f %10.2f %10.1f %8d %8d  %s\n", op.Command, op.Namespace, op.TargetRate,
+			op.OpsPerSec, op.AvgMilli, op.Errors, op.Dropped, op.Filter))
+	}
+	return buffer.String()
+}
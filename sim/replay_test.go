@@ -0,0 +1,62 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package sim
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/simagix/keyhole/mdb"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGetReplayOps(t *testing.T) {
+	start := time.Date(2019, 10, 1, 10, 0, 0, 0, time.UTC)
+	li := &mdb.LogInfo{StartTime: start, EndTime: start.Add(100 * time.Second), OpsPatterns: []mdb.OpPerformanceDoc{
+		{Command: "find", Namespace: "keyhole.cars", Filter: `{"color":1}`, Count: 50, NReturned: 120},
+		{Command: "insert", Namespace: "keyhole.cars", Filter: "{}", Count: 500},
+		{Command: "remove", Namespace: "keyhole.cars", Filter: `{"color":1}`, Count: 500},
+		{Command: "count", Namespace: "keyhole.dealers", Filter: `{"name":1}`, Count: 200},
+		{Command: "update", Namespace: "keyhole.cars", Filter: `{"_id":1}`, Count: 100},
+	}}
+	if ops := getReplayOps(li, 2, true); len(ops) != 3 {
+		t.Fatal(ops)
+	}
+	ops := getReplayOps(li, 2, false)
+	if len(ops) != 2 || ops[0].Command != "count" || ops[0].TargetRate != 4 || ops[0].database != "keyhole" {
+		t.Fatal(ops)
+	}
+	if ops[1].TargetRate != 1 || ops[1].limit != 3 || ops[1].cmd.Collection != "cars" {
+		t.Fatal(ops[1])
+	}
+}
+
+func TestGetReplayInterval(t *testing.T) {
+	if d := getReplayInterval(4); d != 250*time.Millisecond {
+		t.Fatal(d)
+	}
+	if d := getReplayInterval(1e12); d != minReplayInterval {
+		t.Fatal(d)
+	}
+}
+
+func TestGetReplayPipeline(t *testing.T) {
+	cmd := mdb.ExplainCommand{Filter: bson.D{{Key: "color", Value: "Red"}}, Sort: bson.D{{Key: "year", Value: -1}}, Group: "brand"}
+	pipeline := getReplayPipeline(cmd)
+	if len(pipeline) != 3 || pipeline[0][0].Key != "$match" || pipeline[1][0].Key != "$sort" || pipeline[2][0].Key != "$group" {
+		t.Fatal(pipeline)
+	}
+	if pipeline = getReplayPipeline(mdb.ExplainCommand{Filter: bson.D{}}); len(pipeline) != 1 {
+		t.Fatal(pipeline)
+	}
+}
+
+func TestGetReplaySummary(t *testing.T) {
+	str := GetReplaySummary([]ReplayOp{{Command: "find", Namespace: "keyhole.cars", Filter: `{"color":1}`, TargetRate: 1,
+		Ops: 59, OpsPerSec: 0.98, AvgMilli: 1.5}})
+	t.Log(str)
+	if strings.Contains(str, "keyhole.cars") == false || strings.Contains(str, "0.98") == false {
+		t.Fatal(str)
+	}
+}
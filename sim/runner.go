@@ -43,6 +43,11 @@ type Runner struct {
 	conns         int
 	txFilename    string
 	simOnly       bool
+	scenario      *Scenario
+	scenarioStats *scenarioStats
+	latencies     *LatencyRecorder
+	loadSteps     *loadSteps
+	profile       LoadProfile
 }
 
 var ssi mdb.ServerInfo
@@ -73,7 +78,8 @@ func NewRunner(uri string, sslCAFile string, sslPEMKeyFile string) (*Runner, err
 		return &runner, err
 	}
 	runner = Runner{uri: uri, sslCAFile: sslCAFile, sslPEMKeyFile: sslPEMKeyFile,
-		cleanup: true, connString: connString, client: client}
+		cleanup: true, connString: connString, client: client, latencies: NewLatencyRecorder(time.Minute),
+		loadSteps: newLoadSteps(), profile: LoadProfile{Type: ProfileFlat}}
 	runner.initSimDocs()
 	return &runner, err
 }
@@ -123,6 +129,11 @@ func (rn *Runner) SetTransactionTemplateFilename(filename string) {
 	rn.txFilename = filename
 }
 
+// SetLoadProfile sets a load profile shaping TPS of minutes
+func (rn *Runner) SetLoadProfile(profile LoadProfile) {
+	rn.profile = profile
+}
+
 // SetSimOnlyMode -
 func (rn *Runner) SetSimOnlyMode(mode bool) {
 	rn.simOnly = mode
@@ -171,13 +182,26 @@ func (rn *Runner) Start() error {
 			}
 		}
 
+		if rn.scenario != nil { // --scenario file
+			log.Printf("Scenario %v: %d threads, duration: %d (mins)\n", rn.scenario.Name, rn.conns, rn.duration)
+			if err = rn.createScenarioIndexes(); err != nil {
+				return err
+			}
+			rn.scenarioStats = newScenarioStats()
+			for i := 0; i < rn.conns; i++ {
+				go rn.simulateScenario(i)
+			}
+			rn.collectAllStatus(uriList)
+			return err
+		}
+
 		// Simulation mode
 		// 1st minute - build up data and memory
 		// 2nd and 3rd minutes - normal TPS ops
 		// remaining minutes - burst with no delay
 		// last minute - normal TPS ops until exit
-		log.Printf("Total TPS: %d (tps) * %d (conns) = %d, duration: %d (mins)\n",
-			rn.tps, rn.conns, rn.tps*rn.conns, rn.duration)
+		log.Printf("Total TPS: %d (tps) * %d (conns) = %d, duration: %d (mins), load profile: %v\n",
+			rn.tps, rn.conns, rn.tps*rn.conns, rn.duration, rn.profile.Type)
 
 		tdoc := GetTransactions(rn.txFilename)
 		rn.CreateIndexes(tdoc.Indexes)
@@ -227,6 +251,21 @@ func (rn *Runner) terminate(uriList []string) {
 	var filename string
 	var err error
 
+	if rn.scenarioStats != nil {
+		log.Println(GetScenarioSummary(rn.scenario.Name, rn.scenarioStats.getOpStats()))
+	}
+	if steps := rn.loadSteps.getLoadSteps(); len(steps) > 0 {
+		log.Println(GetLoadStepsSummary(rn.profile, steps))
+	}
+	if report := rn.latencies.GetReport(); len(report.Overall) > 0 {
+		log.Println(GetLatencyReportTable(report))
+		filename = keyholeStatsDataFile + "-latencies.json"
+		if err = WriteLatencyReport(report, filename); err != nil {
+			log.Println(err)
+		} else {
+			log.Println("latencies written to", filename)
+		}
+	}
 	if rn.cleanup {
 		rn.Cleanup()
 	}
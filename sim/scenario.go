@@ -0,0 +1,276 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package sim
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/simagix/keyhole/mdb"
+	"github.com/simagix/keyhole/sim/util"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// scenarioCommands are commands of operations of scenarios
+var scenarioCommands = []string{"insert", "find", "findOne", "update", "updateAll", "updateMany", "remove", "deleteOne",
+	"removeAll", "deleteMany", "aggregate"}
+
+// Scenario is a load test workload defined in a JSON file, collections are of the simulation database
+type Scenario struct {
+	Name        string               `json:"name"`
+	Duration    int                  `json:"duration"`  // minutes, --duration if 0
+	Threads     int                  `json:"threads"`   // --conn if 0
+	ThinkTime   int                  `json:"thinkTime"` // milliseconds between operations of a thread
+	Collections []ScenarioCollection `json:"collections"`
+	filename    string
+}
+
+// ScenarioCollection is a collection with a document template, indexes, and a mix of operations
+type ScenarioCollection struct {
+	Name         string                 `json:"name"`
+	Template     map[string]interface{} `json:"template"`     // document randomized for inserts, demo doc if empty
+	TemplateFile string                 `json:"templateFile"` // template file, relative to the scenario file
+	Indexes      []json.RawMessage      `json:"indexes"`      // keys of indexes, e.g. {"email": 1, "ts": -1}
+	Operations   []ScenarioOp           `json:"operations"`
+}
+
+// ScenarioOp is an operation of a transaction template and its percentage of the mix
+type ScenarioOp struct {
+	Transaction
+	Percent   float64 `json:"pct"`
+	ThinkTime int     `json:"thinkTime"` // milliseconds after the operation, the scenario think time if 0
+}
+
+// ScenarioOpStats is executions of an operation of a collection
+type ScenarioOpStats struct {
+	Collection string  `json:"collection"`
+	Command    string  `json:"command"`
+	Count      int     `json:"count"`
+	AvgMilli   float64 `json:"avgMilli"`
+	totalMilli float64
+}
+
+// scenarioStats is executions of operations by collections and commands
+type scenarioStats struct {
+	mutex sync.Mutex
+	ops   map[string]*ScenarioOpStats
+}
+
+// LoadScenario reads and validates a scenario from a JSON file
+func LoadScenario(filename string) (*Scenario, error) {
+	var err error
+	var data []byte
+	if data, err = ioutil.ReadFile(filename); err != nil {
+		return nil, err
+	}
+	scenario := &Scenario{filename: filename}
+	if err = json.Unmarshal(data, scenario); err != nil {
+		return nil, err
+	}
+	return scenario, scenario.validate()
+}
+
+// validate returns an error if a collection has no name or operations, or an operation is unknown
+func (s *Scenario) validate() error {
+	var err error
+	if len(s.Collections) == 0 {
+		return fmt.Errorf("%v: no collections defined", s.filename)
+	}
+	for i, coll := range s.Collections {
+		if coll.Name == "" || len(coll.Operations) == 0 {
+			return fmt.Errorf("%v: collection %d has no name or no operations", s.filename, i+1)
+		}
+		total := 0.0
+		for _, op := range coll.Operations {
+			if isScenarioCommand(op.C) == false {
+				return fmt.Errorf("%v: unknown operation %v of %v", s.filename, op.C, coll.Name)
+			} else if op.Percent < 0 {
+				return fmt.Errorf("%v: negative pct of %v of %v", s.filename, op.C, coll.Name)
+			}
+			total += op.Percent
+		}
+		if total == 0 {
+			return fmt.Errorf("%v: pct of operations of %v are all 0", s.filename, coll.Name)
+		}
+		if coll.TemplateFile != "" && len(coll.Template) == 0 {
+			filename := coll.TemplateFile
+			if filepath.IsAbs(filename) == false {
+				filename = filepath.Join(filepath.Dir(s.filename), filename)
+			}
+			var doc bson.M
+			if doc, err = util.GetDocByTemplate(filename, true); err != nil {
+				return err
+			}
+			data, _ := json.Marshal(doc)
+			json.Unmarshal(data, &s.Collections[i].Template)
+		}
+	}
+	return err
+}
+
+// isScenarioCommand returns true if a command is supported by scenarios
+func isScenarioCommand(command string) bool {
+	for _, c := range scenarioCommands {
+		if c == command {
+			return true
+		}
+	}
+	return false
+}
+
+// getIndexKeys returns keys of indexes of a collection in orders defined
+func (coll ScenarioCollection) getIndexKeys() ([]bson.D, error) {
+	var err error
+	list := []bson.D{}
+	for _, raw := range coll.Indexes {
+		var keys bson.D
+		if err = bson.UnmarshalExtJSON(raw, false, &keys); err != nil {
+			return list, fmt.Errorf("index %v of %v: %v", string(raw), coll.Name, err)
+		}
+		list = append(list, keys)
+	}
+	return list, err
+}
+
+// pickOp returns an operation of the mix by a random number of [0, 1)
+func (coll ScenarioCollection) pickOp(r float64) ScenarioOp {
+	total := 0.0
+	for _, op := range coll.Operations {
+		total += op.Percent
+	}
+	x := r * total
+	for _, op := range coll.Operations {
+		if x < op.Percent {
+			return op
+		}
+		x -= op.Percent
+	}
+	return coll.Operations[len(coll.Operations)-1]
+}
+
+// getDoc returns a document randomized from the template
+func (coll ScenarioCollection) getDoc() bson.M {
+	if len(coll.Template) == 0 {
+		return util.GetDemoDoc()
+	}
+	doc := make(map[string]interface{})
+	util.RandomizeDocument(&doc, coll.Template, false)
+	return doc
+}
+
+// SetScenario sets a scenario to run instead of the default transactions, its duration and threads override
+func (rn *Runner) SetScenario(scenario *Scenario) {
+	rn.scenario = scenario
+	if scenario.Duration > 0 {
+		rn.duration = scenario.Duration
+	}
+	if scenario.Threads > 0 {
+		rn.conns = scenario.Threads
+	}
+}
+
+// createScenarioIndexes creates indexes of collections of the scenario
+func (rn *Runner) createScenarioIndexes() error {
+	var err error
+	ctx := context.Background()
+	for _, coll := range rn.scenario.Collections {
+		var list []bson.D
+		if list, err = coll.getIndexKeys(); err != nil {
+			return err
+		}
+		indexView := rn.client.Database(SimDBName).Collection(coll.Name).Indexes()
+		for _, keys := range list {
+			if _, err = indexView.CreateOne(ctx, mongo.IndexModel{Keys: keys}); err != nil {
+				return err
+			}
+		}
+	}
+	return err
+}
+
+// simulateScenario runs operations of the scenario mix of all collections until the duration ends
+func (rn *Runner) simulateScenario(thread int) {
+	var err error
+	var client *mongo.Client
+	var ctx = context.Background()
+	if client, err = mdb.NewMongoClient(rn.uri, rn.sslCAFile, rn.sslPEMKeyFile); err != nil {
+		panic(err)
+	}
+	defer client.Disconnect(ctx)
+	deadline := time.Now().Add(time.Duration(rn.duration) * time.Minute)
+	collections := rn.scenario.Collections
+	for n := thread; time.Now().Before(deadline); n++ {
+		coll := collections[n%len(collections)]
+		op := coll.pickOp(rand.Float64())
+		c := client.Database(SimDBName).Collection(coll.Name)
+		t := time.Now()
+		execTXByTemplateAndTX(c, coll.getDoc(), []Transaction{op.Transaction})
+		elapsed := time.Since(t)
+		rn.scenarioStats.add(coll.Name, op.C, elapsed)
+		rn.latencies.Add(op.C, time.Now(), elapsed)
+		thinkTime := op.ThinkTime
+		if thinkTime == 0 {
+			thinkTime = rn.scenario.ThinkTime
+		}
+		if thinkTime > 0 {
+			time.Sleep(time.Duration(thinkTime) * time.Millisecond)
+		}
+	}
+}
+
+// newScenarioStats returns an empty scenarioStats
+func newScenarioStats() *scenarioStats {
+	return &scenarioStats{ops: map[string]*ScenarioOpStats{}}
+}
+
+// add adds an execution of an operation
+func (s *scenarioStats) add(collection string, command string, elapsed time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := collection + " " + command
+	stats, ok := s.ops[key]
+	if ok == false {
+		stats = &ScenarioOpStats{Collection: collection, Command: command}
+		s.ops[key] = stats
+	}
+	stats.Count++
+	stats.totalMilli += float64(elapsed) / float64(time.Millisecond)
+}
+
+// getOpStats returns executions of operations by collections and commands
+func (s *scenarioStats) getOpStats() []ScenarioOpStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	list := []ScenarioOpStats{}
+	for _, stats := range s.ops {
+		stats.AvgMilli = stats.totalMilli / float64(stats.Count)
+		list = append(list, *stats)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Collection != list[j].Collection {
+			return list[i].Collection < list[j].Collection
+		}
+		return list[i].Command < list[j].Command
+	})
+	return list
+}
+
+// GetScenarioSummary returns executions of operations of a scenario
+func GetScenarioSummary(name string, list []ScenarioOpStats) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("Scenario %v\n", name))
+	buffer.WriteString(fmt.Sprintf("%-24s %-12s %10s %10s\n", "collection", "command", "count", "avg ms"))
+	for _, stats := range list {
+		buffer.WriteString(fmt.Sprintf("%-24s %-12s %10d %10.2f\n", stats.Collection, stats.Command, stats.Count, stats.AvgMilli))
+	}
+	return buffer.String()
+}
@@ -0,0 +1,66 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package sim
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadScenario(t *testing.T) {
+	scenario, err := LoadScenario("../examples/scenario.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	coll := scenario.Collections[0]
+	if scenario.Threads != 4 || len(coll.Operations) != 4 || coll.Template["email"] == nil {
+		t.Fatal(scenario)
+	}
+	keys, err := coll.getIndexKeys()
+	if err != nil || len(keys) != 1 || keys[0][0].Key != "email" || keys[0][1].Key != "lastUpdated" {
+		t.Fatal(keys, err)
+	}
+	if doc := coll.getDoc(); doc["email"] == nil || doc["_id"] == nil {
+		t.Fatal(doc)
+	}
+}
+
+func TestScenarioValidate(t *testing.T) {
+	scenario := &Scenario{Collections: []ScenarioCollection{{Name: "examples",
+		Operations: []ScenarioOp{{Transaction: Transaction{C: "mapReduce"}, Percent: 10}}}}}
+	if err := scenario.validate(); err == nil {
+		t.Fatal("mapReduce should be rejected")
+	}
+	scenario.Collections[0].Operations[0].C = "find"
+	scenario.Collections[0].Operations[0].Percent = 0
+	if err := scenario.validate(); err == nil {
+		t.Fatal("pct all 0 should be rejected")
+	}
+}
+
+func TestPickOp(t *testing.T) {
+	coll := ScenarioCollection{Operations: []ScenarioOp{{Transaction: Transaction{C: "insert"}, Percent: 30},
+		{Transaction: Transaction{C: "find"}, Percent: 60}, {Transaction: Transaction{C: "update"}, Percent: 10}}}
+	for r, c := range map[float64]string{0: "insert", 0.29: "insert", 0.3: "find", 0.89: "find", 0.9: "update", 0.999: "update"} {
+		if op := coll.pickOp(r); op.C != c {
+			t.Fatal(r, op.C)
+		}
+	}
+}
+
+func TestGetScenarioSummary(t *testing.T) {
+	stats := newScenarioStats()
+	stats.add("examples", "find", 2*time.Millisecond)
+	stats.add("examples", "find", 4*time.Millisecond)
+	stats.add("examples", "insert", time.Millisecond)
+	list := stats.getOpStats()
+	if len(list) != 2 || list[0].Command != "find" || list[0].Count != 2 || list[0].AvgMilli != 3 {
+		t.Fatal(list)
+	}
+	str := GetScenarioSummary("examples", list)
+	t.Log(str)
+	if strings.Contains(str, "Scenario examples") == false {
+		t.Fatal(str)
+	}
+}
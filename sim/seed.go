@@ -23,12 +23,16 @@ import (
 
 // Feeder seeds feeder
 type Feeder struct {
+	batchSize    int
 	collection   string
 	database     string
 	file         string
 	isDrop       bool
+	sampleFrom   string // collection to sample documents from as templates
+	sampleSize   int
 	showProgress bool
 	total        int
+	workers      int
 }
 
 // Model - robot model
@@ -56,7 +60,14 @@ type Robot struct {
 
 // NewFeeder establish seeding parameters
 func NewFeeder() *Feeder {
-	return &Feeder{isDrop: false, total: 1000, showProgress: true}
+	return &Feeder{batchSize: 100, isDrop: false, sampleSize: 1000, total: 1000, showProgress: true, workers: runtime.NumCPU()}
+}
+
+// SetBatchSize sets number of documents of an insertMany of seeding from templates
+func (f *Feeder) SetBatchSize(batchSize int) {
+	if batchSize > 0 {
+		f.batchSize = batchSize
+	}
 }
 
 // SetCollection set collection
@@ -79,6 +90,18 @@ func (f *Feeder) SetIsDrop(isDrop bool) {
 	f.isDrop = isDrop
 }
 
+// SetSampleFrom sets a collection to sample documents from as templates
+func (f *Feeder) SetSampleFrom(sampleFrom string) {
+	f.sampleFrom = sampleFrom
+}
+
+// SetSampleSize sets number of documents to sample as templates
+func (f *Feeder) SetSampleSize(sampleSize int) {
+	if sampleSize > 0 {
+		f.sampleSize = sampleSize
+	}
+}
+
 // SetShowProgress set showProgress
 func (f *Feeder) SetShowProgress(showProgress bool) {
 	f.showProgress = showProgress
@@ -89,14 +112,24 @@ func (f *Feeder) SetTotal(total int) {
 	f.total = total
 }
 
+// SetWorkers sets number of concurrent insertMany of seeding from templates
+func (f *Feeder) SetWorkers(workers int) {
+	if workers > 0 {
+		f.workers = workers
+	}
+}
+
 // SeedData seeds all demo data
 func (f *Feeder) SeedData(client *mongo.Client) error {
-	if f.file == "" {
+	if f.file == "" && f.sampleFrom == "" {
 		f.SeedAllDemoData(client)
 		return nil
 	} else {
 		if f.collection == "" {
-			return errors.New("usage: keyhole --uri connection_uri --seed [--file filename --collection collection_name]")
+			return errors.New("usage: keyhole --uri connection_uri --seed [--file filename|--sampleFrom collection_name] --collection collection_name")
+		}
+		if f.sampleFrom != "" {
+			return f.seedFromSamples(client)
 		}
 		return f.seedFromTemplate(client)
 	}
@@ -348,8 +381,6 @@ func (f *Feeder) seedCollection(c *mongo.Collection, fnum int) int {
 func (f *Feeder) seedFromTemplate(client *mongo.Client) error {
 	var err error
 	var ctx = context.Background()
-	var bsize = 100
-	var remaining = f.total
 	var sdoc bson.M
 	if sdoc, err = util.GetDocByTemplate(f.file, true); err != nil {
 		return err
@@ -367,38 +398,71 @@ func (f *Feeder) seedFromTemplate(client *mongo.Client) error {
 		c.Drop(ctx)
 	}
 
-	var wg = gox.NewWaitGroup(runtime.NumCPU())
-	for threadNum := 0; threadNum < f.total; threadNum += bsize {
+	f.insertDocs(c, func() interface{} {
+		ndoc := make(map[string]interface{})
+		util.RandomizeDocument(&ndoc, doc, false)
+		return ndoc
+	})
+	cnt, _ := c.CountDocuments(ctx, bson.M{})
+	fmt.Printf("\rSeeded %s: %d, total count: %d\n", collName, f.total, cnt)
+	return err
+}
+
+// seedFromSamples seeds data like documents sampled from a collection, preserving types, structures, and
+// cardinalities of fields
+func (f *Feeder) seedFromSamples(client *mongo.Client) error {
+	var err error
+	var ctx = context.Background()
+	var docs []bson.D
+	if docs, err = sampleDocs(client.Database(f.database).Collection(f.sampleFrom), f.sampleSize); err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		return fmt.Errorf("no documents sampled from %v.%v", f.database, f.sampleFrom)
+	}
+	ds := newDocSampler(docs)
+	log.Println("Seed data to collection", f.collection, "like", len(docs), "documents sampled from", f.sampleFrom)
+	c := client.Database(f.database).Collection(f.collection)
+	if f.isDrop {
+		c.Drop(ctx)
+	}
+	f.insertDocs(c, func() interface{} { return ds.getDoc() })
+	cnt, _ := c.CountDocuments(ctx, bson.M{})
+	fmt.Printf("\rSeeded %s: %d, total count: %d\n", f.collection, f.total, cnt)
+	return err
+}
+
+// insertDocs inserts total documents of a generator in batches by concurrent workers, duplicates are ignored
+func (f *Feeder) insertDocs(c *mongo.Collection, getDoc func() interface{}) {
+	var ctx = context.Background()
+	var remaining = f.total
+	var wg = gox.NewWaitGroup(f.workers)
+	for remaining > 0 {
 		wg.Add(1)
-		num := bsize
-		if remaining < bsize {
+		num := f.batchSize
+		if remaining < num {
 			num = remaining
 		}
 		remaining -= num
 		if f.showProgress {
 			fmt.Fprintf(os.Stderr, "\r%3.1f%% ", float64(100*(f.total-remaining))/float64(f.total))
 		}
-		go func(threadNum int, num int) {
+		go func(num int) {
 			defer wg.Done()
 			var contentArray []interface{}
 			for n := 0; n < num; n++ {
-				ndoc := make(map[string]interface{})
-				util.RandomizeDocument(&ndoc, doc, false)
-				contentArray = append(contentArray, ndoc)
+				contentArray = append(contentArray, getDoc())
 			}
 			opts := options.InsertMany()
 			opts.SetOrdered(false) // ignore duplication errors
 			c.InsertMany(ctx, contentArray, opts)
-		}(threadNum, num)
+		}(num)
 	}
 	wg.Wait()
 
 	if f.showProgress {
 		fmt.Fprintf(os.Stderr, "\r100%%   \n")
 	}
-	cnt, _ := c.CountDocuments(ctx, bson.M{})
-	fmt.Printf("\rSeeded %s: %d, total count: %d\n", collName, f.total, cnt)
-	return err
 }
 
 func getEmployee(id int, supervisor int) bson.M {
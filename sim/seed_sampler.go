@@ -0,0 +1,169 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package sim
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/simagix/keyhole/sim/util"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// lowCardinalityRatio is the max ratio of distinct values to values sampled of a field to reuse values sampled
+const lowCardinalityRatio = 0.1
+
+// docSampler generates documents of the structures of documents sampled, values of low cardinality fields are
+// picked from values sampled and others are randomized by their types within ranges sampled
+type docSampler struct {
+	docs     []bson.D
+	distinct map[string]map[string]bool
+	max      map[string]float64
+	min      map[string]float64
+	values   map[string][]interface{} // values sampled by paths, elements of arrays are of path field[]
+}
+
+// newDocSampler returns a docSampler of documents sampled
+func newDocSampler(docs []bson.D) *docSampler {
+	ds := &docSampler{docs: docs, distinct: map[string]map[string]bool{}, max: map[string]float64{},
+		min: map[string]float64{}, values: map[string][]interface{}{}}
+	for _, doc := range docs {
+		ds.addDoc(doc, "")
+	}
+	return ds
+}
+
+// sampleDocs returns documents sampled from a collection
+func sampleDocs(c *mongo.Collection, sampleSize int) ([]bson.D, error) {
+	var err error
+	var cur *mongo.Cursor
+	ctx := context.Background()
+	pipeline := mongo.Pipeline{{{Key: "$sample", Value: bson.D{{Key: "size", Value: sampleSize}}}}}
+	if cur, err = c.Aggregate(ctx, pipeline); err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	docs := []bson.D{}
+	for cur.Next(ctx) {
+		var doc bson.D
+		if err = cur.Decode(&doc); err != nil {
+			return docs, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, cur.Err()
+}
+
+// addDoc adds values of a document
+func (ds *docSampler) addDoc(doc bson.D, prefix string) {
+	for _, elem := range doc {
+		ds.addValue(prefix+elem.Key, elem.Value)
+	}
+}
+
+// addValue adds a value of a path
+func (ds *docSampler) addValue(path string, value interface{}) {
+	switch v := value.(type) {
+	case bson.D:
+		ds.addDoc(v, path+".")
+		return
+	case primitive.A:
+		for _, elem := range v {
+			ds.addValue(path+"[]", elem)
+		}
+		return
+	}
+	ds.values[path] = append(ds.values[path], value)
+	if ds.distinct[path] == nil {
+		ds.distinct[path] = map[string]bool{}
+	}
+	ds.distinct[path][fmt.Sprintf("%T:%v", value, value)] = true
+	if x, ok := getSampledNumber(value); ok == true {
+		if _, ok = ds.min[path]; ok == false || x < ds.min[path] {
+			ds.min[path] = x
+		}
+		if _, ok = ds.max[path]; ok == false || x > ds.max[path] {
+			ds.max[path] = x
+		}
+	}
+}
+
+// isLowCardinality returns true if distinct values of a path are few compared to values sampled
+func (ds *docSampler) isLowCardinality(path string) bool {
+	n := len(ds.values[path])
+	return n > 1 && float64(len(ds.distinct[path])) <= lowCardinalityRatio*float64(n)
+}
+
+// getDoc returns a document of the structure of a document sampled
+func (ds *docSampler) getDoc() bson.D {
+	if len(ds.docs) == 0 {
+		return bson.D{}
+	}
+	return ds.getSubdoc(ds.docs[rand.Intn(len(ds.docs))], "")
+}
+
+// getSubdoc returns a document of values generated
+func (ds *docSampler) getSubdoc(doc bson.D, prefix string) bson.D {
+	ndoc := make(bson.D, 0, len(doc))
+	for _, elem := range doc {
+		ndoc = append(ndoc, bson.E{Key: elem.Key, Value: ds.getValue(prefix+elem.Key, elem.Value)})
+	}
+	return ndoc
+}
+
+// getValue returns a value of a path of the type of a value sampled
+func (ds *docSampler) getValue(path string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.D:
+		return ds.getSubdoc(v, path+".")
+	case primitive.A:
+		arr := make(primitive.A, 0, len(v))
+		for _, elem := range v {
+			arr = append(arr, ds.getValue(path+"[]", elem))
+		}
+		return arr
+	}
+	if ds.isLowCardinality(path) == true {
+		values := ds.values[path]
+		return values[rand.Intn(len(values))]
+	}
+	x := ds.min[path] + rand.Float64()*(ds.max[path]-ds.min[path])
+	switch v := value.(type) {
+	case int32:
+		return int32(math.Round(x))
+	case int64:
+		return int64(math.Round(x))
+	case float64:
+		return math.Round(x*100) / 100
+	case primitive.DateTime:
+		return primitive.DateTime(int64(x))
+	case primitive.ObjectID:
+		return primitive.NewObjectID()
+	case bool:
+		return rand.Intn(2) == 0
+	case string:
+		return util.GetRandomizedString(v)
+	default:
+		return value
+	}
+}
+
+// getSampledNumber returns a number of a numeric or date value
+func getSampledNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case primitive.DateTime:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
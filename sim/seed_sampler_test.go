@@ -0,0 +1,50 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package sim
+
+import (
+	"fmt"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDocSampler(t *testing.T) {
+	docs := []bson.D{}
+	for i := 0; i < 100; i++ {
+		docs = append(docs, bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "color", Value: []string{"red", "blue"}[i%2]},
+			{Key: "year", Value: int32(2000 + i)}, {Key: "price", Value: float64(i) * 1.5},
+			{Key: "specs", Value: bson.D{{Key: "hp", Value: int64(100 + i)}, {Key: "used", Value: i%3 == 0}}},
+			{Key: "tags", Value: primitive.A{fmt.Sprintf("tag-%d", i), "sale"}}})
+	}
+	ds := newDocSampler(docs)
+	if ds.isLowCardinality("color") == false || ds.isLowCardinality("year") == true || ds.isLowCardinality("specs.used") == false {
+		t.Fatal(ds.distinct)
+	}
+	for i := 0; i < 100; i++ {
+		doc := ds.getDoc()
+		m := doc.Map()
+		if len(doc) != 6 || (m["color"] != "red" && m["color"] != "blue") {
+			t.Fatal(doc)
+		}
+		if year, ok := m["year"].(int32); ok == false || year < 2000 || year > 2099 {
+			t.Fatal(doc)
+		}
+		if price, ok := m["price"].(float64); ok == false || price < 0 || price > 148.5 {
+			t.Fatal(doc)
+		}
+		if hp, ok := m["specs"].(bson.D).Map()["hp"].(int64); ok == false || hp < 100 || hp > 199 {
+			t.Fatal(doc)
+		}
+		if tags, ok := m["tags"].(primitive.A); ok == false || len(tags) != 2 {
+			t.Fatal(doc)
+		}
+	}
+}
+
+func TestDocSamplerEmpty(t *testing.T) {
+	if doc := newDocSampler([]bson.D{}).getDoc(); len(doc) != 0 {
+		t.Fatal(doc)
+	}
+}
@@ -117,10 +117,14 @@ func (rn *Runner) Simulate(duration int, transactions []Transaction, thread int)
 		} else {
 			totalTPS = rn.tps / 2
 		}
+		if rn.profile.Type != ProfileFlat && isTeardown == false {
+			totalTPS = rn.profile.GetTPS(rn.tps, run, duration)
+		}
 
 		batchCount := 0
 		totalCount := 0
 		beginTime := time.Now()
+		rn.loadSteps.addTarget(run, beginTime, totalTPS)
 		counter := 0
 		results := []bson.M{}
 		for time.Now().Sub(beginTime) < time.Minute {
@@ -132,12 +136,22 @@ func (rn *Runner) Simulate(duration int, transactions []Transaction, thread int)
 				if isTeardown {
 					c.DeleteMany(ctx, bson.M{"_search": doc["_search"]})
 				} else if len(transactions) > 0 { // --file and --tx
-					txCount += execTXByTemplateAndTX(c, util.CloneDoc(doc), transactions)
+					for _, tx := range transactions {
+						t := time.Now()
+						txCount += execTXByTemplateAndTX(c, util.CloneDoc(doc), []Transaction{tx})
+						elapsed := time.Since(t)
+						rn.latencies.Add(tx.C, time.Now(), elapsed)
+						rn.loadSteps.addOp(run, elapsed)
+					}
 				} else {
 					var res bson.M
 					if res, err = execTx(c, doc); err != nil {
 						break
 					}
+					for k, v := range res {
+						rn.latencies.Add(k, time.Now(), v.(time.Duration))
+						rn.loadSteps.addOp(run, v.(time.Duration))
+					}
 					if thread == 0 {
 						results = append(results, res)
 					}
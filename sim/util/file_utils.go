@@ -3,38 +3,100 @@
 package util
 
 import (
+	"archive/tar"
 	"bufio"
 	"bytes"
+	"compress/bzip2"
 	"compress/gzip"
 	"io"
 	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
 )
 
-// NewReader returns a reader from either a gzip or plain file
+// NewReader returns a reader from a plain, gzip, bzip2, zstd, or tar file, or a compressed tar file
 func NewReader(file *os.File) (*bufio.Reader, error) {
-	var buf []byte
 	var err error
 	var reader *bufio.Reader
 
 	reader = bufio.NewReader(file)
-	if buf, err = reader.Peek(2); err != nil {
+	if _, err = reader.Peek(2); err != nil {
 		return reader, err
 	}
 	file.Seek(0, 0)
+	return newDecompressReader(bufio.NewReader(file))
+}
 
-	if buf[0] == 31 && buf[1] == 139 {
+// newDecompressReader returns a reader of decompressed and untarred contents by magic numbers
+func newDecompressReader(reader *bufio.Reader) (*bufio.Reader, error) {
+	var err error
+	buf, _ := reader.Peek(4)
+	if len(buf) >= 2 && buf[0] == 0x1f && buf[1] == 0x8b {
 		var zreader *gzip.Reader
-		if zreader, err = gzip.NewReader(file); err != nil {
+		if zreader, err = gzip.NewReader(reader); err != nil {
+			return reader, err
+		}
+		return newDecompressReader(bufio.NewReader(zreader))
+	} else if len(buf) >= 3 && string(buf[:3]) == "BZh" {
+		return newDecompressReader(bufio.NewReader(bzip2.NewReader(reader)))
+	} else if len(buf) == 4 && bytes.Equal(buf, zstdMagic) {
+		var zreader *zstd.Decoder
+		if zreader, err = zstd.NewReader(reader, zstd.WithDecoderConcurrency(1)); err != nil {
 			return reader, err
 		}
-		reader = bufio.NewReader(zreader)
-	} else {
-		reader = bufio.NewReader(file)
+		return newDecompressReader(bufio.NewReader(zreader))
+	} else if buf, _ = reader.Peek(tarMagicOffset + len(tarMagic)); len(buf) == tarMagicOffset+len(tarMagic) &&
+		string(buf[tarMagicOffset:]) == tarMagic {
+		return bufio.NewReader(&tarReader{tr: tar.NewReader(reader), last: '\n'}), nil
 	}
-
 	return reader, nil
 }
 
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+const tarMagic = "ustar"
+const tarMagicOffset = 257
+
+// tarReader reads regular files of a tar archive one after another, FTDC files are skipped
+type tarReader struct {
+	tr     *tar.Reader
+	reader io.Reader
+	last   byte
+}
+
+func (t *tarReader) Read(p []byte) (int, error) {
+	for {
+		if t.reader != nil {
+			n, err := t.reader.Read(p)
+			if n > 0 {
+				t.last = p[n-1]
+				return n, nil
+			}
+			if err != io.EOF {
+				return n, err
+			}
+			t.reader = nil
+			if t.last != '\n' && len(p) > 0 { // files don't run into each other
+				p[0], t.last = '\n', '\n'
+				return 1, nil
+			}
+		}
+		header, err := t.tr.Next()
+		if err != nil {
+			return 0, err
+		}
+		if header.Typeflag != tar.TypeReg || strings.Contains(header.Name, "diagnostic.data/") {
+			continue
+		}
+		var reader *bufio.Reader
+		if reader, err = newDecompressReader(bufio.NewReader(t.tr)); err != nil {
+			return 0, err
+		}
+		t.reader = reader
+	}
+}
+
 // CountLines count number of '\n'
 func CountLines(reader *bufio.Reader) (int, error) {
 	buf := make([]byte, 32*1024)
@@ -3,10 +3,15 @@
 package util
 
 import (
+	"archive/tar"
 	"bufio"
 	"compress/gzip"
+	"encoding/hex"
+	"io/ioutil"
 	"os"
 	"testing"
+
+	"github.com/klauspost/compress/zstd"
 )
 
 func TestNewReader(t *testing.T) {
@@ -33,6 +38,72 @@ func TestNewReader(t *testing.T) {
 	}
 }
 
+func TestNewReaderBzip2(t *testing.T) {
+	data, _ := hex.DecodeString("425a6839314159265359ae935c9a00000241800010024c80202000221a3d4f508602022be2ee48a70a1215d26b9340")
+	filename := "/tmp/count.file.bz2"
+	if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, _ := os.Open(filename)
+	defer file.Close()
+	reader, _ := NewReader(file)
+	buf, _, _ := reader.ReadLine()
+	if string(buf) != "keyhole" {
+		t.Fatal(string(buf))
+	}
+}
+
+func TestNewReaderZstd(t *testing.T) {
+	var err error
+	var file *os.File
+	filename := "/tmp/count.file.zst"
+	if file, err = os.Create(filename); err != nil {
+		t.Fatal(err)
+	}
+	writer, _ := zstd.NewWriter(file)
+	writer.Write([]byte("keyhole\n"))
+	writer.Close()
+	file.Close()
+
+	file, _ = os.Open(filename)
+	defer file.Close()
+	reader, _ := NewReader(file)
+	buf, _, _ := reader.ReadLine()
+	if string(buf) != "keyhole" {
+		t.Fatal(string(buf))
+	}
+}
+
+func TestNewReaderTarGzip(t *testing.T) {
+	var err error
+	var file *os.File
+	filename := "/tmp/count.file.tar.gz"
+	if file, err = os.Create(filename); err != nil {
+		t.Fatal(err)
+	}
+	zwriter := gzip.NewWriter(file)
+	writer := tar.NewWriter(zwriter)
+	for _, entry := range []struct{ name, body string }{
+		{"logs/mongod.log", "line 1\nline 2"},
+		{"logs/diagnostic.data/metrics.2019-09-28T10-00-00Z-00000", "binary"},
+		{"logs/mongod.log.1", "line 3\n"},
+	} {
+		writer.WriteHeader(&tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.body)), Typeflag: tar.TypeReg})
+		writer.Write([]byte(entry.body))
+	}
+	writer.Close()
+	zwriter.Close()
+	file.Close()
+
+	file, _ = os.Open(filename)
+	defer file.Close()
+	reader, _ := NewReader(file)
+	count, _ := CountLines(reader)
+	if count != 3 {
+		t.Fatal("expected 3 lines, but got", count)
+	}
+}
+
 func TestCountLines(t *testing.T) {
 	var err error
 	var file *os.File
@@ -144,6 +144,11 @@ const metaTEL = "$tel"
 const metaDate = "$date"
 const metaOID = "$oId"
 
+// GetRandomizedString returns a random string like str, e.g. another email address of an email address
+func GetRandomizedString(str string) string {
+	return getMagicString(str, false)
+}
+
 // Returns randomized string.  if meta is true, it intends to avoid future regex
 // actions by replacing the values with $email, $ip, and $date.
 func getMagicString(str string, meta bool) string {
@@ -46,6 +46,15 @@ func TestGetMagicString(t *testing.T) {
 	}
 }
 
+func TestGetRandomizedString(t *testing.T) {
+	if str := GetRandomizedString("ken.chen@simagix.com"); isEmailAddress(str) == false {
+		t.Fatal(str)
+	}
+	if str := GetRandomizedString("keyhole"); len(str) != len("keyhole") {
+		t.Fatal(str)
+	}
+}
+
 func TestIsEmailAddress(t *testing.T) {
 	email := "ken.chen@simagix.com"
 	if isEmailAddress(email) == false {